@@ -0,0 +1,51 @@
+package pbkdf2
+
+import (
+	"runtime"
+	"time"
+)
+
+// Operations reported in Usage.Op.
+const (
+	UsageHash   = "hash"
+	UsageVerify = "verify"
+)
+
+// Usage reports the cost of a key derivation performed by a Hasher, so that
+// multi-tenant platforms can meter hashing per tenant. See Hasher.OnUsage.
+type Usage struct {
+	// Op is UsageHash when a hash was created, and UsageVerify when one was
+	// verified.
+	Op string
+
+	// Labels are those of the context of the call; see WithLabels.
+	Labels map[string]string
+
+	// Params are the params of the hash created or verified.
+	Params *Params
+
+	// Wall is the elapsed time, and CPU the CPU time consumed by the thread
+	// deriving the key. CPU is zero where it cannot be measured; see
+	// BackendInfo.CPUTime.
+	Wall time.Duration
+	CPU  time.Duration
+}
+
+// measure calls f, which derives a key, and returns the wall and CPU time it
+// took. The goroutine is locked to its thread meanwhile, so that the thread's
+// CPU time is the goroutine's.
+func measure(f func()) (wall, cpu time.Duration) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	startCPU, ok := threadCPUTime()
+	start := time.Now()
+	f()
+	wall = time.Since(start)
+	if ok {
+		if end, ok := threadCPUTime(); ok {
+			cpu = end - startCPU
+		}
+	}
+	return wall, cpu
+}
@@ -0,0 +1,65 @@
+package pbkdf2
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestOnUsage(t *testing.T) {
+	var mu sync.Mutex
+	var usage []Usage
+	h := &Hasher{
+		Params: &Params{Iterations: 20000, SaltLength: 16, KeyLength: 32},
+		Policy: &Policy{MaxIterations: 50000},
+		OnUsage: func(u Usage) {
+			mu.Lock()
+			defer mu.Unlock()
+			usage = append(usage, u)
+		},
+	}
+	hash, err := h.CreateHash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Verify("wrong", hash); err != ErrMismatchedHashAndPassword {
+		t.Fatalf("expected ErrMismatchedHashAndPassword, got %v", err)
+	}
+	// Rejected by the policy before deriving a key, so not reported.
+	expensive, err := CreateHash("password", &Params{Iterations: 60000, SaltLength: 16, KeyLength: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Verify("password", expensive); err == nil {
+		t.Fatal("expected policy violation")
+	}
+
+	if len(usage) != 2 || usage[0].Op != UsageHash || usage[1].Op != UsageVerify {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+	for _, u := range usage {
+		if u.Params.Iterations != 20000 || u.Wall <= 0 {
+			t.Errorf("unexpected usage %+v", u)
+		}
+		if Backend().CPUTime && u.CPU <= 0 {
+			t.Errorf("expected CPU time to be measured: %+v", u)
+		}
+	}
+}
+
+func TestCheckHashTimedCPUTime(t *testing.T) {
+	if runtime.GOOS == "linux" && !Backend().CPUTime {
+		t.Error("expected CPU time to be measured on Linux")
+	}
+	hash, err := CreateHash("password", &Params{Iterations: 20000, SaltLength: 16, KeyLength: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := CheckHashTimed("password", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Backend().CPUTime && (result.CPUTime <= 0 || result.CPUTime > 10*result.Duration) {
+		t.Errorf("implausible CPU time %v for duration %v", result.CPUTime, result.Duration)
+	}
+}
@@ -0,0 +1,255 @@
+// Package ansiblevault encrypts and decrypts Ansible Vault files, so that Go
+// tooling can read and write vault-encrypted secrets without shelling out to
+// ansible-vault.
+//
+// Vault format 1.1 and 1.2 files use the AES256 cipher: the vault password is
+// stretched with PBKDF2-HMAC-SHA256 over a random 32-byte salt, 10000
+// iterations, into an AES-256 key, an HMAC-SHA256 key and a CTR-mode IV. The
+// plaintext is padded with PKCS #7, encrypted with AES-256-CTR, and
+// authenticated with an HMAC of the ciphertext:
+//
+//	plaintext, err := ansiblevault.Decrypt(data, password)
+//	data, err := ansiblevault.Encrypt(plaintext, password, "")
+//
+// Ansible strips trailing whitespace, such as a final newline, from passwords
+// read from files; callers reading a password file should do the same.
+package ansiblevault
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// Iterations is the PBKDF2 iteration count of the AES256 cipher.
+const Iterations = 10000
+
+// SaltSize is the length of the salt of new vaults.
+const SaltSize = 32
+
+// Header is the start of the first line of every vault.
+const Header = "$ANSIBLE_VAULT"
+
+// Cipher is the only cipher supported, and the only one Ansible writes.
+const Cipher = "AES256"
+
+// lineWidth is the length of the lines of hexadecimal in a vault.
+const lineWidth = 80
+
+var (
+	// ErrNotVault is returned by Parse if the data does not start with a
+	// vault header.
+	ErrNotVault = errors.New("ansiblevault: not an Ansible Vault file")
+
+	// ErrUnsupported is returned by Parse for vault versions other than 1.1
+	// and 1.2, and for ciphers other than AES256.
+	ErrUnsupported = errors.New("ansiblevault: unsupported vault version or cipher")
+
+	// ErrMalformed is returned by Parse if the vault's body is corrupt.
+	ErrMalformed = errors.New("ansiblevault: malformed vault")
+
+	// ErrDecrypt is returned by Decrypt if the password is wrong or the
+	// vault has been modified: its HMAC does not match.
+	ErrDecrypt = errors.New("ansiblevault: wrong password or corrupted vault")
+
+	// ErrInvalidLabel is returned by Encrypt if the label contains a ";" or
+	// whitespace.
+	ErrInvalidLabel = errors.New("ansiblevault: invalid vault ID label")
+)
+
+// Vault is a parsed vault file.
+type Vault struct {
+	// Version is "1.1", or "1.2" for vaults with a Label.
+	Version string
+
+	// Label is the vault ID label, identifying the password that encrypts
+	// the vault, or "" for version 1.1.
+	Label string
+
+	Salt       []byte
+	HMAC       []byte
+	Ciphertext []byte
+}
+
+// Parse parses a vault file without decrypting it.
+func Parse(data []byte) (*Vault, error) {
+	header, body, _ := bytes.Cut(data, []byte("\n"))
+	fields := strings.Split(strings.TrimSpace(string(header)), ";")
+	if fields[0] != Header {
+		return nil, ErrNotVault
+	}
+	v := &Vault{}
+	switch {
+	case len(fields) == 3 && fields[1] == "1.1":
+	case len(fields) == 4 && fields[1] == "1.2" && fields[3] != "":
+		v.Label = fields[3]
+	default:
+		return nil, ErrUnsupported
+	}
+	v.Version = fields[1]
+	if strings.TrimSpace(fields[2]) != Cipher {
+		return nil, ErrUnsupported
+	}
+
+	inner, err := hex.DecodeString(strings.Join(strings.Fields(string(body)), ""))
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	parts := bytes.Split(inner, []byte("\n"))
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	decoded := make([][]byte, 3)
+	for i, p := range parts {
+		if decoded[i], err = hex.DecodeString(string(p)); err != nil || len(decoded[i]) == 0 {
+			return nil, ErrMalformed
+		}
+	}
+	v.Salt, v.HMAC, v.Ciphertext = decoded[0], decoded[1], decoded[2]
+	if len(v.HMAC) != sha256.Size || len(v.Ciphertext)%aes.BlockSize != 0 {
+		return nil, ErrMalformed
+	}
+	return v, nil
+}
+
+// Marshal encodes v as a vault file, as written by ansible-vault.
+func (v *Vault) Marshal() []byte {
+	inner := hex.EncodeToString(v.Salt) + "\n" + hex.EncodeToString(v.HMAC) + "\n" + hex.EncodeToString(v.Ciphertext)
+	body := hex.EncodeToString([]byte(inner))
+
+	var b strings.Builder
+	b.WriteString(Header + ";" + v.Version + ";" + Cipher)
+	if v.Label != "" {
+		b.WriteString(";" + v.Label)
+	}
+	b.WriteByte('\n')
+	for len(body) > lineWidth {
+		b.WriteString(body[:lineWidth] + "\n")
+		body = body[lineWidth:]
+	}
+	b.WriteString(body + "\n")
+	return []byte(b.String())
+}
+
+// Keys are the keys derived from a vault password and salt.
+type Keys struct {
+	CipherKey []byte
+	HMACKey   []byte
+	IV        []byte
+
+	derived *pbkdf2.SecureBytes
+}
+
+// Destroy wipes the keys.
+func (k *Keys) Destroy() {
+	k.derived.Destroy()
+}
+
+// DeriveKeys derives the AES key, HMAC key and IV for a vault from its
+// password and salt, with PBKDF2-HMAC-SHA256. The caller should destroy the
+// keys once they are no longer needed.
+func DeriveKeys(password, salt []byte) (*Keys, error) {
+	secret := pbkdf2.NewSecureBytes(append([]byte(nil), password...))
+	defer secret.Destroy()
+
+	derived, err := pbkdf2.DeriveKey(secret, salt, &pbkdf2.Params{
+		Iterations: Iterations,
+		SaltLength: uint32(len(salt)),
+		KeyLength:  2*32 + aes.BlockSize,
+		Variant:    pbkdf2.VariantSHA256,
+	})
+	if err != nil {
+		return nil, err
+	}
+	b := derived.Bytes()
+	return &Keys{CipherKey: b[:32], HMACKey: b[32:64], IV: b[64:], derived: derived}, nil
+}
+
+// Decrypt decrypts a vault file with password. It returns ErrDecrypt if the
+// password is wrong, and any error from Parse.
+func Decrypt(data, password []byte) ([]byte, error) {
+	v, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return v.Decrypt(password)
+}
+
+// Decrypt decrypts v with password, checking its HMAC first.
+func (v *Vault) Decrypt(password []byte) ([]byte, error) {
+	keys, err := DeriveKeys(password, v.Salt)
+	if err != nil {
+		return nil, err
+	}
+	defer keys.Destroy()
+
+	mac := hmac.New(sha256.New, keys.HMACKey)
+	mac.Write(v.Ciphertext)
+	if !hmac.Equal(mac.Sum(nil), v.HMAC) {
+		return nil, ErrDecrypt
+	}
+
+	if len(v.Ciphertext) == 0 {
+		return nil, ErrMalformed
+	}
+	plaintext := make([]byte, len(v.Ciphertext))
+	ctr(keys).XORKeyStream(plaintext, v.Ciphertext)
+	n := len(plaintext)
+	pad := int(plaintext[n-1])
+	if pad == 0 || pad > aes.BlockSize || !bytes.Equal(plaintext[n-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, ErrMalformed
+	}
+	return plaintext[:n-pad], nil
+}
+
+// Encrypt encrypts plaintext with password as a vault file, with a random
+// salt. If label is not empty, the vault is version 1.2, recording label as
+// its vault ID.
+func Encrypt(plaintext, password []byte, label string) ([]byte, error) {
+	if strings.ContainsAny(label, "; \t\r\n") {
+		return nil, ErrInvalidLabel
+	}
+	v := &Vault{Version: "1.1", Label: label, Salt: make([]byte, SaltSize)}
+	if label != "" {
+		v.Version = "1.2"
+	}
+	if _, err := io.ReadFull(pbkdf2.Rand, v.Salt); err != nil {
+		return nil, err
+	}
+	if err := v.encrypt(plaintext, password); err != nil {
+		return nil, err
+	}
+	return v.Marshal(), nil
+}
+
+// encrypt sets v's ciphertext and HMAC from plaintext, with v's salt.
+func (v *Vault) encrypt(plaintext, password []byte) error {
+	keys, err := DeriveKeys(password, v.Salt)
+	if err != nil {
+		return err
+	}
+	defer keys.Destroy()
+
+	pad := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padded := append(append([]byte(nil), plaintext...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	v.Ciphertext = make([]byte, len(padded))
+	ctr(keys).XORKeyStream(v.Ciphertext, padded)
+
+	mac := hmac.New(sha256.New, keys.HMACKey)
+	mac.Write(v.Ciphertext)
+	v.HMAC = mac.Sum(nil)
+	return nil
+}
+
+func ctr(keys *Keys) cipher.Stream {
+	block, _ := aes.NewCipher(keys.CipherKey)
+	return cipher.NewCTR(block, keys.IV)
+}
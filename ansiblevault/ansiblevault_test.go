@@ -0,0 +1,115 @@
+package ansiblevault
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// testVault was generated in the same way as ansible-vault, with Python's
+// hashlib and hmac and OpenSSL for AES-256-CTR:
+//
+//	dk = hashlib.pbkdf2_hmac("sha256", b"vault password", bytes(range(32)), 10000, 80)
+//	ct = AES-256-CTR(key=dk[:32], iv=dk[64:], PKCS7(b"db_password: hunter2\n"))
+//	mac = hmac.new(dk[32:64], ct, hashlib.sha256).digest()
+//	body = hexlify(b"\n".join([hexlify(salt), hexlify(mac), hexlify(ct)]))
+const testVault = `$ANSIBLE_VAULT;1.1;AES256
+30303031303230333034303530363037303830393061306230633064306530663130313131323133
+3134313531363137313831393161316231633164316531660a636161623635616439623537613530
+35333165323336666333373039643463363631656465353633363134623632616239336335316138
+6266613266396132300a626435633666656263616262353265656539316333613233386439323638
+34326532646331396333396334613065656261383539643464636461373932333962
+`
+
+const testPlaintext = "db_password: hunter2\n"
+
+var testPassword = []byte("vault password")
+
+func TestDecryptVector(t *testing.T) {
+	plaintext, err := Decrypt([]byte(testVault), testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != testPlaintext {
+		t.Fatalf("got %q, want %q", plaintext, testPlaintext)
+	}
+	if _, err := Decrypt([]byte(testVault), []byte("wrong")); err != ErrDecrypt {
+		t.Fatalf("expected ErrDecrypt, got %v", err)
+	}
+}
+
+func TestMarshalVector(t *testing.T) {
+	v, err := Parse([]byte(testVault))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != "1.1" || v.Label != "" || len(v.Salt) != 32 {
+		t.Fatalf("unexpected vault %+v", v)
+	}
+	if got := string(v.Marshal()); got != testVault {
+		t.Fatalf("got\n%s\nwant\n%s", got, testVault)
+	}
+
+	// Re-encrypting with the same salt reproduces the vault exactly.
+	again := &Vault{Version: "1.1", Salt: v.Salt}
+	if err := again.encrypt([]byte(testPlaintext), testPassword); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again.Marshal(), []byte(testVault)) {
+		t.Fatal("expected encryption to reproduce the vector")
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	for _, label := range []string{"", "prod"} {
+		for _, plaintext := range []string{"", "x", strings.Repeat("0123456789abcdef", 4)} {
+			data, err := Encrypt([]byte(plaintext), testPassword, label)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := Parse(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := map[string]string{"": "1.1", "prod": "1.2"}[label]; v.Version != want || v.Label != label {
+				t.Fatalf("label %q: got version %s, label %q", label, v.Version, v.Label)
+			}
+			got, err := v.Decrypt(testPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != plaintext {
+				t.Fatalf("got %q, want %q", got, plaintext)
+			}
+		}
+	}
+	if _, err := Encrypt(nil, testPassword, "a;b"); err != ErrInvalidLabel {
+		t.Fatalf("expected ErrInvalidLabel, got %v", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	lines := strings.SplitN(testVault, "\n", 2)
+	tests := map[string]struct {
+		data string
+		err  error
+	}{
+		"not a vault": {"hello", ErrNotVault},
+		"version":     {"$ANSIBLE_VAULT;1.0;AES256\n" + lines[1], ErrUnsupported},
+		"cipher":      {"$ANSIBLE_VAULT;1.1;AES128\n" + lines[1], ErrUnsupported},
+		"no label":    {"$ANSIBLE_VAULT;1.2;AES256\n" + lines[1], ErrUnsupported},
+		"hex":         {lines[0] + "\nzz" + lines[1], ErrMalformed},
+		"truncated":   {lines[0] + "\n" + lines[1][:160], ErrMalformed},
+	}
+	for name, tt := range tests {
+		if _, err := Parse([]byte(tt.data)); err != tt.err {
+			t.Errorf("%s: expected %v, got %v", name, tt.err, err)
+		}
+	}
+
+	labelled := "$ANSIBLE_VAULT;1.2;AES256;dev\n" + lines[1]
+	v, err := Parse([]byte(labelled))
+	if err != nil || v.Label != "dev" {
+		t.Fatalf("got %+v, %v", v, err)
+	}
+}
@@ -0,0 +1,239 @@
+// Package argon2id provides the same API as github.com/alexedwards/argon2id,
+// backed by the pbkdf2 package, so that projects using that package can switch
+// by changing the import path and migrate their stored hashes over time.
+//
+// CreateHash produces hashes in the pbkdf2 package's format. ComparePasswordAndHash,
+// CheckHash and DecodeHash accept both those hashes and existing $argon2id$
+// hashes, so a single verifier handles every stored hash during the migration.
+// Use NeedsMigration after a successful login to decide whether to replace an
+// Argon2id hash with a new one:
+//
+//	match, err := argon2id.ComparePasswordAndHash(password, stored)
+//	if err == nil && match && argon2id.NeedsMigration(stored) {
+//		newHash, err := argon2id.CreateHash(password, argon2id.DefaultParams)
+//		// ... store newHash
+//	}
+//
+// When creating hashes, Params.Iterations is the PBKDF2 iteration count, which
+// is several orders of magnitude larger than an Argon2 time cost. To avoid
+// silently producing weak hashes from Params copied from Argon2 code,
+// CreateHash rejects iteration counts below MinIterations. Memory and
+// Parallelism only apply to Argon2id and are ignored when creating hashes.
+package argon2id
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+var (
+	// ErrInvalidHash in returned by ComparePasswordAndHash if the provided
+	// hash isn't in the expected format. It is the same value as
+	// pbkdf2.ErrInvalidHash.
+	ErrInvalidHash = pbkdf2.ErrInvalidHash
+
+	// ErrIncompatibleVariant is returned by ComparePasswordAndHash if the
+	// provided hash was created using an unsupported variant of Argon2 or
+	// PBKDF2. It is the same value as pbkdf2.ErrIncompatibleVariant.
+	ErrIncompatibleVariant = pbkdf2.ErrIncompatibleVariant
+
+	// ErrIncompatibleVersion is returned by ComparePasswordAndHash if the
+	// provided Argon2id hash was created using a different version of Argon2.
+	ErrIncompatibleVersion = errors.New("argon2id: incompatible version of argon2")
+
+	// ErrIterationsTooLow is returned by CreateHash if Params.Iterations is
+	// below MinIterations.
+	ErrIterationsTooLow = errors.New("argon2id: iterations is below the PBKDF2 minimum; Argon2 time costs do not carry over")
+)
+
+// MinIterations is the lowest PBKDF2 iteration count CreateHash accepts.
+const MinIterations = 10000
+
+// maxMemory is the largest Argon2id memory cost, in kibibytes, that hashes
+// are verified with: 2 GiB, the first recommendation of RFC 9106. It keeps
+// a hostile hash from exhausting memory.
+const maxMemory = 2 * 1024 * 1024
+
+// DefaultParams provides some sane default parameters for hashing passwords,
+// using the same PBKDF2 cost as pbkdf2.DefaultParams.
+var DefaultParams = &Params{
+	Memory:      64 * 1024,
+	Iterations:  210000,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   64,
+}
+
+// Params describes the input parameters of a hash. For hashes created by this
+// package, only Iterations, SaltLength and KeyLength are used. Memory and
+// Parallelism are only set for, and used by, Argon2id hashes.
+type Params struct {
+	// The amount of memory used by Argon2id, in kibibytes.
+	Memory uint32
+
+	// The number of PBKDF2 iterations, or the Argon2id time cost.
+	Iterations uint32
+
+	// The number of threads used by Argon2id.
+	Parallelism uint8
+
+	// Length of the random salt. 16 bytes is recommended for password hashing.
+	SaltLength uint32
+
+	// Length of the generated key. 16 bytes or more is recommended.
+	KeyLength uint32
+}
+
+// CreateHash returns a PBKDF2-HMAC-SHA512 hash of a plain-text password using
+// the provided parameters, in the format documented by pbkdf2.CreateHash.
+func CreateHash(password string, params *Params) (hash string, err error) {
+	if params.Iterations < MinIterations {
+		return "", ErrIterationsTooLow
+	}
+	return pbkdf2.CreateHash(password, &pbkdf2.Params{
+		Iterations: params.Iterations,
+		SaltLength: params.SaltLength,
+		KeyLength:  params.KeyLength,
+	})
+}
+
+// ComparePasswordAndHash performs a constant-time comparison between a
+// plain-text password and a PBKDF2 or Argon2id hash, using the parameters and
+// salt contained in the hash. It returns true if they match, otherwise it
+// returns false.
+func ComparePasswordAndHash(password, hash string) (match bool, err error) {
+	match, _, err = CheckHash(password, hash)
+	return match, err
+}
+
+// CheckHash is like ComparePasswordAndHash, except it also returns the params
+// that the hash was created with.
+func CheckHash(password, hash string) (match bool, params *Params, err error) {
+	if !isArgon2id(hash) {
+		match, p, err := pbkdf2.CheckHash(password, hash)
+		if err != nil {
+			return false, nil, err
+		}
+		return match, fromPBKDF2(p), nil
+	}
+
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false, nil, err
+	}
+	otherKey := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	if subtle.ConstantTimeCompare(key, otherKey) == 1 {
+		return true, params, nil
+	}
+	return false, params, nil
+}
+
+// DecodeHash parses a PBKDF2 or Argon2id hash, returning the params used to
+// create it, as well as the salt and key.
+func DecodeHash(hash string) (params *Params, salt, key []byte, err error) {
+	if isArgon2id(hash) {
+		return decodeArgon2id(hash)
+	}
+
+	p, salt, key, err := pbkdf2.DecodeHash(hash)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return fromPBKDF2(p), salt, key, nil
+}
+
+// NeedsMigration reports whether hash is an Argon2id hash, which should be
+// replaced with one from CreateHash the next time the password is available.
+func NeedsMigration(hash string) bool {
+	return isArgon2id(hash)
+}
+
+func isArgon2id(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func fromPBKDF2(p *pbkdf2.Params) *Params {
+	return &Params{
+		Iterations: p.Iterations,
+		SaltLength: p.SaltLength,
+		KeyLength:  p.KeyLength,
+	}
+}
+
+// decodeArgon2id parses hashes in the format produced by
+// github.com/alexedwards/argon2id:
+//
+//	$argon2id$v=19$m=65536,t=1,p=2$<b64Salt>$<b64Key>
+func decodeArgon2id(hash string) (params *Params, salt, key []byte, err error) {
+	vals := strings.Split(hash, "$")
+	if len(vals) != 6 || vals[0] != "" {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	if vals[1] != "argon2id" {
+		return nil, nil, nil, ErrIncompatibleVariant
+	}
+
+	version, ok := parseArgon2Params(vals[2], "v")
+	if !ok || len(version) != 1 {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	if version[0] != argon2.Version {
+		return nil, nil, nil, ErrIncompatibleVersion
+	}
+
+	costs, ok := parseArgon2Params(vals[3], "m", "t", "p")
+	if !ok || costs[0] > maxMemory || costs[1] == 0 || costs[2] == 0 || costs[2] > 255 {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	params = &Params{Memory: costs[0], Iterations: costs[1], Parallelism: uint8(costs[2])}
+
+	salt, err = base64.RawStdEncoding.Strict().DecodeString(vals[4])
+	if err != nil || len(salt) == 0 {
+		return nil, nil, nil, &pbkdf2.DecodeError{Field: "salt", Err: err}
+	}
+	params.SaltLength = uint32(len(salt))
+
+	key, err = base64.RawStdEncoding.Strict().DecodeString(vals[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, &pbkdf2.DecodeError{Field: "key", Err: err}
+	}
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
+
+// parseArgon2Params parses a comma-separated list of name=value pairs, which
+// must use exactly the given names in order.
+func parseArgon2Params(s string, names ...string) ([]uint32, bool) {
+	pairs := strings.Split(s, ",")
+	if len(pairs) != len(names) {
+		return nil, false
+	}
+
+	values := make([]uint32, len(names))
+	for i, pair := range pairs {
+		digits := strings.TrimPrefix(pair, names[i]+"=")
+		if digits == pair || digits == "" || len(digits) > 10 {
+			return nil, false
+		}
+		var n uint64
+		for j := 0; j < len(digits); j++ {
+			if digits[j] < '0' || digits[j] > '9' {
+				return nil, false
+			}
+			n = n*10 + uint64(digits[j]-'0')
+		}
+		if n > 1<<32-1 {
+			return nil, false
+		}
+		values[i] = uint32(n)
+	}
+	return values, true
+}
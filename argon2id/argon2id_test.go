@@ -0,0 +1,95 @@
+package argon2id
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+func TestCreateHash(t *testing.T) {
+	params := &Params{Iterations: MinIterations, SaltLength: 16, KeyLength: 32}
+	hash, err := CreateHash("pa$$word", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	match, checkParams, err := CheckHash("pa$$word", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !match {
+		t.Error("expected password and hash to match")
+	}
+	if *checkParams != *params {
+		t.Fatalf("expected %#v got %#v", *params, *checkParams)
+	}
+	if NeedsMigration(hash) {
+		t.Error("PBKDF2 hashes do not need migrating")
+	}
+
+	// Argon2 time costs are rejected.
+	if _, err := CreateHash("pa$$word", &Params{Memory: 64 * 1024, Iterations: 3, Parallelism: 2, SaltLength: 16, KeyLength: 32}); err != ErrIterationsTooLow {
+		t.Fatalf("expected ErrIterationsTooLow, got %v", err)
+	}
+}
+
+func TestArgon2idHash(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte("pa$$word"), salt, 1, 64, 2, 32)
+	hash := "$argon2id$v=19$m=64,t=1,p=2$" + base64.RawStdEncoding.EncodeToString(salt) + "$" + base64.RawStdEncoding.EncodeToString(key)
+
+	match, params, err := CheckHash("pa$$word", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !match {
+		t.Error("expected password and hash to match")
+	}
+	want := Params{Memory: 64, Iterations: 1, Parallelism: 2, SaltLength: 16, KeyLength: 32}
+	if *params != want {
+		t.Fatalf("expected %#v got %#v", want, *params)
+	}
+	if !NeedsMigration(hash) {
+		t.Error("expected Argon2id hash to need migrating")
+	}
+
+	match, err = ComparePasswordAndHash("otherPa$$word", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match {
+		t.Error("expected password and hash to not match")
+	}
+
+	if _, _, _, err := DecodeHash("$argon2id$v=16$m=64,t=1,p=2$AA$AA"); err != ErrIncompatibleVersion {
+		t.Fatalf("expected ErrIncompatibleVersion, got %v", err)
+	}
+	if _, _, _, err := DecodeHash("$argon2id$v=19$t=1,m=64,p=2$AA$AA"); err != ErrInvalidHash {
+		t.Fatalf("expected ErrInvalidHash, got %v", err)
+	}
+}
+
+func TestArgon2idHashInvalid(t *testing.T) {
+	hashes := []string{
+		"$argon2id$v=19$m=16,t=1,p=1$c2FsdHNhbHQ$",
+		"$argon2id$v=19$m=16,t=1,p=1$$a2V5a2V5",
+		"$argon2id$v=19$m=16,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5=",
+		"$argon2id$v=19$m=16,t=1,p=1$c2Fs!HNhbHQ$a2V5a2V5",
+		"$argon2id$v=19$m=4294967295,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5",
+	}
+	for _, hash := range hashes {
+		if _, _, err := CheckHash("pa$$word", hash); !errors.Is(err, ErrInvalidHash) {
+			t.Errorf("%s: expected ErrInvalidHash, got %v", hash, err)
+		}
+	}
+}
+
+func TestSharedErrors(t *testing.T) {
+	if ErrInvalidHash != pbkdf2.ErrInvalidHash || ErrIncompatibleVariant != pbkdf2.ErrIncompatibleVariant {
+		t.Fatal("errors must be shared with the pbkdf2 package")
+	}
+}
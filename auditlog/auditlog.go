@@ -0,0 +1,286 @@
+// Package auditlog writes a tamper-evident, append-only log of password
+// verifications, so that after an incident the history of logins can be shown
+// not to have been edited.
+//
+// The log is a file of JSON lines, one Entry each. Every entry carries an
+// HMAC-SHA256 over its own fields and the HMAC of the entry before it, so that
+// editing, reordering or deleting any entry breaks the chain from that point
+// on, which Verify reports:
+//
+//	w, err := auditlog.NewWriter(file, key)
+//	hasher := &pbkdf2.Hasher{OnEvent: w.Event}
+//	...
+//	head, err := auditlog.Verify(file, key)
+//
+// The chain cannot reveal entries removed from the end of the log. To detect
+// truncation, record Writer.Head periodically somewhere the log's writer
+// cannot alter, and compare it with the Head returned by Verify.
+//
+// The key must be kept from anyone able to edit the log, or they can rebuild
+// the chain; ideally it is held by a separate signing service.
+package auditlog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// MinKeyLength is the shortest key accepted, in bytes.
+const MinKeyLength = 16
+
+// UserLabel is the label, set with pbkdf2.WithLabels, whose value Writer.Event
+// records as the user of an event.
+const UserLabel = "user"
+
+// Outcomes of a verification, as recorded in Entry.Outcome.
+const (
+	OutcomeMatch    = "match"
+	OutcomeMismatch = "mismatch"
+	OutcomeError    = "error"
+)
+
+var (
+	// ErrInvalidKey is returned if the key is shorter than MinKeyLength.
+	ErrInvalidKey = errors.New("auditlog: key too short")
+
+	// ErrTampered is wrapped by the *ChainError returned when a log does not
+	// verify.
+	ErrTampered = errors.New("auditlog: log has been tampered with")
+)
+
+// ChainError reports where a log stops verifying.
+type ChainError struct {
+	// Line is the line of the first entry that does not verify.
+	Line int
+
+	// Reason describes what is wrong with the entry.
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return ErrTampered.Error() + ": line " + strconv.Itoa(e.Line) + ": " + e.Reason
+}
+
+func (e *ChainError) Unwrap() error {
+	return ErrTampered
+}
+
+// An Entry records one verification, or creation, of a hash.
+type Entry struct {
+	// Seq is the position of the entry in the log, starting at 1. It is set
+	// by Writer.Append.
+	Seq uint64 `json:"seq"`
+
+	// Time is when the entry was appended, set by Writer.Append if zero.
+	Time time.Time `json:"time"`
+
+	// User identifies the user, such as a username or an opaque user ID.
+	User string `json:"user,omitempty"`
+
+	// Op is pbkdf2.UsageVerify or pbkdf2.UsageHash.
+	Op string `json:"op"`
+
+	// Outcome is OutcomeMatch, OutcomeMismatch or OutcomeError for
+	// verifications, and OutcomeMatch or OutcomeError for hashes created.
+	Outcome string `json:"outcome"`
+
+	// Error is the error returned, for OutcomeError.
+	Error string `json:"error,omitempty"`
+
+	// Variant and Iterations are those of the hash, if it could be decoded.
+	Variant    string `json:"variant,omitempty"`
+	Iterations uint32 `json:"iterations,omitempty"`
+}
+
+// macField introduces the MAC at the end of each line.
+const macField = `,"mac":"`
+
+// Head identifies the last entry of a log.
+type Head struct {
+	// Entries is the number of entries, and the Seq of the last one.
+	Entries uint64
+
+	// MAC is the hex-encoded MAC of the last entry, or "" for an empty log.
+	MAC string
+}
+
+// A Writer appends entries to a log. It is safe for concurrent use.
+type Writer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	mac  hash.Hash
+	prev []byte
+	seq  uint64
+	err  error
+
+	// Clock, if set, replaces pbkdf2.SystemClock for the times of entries
+	// appended without one.
+	Clock pbkdf2.Clock
+}
+
+// NewWriter returns a Writer starting a new log on w, which should be opened
+// for appending. Each entry is written with a single call to w.Write.
+func NewWriter(w io.Writer, key []byte) (*Writer, error) {
+	if len(key) < MinKeyLength {
+		return nil, ErrInvalidKey
+	}
+	return &Writer{w: w, mac: hmac.New(sha256.New, key)}, nil
+}
+
+// Resume verifies the existing log read from r and returns a Writer that
+// continues it on w, typically the same file opened for appending. It returns
+// the error from Verify if the log does not verify.
+func Resume(r io.Reader, w io.Writer, key []byte) (*Writer, error) {
+	aw, err := NewWriter(w, key)
+	if err != nil {
+		return nil, err
+	}
+	head, err := Verify(r, key)
+	if err != nil {
+		return nil, err
+	}
+	aw.seq = head.Entries
+	aw.prev, _ = hex.DecodeString(head.MAC)
+	return aw, nil
+}
+
+// Append writes e to the log, setting its Seq, and its Time if zero. Once a
+// write fails, Append returns the same error on every call, as later entries
+// could not be chained to the lost one.
+func (w *Writer) Append(e Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+
+	e.Seq = w.seq + 1
+	if e.Time.IsZero() {
+		clock := w.Clock
+		if clock == nil {
+			clock = pbkdf2.SystemClock
+		}
+		e.Time = clock.Now()
+	}
+	e.Time = e.Time.UTC()
+	body, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+	sum := chainMAC(w.mac, w.prev, body)
+
+	line := make([]byte, 0, len(body)+len(macField)+2*len(sum)+3)
+	line = append(line, body[:len(body)-1]...)
+	line = append(line, macField...)
+	line = append(line, hex.EncodeToString(sum)...)
+	line = append(line, "\"}\n"...)
+	if _, err := w.w.Write(line); err != nil {
+		w.err = err
+		return err
+	}
+	w.seq, w.prev = e.Seq, sum
+	return nil
+}
+
+// Event appends an entry for a pbkdf2.Event, taking the user from the event's
+// UserLabel label. Its signature matches pbkdf2.Hasher.OnEvent. As OnEvent
+// cannot return an error, check Err for failures to write.
+func (w *Writer) Event(e pbkdf2.Event) {
+	entry := Entry{User: e.Labels[UserLabel], Op: e.Op}
+	switch {
+	case e.Err != nil:
+		entry.Outcome, entry.Error = OutcomeError, e.Err.Error()
+	case e.Op == pbkdf2.UsageVerify && !e.Match:
+		entry.Outcome = OutcomeMismatch
+	default:
+		entry.Outcome = OutcomeMatch
+	}
+	if e.Params != nil {
+		entry.Variant, entry.Iterations = e.Params.Variant, e.Params.Iterations
+		if entry.Variant == "" {
+			entry.Variant = pbkdf2.VariantSHA512
+		}
+	}
+	_ = w.Append(entry)
+}
+
+// Err returns the error that made the Writer fail, if any.
+func (w *Writer) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Head returns the head of the log written so far.
+func (w *Writer) Head() Head {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return Head{Entries: w.seq, MAC: hex.EncodeToString(w.prev)}
+}
+
+// Verify reads a log from r and checks its chain of MACs, returning the head
+// of the log if it verifies, or a *ChainError for the first entry that does
+// not, along with the head of the entries before it.
+func Verify(r io.Reader, key []byte) (Head, error) {
+	var head Head
+	if len(key) < MinKeyLength {
+		return head, ErrInvalidKey
+	}
+	mac := hmac.New(sha256.New, key)
+	var prev []byte
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Bytes()
+		fail := func(reason string) (Head, error) {
+			return head, &ChainError{Line: line, Reason: reason}
+		}
+
+		i := bytes.LastIndex(text, []byte(macField))
+		if i < 0 || !bytes.HasSuffix(text, []byte("\"}")) {
+			return fail("entry has no MAC")
+		}
+		sum, err := hex.DecodeString(string(text[i+len(macField) : len(text)-2]))
+		if err != nil {
+			return fail("entry has a malformed MAC")
+		}
+		body := append(text[:i:i], '}')
+		if !hmac.Equal(sum, chainMAC(mac, prev, body)) {
+			return fail("MAC does not match; the entry or one before it was edited, removed or reordered")
+		}
+
+		var e Entry
+		if err := json.Unmarshal(body, &e); err != nil {
+			return fail("entry is not valid JSON")
+		}
+		if e.Seq != head.Entries+1 {
+			return fail("sequence number " + strconv.FormatUint(e.Seq, 10) + " is out of order")
+		}
+		prev = sum
+		head = Head{Entries: e.Seq, MAC: hex.EncodeToString(sum)}
+	}
+	return head, scanner.Err()
+}
+
+// chainMAC returns the MAC of an entry's body chained to the previous MAC.
+func chainMAC(mac hash.Hash, prev, body []byte) []byte {
+	mac.Reset()
+	mac.Write(prev)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
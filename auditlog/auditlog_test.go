@@ -0,0 +1,167 @@
+package auditlog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func writeLog(t *testing.T, n int) (*bytes.Buffer, *Writer) {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		err := w.Append(Entry{Time: time.Unix(int64(i), 0), User: "alice", Op: pbkdf2.UsageVerify, Outcome: OutcomeMatch})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &buf, w
+}
+
+func TestVerify(t *testing.T) {
+	buf, w := writeLog(t, 5)
+	head, err := Verify(bytes.NewReader(buf.Bytes()), testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head != w.Head() || head.Entries != 5 || len(head.MAC) != 64 {
+		t.Fatalf("unexpected head %+v, writer has %+v", head, w.Head())
+	}
+
+	if _, err := Verify(bytes.NewReader(buf.Bytes()), []byte("another key of 32 bytes length!!")); !errors.Is(err, ErrTampered) {
+		t.Fatalf("expected ErrTampered with the wrong key, got %v", err)
+	}
+	if head, err := Verify(strings.NewReader(""), testKey); err != nil || head != (Head{}) {
+		t.Fatalf("empty log: got %+v, %v", head, err)
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	buf, _ := writeLog(t, 5)
+	lines := strings.SplitAfter(buf.String(), "\n")
+	lines = lines[:len(lines)-1]
+
+	tests := map[string]struct {
+		edit func([]string) []string
+		line int
+	}{
+		"edited": {func(ls []string) []string {
+			ls[2] = strings.Replace(ls[2], `"match"`, `"mismatch"`, 1)
+			return ls
+		}, 3},
+		"deleted": {func(ls []string) []string {
+			return append(ls[:1], ls[2:]...)
+		}, 2},
+		"reordered": {func(ls []string) []string {
+			ls[1], ls[2] = ls[2], ls[1]
+			return ls
+		}, 2},
+		"no MAC": {func(ls []string) []string {
+			ls[4] = `{"seq":5}` + "\n"
+			return ls
+		}, 5},
+	}
+	for name, test := range tests {
+		edited := test.edit(append([]string(nil), lines...))
+		head, err := Verify(strings.NewReader(strings.Join(edited, "")), testKey)
+		var ce *ChainError
+		if !errors.As(err, &ce) || ce.Line != test.line {
+			t.Errorf("%s: expected a ChainError at line %d, got %v", name, test.line, err)
+			continue
+		}
+		if head.Entries != uint64(test.line-1) {
+			t.Errorf("%s: expected head at %d entries, got %d", name, test.line-1, head.Entries)
+		}
+	}
+
+	// Truncation only shows against a recorded head.
+	head, err := Verify(strings.NewReader(strings.Join(lines[:3], "")), testKey)
+	if err != nil || head.Entries != 3 {
+		t.Fatalf("truncated log: got %+v, %v", head, err)
+	}
+}
+
+func TestResume(t *testing.T) {
+	buf, _ := writeLog(t, 3)
+	w, err := Resume(bytes.NewReader(buf.Bytes()), buf, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Append(Entry{Op: pbkdf2.UsageHash, Outcome: OutcomeMatch}); err != nil {
+		t.Fatal(err)
+	}
+	head, err := Verify(bytes.NewReader(buf.Bytes()), testKey)
+	if err != nil || head.Entries != 4 || head != w.Head() {
+		t.Fatalf("resumed log: got %+v, %v", head, err)
+	}
+
+	tampered := bytes.Replace(buf.Bytes(), []byte("alice"), []byte("mallory"), 1)
+	if _, err := Resume(bytes.NewReader(tampered), &bytes.Buffer{}, testKey); !errors.Is(err, ErrTampered) {
+		t.Fatalf("expected ErrTampered, got %v", err)
+	}
+}
+
+func TestEvent(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := &pbkdf2.Params{Iterations: 1000, SaltLength: 16, KeyLength: 32}
+	hasher := &pbkdf2.Hasher{Params: params, OnEvent: w.Event}
+	ctx := pbkdf2.WithLabels(context.Background(), map[string]string{UserLabel: "alice"})
+
+	hash, err := hasher.CreateHashContext(ctx, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasher.VerifyContext(ctx, "password", hash)
+	hasher.VerifyContext(ctx, "wrong", hash)
+	hasher.VerifyContext(ctx, "password", "garbage")
+	if err := w.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		`"seq":1,`, `"user":"alice"`, `"op":"hash","outcome":"match"`,
+		`"op":"verify","outcome":"match"`, `"outcome":"mismatch"`, `"outcome":"error"`,
+		`"variant":"pbkdf2-sha512","iterations":1000`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log does not contain %s:\n%s", want, out)
+		}
+	}
+	if head, err := Verify(strings.NewReader(out), testKey); err != nil || head.Entries != 4 {
+		t.Fatalf("got %+v, %v", head, err)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestWriterSticksOnError(t *testing.T) {
+	w, err := NewWriter(failingWriter{}, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Event(pbkdf2.Event{Op: pbkdf2.UsageVerify})
+	if w.Err() == nil || w.Append(Entry{}) != w.Err() {
+		t.Fatalf("expected sticky error, got %v", w.Err())
+	}
+	if _, err := NewWriter(&bytes.Buffer{}, testKey[:8]); err != ErrInvalidKey {
+		t.Fatalf("expected ErrInvalidKey, got %v", err)
+	}
+}
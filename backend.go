@@ -0,0 +1,92 @@
+package pbkdf2
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/cpu"
+)
+
+// Names of the key derivation backends reported by Backend.
+const (
+	// BackendGo is the Go implementation from golang.org/x/crypto/pbkdf2,
+	// using the standard library hash functions, which have assembly
+	// implementations on common architectures.
+	BackendGo = "go"
+
+	// BackendPureGo is BackendGo built with the purego build tag, which
+	// disables the assembly implementations.
+	BackendPureGo = "purego"
+
+	// BackendOpenSSL is OpenSSL's PKCS5_PBKDF2_HMAC, selected with the
+	// openssl build tag. Variants OpenSSL does not provide, such as those
+	// added with RegisterVariant, use the Go implementation.
+	BackendOpenSSL = "openssl"
+
+	// BackendWebCrypto is the browser's SubtleCrypto.deriveBits, used for
+	// the SHA-2 variants on js/wasm when it is available.
+	BackendWebCrypto = "webcrypto"
+)
+
+// BackendInfo describes the implementation used to derive keys.
+type BackendInfo struct {
+	// Name is the backend used for the default variant, such as BackendGo.
+	Name string
+
+	// GOOS and GOARCH are the platform the program was built for.
+	GOOS   string
+	GOARCH string
+
+	// CPUFeatures lists the detected CPU features that the hash functions
+	// can use for acceleration, such as "avx2" or "sha512", in a fixed
+	// order. It is empty if none are available, in which case the portable
+	// implementations are used.
+	CPUFeatures []string
+
+	// CPUTime reports whether the CPU time of key derivations is measured,
+	// for CheckResult.CPUTime and Usage.CPU. It is measured per thread, which
+	// is supported on Linux, macOS, FreeBSD and OpenBSD.
+	CPUTime bool
+}
+
+// Backend reports the backend and CPU features used to derive keys, so that
+// operators can confirm that the fast path is active in production. The
+// backend can be pinned at build time with build tags; see the package
+// documentation.
+func Backend() BackendInfo {
+	return BackendInfo{
+		Name:        backendName(),
+		GOOS:        runtime.GOOS,
+		GOARCH:      runtime.GOARCH,
+		CPUFeatures: cpuFeatures(),
+		CPUTime:     threadCPUTimeSupported,
+	}
+}
+
+// cpuFeatures returns the CPU features used by the standard library's SHA-1,
+// SHA-256 and SHA-512 implementations on the current architecture.
+func cpuFeatures() []string {
+	var features []string
+	if purego {
+		return features
+	}
+	add := func(name string, ok bool) {
+		if ok {
+			features = append(features, name)
+		}
+	}
+
+	switch runtime.GOARCH {
+	case "amd64":
+		add("avx2", cpu.X86.HasAVX2)
+		add("bmi2", cpu.X86.HasBMI2)
+	case "arm64":
+		add("sha1", cpu.ARM64.HasSHA1)
+		add("sha2", cpu.ARM64.HasSHA2)
+		add("sha512", cpu.ARM64.HasSHA512)
+	case "s390x":
+		add("sha1", cpu.S390X.HasSHA1)
+		add("sha256", cpu.S390X.HasSHA256)
+		add("sha512", cpu.S390X.HasSHA512)
+	}
+	return features
+}
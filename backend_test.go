@@ -0,0 +1,33 @@
+package pbkdf2
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestBackend(t *testing.T) {
+	b := Backend()
+	switch b.Name {
+	case BackendGo, BackendWebCrypto, BackendOpenSSL:
+		if purego {
+			t.Errorf("expected the pure Go backend with the purego tag, got %q", b.Name)
+		}
+	case BackendPureGo:
+		if len(b.CPUFeatures) != 0 {
+			t.Errorf("unexpected CPU features %q with the purego tag", b.CPUFeatures)
+		}
+	default:
+		t.Errorf("unexpected backend %q", b.Name)
+	}
+	if runtime.GOOS != "js" && b.Name == BackendWebCrypto {
+		t.Errorf("unexpected WebCrypto backend outside js/wasm")
+	}
+	if b.GOOS != runtime.GOOS || b.GOARCH != runtime.GOARCH {
+		t.Errorf("unexpected platform %s/%s", b.GOOS, b.GOARCH)
+	}
+	for _, f := range b.CPUFeatures {
+		if f == "" {
+			t.Error("unexpected empty CPU feature")
+		}
+	}
+}
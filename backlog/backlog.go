@@ -0,0 +1,155 @@
+// Package backlog tracks which users still have hashes that need upgrading,
+// so that a migration to stronger params can be followed to completion.
+//
+// Hashes can only be upgraded when the password is known, that is, at login.
+// A Tracker is consulted at verify time: it reports whether the user's hash
+// needs rehashing and records the answer in a Sink, and is told when the
+// upgraded hash has been stored:
+//
+//	match, _, err := hasher.CheckHash(password, stored)
+//	if err == nil && match {
+//		needsRehash, _ := tracker.Observe(userID, stored)
+//		if needsRehash {
+//			newHash, err := hasher.ConvertHash(password, stored)
+//			// ... store newHash
+//			tracker.Upgraded(userID)
+//		}
+//	}
+//
+// Progress can be exported as a metric, for example with expvar:
+//
+//	expvar.Publish("rehash_backlog", expvar.Func(func() any {
+//		p, _ := tracker.Progress()
+//		return p
+//	}))
+package backlog
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// A Sink persists the set of users whose hashes need rehashing. It must be
+// safe for concurrent use.
+type Sink interface {
+	// Record marks userID as pending if pending is true, and as up to date
+	// otherwise.
+	Record(userID string, pending bool) error
+
+	// Pending returns the number of users currently marked as pending.
+	Pending() (int, error)
+}
+
+// Progress describes the state of a migration.
+type Progress struct {
+	// Observed is the number of hashes observed since the Tracker was
+	// created, and Stale the number of those that needed rehashing.
+	Observed uint64
+	Stale    uint64
+
+	// Upgraded is the number of hashes upgraded since the Tracker was
+	// created.
+	Upgraded uint64
+
+	// Pending is the number of users recorded in the Sink as still needing
+	// rehashing. Users who have not logged in since the migration started
+	// are not counted, so it only reaches zero once every user has been
+	// observed.
+	Pending int
+}
+
+// A Tracker records users with hashes that need rehashing. It is safe for
+// concurrent use.
+type Tracker struct {
+	hasher *pbkdf2.Hasher
+	sink   Sink
+
+	observed atomic.Uint64
+	stale    atomic.Uint64
+	upgraded atomic.Uint64
+}
+
+// NewTracker returns a Tracker that decides whether hashes need rehashing
+// with hasher.NeedsRehash, and records the outcome in sink. If hasher is nil,
+// a zero Hasher is used, which targets the package-level default params.
+func NewTracker(hasher *pbkdf2.Hasher, sink Sink) *Tracker {
+	if hasher == nil {
+		hasher = &pbkdf2.Hasher{}
+	}
+	return &Tracker{hasher: hasher, sink: sink}
+}
+
+// Observe reports whether the hash of userID needs rehashing, and records the
+// answer in the sink. The result is valid even if the sink returns an error.
+func (t *Tracker) Observe(userID, hash string) (needsRehash bool, err error) {
+	needsRehash = t.hasher.NeedsRehash(hash)
+	t.observed.Add(1)
+	if needsRehash {
+		t.stale.Add(1)
+	}
+	return needsRehash, t.sink.Record(userID, needsRehash)
+}
+
+// Upgraded records that the hash of userID has been replaced with one that
+// does not need rehashing.
+func (t *Tracker) Upgraded(userID string) error {
+	t.upgraded.Add(1)
+	return t.sink.Record(userID, false)
+}
+
+// Progress returns the current state of the migration.
+func (t *Tracker) Progress() (Progress, error) {
+	pending, err := t.sink.Pending()
+	return Progress{
+		Observed: t.observed.Load(),
+		Stale:    t.stale.Load(),
+		Upgraded: t.upgraded.Load(),
+		Pending:  pending,
+	}, err
+}
+
+// MemorySink is a Sink that keeps the pending users in memory. The zero value
+// is ready to use.
+type MemorySink struct {
+	mu      sync.Mutex
+	pending map[string]struct{}
+}
+
+// Record implements Sink.
+func (s *MemorySink) Record(userID string, pending bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !pending {
+		delete(s.pending, userID)
+		return nil
+	}
+	if s.pending == nil {
+		s.pending = make(map[string]struct{})
+	}
+	s.pending[userID] = struct{}{}
+	return nil
+}
+
+// Pending implements Sink.
+func (s *MemorySink) Pending() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending), nil
+}
+
+// Users returns the IDs of the pending users, sorted.
+func (s *MemorySink) Users() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := make([]string, 0, len(s.pending))
+	for id := range s.pending {
+		users = append(users, id)
+	}
+	sort.Strings(users)
+	return users
+}
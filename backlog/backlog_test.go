@@ -0,0 +1,76 @@
+package backlog
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+func TestTracker(t *testing.T) {
+	oldParams := &pbkdf2.Params{Iterations: 1000, SaltLength: 16, KeyLength: 32}
+	hasher := &pbkdf2.Hasher{Params: &pbkdf2.Params{Iterations: 2000, SaltLength: 16, KeyLength: 32}}
+
+	oldHash, err := pbkdf2.CreateHash("pa$$word", oldParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	currentHash, err := hasher.CreateHash("pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sink := &MemorySink{}
+	tracker := NewTracker(hasher, sink)
+
+	for _, id := range []string{"alice", "bob", "alice"} {
+		needsRehash, err := tracker.Observe(id, oldHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !needsRehash {
+			t.Fatal("expected old hash to need rehashing")
+		}
+	}
+	if needsRehash, err := tracker.Observe("carol", currentHash); err != nil || needsRehash {
+		t.Fatalf("expected current hash not to need rehashing, got %v, %v", needsRehash, err)
+	}
+	if err := tracker.Upgraded("alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	progress, err := tracker.Progress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Progress{Observed: 4, Stale: 3, Upgraded: 1, Pending: 1}
+	if progress != want {
+		t.Fatalf("expected %#v got %#v", want, progress)
+	}
+	if users := sink.Users(); !reflect.DeepEqual(users, []string{"bob"}) {
+		t.Fatalf("expected [bob] got %v", users)
+	}
+}
+
+type failingSink struct{}
+
+var errSink = errors.New("sink unavailable")
+
+func (failingSink) Record(string, bool) error { return errSink }
+func (failingSink) Pending() (int, error)     { return 0, errSink }
+
+func TestTrackerSinkError(t *testing.T) {
+	tracker := NewTracker(nil, failingSink{})
+
+	needsRehash, err := tracker.Observe("alice", "$pbkdf2$1000$AA$AA")
+	if err != errSink {
+		t.Fatalf("expected sink error, got %v", err)
+	}
+	if !needsRehash {
+		t.Error("expected result to be valid despite the sink error")
+	}
+	if _, err := tracker.Progress(); err != errSink {
+		t.Fatalf("expected sink error, got %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+// Package bcrypt is a drop-in replacement for golang.org/x/crypto/bcrypt
+// backed by PBKDF2-HMAC-SHA512 hashes from the pbkdf2 package. It exposes the
+// same functions, constants and error values, so that switching from bcrypt
+// only requires changing the import path:
+//
+//	import "github.com/pganguli/pbkdf2/bcrypt"
+//
+//	hash, err := bcrypt.GenerateFromPassword([]byte("pa$$word"), bcrypt.DefaultCost)
+//	err = bcrypt.CompareHashAndPassword(hash, []byte("pa$$word"))
+//
+// The returned hashes are in the pbkdf2 package's format, and can be verified
+// with pbkdf2.ComparePasswordAndHash. They cannot be verified by the real
+// bcrypt package, and bcrypt hashes cannot be verified by this one.
+//
+// Like bcrypt, each increment of cost doubles the work factor. DefaultCost maps
+// to the pbkdf2 package's 210000 iterations, so cost 11 uses 420000 and cost 9
+// uses 105000; see Iterations. Unlike bcrypt, passwords longer than 72 bytes
+// are supported, and MaxCost is 24, as higher costs would exceed the 32-bit
+// iteration count.
+package bcrypt
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+const (
+	MinCost     int = 4  // the minimum allowable cost as passed in to GenerateFromPassword
+	MaxCost     int = 24 // the maximum allowable cost as passed in to GenerateFromPassword
+	DefaultCost int = 10 // the cost that will actually be set if a cost below MinCost is passed into GenerateFromPassword
+)
+
+// The iteration count that DefaultCost maps to, and the salt and key lengths
+// of generated hashes.
+const (
+	defaultIterations = 210000
+	saltLength        = 16
+	keyLength         = 64
+)
+
+// The error returned from CompareHashAndPassword when a password and hash do
+// not match. It is the same value as pbkdf2.ErrMismatchedHashAndPassword.
+var ErrMismatchedHashAndPassword = pbkdf2.ErrMismatchedHashAndPassword
+
+// The error returned from CompareHashAndPassword when a hash is too short to
+// be a hash generated by this package.
+var ErrHashTooShort = errors.New("pbkdf2/bcrypt: hashedSecret too short to be a hashed password")
+
+// ErrPasswordTooLong is never returned, as PBKDF2 places no limit on the
+// length of the password. It is provided for source compatibility with bcrypt.
+var ErrPasswordTooLong = errors.New("pbkdf2/bcrypt: password length exceeds 72 bytes")
+
+// HashVersionTooNewError is never returned; hashes using an unsupported
+// PBKDF2 variant are reported with pbkdf2.ErrIncompatibleVariant instead. It
+// is provided for source compatibility with bcrypt.
+type HashVersionTooNewError byte
+
+func (hv HashVersionTooNewError) Error() string {
+	return "pbkdf2/bcrypt: hash version '" + string(rune(hv)) + "' is newer than supported"
+}
+
+// The error returned from CompareHashAndPassword when a hash starts with something other than '$'
+type InvalidHashPrefixError byte
+
+func (ih InvalidHashPrefixError) Error() string {
+	return "pbkdf2/bcrypt: hashes must start with '$', but hashedSecret started with '" + string(rune(ih)) + "'"
+}
+
+// The error returned from GenerateFromPassword when cost is above MaxCost.
+type InvalidCostError int
+
+func (ic InvalidCostError) Error() string {
+	return "pbkdf2/bcrypt: cost " + strconv.Itoa(int(ic)) + " is outside allowed range (" + strconv.Itoa(MinCost) + "," + strconv.Itoa(MaxCost) + ")"
+}
+
+// minHashSize is the length of the shortest hash GenerateFromPassword could
+// produce, with a single-digit iteration count.
+var minHashSize = len("$pbkdf2-sha512$1$") + (saltLength*8+5)/6 + 1 + (keyLength*8+5)/6
+
+// GenerateFromPassword returns the hash of the password at the given cost. If
+// the cost given is less than MinCost, the cost will be set to DefaultCost,
+// instead. Use CompareHashAndPassword, as defined in this package, to compare
+// the returned hashed password with its cleartext version.
+func GenerateFromPassword(password []byte, cost int) ([]byte, error) {
+	if cost < MinCost {
+		cost = DefaultCost
+	}
+	iterations, err := Iterations(cost)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := pbkdf2.NewSecureBytes(append([]byte(nil), password...))
+	defer secret.Destroy()
+
+	hash, err := pbkdf2.CreateHashSecure(secret, &pbkdf2.Params{
+		Iterations: iterations,
+		SaltLength: saltLength,
+		KeyLength:  keyLength,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []byte(hash), nil
+}
+
+// CompareHashAndPassword compares a hashed password with its possible
+// plaintext equivalent. Returns nil on success, or an error on failure.
+func CompareHashAndPassword(hashedPassword, password []byte) error {
+	if err := checkPrefix(hashedPassword); err != nil {
+		return err
+	}
+
+	secret := pbkdf2.NewSecureBytes(append([]byte(nil), password...))
+	defer secret.Destroy()
+
+	return pbkdf2.VerifySecure(secret, string(hashedPassword))
+}
+
+// Cost returns the hashing cost used to create the given hashed password.
+// When, in the future, the hashing cost of a password system needs to be
+// increased in order to adjust for greater computational power, this function
+// allows one to establish which passwords need to be updated.
+//
+// For hashes not generated by GenerateFromPassword, Cost returns the highest
+// cost whose iteration count does not exceed that of the hash, or 0 if the
+// hash uses fewer iterations than MinCost.
+func Cost(hashedPassword []byte) (int, error) {
+	if err := checkPrefix(hashedPassword); err != nil {
+		return 0, err
+	}
+	params, _, _, err := pbkdf2.DecodeHash(string(hashedPassword))
+	if err != nil {
+		return 0, err
+	}
+
+	cost := 0
+	for c := MinCost; c <= MaxCost; c++ {
+		iterations, _ := Iterations(c)
+		if iterations > params.Iterations {
+			break
+		}
+		cost = c
+	}
+	return cost, nil
+}
+
+// Iterations returns the PBKDF2 iteration count that cost maps to. It returns
+// an InvalidCostError if cost is outside the range [MinCost, MaxCost].
+func Iterations(cost int) (uint32, error) {
+	if cost < MinCost || cost > MaxCost {
+		return 0, InvalidCostError(cost)
+	}
+	if cost < DefaultCost {
+		return defaultIterations >> uint(DefaultCost-cost), nil
+	}
+	return defaultIterations << uint(cost-DefaultCost), nil
+}
+
+func checkPrefix(hashedPassword []byte) error {
+	if len(hashedPassword) < minHashSize {
+		return ErrHashTooShort
+	}
+	if hashedPassword[0] != '$' {
+		return InvalidHashPrefixError(hashedPassword[0])
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package bcrypt
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+func TestGenerateAndCompare(t *testing.T) {
+	hash, err := GenerateFromPassword([]byte("pa$$word"), MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CompareHashAndPassword(hash, []byte("pa$$word")); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if err := CompareHashAndPassword(hash, []byte("otherPa$$word")); err != ErrMismatchedHashAndPassword {
+		t.Fatalf("expected ErrMismatchedHashAndPassword, got %v", err)
+	}
+
+	// Hashes are interchangeable with the pbkdf2 package.
+	match, err := pbkdf2.ComparePasswordAndHash("pa$$word", string(hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !match {
+		t.Error("expected password and hash to match")
+	}
+
+	cost, err := Cost(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost != MinCost {
+		t.Fatalf("expected cost %d, got %d", MinCost, cost)
+	}
+}
+
+func TestIterations(t *testing.T) {
+	tests := map[int]uint32{
+		4:  3281,
+		9:  105000,
+		10: 210000,
+		11: 420000,
+		24: 3440640000,
+	}
+	for cost, want := range tests {
+		got, err := Iterations(cost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("cost %d: expected %d iterations, got %d", cost, want, got)
+		}
+	}
+
+	var costErr InvalidCostError
+	if _, err := GenerateFromPassword([]byte("pa$$word"), MaxCost+1); !errors.As(err, &costErr) || int(costErr) != MaxCost+1 {
+		t.Fatalf("expected InvalidCostError, got %v", err)
+	}
+}
+
+func TestCostOfForeignHash(t *testing.T) {
+	hash, err := pbkdf2.CreateHash("pa$$word", &pbkdf2.Params{Iterations: 300000, SaltLength: 16, KeyLength: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cost, err := Cost([]byte(hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost != DefaultCost {
+		t.Fatalf("expected cost %d, got %d", DefaultCost, cost)
+	}
+}
+
+func TestInvalidHash(t *testing.T) {
+	if err := CompareHashAndPassword([]byte("$2a$10$short"), []byte("pa$$word")); err != ErrHashTooShort {
+		t.Fatalf("expected ErrHashTooShort, got %v", err)
+	}
+
+	hash, err := GenerateFromPassword([]byte("pa$$word"), MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash[0] = 'x'
+	var prefixErr InvalidHashPrefixError
+	if err := CompareHashAndPassword(hash, []byte("pa$$word")); !errors.As(err, &prefixErr) || prefixErr != 'x' {
+		t.Fatalf("expected InvalidHashPrefixError, got %v", err)
+	}
+}
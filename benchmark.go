@@ -0,0 +1,87 @@
+package pbkdf2
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrInvalidBenchmark is returned by Benchmark if n is not positive.
+var ErrInvalidBenchmark = errors.New("pbkdf2: benchmark needs at least one run")
+
+// BenchmarkResult summarises the durations of a series of key derivations.
+type BenchmarkResult struct {
+	// N is the number of derivations run.
+	N int
+
+	// Min, Median, P95 and Max are order statistics of the individual
+	// derivation durations. P95 uses the nearest-rank method.
+	Min    time.Duration
+	Median time.Duration
+	P95    time.Duration
+	Max    time.Duration
+
+	// HashesPerSecond is the throughput of a single goroutine: N divided by
+	// the total time spent deriving. Throughput scales roughly linearly with
+	// the number of cores that can be dedicated to hashing.
+	HashesPerSecond float64
+}
+
+// Benchmark runs n key derivations with params, one after another on the
+// calling goroutine, and returns statistics about their durations. It is
+// intended for capacity planning, to find how many verifications per second a
+// machine can sustain with given params. If params is nil, the package-level
+// default params are used.
+func Benchmark(params *Params, n int) (*BenchmarkResult, error) {
+	if n <= 0 {
+		return nil, ErrInvalidBenchmark
+	}
+	if params == nil {
+		params = GetDefaultParams()
+	}
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	password := SecureBytesFromString("benchmark password")
+	defer password.Destroy()
+	salt := NewSecureBytes(make([]byte, params.SaltLength))
+	defer salt.Destroy()
+
+	durations := make([]time.Duration, n)
+	var total time.Duration
+	for i := range durations {
+		start := time.Now()
+		deriveKey(password, salt, params).Destroy()
+		durations[i] = time.Since(start)
+		total += durations[i]
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	result := &BenchmarkResult{
+		N:      n,
+		Min:    durations[0],
+		Median: median(durations),
+		P95:    percentile(durations, 95),
+		Max:    durations[n-1],
+	}
+	if total > 0 {
+		result.HashesPerSecond = float64(n) / total.Seconds()
+	}
+	return result, nil
+}
+
+// percentile returns the p-th percentile of sorted durations, using the
+// nearest-rank method.
+func percentile(d []time.Duration, p int) time.Duration {
+	return d[(p*len(d)+99)/100-1]
+}
+
+// median returns the median of sorted durations.
+func median(d []time.Duration) time.Duration {
+	n := len(d)
+	if n%2 == 1 {
+		return d[n/2]
+	}
+	return (d[n/2-1] + d[n/2]) / 2
+}
@@ -0,0 +1,45 @@
+package pbkdf2
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBenchmark(t *testing.T) {
+	result, err := Benchmark(&Params{Iterations: 1000, SaltLength: 16, KeyLength: 32}, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.N != 20 || result.Min <= 0 || result.HashesPerSecond <= 0 {
+		t.Fatalf("unexpected result %#v", *result)
+	}
+	if !(result.Min <= result.Median && result.Median <= result.P95 && result.P95 <= result.Max) {
+		t.Fatalf("order statistics out of order: %#v", *result)
+	}
+
+	if _, err := Benchmark(nil, 0); err != ErrInvalidBenchmark {
+		t.Fatalf("expected ErrInvalidBenchmark, got %v", err)
+	}
+	if _, err := Benchmark(&Params{}, 1); err != ErrInvalidParams {
+		t.Fatalf("expected ErrInvalidParams, got %v", err)
+	}
+}
+
+func TestOrderStatistics(t *testing.T) {
+	d := make([]time.Duration, 20)
+	for i := range d {
+		d[i] = time.Duration(i + 1)
+	}
+	if m := median(d); m != 10 {
+		t.Errorf("expected median 10, got %d", m)
+	}
+	if m := median(d[:5]); m != 3 {
+		t.Errorf("expected median 3, got %d", m)
+	}
+	if p95 := percentile(d, 95); p95 != 19 {
+		t.Errorf("expected p95 19, got %d", p95)
+	}
+	if p95 := percentile(d[:1], 95); p95 != 1 {
+		t.Errorf("expected p95 1, got %d", p95)
+	}
+}
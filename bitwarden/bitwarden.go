@@ -0,0 +1,118 @@
+// Package bitwarden derives the master key and master password hash used by
+// Bitwarden-compatible password managers with the PBKDF2 KDF, so that Go
+// tooling can interoperate with their accounts and encrypted exports.
+//
+// The master key is PBKDF2-HMAC-SHA256 of the master password, salted with
+// the account's email address, trimmed and lowercased. It never leaves the
+// client: the server authenticates a single further PBKDF2 iteration of it,
+// salted with the password, and encrypted data is protected with keys
+// expanded from it with HKDF:
+//
+//	masterKey, err := bitwarden.MasterKey(password, email, iterations)
+//	authHash := bitwarden.MasterPasswordHash(masterKey, password, bitwarden.ServerAuthorization)
+//	encKey, macKey, err := bitwarden.StretchMasterKey(masterKey)
+//
+// Accounts using the Argon2id KDF are not supported.
+package bitwarden
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// DefaultIterations is the PBKDF2 iteration count Bitwarden uses for new
+// accounts.
+const DefaultIterations = 600000
+
+// KeySize is the length of the master key and of the keys expanded from it.
+const KeySize = 32
+
+// ErrEmptyEmail is returned by MasterKey if the email address is empty, since
+// the salt would then be empty.
+var ErrEmptyEmail = errors.New("bitwarden: email address is empty")
+
+// A Purpose selects the number of iterations of the master password hash.
+type Purpose int
+
+const (
+	// ServerAuthorization is the hash sent to the server to log in, using one
+	// iteration.
+	ServerAuthorization Purpose = 1
+
+	// LocalAuthorization is the hash clients keep to unlock a vault offline,
+	// using two iterations.
+	LocalAuthorization Purpose = 2
+)
+
+// MasterKey derives the master key from the master password and the
+// account's email address. It returns pbkdf2.ErrInvalidParams if iterations
+// is zero. The caller should destroy the key once it is no longer needed.
+func MasterKey(password, email string, iterations uint32) (*pbkdf2.SecureBytes, error) {
+	salt := strings.ToLower(strings.TrimSpace(email))
+	if salt == "" {
+		return nil, ErrEmptyEmail
+	}
+	secret := pbkdf2.SecureBytesFromString(password)
+	defer secret.Destroy()
+
+	return pbkdf2.DeriveKey(secret, []byte(salt), &pbkdf2.Params{
+		Iterations: iterations,
+		SaltLength: uint32(len(salt)),
+		KeyLength:  KeySize,
+		Variant:    pbkdf2.VariantSHA256,
+	})
+}
+
+// MasterPasswordHash returns the base64-encoded master password hash for the
+// given purpose: PBKDF2-HMAC-SHA256 of the master key, salted with the master
+// password. It panics if purpose is not ServerAuthorization or
+// LocalAuthorization.
+func MasterPasswordHash(masterKey *pbkdf2.SecureBytes, password string, purpose Purpose) string {
+	if purpose != ServerAuthorization && purpose != LocalAuthorization {
+		panic("bitwarden: invalid purpose")
+	}
+	hash, err := pbkdf2.DeriveKey(masterKey, []byte(password), &pbkdf2.Params{
+		Iterations: uint32(purpose),
+		SaltLength: 1,
+		KeyLength:  KeySize,
+		Variant:    pbkdf2.VariantSHA256,
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer hash.Destroy()
+	return base64.StdEncoding.EncodeToString(hash.Bytes())
+}
+
+// StretchMasterKey expands the master key into the encryption and MAC keys
+// that protect the account's symmetric key, using HKDF-Expand with SHA-256
+// and the info strings "enc" and "mac". The caller should destroy both keys
+// once they are no longer needed.
+func StretchMasterKey(masterKey *pbkdf2.SecureBytes) (encKey, macKey *pbkdf2.SecureBytes, err error) {
+	expand := func(info string) (*pbkdf2.SecureBytes, error) {
+		key := make([]byte, KeySize)
+		_, err := io.ReadFull(hkdf.Expand(sha256.New, masterKey.Bytes(), []byte(info)), key)
+		runtime.KeepAlive(masterKey)
+		if err != nil {
+			return nil, err
+		}
+		return pbkdf2.NewSecureBytes(key), nil
+	}
+
+	if encKey, err = expand("enc"); err != nil {
+		return nil, nil, err
+	}
+	if macKey, err = expand("mac"); err != nil {
+		encKey.Destroy()
+		return nil, nil, err
+	}
+	return encKey, macKey, nil
+}
@@ -0,0 +1,81 @@
+package bitwarden
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// The vectors were generated with Python's hashlib and hmac:
+//
+//	mk = hashlib.pbkdf2_hmac("sha256", b"correct horse battery staple", b"user@example.com", 5000, 32)
+//	base64.b64encode(hashlib.pbkdf2_hmac("sha256", mk, b"correct horse battery staple", 1, 32))
+//	hmac.new(mk, b"enc\x01", "sha256").hexdigest()
+const (
+	testPassword   = "correct horse battery staple"
+	testEmail      = "  User@Example.com "
+	testIterations = 5000
+
+	testMasterKey  = "52ce2d33e007a7c15b3e3083c551fcff97da83045fa80cc6bbbd2cbc52c6f084"
+	testServerHash = "0FMeontUyfpu9Ga/DvERL9LMAXg9KB82VK6UqHdnKko="
+	testLocalHash  = "QLA7fncHl/FMIfotcW68iOkdHp309ggo7RdA5FKbUKk="
+	testEncKey     = "83b79d8c4f57f2e6ab04e4427c3fcc0d9bdeff981254a535a3df2bd2a4df84a7"
+	testMACKey     = "016149a9eb912a3d9a41c4b3878a833e3cac88feef99dc4b1a6258716113e881"
+)
+
+func TestMasterKey(t *testing.T) {
+	key, err := MasterKey(testPassword, testEmail, testIterations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Destroy()
+	if got := hex.EncodeToString(key.Bytes()); got != testMasterKey {
+		t.Fatalf("master key = %s, want %s", got, testMasterKey)
+	}
+
+	if got := MasterPasswordHash(key, testPassword, ServerAuthorization); got != testServerHash {
+		t.Errorf("server hash = %s, want %s", got, testServerHash)
+	}
+	if got := MasterPasswordHash(key, testPassword, LocalAuthorization); got != testLocalHash {
+		t.Errorf("local hash = %s, want %s", got, testLocalHash)
+	}
+}
+
+func TestMasterKeyErrors(t *testing.T) {
+	if _, err := MasterKey(testPassword, " ", testIterations); err != ErrEmptyEmail {
+		t.Errorf("empty email: err = %v, want ErrEmptyEmail", err)
+	}
+	if _, err := MasterKey(testPassword, testEmail, 0); !errors.Is(err, pbkdf2.ErrInvalidParams) {
+		t.Errorf("zero iterations: err = %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestMasterPasswordHashInvalidPurpose(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("MasterPasswordHash did not panic")
+		}
+	}()
+	MasterPasswordHash(pbkdf2.NewSecureBytes(make([]byte, KeySize)), testPassword, 0)
+}
+
+func TestStretchMasterKey(t *testing.T) {
+	b, _ := hex.DecodeString(testMasterKey)
+	key := pbkdf2.NewSecureBytes(b)
+	defer key.Destroy()
+
+	enc, mac, err := StretchMasterKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Destroy()
+	defer mac.Destroy()
+	if got := hex.EncodeToString(enc.Bytes()); got != testEncKey {
+		t.Errorf("enc key = %s, want %s", got, testEncKey)
+	}
+	if got := hex.EncodeToString(mac.Bytes()); got != testMACKey {
+		t.Errorf("mac key = %s, want %s", got, testMACKey)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build asm && !(amd64 || arm64 || loong64 || ppc64 || ppc64le || riscv64 || s390x)
+
+package pbkdf2
+
+// The standard library has no assembly implementation of the hash functions
+// for this architecture.
+var _ = build_tag_asm_is_unsupported_on_this_architecture
@@ -0,0 +1,6 @@
+//go:build openssl && !cgo
+
+package pbkdf2
+
+// The OpenSSL backend is called through cgo.
+var _ = build_tag_openssl_requires_cgo
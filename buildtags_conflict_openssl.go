@@ -0,0 +1,7 @@
+//go:build openssl && (purego || asm)
+
+package pbkdf2
+
+// The openssl build tag pins the OpenSSL backend, which cannot be combined
+// with the Go backends.
+var _ = build_tag_openssl_cannot_be_combined_with_purego_or_asm
@@ -0,0 +1,6 @@
+//go:build purego && asm
+
+package pbkdf2
+
+// The purego and asm build tags are mutually exclusive.
+var _ = build_tags_purego_and_asm_are_mutually_exclusive
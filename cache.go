@@ -0,0 +1,121 @@
+package pbkdf2
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DecodeCache is a bounded, least-recently-used cache of decoded hashes, for
+// services that verify the same stored hashes over and over, such as those of
+// service accounts and devices. With it set as Hasher.DecodeCache, verifying
+// a cached hash skips parsing it and decoding its base64:
+//
+//	cache := pbkdf2.NewDecodeCache(1000)
+//	h := &pbkdf2.Hasher{DecodeCache: cache}
+//
+// Only hashes that decode are cached. The cache holds their salts and keys in
+// memory, as the hashes themselves are held by the caller; Purge wipes them.
+// It is safe for concurrent use, and may be shared by several Hashers.
+type DecodeCache struct {
+	capacity int
+
+	mu    sync.Mutex
+	order *list.List // of *cacheEntry, most recently used first
+	items map[string]*list.Element
+
+	hits, misses uint64
+}
+
+type cacheEntry struct {
+	hash    string
+	decoded *Hash
+}
+
+// NewDecodeCache returns a DecodeCache holding at most capacity hashes. It
+// panics if capacity is not positive.
+func NewDecodeCache(capacity int) *DecodeCache {
+	if capacity <= 0 {
+		panic("pbkdf2: decode cache capacity must be positive")
+	}
+	return &DecodeCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Len returns the number of hashes in the cache.
+func (c *DecodeCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Stats returns the number of lookups that found a hash in the cache, and the
+// number that had to decode it.
+func (c *DecodeCache) Stats() (hits, misses uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// Purge removes every hash from the cache, wiping their salts and keys.
+func (c *DecodeCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for e := c.order.Front(); e != nil; e = e.Next() {
+		wipeCached(e.Value.(*cacheEntry).decoded)
+	}
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+// decode is decodeHash, served from the cache where possible. The result is a
+// copy that the caller may wipe, sharing only the read-only metadata.
+func (c *DecodeCache) decode(hash string) (*Hash, error) {
+	c.mu.Lock()
+	if e, ok := c.items[hash]; ok {
+		c.hits++
+		c.order.MoveToFront(e)
+		h := cloneHash(e.Value.(*cacheEntry).decoded)
+		c.mu.Unlock()
+		return h, nil
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	decoded, err := decodeHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	h := cloneHash(decoded)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.items[hash]; !ok {
+		c.items[hash] = c.order.PushFront(&cacheEntry{hash: hash, decoded: decoded})
+		if c.order.Len() > c.capacity {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			entry := oldest.Value.(*cacheEntry)
+			delete(c.items, entry.hash)
+			wipeCached(entry.decoded)
+		}
+	} else {
+		wipeCached(decoded)
+	}
+	return h, nil
+}
+
+// cloneHash copies h, with its salt and key in a single allocation.
+func cloneHash(h *Hash) *Hash {
+	b := make([]byte, len(h.Salt)+len(h.Key))
+	n := copy(b, h.Salt)
+	copy(b[n:], h.Key)
+	return &Hash{Params: h.Params, Salt: b[:n:n], Key: b[n:], Metadata: h.Metadata}
+}
+
+func wipeCached(h *Hash) {
+	wipe(h.Salt)
+	wipe(h.Key)
+}
@@ -0,0 +1,80 @@
+package pbkdf2
+
+import (
+	"testing"
+)
+
+func TestDecodeCache(t *testing.T) {
+	cache := NewDecodeCache(2)
+	h := &Hasher{Params: testParams, DecodeCache: cache, Policy: &Policy{MinIterations: 1}}
+	a, err := h.CreateHash("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := h.CreateHash("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := h.CreateHash("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := h.Verify("a", a); err != nil {
+			t.Fatalf("verify %d: %v", i, err)
+		}
+		if err := h.Verify("wrong", a); err != ErrMismatchedHashAndPassword {
+			t.Fatalf("verify %d: expected a mismatch, got %v", i, err)
+		}
+	}
+	if hits, misses := cache.Stats(); misses != 1 || hits != 11 {
+		t.Fatalf("expected 1 miss and 11 hits, got %d and %d", misses, hits)
+	}
+
+	// c evicts a, the least recently used after b is verified.
+	if err := h.Verify("b", b); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Verify("c", c); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Len() != 2 {
+		t.Fatalf("expected 2 cached hashes, got %d", cache.Len())
+	}
+	_, misses := cache.Stats()
+	if err := h.Verify("a", a); err != nil {
+		t.Fatal(err)
+	}
+	if _, again := cache.Stats(); again != misses+1 {
+		t.Fatal("expected the evicted hash to be decoded again")
+	}
+
+	if err := h.Verify("a", "$pbkdf2-sha512$1000$bad"); err == nil {
+		t.Fatal("expected an invalid hash to fail")
+	}
+	if cache.Len() != 2 {
+		t.Fatal("expected invalid hashes not to be cached")
+	}
+
+	cache.Purge()
+	if cache.Len() != 0 {
+		t.Fatal("expected an empty cache")
+	}
+	if err := h.Verify("a", a); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodeCacheAllocs(t *testing.T) {
+	cache := NewDecodeCache(10)
+	hash := "$pbkdf2-sha512$ns=acme,norm=nfkc$1000$KuwdBW88vV7YiVGWsMmc8g$XO+ztCemYHheH1kqHe6QAmb99lL3MI7IeBQ05dnAXGk"
+	if _, err := cache.decode(hash); err != nil {
+		t.Fatal(err)
+	}
+	cached := testing.AllocsPerRun(100, func() { cache.decode(hash) })
+	uncached := testing.AllocsPerRun(100, func() { decodeHash(hash) })
+	if cached >= uncached {
+		t.Fatalf("expected fewer allocations from the cache: %v, against %v", cached, uncached)
+	}
+}
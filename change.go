@@ -0,0 +1,16 @@
+package pbkdf2
+
+// VerifyOldAndHashNew implements the core of a password change: it verifies
+// oldPassword against hash and, only if it matches, returns a hash of
+// newPassword created with params. If params is nil, the package-level
+// default params are used.
+//
+// The new hash is created even if the old password does not match, so that
+// the time taken does not reveal whether it did. It returns
+// ErrMismatchedHashAndPassword if the old password does not match, or any
+// error from decoding hash or creating the new hash; in every error case no
+// hash is returned, so the caller can unconditionally store the result on
+// success.
+func VerifyOldAndHashNew(oldPassword, newPassword, hash string, params *Params) (newHash string, err error) {
+	return (&Hasher{Params: params}).VerifyOldAndHashNew(oldPassword, newPassword, hash)
+}
@@ -0,0 +1,35 @@
+package pbkdf2
+
+import "testing"
+
+func TestVerifyOldAndHashNew(t *testing.T) {
+	params := &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32}
+	hash, err := CreateHash("old", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newHash, err := VerifyOldAndHashNew("old", "new", hash, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify("new", newHash); err != nil {
+		t.Errorf("new hash does not verify: %v", err)
+	}
+
+	if newHash, err := VerifyOldAndHashNew("wrong", "new", hash, params); err != ErrMismatchedHashAndPassword || newHash != "" {
+		t.Errorf("expected ErrMismatchedHashAndPassword and no hash, got %q, %v", newHash, err)
+	}
+	if _, err := VerifyOldAndHashNew("old", "new", hash, &Params{}); err != ErrInvalidParams {
+		t.Errorf("expected ErrInvalidParams, got %v", err)
+	}
+	if _, err := VerifyOldAndHashNew("old", "new", "$pbkdf2-sha512$1000", params); err == nil {
+		t.Error("expected an error for an invalid hash")
+	}
+
+	h := &Hasher{Params: params, Namespace: "acme"}
+	newHash, err = h.VerifyOldAndHashNew("old", "new", hash)
+	if err != ErrNamespaceMismatch || newHash != "" {
+		t.Errorf("expected ErrNamespaceMismatch, got %q, %v", newHash, err)
+	}
+}
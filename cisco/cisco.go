@@ -0,0 +1,118 @@
+// Package cisco creates and verifies Cisco IOS type 8 secrets, as configured
+// with "enable algorithm-type sha256 secret" or "username ... algorithm-type
+// sha256 secret", so that network automation can mint and audit device
+// credentials:
+//
+//	$8$<salt>$<key>
+//
+// A type 8 secret is PBKDF2-HMAC-SHA256 with 20000 iterations and a 32-byte
+// key. The salt is 14 characters from the Cisco alphabet, and is used as it
+// appears rather than decoded. The key is base64 encoded with the Cisco
+// alphabet, "./0-9A-Za-z" in that order, without padding.
+package cisco
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"io"
+	"strings"
+
+	xpbkdf2 "golang.org/x/crypto/pbkdf2"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// Parameters of type 8 secrets.
+const (
+	Prefix     = "$8$"
+	Iterations = 20000
+	SaltLength = 14
+	KeyLength  = sha256.Size
+)
+
+const alphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var encoding = base64.NewEncoding(alphabet).WithPadding(base64.NoPadding)
+
+// ErrInvalidHash is returned if a secret is not in the type 8 format. It is
+// the same value as pbkdf2.ErrInvalidHash.
+var ErrInvalidHash = pbkdf2.ErrInvalidHash
+
+// A Type8 is a decoded type 8 secret.
+type Type8 struct {
+	// Salt is the salt as it appears in the secret.
+	Salt string
+
+	// Key is the derived key.
+	Key []byte
+}
+
+// Parse decodes a type 8 secret.
+func Parse(secret string) (*Type8, error) {
+	rest := strings.TrimPrefix(secret, Prefix)
+	if rest == secret {
+		return nil, ErrInvalidHash
+	}
+	salt, encoded, ok := strings.Cut(rest, "$")
+	if !ok || len(salt) != SaltLength || strings.Trim(salt, alphabet) != "" {
+		return nil, ErrInvalidHash
+	}
+	key, err := encoding.Strict().DecodeString(encoded)
+	if err != nil || len(key) != KeyLength {
+		return nil, ErrInvalidHash
+	}
+	return &Type8{Salt: salt, Key: key}, nil
+}
+
+// String returns the encoded secret.
+func (t *Type8) String() string {
+	return Prefix + t.Salt + "$" + encoding.EncodeToString(t.Key)
+}
+
+// Generate returns a type 8 secret for password with a random salt.
+func Generate(password string) (string, error) {
+	b := make([]byte, SaltLength)
+	if _, err := io.ReadFull(pbkdf2.Rand, b); err != nil {
+		return "", err
+	}
+	// len(alphabet) divides 256, so this is uniform.
+	for i := range b {
+		b[i] = alphabet[b[i]%byte(len(alphabet))]
+	}
+	t := &Type8{Salt: string(b), Key: derive(password, string(b))}
+	return t.String(), nil
+}
+
+// Verify returns nil if password matches secret,
+// pbkdf2.ErrMismatchedHashAndPassword if it does not, and ErrInvalidHash if
+// secret is not a type 8 secret. The crypt package uses it for $8$ hashes.
+func Verify(password, secret string) error {
+	t, err := Parse(secret)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(derive(password, t.Salt), t.Key) != 1 {
+		return pbkdf2.ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+// ToHash converts the secret into a pbkdf2 hash, which verifies the same
+// passwords with pbkdf2.CheckHash.
+func (t *Type8) ToHash() *pbkdf2.Hash {
+	return &pbkdf2.Hash{
+		Params: pbkdf2.Params{
+			Iterations: Iterations,
+			SaltLength: SaltLength,
+			KeyLength:  KeyLength,
+			Variant:    pbkdf2.VariantSHA256,
+		},
+		Salt: []byte(t.Salt),
+		Key:  append([]byte(nil), t.Key...),
+	}
+}
+
+func derive(password, salt string) []byte {
+	return xpbkdf2.Key([]byte(password), []byte(salt), Iterations, KeyLength, sha256.New)
+}
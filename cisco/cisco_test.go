@@ -0,0 +1,59 @@
+package cisco
+
+import (
+	"testing"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// The example from hashcat's documentation for mode 9200.
+const example = "$8$TnGX/fE4KGHOVU$pEhnEvxrvaynpi8j4f.EMHr6M.FzU8xnZnBr/tJdFWk"
+
+func TestVerify(t *testing.T) {
+	if err := Verify("hashcat", example); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify("wrong", example); err != pbkdf2.ErrMismatchedHashAndPassword {
+		t.Errorf("expected ErrMismatchedHashAndPassword, got %v", err)
+	}
+
+	parsed, err := Parse(example)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.String() != example {
+		t.Errorf("round trip produced %s", parsed)
+	}
+	if err := pbkdf2.Verify("hashcat", parsed.ToHash().String()); err != nil {
+		t.Errorf("converted hash does not verify: %v", err)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	secret, err := Generate("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret) != len(example) {
+		t.Errorf("unexpected length of %s", secret)
+	}
+	if err := Verify("password", secret); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, secret := range []string{
+		"",
+		"$9$TnGX/fE4KGHOVU$pEhnEvxrvaynpi8j4f.EMHr6M.FzU8xnZnBr/tJdFWk",
+		"$8$TnGX/fE4KGHOV$pEhnEvxrvaynpi8j4f.EMHr6M.FzU8xnZnBr/tJdFWk",
+		"$8$TnGX/fE4KGHOV+$pEhnEvxrvaynpi8j4f.EMHr6M.FzU8xnZnBr/tJdFWk",
+		"$8$TnGX/fE4KGHOVU$pEhnEvxrvaynpi8j4f.EMHr6M.FzU8xnZnBr/tJdFW",
+		"$8$TnGX/fE4KGHOVU$pEhnEvxrvaynpi8j4f+EMHr6M.FzU8xnZnBr/tJdFWk",
+		"$8$TnGX/fE4KGHOVU",
+	} {
+		if _, err := Parse(secret); err != ErrInvalidHash {
+			t.Errorf("%q: expected ErrInvalidHash, got %v", secret, err)
+		}
+	}
+}
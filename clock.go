@@ -0,0 +1,105 @@
+package pbkdf2
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A Clock tells the time and waits, so that code depending on time, such as
+// Hasher.MinFailureDuration, Target calibration and rate limiting, can be
+// tested and simulated deterministically with a FakeClock. Implementations
+// must be safe for concurrent use.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// Since returns the time elapsed since t.
+	Since(t time.Time) time.Duration
+
+	// Sleep waits for d, or until ctx is done.
+	Sleep(ctx context.Context, d time.Duration)
+}
+
+// SystemClock is the Clock used when none is set: the time package's clocks,
+// with monotonic readings for Since.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) Since(t time.Time) time.Duration { return time.Since(t) }
+
+func (systemClock) Sleep(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
+// clockOrSystem returns c, or SystemClock if c is nil.
+func clockOrSystem(c Clock) Clock {
+	if c == nil {
+		return SystemClock
+	}
+	return c
+}
+
+// FakeClock is a Clock whose time only moves when told to. Sleep returns at
+// once, advancing the time by the duration slept, so that simulated delays
+// take no real time. It is safe for concurrent use.
+type FakeClock struct {
+	mu   sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the clock's time, and then advances it by the step set with
+// SetStep.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
+
+// Since returns the clock's time, read with Now, less t.
+func (c *FakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Sleep advances the clock by d, unless ctx is already done.
+func (c *FakeClock) Sleep(ctx context.Context, d time.Duration) {
+	if d > 0 && ctx.Err() == nil {
+		c.Advance(d)
+	}
+}
+
+// Advance moves the clock forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// SetStep makes every reading of the clock advance it by d, so that work
+// appears to take d between two readings, as when simulating how long a key
+// derivation takes during calibration. A zero step, the default, stops the
+// clock between calls to Advance and Sleep.
+func (c *FakeClock) SetStep(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.step = d
+}
@@ -0,0 +1,50 @@
+package pbkdf2
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	c := NewFakeClock(start)
+	if !c.Now().Equal(start) || c.Since(start) != 0 {
+		t.Fatal("expected a stopped clock")
+	}
+
+	c.Advance(time.Minute)
+	c.Sleep(context.Background(), time.Second)
+	if d := c.Since(start); d != time.Minute+time.Second {
+		t.Fatalf("expected 1m1s to have passed, got %v", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Sleep(ctx, time.Hour)
+	if d := c.Since(start); d != time.Minute+time.Second {
+		t.Fatalf("expected a cancelled sleep not to advance the clock, got %v", d)
+	}
+
+	c.SetStep(time.Millisecond)
+	t0 := c.Now()
+	if d := c.Since(t0); d != time.Millisecond {
+		t.Fatalf("expected each reading to advance by the step, got %v", d)
+	}
+}
+
+func TestSystemClockSleep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	SystemClock.Sleep(ctx, time.Hour)
+	if time.Since(start) > time.Second {
+		t.Fatal("expected a cancelled sleep to return early")
+	}
+
+	start = SystemClock.Now()
+	SystemClock.Sleep(context.Background(), 10*time.Millisecond)
+	if d := SystemClock.Since(start); d < 10*time.Millisecond {
+		t.Fatalf("slept for only %v", d)
+	}
+}
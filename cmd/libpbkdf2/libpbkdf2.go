@@ -0,0 +1,76 @@
+//go:build cgo
+
+package main
+
+import (
+	"encoding/base64"
+	"math"
+	"strconv"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// Return codes, which must match the definitions in the cgo preamble of
+// main.go.
+const (
+	codeOK              = 0
+	codeMatch           = 1
+	codeMismatch        = 0
+	codeInvalidArgument = -1
+	codeBufferTooSmall  = -2
+	codeInvalidHash     = -3
+	codeIncompatible    = -4
+	codeInternal        = -5
+)
+
+// maxPasswordLen is the longest password accepted, in bytes, since C.GoBytes
+// takes the length as a C int.
+const maxPasswordLen = math.MaxInt32
+
+// hashSize returns the buffer size, including the terminating NUL, needed for
+// a hash with the given salt and key lengths and the widest possible iteration
+// count. If both lengths are zero, it is the size for the package-level
+// default params, which hash selects when every parameter is zero.
+func hashSize(saltLen, keyLen uint32) int {
+	prefix := "$pbkdf2-sha512$"
+	if saltLen == 0 && keyLen == 0 {
+		params := pbkdf2.GetDefaultParams()
+		saltLen, keyLen = params.SaltLength, params.KeyLength
+		if params.Variant != "" {
+			prefix = "$" + params.Variant + "$"
+		}
+	}
+	maxIterations := len(strconv.FormatUint(1<<32-1, 10))
+	enc := base64.RawStdEncoding
+
+	return len(prefix) + maxIterations + 1 + enc.EncodedLen(int(saltLen)) + 1 + enc.EncodedLen(int(keyLen)) + 1
+}
+
+func hash(password *pbkdf2.SecureBytes, iterations, saltLen, keyLen uint32) (string, int) {
+	params := &pbkdf2.Params{Iterations: iterations, SaltLength: saltLen, KeyLength: keyLen}
+	if iterations == 0 && saltLen == 0 && keyLen == 0 {
+		params = nil
+	}
+
+	hash, err := pbkdf2.CreateHashSecure(password, params)
+	if err == pbkdf2.ErrInvalidParams {
+		return "", codeInvalidArgument
+	}
+	if err != nil {
+		return "", codeInternal
+	}
+	return hash, codeOK
+}
+
+func verify(password *pbkdf2.SecureBytes, hash string) int {
+	match, err := pbkdf2.ComparePasswordAndHashSecure(password, hash)
+	switch {
+	case err == pbkdf2.ErrIncompatibleVariant:
+		return codeIncompatible
+	case err != nil:
+		return codeInvalidHash
+	case match:
+		return codeMatch
+	}
+	return codeMismatch
+}
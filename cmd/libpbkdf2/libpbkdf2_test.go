@@ -0,0 +1,61 @@
+//go:build cgo
+
+package main
+
+import (
+	"testing"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	password := pbkdf2.SecureBytesFromString("pa$$word")
+	defer password.Destroy()
+
+	h, code := hash(password, 1000, 16, 64)
+	if code != codeOK {
+		t.Fatalf("expected codeOK, got %d", code)
+	}
+	if len(h)+1 > hashSize(16, 64) {
+		t.Fatalf("hash %q does not fit in hashSize %d", h, hashSize(16, 64))
+	}
+
+	if code := verify(password, h); code != codeMatch {
+		t.Fatalf("expected codeMatch, got %d", code)
+	}
+
+	other := pbkdf2.SecureBytesFromString("otherPa$$word")
+	defer other.Destroy()
+	if code := verify(other, h); code != codeMismatch {
+		t.Fatalf("expected codeMismatch, got %d", code)
+	}
+
+	if code := verify(password, "$pbkdf2-sha512$1000"); code != codeInvalidHash {
+		t.Fatalf("expected codeInvalidHash, got %d", code)
+	}
+	if _, code := hash(password, 1000, 0, 64); code != codeInvalidArgument {
+		t.Fatalf("expected codeInvalidArgument, got %d", code)
+	}
+}
+
+func TestHashSizeDefaults(t *testing.T) {
+	defer pbkdf2.SetDefaultParams(pbkdf2.GetDefaultParams())
+	password := pbkdf2.SecureBytesFromString("pa$$word")
+	defer password.Destroy()
+
+	for _, params := range []*pbkdf2.Params{
+		pbkdf2.GetDefaultParams(),
+		{Iterations: 1000, SaltLength: 32, KeyLength: 64, Variant: pbkdf2.VariantSHA3_512},
+	} {
+		if err := pbkdf2.SetDefaultParams(params); err != nil {
+			t.Fatal(err)
+		}
+		h, code := hash(password, 0, 0, 0)
+		if code != codeOK {
+			t.Fatalf("expected codeOK, got %d", code)
+		}
+		if len(h)+1 > hashSize(0, 0) {
+			t.Errorf("hash %q does not fit in hashSize %d", h, hashSize(0, 0))
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Command libpbkdf2 builds a C shared library exposing this module's password
+// hashing, so that C, C++, Python and other non-Go services can produce and
+// verify hashes that are byte-for-byte compatible with the Go package.
+//
+// Build it with:
+//
+//	go build -buildmode=c-shared -o libpbkdf2.so ./cmd/libpbkdf2
+//
+// which also writes the header libpbkdf2.h next to the library.
+//
+// # ABI
+//
+// The ABI is versioned by pbkdf2_abi_version, which returns PBKDF2_ABI_VERSION
+// (currently 1). Functions are only ever added within a version; existing
+// signatures and return codes do not change.
+//
+// All memory is owned by the caller: no function allocates memory that the
+// caller has to free. Passwords are passed as a pointer and length, so they
+// may contain NUL bytes; Go's copy of the password is wiped before returning.
+// Hashes are NUL-terminated ASCII strings.
+//
+//	uint32_t pbkdf2_abi_version(void);
+//
+//	size_t pbkdf2_hash_size(uint32_t salt_len, uint32_t key_len);
+//
+// Returns the size of the buffer, including the terminating NUL, needed to
+// hold a hash with the given salt and key lengths. Passing zero for both
+// returns the size for the package's default parameters, as selected by
+// pbkdf2_hash.
+//
+//	int pbkdf2_hash(const char *password, size_t password_len,
+//	                uint32_t iterations, uint32_t salt_len, uint32_t key_len,
+//	                char *out, size_t out_len);
+//
+// Hashes the password with the given parameters, writing the NUL-terminated
+// hash to out. Returns PBKDF2_OK on success, or a negative error code. Passing
+// zero for all three parameters selects the package's default parameters.
+//
+//	int pbkdf2_verify(const char *password, size_t password_len, const char *hash);
+//
+// Returns PBKDF2_MATCH (1) if the password matches the hash, PBKDF2_MISMATCH
+// (0) if it does not, or a negative error code.
+//
+// # Error codes
+//
+//	PBKDF2_ERR_INVALID_ARGUMENT   -1  a NULL pointer, a zero parameter or a
+//	                                  password_len above INT_MAX
+//	PBKDF2_ERR_BUFFER_TOO_SMALL   -2  out_len is smaller than pbkdf2_hash_size
+//	PBKDF2_ERR_INVALID_HASH       -3  the hash is not in the correct format
+//	PBKDF2_ERR_INCOMPATIBLE       -4  the hash uses an unsupported variant
+//	PBKDF2_ERR_INTERNAL           -5  any other failure, e.g. reading randomness
+//
+// # Example
+//
+// From Python, using ctypes:
+//
+//	lib = ctypes.CDLL("./libpbkdf2.so")
+//	lib.pbkdf2_hash_size.argtypes = [ctypes.c_uint32, ctypes.c_uint32]
+//	lib.pbkdf2_hash_size.restype = ctypes.c_size_t
+//	lib.pbkdf2_hash.argtypes = [ctypes.c_char_p, ctypes.c_size_t, ctypes.c_uint32,
+//	                            ctypes.c_uint32, ctypes.c_uint32, ctypes.c_char_p, ctypes.c_size_t]
+//	lib.pbkdf2_verify.argtypes = [ctypes.c_char_p, ctypes.c_size_t, ctypes.c_char_p]
+//
+//	buf = ctypes.create_string_buffer(lib.pbkdf2_hash_size(16, 64))
+//	pw = b"pa$$word"
+//	assert lib.pbkdf2_hash(pw, len(pw), 210000, 16, 64, buf, len(buf)) == 0
+//	assert lib.pbkdf2_verify(pw, len(pw), buf.value) == 1
+package main
+
+/*
+#include <stddef.h>
+#include <stdint.h>
+
+#define PBKDF2_ABI_VERSION 1
+
+#define PBKDF2_OK 0
+#define PBKDF2_MATCH 1
+#define PBKDF2_MISMATCH 0
+
+#define PBKDF2_ERR_INVALID_ARGUMENT -1
+#define PBKDF2_ERR_BUFFER_TOO_SMALL -2
+#define PBKDF2_ERR_INVALID_HASH -3
+#define PBKDF2_ERR_INCOMPATIBLE -4
+#define PBKDF2_ERR_INTERNAL -5
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+func main() {}
+
+//export pbkdf2_abi_version
+func pbkdf2_abi_version() C.uint32_t {
+	return C.PBKDF2_ABI_VERSION
+}
+
+//export pbkdf2_hash_size
+func pbkdf2_hash_size(saltLen, keyLen C.uint32_t) C.size_t {
+	return C.size_t(hashSize(uint32(saltLen), uint32(keyLen)))
+}
+
+//export pbkdf2_hash
+func pbkdf2_hash(password *C.char, passwordLen C.size_t, iterations, saltLen, keyLen C.uint32_t, out *C.char, outLen C.size_t) C.int {
+	if (password == nil && passwordLen != 0) || out == nil || passwordLen > maxPasswordLen {
+		return C.PBKDF2_ERR_INVALID_ARGUMENT
+	}
+
+	secret := pbkdf2.NewSecureBytes(C.GoBytes(unsafe.Pointer(password), C.int(passwordLen)))
+	defer secret.Destroy()
+
+	hash, code := hash(secret, uint32(iterations), uint32(saltLen), uint32(keyLen))
+	if code != codeOK {
+		return C.int(code)
+	}
+	if uint64(len(hash))+1 > uint64(outLen) {
+		return C.PBKDF2_ERR_BUFFER_TOO_SMALL
+	}
+
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(out)), len(hash)+1)
+	copy(buf, hash)
+	buf[len(hash)] = 0
+	return C.PBKDF2_OK
+}
+
+//export pbkdf2_verify
+func pbkdf2_verify(password *C.char, passwordLen C.size_t, hash *C.char) C.int {
+	if (password == nil && passwordLen != 0) || hash == nil || passwordLen > maxPasswordLen {
+		return C.PBKDF2_ERR_INVALID_ARGUMENT
+	}
+
+	secret := pbkdf2.NewSecureBytes(C.GoBytes(unsafe.Pointer(password), C.int(passwordLen)))
+	defer secret.Destroy()
+
+	return C.int(verify(secret, C.GoString(hash)))
+}
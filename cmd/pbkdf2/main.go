@@ -0,0 +1,74 @@
+// Command pbkdf2 provides administrative tools for stores of pbkdf2 hashes.
+//
+// Usage:
+//
+//	pbkdf2 <command> [flags]
+//
+// The commands are:
+//
+//	adduser    add a user to a credential file
+//	deluser    remove a user from a credential file
+//	list       list the users of a credential file
+//	passwd     change a user's password in a credential file
+//	repepper   move AES-GCM peppered hashes to a new pepper key
+//	serve      serve the hashing API over HTTPS
+//	vectors    write JSON test vectors for interoperability testing
+//
+// Run "pbkdf2 <command> -h" for the flags of each command.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// A command runs with the arguments following its name, and returns the
+// process exit code.
+type command struct {
+	summary string
+	run     func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+}
+
+var commands = map[string]command{
+	"adduser":  {"add a user to a credential file", runAddUser},
+	"deluser":  {"remove a user from a credential file", runDelUser},
+	"list":     {"list the users of a credential file", runList},
+	"passwd":   {"change a user's password in a credential file", runPasswd},
+	"repepper": {"move AES-GCM peppered hashes to a new pepper key", runRePepper},
+	"serve":    {"serve the hashing API over HTTPS", runServe},
+	"vectors":  {"write JSON test vectors for interoperability testing", runVectors},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "help" {
+		usage(stderr)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "pbkdf2: unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	return cmd.run(args[1:], stdin, stdout, stderr)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: pbkdf2 <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].summary)
+	}
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pganguli/pbkdf2"
+	"github.com/pganguli/pbkdf2/pepper"
+	"github.com/pganguli/pbkdf2/service"
+	"github.com/pganguli/pbkdf2/store"
+)
+
+func TestRun(t *testing.T) {
+	var stderr bytes.Buffer
+	if code := run(nil, nil, nil, &stderr); code != 2 || !strings.Contains(stderr.String(), "repepper") {
+		t.Fatalf("expected usage listing commands, got %d: %s", code, stderr.String())
+	}
+	stderr.Reset()
+	if code := run([]string{"frobnicate"}, nil, nil, &stderr); code != 2 || !strings.Contains(stderr.String(), "unknown command") {
+		t.Fatalf("expected unknown command error, got %d: %s", code, stderr.String())
+	}
+}
+
+func TestRePepper(t *testing.T) {
+	key1 := pepper.Key{ID: "k1", Secret: bytes.Repeat([]byte{1}, pepper.KeySize)}
+	key2 := pepper.Key{ID: "k2", Secret: bytes.Repeat([]byte{2}, pepper.KeySize)}
+
+	dir := t.TempDir()
+	keyringPath := filepath.Join(dir, "keyring")
+	keyringFile := "# pepper keys\n" +
+		"k1 " + base64.StdEncoding.EncodeToString(key1.Secret) + "\n\n" +
+		"k2 " + base64.StdEncoding.EncodeToString(key2.Secret) + "\n"
+	if err := os.WriteFile(keyringPath, []byte(keyringFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keyring, _ := pepper.NewKeyring("k1", key1, key2)
+	h := &pepper.Hasher{Keyring: keyring, Params: &pbkdf2.Params{Iterations: 1000, SaltLength: 16, KeyLength: 32}}
+	hash, err := h.CreateHash("pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	// Hashes with metadata contain commas, so must be quoted in CSV.
+	stdin := strings.NewReader("username,hash\nalice,\"" + hash + "\"\n")
+	args := []string{"repepper", "-keyring", keyringPath, "-key", "k2", "-format", "csv"}
+	if code := run(args, stdin, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "re-peppered 1") {
+		t.Errorf("unexpected summary %q", stderr.String())
+	}
+
+	records, err := csv.NewReader(&stdout).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1][0] != "alice" {
+		t.Fatalf("unexpected output %q", records)
+	}
+	newHash := records[1][1]
+	h.Keyring, _ = pepper.NewKeyring("k2", key2)
+	if err := h.Verify("pa$$word", newHash); err != nil {
+		t.Fatal(err)
+	}
+
+	stderr.Reset()
+	if code := run([]string{"repepper", "-keyring", keyringPath}, nil, nil, &stderr); code != 2 {
+		t.Fatalf("expected usage error without -key, got %d", code)
+	}
+}
+
+// writeSelfSigned writes a self-signed certificate and key to dir, returning
+// their paths.
+func writeSelfSigned(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestServe(t *testing.T) {
+	var stderr bytes.Buffer
+	if code := run([]string{"serve", "-tokens", "x"}, nil, nil, &stderr); code != 2 {
+		t.Fatalf("expected usage error without -tls-cert, got %d", code)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSigned(t, dir)
+	stderr.Reset()
+	if code := run([]string{"serve", "-tls-cert", certFile, "-tls-key", keyFile}, nil, nil, &stderr); code != 1 || !strings.Contains(stderr.String(), "no client authentication") {
+		t.Fatalf("expected error without client authentication, got %d: %s", code, stderr.String())
+	}
+
+	tokensPath := filepath.Join(dir, "tokens")
+	if err := os.WriteFile(tokensPath, []byte("# clients\nbilling s3cret-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keyringPath := filepath.Join(dir, "keyring")
+	if err := os.WriteFile(keyringPath, []byte("k1 "+base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, pepper.KeySize))+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("params:\n  iterations: 1000\n  salt_length: 16\n  key_length: 32\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	stderr.Reset()
+	srv, err := newServer(&serveOptions{
+		addr: ":0", certFile: certFile, keyFile: keyFile,
+		tokensFile: tokensPath, keyringPath: keyringPath, keyID: "k1", configPath: configPath,
+		rate: 1, burst: 1,
+	}, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stderr.String(), "warning: pbkdf2: pbkdf2-sha512 params are below") {
+		t.Errorf("no guidance warning in %q", stderr.String())
+	}
+	r := httptest.NewRequest(http.MethodPost, "/v1/hash", strings.NewReader(`{"password":"pa$$word"}`))
+	r.Header.Set("Authorization", "Bearer s3cret-token")
+	w := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, r)
+	var resp struct{ Hash string }
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	h, err := pbkdf2.ParseHash(resp.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Params.Iterations != 1000 || h.Metadata["kid"] != "k1" {
+		t.Errorf("unexpected hash %q", resp.Hash)
+	}
+}
+
+func TestServeUntil(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSigned(t, dir)
+	tokensPath := filepath.Join(dir, "tokens")
+	if err := os.WriteFile(tokensPath, []byte("billing s3cret-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	srv, err := newServer(&serveOptions{certFile: certFile, keyFile: keyFile, tokensFile: tokensPath, rate: 1, burst: 1}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	var stderr bytes.Buffer
+	go func() {
+		done <- serveUntil(ctx, srv, ln, time.Second, &stderr)
+	}()
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stderr.String(), "shutting down") {
+		t.Errorf("unexpected output %q", stderr.String())
+	}
+}
+
+func TestServeReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSigned(t, dir)
+	tokensPath := filepath.Join(dir, "tokens")
+	keyringPath := filepath.Join(dir, "keyring")
+	configPath := filepath.Join(dir, "config.yaml")
+	writeFile := func(path, data string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	secret := func(b byte) string {
+		return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, pepper.KeySize))
+	}
+	writeConfig := func(active, keyIDs string) {
+		writeFile(configPath, "params:\n  iterations: 1000\n  salt_length: 16\n  key_length: 32\n"+
+			"pepper:\n  active_key_id: "+active+"\n  key_ids: ["+keyIDs+"]\n")
+	}
+	writeFile(tokensPath, "billing s3cret-token\n")
+	writeFile(keyringPath, "k1 "+secret(1)+"\n")
+	writeConfig("k1", "k1")
+
+	opts := &serveOptions{
+		certFile: certFile, keyFile: keyFile, tokensFile: tokensPath,
+		keyringPath: keyringPath, configPath: configPath, rate: 100, burst: 100,
+	}
+	srv, err := newServer(opts, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := srv.Handler.(*service.Handler)
+	keyID := func() string {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodPost, "/v1/hash", strings.NewReader(`{"password":"pa$$word"}`))
+		r.Header.Set("Authorization", "Bearer s3cret-token")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		var resp struct{ Hash string }
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		parsed, err := pbkdf2.ParseHash(resp.Hash)
+		if err != nil {
+			t.Fatalf("status %d: %s", w.Code, w.Body.String())
+		}
+		return parsed.Metadata["kid"]
+	}
+	if id := keyID(); id != "k1" {
+		t.Fatalf("key ID %q, want k1", id)
+	}
+
+	writeFile(keyringPath, "k1 "+secret(1)+"\nk2 "+secret(2)+"\n")
+	writeConfig("k2", "k1, k2")
+	var stderr bytes.Buffer
+	reload(opts, h, nil, &stderr)
+	if id := keyID(); id != "k2" {
+		t.Errorf("after reload: key ID %q, want k2: %s", id, stderr.String())
+	}
+
+	// An invalid configuration leaves the previous one in effect.
+	writeConfig("k3", "k1, k2, k3")
+	stderr.Reset()
+	reload(opts, h, nil, &stderr)
+	if !strings.Contains(stderr.String(), "reload failed") {
+		t.Errorf("unexpected output %q", stderr.String())
+	}
+	if id := keyID(); id != "k2" {
+		t.Errorf("after failed reload: key ID %q, want k2", id)
+	}
+}
+
+func TestVectors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	args := []string{"vectors", "-variants", "pbkdf2-sha256", "-iterations", "1,2", "-lengths", "32"}
+	if code := run(args, nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("vectors exited %d: %s", code, stderr.String())
+	}
+	var vs []struct {
+		Password string `json:"password"`
+		Hash     string `json:"hash"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &vs); err != nil {
+		t.Fatal(err)
+	}
+	if len(vs) != 4*2*2 {
+		t.Fatalf("expected 16 vectors, got %d", len(vs))
+	}
+	for _, v := range vs {
+		if err := pbkdf2.Verify(v.Password, v.Hash); err != nil {
+			t.Fatalf("%s: %v", v.Hash, err)
+		}
+	}
+
+	out := filepath.Join(t.TempDir(), "vectors.json")
+	stdout.Reset()
+	if code := run([]string{"vectors", "-iterations", "1", "-o", out}, nil, &stdout, &stderr); code != 0 || stdout.Len() != 0 {
+		t.Fatalf("vectors -o exited %d: %s", code, stderr.String())
+	}
+	if _, err := os.Stat(out); err != nil {
+		t.Fatal(err)
+	}
+
+	stderr.Reset()
+	if code := run([]string{"vectors", "-iterations", "0"}, nil, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "-iterations") {
+		t.Fatalf("expected invalid iterations error, got %d: %s", code, stderr.String())
+	}
+}
+
+func TestUserCommands(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "passwd")
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("params:\n  iterations: 1000\n  salt_length: 16\n  key_length: 32\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	runUsers := func(stdin string, args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	if code, _, stderr := runUsers("s3cret\n", "adduser", "-file", path, "-config", configPath, "alice"); code != 0 {
+		t.Fatalf("adduser: exit code %d: %s", code, stderr)
+	}
+	if code, _, stderr := runUsers("s3cret\n", "adduser", "-file", path, "alice"); code != 1 || !strings.Contains(stderr, "already exists") {
+		t.Errorf("adduser of an existing user: exit code %d: %s", code, stderr)
+	}
+	if code, _, stderr := runUsers("hunter2", "adduser", "-file", path, "-config", configPath, "bob"); code != 0 {
+		t.Fatalf("adduser without a newline: exit code %d: %s", code, stderr)
+	}
+	if code, _, _ := runUsers("", "adduser", "-file", path, "carol"); code != 1 {
+		t.Errorf("adduser with an empty password: exit code %d", code)
+	}
+
+	s := store.NewFileStore(path)
+	hash, err := s.Get(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match, _, err := pbkdf2.CheckHash("s3cret", hash); !match || err != nil {
+		t.Errorf("alice's hash does not match: %v", err)
+	}
+
+	if code, _, stderr := runUsers("n3w\n", "passwd", "-file", path, "alice"); code != 0 {
+		t.Fatalf("passwd: exit code %d: %s", code, stderr)
+	}
+	hash, _ = s.Get(context.Background(), "alice")
+	if match, _, _ := pbkdf2.CheckHash("n3w", hash); !match {
+		t.Error("passwd did not change the password")
+	}
+	if code, _, _ := runUsers("x\n", "passwd", "-file", path, "nobody"); code != 1 {
+		t.Errorf("passwd of a missing user: exit code %d", code)
+	}
+
+	code, stdout, stderr := runUsers("", "list", "-file", path, "-config", configPath)
+	if code != 0 {
+		t.Fatalf("list: exit code %d: %s", code, stderr)
+	}
+	want := "alice\tpbkdf2-sha512\t" + strconv.Itoa(int(pbkdf2.GetDefaultParams().Iterations)) + "\trehash\n" +
+		"bob\tpbkdf2-sha512\t1000\n"
+	if stdout != want {
+		t.Errorf("list = %q, want %q", stdout, want)
+	}
+
+	if code, _, stderr := runUsers("", "deluser", "-file", path, "bob"); code != 0 {
+		t.Fatalf("deluser: exit code %d: %s", code, stderr)
+	}
+	if code, _, _ := runUsers("", "deluser", "-file", path, "bob"); code != 1 {
+		t.Errorf("deluser of a missing user: exit code %d", code)
+	}
+	if code, _, _ := runUsers("", "list"); code != 2 {
+		t.Errorf("list without -file: exit code %d", code)
+	}
+}
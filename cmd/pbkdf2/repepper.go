@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pganguli/pbkdf2/pepper"
+	"github.com/pganguli/pbkdf2/store"
+)
+
+const rePepperUsage = `usage: pbkdf2 repepper -keyring FILE -key ID [-format jsonl|csv] < in > out
+
+Reads (username, hash) records, moves every AES-GCM peppered hash to the key
+ID from the keyring file, and writes all records out in the same format.
+HMAC-peppered hashes cannot be moved without the password and are written
+unchanged; their number is reported.
+
+The keyring file holds one key per line, as an ID and the base64-encoded
+32-byte secret separated by whitespace. Blank lines and lines starting with
+# are ignored. It must contain every key in use as well as the new key.
+
+flags:
+`
+
+func runRePepper(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("repepper", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, rePepperUsage)
+		fs.PrintDefaults()
+	}
+	keyringPath := fs.String("keyring", "", "path to the keyring `file`")
+	keyID := fs.String("key", "", "`ID` of the key to move hashes to")
+	formatName := fs.String("format", "jsonl", "record format: jsonl or csv")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *keyringPath == "" || *keyID == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	fail := func(err error) int {
+		fmt.Fprintln(stderr, "pbkdf2 repepper:", err)
+		return 1
+	}
+
+	format, err := parseFormat(*formatName)
+	if err != nil {
+		return fail(err)
+	}
+	keys, err := readKeyring(*keyringPath)
+	if err != nil {
+		return fail(err)
+	}
+	keyring, err := pepper.NewKeyring(*keyID, keys...)
+	if err != nil {
+		return fail(err)
+	}
+	var newKey pepper.Key
+	for _, k := range keys {
+		if k.ID == *keyID {
+			newKey = k
+		}
+	}
+
+	ctx := context.Background()
+	s := &store.MemoryStore{}
+	if _, err := store.Import(ctx, s, stdin, store.ImportOptions{Format: format}); err != nil {
+		return fail(err)
+	}
+	result, err := pepper.RePepperStore(ctx, s, keyring, newKey)
+	if err != nil {
+		return fail(err)
+	}
+	if _, err := store.Export(ctx, s, stdout, format, 0, nil); err != nil {
+		return fail(err)
+	}
+
+	fmt.Fprintf(stderr, "re-peppered %d, already current %d, HMAC (unchanged) %d\n", result.RePeppered, result.Current, result.Irreversible)
+	return 0
+}
+
+func parseFormat(name string) (store.Format, error) {
+	switch name {
+	case "jsonl":
+		return store.JSONL, nil
+	case "csv":
+		return store.CSV, nil
+	}
+	return 0, fmt.Errorf("unknown format %q", name)
+}
+
+func readKeyring(path string) ([]pepper.Key, error) {
+	var keys []pepper.Key
+	err := readPairs(path, func(line int, id, value string) error {
+		secret, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return fmt.Errorf("%s:%d: invalid secret: %v", path, line, err)
+		}
+		keys = append(keys, pepper.Key{ID: id, Secret: secret})
+		return nil
+	})
+	return keys, err
+}
+
+// readPairs calls fn with the line number and two fields of each line of the
+// file at path, an ID and a value separated by whitespace. Blank lines and
+// lines starting with # are ignored.
+func readPairs(path string, fn func(line int, id, value string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return fmt.Errorf("%s:%d: expected an ID and a value", path, line)
+		}
+		if err := fn(line, fields[0], fields[1]); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pganguli/pbkdf2"
+	"github.com/pganguli/pbkdf2/config"
+	"github.com/pganguli/pbkdf2/pepper"
+	"github.com/pganguli/pbkdf2/ratelimit"
+	"github.com/pganguli/pbkdf2/service"
+)
+
+const serveUsage = `usage: pbkdf2 serve -tls-cert FILE -tls-key FILE [-client-ca FILE] [-tokens FILE] [flags]
+
+Serves the hashing API of the service package over HTTPS. Clients must
+authenticate, with a bearer token from the tokens file or, if -client-ca is
+given, with a client certificate signed by one of its CAs; the certificate's
+common name identifies the client.
+
+The tokens file holds one client per line, as an ID and a bearer token
+separated by whitespace. Blank lines and lines starting with # are ignored.
+
+With -keyring, new hashes are peppered with the key given by -key, or by
+pepper.active_key_id in the -config file; the keyring file has the format
+described by "pbkdf2 repepper -h".
+
+At startup and on each reload, a warning is printed if the hashing params
+fall below the current guidance; see pbkdf2.IsBelowCurrentGuidance.
+
+On SIGHUP, the configuration and keyring files are read again, and their
+params, policy and pepper keys apply to subsequent requests; connections are
+not dropped, and if the files are invalid the previous settings remain. With
+-watch, the configuration file is also polled for changes.
+
+On SIGINT or SIGTERM, the server stops accepting connections and waits up to
+-shutdown-timeout for requests in flight to complete. It exits with status 1
+if any were abandoned.
+
+flags:
+`
+
+type serveOptions struct {
+	addr, certFile, keyFile, clientCAFile string
+	tokensFile, keyringPath, keyID        string
+	configPath                            string
+	rate                                  float64
+	burst                                 int
+	shutdownTimeout, watchInterval        time.Duration
+}
+
+func runServe(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, serveUsage)
+		fs.PrintDefaults()
+	}
+	var opts serveOptions
+	fs.StringVar(&opts.addr, "addr", ":8443", "`address` to listen on")
+	fs.StringVar(&opts.certFile, "tls-cert", "", "PEM `file` holding the server certificate")
+	fs.StringVar(&opts.keyFile, "tls-key", "", "PEM `file` holding the server key")
+	fs.StringVar(&opts.clientCAFile, "client-ca", "", "PEM `file` of CAs for client certificates; enables mutual TLS")
+	fs.StringVar(&opts.tokensFile, "tokens", "", "path to the client tokens `file`")
+	fs.StringVar(&opts.keyringPath, "keyring", "", "path to the pepper keyring `file`")
+	fs.StringVar(&opts.keyID, "key", "", "`ID` of the pepper key for new hashes")
+	fs.StringVar(&opts.configPath, "config", "", "YAML or TOML hashing configuration `file`")
+	fs.Float64Var(&opts.rate, "rate", 10, "requests per second allowed per client")
+	fs.IntVar(&opts.burst, "burst", 20, "requests allowed per client at once")
+	fs.DurationVar(&opts.watchInterval, "watch", 0, "poll the -config file for changes at this `interval`")
+	fs.DurationVar(&opts.shutdownTimeout, "shutdown-timeout", 30*time.Second, "how long to wait for requests in flight on shutdown")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if opts.certFile == "" || opts.keyFile == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	srv, err := newServer(&opts, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, "pbkdf2 serve:", err)
+		return 1
+	}
+	ln, err := net.Listen("tcp", opts.addr)
+	if err != nil {
+		fmt.Fprintln(stderr, "pbkdf2 serve:", err)
+		return 1
+	}
+	fmt.Fprintln(stderr, "pbkdf2 serve: listening on", ln.Addr())
+
+	handler := srv.Handler.(*service.Handler)
+	stopHangup := onHangup(func() { reload(&opts, handler, nil, stderr) })
+	defer stopHangup()
+	if opts.watchInterval > 0 && opts.configPath != "" {
+		w, err := config.Watch(opts.configPath, config.WatchOptions{
+			Interval: opts.watchInterval,
+			OnReload: func(cfg *config.Config) { reload(&opts, handler, cfg, stderr) },
+			OnError: func(err error) {
+				fmt.Fprintln(stderr, "pbkdf2 serve: reload failed:", err)
+			},
+		})
+		if err != nil {
+			fmt.Fprintln(stderr, "pbkdf2 serve:", err)
+			return 1
+		}
+		defer w.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := serveUntil(ctx, srv, ln, opts.shutdownTimeout, stderr); err != nil {
+		fmt.Fprintln(stderr, "pbkdf2 serve:", err)
+		return 1
+	}
+	return 0
+}
+
+// serveUntil serves on ln until ctx ends, then shuts the server down
+// gracefully, allowing requests in flight up to timeout to complete. It
+// returns an error if serving failed or requests were abandoned.
+func serveUntil(ctx context.Context, srv *http.Server, ln net.Listener, timeout time.Duration, stderr io.Writer) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ServeTLS(ln, "", "")
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	fmt.Fprintln(stderr, "pbkdf2 serve: shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	go srv.Shutdown(shutdownCtx)
+	abandoned, err := srv.Handler.(*service.Handler).Shutdown(shutdownCtx)
+	if err != nil {
+		return fmt.Errorf("abandoned %d requests in flight: %v", abandoned, err)
+	}
+	if err := <-errc; err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func newServer(opts *serveOptions, stderr io.Writer) (*http.Server, error) {
+	if opts.tokensFile == "" && opts.clientCAFile == "" {
+		return nil, errors.New("no client authentication: give -tokens, -client-ca or both")
+	}
+	if opts.rate <= 0 || opts.burst < 1 {
+		return nil, errors.New("-rate must be positive and -burst at least 1")
+	}
+
+	tlsConfig, err := service.TLSConfig(opts.certFile, opts.keyFile, opts.clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := loadConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	hasher, err := newServiceHasher(opts, cfg)
+	if err != nil {
+		return nil, err
+	}
+	warnBelowGuidance(cfg, stderr)
+	tokens := map[string]string{}
+	if opts.tokensFile != "" {
+		err := readPairs(opts.tokensFile, func(line int, id, token string) error {
+			if _, ok := tokens[id]; ok {
+				return fmt.Errorf("%s:%d: duplicate client %q", opts.tokensFile, line, id)
+			}
+			tokens[id] = token
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &http.Server{
+		Addr: opts.addr,
+		Handler: &service.Handler{
+			Hasher:          hasher,
+			Tokens:          tokens,
+			CertificateAuth: opts.clientCAFile != "",
+			Limiter:         ratelimit.NewLimiter(opts.rate, opts.burst),
+		},
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+	}, nil
+}
+
+// reload replaces the hasher of h with one built from cfg, or from the
+// configuration file if cfg is nil, and the keyring file. On error, it keeps
+// the current hasher.
+func reload(opts *serveOptions, h *service.Handler, cfg *config.Config, stderr io.Writer) {
+	var err error
+	if cfg == nil {
+		cfg, err = loadConfig(opts)
+	}
+	var hasher service.Hasher
+	if err == nil {
+		hasher, err = newServiceHasher(opts, cfg)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "pbkdf2 serve: reload failed:", err)
+		return
+	}
+	h.SetHasher(hasher)
+	fmt.Fprintln(stderr, "pbkdf2 serve: reloaded configuration")
+	warnBelowGuidance(cfg, stderr)
+}
+
+// warnBelowGuidance prints a warning if the params of cfg fall below the
+// current guidance. cfg must be valid.
+func warnBelowGuidance(cfg *config.Config, stderr io.Writer) {
+	params, err := cfg.HashParams()
+	if err != nil {
+		return
+	}
+	if below, report := pbkdf2.IsBelowCurrentGuidance(params); below {
+		fmt.Fprintln(stderr, "pbkdf2 serve: warning:", report)
+	}
+}
+
+func loadConfig(opts *serveOptions) (*config.Config, error) {
+	if opts.configPath == "" {
+		return &config.Config{}, nil
+	}
+	return config.Load(opts.configPath)
+}
+
+func newServiceHasher(opts *serveOptions, cfg *config.Config) (service.Hasher, error) {
+	params, err := cfg.HashParams()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.keyringPath == "" {
+		return &pbkdf2.Hasher{Params: params, Policy: cfg.Policy}, nil
+	}
+	keyID := opts.keyID
+	if keyID == "" {
+		keyID = cfg.Pepper.ActiveKeyID
+	}
+	keys, err := readKeyring(opts.keyringPath)
+	if err != nil {
+		return nil, err
+	}
+	keyring, err := pepper.NewKeyring(keyID, keys...)
+	if err != nil {
+		return nil, err
+	}
+	return &pepper.Hasher{Keyring: keyring, Params: params, Policy: cfg.Policy}, nil
+}
@@ -0,0 +1,21 @@
+//go:build !js && !wasip1
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// onHangup calls f on each SIGHUP until the returned function is called.
+func onHangup(f func()) (stop func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			f()
+		}
+	}()
+	return func() { signal.Stop(hup) }
+}
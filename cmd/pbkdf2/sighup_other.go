@@ -0,0 +1,9 @@
+//go:build js || wasip1
+
+package main
+
+// onHangup does nothing, as there is no SIGHUP on this platform; the
+// configuration can still be reloaded with -watch.
+func onHangup(f func()) (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/pganguli/pbkdf2"
+	"github.com/pganguli/pbkdf2/config"
+	"github.com/pganguli/pbkdf2/store"
+)
+
+const usersUsage = `usage: pbkdf2 adduser -file FILE [-config FILE] USER
+       pbkdf2 passwd -file FILE [-config FILE] USER
+       pbkdf2 deluser -file FILE USER
+       pbkdf2 list -file FILE [-config FILE]
+
+Manages the users of an htpasswd-style credential file, one user per line as
+a username and a pbkdf2 hash separated by a colon. The file is created by the
+first adduser, with mode 0600, and locked while it is changed, so the
+commands can run alongside each other and alongside services reading it.
+
+adduser and passwd prompt for the password twice, without echoing it, if
+standard input is a terminal, and otherwise read it from the first line of
+standard input. New hashes use the params of the -config file, or the
+defaults. list prints each user with the variant and iteration count of
+their hash, marking with "rehash" those that fall short of those params.
+
+flags:
+`
+
+// userCommand returns the run function of a user-management command, which
+// parses the common flags and calls fn with the store and the remaining
+// arguments.
+func userCommand(name string, nargs int, fn func(ctx context.Context, s *store.FileStore, h *pbkdf2.Hasher, args []string, stdin io.Reader, stdout, stderr io.Writer) error) func([]string, io.Reader, io.Writer, io.Writer) int {
+	return func(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		fs.Usage = func() {
+			fmt.Fprint(stderr, usersUsage)
+			fs.PrintDefaults()
+		}
+		path := fs.String("file", "", "path to the credential `file`")
+		configPath := fs.String("config", "", "YAML or TOML hashing configuration `file`")
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if *path == "" || fs.NArg() != nargs {
+			fs.Usage()
+			return 2
+		}
+
+		h := &pbkdf2.Hasher{}
+		if *configPath != "" {
+			cfg, err := config.Load(*configPath)
+			if err == nil {
+				h.Policy = cfg.Policy
+				h.Params, err = cfg.HashParams()
+			}
+			if err != nil {
+				fmt.Fprintf(stderr, "pbkdf2 %s: %v\n", name, err)
+				return 1
+			}
+		}
+		if err := fn(context.Background(), store.NewFileStore(*path), h, fs.Args(), stdin, stdout, stderr); err != nil {
+			fmt.Fprintf(stderr, "pbkdf2 %s: %v\n", name, err)
+			return 1
+		}
+		return 0
+	}
+}
+
+var (
+	runAddUser = userCommand("adduser", 1, addUser)
+	runPasswd  = userCommand("passwd", 1, changePassword)
+	runDelUser = userCommand("deluser", 1, delUser)
+	runList    = userCommand("list", 0, listUsers)
+)
+
+func addUser(ctx context.Context, s *store.FileStore, h *pbkdf2.Hasher, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	username := args[0]
+	if _, err := s.Get(ctx, username); err == nil {
+		return fmt.Errorf("user %q already exists", username)
+	}
+	hash, err := newPasswordHash(h, stdin, stderr)
+	if err != nil {
+		return err
+	}
+	err = s.Create(ctx, username, hash)
+	if errors.Is(err, store.ErrExists) {
+		return fmt.Errorf("user %q already exists", username)
+	}
+	return err
+}
+
+func changePassword(ctx context.Context, s *store.FileStore, h *pbkdf2.Hasher, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	username := args[0]
+	if _, err := s.Get(ctx, username); errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("no user %q", username)
+	} else if err != nil {
+		return err
+	}
+	hash, err := newPasswordHash(h, stdin, stderr)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, username, hash)
+}
+
+func delUser(ctx context.Context, s *store.FileStore, h *pbkdf2.Hasher, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	err := s.Delete(ctx, args[0])
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("no user %q", args[0])
+	}
+	return err
+}
+
+func listUsers(ctx context.Context, s *store.FileStore, h *pbkdf2.Hasher, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return s.Range(ctx, func(username, hash string) error {
+		params, _, _, err := pbkdf2.DecodeHash(hash)
+		if err != nil {
+			fmt.Fprintf(stdout, "%s\tunrecognized\n", username)
+			return nil
+		}
+		variant := params.Variant
+		if variant == "" {
+			variant = pbkdf2.VariantSHA512
+		}
+		line := fmt.Sprintf("%s\t%s\t%d", username, variant, params.Iterations)
+		if h.NeedsRehash(hash) {
+			line += "\trehash"
+		}
+		fmt.Fprintln(stdout, line)
+		return nil
+	})
+}
+
+// newPasswordHash reads a new password and hashes it with h.
+func newPasswordHash(h *pbkdf2.Hasher, stdin io.Reader, stderr io.Writer) (string, error) {
+	password, err := readNewPassword(stdin, stderr)
+	if err != nil {
+		return "", err
+	}
+	return h.CreateHash(password)
+}
+
+// readNewPassword prompts for a password twice on a terminal, without echo,
+// or reads it from the first line of stdin otherwise.
+func readNewPassword(stdin io.Reader, stderr io.Writer) (string, error) {
+	var password string
+	if f, ok := stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		read := func(prompt string) (string, error) {
+			fmt.Fprint(stderr, prompt)
+			b, err := term.ReadPassword(int(f.Fd()))
+			fmt.Fprintln(stderr)
+			return string(b), err
+		}
+		var err error
+		if password, err = read("New password: "); err != nil {
+			return "", err
+		}
+		again, err := read("Retype new password: ")
+		if err != nil {
+			return "", err
+		}
+		if again != password {
+			return "", errors.New("passwords do not match")
+		}
+	} else {
+		line, err := bufio.NewReader(stdin).ReadString('\n')
+		if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+			return "", fmt.Errorf("reading password: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	if password == "" {
+		return "", errors.New("empty password")
+	}
+	return password, nil
+}
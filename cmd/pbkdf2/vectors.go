@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pganguli/pbkdf2/vectors"
+)
+
+const vectorsUsage = `usage: pbkdf2 vectors [-variants LIST] [-iterations LIST] [-lengths LIST] [-o FILE]
+
+Writes a JSON array of test vectors: the inputs and derived keys of PBKDF2
+across variants, iteration counts and key lengths, with the encoded hash and
+its equivalent alternative encodings, for checking implementations in other
+languages against this one. The output is the same on every run.
+
+LIST is comma-separated. By default every registered variant is included.
+
+flags:
+`
+
+func runVectors(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("vectors", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, vectorsUsage)
+		fs.PrintDefaults()
+	}
+	variants := fs.String("variants", "", "comma-separated `variants` to include")
+	iterations := fs.String("iterations", "1,2,1000", "comma-separated iteration `counts`")
+	lengths := fs.String("lengths", "16,64,100", "comma-separated key `lengths` in bytes")
+	out := fs.String("o", "", "write to `file` instead of standard output")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	fail := func(err error) int {
+		fmt.Fprintln(stderr, "pbkdf2 vectors:", err)
+		return 1
+	}
+
+	opts := &vectors.Options{}
+	if *variants != "" {
+		opts.Variants = strings.Split(*variants, ",")
+	}
+	var err error
+	if opts.Iterations, err = parseUintList("iterations", *iterations); err != nil {
+		return fail(err)
+	}
+	if opts.KeyLengths, err = parseUintList("lengths", *lengths); err != nil {
+		return fail(err)
+	}
+	vs, err := vectors.Generate(opts)
+	if err != nil {
+		return fail(err)
+	}
+
+	var buf bytes.Buffer
+	if err := vectors.WriteJSON(&buf, vs); err != nil {
+		return fail(err)
+	}
+	if *out != "" {
+		err = os.WriteFile(*out, buf.Bytes(), 0o644)
+	} else {
+		_, err = stdout.Write(buf.Bytes())
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+// parseUintList parses a comma-separated list of positive integers given for
+// the named flag.
+func parseUintList(name, s string) ([]uint32, error) {
+	var ns []uint32
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.ParseUint(strings.TrimSpace(f), 10, 32)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("invalid -%s value %q", name, f)
+		}
+		ns = append(ns, uint32(n))
+	}
+	return ns, nil
+}
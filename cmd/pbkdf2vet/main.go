@@ -0,0 +1,269 @@
+// Command pbkdf2vet is a vet tool reporting weak password hashing parameters
+// in code using the pbkdf2 module, as described in package paramcheck:
+//
+//	go install github.com/pganguli/pbkdf2/cmd/pbkdf2vet@latest
+//	go vet -vettool=$(which pbkdf2vet) ./...
+//
+// It reports pbkdf2.Params literals and assignments with constant iterations,
+// salt length or key length below the current guidance, similarly weak
+// arguments to functions such as mobile.CreateHash, and uses of
+// pbkdf2.DefaultParams outside tests. A "//pbkdf2vet:ignore" comment on the
+// line of a diagnostic, or the line above, suppresses it.
+//
+// go vet runs pbkdf2vet once for each package, with the path of a JSON file
+// describing the package and the export data of its dependencies, as it does
+// for tools built with golang.org/x/tools/go/analysis/unitchecker; pbkdf2vet
+// implements that protocol with the standard library alone. Diagnostics are
+// printed to standard error, and make the exit status 1.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pganguli/pbkdf2/paramcheck"
+)
+
+// config is the package description written by go vet, a subset of the
+// fields known to unitchecker.
+type config struct {
+	ID                        string
+	Compiler                  string
+	Dir                       string
+	ImportPath                string
+	GoFiles                   []string
+	ImportMap                 map[string]string
+	PackageFile               map[string]string
+	VetxOnly                  bool
+	VetxOutput                string
+	Stdout                    string
+	SucceedOnTypecheckFailure bool
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet(paramcheck.Name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	version := fs.String("V", "", "print version and exit (go vet passes -V=full)")
+	describe := fs.Bool("flags", false, "print flags as JSON and exit, for go vet")
+	jsonOut := fs.Bool("json", false, "print diagnostics as JSON to standard output")
+	// pbkdf2vet suggests no fixes, so the fix flags of go vet and go fix
+	// are accepted and have no effect.
+	fs.Bool("fix", false, "apply suggested fixes (none are suggested)")
+	fs.Bool("diff", false, "with -fix, print fixes as diffs instead of applying them")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "%s: %s\n\nUsage: go vet -vettool=$(which %s) [packages]\n", paramcheck.Name, paramcheck.Doc, paramcheck.Name)
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	switch {
+	case *version != "":
+		return printVersion(stdout, stderr)
+	case *describe:
+		// There are no analyzer flags to offer go vet.
+		fmt.Fprintln(stdout, "[]")
+		return 0
+	case fs.NArg() != 1 || filepath.Ext(fs.Arg(0)) != ".cfg":
+		fs.Usage()
+		return 2
+	}
+
+	cfg, err := readConfig(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", paramcheck.Name, err)
+		return 1
+	}
+	diags, fset, err := check(cfg)
+	if err != nil && cfg.SucceedOnTypecheckFailure {
+		return 0
+	}
+	if *jsonOut {
+		if cfg.Stdout != "" {
+			// Newer versions of go vet read the output of the tool
+			// from this file rather than from its standard output.
+			f, err := os.Create(cfg.Stdout)
+			if err != nil {
+				fmt.Fprintf(stderr, "%s: %v\n", paramcheck.Name, err)
+				return 1
+			}
+			defer f.Close()
+			stdout = f
+		}
+		return printJSON(stdout, stderr, cfg.ID, fset, diags, err)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", paramcheck.Name, err)
+		return 1
+	}
+	for _, d := range diags {
+		fmt.Fprintf(stderr, "%s: %s\n", fset.Position(d.Pos), d.Message)
+	}
+	if len(diags) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// jsonDiagnostic is a diagnostic in the JSON form of unitchecker.
+type jsonDiagnostic struct {
+	Posn    string `json:"posn"`
+	Message string `json:"message"`
+}
+
+// printJSON prints the diagnostics, or the error, of the package with the
+// given ID as unitchecker does with -json: a map from package ID to checker
+// name to either a list of diagnostics or an object with an error. go vet
+// passes -json and reports what it reads, so the exit status is 0 unless the
+// output cannot be written.
+func printJSON(stdout, stderr io.Writer, id string, fset *token.FileSet, diags []paramcheck.Diagnostic, checkErr error) int {
+	var result interface{}
+	if checkErr != nil {
+		result = struct {
+			Err string `json:"error"`
+		}{checkErr.Error()}
+	} else if len(diags) > 0 {
+		list := make([]jsonDiagnostic, len(diags))
+		for i, d := range diags {
+			list[i] = jsonDiagnostic{Posn: fset.Position(d.Pos).String(), Message: d.Message}
+		}
+		result = list
+	} else {
+		// Like unitchecker, print nothing for a clean package.
+		return 0
+	}
+	tree := map[string]map[string]interface{}{id: {paramcheck.Name: result}}
+	data, err := json.MarshalIndent(tree, "", "\t")
+	if err == nil {
+		_, err = fmt.Fprintf(stdout, "%s\n", data)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", paramcheck.Name, err)
+		return 1
+	}
+	return 0
+}
+
+// printVersion prints the version line go vet asks for with -V=full, with a
+// build ID that changes with the executable, so that go vet's cache of
+// results is invalidated when pbkdf2vet is rebuilt.
+func printVersion(stdout, stderr io.Writer) int {
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", paramcheck.Name, err)
+		return 1
+	}
+	f, err := os.Open(exe)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", paramcheck.Name, err)
+		return 1
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", paramcheck.Name, err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "%s version devel buildID=%x\n", paramcheck.Name, h.Sum(nil))
+	return 0
+}
+
+func readConfig(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := new(config)
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("cannot decode %s: %v", path, err)
+	}
+	if len(cfg.GoFiles) == 0 {
+		return nil, fmt.Errorf("package %s has no Go files", cfg.ImportPath)
+	}
+	return cfg, nil
+}
+
+// check type-checks the package described by cfg and runs paramcheck on it.
+// pbkdf2vet records no facts, but go vet expects the facts file to be
+// written, empty, for the packages that depend on this one.
+func check(cfg *config) ([]paramcheck.Diagnostic, *token.FileSet, error) {
+	if cfg.VetxOutput != "" {
+		if err := os.WriteFile(cfg.VetxOutput, nil, 0o666); err != nil {
+			return nil, nil, err
+		}
+	}
+	fset := token.NewFileSet()
+	if cfg.VetxOnly {
+		return nil, fset, nil
+	}
+
+	var files []*ast.File
+	for _, name := range cfg.GoFiles {
+		f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, f)
+	}
+
+	compiler := cfg.Compiler
+	if compiler == "" {
+		compiler = "gc"
+	}
+	exports := importer.ForCompiler(fset, compiler, func(path string) (io.ReadCloser, error) {
+		file, ok := cfg.PackageFile[path]
+		if !ok {
+			return nil, errors.New("no export data for " + path)
+		}
+		return os.Open(file)
+	})
+	tc := &types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			if path == "unsafe" {
+				return types.Unsafe, nil
+			}
+			if mapped, ok := cfg.ImportMap[path]; ok {
+				path = mapped
+			}
+			return exports.Import(path)
+		}),
+		Sizes: types.SizesFor(compiler, build.Default.GOARCH),
+	}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	pkg, err := tc.Check(cfg.ImportPath, fset, files, info)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var diags []paramcheck.Diagnostic
+	paramcheck.Run(&paramcheck.Pass{Fset: fset, Files: files, Pkg: pkg, TypesInfo: info, Report: func(d paramcheck.Diagnostic) {
+		diags = append(diags, d)
+	}})
+	return diags, fset, nil
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/pganguli/pbkdf2/paramcheck"
+)
+
+func TestRun(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-flags"}, &stdout, &stderr); code != 0 || strings.TrimSpace(stdout.String()) != "[]" {
+		t.Fatalf("expected no analyzer flags, got %d: %q", code, stdout.String())
+	}
+	stdout.Reset()
+	if code := run(nil, &stdout, &stderr); code != 2 || !strings.Contains(stderr.String(), "go vet -vettool") {
+		t.Fatalf("expected usage, got %d: %s", code, stderr.String())
+	}
+	stderr.Reset()
+	if code := run([]string{"-V=full"}, &stdout, &stderr); code != 0 || !strings.HasPrefix(stdout.String(), paramcheck.Name+" version devel buildID=") {
+		t.Fatalf("expected version line, got %d: %q %s", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestPrintJSON(t *testing.T) {
+	fset := token.NewFileSet()
+	f := fset.AddFile("weak.go", -1, 100)
+	f.SetLines([]int{0, 50})
+	diags := []paramcheck.Diagnostic{{Pos: f.Pos(52), Message: "weak pbkdf2.Params: key length 8 is below the recommended 16"}}
+
+	var stdout, stderr bytes.Buffer
+	if code := printJSON(&stdout, &stderr, "example.com/weak", fset, diags, nil); code != 0 {
+		t.Fatalf("printJSON: %d: %s", code, stderr.String())
+	}
+	var tree map[string]map[string][]jsonDiagnostic
+	if err := json.Unmarshal(stdout.Bytes(), &tree); err != nil {
+		t.Fatal(err)
+	}
+	got := tree["example.com/weak"][paramcheck.Name]
+	if len(got) != 1 || got[0].Posn != "weak.go:2:3" || got[0].Message != diags[0].Message {
+		t.Fatalf("unexpected diagnostics: %+v", tree)
+	}
+
+	stdout.Reset()
+	if code := printJSON(&stdout, &stderr, "example.com/weak", fset, nil, nil); code != 0 || stdout.Len() != 0 {
+		t.Fatalf("expected no output for a clean package, got %d: %q", code, stdout.String())
+	}
+
+	if code := printJSON(&stdout, &stderr, "example.com/weak", fset, nil, errors.New("undefined: x")); code != 0 {
+		t.Fatalf("printJSON: %d: %s", code, stderr.String())
+	}
+	var errTree map[string]map[string]struct {
+		Err string `json:"error"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &errTree); err != nil {
+		t.Fatal(err)
+	}
+	if got := errTree["example.com/weak"][paramcheck.Name].Err; got != "undefined: x" {
+		t.Fatalf("expected the type-checking error, got %q", got)
+	}
+}
@@ -0,0 +1,146 @@
+package pbkdf2
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Names of the built-in hash formats reported by Codecs.
+const (
+	// FormatPHC is the format produced by CreateHash, in the style of the
+	// PHC string format:
+	//
+	//	$<variant>[$<metadata>]$<iterations>$<salt>$<key>
+	//
+	// It is used for any hash that matches no registered codec.
+	FormatPHC = "phc"
+
+	// FormatLegacySHA1 is the $pbkdf2$ format described by VariantLegacySHA1,
+	// which is only decoded where SHA-1 hashes are allowed.
+	FormatLegacySHA1 = "legacy-sha1"
+)
+
+// A codec decodes hashes starting with prefix.
+type codec struct {
+	name, prefix string
+	decode       func(hash string) (*Hash, error)
+
+	// legacy is set for FormatLegacySHA1, which DecodeHash and the other
+	// package-level functions reject with ErrIncompatibleVariant.
+	legacy bool
+}
+
+var (
+	codecsMu sync.RWMutex
+	// codecs is sorted by prefix, longest first, so that the most specific
+	// prefix wins.
+	codecs = []codec{
+		{name: FormatLegacySHA1, prefix: legacySHA1Prefix, decode: decodeLegacySHA1, legacy: true},
+	}
+)
+
+// RegisterCodec makes a hash format available under name, so that hashes
+// starting with prefix are decoded with decode wherever this package decodes
+// hashes: by DecodeHash, ParseHash and the functions and Hasher methods that
+// verify hashes. Foreign formats of PBKDF2 hashes, such as Django's
+//
+//	pbkdf2_sha256$600000$<salt>$<key>
+//
+// can thus be verified directly, as long as their PRF is a supported variant.
+// decode must return a Hash whose Params agree with its Salt and Key, and
+// ErrInvalidHash, or an error wrapping it, for malformed input. Hashes it
+// returns that would not pass Hash.MarshalText are rejected with
+// ErrInvalidHash.
+//
+// When several prefixes match a hash, the longest is used. Hashes matching
+// none are decoded as FormatPHC, so prefixes must not start with "$" followed
+// by a variant name and "$". RegisterCodec is intended to be called from init
+// functions. It panics if name or prefix is empty or already registered, if
+// prefix collides with FormatPHC, or if decode is nil.
+func RegisterCodec(name, prefix string, decode func(hash string) (*Hash, error)) {
+	if name == "" || name == FormatPHC || prefix == "" || decode == nil {
+		panic("pbkdf2: RegisterCodec called with an empty name or prefix or nil decode")
+	}
+	if variant, _, ok := strings.Cut(strings.TrimPrefix(prefix, "$"), "$"); ok && prefix[0] == '$' {
+		if _, ok := variantPRF(variant); ok {
+			panic("pbkdf2: RegisterCodec prefix " + strconv.Quote(prefix) + " collides with variant " + variant)
+		}
+	}
+
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	for _, c := range codecs {
+		if c.name == name || c.prefix == prefix {
+			panic("pbkdf2: RegisterCodec called twice for " + strconv.Quote(name) + " or " + strconv.Quote(prefix))
+		}
+	}
+	codecs = append(codecs, codec{name: name, prefix: prefix, decode: checkedDecode(decode)})
+	sort.SliceStable(codecs, func(i, j int) bool {
+		return len(codecs[i].prefix) > len(codecs[j].prefix)
+	})
+}
+
+// checkedDecode wraps a registered decode function, rejecting the hashes it
+// returns that are not valid.
+func checkedDecode(decode func(string) (*Hash, error)) func(string) (*Hash, error) {
+	return func(hash string) (*Hash, error) {
+		h, err := decode(hash)
+		if err != nil {
+			return nil, err
+		}
+		if h == nil {
+			return nil, ErrInvalidHash
+		}
+		if err := h.check(); err != nil {
+			wipe(h.Salt)
+			wipe(h.Key)
+			return nil, err
+		}
+		return h, nil
+	}
+}
+
+// Codecs returns the names of all hash formats that can be decoded, including
+// FormatPHC and FormatLegacySHA1, sorted.
+func Codecs() []string {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	names := []string{FormatPHC}
+	for _, c := range codecs {
+		names = append(names, c.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// decodeHash decodes hash with the codec for its format. Legacy SHA-1
+// hashes are rejected with ErrIncompatibleVariant.
+func decodeHash(hash string) (*Hash, error) {
+	return decodeFormat(hash, false)
+}
+
+// decodeFormat decodes hash with the codec for its format, including
+// FormatLegacySHA1 if allowLegacy is set.
+func decodeFormat(hash string, allowLegacy bool) (*Hash, error) {
+	codecsMu.RLock()
+	var decode func(string) (*Hash, error)
+	for _, c := range codecs {
+		if strings.HasPrefix(hash, c.prefix) {
+			if c.legacy && !allowLegacy {
+				codecsMu.RUnlock()
+				return nil, ErrIncompatibleVariant
+			}
+			decode = c.decode
+			break
+		}
+	}
+	codecsMu.RUnlock()
+
+	if decode == nil {
+		return decodePHC(hash)
+	}
+	return decode(hash)
+}
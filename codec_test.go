@@ -0,0 +1,109 @@
+package pbkdf2
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Generated with Python's base64.b64encode(hashlib.pbkdf2_hmac("sha256",
+// b"password", b"seasalt1234", 1000)), in the layout of Django's
+// PBKDF2PasswordHasher.
+const djangoHash = "pbkdf2_sha256$1000$seasalt1234$iX6D1OoXKWsmC82FxfLWOA73XMoClH4DFhojI/+rn44="
+
+func decodeDjango(hash string) (*Hash, error) {
+	vals := strings.Split(hash, "$")
+	if len(vals) != 4 {
+		return nil, ErrInvalidHash
+	}
+	iterations, err := parseIterations(vals[1])
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(vals[3])
+	if err != nil {
+		return nil, ErrInvalidHash
+	}
+	h := &Hash{Salt: []byte(vals[2]), Key: key}
+	h.Params = Params{Iterations: iterations, SaltLength: uint32(len(h.Salt)), KeyLength: uint32(len(key)), Variant: VariantSHA256}
+	return h, nil
+}
+
+func TestRegisterCodec(t *testing.T) {
+	RegisterCodec("django-pbkdf2-sha256", "pbkdf2_sha256$", decodeDjango)
+	RegisterCodec("broken", "broken$", func(string) (*Hash, error) {
+		return &Hash{Params: Params{Iterations: 1, SaltLength: 4, KeyLength: 4}, Salt: []byte("salt")}, nil
+	})
+
+	found := 0
+	for _, name := range Codecs() {
+		if name == FormatPHC || name == FormatLegacySHA1 || name == "django-pbkdf2-sha256" {
+			found++
+		}
+	}
+	if found != 3 {
+		t.Errorf("unexpected codecs %q", Codecs())
+	}
+
+	params, _, _, err := DecodeHash(djangoHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Variant != VariantSHA256 || params.Iterations != 1000 || params.SaltLength != 11 {
+		t.Errorf("unexpected params %+v", params)
+	}
+	if err := Verify("password", djangoHash); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if err := Verify("wrong", djangoHash); err != ErrMismatchedHashAndPassword {
+		t.Errorf("expected ErrMismatchedHashAndPassword, got %v", err)
+	}
+	// Converting yields the native format, which still verifies.
+	h, err := ParseHash(djangoHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify("password", h.String()); err != nil || !strings.HasPrefix(h.String(), "$pbkdf2-sha256$1000$") {
+		t.Errorf("converted hash %q: %v", h.String(), err)
+	}
+
+	if _, err := ParseHash("pbkdf2_sha256$1000$salt"); err != ErrInvalidHash {
+		t.Errorf("malformed: expected ErrInvalidHash, got %v", err)
+	}
+	if _, err := ParseHash("broken$"); err != ErrInvalidHash {
+		t.Errorf("invalid decoded hash: expected ErrInvalidHash, got %v", err)
+	}
+}
+
+func TestDecodeLegacyFormat(t *testing.T) {
+	const legacy = "$pbkdf2$1000$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5a2V5a2V5a2V5"
+	if _, err := ParseHash(legacy); err != ErrIncompatibleVariant {
+		t.Errorf("expected ErrIncompatibleVariant, got %v", err)
+	}
+	if _, err := Normalize(legacy); err != nil {
+		t.Errorf("Normalize: %v", err)
+	}
+	if _, err := ParseHash("$pbkdf2-sha512$1000$AA"); !errors.Is(err, ErrInvalidHash) {
+		t.Errorf("expected ErrInvalidHash, got %v", err)
+	}
+}
+
+func TestRegisterCodecPanics(t *testing.T) {
+	for name, f := range map[string]func(){
+		"empty prefix": func() { RegisterCodec("x", "", decodeDjango) },
+		"phc name":     func() { RegisterCodec(FormatPHC, "x$", decodeDjango) },
+		"legacy":       func() { RegisterCodec("x", legacySHA1Prefix, decodeDjango) },
+		"variant":      func() { RegisterCodec("x", "$pbkdf2-sha512$", decodeDjango) },
+		"nil":          func() { RegisterCodec("x", "x$", nil) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -0,0 +1,357 @@
+// Package config loads password hashing configuration from YAML or TOML
+// files, and can watch a file for changes so that cost increases roll out
+// without restarting the process.
+//
+// A configuration selects the hashing parameters, either by naming one of the
+// pbkdf2 profiles or by giving them explicitly, and may set policy floors and
+// the IDs of the pepper keys in use:
+//
+//	# YAML
+//	profile: moderate
+//	policy:
+//	  min_iterations: 210000
+//	  min_salt_length: 16
+//	pepper:
+//	  active_key_id: k2
+//	  key_ids: [k1, k2]
+//
+//	# TOML
+//	[params]
+//	iterations = 600_000
+//	salt_length = 16
+//	key_length = 32
+//	variant = "pbkdf2-sha256"
+//
+//	[policy]
+//	min_iterations = 210_000
+//
+//	[policy.variant_min_iterations]
+//	pbkdf2-sha256 = 600_000
+//
+// Instead of a fixed iteration count, the cost may be given as a time budget,
+// which is resolved by calibration the first time HashParams is called, and
+// clamped to policy.min_iterations and policy.max_iterations:
+//
+//	target: 300ms
+//	params:
+//	  salt_length: 16
+//	  key_length: 64
+//	policy:
+//	  min_iterations: 210000
+//
+// The recognised keys are profile; target; params.iterations,
+// params.salt_length, params.key_length and params.variant;
+// policy.min_iterations, policy.max_iterations, policy.min_salt_length,
+// policy.min_key_length, policy.max_key_length and
+// policy.variant_min_iterations.<variant>; and pepper.active_key_id and
+// pepper.key_ids. Unknown keys are rejected, so that a typo cannot silently
+// leave a setting at its default.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// Config is a password hashing configuration.
+type Config struct {
+	// Profile names one of the pbkdf2 profiles, such as pbkdf2.ProfileModerate.
+	// It is mutually exclusive with Params and Target. If none of them is
+	// set, the pbkdf2 package-level default params are used.
+	Profile string
+
+	// Params gives the hashing parameters explicitly.
+	Params *pbkdf2.Params
+
+	// Target gives the cost of hashing as a duration. When parsed from a
+	// file, its salt length, key length and variant come from
+	// params.salt_length, params.key_length and params.variant, which then
+	// must not be accompanied by params.iterations, and its iteration bounds
+	// from the policy, using the floor for its variant where
+	// policy.variant_min_iterations has one.
+	Target *pbkdf2.Target
+
+	// Policy holds the floors (and ceilings) that the hashing parameters, and
+	// hashes being verified, must satisfy. It is nil if not configured.
+	Policy *pbkdf2.Policy
+
+	// Pepper lists the pepper keys in use.
+	Pepper PepperConfig
+}
+
+// PepperConfig identifies pepper keys. The keys themselves are secrets and do
+// not belong in a configuration file; only their IDs are recorded, for the
+// application to resolve.
+type PepperConfig struct {
+	// ActiveKeyID is the key used for new hashes.
+	ActiveKeyID string
+
+	// KeyIDs lists every key that may be needed to verify existing hashes,
+	// including the active one.
+	KeyIDs []string
+}
+
+// Load reads and validates the configuration file at path. Its format is
+// determined from the file extension; see FormatFromPath.
+func Load(path string) (*Config, error) {
+	format, err := FormatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, format)
+}
+
+// Parse parses and validates a configuration in the given format.
+func Parse(data []byte, format Format) (*Config, error) {
+	values, err := parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := c.set(k, values[k]); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.Target != nil {
+		if c.Params != nil {
+			if c.Params.Iterations != 0 {
+				return nil, errors.New("config: target and params.iterations are mutually exclusive")
+			}
+			c.Target.Base, c.Params = c.Params, nil
+		}
+		if c.Policy != nil {
+			variant := pbkdf2.GetDefaultParams().Variant
+			if c.Target.Base != nil {
+				variant = c.Target.Base.Variant
+			}
+			c.Target.MinIterations = minIterations(c.Policy, variant)
+			c.Target.MaxIterations = c.Policy.MaxIterations
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) set(key string, v value) error {
+	fail := func(msg string) error {
+		return &syntaxError{v.line, strconv.Quote(key) + " " + msg}
+	}
+	if v.isList != (key == "pepper.key_ids") {
+		if v.isList {
+			return fail("must be a single value")
+		}
+		return fail("must be a list")
+	}
+
+	uint32Field := func(dst *uint32) error {
+		n, err := strconv.ParseUint(v.scalar, 10, 32)
+		if err != nil {
+			return fail("must be a non-negative integer that fits in 32 bits")
+		}
+		*dst = uint32(n)
+		return nil
+	}
+	params := func() *pbkdf2.Params {
+		if c.Params == nil {
+			c.Params = &pbkdf2.Params{}
+		}
+		return c.Params
+	}
+	policy := func() *pbkdf2.Policy {
+		if c.Policy == nil {
+			c.Policy = &pbkdf2.Policy{}
+		}
+		return c.Policy
+	}
+
+	switch key {
+	case "profile":
+		c.Profile = v.scalar
+	case "target":
+		d, err := time.ParseDuration(v.scalar)
+		if err != nil || d <= 0 {
+			return fail("must be a positive duration such as 300ms")
+		}
+		c.Target = &pbkdf2.Target{Duration: d}
+	case "params.iterations":
+		return uint32Field(&params().Iterations)
+	case "params.salt_length":
+		return uint32Field(&params().SaltLength)
+	case "params.key_length":
+		return uint32Field(&params().KeyLength)
+	case "params.variant":
+		params().Variant = v.scalar
+	case "policy.min_iterations":
+		return uint32Field(&policy().MinIterations)
+	case "policy.max_iterations":
+		return uint32Field(&policy().MaxIterations)
+	case "policy.min_salt_length":
+		return uint32Field(&policy().MinSaltLength)
+	case "policy.min_key_length":
+		return uint32Field(&policy().MinKeyLength)
+	case "policy.max_key_length":
+		return uint32Field(&policy().MaxKeyLength)
+	case "pepper.active_key_id":
+		c.Pepper.ActiveKeyID = v.scalar
+	case "pepper.key_ids":
+		c.Pepper.KeyIDs = v.list
+	default:
+		variant := strings.TrimPrefix(key, "policy.variant_min_iterations.")
+		if variant == key {
+			return fail("is not a recognised setting")
+		}
+		var floor uint32
+		if err := uint32Field(&floor); err != nil {
+			return err
+		}
+		if policy().VariantMinIterations == nil {
+			c.Policy.VariantMinIterations = make(map[string]uint32)
+		}
+		c.Policy.VariantMinIterations[variant] = floor
+	}
+	return nil
+}
+
+// Validate checks that the configuration is consistent: the profile exists,
+// the parameters are complete and satisfy the policy, and the active pepper
+// key is one of the listed keys. A target is checked without calibrating it.
+func (c *Config) Validate() error {
+	set := 0
+	for _, ok := range []bool{c.Profile != "", c.Params != nil, c.Target != nil} {
+		if ok {
+			set++
+		}
+	}
+	if set > 1 {
+		return errors.New("config: profile, params and target are mutually exclusive")
+	}
+
+	if c.Target != nil {
+		if err := c.validateTarget(); err != nil {
+			return err
+		}
+	} else {
+		params, err := c.HashParams()
+		if err != nil {
+			return err
+		}
+		if err := params.Validate(); errors.Is(err, pbkdf2.ErrIncompatibleVariant) {
+			return fmt.Errorf("config: params.variant %q is not a registered variant: %w", params.Variant, err)
+		} else if err != nil {
+			return fmt.Errorf("config: params.iterations, params.salt_length and params.key_length must all be set and non-zero: %w", err)
+		}
+		if err := c.Policy.Check(params); err != nil {
+			return fmt.Errorf("config: configured params do not satisfy the policy: %w", err)
+		}
+	}
+
+	seen := make(map[string]bool, len(c.Pepper.KeyIDs))
+	for _, id := range c.Pepper.KeyIDs {
+		if id == "" {
+			return errors.New("config: pepper.key_ids contains an empty key ID")
+		}
+		if seen[id] {
+			return fmt.Errorf("config: pepper.key_ids contains %q more than once", id)
+		}
+		seen[id] = true
+	}
+	if c.Pepper.ActiveKeyID != "" && !seen[c.Pepper.ActiveKeyID] {
+		return fmt.Errorf("config: pepper.active_key_id %q is not listed in pepper.key_ids", c.Pepper.ActiveKeyID)
+	}
+	return nil
+}
+
+// validateTarget checks everything about the target except the calibrated
+// iteration count, which the target clamps to its bounds.
+func (c *Config) validateTarget() error {
+	t := c.Target
+	if t.Duration <= 0 {
+		return errors.New("config: target must be a positive duration")
+	}
+	if t.MaxIterations != 0 && t.MinIterations > t.MaxIterations {
+		return errors.New("config: the target's minimum iterations exceed its maximum")
+	}
+
+	params := pbkdf2.GetDefaultParams()
+	if t.Base != nil {
+		params = &pbkdf2.Params{SaltLength: t.Base.SaltLength, KeyLength: t.Base.KeyLength, Variant: t.Base.Variant}
+	}
+	params.Iterations = t.MinIterations
+	if params.Iterations == 0 {
+		params.Iterations = 1
+	}
+	if floor := minIterations(c.Policy, params.Variant); floor > params.Iterations {
+		params.Iterations = floor
+	}
+	if err := params.Validate(); errors.Is(err, pbkdf2.ErrIncompatibleVariant) {
+		return fmt.Errorf("config: params.variant %q is not a registered variant: %w", params.Variant, err)
+	} else if err != nil {
+		return fmt.Errorf("config: params.salt_length and params.key_length must both be set and non-zero: %w", err)
+	}
+	if err := c.Policy.Check(params); err != nil {
+		return fmt.Errorf("config: configured params do not satisfy the policy: %w", err)
+	}
+	return nil
+}
+
+// minIterations returns the iteration floor that policy, which may be nil,
+// sets for variant: its VariantMinIterations entry if there is one, otherwise
+// MinIterations.
+func minIterations(policy *pbkdf2.Policy, variant string) uint32 {
+	if policy == nil {
+		return 0
+	}
+	if variant == "" {
+		variant = pbkdf2.VariantSHA512
+	}
+	if floor, ok := policy.VariantMinIterations[variant]; ok {
+		return floor
+	}
+	return policy.MinIterations
+}
+
+// HashParams returns the parameters to hash new passwords with: the explicit
+// Params if set, otherwise those calibrated from the Target, otherwise those
+// of the named profile, otherwise those returned by pbkdf2.GetDefaultParams.
+func (c *Config) HashParams() (*pbkdf2.Params, error) {
+	if c.Target != nil {
+		return c.Target.Params()
+	}
+	if c.Params != nil {
+		params := *c.Params
+		return &params, nil
+	}
+
+	profile := c.Profile
+	if profile == "" {
+		profile = pbkdf2.ProfileDefault
+	}
+	params, err := pbkdf2.ProfileParams(profile)
+	if err != nil {
+		return nil, fmt.Errorf("config: profile %q: %w", c.Profile, err)
+	}
+	return params, nil
+}
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+const testYAML = `---
+# Hashing configuration
+params:
+  iterations: 600000
+  salt_length: 16
+  key_length: 64   # bytes
+policy:
+  min_iterations: 210000
+  min_salt_length: 16
+  variant_min_iterations:
+    pbkdf2-sha256: 600000
+pepper:
+  active_key_id: "k2"
+  key_ids:
+    - k1
+    - 'k2'
+`
+
+const testTOML = `
+# Hashing configuration
+[params]
+iterations = 600_000
+salt_length = 16
+key_length = 64 # bytes
+
+[policy]
+min_iterations = 210_000
+min_salt_length = 16
+
+[policy.variant_min_iterations]
+pbkdf2-sha256 = 600_000
+
+[pepper]
+active_key_id = "k2"
+key_ids = ["k1", 'k2']
+`
+
+func TestParse(t *testing.T) {
+	want := &Config{
+		Params: &pbkdf2.Params{Iterations: 600000, SaltLength: 16, KeyLength: 64},
+		Policy: &pbkdf2.Policy{
+			MinIterations:        210000,
+			MinSaltLength:        16,
+			VariantMinIterations: map[string]uint32{pbkdf2.VariantSHA256: 600000},
+		},
+		Pepper: PepperConfig{ActiveKeyID: "k2", KeyIDs: []string{"k1", "k2"}},
+	}
+
+	for format, text := range map[Format]string{YAML: testYAML, TOML: testTOML} {
+		c, err := Parse([]byte(text), format)
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		if !reflect.DeepEqual(c, want) {
+			t.Fatalf("%v: expected %#v got %#v", format, want, c)
+		}
+	}
+}
+
+func TestParseProfile(t *testing.T) {
+	c, err := Parse([]byte("profile: moderate\npepper:\n  key_ids: [a, b]\n"), YAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, err := c.HashParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := pbkdf2.ProfileParams(pbkdf2.ProfileModerate)
+	if *params != *want {
+		t.Fatalf("expected %#v got %#v", *want, *params)
+	}
+	if !reflect.DeepEqual(c.Pepper.KeyIDs, []string{"a", "b"}) {
+		t.Fatalf("unexpected key IDs %#v", c.Pepper.KeyIDs)
+	}
+
+	c, err = Parse(nil, TOML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, err = c.HashParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *params != *pbkdf2.DefaultParams {
+		t.Fatalf("expected %#v got %#v", *pbkdf2.DefaultParams, *params)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		format Format
+		text   string
+	}{
+		{YAML, "profile: fast\n"},
+		{YAML, "profile: default\nparams:\n  iterations: 1\n"},
+		{YAML, "params:\n  iterations: 1000\n"},
+		{YAML, "params:\n  iterations: -1\n  salt_length: 16\n  key_length: 64\n"},
+		{YAML, "params:\n\titerations: 1000\n"},
+		{YAML, "profiles: default\n"},
+		{YAML, "profile: default\nprofile: moderate\n"},
+		{YAML, "profile: &anchor default\n"},
+		{YAML, "pepper:\n  key_ids: k1\n"},
+		{YAML, "pepper:\n  active_key_id: k3\n  key_ids: [k1, k2]\n"},
+		{TOML, "profile = default\n"},
+		{TOML, "[params\n"},
+		{TOML, "[[params]]\n"},
+		{TOML, "profile = \"moderate\n"},
+		{TOML, "[policy]\nmin_iterations = 600000\n"},
+		{YAML, "target: fast\n"},
+		{YAML, "target: -300ms\n"},
+		{YAML, "target: 300ms\nprofile: moderate\n"},
+		{YAML, "target: 300ms\nparams:\n  iterations: 1000\n  salt_length: 16\n  key_length: 64\n"},
+		{YAML, "target: 300ms\nparams:\n  salt_length: 16\n"},
+		{YAML, "target: 300ms\npolicy:\n  min_iterations: 2000\n  max_iterations: 1000\n"},
+	}
+
+	for _, test := range tests {
+		if _, err := Parse([]byte(test.text), test.format); err == nil {
+			t.Errorf("%v: expected %q to be rejected", test.format, test.text)
+		}
+	}
+}
+
+func TestParsePolicyViolation(t *testing.T) {
+	_, err := Parse([]byte("profile: default\npolicy:\n  min_iterations: 600000\n"), YAML)
+	if !errors.Is(err, pbkdf2.ErrPolicyViolation) {
+		t.Fatalf("expected ErrPolicyViolation, got %v", err)
+	}
+}
+
+func TestParseVariant(t *testing.T) {
+	for format, text := range map[Format]string{
+		YAML: "params:\n  iterations: 600000\n  salt_length: 16\n  key_length: 32\n  variant: pbkdf2-sha256\n",
+		TOML: "[params]\niterations = 600_000\nsalt_length = 16\nkey_length = 32\nvariant = \"pbkdf2-sha256\"\n",
+	} {
+		c, err := Parse([]byte(text), format)
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		if c.Params.Variant != pbkdf2.VariantSHA256 {
+			t.Fatalf("%v: expected variant %q got %q", format, pbkdf2.VariantSHA256, c.Params.Variant)
+		}
+	}
+
+	for _, text := range []string{
+		"params:\n  iterations: 600000\n  salt_length: 16\n  key_length: 32\n  variant: pbkdf2-md5\n",
+		"target: 300ms\nparams:\n  salt_length: 16\n  key_length: 32\n  variant: pbkdf2-md5\n",
+	} {
+		if _, err := Parse([]byte(text), YAML); !errors.Is(err, pbkdf2.ErrIncompatibleVariant) {
+			t.Errorf("expected ErrIncompatibleVariant for %q, got %v", text, err)
+		}
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	text := "target = \"1ms\"\n\n[params]\nsalt_length = 32\nkey_length = 32\n\n[policy]\nmin_iterations = 50_000\nmax_iterations = 60_000\n"
+	c, err := Parse([]byte(text), TOML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Params != nil || c.Target == nil || c.Target.Duration != time.Millisecond {
+		t.Fatalf("unexpected config %#v", c)
+	}
+	if c.Target.MinIterations != 50000 || c.Target.MaxIterations != 60000 {
+		t.Fatalf("expected the policy to bound the target, got %#v", c.Target)
+	}
+
+	params, err := c.HashParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Iterations < 50000 || params.Iterations > 60000 || params.SaltLength != 32 || params.KeyLength != 32 {
+		t.Fatalf("unexpected params %#v", *params)
+	}
+}
+
+func TestParseTargetVariantFloor(t *testing.T) {
+	text := "target: 1ms\npolicy:\n  min_iterations: 1000\n  variant_min_iterations:\n    pbkdf2-sha512: 50000\n    pbkdf2-sha256: 60000\n"
+	c, err := Parse([]byte(text), YAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Target.MinIterations != 50000 {
+		t.Fatalf("expected the SHA-512 floor of 50000, got %d", c.Target.MinIterations)
+	}
+	params, err := c.HashParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Policy.Check(params); err != nil {
+		t.Fatalf("calibrated params violate the policy: %v", err)
+	}
+
+	c, err = Parse([]byte(text+"params:\n  salt_length: 16\n  key_length: 32\n  variant: pbkdf2-sha256\n"), YAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Target.MinIterations != 60000 {
+		t.Fatalf("expected the SHA-256 floor of 60000, got %d", c.Target.MinIterations)
+	}
+}
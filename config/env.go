@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// Environment variables read by ParamsFromEnv.
+const (
+	EnvProfile    = "PBKDF2_PROFILE"
+	EnvIterations = "PBKDF2_ITERATIONS"
+	EnvSaltLength = "PBKDF2_SALT_LENGTH"
+	EnvKeyLength  = "PBKDF2_KEY_LENGTH"
+)
+
+// ParamsFromEnv returns hashing parameters configured by environment
+// variables. PBKDF2_PROFILE selects a base profile (pbkdf2.ProfileDefault if
+// unset), and PBKDF2_ITERATIONS, PBKDF2_SALT_LENGTH and PBKDF2_KEY_LENGTH
+// override its individual parameters. Variables that are unset or empty are
+// ignored, so with no variables set the result is the pbkdf2 package-level
+// default params.
+//
+// An error naming the offending variable is returned if the profile is unknown
+// or a parameter is not a positive integer that fits in 32 bits, so that the
+// params returned always pass pbkdf2.Params.Validate.
+func ParamsFromEnv() (*pbkdf2.Params, error) {
+	return paramsFromEnv(os.LookupEnv)
+}
+
+func paramsFromEnv(lookup func(string) (string, bool)) (*pbkdf2.Params, error) {
+	profile := pbkdf2.ProfileDefault
+	if v, ok := lookup(EnvProfile); ok && v != "" {
+		profile = v
+	}
+	params, err := pbkdf2.ProfileParams(profile)
+	if err != nil {
+		return nil, fmt.Errorf("config: %s=%q: %w", EnvProfile, profile, err)
+	}
+
+	fields := []struct {
+		name string
+		dst  *uint32
+	}{
+		{EnvIterations, &params.Iterations},
+		{EnvSaltLength, &params.SaltLength},
+		{EnvKeyLength, &params.KeyLength},
+	}
+	for _, f := range fields {
+		v, ok := lookup(f.name)
+		if !ok || v == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("config: %s=%q: must be a positive integer that fits in 32 bits", f.name, v)
+		}
+		*f.dst = uint32(n)
+	}
+	if err := params.Validate(); err != nil {
+		return nil, fmt.Errorf("config: params from the environment are invalid: %w", err)
+	}
+	return params, nil
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+func TestParamsFromEnv(t *testing.T) {
+	params, err := ParamsFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *params != *pbkdf2.DefaultParams {
+		t.Fatalf("expected %#v got %#v", *pbkdf2.DefaultParams, *params)
+	}
+
+	t.Setenv(EnvProfile, pbkdf2.ProfileSensitive)
+	t.Setenv(EnvIterations, "2000000")
+	t.Setenv(EnvKeyLength, "")
+	params, err = ParamsFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := pbkdf2.ProfileParams(pbkdf2.ProfileSensitive)
+	want.Iterations = 2000000
+	if *params != *want {
+		t.Fatalf("expected %#v got %#v", *want, *params)
+	}
+
+	// Any count that fits in 32 bits is one CreateHash accepts.
+	t.Setenv(EnvIterations, "4000000000")
+	params, err = ParamsFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Iterations != 4000000000 {
+		t.Fatalf("expected 4000000000 iterations, got %d", params.Iterations)
+	}
+	if err := params.Validate(); err != nil {
+		t.Fatalf("expected params that validate, got %v", err)
+	}
+}
+
+func TestParamsFromEnvInvalid(t *testing.T) {
+	for _, env := range []map[string]string{
+		{EnvProfile: "fast"},
+		{EnvIterations: "210k"},
+		{EnvSaltLength: "0"},
+		{EnvKeyLength: "-64"},
+		{EnvIterations: "4294967296"},
+	} {
+		_, err := paramsFromEnv(func(k string) (string, bool) {
+			v, ok := env[k]
+			return v, ok
+		})
+		if err == nil {
+			t.Errorf("expected %v to be rejected", env)
+		}
+	}
+}
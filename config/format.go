@@ -0,0 +1,368 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Format identifies the syntax of a configuration file.
+type Format int
+
+const (
+	// YAML configuration. Only the subset needed to express a Config is
+	// supported: nested block mappings, scalars, and flow ([a, b]) or block
+	// ("- a") sequences of scalars. Anchors, tags, multi-line scalars and
+	// multiple documents are rejected.
+	YAML Format = iota + 1
+
+	// TOML configuration. Only the subset needed to express a Config is
+	// supported: tables, and key/value pairs whose values are strings,
+	// integers, booleans, or single-line arrays of those.
+	TOML
+)
+
+func (f Format) String() string {
+	switch f {
+	case YAML:
+		return "YAML"
+	case TOML:
+		return "TOML"
+	}
+	return "Format(" + strconv.Itoa(int(f)) + ")"
+}
+
+// FormatFromPath returns the format implied by the extension of path: .yaml and
+// .yml for YAML, and .toml for TOML.
+func FormatFromPath(path string) (Format, error) {
+	switch {
+	case strings.HasSuffix(path, ".yaml"), strings.HasSuffix(path, ".yml"):
+		return YAML, nil
+	case strings.HasSuffix(path, ".toml"):
+		return TOML, nil
+	}
+	return 0, fmt.Errorf("config: cannot determine format of %q from its extension", path)
+}
+
+// value is a scalar or list found at a dotted key path, such as
+// "params.iterations", along with the line it was defined on.
+type value struct {
+	line   int
+	scalar string
+	list   []string
+	isList bool
+}
+
+// syntaxError reports a problem at a specific line of a configuration file.
+type syntaxError struct {
+	line int
+	msg  string
+}
+
+func (e *syntaxError) Error() string {
+	return "config: line " + strconv.Itoa(e.line) + ": " + e.msg
+}
+
+var errUnterminated = errors.New("unterminated quoted string")
+
+func parse(data []byte, format Format) (map[string]value, error) {
+	switch format {
+	case YAML:
+		return parseYAML(string(data))
+	case TOML:
+		return parseTOML(string(data))
+	}
+	return nil, fmt.Errorf("config: unsupported format %v", format)
+}
+
+func parseYAML(text string) (map[string]value, error) {
+	type frame struct {
+		indent int
+		prefix string
+	}
+	values := make(map[string]value)
+	stack := []frame{{indent: -1}}
+	listKey, listIndent := "", 0
+
+	for i, raw := range strings.Split(text, "\n") {
+		line := i + 1
+		content, err := stripComment(strings.TrimRight(raw, " \r"))
+		if err != nil {
+			return nil, &syntaxError{line, err.Error()}
+		}
+		trimmed := strings.TrimLeft(content, " ")
+		if trimmed == "" || (line == 1 && trimmed == "---") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, &syntaxError{line, "tabs are not allowed for indentation"}
+		}
+		indent := len(content) - len(trimmed)
+
+		if trimmed == "-" || strings.HasPrefix(trimmed, "- ") {
+			if listKey == "" || indent < listIndent {
+				return nil, &syntaxError{line, "sequence item without a key"}
+			}
+			item, err := parseScalar(strings.TrimSpace(trimmed[1:]))
+			if err != nil {
+				return nil, &syntaxError{line, err.Error()}
+			}
+			v := values[listKey]
+			v.list = append(v.list, item)
+			values[listKey] = v
+			continue
+		}
+		listKey = ""
+
+		for indent <= stack[len(stack)-1].indent {
+			stack = stack[:len(stack)-1]
+		}
+
+		key, rest, ok := cutMapping(trimmed)
+		if !ok {
+			return nil, &syntaxError{line, "expected \"key: value\""}
+		}
+		full := stack[len(stack)-1].prefix + key
+		if _, dup := values[full]; dup {
+			return nil, &syntaxError{line, "duplicate key " + strconv.Quote(full)}
+		}
+
+		switch {
+		case rest == "":
+			// Either a nested mapping or a block sequence follows.
+			stack = append(stack, frame{indent: indent, prefix: full + "."})
+			values[full] = value{line: line, isList: true}
+			listKey, listIndent = full, indent
+		case strings.HasPrefix(rest, "["):
+			list, err := parseFlowList(rest, parseScalar)
+			if err != nil {
+				return nil, &syntaxError{line, err.Error()}
+			}
+			values[full] = value{line: line, list: list, isList: true}
+		default:
+			if strings.ContainsAny(rest[:1], "&*!|>{") {
+				return nil, &syntaxError{line, "unsupported YAML syntax " + strconv.Quote(rest)}
+			}
+			scalar, err := parseScalar(rest)
+			if err != nil {
+				return nil, &syntaxError{line, err.Error()}
+			}
+			values[full] = value{line: line, scalar: scalar}
+		}
+	}
+
+	// Keys that opened a nested mapping are not values in their own right.
+	for k, v := range values {
+		if v.isList && v.list == nil && hasChild(values, k) {
+			delete(values, k)
+		}
+	}
+	return values, nil
+}
+
+func cutMapping(s string) (key, rest string, ok bool) {
+	if strings.HasSuffix(s, ":") {
+		key = strings.TrimSpace(s[:len(s)-1])
+	} else if i := strings.Index(s, ": "); i >= 0 {
+		key, rest = strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+2:])
+	} else {
+		return "", "", false
+	}
+	return key, rest, validKey(key)
+}
+
+func hasChild(values map[string]value, key string) bool {
+	for k := range values {
+		if strings.HasPrefix(k, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseScalar unquotes a YAML scalar.
+func parseScalar(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		return strconv.Unquote(s)
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", errUnterminated
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return s, nil
+}
+
+func parseTOML(text string) (map[string]value, error) {
+	values := make(map[string]value)
+	prefix := ""
+
+	for i, raw := range strings.Split(text, "\n") {
+		line := i + 1
+		content, err := stripComment(raw)
+		if err != nil {
+			return nil, &syntaxError{line, err.Error()}
+		}
+		content = strings.TrimSpace(content)
+		if content == "" {
+			continue
+		}
+
+		if strings.HasPrefix(content, "[") {
+			if !strings.HasSuffix(content, "]") || strings.HasPrefix(content, "[[") {
+				return nil, &syntaxError{line, "invalid table header"}
+			}
+			table := strings.TrimSpace(content[1 : len(content)-1])
+			if !validKey(table) {
+				return nil, &syntaxError{line, "invalid table name " + strconv.Quote(table)}
+			}
+			prefix = table + "."
+			continue
+		}
+
+		eq := strings.IndexByte(content, '=')
+		if eq < 0 {
+			return nil, &syntaxError{line, "expected \"key = value\""}
+		}
+		key := strings.TrimSpace(content[:eq])
+		rest := strings.TrimSpace(content[eq+1:])
+		if !validKey(key) {
+			return nil, &syntaxError{line, "invalid key " + strconv.Quote(key)}
+		}
+		full := prefix + key
+		if _, dup := values[full]; dup {
+			return nil, &syntaxError{line, "duplicate key " + strconv.Quote(full)}
+		}
+
+		if strings.HasPrefix(rest, "[") {
+			list, err := parseFlowList(rest, parseTOMLValue)
+			if err != nil {
+				return nil, &syntaxError{line, err.Error()}
+			}
+			values[full] = value{line: line, list: list, isList: true}
+			continue
+		}
+		scalar, err := parseTOMLValue(rest)
+		if err != nil {
+			return nil, &syntaxError{line, err.Error()}
+		}
+		values[full] = value{line: line, scalar: scalar}
+	}
+	return values, nil
+}
+
+// parseTOMLValue unquotes a TOML string, or validates a bare integer or
+// boolean.
+func parseTOMLValue(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		return strconv.Unquote(s)
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") || strings.Contains(s[1:len(s)-1], "'") {
+			return "", errUnterminated
+		}
+		return s[1 : len(s)-1], nil
+	case s == "true" || s == "false":
+		return s, nil
+	}
+
+	digits := strings.ReplaceAll(s, "_", "")
+	if _, err := strconv.ParseInt(digits, 10, 64); err != nil {
+		return "", errors.New("unsupported value " + strconv.Quote(s))
+	}
+	return digits, nil
+}
+
+// parseFlowList parses a single-line list of scalars such as [a, "b", 'c'].
+func parseFlowList(s string, item func(string) (string, error)) ([]string, error) {
+	if !strings.HasSuffix(s, "]") {
+		return nil, errors.New("unterminated list")
+	}
+	inner := strings.TrimSpace(s[1 : len(s)-1])
+	list := []string{}
+	if inner == "" {
+		return list, nil
+	}
+
+	for _, elem := range splitOutsideQuotes(inner, ',') {
+		elem = strings.TrimSpace(elem)
+		if elem == "" {
+			// Allow a trailing comma.
+			continue
+		}
+		v, err := item(elem)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+// stripComment removes a trailing # comment that is not inside a quoted
+// string.
+func stripComment(s string) (string, error) {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i], nil
+		}
+	}
+	if quote != 0 {
+		return "", errUnterminated
+	}
+	return s, nil
+}
+
+func splitOutsideQuotes(s string, sep byte) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func validKey(k string) bool {
+	if k == "" {
+		return false
+	}
+	for _, part := range strings.Split(k, ".") {
+		if part == "" {
+			return false
+		}
+		for i := 0; i < len(part); i++ {
+			c := part[i]
+			if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultWatchInterval is the polling interval used by Watch when
+// WatchOptions.Interval is zero.
+const DefaultWatchInterval = 5 * time.Second
+
+// WatchOptions configures a Watcher.
+type WatchOptions struct {
+	// Interval is how often the file is checked for changes.
+	Interval time.Duration
+
+	// OnReload, if set, is called with each new configuration after it has
+	// been loaded and validated.
+	OnReload func(*Config)
+
+	// OnError, if set, is called when a changed file cannot be read or is
+	// invalid. The previous configuration remains in effect.
+	OnError func(error)
+}
+
+// A Watcher holds the current configuration loaded from a file, and reloads it
+// whenever the file's contents change.
+//
+// The file is polled rather than watched with OS notifications, which works
+// uniformly across platforms and with the symlink swaps used by Kubernetes
+// ConfigMap volumes.
+type Watcher struct {
+	path    string
+	opts    WatchOptions
+	current atomic.Pointer[Config]
+
+	// The file contents last seen by poll, whether valid or not, so that an
+	// invalid file is only reported once.
+	seen []byte
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// Watch loads the configuration file at path and starts watching it for
+// changes. It returns an error if the initial configuration cannot be loaded.
+// Call Close to stop watching.
+func Watch(path string, opts WatchOptions) (*Watcher, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultWatchInterval
+	}
+
+	w := &Watcher{
+		path: path,
+		opts: opts,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	data, c, err := w.load()
+	if err != nil {
+		return nil, err
+	}
+	w.seen = data
+	w.current.Store(c)
+
+	go w.run()
+	return w, nil
+}
+
+// Config returns the most recently loaded valid configuration. The returned
+// value must not be modified.
+func (w *Watcher) Config() *Config {
+	return w.current.Load()
+}
+
+// Close stops watching the file.
+func (w *Watcher) Close() error {
+	w.once.Do(func() { close(w.stop) })
+	<-w.done
+	return nil
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.poll()
+		}
+	}
+}
+
+func (w *Watcher) poll() {
+	data, c, err := w.load()
+	if data != nil {
+		if bytes.Equal(data, w.seen) {
+			return
+		}
+		w.seen = data
+	}
+	if err != nil {
+		if w.opts.OnError != nil {
+			w.opts.OnError(err)
+		}
+		return
+	}
+
+	w.current.Store(c)
+	if w.opts.OnReload != nil {
+		w.opts.OnReload(c)
+	}
+}
+
+func (w *Watcher) load() ([]byte, *Config, error) {
+	format, err := FormatFromPath(w.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := Parse(data, format)
+	return data, c, err
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pbkdf2.yaml")
+	if err := os.WriteFile(path, []byte("profile: interactive\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reloads := make(chan *Config, 1)
+	errs := make(chan error, 1)
+	w, err := Watch(path, WatchOptions{
+		Interval: 10 * time.Millisecond,
+		OnReload: func(c *Config) { reloads <- c },
+		OnError:  func(err error) { errs <- err },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if w.Config().Profile != "interactive" {
+		t.Fatalf("unexpected initial config %#v", w.Config())
+	}
+
+	// An invalid file is reported and the previous config kept.
+	if err := os.WriteFile(path, []byte("profile: fast\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-errs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an error")
+	}
+	if w.Config().Profile != "interactive" {
+		t.Fatalf("invalid config replaced the current one: %#v", w.Config())
+	}
+
+	if err := os.WriteFile(path, []byte("profile: moderate\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case c := <-reloads:
+		if c.Profile != "moderate" {
+			t.Fatalf("unexpected reloaded config %#v", c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a reload")
+	}
+	if w.Config().Profile != "moderate" {
+		t.Fatalf("unexpected current config %#v", w.Config())
+	}
+}
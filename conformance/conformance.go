@@ -0,0 +1,171 @@
+// Package conformance checks password hash verifiers against an embedded
+// corpus of known-good hashes in the formats of other PBKDF2 implementations,
+// so that anyone adding a codec, a crypt verifier or a key derivation backend
+// can prove compatibility with one call:
+//
+//	report := conformance.RunConformance(crypt.VerifierFunc(crypt.Verify))
+//	if err := report.Err(); err != nil {
+//		t.Fatal(err)
+//	}
+//
+// The corpus covers these formats:
+//
+//	passlib-pbkdf2    passlib's pbkdf2_sha256 and pbkdf2_sha512, $pbkdf2-sha256$<rounds>$<ab64 salt>$<ab64 key>
+//	django-pbkdf2     Django's pbkdf2_sha256 and pbkdf2_sha1, pbkdf2_sha256$<iterations>$<salt>$<base64 key>
+//	aspnet-identity   ASP.NET Core Identity's PasswordHasher, versions 2 and 3, as a single base64 string
+//	phc-pbkdf2        Node's @phc/pbkdf2, $pbkdf2-sha512$i=<iterations>$<salt>$<key>
+//
+// Each hash is accompanied by a case with a wrong password, which must be
+// rejected. The hashes were computed with Python's hashlib.pbkdf2_hmac and
+// encoded as each implementation does, with iteration counts lower than their
+// defaults to keep the suite fast.
+package conformance
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pganguli/pbkdf2"
+	"github.com/pganguli/pbkdf2/crypt"
+)
+
+// Formats of the hashes in the corpus.
+const (
+	FormatPasslib = "passlib-pbkdf2"
+	FormatDjango  = "django-pbkdf2"
+	FormatASPNet  = "aspnet-identity"
+	FormatPHC     = "phc-pbkdf2"
+)
+
+// A Case is a password and a hash from the corpus, and whether they match.
+type Case struct {
+	// Source is the implementation that produces the format: "passlib",
+	// "django", "dotnet" or "node".
+	Source string `json:"source"`
+
+	// Format is one of the Format constants.
+	Format string `json:"format"`
+
+	// Note describes the case, such as the hasher that produced it.
+	Note string `json:"note"`
+
+	Password string `json:"password"`
+	Hash     string `json:"hash"`
+	Match    bool   `json:"match"`
+}
+
+//go:embed corpus.json
+var corpusJSON []byte
+
+// Corpus returns the cases in the corpus, in a fixed order.
+func Corpus() []Case {
+	var cases []Case
+	if err := json.Unmarshal(corpusJSON, &cases); err != nil {
+		panic("conformance: invalid embedded corpus: " + err.Error())
+	}
+	return cases
+}
+
+// A Failure is a case that a verifier got wrong, and the error it returned.
+type Failure struct {
+	Case Case
+	Err  error
+}
+
+// A Report is the outcome of RunConformance.
+type Report struct {
+	// Passed is the number of cases the verifier got right.
+	Passed int
+
+	// Unsupported holds the cases whose format the verifier reported it
+	// does not support, by returning an error matching
+	// crypt.ErrUnknownScheme or pbkdf2.ErrIncompatibleVariant. They do not
+	// count as failures.
+	Unsupported []Case
+
+	// Failures holds the cases the verifier got wrong: a matching password
+	// that was not accepted, or a wrong password that was not rejected with
+	// an error matching crypt.ErrMismatchedHashAndPassword.
+	Failures []Failure
+
+	// formats are the formats RunConformance was asked to check, if any.
+	formats []string
+}
+
+// Err returns an error describing the failures, or nil if there were none.
+// Since a verifier that supports nothing cannot fail, Err also returns an
+// error if no case was supported, or if every case of a format passed to
+// RunConformance was unsupported.
+func (r *Report) Err() error {
+	if len(r.Failures) == 0 {
+		if r.Passed == 0 {
+			return errors.New("conformance: no cases were supported")
+		}
+		for _, f := range r.formats {
+			if r.unsupported(f) {
+				return errors.New("conformance: no " + f + " cases were supported")
+			}
+		}
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "conformance: %d of %d cases failed", len(r.Failures), r.Passed+len(r.Failures))
+	for _, f := range r.Failures {
+		msg := "wrong password accepted"
+		if f.Err != nil {
+			msg = f.Err.Error()
+		}
+		fmt.Fprintf(&b, "\n\t%s (%s) %s: %s", f.Case.Format, f.Case.Note, f.Case.Hash, msg)
+	}
+	return errors.New(b.String())
+}
+
+// unsupported reports whether every case of format was unsupported, which is
+// also the case if the corpus has no cases of format.
+func (r *Report) unsupported(format string) bool {
+	n := 0
+	for _, c := range Corpus() {
+		if c.Format == format {
+			n++
+		}
+	}
+	for _, c := range r.Unsupported {
+		if c.Format == format {
+			n--
+		}
+	}
+	return n == 0
+}
+
+// RunConformance verifies every case in the corpus with v, or only the cases
+// in the given formats if any are given, and reports the results.
+func RunConformance(v crypt.Verifier, formats ...string) *Report {
+	r := &Report{formats: formats}
+	for _, c := range Corpus() {
+		if len(formats) > 0 && !contains(formats, c.Format) {
+			continue
+		}
+		err := v.Verify(c.Password, c.Hash)
+		switch {
+		case errors.Is(err, crypt.ErrUnknownScheme) || errors.Is(err, pbkdf2.ErrIncompatibleVariant):
+			r.Unsupported = append(r.Unsupported, c)
+		case c.Match && err == nil, !c.Match && errors.Is(err, crypt.ErrMismatchedHashAndPassword):
+			r.Passed++
+		default:
+			r.Failures = append(r.Failures, Failure{Case: c, Err: err})
+		}
+	}
+	return r
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package conformance
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+	"testing"
+
+	xpbkdf2 "golang.org/x/crypto/pbkdf2"
+
+	"github.com/pganguli/pbkdf2/crypt"
+)
+
+var errMalformed = errors.New("malformed hash")
+
+// referenceVerify decodes each format in the corpus independently of the
+// pbkdf2 package, to check the corpus itself.
+func referenceVerify(password, h string) error {
+	var (
+		prf        func() hash.Hash
+		iterations int
+		salt, key  []byte
+		err        error
+	)
+	digests := map[string]func() hash.Hash{"sha1": sha1.New, "sha256": sha256.New, "sha512": sha512.New}
+	vals := strings.Split(h, "$")
+	switch {
+	case strings.HasPrefix(h, "$pbkdf2-") && strings.HasPrefix(vals[2], "i="):
+		prf = digests[strings.TrimPrefix(vals[1], "pbkdf2-")]
+		iterations, err = strconv.Atoi(vals[2][2:])
+		salt, _ = base64.RawStdEncoding.DecodeString(vals[3])
+		key, _ = base64.RawStdEncoding.DecodeString(vals[4])
+	case strings.HasPrefix(h, "$pbkdf2-"):
+		prf = digests[strings.TrimPrefix(vals[1], "pbkdf2-")]
+		iterations, err = strconv.Atoi(vals[2])
+		salt, _ = base64.RawStdEncoding.DecodeString(strings.ReplaceAll(vals[3], ".", "+"))
+		key, _ = base64.RawStdEncoding.DecodeString(strings.ReplaceAll(vals[4], ".", "+"))
+	case strings.HasPrefix(h, "pbkdf2_"):
+		prf = digests[strings.TrimPrefix(vals[0], "pbkdf2_")]
+		iterations, err = strconv.Atoi(vals[1])
+		salt = []byte(vals[2])
+		key, _ = base64.StdEncoding.DecodeString(vals[3])
+	default:
+		b, _ := base64.StdEncoding.DecodeString(h)
+		switch {
+		case len(b) == 49 && b[0] == 0:
+			prf, iterations, salt, key = sha1.New, 1000, b[1:17], b[17:]
+		case len(b) > 13 && b[0] == 1:
+			prf = []func() hash.Hash{sha1.New, sha256.New, sha512.New}[binary.BigEndian.Uint32(b[1:])]
+			iterations = int(binary.BigEndian.Uint32(b[5:]))
+			n := binary.BigEndian.Uint32(b[9:])
+			salt, key = b[13:13+n], b[13+n:]
+		default:
+			return crypt.ErrUnknownScheme
+		}
+	}
+	if prf == nil || err != nil || len(salt) == 0 || len(key) == 0 {
+		return errMalformed
+	}
+	if subtle.ConstantTimeCompare(key, xpbkdf2.Key([]byte(password), salt, iterations, len(key), prf)) != 1 {
+		return crypt.ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+func TestCorpus(t *testing.T) {
+	cases := Corpus()
+	formats := map[string]int{}
+	for _, c := range cases {
+		formats[c.Format]++
+	}
+	for _, f := range []string{FormatPasslib, FormatDjango, FormatASPNet, FormatPHC} {
+		if formats[f] == 0 {
+			t.Errorf("no cases for format %s", f)
+		}
+	}
+
+	r := RunConformance(crypt.VerifierFunc(referenceVerify))
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Passed != len(cases) || len(r.Unsupported) != 0 {
+		t.Fatalf("expected %d passed, got %d passed and %d unsupported", len(cases), r.Passed, len(r.Unsupported))
+	}
+}
+
+func TestRunConformanceFormats(t *testing.T) {
+	r := RunConformance(crypt.VerifierFunc(referenceVerify), FormatDjango)
+	n := 0
+	for _, c := range Corpus() {
+		if c.Format == FormatDjango {
+			n++
+		}
+	}
+	if r.Passed != n {
+		t.Fatalf("expected %d Django cases, got %d", n, r.Passed)
+	}
+}
+
+func TestRunConformanceFailures(t *testing.T) {
+	acceptAll := crypt.VerifierFunc(func(password, hash string) error { return nil })
+	r := RunConformance(acceptAll, FormatPHC)
+	if len(r.Failures) == 0 || r.Passed == 0 {
+		t.Fatalf("expected wrong passwords to fail and right ones to pass, got %+v", r)
+	}
+	for _, f := range r.Failures {
+		if f.Case.Match || f.Err != nil {
+			t.Errorf("unexpected failure %+v", f)
+		}
+	}
+	if err := r.Err(); err == nil || !strings.Contains(err.Error(), "wrong password accepted") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	unknown := crypt.VerifierFunc(func(password, hash string) error { return crypt.ErrUnknownScheme })
+	r = RunConformance(unknown)
+	if len(r.Unsupported) != len(Corpus()) || r.Err() == nil {
+		t.Fatalf("expected every case unsupported and an error, got %d, %v", len(r.Unsupported), r.Err())
+	}
+
+	// Wrapped errors are recognised.
+	wrapping := crypt.VerifierFunc(func(password, hash string) error {
+		if strings.HasPrefix(hash, "pbkdf2_") {
+			return fmt.Errorf("django: %w", crypt.ErrUnknownScheme)
+		}
+		if err := referenceVerify(password, hash); err != nil {
+			return fmt.Errorf("verify: %w", err)
+		}
+		return nil
+	})
+	r = RunConformance(wrapping)
+	if len(r.Failures) != 0 || r.Err() != nil {
+		t.Fatalf("expected wrapped errors to be recognised, got %v", r.Err())
+	}
+	if r = RunConformance(wrapping, FormatDjango, FormatPHC); r.Err() == nil {
+		t.Fatal("expected an error when a requested format is unsupported")
+	}
+	if r = RunConformance(wrapping, "no-such-format"); r.Err() == nil {
+		t.Fatal("expected an error for a format with no cases")
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd || openbsd)
+
+package pbkdf2
+
+import "time"
+
+const threadCPUTimeSupported = false
+
+func threadCPUTime() (time.Duration, bool) {
+	return 0, false
+}
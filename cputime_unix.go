@@ -0,0 +1,20 @@
+//go:build linux || darwin || freebsd || openbsd
+
+package pbkdf2
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const threadCPUTimeSupported = true
+
+// threadCPUTime returns the CPU time consumed by the current OS thread.
+func threadCPUTime() (time.Duration, bool) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_THREAD_CPUTIME_ID, &ts); err != nil {
+		return 0, false
+	}
+	return time.Duration(ts.Nano()), true
+}
@@ -0,0 +1,165 @@
+// Package crypt is a single entry point for verifying passwords against hashes
+// in any of several formats, while always creating new hashes in the pbkdf2
+// package's format. It is intended for applications migrating from another
+// password hashing scheme:
+//
+//	err := crypt.Verify(password, stored)
+//	if err == nil && !crypt.IsNative(stored) {
+//		newHash, err := crypt.Hash(password, nil)
+//		// ... store newHash
+//	}
+//
+// The scheme of a hash is detected from its prefix. The following are
+// supported out of the box:
+//
+//	$pbkdf2-         this module's PBKDF2 hashes
+//	$2a$ $2b$ $2y$   bcrypt
+//	$argon2id$       Argon2id, as produced by github.com/alexedwards/argon2id
+//	$scrypt$         scrypt, in the passlib format $scrypt$ln=14,r=8,p=1$<salt>$<key>
+//	$8$              Cisco IOS type 8 secrets
+//	{PBKDF2}         Dovecot PBKDF2 password fields
+//
+// Further schemes can be added with Register. DetectScheme identifies a wider
+// range of formats, including ones that cannot be verified, without verifying
+// them.
+package crypt
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pganguli/pbkdf2"
+	"github.com/pganguli/pbkdf2/cisco"
+	"github.com/pganguli/pbkdf2/dovecot"
+)
+
+var (
+	// ErrMismatchedHashAndPassword is returned by Verify if the password does
+	// not match the hash. It is the same value as
+	// pbkdf2.ErrMismatchedHashAndPassword, and registered verifiers must
+	// return it on a mismatch.
+	ErrMismatchedHashAndPassword = pbkdf2.ErrMismatchedHashAndPassword
+
+	// ErrUnknownScheme is returned by Verify if no verifier is registered for
+	// the scheme of the hash.
+	ErrUnknownScheme = errors.New("crypt: unrecognised hash scheme")
+)
+
+// A Verifier verifies passwords against hashes in a particular scheme.
+type Verifier interface {
+	// Verify returns nil if password matches hash,
+	// ErrMismatchedHashAndPassword if it does not, and any other error if
+	// hash is malformed.
+	Verify(password, hash string) error
+}
+
+// VerifierFunc adapts an ordinary function to a Verifier.
+type VerifierFunc func(password, hash string) error
+
+// Verify calls f(password, hash).
+func (f VerifierFunc) Verify(password, hash string) error {
+	return f(password, hash)
+}
+
+const nativePrefix = "$pbkdf2-"
+
+var (
+	mu        sync.RWMutex
+	verifiers = map[string]Verifier{
+		nativePrefix:   VerifierFunc(pbkdf2.Verify),
+		"$2a$":         VerifierFunc(verifyBcrypt),
+		"$2b$":         VerifierFunc(verifyBcrypt),
+		"$2y$":         VerifierFunc(verifyBcrypt),
+		"$argon2id$":   VerifierFunc(verifyArgon2id),
+		"$scrypt$":     VerifierFunc(verifyScrypt),
+		cisco.Prefix:   VerifierFunc(cisco.Verify),
+		dovecot.Prefix: VerifierFunc(dovecot.Verify),
+	}
+	// prefixes holds the keys of verifiers, longest first, so that the most
+	// specific prefix wins.
+	prefixes = sortedPrefixes()
+)
+
+// Register makes a verifier available for hashes starting with prefix,
+// replacing any verifier previously registered for it, including the built-in
+// ones. When several prefixes match a hash, the longest is used. Register
+// panics if prefix is empty or v is nil.
+func Register(prefix string, v Verifier) {
+	if prefix == "" || v == nil {
+		panic("crypt: Register called with an empty prefix or nil verifier")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	verifiers[prefix] = v
+	prefixes = sortedPrefixes()
+}
+
+// Schemes returns the prefixes of all registered verifiers, sorted.
+func Schemes() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	schemes := append([]string(nil), prefixes...)
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Scheme returns the registered prefix that matches hash, and whether one was
+// found.
+func Scheme(hash string) (prefix string, ok bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, p := range prefixes {
+		if strings.HasPrefix(hash, p) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// Verify checks password against hash using the verifier registered for the
+// scheme of the hash. It returns nil if they match,
+// ErrMismatchedHashAndPassword if they do not, ErrUnknownScheme if the scheme
+// is not recognised, or the verifier's error if the hash is malformed.
+func Verify(password, hash string) error {
+	prefix, ok := Scheme(hash)
+	if !ok {
+		return ErrUnknownScheme
+	}
+
+	mu.RLock()
+	v := verifiers[prefix]
+	mu.RUnlock()
+
+	return v.Verify(password, hash)
+}
+
+// Hash returns a hash of password in the pbkdf2 package's format, using
+// params, or the pbkdf2 package-level default params if params is nil.
+func Hash(password string, params *pbkdf2.Params) (string, error) {
+	return pbkdf2.CreateHash(password, params)
+}
+
+// IsNative reports whether hash is in the format produced by Hash. Hashes in
+// any other scheme should be replaced the next time the password is verified.
+func IsNative(hash string) bool {
+	return strings.HasPrefix(hash, nativePrefix)
+}
+
+func sortedPrefixes() []string {
+	p := make([]string, 0, len(verifiers))
+	for prefix := range verifiers {
+		p = append(p, prefix)
+	}
+	sort.Slice(p, func(i, j int) bool {
+		if len(p[i]) != len(p[j]) {
+			return len(p[i]) > len(p[j])
+		}
+		return p[i] < p[j]
+	})
+	return p
+}
@@ -0,0 +1,132 @@
+package crypt
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// Generated with Python's hashlib.scrypt(b"password", salt=b"saltsaltsaltsalt", n=16, r=8, p=1, dklen=32).
+const scryptHash = "$scrypt$ln=4,r=8,p=1$c2FsdHNhbHRzYWx0c2FsdA$5f/Vi.XRWGUNGScbsma6KJ4zLFIke/NJsrvr7lQLAyA"
+
+// Generated with Python's hashlib.pbkdf2_hmac("sha256", b"password", b"abcdefghijklmn", 20000, 32),
+// encoded with the Cisco alphabet.
+const ciscoHash = "$8$abcdefghijklmn$aQ27Q9LKGHb3aAKidkVlmc76SAZ9mqCzhWwOdPnrCyg"
+
+// Generated with Python's hashlib.pbkdf2_hmac("sha1", b"password", b"Lp9xR2kQ./vT7mZa", 5000, 20).
+const dovecotHash = "{PBKDF2}$1$Lp9xR2kQ./vT7mZa$5000$d82706ab8e799b95bd9f902ed676b1e189a89947"
+
+func TestHash(t *testing.T) {
+	hash, err := Hash("password", &pbkdf2.Params{Iterations: 1000, SaltLength: 16, KeyLength: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsNative(hash) {
+		t.Errorf("expected %q to be native", hash)
+	}
+	if err := Verify("password", hash); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify("wrong", hash); err != ErrMismatchedHashAndPassword {
+		t.Fatalf("expected ErrMismatchedHashAndPassword, got %v", err)
+	}
+}
+
+func TestVerifySchemes(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt := []byte("0123456789abcdef")
+	argonHash := "$argon2id$v=19$m=64,t=1,p=1$" + base64.RawStdEncoding.EncodeToString(salt) + "$" +
+		base64.RawStdEncoding.EncodeToString(argon2.IDKey([]byte("password"), salt, 1, 64, 1, 32))
+	nativeHash, err := Hash("password", &pbkdf2.Params{Iterations: 1000, SaltLength: 16, KeyLength: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		hash   string
+		scheme string
+	}{
+		{string(bcryptHash), "$2a$"},
+		{argonHash, "$argon2id$"},
+		{nativeHash, "$pbkdf2-"},
+		{scryptHash, "$scrypt$"},
+		{ciscoHash, "$8$"},
+		{dovecotHash, "{PBKDF2}"},
+	}
+	for _, tt := range tests {
+		scheme, ok := Scheme(tt.hash)
+		if !ok || scheme != tt.scheme {
+			t.Errorf("Scheme(%q) = %q, %v, want %q", tt.hash, scheme, ok, tt.scheme)
+		}
+		if err := Verify("password", tt.hash); err != nil {
+			t.Errorf("Verify(%q): %v", tt.hash, err)
+		}
+		if err := Verify("wrong", tt.hash); err != ErrMismatchedHashAndPassword {
+			t.Errorf("Verify(wrong, %q): expected ErrMismatchedHashAndPassword, got %v", tt.hash, err)
+		}
+		if IsNative(tt.hash) != (tt.scheme == "$pbkdf2-") {
+			t.Errorf("IsNative(%q) = %v", tt.hash, IsNative(tt.hash))
+		}
+	}
+}
+
+func TestVerifyErrors(t *testing.T) {
+	if err := Verify("password", "$md5$abc"); err != ErrUnknownScheme {
+		t.Errorf("expected ErrUnknownScheme, got %v", err)
+	}
+	for _, hash := range []string{
+		"$scrypt$ln=4,r=8$c2FsdA$AA",
+		"$scrypt$r=8,ln=4,p=1$c2FsdA$AA",
+		"$scrypt$ln=4,r=8,p=1$c2F+dA$AA",
+		"$scrypt$ln=4,r=8,p=1$c2FsdA$",
+		"$scrypt$ln=40,r=8,p=1$c2FsdA$AA",
+	} {
+		if err := Verify("password", hash); err != errInvalidScrypt {
+			t.Errorf("Verify(%q): expected errInvalidScrypt, got %v", hash, err)
+		}
+	}
+	if err := Verify("password", "$pbkdf2-sha512$1000$AA"); !errors.Is(err, pbkdf2.ErrInvalidHash) {
+		t.Errorf("expected ErrInvalidHash, got %v", err)
+	}
+	if err := Verify("password", "$argon2id$v=19$m=16,t=1,p=1$c2FsdHNhbHQ$"); !errors.Is(err, pbkdf2.ErrInvalidHash) {
+		t.Errorf("expected ErrInvalidHash for an empty Argon2id key, got %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("$plain$", VerifierFunc(func(password, hash string) error {
+		if strings.TrimPrefix(hash, "$plain$") != password {
+			return ErrMismatchedHashAndPassword
+		}
+		return nil
+	}))
+	if err := Verify("password", "$plain$password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify("wrong", "$plain$password"); err != ErrMismatchedHashAndPassword {
+		t.Fatalf("expected ErrMismatchedHashAndPassword, got %v", err)
+	}
+
+	// The longest matching prefix wins.
+	Register("$plain$v2$", VerifierFunc(func(password, hash string) error { return nil }))
+	if scheme, _ := Scheme("$plain$v2$x"); scheme != "$plain$v2$" {
+		t.Fatalf("expected longest prefix to match, got %q", scheme)
+	}
+
+	found := false
+	for _, s := range Schemes() {
+		found = found || s == "$plain$"
+	}
+	if !found {
+		t.Error("expected Schemes to include registered prefix")
+	}
+}
@@ -0,0 +1,124 @@
+package crypt
+
+import (
+	"strings"
+)
+
+// Scheme families reported by DetectScheme.
+const (
+	SchemeUnknown  = "unknown"
+	SchemeNative   = "pbkdf2"   // this module's hashes, also produced by passlib's pbkdf2_sha256 and pbkdf2_sha512
+	SchemePasslib  = "passlib"  // passlib formats other than the native one
+	SchemeDjango   = "django"   // Django's password field, <algorithm>$<params>...
+	SchemeWerkzeug = "werkzeug" // Werkzeug's generate_password_hash, pbkdf2:<digest>:<iterations>$<salt>$<key>
+	SchemeBcrypt   = "bcrypt"
+	SchemeArgon2   = "argon2"
+	SchemeCisco    = "cisco"
+	SchemeDovecot  = "dovecot"
+	SchemeUnix     = "unix-crypt" // the MD5, SHA-256 and SHA-512 crypt(3) schemes, and yescrypt
+	SchemeOther    = "registered" // a prefix added with Register
+)
+
+// A Detection describes the scheme of a hash, as identified by DetectScheme.
+type Detection struct {
+	// Scheme is the family of formats the hash belongs to, one of the
+	// Scheme constants.
+	Scheme string
+
+	// Variant identifies the algorithm within the family, in the family's own
+	// spelling where it has one: "pbkdf2-sha512" or "pbkdf2" for the legacy
+	// SHA-1 format of the pbkdf2 package, "pbkdf2_sha256" for Django, "2b"
+	// for bcrypt, "argon2id", "sha512-crypt" and so on. For SchemeOther it is
+	// the registered prefix, and for SchemeUnknown it is empty.
+	Variant string
+
+	// Verifiable reports whether Verify supports the hash: whether a verifier
+	// is registered for it.
+	Verifiable bool
+}
+
+// detectPrefixes maps hash prefixes to their scheme and variant, for the
+// schemes whose variant is fixed by their prefix. Django hashes are matched
+// separately, since their algorithm names are not "$"-prefixed.
+var detectPrefixes = []struct {
+	prefix, scheme, variant string
+}{
+	{"$pbkdf2$", SchemePasslib, "pbkdf2"},
+	{"$scrypt$", SchemePasslib, "scrypt"},
+	{"$2a$", SchemeBcrypt, "2a"},
+	{"$2b$", SchemeBcrypt, "2b"},
+	{"$2x$", SchemeBcrypt, "2x"},
+	{"$2y$", SchemeBcrypt, "2y"},
+	{"$argon2id$", SchemeArgon2, "argon2id"},
+	{"$argon2i$", SchemeArgon2, "argon2i"},
+	{"$argon2d$", SchemeArgon2, "argon2d"},
+	{"$8$", SchemeCisco, "pbkdf2-sha256"},
+	{"$9$", SchemeCisco, "scrypt"},
+	{"$1$", SchemeUnix, "md5-crypt"},
+	{"$5$", SchemeUnix, "sha256-crypt"},
+	{"$6$", SchemeUnix, "sha512-crypt"},
+	{"$y$", SchemeUnix, "yescrypt"},
+}
+
+// djangoAlgorithms lists the algorithm names that start Django's password
+// hashes.
+var djangoAlgorithms = []string{
+	"pbkdf2_sha256", "pbkdf2_sha1", "argon2", "bcrypt_sha256", "bcrypt", "scrypt",
+}
+
+// DetectScheme identifies the scheme and variant of hash from its layout,
+// without verifying or fully decoding it, so that ingestion pipelines can
+// route records to the right verifier or migration. A hash that merely has
+// the right prefix is detected even if it is malformed.
+//
+// Besides the schemes Verify supports, it recognises Django, Werkzeug,
+// passlib, Argon2i and Argon2d, Cisco type 9 and the crypt(3) schemes, for
+// which Verifiable is false unless a verifier has been registered. Hashes
+// matching none of them, or only a prefix added with Register, are reported
+// as SchemeUnknown or SchemeOther.
+func DetectScheme(hash string) Detection {
+	prefix, verifiable := Scheme(hash)
+	d := detect(hash)
+	if d.Scheme == SchemeUnknown && verifiable {
+		d = Detection{Scheme: SchemeOther, Variant: prefix}
+	}
+	d.Verifiable = verifiable
+	return d
+}
+
+func detect(hash string) Detection {
+	if strings.HasPrefix(hash, nativePrefix) {
+		variant := hash[1:]
+		if i := strings.IndexByte(variant, '$'); i >= 0 {
+			variant = variant[:i]
+		}
+		return Detection{Scheme: SchemeNative, Variant: strings.ToLower(variant)}
+	}
+	for _, p := range detectPrefixes {
+		if strings.HasPrefix(hash, p.prefix) {
+			return Detection{Scheme: p.scheme, Variant: p.variant}
+		}
+	}
+	if len(hash) >= len("{PBKDF2}") && strings.EqualFold(hash[:len("{PBKDF2}")], "{PBKDF2}") {
+		return Detection{Scheme: SchemeDovecot, Variant: "pbkdf2-sha1"}
+	}
+
+	if rest := strings.TrimPrefix(hash, "pbkdf2:"); rest != hash {
+		// pbkdf2:sha256:600000$<salt>$<key>, where the iterations may be
+		// omitted.
+		if params, _, ok := strings.Cut(rest, "$"); ok {
+			digest, _, _ := strings.Cut(params, ":")
+			if digest != "" {
+				return Detection{Scheme: SchemeWerkzeug, Variant: "pbkdf2:" + digest}
+			}
+		}
+	}
+	if algorithm, _, ok := strings.Cut(hash, "$"); ok {
+		for _, a := range djangoAlgorithms {
+			if algorithm == a {
+				return Detection{Scheme: SchemeDjango, Variant: a}
+			}
+		}
+	}
+	return Detection{Scheme: SchemeUnknown}
+}
@@ -0,0 +1,43 @@
+package crypt
+
+import "testing"
+
+func TestDetectScheme(t *testing.T) {
+	tests := []struct {
+		hash string
+		want Detection
+	}{
+		{"$pbkdf2-sha512$1000$c2FsdA$a2V5", Detection{SchemeNative, "pbkdf2-sha512", true}},
+		{"$pbkdf2-SHA256$i=1000$c2FsdA$a2V5", Detection{SchemeNative, "pbkdf2-sha256", true}},
+		{"$pbkdf2$1000$c2FsdA$a2V5", Detection{SchemePasslib, "pbkdf2", false}},
+		{scryptHash, Detection{SchemePasslib, "scrypt", true}},
+		{"$2b$04$abcdefghijklmnopqrstuu", Detection{SchemeBcrypt, "2b", true}},
+		{"$2x$04$abcdefghijklmnopqrstuu", Detection{SchemeBcrypt, "2x", false}},
+		{"$argon2id$v=19$m=64,t=1,p=1$c2FsdA$a2V5", Detection{SchemeArgon2, "argon2id", true}},
+		{"$argon2i$v=19$m=64,t=1,p=1$c2FsdA$a2V5", Detection{SchemeArgon2, "argon2i", false}},
+		{ciscoHash, Detection{SchemeCisco, "pbkdf2-sha256", true}},
+		{"$9$abcdefghijklmn$key", Detection{SchemeCisco, "scrypt", false}},
+		{dovecotHash, Detection{SchemeDovecot, "pbkdf2-sha1", true}},
+		{"{pbkdf2}$1$salt$5000$key", Detection{SchemeDovecot, "pbkdf2-sha1", false}},
+		{"$6$rounds=5000$salt$key", Detection{SchemeUnix, "sha512-crypt", false}},
+		{"pbkdf2_sha256$600000$salt$a2V5", Detection{SchemeDjango, "pbkdf2_sha256", false}},
+		{"argon2$argon2id$v=19$m=102400,t=2,p=8$c2FsdA$a2V5", Detection{SchemeDjango, "argon2", false}},
+		{"bcrypt_sha256$$2b$12$abcdefghijklmnopqrstuu", Detection{SchemeDjango, "bcrypt_sha256", false}},
+		{"pbkdf2:sha256:600000$salt$6b6579", Detection{SchemeWerkzeug, "pbkdf2:sha256", false}},
+		{"pbkdf2:sha1$salt$6b6579", Detection{SchemeWerkzeug, "pbkdf2:sha1", false}},
+		{"pbkdf2:sha256", Detection{SchemeUnknown, "", false}},
+		{"5f4dcc3b5aa765d61d8327deb882cf99", Detection{SchemeUnknown, "", false}},
+		{"", Detection{SchemeUnknown, "", false}},
+	}
+	for _, tt := range tests {
+		if got := DetectScheme(tt.hash); got != tt.want {
+			t.Errorf("DetectScheme(%q) = %+v, want %+v", tt.hash, got, tt.want)
+		}
+	}
+
+	Register("$detect$", VerifierFunc(func(password, hash string) error { return nil }))
+	want := Detection{SchemeOther, "$detect$", true}
+	if got := DetectScheme("$detect$abc"); got != want {
+		t.Errorf("registered prefix: got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,88 @@
+package crypt
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/pganguli/pbkdf2/argon2id"
+)
+
+// errInvalidScrypt is returned for malformed $scrypt$ hashes.
+var errInvalidScrypt = errors.New("crypt: scrypt hash is not in the correct format")
+
+func verifyBcrypt(password, hash string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return ErrMismatchedHashAndPassword
+	}
+	return err
+}
+
+func verifyArgon2id(password, hash string) error {
+	match, err := argon2id.ComparePasswordAndHash(password, hash)
+	if err != nil {
+		return err
+	}
+	if !match {
+		return ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+// ab64 is passlib's "adapted base64" encoding: standard base64 with "." in
+// place of "+", and no padding.
+var ab64 = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789./").WithPadding(base64.NoPadding)
+
+// verifyScrypt verifies passlib's scrypt format:
+//
+//	$scrypt$ln=<log2 N>,r=<r>,p=<p>$<ab64 salt>$<ab64 key>
+func verifyScrypt(password, hash string) error {
+	vals := strings.Split(hash, "$")
+	if len(vals) != 5 || vals[0] != "" || vals[1] != "scrypt" {
+		return errInvalidScrypt
+	}
+
+	costs := strings.Split(vals[2], ",")
+	if len(costs) != 3 {
+		return errInvalidScrypt
+	}
+	var ln, r, p int
+	for i, c := range []struct {
+		name string
+		dst  *int
+	}{{"ln", &ln}, {"r", &r}, {"p", &p}} {
+		v := strings.TrimPrefix(costs[i], c.name+"=")
+		n, err := strconv.Atoi(v)
+		if v == costs[i] || err != nil || n <= 0 {
+			return errInvalidScrypt
+		}
+		*c.dst = n
+	}
+	if ln > 30 {
+		return errInvalidScrypt
+	}
+
+	salt, err := ab64.Strict().DecodeString(vals[3])
+	if err != nil {
+		return errInvalidScrypt
+	}
+	key, err := ab64.Strict().DecodeString(vals[4])
+	if err != nil || len(key) == 0 {
+		return errInvalidScrypt
+	}
+
+	otherKey, err := scrypt.Key([]byte(password), salt, 1<<uint(ln), r, p, len(key))
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(key, otherKey) != 1 {
+		return ErrMismatchedHashAndPassword
+	}
+	return nil
+}
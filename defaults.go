@@ -0,0 +1,31 @@
+package pbkdf2
+
+import "sync/atomic"
+
+var defaultParams atomic.Pointer[Params]
+
+func init() {
+	params := *DefaultParams
+	defaultParams.Store(&params)
+}
+
+// GetDefaultParams returns a copy of the package-level default params, which
+// CreateHash uses when called with nil params. It is safe for concurrent use.
+func GetDefaultParams() *Params {
+	params := *defaultParams.Load()
+	return &params
+}
+
+// SetDefaultParams replaces the package-level default params with a copy of
+// params. It returns ErrInvalidParams, leaving the default unchanged, if params
+// is nil or any of them is zero. It is safe for concurrent use, including with
+// in-flight calls to CreateHash, which use either the old or the new default in
+// full.
+func SetDefaultParams(params *Params) error {
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	p := *params
+	defaultParams.Store(&p)
+	return nil
+}
@@ -0,0 +1,72 @@
+package pbkdf2
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestDefaultParams(t *testing.T) {
+	if got := GetDefaultParams(); *got != *DefaultParams {
+		t.Fatalf("expected %#v got %#v", *DefaultParams, *got)
+	}
+
+	custom := &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32}
+	if err := SetDefaultParams(custom); err != nil {
+		t.Fatal(err)
+	}
+	defer SetDefaultParams(DefaultParams)
+
+	// The default is a copy, isolated from later changes to custom.
+	custom.Iterations = 1
+	if got := GetDefaultParams(); got.Iterations != 1000 {
+		t.Fatalf("expected 1000 iterations, got %d", got.Iterations)
+	}
+
+	if err := SetDefaultParams(&Params{Iterations: 1000, KeyLength: 32}); err != ErrInvalidParams {
+		t.Fatalf("expected ErrInvalidParams, got %v", err)
+	}
+	if err := SetDefaultParams(nil); err != ErrInvalidParams {
+		t.Fatalf("expected ErrInvalidParams for nil params, got %v", err)
+	}
+	if got := GetDefaultParams(); got.SaltLength != 16 {
+		t.Fatal("invalid params replaced the default")
+	}
+
+	hash, err := CreateHash("pa$$word", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, _, _, err := DecodeHash(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Iterations != 1000 || params.KeyLength != 32 {
+		t.Fatalf("expected the managed default to be used, got %#v", *params)
+	}
+}
+
+func TestDefaultParamsConcurrent(t *testing.T) {
+	defer SetDefaultParams(DefaultParams)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			SetDefaultParams(&Params{Iterations: uint32(1000 + i), SaltLength: 16, KeyLength: 32})
+		}(i)
+		go func() {
+			defer wg.Done()
+			if _, err := CreateHash("pa$$word", nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCreateHashInvalidParams(t *testing.T) {
+	if _, err := CreateHash("pa$$word", &Params{SaltLength: 16, KeyLength: 32}); err != ErrInvalidParams {
+		t.Fatalf("expected ErrInvalidParams, got %v", err)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build (!(js && wasm) || purego) && !openssl
+
+package pbkdf2
+
+func pbkdf2Key(password, salt []byte, iterations uint32, keyLength int, variant string) []byte {
+	return goKey(password, salt, iterations, keyLength, variant)
+}
+
+func backendName() string {
+	if purego {
+		return BackendPureGo
+	}
+	return BackendGo
+}
@@ -0,0 +1,118 @@
+//go:build js && wasm && !purego
+
+package pbkdf2
+
+import "syscall/js"
+
+// On js/wasm, key derivation is delegated to the WebCrypto API's
+// SubtleCrypto.deriveBits, which is orders of magnitude faster than PBKDF2
+// compiled to WebAssembly, and produces identical output. If SubtleCrypto is
+// unavailable (for example in an insecure browser context) or reports an
+// error, the pure Go implementation is used instead. WebCrypto only supports
+// the SHA-2 family, so all other variants, including any registered with
+// RegisterVariant, always use the pure Go implementation.
+//
+// SubtleCrypto is asynchronous, so deriving a key blocks the calling goroutine
+// until the returned promise settles. As with any blocking call on js/wasm,
+// this must not happen directly inside a callback created with js.FuncOf, as
+// the event loop cannot run until the callback returns. Start a goroutine from
+// the callback instead.
+
+// webCryptoHashes maps variants to the names of their hash functions in
+// WebCrypto.
+var webCryptoHashes = map[string]string{
+	"":            "SHA-512",
+	VariantSHA512: "SHA-512",
+	VariantSHA256: "SHA-256",
+}
+
+func pbkdf2Key(password, salt []byte, iterations uint32, keyLength int, variant string) []byte {
+	if hash, ok := webCryptoHashes[variant]; ok {
+		if key, ok := webCryptoKey(password, salt, iterations, keyLength, hash); ok {
+			return key
+		}
+	}
+	return goKey(password, salt, iterations, keyLength, variant)
+}
+
+func backendName() string {
+	if webCryptoSubtle().Truthy() {
+		return BackendWebCrypto
+	}
+	return BackendGo
+}
+
+func webCryptoKey(password, salt []byte, iterations uint32, keyLength int, hash string) (key []byte, ok bool) {
+	subtleCrypto := webCryptoSubtle()
+	if !subtleCrypto.Truthy() || iterations == 0 || keyLength <= 0 {
+		return nil, false
+	}
+
+	jsPassword := toUint8Array(password)
+	defer jsPassword.Call("fill", 0)
+
+	baseKey, ok := await(subtleCrypto.Call("importKey", "raw", jsPassword, "PBKDF2", false, []interface{}{"deriveBits"}))
+	if !ok {
+		return nil, false
+	}
+
+	algorithm := map[string]interface{}{
+		"name":       "PBKDF2",
+		"hash":       hash,
+		"salt":       toUint8Array(salt),
+		"iterations": iterations,
+	}
+	bits, ok := await(subtleCrypto.Call("deriveBits", algorithm, baseKey, keyLength*8))
+	if !ok {
+		return nil, false
+	}
+
+	jsKey := js.Global().Get("Uint8Array").New(bits)
+	defer jsKey.Call("fill", 0)
+
+	key = make([]byte, keyLength)
+	if js.CopyBytesToGo(key, jsKey) != keyLength {
+		wipe(key)
+		return nil, false
+	}
+	return key, true
+}
+
+func webCryptoSubtle() js.Value {
+	crypto := js.Global().Get("crypto")
+	if !crypto.Truthy() {
+		return js.Undefined()
+	}
+	return crypto.Get("subtle")
+}
+
+func toUint8Array(b []byte) js.Value {
+	a := js.Global().Get("Uint8Array").New(len(b))
+	js.CopyBytesToJS(a, b)
+	return a
+}
+
+// await blocks until promise settles, returning its value and whether it was
+// fulfilled rather than rejected.
+func await(promise js.Value) (js.Value, bool) {
+	type settled struct {
+		value     js.Value
+		fulfilled bool
+	}
+	done := make(chan settled, 1)
+
+	onFulfilled := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		done <- settled{value: args[0], fulfilled: true}
+		return nil
+	})
+	defer onFulfilled.Release()
+	onRejected := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		done <- settled{}
+		return nil
+	})
+	defer onRejected.Release()
+
+	promise.Call("then", onFulfilled, onRejected)
+	result := <-done
+	return result.value, result.fulfilled
+}
@@ -0,0 +1,23 @@
+//go:build js && wasm && !purego
+
+package pbkdf2
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWebCryptoMatchesGo(t *testing.T) {
+	password := []byte("pa$$word")
+	salt := []byte("yvu2ZftdlhcP4Tbp")
+
+	for variant, hash := range webCryptoHashes {
+		key, ok := webCryptoKey(password, salt, 1000, 64, hash)
+		if !ok {
+			t.Skip("SubtleCrypto is not available")
+		}
+		if want := goKey(password, salt, 1000, 64, variant); !bytes.Equal(key, want) {
+			t.Fatalf("%s: expected %x got %x", hash, want, key)
+		}
+	}
+}
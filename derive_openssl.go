@@ -0,0 +1,90 @@
+//go:build openssl && cgo && !purego && !asm
+
+package pbkdf2
+
+/*
+#cgo LDFLAGS: -lcrypto
+#include <stdlib.h>
+#include <string.h>
+#include <openssl/evp.h>
+
+static int pbkdf2_key(const char *pass, int passlen, const unsigned char *salt,
+		int saltlen, int iter, const char *digest, int keylen, unsigned char *out) {
+	const EVP_MD *md = EVP_get_digestbyname(digest);
+	if (md == NULL) {
+		return -1;
+	}
+	return PKCS5_PBKDF2_HMAC(pass, passlen, salt, saltlen, iter, md, keylen, out);
+}
+*/
+import "C"
+
+import (
+	"math"
+	"unsafe"
+)
+
+// opensslDigests maps the built-in variants to the names of the OpenSSL
+// digests implementing them.
+var opensslDigests = map[string]string{
+	"":                "SHA512",
+	VariantSHA512:     "SHA512",
+	VariantSHA256:     "SHA256",
+	VariantSHA512_256: "SHA512-256",
+	VariantSHA3_512:   "SHA3-512",
+	VariantBLAKE2b:    "BLAKE2b512",
+}
+
+func pbkdf2Key(password, salt []byte, iterations uint32, keyLength int, variant string) []byte {
+	digest, ok := opensslDigests[variant]
+	if !ok || keyLength == 0 || iterations > math.MaxInt32 || overflowsCInt(len(password), len(salt), keyLength) {
+		// Registered variants, and sizes OpenSSL's int parameters cannot
+		// represent, fall back to the Go implementation.
+		return goKey(password, salt, iterations, keyLength, variant)
+	}
+	if key, ok := opensslKey(password, salt, iterations, keyLength, digest); ok {
+		return key
+	}
+	// Older and FIPS builds of OpenSSL may lack some of the digests, such
+	// as SHA3-512 or BLAKE2b512.
+	return goKey(password, salt, iterations, keyLength, variant)
+}
+
+// opensslKey derives a key with OpenSSL's PKCS5_PBKDF2_HMAC and the named
+// digest, reporting false if OpenSSL does not provide the digest.
+func opensslKey(password, salt []byte, iterations uint32, keyLength int, digest string) ([]byte, bool) {
+	// C.CBytes never returns nil, even for empty input, which OpenSSL would
+	// otherwise reject.
+	pw := C.CBytes(password)
+	defer C.free(pw)
+	s := C.CBytes(salt)
+	defer C.free(s)
+	name := C.CString(digest)
+	defer C.free(unsafe.Pointer(name))
+
+	// The copy of the password in C memory is wiped before it is freed.
+	defer C.memset(pw, 0, C.size_t(len(password)))
+
+	key := make([]byte, keyLength)
+	switch C.pbkdf2_key((*C.char)(pw), C.int(len(password)), (*C.uchar)(s), C.int(len(salt)),
+		C.int(iterations), name, C.int(keyLength), (*C.uchar)(unsafe.Pointer(&key[0]))) {
+	case 1:
+		return key, true
+	case -1:
+		return nil, false
+	}
+	panic("pbkdf2: OpenSSL PKCS5_PBKDF2_HMAC failed for " + digest)
+}
+
+func overflowsCInt(values ...int) bool {
+	for _, v := range values {
+		if v > math.MaxInt32 {
+			return true
+		}
+	}
+	return false
+}
+
+func backendName() string {
+	return BackendOpenSSL
+}
@@ -0,0 +1,27 @@
+//go:build openssl && cgo && !purego && !asm
+
+package pbkdf2
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOpenSSLKey(t *testing.T) {
+	for variant := range opensslDigests {
+		for _, password := range []string{"", "password"} {
+			salt := []byte("somesalt")
+			got := pbkdf2Key([]byte(password), salt, 1000, 64, variant)
+			want := goKey([]byte(password), salt, 1000, 64, variant)
+			if !bytes.Equal(got, want) {
+				t.Errorf("%q, password %q: got %x, want %x", variant, password, got, want)
+			}
+		}
+	}
+}
+
+func TestOpenSSLMissingDigest(t *testing.T) {
+	if _, ok := opensslKey([]byte("password"), []byte("somesalt"), 1000, 64, "NO-SUCH-DIGEST"); ok {
+		t.Fatal("expected an unknown digest to be reported")
+	}
+}
@@ -0,0 +1,138 @@
+// Package dovecot creates and verifies passwords in the PBKDF2 scheme of the
+// Dovecot mail server, so that mail-platform provisioning can generate and
+// audit userdb and passdb password fields:
+//
+//	{PBKDF2}$1$<salt>$<rounds>$<key>
+//
+// The scheme is PBKDF2-HMAC-SHA1 with a 20-byte key, encoded in lowercase
+// hexadecimal. Dovecot generates 16-character salts from the crypt alphabet,
+// "./0-9A-Za-z", and uses them as they appear rather than decoded; it
+// defaults to 5000 rounds.
+package dovecot
+
+import (
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/hex"
+	"io"
+	"strconv"
+	"strings"
+
+	xpbkdf2 "golang.org/x/crypto/pbkdf2"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// Parameters of the PBKDF2 scheme.
+const (
+	// Prefix is the scheme prefix of password fields. It is optional where the
+	// default scheme of the passdb is PBKDF2.
+	Prefix = "{PBKDF2}"
+
+	DefaultRounds = 5000
+	SaltLength    = 16
+	KeyLength     = sha1.Size
+)
+
+// version is the format version following the scheme prefix.
+const version = "$1$"
+
+const alphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// ErrInvalidHash is returned if a password field is not in the PBKDF2 scheme.
+// It is the same value as pbkdf2.ErrInvalidHash.
+var ErrInvalidHash = pbkdf2.ErrInvalidHash
+
+// A Password is a decoded PBKDF2 password field.
+type Password struct {
+	// Salt is the salt as it appears in the field.
+	Salt string
+
+	Rounds uint32
+
+	// Key is the derived key.
+	Key []byte
+}
+
+// Parse decodes a PBKDF2 password field, with or without the scheme prefix.
+// The prefix is matched case-insensitively, as Dovecot does.
+func Parse(field string) (*Password, error) {
+	if len(field) >= len(Prefix) && strings.EqualFold(field[:len(Prefix)], Prefix) {
+		field = field[len(Prefix):]
+	}
+	rest := strings.TrimPrefix(field, version)
+	if rest == field {
+		return nil, ErrInvalidHash
+	}
+	parts := strings.Split(rest, "$")
+	if len(parts) != 3 || parts[0] == "" {
+		return nil, ErrInvalidHash
+	}
+	rounds, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil || rounds == 0 {
+		return nil, ErrInvalidHash
+	}
+	key, err := hex.DecodeString(parts[2])
+	if err != nil || len(key) != KeyLength {
+		return nil, ErrInvalidHash
+	}
+	return &Password{Salt: parts[0], Rounds: uint32(rounds), Key: key}, nil
+}
+
+// String returns the encoded password field, with the scheme prefix.
+func (p *Password) String() string {
+	return Prefix + version + p.Salt + "$" + strconv.FormatUint(uint64(p.Rounds), 10) + "$" + hex.EncodeToString(p.Key)
+}
+
+// Generate returns a PBKDF2 password field for password with a random salt.
+// If rounds is zero, DefaultRounds is used.
+func Generate(password string, rounds uint32) (string, error) {
+	if rounds == 0 {
+		rounds = DefaultRounds
+	}
+	b := make([]byte, SaltLength)
+	if _, err := io.ReadFull(pbkdf2.Rand, b); err != nil {
+		return "", err
+	}
+	// len(alphabet) divides 256, so this is uniform.
+	for i := range b {
+		b[i] = alphabet[b[i]%byte(len(alphabet))]
+	}
+	p := &Password{Salt: string(b), Rounds: rounds, Key: derive(password, string(b), rounds)}
+	return p.String(), nil
+}
+
+// Verify returns nil if password matches field,
+// pbkdf2.ErrMismatchedHashAndPassword if it does not, and ErrInvalidHash if
+// field is not in the PBKDF2 scheme. The crypt package uses it for fields
+// with the {PBKDF2} prefix.
+func Verify(password, field string) error {
+	p, err := Parse(field)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(derive(password, p.Salt, p.Rounds), p.Key) != 1 {
+		return pbkdf2.ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+// ToHash converts the password field into a pbkdf2 hash in the legacy
+// $pbkdf2$ format, which verifies the same passwords with a pbkdf2.Hasher that
+// has AllowLegacySHA1 set.
+func (p *Password) ToHash() *pbkdf2.Hash {
+	return &pbkdf2.Hash{
+		Params: pbkdf2.Params{
+			Iterations: p.Rounds,
+			SaltLength: uint32(len(p.Salt)),
+			KeyLength:  KeyLength,
+			Variant:    pbkdf2.VariantLegacySHA1,
+		},
+		Salt: []byte(p.Salt),
+		Key:  append([]byte(nil), p.Key...),
+	}
+}
+
+func derive(password, salt string, rounds uint32) []byte {
+	return xpbkdf2.Key([]byte(password), []byte(salt), int(rounds), KeyLength, sha1.New)
+}
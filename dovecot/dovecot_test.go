@@ -0,0 +1,93 @@
+package dovecot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// Generated with Python's hashlib.pbkdf2_hmac("sha1", b"password", b"Lp9xR2kQ./vT7mZa", 5000, 20).
+const testField = "{PBKDF2}$1$Lp9xR2kQ./vT7mZa$5000$d82706ab8e799b95bd9f902ed676b1e189a89947"
+
+func TestVerify(t *testing.T) {
+	for _, field := range []string{
+		testField,
+		strings.TrimPrefix(testField, Prefix),
+		"{pbkdf2}" + strings.TrimPrefix(testField, Prefix),
+	} {
+		if err := Verify("password", field); err != nil {
+			t.Errorf("Verify(%q): %v", field, err)
+		}
+		if err := Verify("wrong", field); err != pbkdf2.ErrMismatchedHashAndPassword {
+			t.Errorf("Verify(wrong, %q): expected ErrMismatchedHashAndPassword, got %v", field, err)
+		}
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	p, err := Parse(testField)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Salt != "Lp9xR2kQ./vT7mZa" || p.Rounds != 5000 {
+		t.Errorf("parsed %+v", p)
+	}
+	if got := p.String(); got != testField {
+		t.Errorf("String() = %q, want %q", got, testField)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, field := range []string{
+		"",
+		"{PBKDF2}",
+		"{PBKDF2}$2$salt$5000$d82706ab8e799b95bd9f902ed676b1e189a89947",
+		"{PBKDF2}$1$$5000$d82706ab8e799b95bd9f902ed676b1e189a89947",
+		"{PBKDF2}$1$salt$0$d82706ab8e799b95bd9f902ed676b1e189a89947",
+		"{PBKDF2}$1$salt$x$d82706ab8e799b95bd9f902ed676b1e189a89947",
+		"{PBKDF2}$1$salt$5000$d82706ab8e799b95bd9f902ed676b1e189a899",
+		"{PBKDF2}$1$salt$5000$d82706ab8e799b95bd9f902ed676b1e189a89947$",
+		"{SSHA}$1$salt$5000$d82706ab8e799b95bd9f902ed676b1e189a89947",
+	} {
+		if _, err := Parse(field); err != ErrInvalidHash {
+			t.Errorf("Parse(%q): expected ErrInvalidHash, got %v", field, err)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	field, err := Generate("password", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := Parse(field)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Salt) != SaltLength || strings.Trim(p.Salt, alphabet) != "" || p.Rounds != 1000 {
+		t.Errorf("generated %q", field)
+	}
+	if err := Verify("password", field); err != nil {
+		t.Fatal(err)
+	}
+
+	field, err = Generate("password", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := Parse(field); p == nil || p.Rounds != DefaultRounds {
+		t.Errorf("Generate with zero rounds: %q", field)
+	}
+}
+
+func TestToHash(t *testing.T) {
+	p, err := Parse(testField)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &pbkdf2.Hasher{AllowLegacySHA1: true}
+	if err := h.Verify("password", p.ToHash().String()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,195 @@
+package pbkdf2
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrEntropy is matched, with errors.Is, by the *EntropyError returned when
+// the entropy source fails a health test.
+var ErrEntropy = errors.New("pbkdf2: entropy source failed a health test")
+
+// EntropyError describes a failed health test of the entropy source.
+type EntropyError struct {
+	// Test is the test that failed: "repetition" if a byte value repeated
+	// too many times in a row, "proportion" if a byte value occurred too
+	// often within a sample, or "repeated-output" if the source returned the
+	// same bytes twice in a row.
+	Test string
+}
+
+func (e *EntropyError) Error() string {
+	return ErrEntropy.Error() + ": " + e.Test
+}
+
+// Is reports whether target is ErrEntropy.
+func (e *EntropyError) Is(target error) bool {
+	return target == ErrEntropy
+}
+
+// Health test parameters, after the repetition count and adaptive proportion
+// tests of NIST SP 800-90B, section 4.4, for a source claiming full entropy
+// per byte, with a false positive rate of about 2^-40 per sample.
+const (
+	entropySampleSize   = 4096
+	repetitionCutoff    = 6
+	proportionWindow    = 512
+	proportionCutoff    = 20
+	repeatedOutputBytes = 8
+)
+
+type entropySource struct {
+	r io.Reader
+
+	startup    sync.Once
+	startupErr error
+
+	mu   sync.Mutex
+	last []byte
+}
+
+var (
+	entropy         atomic.Pointer[entropySource]
+	strictEntropy   atomic.Bool
+	entropyFailures atomic.Uint64
+)
+
+func init() {
+	entropy.Store(&entropySource{r: rand.Reader})
+}
+
+// SetEntropySource replaces the source of the random salts and nonces
+// generated by this package and read from Rand, crypto/rand.Reader by
+// default, such as with a hardware RNG or a DRBG required by a certification.
+// A nil r restores crypto/rand.Reader. The source must be safe for concurrent
+// use.
+//
+// The source is health tested: before its first use, on a sample of 4096
+// bytes read from it; and on every read, which fails if a byte value repeats
+// more than a few times in a row, or the output repeats the previous read.
+// Failures are counted by EntropyFailures, and with SetStrictEntropy make
+// hash creation fail. It is safe for concurrent use.
+func SetEntropySource(r io.Reader) {
+	if r == nil {
+		r = rand.Reader
+	}
+	entropy.Store(&entropySource{r: r})
+}
+
+// SetStrictEntropy sets whether a failed health test of the entropy source
+// makes functions that generate salts or nonces, such as CreateHash, fail
+// closed with an *EntropyError, rather than proceeding with bytes that may
+// be predictable. It is off by default. Once the startup test has failed,
+// every call fails until SetEntropySource is called again.
+func SetStrictEntropy(strict bool) {
+	strictEntropy.Store(strict)
+}
+
+// EntropyFailures returns the number of health tests the entropy source has
+// failed since the process started, whether or not strict mode is on, so that
+// failures can be monitored.
+func EntropyFailures() uint64 {
+	return entropyFailures.Load()
+}
+
+// CheckEntropy reads a sample from the entropy source and runs the startup
+// health tests on it, returning an *EntropyError if they fail, or the source's
+// error if it cannot be read. It is intended for readiness checks; the same
+// tests run automatically before the source is first used.
+func CheckEntropy() error {
+	return entropy.Load().selfTest()
+}
+
+func (s *entropySource) selfTest() error {
+	sample := make([]byte, entropySampleSize)
+	if _, err := io.ReadFull(s.r, sample); err != nil {
+		return err
+	}
+	if err := repetitionTest(sample); err != nil {
+		return err
+	}
+	for w := sample; len(w) >= proportionWindow; w = w[proportionWindow:] {
+		if bytes.Count(w[:proportionWindow], w[:1]) >= proportionCutoff {
+			return &EntropyError{Test: "proportion"}
+		}
+	}
+	return nil
+}
+
+// read fills b from the source, after the startup test has passed, and runs
+// the continuous tests on it.
+func (s *entropySource) read(b []byte) error {
+	strict := strictEntropy.Load()
+	s.startup.Do(func() {
+		if s.startupErr = s.selfTest(); s.startupErr != nil {
+			entropyFailures.Add(1)
+		}
+	})
+	if s.startupErr != nil && strict {
+		return s.startupErr
+	}
+
+	if _, err := io.ReadFull(s.r, b); err != nil {
+		return err
+	}
+	if err := s.continuousTest(b); err != nil {
+		entropyFailures.Add(1)
+		if strict {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *entropySource) continuousTest(b []byte) error {
+	if err := repetitionTest(b); err != nil {
+		return err
+	}
+	if len(b) < repeatedOutputBytes {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	repeated := bytes.Equal(b, s.last)
+	s.last = append(s.last[:0], b...)
+	if repeated {
+		return &EntropyError{Test: "repeated-output"}
+	}
+	return nil
+}
+
+func repetitionTest(b []byte) error {
+	run := 1
+	for i := 1; i < len(b); i++ {
+		if b[i] != b[i-1] {
+			run = 1
+		} else if run++; run >= repetitionCutoff {
+			return &EntropyError{Test: "repetition"}
+		}
+	}
+	return nil
+}
+
+// Rand reads from the entropy source set with SetEntropySource, subject to
+// its health tests and to SetStrictEntropy, so that packages built on this
+// one generate their salts and nonces as CreateHash does. Each Read fills
+// its buffer entirely or returns an error.
+var Rand io.Reader = randReader{}
+
+type randReader struct{}
+
+func (randReader) Read(b []byte) (int, error) {
+	if err := readEntropy(b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// readEntropy fills b from the configured entropy source.
+func readEntropy(b []byte) error {
+	return entropy.Load().read(b)
+}
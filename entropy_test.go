@@ -0,0 +1,122 @@
+package pbkdf2
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+// readerFunc adapts a function to an io.Reader.
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
+
+func withEntropySource(t *testing.T, r readerFunc, strict bool) {
+	t.Helper()
+	SetEntropySource(r)
+	SetStrictEntropy(strict)
+	t.Cleanup(func() {
+		SetEntropySource(nil)
+		SetStrictEntropy(false)
+	})
+}
+
+var testParams = &Params{Iterations: 1, SaltLength: 16, KeyLength: 16}
+
+func TestCheckEntropy(t *testing.T) {
+	if err := CheckEntropy(); err != nil {
+		t.Fatalf("crypto/rand failed health tests: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		r    readerFunc
+		test string
+	}{
+		{"stuck", func(p []byte) (int, error) {
+			for i := range p {
+				p[i] = 0x42
+			}
+			return len(p), nil
+		}, "repetition"},
+		{"biased", func(p []byte) (int, error) {
+			// Cycles through 16 values, so no value repeats in a row
+			// but each occurs 32 times in every window.
+			for i := range p {
+				p[i] = byte(i % 16)
+			}
+			return len(p), nil
+		}, "proportion"},
+	}
+	for _, tt := range tests {
+		withEntropySource(t, tt.r, false)
+		var entropyErr *EntropyError
+		err := CheckEntropy()
+		if !errors.As(err, &entropyErr) || entropyErr.Test != tt.test || !errors.Is(err, ErrEntropy) {
+			t.Errorf("%s: expected %s failure, got %v", tt.name, tt.test, err)
+		}
+	}
+}
+
+func TestStrictEntropy(t *testing.T) {
+	zeros := func(p []byte) (int, error) {
+		for i := range p {
+			p[i] = 0
+		}
+		return len(p), nil
+	}
+
+	withEntropySource(t, zeros, false)
+	before := EntropyFailures()
+	if _, err := CreateHash("password", testParams); err != nil {
+		t.Fatalf("expected lenient mode to create a hash, got %v", err)
+	}
+	if EntropyFailures() <= before {
+		t.Error("expected failures to be counted")
+	}
+
+	SetStrictEntropy(true)
+	if _, err := CreateHash("password", testParams); !errors.Is(err, ErrEntropy) {
+		t.Fatalf("expected ErrEntropy in strict mode, got %v", err)
+	}
+	if _, _, err := DeriveKeys(SecureBytesFromString("password"), nil, testParams, EncryptionAndMAC...); !errors.Is(err, ErrEntropy) {
+		t.Fatalf("expected ErrEntropy from DeriveKeys, got %v", err)
+	}
+	if _, err := GenerateToken(); !errors.Is(err, ErrEntropy) {
+		t.Fatalf("expected ErrEntropy from GenerateToken, got %v", err)
+	}
+	if n, err := Rand.Read(make([]byte, 16)); n != 0 || !errors.Is(err, ErrEntropy) {
+		t.Fatalf("expected ErrEntropy from Rand, got %d, %v", n, err)
+	}
+}
+
+func TestStrictEntropyRepeatedOutput(t *testing.T) {
+	// A source that passes the startup test, then returns the same output
+	// for every salt.
+	block := make([]byte, 16)
+	if _, err := rand.Read(block); err != nil {
+		t.Fatal(err)
+	}
+	withEntropySource(t, func(p []byte) (int, error) {
+		if len(p) == len(block) {
+			return copy(p, block), nil
+		}
+		return rand.Read(p)
+	}, true)
+
+	if _, err := CreateHash("password", testParams); err != nil {
+		t.Fatalf("first hash: %v", err)
+	}
+	_, err := CreateHash("password", testParams)
+	var entropyErr *EntropyError
+	if !errors.As(err, &entropyErr) || entropyErr.Test != "repeated-output" {
+		t.Fatalf("expected repeated-output failure, got %v", err)
+	}
+
+	SetEntropySource(nil)
+	for i := 0; i < 2; i++ {
+		if _, err := CreateHash("password", testParams); err != nil {
+			t.Fatalf("crypto/rand in strict mode: %v", err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package pbkdf2
+
+import (
+	"bytes"
+	"crypto/subtle"
+)
+
+// HashesEquivalent reports whether two encoded hashes were created with the
+// same variant, iterations and salt, and encode the same key, so that
+// either can stand in for the other. It is intended for deduplicating
+// credentials and making migrations idempotent.
+//
+// Differences in encoding are tolerated: padded or unpadded base64, "." in
+// place of "+" as used by passlib, the PHC layout with the iterations given
+// as "i=<iterations>", variant names in any case, and legacy SHA-1 hashes in
+// the $pbkdf2$ format. Metadata that annotates a hash rather than changing
+// what it verifies, such as the profile, is ignored; the namespace,
+// normalization and stages change the derivation, and must match. Callers
+// comparing peppered hashes, whose metadata determines how the key is
+// interpreted, should compare it separately.
+//
+// It returns an error if either hash cannot be decoded.
+func HashesEquivalent(h1, h2 string) (bool, error) {
+	a, err := decodeAnyLayout(h1)
+	if err != nil {
+		return false, err
+	}
+	defer wipe(a.Salt)
+	defer wipe(a.Key)
+	b, err := decodeAnyLayout(h2)
+	if err != nil {
+		return false, err
+	}
+	defer wipe(b.Salt)
+	defer wipe(b.Key)
+
+	return a.Variant() == b.Variant() &&
+		transformsOf(a.Metadata).derivation() == transformsOf(b.Metadata).derivation() &&
+		a.Params.Iterations == b.Params.Iterations &&
+		bytes.Equal(a.Salt, b.Salt) &&
+		subtle.ConstantTimeCompare(a.Key, b.Key) == 1, nil
+}
@@ -0,0 +1,45 @@
+package pbkdf2
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHashesEquivalent(t *testing.T) {
+	const base = "$pbkdf2-sha512$1000$MDEyMzQ1Njc4OWFiY2RlZg$38DzhdBT7fPaUGBlsh42VTuuKSFAIYGZJ7l6feCDLIk"
+
+	for _, tc := range []struct {
+		other string
+		want  bool
+	}{
+		{base, true},
+		{"$pbkdf2-sha512$1000$MDEyMzQ1Njc4OWFiY2RlZg==$38DzhdBT7fPaUGBlsh42VTuuKSFAIYGZJ7l6feCDLIk=", true},
+		{"$pbkdf2-sha512$i=1000$MDEyMzQ1Njc4OWFiY2RlZg$38DzhdBT7fPaUGBlsh42VTuuKSFAIYGZJ7l6feCDLIk", true},
+		{"$pbkdf2-sha512$tenant=acme$1000$MDEyMzQ1Njc4OWFiY2RlZg$38DzhdBT7fPaUGBlsh42VTuuKSFAIYGZJ7l6feCDLIk", true},
+		{"$pbkdf2-sha512$profile=admin$1000$MDEyMzQ1Njc4OWFiY2RlZg$38DzhdBT7fPaUGBlsh42VTuuKSFAIYGZJ7l6feCDLIk", true},
+		{"$pbkdf2-sha512$ns=acme$1000$MDEyMzQ1Njc4OWFiY2RlZg$38DzhdBT7fPaUGBlsh42VTuuKSFAIYGZJ7l6feCDLIk", false},
+		{"$pbkdf2-sha512$1001$MDEyMzQ1Njc4OWFiY2RlZg$38DzhdBT7fPaUGBlsh42VTuuKSFAIYGZJ7l6feCDLIk", false},
+		{"$pbkdf2-sha256$1000$MDEyMzQ1Njc4OWFiY2RlZg$38DzhdBT7fPaUGBlsh42VTuuKSFAIYGZJ7l6feCDLIk", false},
+		{"$pbkdf2-sha512$1000$MDEyMzQ1Njc4OWFiY2RlZw$38DzhdBT7fPaUGBlsh42VTuuKSFAIYGZJ7l6feCDLIk", false},
+		{"$pbkdf2-sha512$1000$MDEyMzQ1Njc4OWFiY2RlZg$38DzhdBT7fPaUGBlsh42VTuuKSFAIYGZJ7l6feCDLIg", false},
+		{"$pbkdf2$1000$MDEyMzQ1Njc4OWFiY2RlZg$38DzhdBT7fPaUGBlsh42VTuuKSFAIYGZJ7l6feCDLIk", false},
+	} {
+		got, err := HashesEquivalent(base, tc.other)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.other, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.other, got, tc.want)
+		}
+	}
+
+	const legacy = "$pbkdf2$1000$Ln+vLn+vLn+vLn+vLn+vLg$38DzhdBT7fPaUGBlsh42VTuuKSFAIYGZJ7l6feCDLIk"
+	if ok, err := HashesEquivalent(legacy, strings.ReplaceAll(legacy, "+", ".")); err != nil || !ok {
+		t.Errorf("expected ab64-encoded legacy hashes to be equivalent, got %v, %v", ok, err)
+	}
+
+	if _, err := HashesEquivalent(base, "$pbkdf2-sha512$1000$MDEy"); !errors.Is(err, ErrInvalidHash) {
+		t.Errorf("expected ErrInvalidHash, got %v", err)
+	}
+}
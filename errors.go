@@ -0,0 +1,37 @@
+package pbkdf2
+
+// DecodeError describes why a hash could not be decoded. It matches
+// ErrInvalidHash with errors.Is, and unwraps to the underlying cause, if any,
+// so that it can be logged or inspected with errors.As.
+type DecodeError struct {
+	// Field is the part of the hash that could not be decoded: "format",
+	// "metadata", "iterations", "salt" or "key".
+	Field string
+
+	// Err is the underlying cause, such as a *strconv.NumError or a
+	// base64.CorruptInputError. It is nil if the field was rejected by this
+	// package's own checks.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	msg := ErrInvalidHash.Error() + ": invalid " + e.Field
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying cause.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrInvalidHash.
+func (e *DecodeError) Is(target error) bool {
+	return target == ErrInvalidHash
+}
+
+func decodeError(field string, cause error) error {
+	return &DecodeError{Field: field, Err: cause}
+}
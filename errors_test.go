@@ -0,0 +1,63 @@
+package pbkdf2
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestDecodeError(t *testing.T) {
+	tests := []struct {
+		hash  string
+		field string
+		cause interface{}
+	}{
+		{"$pbkdf2-sha512$210000$KuwdBW88vV7YiVGWsMmc8g", "format", nil},
+		{"$pbkdf2-sha512$a$1$AA$AA", "metadata", nil},
+		{"$pbkdf2-sha512$4294967296$AA$AA", "iterations", nil},
+		{"$pbkdf2-sha512$1$A-$AA", "salt", new(base64.CorruptInputError)},
+		{"$pbkdf2-sha512$1$AA$AB", "key", new(base64.CorruptInputError)},
+	}
+
+	for _, test := range tests {
+		_, _, _, err := DecodeHash(test.hash)
+		if !errors.Is(err, ErrInvalidHash) {
+			t.Fatalf("%q: expected ErrInvalidHash, got %v", test.hash, err)
+		}
+
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Fatalf("%q: expected *DecodeError, got %T", test.hash, err)
+		}
+		if decodeErr.Field != test.field {
+			t.Errorf("%q: expected field %q, got %q", test.hash, test.field, decodeErr.Field)
+		}
+		if test.cause != nil && !errors.As(err, test.cause) {
+			t.Errorf("%q: expected cause %T, got %v", test.hash, test.cause, decodeErr.Err)
+		}
+	}
+
+	// Counts beyond MaxIterations are reported as overflow, however large.
+	for _, iterations := range []string{"4294967296", "18446744073709551616", "99999999999999999999999999999999"} {
+		_, _, _, err := DecodeHash("$pbkdf2-sha512$" + iterations + "$AA$AA")
+		if !errors.Is(err, ErrIterationsOverflow) || !errors.Is(err, ErrInvalidHash) {
+			t.Errorf("%s: expected ErrIterationsOverflow, got %v", iterations, err)
+		}
+	}
+	if _, _, _, err := DecodeHash("$pbkdf2-sha512$4294967295$AA$AA"); err != nil {
+		t.Errorf("expected MaxIterations to decode, got %v", err)
+	}
+	for _, iterations := range []string{"-1", "+1", "0x10", "1e6", " 1"} {
+		_, _, _, err := DecodeHash("$pbkdf2-sha512$" + iterations + "$AA$AA")
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) || decodeErr.Field != "iterations" || errors.Is(err, ErrIterationsOverflow) {
+			t.Errorf("%q: expected invalid iterations, got %v", iterations, err)
+		}
+	}
+
+	// Unsupported variants are reported as such, not as malformed hashes.
+	_, _, _, err := DecodeHash("$pbkdf2-md5$1$AA$AA")
+	if err != ErrIncompatibleVariant {
+		t.Fatalf("expected ErrIncompatibleVariant, got %v", err)
+	}
+}
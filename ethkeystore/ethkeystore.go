@@ -0,0 +1,303 @@
+// Package ethkeystore reads and writes Ethereum keystore files, version 3 of
+// the Web3 Secret Storage format, that use the pbkdf2 KDF, so that wallet
+// tooling in Go can rely on this package for the PBKDF2 path.
+//
+// A keystore's crypto section holds the KDF parameters, a private key
+// encrypted with AES-128-CTR under the first 16 bytes of the derived key, and
+// a MAC, Keccak-256 of the next 16 bytes of the derived key and the
+// ciphertext, that shows whether the password is right:
+//
+//	"kdf": "pbkdf2",
+//	"kdfparams": {"c": 262144, "dklen": 32, "prf": "hmac-sha256", "salt": "ae3c..."}
+//
+// KDFParams marshals and unmarshals the kdfparams block and derives the key;
+// DecryptKey and EncryptKey handle whole keystore files:
+//
+//	privateKey, err := ethkeystore.DecryptKey(keyJSON, password)
+//
+// Keystores using the scrypt KDF are rejected with ErrUnsupportedKDF.
+package ethkeystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/sha3"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// Version is the keystore format version supported.
+const Version = 3
+
+// KDF is the name of the KDF supported, as recorded in a keystore.
+const KDF = "pbkdf2"
+
+// PRF is the only pseudorandom function defined for the pbkdf2 KDF.
+const PRF = "hmac-sha256"
+
+// CipherAES128CTR is the only cipher defined for version 3 keystores.
+const CipherAES128CTR = "aes-128-ctr"
+
+// DefaultIterations is the iteration count geth uses for pbkdf2 keystores.
+const DefaultIterations = 262144
+
+// DefaultKeyLength is the length of the derived key: 16 bytes of cipher key
+// and 16 of MAC key.
+const DefaultKeyLength = 32
+
+// SaltSize is the length of the salts of new keystores.
+const SaltSize = 32
+
+var (
+	// ErrUnsupportedKDF is returned for keystores whose KDF is not pbkdf2,
+	// or whose PRF is not hmac-sha256.
+	ErrUnsupportedKDF = errors.New("ethkeystore: unsupported KDF")
+
+	// ErrUnsupported is returned for keystores of versions other than 3, or
+	// with ciphers other than aes-128-ctr.
+	ErrUnsupported = errors.New("ethkeystore: unsupported keystore version or cipher")
+
+	// ErrInvalidParams is returned for KDF parameters with zero iterations,
+	// an empty salt or a derived key shorter than DefaultKeyLength.
+	ErrInvalidParams = errors.New("ethkeystore: invalid KDF parameters")
+
+	// ErrDecrypt is returned by DecryptKey if the password is wrong, or the
+	// keystore has been modified: its MAC does not match.
+	ErrDecrypt = errors.New("ethkeystore: wrong password or corrupted keystore")
+)
+
+// KDFParams are the parameters of the pbkdf2 KDF, the kdfparams block of a
+// keystore.
+type KDFParams struct {
+	// C is the iteration count.
+	C uint32
+
+	// DKLen is the length of the derived key, at least DefaultKeyLength.
+	DKLen uint32
+
+	// PRF is the pseudorandom function, PRF; it is set to PRF if empty.
+	PRF string
+
+	Salt []byte
+}
+
+type kdfParamsJSON struct {
+	C     uint32 `json:"c"`
+	DKLen uint32 `json:"dklen"`
+	PRF   string `json:"prf"`
+	Salt  string `json:"salt"`
+}
+
+// NewKDFParams returns params for a new keystore, with DefaultIterations and
+// a random salt.
+func NewKDFParams() (*KDFParams, error) {
+	p := &KDFParams{C: DefaultIterations, DKLen: DefaultKeyLength, PRF: PRF, Salt: make([]byte, SaltSize)}
+	if _, err := io.ReadFull(pbkdf2.Rand, p.Salt); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// MarshalJSON implements json.Marshaler, encoding the salt in hexadecimal.
+func (p *KDFParams) MarshalJSON() ([]byte, error) {
+	prf := p.PRF
+	if prf == "" {
+		prf = PRF
+	}
+	return json.Marshal(kdfParamsJSON{C: p.C, DKLen: p.DKLen, PRF: prf, Salt: hex.EncodeToString(p.Salt)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *KDFParams) UnmarshalJSON(data []byte) error {
+	var j kdfParamsJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	salt, err := hex.DecodeString(j.Salt)
+	if err != nil {
+		return fmt.Errorf("ethkeystore: invalid salt: %w", err)
+	}
+	*p = KDFParams{C: j.C, DKLen: j.DKLen, PRF: j.PRF, Salt: salt}
+	return nil
+}
+
+// Validate returns ErrUnsupportedKDF if the PRF is not hmac-sha256, and
+// ErrInvalidParams if the params are otherwise unusable.
+func (p *KDFParams) Validate() error {
+	if p.PRF != PRF && p.PRF != "" {
+		return ErrUnsupportedKDF
+	}
+	if p.C == 0 || p.DKLen < DefaultKeyLength || len(p.Salt) == 0 {
+		return ErrInvalidParams
+	}
+	return nil
+}
+
+// DeriveKey derives the key from password, PBKDF2-HMAC-SHA256 with the
+// params' salt and iterations. The caller should destroy the key once it is no
+// longer needed.
+func (p *KDFParams) DeriveKey(password []byte) (*pbkdf2.SecureBytes, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	secret := pbkdf2.NewSecureBytes(append([]byte(nil), password...))
+	defer secret.Destroy()
+
+	return pbkdf2.DeriveKey(secret, p.Salt, &pbkdf2.Params{
+		Iterations: p.C,
+		SaltLength: uint32(len(p.Salt)),
+		KeyLength:  p.DKLen,
+		Variant:    pbkdf2.VariantSHA256,
+	})
+}
+
+// Crypto is the crypto section of a keystore.
+type Crypto struct {
+	Cipher       string          `json:"cipher"`
+	CipherParams CipherParams    `json:"cipherparams"`
+	Ciphertext   string          `json:"ciphertext"`
+	KDF          string          `json:"kdf"`
+	KDFParams    json.RawMessage `json:"kdfparams"`
+	MAC          string          `json:"mac"`
+}
+
+// CipherParams are the parameters of the cipher.
+type CipherParams struct {
+	IV string `json:"iv"`
+}
+
+// Keystore is a keystore file.
+type Keystore struct {
+	// Address is the account's address, in hexadecimal without a 0x prefix.
+	// It is optional, and not checked against the key.
+	Address string `json:"address,omitempty"`
+
+	Crypto  Crypto `json:"crypto"`
+	ID      string `json:"id"`
+	Version int    `json:"version"`
+}
+
+// DecryptKey decrypts the private key in the keystore file keyJSON with
+// password. It returns ErrDecrypt if the password is wrong, ErrUnsupportedKDF
+// for keystores not using pbkdf2, and ErrUnsupported for other versions and
+// ciphers.
+func DecryptKey(keyJSON, password []byte) ([]byte, error) {
+	var ks Keystore
+	if err := json.Unmarshal(keyJSON, &ks); err != nil {
+		return nil, err
+	}
+	if ks.Version != Version || ks.Crypto.Cipher != CipherAES128CTR {
+		return nil, ErrUnsupported
+	}
+	if ks.Crypto.KDF != KDF {
+		return nil, ErrUnsupportedKDF
+	}
+	var params KDFParams
+	if err := json.Unmarshal(ks.Crypto.KDFParams, &params); err != nil {
+		return nil, err
+	}
+	iv, err := hex.DecodeString(ks.Crypto.CipherParams.IV)
+	if err != nil || len(iv) != aes.BlockSize {
+		return nil, ErrUnsupported
+	}
+	ciphertext, err := hex.DecodeString(ks.Crypto.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("ethkeystore: invalid ciphertext: %w", err)
+	}
+	mac, err := hex.DecodeString(ks.Crypto.MAC)
+	if err != nil {
+		return nil, fmt.Errorf("ethkeystore: invalid mac: %w", err)
+	}
+
+	key, err := params.DeriveKey(password)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Destroy()
+
+	if subtle.ConstantTimeCompare(keystoreMAC(key.Bytes(), ciphertext), mac) != 1 {
+		return nil, ErrDecrypt
+	}
+	privateKey := make([]byte, len(ciphertext))
+	aesCTR(key.Bytes(), iv).XORKeyStream(privateKey, ciphertext)
+	return privateKey, nil
+}
+
+// EncryptKey encrypts privateKey with password as a keystore file, with the
+// given KDF params, or those of NewKDFParams if params is nil. The address is
+// recorded as given, and may be empty.
+func EncryptKey(privateKey, password []byte, address string, params *KDFParams) ([]byte, error) {
+	if params == nil {
+		var err error
+		if params, err = NewKDFParams(); err != nil {
+			return nil, err
+		}
+	}
+	key, err := params.DeriveKey(password)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Destroy()
+
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(pbkdf2.Rand, iv); err != nil {
+		return nil, err
+	}
+	id, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := make([]byte, len(privateKey))
+	aesCTR(key.Bytes(), iv).XORKeyStream(ciphertext, privateKey)
+
+	kdfParams, err := params.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&Keystore{
+		Address: address,
+		Crypto: Crypto{
+			Cipher:       CipherAES128CTR,
+			CipherParams: CipherParams{IV: hex.EncodeToString(iv)},
+			Ciphertext:   hex.EncodeToString(ciphertext),
+			KDF:          KDF,
+			KDFParams:    kdfParams,
+			MAC:          hex.EncodeToString(keystoreMAC(key.Bytes(), ciphertext)),
+		},
+		ID:      id,
+		Version: Version,
+	})
+}
+
+// keystoreMAC returns Keccak-256 of the second 16 bytes of the derived key and
+// the ciphertext.
+func keystoreMAC(derivedKey, ciphertext []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(derivedKey[16:32])
+	h.Write(ciphertext)
+	return h.Sum(nil)
+}
+
+func aesCTR(derivedKey, iv []byte) cipher.Stream {
+	block, _ := aes.NewCipher(derivedKey[:16])
+	return cipher.NewCTR(block, iv)
+}
+
+// newUUID returns a random version 4 UUID, for the id of a keystore.
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(pbkdf2.Rand, b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], nil
+}
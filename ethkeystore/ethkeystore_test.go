@@ -0,0 +1,131 @@
+package ethkeystore
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+// testKeystore is the PBKDF2 test vector of the Web3 Secret Storage
+// Definition, encrypted with the password "testpassword".
+const testKeystore = `{
+	"crypto": {
+		"cipher": "aes-128-ctr",
+		"cipherparams": {"iv": "6087dab2f9fdbbfaddc31a909735c1e6"},
+		"ciphertext": "5318b4d5bcd28de64ee5559e671353e16f075ecae9f99c7a79a38af5f869aa46",
+		"kdf": "pbkdf2",
+		"kdfparams": {
+			"c": 262144,
+			"dklen": 32,
+			"prf": "hmac-sha256",
+			"salt": "ae3cd4e7013836a3df6bd7241b12db061dbe2c6785853cce422d148a624ce0bd"
+		},
+		"mac": "517ead924a9d0dc3124507e3393d175ce3ff7c1e96529c6c555ce9e51205e9b2"
+	},
+	"id": "3198bc9c-6672-5ab3-d995-4942343ae5b6",
+	"version": 3
+}`
+
+const (
+	testPassword   = "testpassword"
+	testPrivateKey = "7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d"
+
+	// hashlib.pbkdf2_hmac("sha256", b"testpassword", salt, 262144, 32)
+	testDerivedKey = "f06d69cdc7da0faffb1008270bca38f5e31891a3a773950e6d0fea48a7188551"
+)
+
+func TestDecryptVector(t *testing.T) {
+	key, err := DecryptKey([]byte(testKeystore), []byte(testPassword))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(key); got != testPrivateKey {
+		t.Fatalf("got %s, want %s", got, testPrivateKey)
+	}
+	if _, err := DecryptKey([]byte(testKeystore), []byte("wrong")); err != ErrDecrypt {
+		t.Fatalf("expected ErrDecrypt, got %v", err)
+	}
+}
+
+func TestKDFParamsJSON(t *testing.T) {
+	var ks Keystore
+	if err := json.Unmarshal([]byte(testKeystore), &ks); err != nil {
+		t.Fatal(err)
+	}
+	var params KDFParams
+	if err := json.Unmarshal(ks.Crypto.KDFParams, &params); err != nil {
+		t.Fatal(err)
+	}
+	if params.C != 262144 || params.DKLen != 32 || params.PRF != PRF || len(params.Salt) != 32 {
+		t.Fatalf("unexpected params %+v", params)
+	}
+	key, err := params.DeriveKey([]byte(testPassword))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Destroy()
+	if got := hex.EncodeToString(key.Bytes()); got != testDerivedKey {
+		t.Fatalf("derived %s, want %s", got, testDerivedKey)
+	}
+
+	data, err := json.Marshal(&params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"c":262144,"dklen":32,"prf":"hmac-sha256","salt":"ae3cd4e7013836a3df6bd7241b12db061dbe2c6785853cce422d148a624ce0bd"}`
+	if string(data) != want {
+		t.Fatalf("got %s, want %s", data, want)
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	privateKey, _ := hex.DecodeString(testPrivateKey)
+	params := &KDFParams{C: 1000, DKLen: 32, Salt: []byte("0123456789abcdef")}
+	data, err := EncryptKey(privateKey, []byte("pw"), "008aeeda4d805471df9b2a5b0f38a0c3bcba786b", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ks Keystore
+	if err := json.Unmarshal(data, &ks); err != nil {
+		t.Fatal(err)
+	}
+	if ks.Version != 3 || ks.Crypto.KDF != KDF || len(ks.ID) != 36 || ks.ID[14] != '4' || ks.Address == "" {
+		t.Fatalf("unexpected keystore %s", data)
+	}
+	got, err := DecryptKey(data, []byte("pw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, privateKey) {
+		t.Fatal("round trip changed the key")
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	tests := map[string]struct {
+		edit func(*Keystore)
+		err  error
+	}{
+		"scrypt":  {func(ks *Keystore) { ks.Crypto.KDF = "scrypt" }, ErrUnsupportedKDF},
+		"version": {func(ks *Keystore) { ks.Version = 1 }, ErrUnsupported},
+		"cipher":  {func(ks *Keystore) { ks.Crypto.Cipher = "aes-128-cbc" }, ErrUnsupported},
+		"prf": {func(ks *Keystore) {
+			ks.Crypto.KDFParams = json.RawMessage(`{"c":1,"dklen":32,"prf":"hmac-sha512","salt":"00"}`)
+		}, ErrUnsupportedKDF},
+		"dklen": {func(ks *Keystore) {
+			ks.Crypto.KDFParams = json.RawMessage(`{"c":1,"dklen":16,"prf":"hmac-sha256","salt":"00"}`)
+		}, ErrInvalidParams},
+	}
+	for name, tt := range tests {
+		var ks Keystore
+		if err := json.Unmarshal([]byte(testKeystore), &ks); err != nil {
+			t.Fatal(err)
+		}
+		tt.edit(&ks)
+		data, _ := json.Marshal(&ks)
+		if _, err := DecryptKey(data, []byte(testPassword)); err != tt.err {
+			t.Errorf("%s: expected %v, got %v", name, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package pbkdf2
+
+// A Candidate is one configuration under which a stored hash may verify, such
+// as the current pepper without normalization, or a previous pepper.
+type Candidate struct {
+	// Name identifies the candidate in a FallbackResult.
+	Name string
+
+	// Normalize, if set, transforms the password before it is checked, for
+	// example to apply Unicode normalization.
+	Normalize func(password string) string
+
+	// Check reports whether the password matches the hash, like the
+	// CheckHash method of pepper.Hasher. If nil, the package-level CheckHash
+	// is used.
+	Check func(password, hash string) (match bool, err error)
+}
+
+func (c *Candidate) check(password, hash string) (bool, error) {
+	if c.Normalize != nil {
+		password = c.Normalize(password)
+	}
+	if c.Check == nil {
+		match, _, err := CheckHash(password, hash)
+		return match, err
+	}
+	return c.Check(password, hash)
+}
+
+// A FallbackChain verifies hashes that may have been created under any of
+// several configurations, for use while migrating from one to another.
+//
+// Every candidate is checked on every call, whichever of them matches, so
+// that the time taken does not reveal which configuration a user's hash was
+// created under, or whether the password matched an earlier one. A candidate
+// that fails with an error, which it typically does before deriving a key, is
+// followed by a derivation with the params of the hash in its place.
+type FallbackChain struct {
+	// Candidates are tried in order. The first should be the current
+	// configuration, so that a match against any other indicates the hash
+	// should be upgraded.
+	Candidates []Candidate
+}
+
+// FallbackResult describes the outcome of FallbackChain.CheckHash.
+type FallbackResult struct {
+	// Match reports whether any candidate matched.
+	Match bool
+
+	// Index and Name identify the first candidate that matched. Index is -1
+	// if none did.
+	Index int
+	Name  string
+}
+
+// NeedsUpgrade reports whether the password matched a candidate other than
+// the first, in which case the caller should replace the stored hash with one
+// created under the current configuration.
+func (r *FallbackResult) NeedsUpgrade() bool {
+	return r.Match && r.Index > 0
+}
+
+// CheckHash checks password against hash under every candidate and reports
+// the first that matched. A candidate that fails with an error, such as
+// pepper.ErrNotPeppered for a hash created before peppering was introduced,
+// is treated as not matching. An error is returned only if every candidate
+// failed with one, in which case it is the first of them.
+func (c *FallbackChain) CheckHash(password, hash string) (*FallbackResult, error) {
+	result := &FallbackResult{Index: -1}
+	var firstErr error
+	failed := 0
+	var decoded *Hash
+	for i := range c.Candidates {
+		candidate := &c.Candidates[i]
+		match, err := candidate.check(password, hash)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+				decoded, _ = decodeHash(hash)
+			}
+			failed++
+			deriveInstead(password, decoded)
+			continue
+		}
+		if match && !result.Match {
+			result.Match, result.Index, result.Name = true, i, candidate.Name
+		}
+	}
+	if decoded != nil {
+		wipe(decoded.Salt)
+		wipe(decoded.Key)
+	}
+	if failed > 0 && failed == len(c.Candidates) {
+		return nil, firstErr
+	}
+	return result, nil
+}
+
+// deriveInstead derives and discards a key from password with the params,
+// salt and stages of h, taking as long as checking password against h would.
+// A hash that cannot be decoded, in which case h is nil, needs no such work,
+// since it fails the same way under every candidate.
+func deriveInstead(password string, h *Hash) {
+	if h == nil || h.Params.Validate() != nil {
+		return
+	}
+	stages, err := parseStages(h.Metadata[MetadataStages])
+	if err != nil {
+		return
+	}
+	secret := SecureBytesFromString(password)
+	defer secret.Destroy()
+	salt := NewSecureBytes(append([]byte(nil), h.Salt...))
+	defer salt.Destroy()
+	applyStages(deriveKey(secret, salt, &h.Params), h.Salt, &h.Params, stages).Destroy()
+}
+
+// Verify is like CheckHash, except it returns ErrMismatchedHashAndPassword if
+// no candidate matched.
+func (c *FallbackChain) Verify(password, hash string) (*FallbackResult, error) {
+	result, err := c.CheckHash(password, hash)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Match {
+		return nil, ErrMismatchedHashAndPassword
+	}
+	return result, nil
+}
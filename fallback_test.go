@@ -0,0 +1,117 @@
+package pbkdf2
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFallbackChain(t *testing.T) {
+	params := &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32}
+	lower, err := CreateHash("password", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	counting := func(password, hash string) (bool, error) {
+		calls++
+		match, _, err := CheckHash(password, hash)
+		return match, err
+	}
+	chain := &FallbackChain{Candidates: []Candidate{
+		{Name: "current", Normalize: strings.ToLower, Check: counting},
+		{Name: "raw", Check: counting},
+	}}
+
+	for _, tc := range []struct {
+		password, name string
+		index          int
+		upgrade        bool
+	}{
+		{"PassWord", "current", 0, false},
+		{"password", "current", 0, false},
+		{"other", "", -1, false},
+	} {
+		calls = 0
+		result, err := chain.CheckHash(tc.password, lower)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if calls != len(chain.Candidates) {
+			t.Errorf("%q: %d candidates checked, want %d", tc.password, calls, len(chain.Candidates))
+		}
+		if result.Match != (tc.index >= 0) || result.Index != tc.index || result.Name != tc.name {
+			t.Errorf("%q: unexpected result %+v", tc.password, result)
+		}
+		if result.NeedsUpgrade() != tc.upgrade {
+			t.Errorf("%q: NeedsUpgrade() = %v", tc.password, result.NeedsUpgrade())
+		}
+	}
+
+	mixed, err := CreateHash("PassWord", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := chain.Verify("PassWord", mixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Index != 1 || result.Name != "raw" || !result.NeedsUpgrade() {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if _, err := chain.Verify("password", mixed); err != ErrMismatchedHashAndPassword {
+		t.Errorf("expected ErrMismatchedHashAndPassword, got %v", err)
+	}
+}
+
+func TestFallbackChainErrors(t *testing.T) {
+	hash, err := CreateHash("password", &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errFailed := errors.New("failed")
+	failing := Candidate{Name: "failing", Check: func(string, string) (bool, error) {
+		return false, errFailed
+	}}
+
+	chain := &FallbackChain{Candidates: []Candidate{failing, {Name: "default"}}}
+	result, err := chain.CheckHash("password", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Index != 1 || result.Name != "default" {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	chain = &FallbackChain{Candidates: []Candidate{failing, failing}}
+	if _, err := chain.CheckHash("password", hash); err != errFailed {
+		t.Errorf("expected the first candidate's error, got %v", err)
+	}
+}
+
+func TestFallbackChainTiming(t *testing.T) {
+	hash, err := CreateHash("password", &Params{Iterations: 200000, SaltLength: 16, KeyLength: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failing := Candidate{Name: "failing", Check: func(string, string) (bool, error) {
+		return false, errors.New("failed")
+	}}
+
+	// A failing candidate is replaced by a derivation of the same cost, so
+	// the two chains take about as long.
+	elapsed := func(chain *FallbackChain) time.Duration {
+		start := time.Now()
+		if _, err := chain.CheckHash("password", hash); err != nil {
+			t.Fatal(err)
+		}
+		return time.Since(start)
+	}
+	both := elapsed(&FallbackChain{Candidates: []Candidate{{Name: "a"}, {Name: "b"}}})
+	oneFailing := elapsed(&FallbackChain{Candidates: []Candidate{failing, {Name: "b"}}})
+	if oneFailing < both*3/4 {
+		t.Errorf("a failing candidate took %v against %v for two derivations", oneFailing, both)
+	}
+}
@@ -0,0 +1,303 @@
+// Package filecrypt encrypts files with a password, deriving an AES-256-GCM
+// key with PBKDF2 from the password and a random per-file salt, so that
+// applications get password-based encryption without assembling the
+// primitives themselves:
+//
+//	err := filecrypt.EncryptFile("backup.tar.enc", "backup.tar", password, nil)
+//	// ...
+//	err = filecrypt.DecryptFile("backup.tar", "backup.tar.enc", password)
+//
+// Encrypted data starts with a small header recording everything needed to
+// derive the key again, followed by the ciphertext:
+//
+//	magic     "PBKDF2E" and a version byte, 1
+//	variant   length byte and PBKDF2 variant name, such as "pbkdf2-sha512"
+//	iters     iterations, as a big-endian uint32
+//	salt      length byte and salt
+//	nonce     12-byte GCM nonce
+//
+// The header is authenticated along with the ciphertext, so any change to
+// either, or a wrong password, makes decryption fail with ErrDecrypt. Nothing
+// is written to the destination until the whole input has been authenticated,
+// which requires holding it in memory.
+//
+// For large files, such as multi-gigabyte backups, NewWriter and NewReader
+// encrypt and decrypt streams in authenticated chunks instead, in constant
+// memory:
+//
+//	w, err := filecrypt.NewWriter(f, password, nil)
+//	_, err = io.Copy(w, backup)
+//	err = w.Close()
+package filecrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// KeyLength is the length of the derived AES-256 key.
+const KeyLength = 32
+
+// MaxIterations is the largest number of iterations accepted from a header,
+// so that crafted input cannot make Decrypt run for hours.
+const MaxIterations = 10000000
+
+const (
+	magic     = "PBKDF2E"
+	version   = 1
+	nonceSize = 12
+)
+
+var (
+	// ErrInvalidHeader is returned by Decrypt if the input does not start
+	// with a valid header.
+	ErrInvalidHeader = errors.New("filecrypt: invalid or unsupported header")
+
+	// ErrDecrypt is returned by Decrypt if the password is wrong, or the data
+	// was corrupted or tampered with. The two cannot be told apart.
+	ErrDecrypt = errors.New("filecrypt: wrong password or corrupted data")
+)
+
+// Encrypt reads src until EOF, and writes it to dst encrypted with a key
+// derived from password using params. Only the iterations, salt length and
+// variant of params are used; the key is always KeyLength bytes. If params is
+// nil, the pbkdf2 package-level default params are used.
+func Encrypt(dst io.Writer, src io.Reader, password string, params *pbkdf2.Params) error {
+	plaintext, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	out, err := seal(plaintext, password, params)
+	if err != nil {
+		return err
+	}
+	_, err = dst.Write(out)
+	return err
+}
+
+// Decrypt reads encrypted data from src until EOF, and writes the decrypted
+// plaintext to dst once it has been authenticated. It returns
+// ErrInvalidHeader if src does not start with a valid header, and ErrDecrypt
+// if the password is wrong or the data has been modified.
+func Decrypt(dst io.Writer, src io.Reader, password string) error {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	plaintext, err := open(data, password)
+	if err != nil {
+		return err
+	}
+	_, err = dst.Write(plaintext)
+	return err
+}
+
+// EncryptFile encrypts the file src into dst as Encrypt does. dst is created
+// with mode 0600, or truncated if it exists.
+func EncryptFile(dst, src, password string, params *pbkdf2.Params) error {
+	plaintext, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	out, err := seal(plaintext, password, params)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, out, 0o600)
+}
+
+// DecryptFile decrypts the file src into dst as Decrypt does. dst is only
+// created, with mode 0600, if decryption succeeds.
+func DecryptFile(dst, src, password string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	plaintext, err := open(data, password)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, plaintext, 0o600)
+}
+
+// A header holds the parameters recorded at the start of encrypted data.
+type header struct {
+	version    byte
+	variant    string
+	iterations uint32
+	salt       []byte
+	chunkSize  uint32 // streams only
+	nonce      []byte // the nonce prefix for streams
+}
+
+func (h *header) marshal() []byte {
+	b := make([]byte, 0, len(magic)+1+1+len(h.variant)+4+1+len(h.salt)+4+len(h.nonce))
+	b = append(b, magic...)
+	b = append(b, h.version, byte(len(h.variant)))
+	b = append(b, h.variant...)
+	b = binary.BigEndian.AppendUint32(b, h.iterations)
+	b = append(b, byte(len(h.salt)))
+	b = append(b, h.salt...)
+	if h.version == streamVersion {
+		b = binary.BigEndian.AppendUint32(b, h.chunkSize)
+	}
+	return append(b, h.nonce...)
+}
+
+// readHeader reads a header from r, returning it and its encoding.
+func readHeader(r io.Reader) (*header, []byte, error) {
+	var raw []byte
+	next := func(n int) ([]byte, error) {
+		start := len(raw)
+		raw = append(raw, make([]byte, n)...)
+		if _, err := io.ReadFull(r, raw[start:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				err = ErrInvalidHeader
+			}
+			return nil, err
+		}
+		return raw[start:], nil
+	}
+
+	b, err := next(len(magic) + 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	if string(b[:len(magic)]) != magic || (b[len(magic)] != version && b[len(magic)] != streamVersion) {
+		return nil, nil, ErrInvalidHeader
+	}
+	h := &header{version: b[len(magic)]}
+	if b, err = next(int(b[len(magic)+1]) + 4 + 1); err != nil {
+		return nil, nil, err
+	}
+	h.variant = string(b[:len(b)-5])
+	h.iterations = binary.BigEndian.Uint32(b[len(b)-5:])
+
+	n := int(b[len(b)-1])
+	if h.version == streamVersion {
+		if b, err = next(n + 4 + streamNonceSize); err != nil {
+			return nil, nil, err
+		}
+		h.chunkSize = binary.BigEndian.Uint32(b[n:])
+		h.nonce = b[n+4:]
+		if h.chunkSize == 0 || h.chunkSize > MaxChunkSize {
+			return nil, nil, ErrInvalidHeader
+		}
+	} else {
+		if b, err = next(n + nonceSize); err != nil {
+			return nil, nil, err
+		}
+		h.nonce = b[n:]
+	}
+	h.salt = b[:n]
+
+	if h.iterations == 0 || h.iterations > MaxIterations || len(h.salt) == 0 {
+		return nil, nil, ErrInvalidHeader
+	}
+	return h, raw, nil
+}
+
+// newHeader returns a header for new encrypted data of the given version,
+// with a random salt and nonce.
+func newHeader(v byte, params *pbkdf2.Params) (*header, error) {
+	if params == nil {
+		params = pbkdf2.GetDefaultParams()
+	}
+	if params.SaltLength > 255 {
+		return nil, pbkdf2.ErrInvalidParams
+	}
+	h := &header{
+		version:    v,
+		variant:    params.Variant,
+		iterations: params.Iterations,
+		salt:       make([]byte, params.SaltLength),
+		nonce:      make([]byte, nonceSize),
+	}
+	if v == streamVersion {
+		h.chunkSize = DefaultChunkSize
+		h.nonce = h.nonce[:streamNonceSize]
+	}
+	if h.variant == "" {
+		h.variant = pbkdf2.VariantSHA512
+	}
+	if _, err := io.ReadFull(pbkdf2.Rand, h.salt); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(pbkdf2.Rand, h.nonce); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func seal(plaintext []byte, password string, params *pbkdf2.Params) ([]byte, error) {
+	h, err := newHeader(version, params)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(password, h)
+	if err != nil {
+		return nil, err
+	}
+	hdr := h.marshal()
+	return aead.Seal(hdr, h.nonce, plaintext, hdr), nil
+}
+
+func open(data []byte, password string) ([]byte, error) {
+	h, hdr, err := readHeader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if h.version != version {
+		// Streams must be read with NewReader.
+		return nil, ErrInvalidHeader
+	}
+	aead, err := openAEAD(password, h)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, h.nonce, data[len(hdr):], hdr)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// openAEAD is newAEAD for decryption, where a header naming an unsupported
+// variant is invalid.
+func openAEAD(password string, h *header) (cipher.AEAD, error) {
+	aead, err := newAEAD(password, h)
+	if err == pbkdf2.ErrIncompatibleVariant {
+		return nil, ErrInvalidHeader
+	}
+	return aead, err
+}
+
+// newAEAD derives the key for h from password, and returns an AES-256-GCM
+// AEAD using it.
+func newAEAD(password string, h *header) (cipher.AEAD, error) {
+	secret := pbkdf2.SecureBytesFromString(password)
+	defer secret.Destroy()
+	key, err := pbkdf2.DeriveKey(secret, h.salt, &pbkdf2.Params{
+		Iterations: h.iterations,
+		SaltLength: uint32(len(h.salt)),
+		KeyLength:  KeyLength,
+		Variant:    h.variant,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer key.Destroy()
+
+	block, err := aes.NewCipher(key.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
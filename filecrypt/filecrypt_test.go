@@ -0,0 +1,114 @@
+package filecrypt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+var testParams = &pbkdf2.Params{Iterations: 1000, SaltLength: 16, KeyLength: 64}
+
+func encrypt(t *testing.T, plaintext, password string, params *pbkdf2.Params) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Encrypt(&buf, strings.NewReader(plaintext), password, params); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	for _, params := range []*pbkdf2.Params{
+		testParams,
+		{Iterations: 1000, SaltLength: 32, KeyLength: 32, Variant: pbkdf2.VariantSHA256},
+	} {
+		for _, plaintext := range []string{"", "attack at dawn", strings.Repeat("x", 100000)} {
+			data := encrypt(t, plaintext, "pa$$word", params)
+			if !bytes.HasPrefix(data, []byte("PBKDF2E\x01")) {
+				t.Fatalf("unexpected header %q", data[:8])
+			}
+			var out bytes.Buffer
+			if err := Decrypt(&out, bytes.NewReader(data), "pa$$word"); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != plaintext {
+				t.Errorf("decrypted %d bytes, want %d", out.Len(), len(plaintext))
+			}
+		}
+	}
+
+	a := encrypt(t, "same", "pa$$word", testParams)
+	b := encrypt(t, "same", "pa$$word", testParams)
+	if bytes.Equal(a, b) {
+		t.Error("expected a fresh salt and nonce for each encryption")
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	data := encrypt(t, "attack at dawn", "pa$$word", testParams)
+	decrypt := func(data []byte, password string) error {
+		return Decrypt(&bytes.Buffer{}, bytes.NewReader(data), password)
+	}
+
+	if err := decrypt(data, "wrong"); err != ErrDecrypt {
+		t.Errorf("wrong password: expected ErrDecrypt, got %v", err)
+	}
+	// Flip a bit in the salt, which is authenticated, and in the ciphertext.
+	for _, i := range []int{len("PBKDF2E") + 2 + len(pbkdf2.VariantSHA512) + 4 + 1, len(data) - 1} {
+		tampered := append([]byte(nil), data...)
+		tampered[i] ^= 1
+		if err := decrypt(tampered, "pa$$word"); err != ErrDecrypt {
+			t.Errorf("tampered byte %d: expected ErrDecrypt, got %v", i, err)
+		}
+	}
+	if err := decrypt(data[:len(data)-1], "pa$$word"); err != ErrDecrypt {
+		t.Errorf("truncated: expected ErrDecrypt, got %v", err)
+	}
+
+	excessive := append([]byte(nil), data...)
+	binary.BigEndian.PutUint32(excessive[len("PBKDF2E")+2+len(pbkdf2.VariantSHA512):], MaxIterations+1)
+	unknown := append([]byte("PBKDF2E\x01\x03abc"), data[len("PBKDF2E")+2+len(pbkdf2.VariantSHA512):]...)
+	for name, data := range map[string][]byte{
+		"empty":      nil,
+		"magic":      []byte("not encrypted"),
+		"version":    append([]byte("PBKDF2E\x02"), data[8:]...),
+		"short":      data[:20],
+		"iterations": excessive,
+		"variant":    unknown,
+	} {
+		if err := decrypt(data, "pa$$word"); err != ErrInvalidHeader {
+			t.Errorf("%s: expected ErrInvalidHeader, got %v", name, err)
+		}
+	}
+}
+
+func TestEncryptFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "plain.txt")
+	enc := filepath.Join(dir, "plain.txt.enc")
+	dst := filepath.Join(dir, "decrypted.txt")
+	if err := os.WriteFile(src, []byte("attack at dawn"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EncryptFile(enc, src, "pa$$word", testParams); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecryptFile(dst, enc, "wrong"); err != ErrDecrypt {
+		t.Fatalf("expected ErrDecrypt, got %v", err)
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Fatalf("expected no output after failed decryption, got %v", err)
+	}
+	if err := DecryptFile(dst, enc, "pa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(dst); err != nil || string(b) != "attack at dawn" {
+		t.Fatalf("decrypted %q, %v", b, err)
+	}
+}
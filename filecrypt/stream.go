@@ -0,0 +1,237 @@
+package filecrypt
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// DefaultChunkSize is the size of the plaintext chunks written by NewWriter.
+const DefaultChunkSize = 64 << 10
+
+// MaxChunkSize is the largest chunk size accepted from a stream header, which
+// bounds the memory NewReader uses.
+const MaxChunkSize = 16 << 20
+
+const (
+	streamVersion   = 2
+	streamNonceSize = 7
+)
+
+// errStreamTooLong is returned by the writer if the chunk counter would wrap.
+var errStreamTooLong = errors.New("filecrypt: stream too long")
+
+// chunkNonce returns the nonce for chunk i of a stream: the random prefix from
+// the header, the big-endian chunk index, and a byte that is 1 for the final
+// chunk and 0 otherwise. Binding the index prevents chunks being reordered,
+// and the final flag prevents a stream being truncated at a chunk boundary.
+func chunkNonce(dst, prefix []byte, i uint32, final bool) []byte {
+	dst = append(dst[:0], prefix...)
+	dst = binary.BigEndian.AppendUint32(dst, i)
+	if final {
+		return append(dst, 1)
+	}
+	return append(dst, 0)
+}
+
+type writer struct {
+	dst     io.Writer
+	aead    cipher.AEAD
+	header  *header
+	hdr     []byte
+	buf     []byte
+	out     []byte
+	nonce   []byte
+	counter uint32
+	err     error
+}
+
+// NewWriter returns a WriteCloser that encrypts what is written to it with a
+// key derived from password using params, and writes it to dst in chunks, so
+// that files of any size can be encrypted in constant memory. It writes the
+// header to dst immediately. If params is nil, the pbkdf2 package-level
+// default params are used.
+//
+// Each chunk of DefaultChunkSize bytes is sealed separately with AES-256-GCM,
+// under a nonce binding its position in the stream and whether it is the last.
+// Close must be called to write the final chunk; without it NewReader reports
+// the stream as truncated. Close does not close dst.
+//
+// The header has the same layout as for Encrypt, with version 2, the chunk
+// size as a big-endian uint32 before the nonce, and a 7-byte nonce prefix.
+func NewWriter(dst io.Writer, password string, params *pbkdf2.Params) (io.WriteCloser, error) {
+	h, err := newHeader(streamVersion, params)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(password, h)
+	if err != nil {
+		return nil, err
+	}
+	w := &writer{
+		dst:    dst,
+		aead:   aead,
+		header: h,
+		hdr:    h.marshal(),
+		buf:    make([]byte, 0, h.chunkSize),
+		out:    make([]byte, 0, int(h.chunkSize)+aead.Overhead()),
+	}
+	if _, err := dst.Write(w.hdr); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	written := 0
+	for len(p) > 0 {
+		// A full chunk is only sealed once more data arrives, since until
+		// then it may be the final one.
+		if len(w.buf) == cap(w.buf) {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close writes the final chunk. Subsequent writes fail.
+func (w *writer) Close() error {
+	if w.err != nil {
+		if w.err == errClosed {
+			return nil
+		}
+		return w.err
+	}
+	err := w.flush(true)
+	wipeBytes(w.buf[:cap(w.buf)])
+	if err == nil {
+		w.err = errClosed
+	}
+	return err
+}
+
+var errClosed = errors.New("filecrypt: write to closed stream")
+
+func (w *writer) flush(final bool) error {
+	w.nonce = chunkNonce(w.nonce, w.header.nonce, w.counter, final)
+	w.out = w.aead.Seal(w.out[:0], w.nonce, w.buf, w.hdr)
+	w.buf = w.buf[:0]
+	if _, err := w.dst.Write(w.out); err != nil {
+		w.err = err
+		return err
+	}
+	if w.counter++; w.counter == 0 && !final {
+		w.err = errStreamTooLong
+		return w.err
+	}
+	return nil
+}
+
+type reader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	header  *header
+	hdr     []byte
+	in      []byte
+	plain   []byte
+	nonce   []byte
+	counter uint32
+	done    bool
+	err     error
+}
+
+// NewReader reads the header of a stream written by NewWriter from src,
+// derives its key from password, and returns a Reader of the decrypted
+// plaintext. It returns ErrInvalidHeader if src does not start with a valid
+// stream header.
+//
+// Each chunk is authenticated before any of it is returned, and reads fail
+// with ErrDecrypt if the password is wrong, or the stream has been modified,
+// reordered or truncated. Since a stream can only be known to be complete at
+// its end, callers must not act on the plaintext until Read returns io.EOF.
+func NewReader(src io.Reader, password string) (io.Reader, error) {
+	h, hdr, err := readHeader(src)
+	if err != nil {
+		return nil, err
+	}
+	if h.version != streamVersion {
+		// Data written by Encrypt must be read with Decrypt.
+		return nil, ErrInvalidHeader
+	}
+	aead, err := openAEAD(password, h)
+	if err != nil {
+		return nil, err
+	}
+	return &reader{
+		src:    bufio.NewReader(src),
+		aead:   aead,
+		header: h,
+		hdr:    hdr,
+		in:     make([]byte, int(h.chunkSize)+aead.Overhead()),
+	}, nil
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		r.err = r.next()
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+// next reads and opens the next chunk.
+func (r *reader) next() error {
+	n, err := io.ReadFull(r.src, r.in)
+	final := false
+	switch err {
+	case nil:
+		// A full chunk is the last if nothing follows it.
+		if _, err := r.src.Peek(1); err == io.EOF {
+			final = true
+		} else if err != nil {
+			return err
+		}
+	case io.EOF, io.ErrUnexpectedEOF:
+		final = true
+	default:
+		return err
+	}
+
+	r.nonce = chunkNonce(r.nonce, r.header.nonce, r.counter, final)
+	plain, err := r.aead.Open(r.in[:0], r.nonce, r.in[:n], r.hdr)
+	if err != nil {
+		return ErrDecrypt
+	}
+	r.plain = plain
+	r.done = final
+	if r.counter++; r.counter == 0 && !final {
+		return ErrDecrypt
+	}
+	return nil
+}
+
+func wipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
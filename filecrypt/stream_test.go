@@ -0,0 +1,123 @@
+package filecrypt
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func encryptStream(t *testing.T, plaintext []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "pa$$word", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Write in uneven pieces, to exercise chunk boundaries.
+	for p := plaintext; len(p) > 0; {
+		n := len(p)
+		if n > 10007 {
+			n = 10007
+		}
+		if _, err := w.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decryptStream(data []byte, password string) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(data), password)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestStream(t *testing.T) {
+	for _, n := range []int{0, 1, DefaultChunkSize - 1, DefaultChunkSize, DefaultChunkSize + 1, 3*DefaultChunkSize + 5} {
+		plaintext := bytes.Repeat([]byte("0123456789"), n/10+1)[:n]
+		data := encryptStream(t, plaintext)
+		if !bytes.HasPrefix(data, []byte("PBKDF2E\x02")) {
+			t.Fatalf("unexpected header %q", data[:8])
+		}
+		got, err := decryptStream(data, "pa$$word")
+		if err != nil {
+			t.Fatalf("length %d: %v", n, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("length %d: decrypted %d bytes that differ", n, len(got))
+		}
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("x"), 2*DefaultChunkSize+100)
+	data := encryptStream(t, plaintext)
+	hdrLen := len("PBKDF2E") + 2 + len("pbkdf2-sha512") + 4 + 1 + 16 + 4 + streamNonceSize
+	chunk := DefaultChunkSize + 16
+
+	if _, err := decryptStream(data, "wrong"); err != ErrDecrypt {
+		t.Errorf("wrong password: expected ErrDecrypt, got %v", err)
+	}
+
+	reordered := append([]byte(nil), data[:hdrLen]...)
+	reordered = append(reordered, data[hdrLen+chunk:hdrLen+2*chunk]...)
+	reordered = append(reordered, data[hdrLen:hdrLen+chunk]...)
+	reordered = append(reordered, data[hdrLen+2*chunk:]...)
+
+	tampered := append([]byte(nil), data...)
+	tampered[hdrLen+chunk+5] ^= 1
+
+	for name, data := range map[string][]byte{
+		"reordered":         reordered,
+		"tampered":          tampered,
+		"truncated":         data[:len(data)-1],
+		"truncated chunk":   data[:hdrLen+chunk],
+		"no chunks":         data[:hdrLen],
+		"trailing data":     append(append([]byte(nil), data...), 0),
+		"missing one chunk": append(append([]byte(nil), data[:hdrLen+chunk]...), data[hdrLen+2*chunk:]...),
+	} {
+		got, err := decryptStream(data, "pa$$word")
+		if err != ErrDecrypt {
+			t.Errorf("%s: expected ErrDecrypt, got %v", name, err)
+		}
+		if !bytes.Equal(got, plaintext[:len(got)]) {
+			t.Errorf("%s: returned unauthenticated data", name)
+		}
+	}
+
+	// Streams and whole-file encryption are not interchangeable.
+	if err := Decrypt(io.Discard, bytes.NewReader(data), "pa$$word"); err != ErrInvalidHeader {
+		t.Errorf("Decrypt of stream: expected ErrInvalidHeader, got %v", err)
+	}
+	var whole bytes.Buffer
+	if err := Encrypt(&whole, strings.NewReader("x"), "pa$$word", testParams); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewReader(&whole, "pa$$word"); err != ErrInvalidHeader {
+		t.Errorf("NewReader of whole file: expected ErrInvalidHeader, got %v", err)
+	}
+}
+
+func TestStreamClose(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "pa$$word", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	if _, err := w.Write([]byte("x")); err != errClosed {
+		t.Errorf("Write after Close: expected errClosed, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+//go:build gofuzz
+
+package pbkdf2
+
+import "errors"
+
+// This file contains entry points for go-fuzz (https://github.com/dvyukov/go-fuzz)
+// and go-fuzz compatible harnesses such as OSS-Fuzz. Native Go fuzz targets
+// with the same names live in fuzz_test.go and share the seed corpus under
+// testdata/fuzz.
+
+// Bounds on the work FuzzCheckHash is willing to do for a single input, so
+// that the fuzzer explores the parser rather than spending its time in PBKDF2.
+// The iterations bound counts every stage added by Strengthen.
+const (
+	fuzzMaxIterations = 1 << 10
+	fuzzMaxKeyLength  = 1 << 10
+)
+
+// FuzzDecodeHash is a go-fuzz entry point for DecodeHash and ParseHash. It
+// panics if a hash that decodes successfully does not re-encode to a canonical
+// form that itself round-trips.
+func FuzzDecodeHash(data []byte) int {
+	hash := string(data)
+	params, salt, key, err := DecodeHash(hash)
+	if err != nil {
+		return 0
+	}
+
+	if uint32(len(salt)) != params.SaltLength || uint32(len(key)) != params.KeyLength {
+		panic("pbkdf2: decoded params do not match salt and key lengths")
+	}
+
+	h, err := ParseHash(hash)
+	if err != nil {
+		panic("pbkdf2: ParseHash rejected a hash accepted by DecodeHash: " + err.Error())
+	}
+	canonical := h.String()
+	reparsed, err := ParseHash(canonical)
+	if err != nil || reparsed.String() != canonical {
+		panic("pbkdf2: decoded hash does not round-trip")
+	}
+	return 1
+}
+
+// FuzzCheckHash is a go-fuzz entry point for CheckHash. The first line of the
+// input is used as the hash and the remainder as the password.
+func FuzzCheckHash(data []byte) int {
+	hash, password := splitFuzzInput(data)
+
+	params, _, _, err := DecodeHash(hash)
+	if err != nil {
+		return 0
+	}
+	h, err := ParseHash(hash)
+	if err != nil {
+		panic("pbkdf2: ParseHash rejected a hash accepted by DecodeHash: " + err.Error())
+	}
+	if total, err := totalIterations(h); err != nil || total > fuzzMaxIterations || params.KeyLength > fuzzMaxKeyLength {
+		return 0
+	}
+
+	match, checkParams, err := CheckHash(password, hash)
+	if errors.Is(err, ErrUnknownNormalization) {
+		// The normalization is recorded in the hash, and only registered
+		// ones can be applied.
+		return 0
+	}
+	if err != nil {
+		panic("pbkdf2: CheckHash failed on a hash accepted by DecodeHash: " + err.Error())
+	}
+	if *checkParams != *params {
+		panic("pbkdf2: CheckHash and DecodeHash disagree on params")
+	}
+	if match {
+		return 1
+	}
+	return 0
+}
+
+func splitFuzzInput(data []byte) (hash, password string) {
+	for i, c := range data {
+		if c == '\n' {
+			return string(data[:i]), string(data[i+1:])
+		}
+	}
+	return string(data), ""
+}
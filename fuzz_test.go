@@ -0,0 +1,115 @@
+//go:build !gofuzz
+
+package pbkdf2
+
+import (
+	"errors"
+	"testing"
+)
+
+var fuzzSeedHashes = []string{
+	"$pbkdf2-sha512$210000$KuwdBW88vV7YiVGWsMmc8g$XO+ztCemYHheH1kqHe6QAmb99lL3MI7IeBQ05dnAXGk",
+	"$pbkdf2-sha512$1$AA$AA",
+	"$pbkdf2-sha512$4294967295$AA$AA",
+	"$pbkdf2-sha512$4294967296$AA$AA",
+	"$pbkdf2-sha512$99999999999999999999999$AA$AA",
+	"$pbkdf2-sha512$0$AA$AA",
+	"$pbkdf2-sha512$0210000$AA$AA",
+	"$pbkdf2-sha512$-1$AA$AA",
+	"$pbkdf2-sha512$+1$AA$AA",
+	"$pbkdf2-sha512$ 1$AA$AA",
+	"$pbkdf2-sha512$1e6$AA$AA",
+	"$pbkdf2-sha512$0x10$AA$AA",
+	"$pbkdf2-sha512$1$$AA",
+	"$pbkdf2-sha512$1$AA$",
+	"$pbkdf2-sha512$1$AA==$AA",
+	"$pbkdf2-sha512$1$A-_A$AA",
+	"$pbkdf2-sha512$1$AB$AA",
+	"$pbkdf2-sha512$1$A\n$AA",
+	"$pbkdf2-sha512$210000$KuwdBW88vV7YiVGWsMmc8g",
+	"$pbkdf2-sha512$210000",
+	"$pbkdf2-sha512$",
+	"$$$$",
+	"$pbkdf2-sha512$tenant=acme$1$AA$AA",
+	"$pbkdf2-sha512$norm=x$1$AA$AA",
+	"$pbkdf2-sha512$stages=4000000000$1$AA$AA",
+	"$pbkdf2-sha512$b=2,a=1$1$AA$AA",
+	"$pbkdf2-sha512$a=1,a=1$1$AA$AA",
+	"$pbkdf2-sha512$a=$1$AA$AA",
+	"$pbkdf2-sha512$=1$1$AA$AA",
+	"$pbkdf2-sha512$1$AA$AA$",
+	"",
+}
+
+func FuzzDecodeHash(f *testing.F) {
+	for _, hash := range fuzzSeedHashes {
+		f.Add(hash)
+	}
+
+	f.Fuzz(func(t *testing.T, hash string) {
+		params, salt, key, err := DecodeHash(hash)
+		if err != nil {
+			return
+		}
+
+		if params.Iterations == 0 {
+			t.Fatalf("accepted zero iterations in %q", hash)
+		}
+		if uint32(len(salt)) != params.SaltLength || uint32(len(key)) != params.KeyLength {
+			t.Fatalf("params %#v do not match salt and key lengths %d/%d", *params, len(salt), len(key))
+		}
+
+		// Metadata may appear in any order, so only hashes that are already
+		// canonical are expected to re-encode to the same string.
+		h, err := ParseHash(hash)
+		if err != nil {
+			t.Fatalf("ParseHash rejected %q accepted by DecodeHash: %v", hash, err)
+		}
+		canonical := h.String()
+		reparsed, err := ParseHash(canonical)
+		if err != nil {
+			t.Fatalf("re-encoded hash %q does not parse: %v", canonical, err)
+		}
+		if got := reparsed.String(); got != canonical {
+			t.Fatalf("hash %q re-encoded as %q", canonical, got)
+		}
+		if len(h.Metadata) <= 1 && canonical != hash {
+			t.Fatalf("hash %q re-encoded as %q", hash, canonical)
+		}
+	})
+}
+
+func FuzzCheckHash(f *testing.F) {
+	for _, hash := range fuzzSeedHashes {
+		f.Add("bug", hash)
+	}
+
+	f.Fuzz(func(t *testing.T, password, hash string) {
+		params, _, _, err := DecodeHash(hash)
+		if err != nil {
+			return
+		}
+		// Keep each input cheap so the fuzzer spends its time in the parser,
+		// counting the iterations of any stages added by Strengthen.
+		h, err := ParseHash(hash)
+		if err != nil {
+			t.Fatalf("ParseHash rejected %q accepted by DecodeHash: %v", hash, err)
+		}
+		if total, err := totalIterations(h); err != nil || total > 1<<10 || params.KeyLength > 1<<10 {
+			return
+		}
+
+		_, checkParams, err := CheckHash(password, hash)
+		if errors.Is(err, ErrUnknownNormalization) {
+			// The normalization is recorded in the hash, and only
+			// registered ones can be applied.
+			return
+		}
+		if err != nil {
+			t.Fatalf("CheckHash rejected %q accepted by DecodeHash: %v", hash, err)
+		}
+		if *checkParams != *params {
+			t.Fatalf("CheckHash params %#v differ from DecodeHash params %#v", *checkParams, *params)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+// Package fxa implements the password stretching of Firefox Accounts' onepw
+// protocol, so that Go sync clients can log in to accounts and unwrap their
+// keys.
+//
+// The client stretches the password with PBKDF2-HMAC-SHA256, 1000 iterations,
+// salted with a fixed prefix and the account's email address. The result,
+// quickStretchedPW, never leaves the client; the server authenticates authPW,
+// and unwrapBKey unwraps the account's kB key, both expanded from it with
+// HKDF-SHA256:
+//
+//	stretched, err := fxa.QuickStretch(email, password)
+//	authPW, err := fxa.AuthPW(stretched)
+//	unwrapBKey, err := fxa.UnwrapBKey(stretched)
+//
+// Version 2 of the key stretching, with a random client salt, is not
+// supported.
+package fxa
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+	"runtime"
+
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// Iterations is the PBKDF2 iteration count of quickStretch.
+const Iterations = 1000
+
+// KeySize is the length of quickStretchedPW and of the keys expanded from it.
+const KeySize = 32
+
+// The prefixes of the quickStretch salt and of the HKDF info strings.
+const (
+	QuickStretchPrefix = "identity.mozilla.com/picl/v1/quickStretch:"
+	AuthPWInfo         = "identity.mozilla.com/picl/v1/authPW"
+	UnwrapBKeyInfo     = "identity.mozilla.com/picl/v1/unwrapBkey"
+)
+
+// ErrEmptyEmail is returned by QuickStretch if the email address is empty.
+var ErrEmptyEmail = errors.New("fxa: email address is empty")
+
+// QuickStretch derives quickStretchedPW from the account's email address and
+// password, encoded as UTF-8. The email address must be exactly as entered
+// when the account was created; Firefox Accounts does not normalize it for
+// the salt. The caller should destroy the key once it is no longer needed.
+func QuickStretch(email, password string) (*pbkdf2.SecureBytes, error) {
+	if email == "" {
+		return nil, ErrEmptyEmail
+	}
+	secret := pbkdf2.SecureBytesFromString(password)
+	defer secret.Destroy()
+
+	salt := QuickStretchPrefix + email
+	return pbkdf2.DeriveKey(secret, []byte(salt), &pbkdf2.Params{
+		Iterations: Iterations,
+		SaltLength: uint32(len(salt)),
+		KeyLength:  KeySize,
+		Variant:    pbkdf2.VariantSHA256,
+	})
+}
+
+// AuthPW returns authPW, which the client sends to the server to log in or
+// create the account. The caller should destroy it once it is no longer
+// needed.
+func AuthPW(quickStretchedPW *pbkdf2.SecureBytes) (*pbkdf2.SecureBytes, error) {
+	return expand(quickStretchedPW, AuthPWInfo)
+}
+
+// UnwrapBKey returns unwrapBKey, which unwraps the wrapped kB key returned by
+// the server. The caller should destroy it once it is no longer needed.
+func UnwrapBKey(quickStretchedPW *pbkdf2.SecureBytes) (*pbkdf2.SecureBytes, error) {
+	return expand(quickStretchedPW, UnwrapBKeyInfo)
+}
+
+// expand derives a key from quickStretchedPW with HKDF-SHA256, an empty salt
+// and the given info.
+func expand(quickStretchedPW *pbkdf2.SecureBytes, info string) (*pbkdf2.SecureBytes, error) {
+	key := make([]byte, KeySize)
+	_, err := io.ReadFull(hkdf.New(sha256.New, quickStretchedPW.Bytes(), nil, []byte(info)), key)
+	runtime.KeepAlive(quickStretchedPW)
+	if err != nil {
+		return nil, err
+	}
+	return pbkdf2.NewSecureBytes(key), nil
+}
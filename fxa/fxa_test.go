@@ -0,0 +1,53 @@
+package fxa
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// The vectors are those of the onepw protocol documentation, and were checked
+// with Python's hashlib and hmac:
+//
+//	stretched = hashlib.pbkdf2_hmac("sha256", password, prefix + email, 1000, 32)
+//	HKDF-SHA256(stretched, salt=b"", info=info, length=32)
+const (
+	testEmail    = "andré@example.org"
+	testPassword = "pässwörd"
+
+	testQuickStretchedPW = "e4e8889bd8bd61ad6de6b95c059d56e7b50dacdaf62bd84644af7e2add84345d"
+	testAuthPW           = "247b675ffb4c46310bc87e26d712153abe5e1c90ef00a4784594f97ef54f2375"
+	testUnwrapBKey       = "de6a2648b78284fcb9ffa81ba95803309cfba7af583c01a8a1a63e567234dd28"
+)
+
+func TestVectors(t *testing.T) {
+	stretched, err := QuickStretch(testEmail, testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stretched.Destroy()
+	if got := hex.EncodeToString(stretched.Bytes()); got != testQuickStretchedPW {
+		t.Errorf("quickStretchedPW = %s, want %s", got, testQuickStretchedPW)
+	}
+
+	authPW, err := AuthPW(stretched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(authPW.Bytes()); got != testAuthPW {
+		t.Errorf("authPW = %s, want %s", got, testAuthPW)
+	}
+
+	unwrapBKey, err := UnwrapBKey(stretched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(unwrapBKey.Bytes()); got != testUnwrapBKey {
+		t.Errorf("unwrapBKey = %s, want %s", got, testUnwrapBKey)
+	}
+}
+
+func TestEmptyEmail(t *testing.T) {
+	if _, err := QuickStretch("", testPassword); err != ErrEmptyEmail {
+		t.Fatalf("expected ErrEmptyEmail, got %v", err)
+	}
+}
@@ -2,4 +2,8 @@ module github.com/pganguli/pbkdf2
 
 go 1.19
 
-require golang.org/x/crypto v0.5.0
+require (
+	golang.org/x/crypto v0.5.0
+	golang.org/x/sys v0.4.0
+	golang.org/x/term v0.4.0
+)
@@ -0,0 +1,111 @@
+package pbkdf2
+
+import "testing"
+
+func TestCostForYear(t *testing.T) {
+	tests := []struct {
+		variant    string
+		year       int
+		iterations uint32
+	}{
+		{VariantSHA512, 2010, 10000},
+		{VariantSHA512, 2020, 10000},
+		{VariantSHA512, 2021, 120000},
+		{VariantSHA256, 2022, 310000},
+		{"", 2023, 210000},
+		{VariantSHA256, 2023, 600000},
+		{VariantBLAKE2b, 2023, 210000},
+		// Extrapolated: doubling every two years.
+		{VariantSHA512, 2024, 297000},
+		{VariantSHA512, 2025, 420000},
+		{VariantSHA256, 2027, 2400000},
+		{VariantSHA256, 2100, MaxIterations},
+	}
+	for _, tt := range tests {
+		params, err := CostForYear(tt.variant, tt.year)
+		if err != nil {
+			t.Fatalf("CostForYear(%q, %d): %v", tt.variant, tt.year, err)
+		}
+		if params.Iterations != tt.iterations {
+			t.Errorf("CostForYear(%q, %d) = %d iterations, want %d", tt.variant, tt.year, params.Iterations, tt.iterations)
+		}
+		if err := params.Validate(); err != nil {
+			t.Errorf("CostForYear(%q, %d): %v", tt.variant, tt.year, err)
+		}
+	}
+
+	params, _ := CostForYear(VariantSHA256, 2023)
+	want := Params{Iterations: 600000, SaltLength: 16, KeyLength: 32, Variant: VariantSHA256}
+	if *params != want {
+		t.Errorf("CostForYear(VariantSHA256, 2023) = %+v, want %+v", params, want)
+	}
+	params, _ = CostForYear(VariantSHA512, 2023)
+	if *params != *DefaultParams {
+		t.Errorf("CostForYear(VariantSHA512, 2023) = %+v, want DefaultParams", params)
+	}
+
+	for _, variant := range []string{VariantLegacySHA1, "pbkdf2-unknown"} {
+		if _, err := CostForYear(variant, 2023); err != ErrIncompatibleVariant {
+			t.Errorf("CostForYear(%q): expected ErrIncompatibleVariant, got %v", variant, err)
+		}
+	}
+}
+
+func TestCostForYearRehash(t *testing.T) {
+	old, _ := CostForYear("", 2021)
+	old.Iterations = 1000
+	hash, err := CreateHash("pa$$word", old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, _ := CostForYear("", 2023)
+	if !NeedsRehash(hash, current) {
+		t.Error("expected hash below the current year's cost to need rehashing")
+	}
+}
+
+func TestRecommendedMinIterationsMatchesGuidance(t *testing.T) {
+	for variant, n := range RecommendedMinIterations() {
+		if got, _ := iterationsForYear(variant, currentGuidance().year); got != n {
+			t.Errorf("%s: RecommendedMinIterations %d, current guidance %d", variant, n, got)
+		}
+	}
+}
+
+func TestIsBelowCurrentGuidance(t *testing.T) {
+	if below, report := IsBelowCurrentGuidance(nil); below {
+		t.Errorf("default params below guidance: %v", report)
+	}
+	below, report := IsBelowCurrentGuidance(&Params{Iterations: 600000, SaltLength: 16, KeyLength: 32, Variant: VariantSHA256})
+	if below || len(report.Shortfalls) != 0 || report.Year != 2023 || report.Variant != VariantSHA256 {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	below, report = IsBelowCurrentGuidance(&Params{Iterations: 210000, SaltLength: 8, KeyLength: 8, Variant: VariantSHA256})
+	if !below {
+		t.Fatal("expected params to be below guidance")
+	}
+	want := []GuidanceShortfall{
+		{Param: "iterations", Value: 210000, Recommended: 600000},
+		{Param: "salt length", Value: 8, Recommended: 16},
+		{Param: "key length", Value: 8, Recommended: 16},
+	}
+	if len(report.Shortfalls) != len(want) {
+		t.Fatalf("shortfalls %+v, want %+v", report.Shortfalls, want)
+	}
+	for i := range want {
+		if report.Shortfalls[i] != want[i] {
+			t.Errorf("shortfall %d = %+v, want %+v", i, report.Shortfalls[i], want[i])
+		}
+	}
+	msg := "pbkdf2: pbkdf2-sha256 params are below OWASP Password Storage Cheat Sheet (2023): " +
+		"iterations 210000 is below the recommended 600000; salt length 8 is below the recommended 16; key length 8 is below the recommended 16"
+	if report.Error() != msg {
+		t.Errorf("Error() = %q, want %q", report.Error(), msg)
+	}
+
+	below, report = IsBelowCurrentGuidance(&Params{Iterations: 2000000, SaltLength: 16, KeyLength: 20, Variant: VariantLegacySHA1})
+	if !below || len(report.Shortfalls) != 1 || report.Shortfalls[0].Param != "variant" {
+		t.Errorf("legacy SHA-1: unexpected report %+v", report)
+	}
+}
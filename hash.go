@@ -0,0 +1,200 @@
+package pbkdf2
+
+import (
+	"sort"
+	"strings"
+)
+
+// Hash is a decoded hash, as produced by CreateHash and parsed by ParseHash.
+type Hash struct {
+	// The params the hash was created with.
+	Params Params
+
+	// The salt and derived key (password hash).
+	Salt []byte
+	Key  []byte
+
+	// Metadata holds optional annotations recorded in the hash. Keys consist of
+	// lowercase letters, digits and hyphens, and must start with a letter.
+	// Values are non-empty and consist of letters, digits and the characters
+	// "+/.:_-". Metadata is encoded as a comma-separated list of key=value
+	// pairs, sorted by key, between the variant and the iterations:
+	//
+	//	$pbkdf2-sha512$tenant=acme$210000$yvu2ZftdlhcP4Tbpe2TYqA$XJsU2xkz...
+	Metadata map[string]string
+}
+
+// ParseHash parses a hash created from this package. It accepts the same input
+// as DecodeHash, and additionally returns any metadata recorded in the hash.
+func ParseHash(hash string) (*Hash, error) {
+	return decodeHash(hash)
+}
+
+// Variant returns the PBKDF2 variant of the hash, as it appears in the encoded
+// form.
+func (h *Hash) Variant() string {
+	if h.Params.Variant == "" {
+		return VariantSHA512
+	}
+	return h.Params.Variant
+}
+
+// String returns the encoded form of the hash. It does not check that the hash
+// is valid; use MarshalText for that.
+func (h *Hash) String() string {
+	return encodeHash(h.Params.Variant, h.Params.Iterations, h.Salt, h.Key, h.Metadata)
+}
+
+// MarshalText implements encoding.TextMarshaler. It returns ErrInvalidHash if
+// the hash could not be parsed back, for example because the salt or key is
+// empty, or the metadata contains invalid characters, and
+// ErrIncompatibleVariant if the variant is not supported.
+func (h *Hash) MarshalText() ([]byte, error) {
+	if err := h.check(); err != nil {
+		return nil, err
+	}
+	return []byte(h.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using ParseHash.
+func (h *Hash) UnmarshalText(text []byte) error {
+	parsed, err := ParseHash(string(text))
+	if err != nil {
+		return err
+	}
+	*h = *parsed
+	return nil
+}
+
+func (h *Hash) check() error {
+	if _, ok := variantPRF(h.Params.Variant); !ok {
+		return ErrIncompatibleVariant
+	}
+	if h.Params.Iterations == 0 || len(h.Salt) == 0 || len(h.Key) == 0 ||
+		uint32(len(h.Salt)) != h.Params.SaltLength || uint32(len(h.Key)) != h.Params.KeyLength {
+		return ErrInvalidHash
+	}
+	for k, v := range h.Metadata {
+		if !validMetadataKey(k) || !validMetadataValue(v) {
+			return ErrInvalidHash
+		}
+	}
+	if total, err := totalIterations(h); err != nil || total > MaxIterations {
+		return ErrInvalidHash
+	}
+	return nil
+}
+
+// decodePHC decodes a hash in FormatPHC.
+func decodePHC(hash string) (*Hash, error) {
+	vals := strings.Split(hash, "$")
+	if (len(vals) != 5 && len(vals) != 6) || vals[0] != "" {
+		return nil, decodeError("format", nil)
+	}
+
+	if _, ok := variantPRF(vals[1]); !ok || vals[1] == "" {
+		return nil, ErrIncompatibleVariant
+	}
+
+	h := &Hash{}
+	if vals[1] != VariantSHA512 {
+		h.Params.Variant = vals[1]
+	}
+	if len(vals) == 6 {
+		metadata, err := parseMetadata(vals[2])
+		if err != nil {
+			return nil, err
+		}
+		h.Metadata = metadata
+		vals = append(vals[:2], vals[3:]...)
+	}
+
+	iterations, err := parseIterations(vals[2])
+	if err != nil {
+		return nil, err
+	}
+	h.Params.Iterations = iterations
+	if total, err := totalIterations(h); err != nil {
+		return nil, err
+	} else if total > MaxIterations {
+		return nil, decodeError("metadata", ErrIterationsOverflow)
+	}
+
+	h.Salt, err = decodeBase64(vals[3])
+	if err != nil {
+		return nil, decodeError("salt", err)
+	}
+	h.Params.SaltLength = uint32(len(h.Salt))
+
+	h.Key, err = decodeBase64(vals[4])
+	if err != nil {
+		wipe(h.Salt)
+		return nil, decodeError("key", err)
+	}
+	h.Params.KeyLength = uint32(len(h.Key))
+
+	return h, nil
+}
+
+func parseMetadata(s string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		i := strings.IndexByte(pair, '=')
+		if i < 0 {
+			return nil, decodeError("metadata", nil)
+		}
+		k, v := pair[:i], pair[i+1:]
+		if !validMetadataKey(k) || !validMetadataValue(v) {
+			return nil, decodeError("metadata", nil)
+		}
+		if _, ok := metadata[k]; ok {
+			return nil, decodeError("metadata", nil)
+		}
+		metadata[k] = v
+	}
+	return metadata, nil
+}
+
+func appendMetadata(b []byte, metadata map[string]string) []byte {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for i, k := range keys {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, k...)
+		b = append(b, '=')
+		b = append(b, metadata[k]...)
+	}
+	return b
+}
+
+func validMetadataKey(k string) bool {
+	if k == "" || k[0] < 'a' || k[0] > 'z' {
+		return false
+	}
+	for i := 0; i < len(k); i++ {
+		c := k[i]
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func validMetadataValue(v string) bool {
+	if v == "" {
+		return false
+	}
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if !('A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("+/.:_-", c) >= 0) {
+			return false
+		}
+	}
+	return true
+}
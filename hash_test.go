@@ -0,0 +1,72 @@
+package pbkdf2
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseHashMetadata(t *testing.T) {
+	hash := "$pbkdf2-sha512$profile=admin,tenant=acme$1000$KuwdBW88vV7YiVGWsMmc8g$XO+ztCemYHheH1kqHe6QAmb99lL3MI7IeBQ05dnAXGk"
+
+	h, err := ParseHash(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Params.Iterations != 1000 || h.Params.SaltLength != 16 || h.Params.KeyLength != 32 {
+		t.Fatalf("unexpected params %#v", h.Params)
+	}
+	if len(h.Metadata) != 2 || h.Metadata["tenant"] != "acme" || h.Metadata["profile"] != "admin" {
+		t.Fatalf("unexpected metadata %#v", h.Metadata)
+	}
+	if got := h.String(); got != hash {
+		t.Fatalf("expected %q got %q", hash, got)
+	}
+
+	// Metadata does not affect DecodeHash.
+	params, salt, key, err := DecodeHash(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *params != h.Params || !bytes.Equal(salt, h.Salt) || !bytes.Equal(key, h.Key) {
+		t.Fatal("DecodeHash and ParseHash disagree")
+	}
+
+	// Pairs are re-encoded sorted by key.
+	h, err = ParseHash("$pbkdf2-sha512$tenant=acme,profile=admin$1000$KuwdBW88vV7YiVGWsMmc8g$XO+ztCemYHheH1kqHe6QAmb99lL3MI7IeBQ05dnAXGk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := h.String(); got != hash {
+		t.Fatalf("expected %q got %q", hash, got)
+	}
+}
+
+func TestHashMarshalText(t *testing.T) {
+	hash, err := CreateHash("pa$$word", &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var h Hash
+	if err := h.UnmarshalText([]byte(hash)); err != nil {
+		t.Fatal(err)
+	}
+	h.Metadata = map[string]string{"tenant": "acme"}
+	text, err := h.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	match, err := ComparePasswordAndHash("pa$$word", string(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !match {
+		t.Error("expected password and hash to match")
+	}
+
+	h.Metadata["tenant"] = "a$b"
+	if _, err := h.MarshalText(); err != ErrInvalidHash {
+		t.Fatalf("expected ErrInvalidHash, got %v", err)
+	}
+}
@@ -0,0 +1,448 @@
+package pbkdf2
+
+import (
+	"context"
+	"time"
+)
+
+// A Hasher creates and verifies hashes using a fixed set of params and
+// options. The zero value uses the package-level default params and the same
+// options as the package-level functions. A Hasher must not be modified while
+// in use, but is otherwise safe for concurrent use.
+type Hasher struct {
+	// Params are used to create new hashes, and as the target for
+	// NeedsRehash. If nil, the package-level default params returned by
+	// GetDefaultParams are used.
+	Params *Params
+
+	// AllowLegacySHA1 enables verification of PBKDF2-HMAC-SHA1 hashes in the
+	// $pbkdf2$ format described by VariantLegacySHA1. Without it, they are
+	// rejected with ErrIncompatibleVariant. Hashes are never created with
+	// SHA-1, and NeedsRehash always reports that SHA-1 hashes need replacing.
+	AllowLegacySHA1 bool
+
+	// Policy, if set, is checked against the params of every hash before it
+	// is verified. Hashes that violate it are rejected with a *PolicyError
+	// without deriving a key, which also guards against hashes whose
+	// iteration count is high enough to make verifying them a denial of
+	// service.
+	Policy *Policy
+
+	// Namespace, if set, scopes new hashes to a namespace such as a tenant
+	// ID: it is mixed into the salt with NamespaceSalt, and recorded in the
+	// hash's metadata so that the hash verifies with the package-level
+	// functions. Hashes from any other namespace, or from none, are rejected
+	// with ErrNamespaceMismatch, and reported by NeedsRehash. It must satisfy
+	// ValidNamespace.
+	Namespace string
+
+	// Normalization, if set, names a normalization registered with
+	// RegisterNormalization, which is applied to passwords before new hashes
+	// are created and recorded in the hashes. Hashes are always verified with
+	// the normalization they record, whatever h.Normalization is, and
+	// NeedsRehash reports those whose normalization differs.
+	Normalization string
+
+	// Profiles are named params for different classes of account, such as
+	// "admin" or "service", selected with CreateHashWithProfile or Profile.
+	// Hashes created with a profile record its name as metadata, and
+	// NeedsRehash compares them against the profile's current definition
+	// rather than against Params, so that each class can be re-costed
+	// independently. Names must satisfy ValidNamespace. Names not in Profiles
+	// fall back to the built-in profiles, such as ProfileSensitive.
+	Profiles map[string]*Params
+
+	// Profile, if set, names the profile used by CreateHash and ConvertHash
+	// in place of Params.
+	Profile string
+
+	// MinFailureDuration, if set, is the minimum time taken by a failed
+	// verification, whether the password did not match or the hash was
+	// rejected, to slow online guessing. Failures that finish sooner are
+	// padded, measured on the monotonic clock; successful verifications are
+	// not delayed.
+	MinFailureDuration time.Duration
+
+	// Clock, if set, replaces SystemClock for measuring and padding failures
+	// to MinFailureDuration, so that padding can be tested without waiting.
+	// The durations reported to OnUsage and by CheckHashTimed are always
+	// measured on the system clock, as they describe real work.
+	Clock Clock
+
+	// DecodeCache, if set, caches decoded hashes for verification, so that
+	// hashes verified repeatedly are not parsed each time.
+	DecodeCache *DecodeCache
+
+	// OnUsage, if set, is called after every key derivation, whether creating
+	// or verifying a hash, with the time and CPU time it took, so that
+	// hashing can be metered, for example per tenant. It is not called for
+	// hashes rejected before a key is derived. It is called synchronously,
+	// and must be safe for concurrent use.
+	OnUsage func(Usage)
+
+	// OnEvent, if set, is called after every hash is created or verified,
+	// successfully or not, for audit logging. Like OnUsage, it receives the
+	// labels of the context given to the ...Context methods; see WithLabels.
+	// It is called synchronously, and must be safe for concurrent use.
+	OnEvent func(Event)
+}
+
+// An Event describes a hash created or verified by a Hasher. See
+// Hasher.OnEvent.
+type Event struct {
+	// Op is UsageHash or UsageVerify.
+	Op string
+
+	// Labels are those of the context of the call; see WithLabels.
+	Labels map[string]string
+
+	// Params are the params of the hash, or nil if a hash to verify could not
+	// be decoded.
+	Params *Params
+
+	// Match reports whether a verified password matched. Err is the error
+	// returned to the caller, other than ErrMismatchedHashAndPassword.
+	Match bool
+	Err   error
+}
+
+func (h *Hasher) transforms() transforms {
+	return transforms{namespace: h.Namespace, normalization: h.Normalization, profile: h.Profile}
+}
+
+// params returns the params for new hashes: those of h.Profile if it is set,
+// or an invalid zero Params if it is unknown, so that creating hashes fails
+// rather than silently using other params.
+func (h *Hasher) params() *Params {
+	if h.Profile != "" {
+		params, err := h.profileParams(h.Profile)
+		if err != nil {
+			return &Params{}
+		}
+		return params
+	}
+	if h.Params == nil {
+		return GetDefaultParams()
+	}
+	return h.Params
+}
+
+// profileParams returns the params of the named profile, from h.Profiles or
+// the built-in profiles.
+func (h *Hasher) profileParams(name string) (*Params, error) {
+	if !ValidNamespace(name) {
+		return nil, ErrUnknownProfile
+	}
+	if params, ok := h.Profiles[name]; ok && params != nil {
+		return params, nil
+	}
+	return ProfileParams(name)
+}
+
+// CreateHash is like the package-level CreateHash, using h.Params.
+func (h *Hasher) CreateHash(password string) (hash string, err error) {
+	return h.CreateHashContext(context.Background(), password)
+}
+
+// CreateHashContext is like CreateHash, passing the labels of ctx to h's
+// hooks.
+func (h *Hasher) CreateHashContext(ctx context.Context, password string) (hash string, err error) {
+	secret := SecureBytesFromString(password)
+	defer secret.Destroy()
+
+	return h.createHash(ctx, secret, h.params())
+}
+
+// CreateHashWithProfile is like CreateHashContext, using the params of the
+// named profile from h.Profiles, or the built-in profiles, and recording its
+// name in the hash. It returns ErrUnknownProfile if there is no such profile.
+func (h *Hasher) CreateHashWithProfile(ctx context.Context, password, profile string) (hash string, err error) {
+	params, err := h.profileParams(profile)
+	if err != nil {
+		return "", err
+	}
+	secret := SecureBytesFromString(password)
+	defer secret.Destroy()
+
+	withProfile := *h
+	withProfile.Profile = profile
+	return withProfile.createHash(ctx, secret, params)
+}
+
+// createHash is the package-level createHash with h's transforms, reporting
+// to h's hooks.
+func (h *Hasher) createHash(ctx context.Context, password *SecureBytes, params *Params) (hash string, err error) {
+	if h.Profile != "" {
+		if _, err := h.profileParams(h.Profile); err != nil {
+			return "", err
+		}
+	}
+	if h.OnUsage == nil && h.OnEvent == nil {
+		return createHash(password, params, h.transforms())
+	}
+	wall, cpu := measure(func() {
+		hash, err = createHash(password, params, h.transforms())
+	})
+	labels := LabelsFromContext(ctx)
+	if h.OnUsage != nil && err == nil {
+		h.OnUsage(Usage{Op: UsageHash, Labels: labels, Params: params, Wall: wall, CPU: cpu})
+	}
+	if h.OnEvent != nil {
+		h.OnEvent(Event{Op: UsageHash, Labels: labels, Params: params, Err: err})
+	}
+	return hash, err
+}
+
+// CheckHash is like the package-level CheckHash, subject to h's options.
+func (h *Hasher) CheckHash(password, hash string) (match bool, params *Params, err error) {
+	return h.CheckHashContext(context.Background(), password, hash)
+}
+
+// CheckHashContext is like CheckHash, passing the labels of ctx to h's hooks.
+func (h *Hasher) CheckHashContext(ctx context.Context, password, hash string) (match bool, params *Params, err error) {
+	secret := SecureBytesFromString(password)
+	defer secret.Destroy()
+
+	return h.checkHashSecure(ctx, secret, hash)
+}
+
+// CheckHashSecure is like CheckHash, except the password is provided as a
+// SecureBytes.
+func (h *Hasher) CheckHashSecure(password *SecureBytes, hash string) (match bool, params *Params, err error) {
+	return h.checkHashSecure(context.Background(), password, hash)
+}
+
+func (h *Hasher) checkHashSecure(ctx context.Context, password *SecureBytes, hash string) (match bool, params *Params, err error) {
+	result, err := h.checkHash(ctx, password, hash)
+	if err != nil {
+		if result != nil {
+			return false, result.Params, err
+		}
+		return false, nil, err
+	}
+	return result.Match, result.Params, nil
+}
+
+// CheckHashTimed is like the package-level CheckHashTimed, subject to h's
+// options.
+func (h *Hasher) CheckHashTimed(password, hash string) (*CheckResult, error) {
+	secret := SecureBytesFromString(password)
+	defer secret.Destroy()
+
+	return h.checkHash(context.Background(), secret, hash)
+}
+
+// checkHash is verifyHash, reporting to h's hooks, and padded to
+// h.MinFailureDuration if it fails.
+func (h *Hasher) checkHash(ctx context.Context, password *SecureBytes, hash string) (*CheckResult, error) {
+	clock := clockOrSystem(h.Clock)
+	start := clock.Now()
+	result, err := h.verifyHash(password, hash)
+	h.reportCheck(ctx, result, err)
+	if h.MinFailureDuration <= 0 {
+		return result, err
+	}
+	if err != nil || !result.Match {
+		// The padding must not end early, or its end would reveal when
+		// ctx was cancelled rather than hide how long verifying took.
+		clock.Sleep(context.Background(), h.MinFailureDuration-clock.Since(start))
+	}
+	return result, err
+}
+
+// reportCheck reports the outcome of verifying a hash to h's hooks.
+func (h *Hasher) reportCheck(ctx context.Context, result *CheckResult, err error) {
+	if h.OnUsage == nil && h.OnEvent == nil {
+		return
+	}
+	labels := LabelsFromContext(ctx)
+	if h.OnUsage != nil && err == nil {
+		h.OnUsage(Usage{Op: UsageVerify, Labels: labels, Params: result.Params, Wall: result.Duration, CPU: result.CPUTime})
+	}
+	if h.OnEvent != nil {
+		e := Event{Op: UsageVerify, Labels: labels, Err: err}
+		if result != nil {
+			e.Params, e.Match = result.Params, result.Match
+		}
+		h.OnEvent(e)
+	}
+}
+
+// verifyHash returns a result with only Params set along with a *PolicyError
+// if the hash violates h.Policy.
+func (h *Hasher) verifyHash(password *SecureBytes, hash string) (*CheckResult, error) {
+	if err := h.checkNamespace(hash); err != nil {
+		return nil, err
+	}
+	if h.Policy != nil {
+		decoded, err := h.decodeWiped(hash)
+		if err != nil {
+			return nil, err
+		}
+		if err := h.Policy.CheckHash(decoded); err != nil {
+			return &CheckResult{Params: &decoded.Params}, err
+		}
+	}
+
+	if h.AllowLegacySHA1 && isLegacySHA1(hash) {
+		return checkLegacySHA1(password, hash)
+	}
+	decoded, err := h.decode(hash)
+	if err != nil {
+		return nil, err
+	}
+	return checkDecoded(password, decoded)
+}
+
+// decode is decodeHash, using h.DecodeCache if it is set.
+func (h *Hasher) decode(hash string) (*Hash, error) {
+	if h.DecodeCache == nil {
+		return decodeHash(hash)
+	}
+	return h.DecodeCache.decode(hash)
+}
+
+// checkNamespace returns ErrNamespaceMismatch if h has a namespace that hash
+// does not belong to.
+func (h *Hasher) checkNamespace(hash string) error {
+	if h.Namespace == "" {
+		return nil
+	}
+	if isLegacySHA1(hash) {
+		return ErrNamespaceMismatch
+	}
+	decoded, err := h.decode(hash)
+	if err != nil {
+		return err
+	}
+	wipe(decoded.Salt)
+	wipe(decoded.Key)
+	if decoded.Metadata[MetadataNamespace] != h.Namespace {
+		return ErrNamespaceMismatch
+	}
+	return nil
+}
+
+// decodeWiped decodes hash subject to h's options, wiping its salt and key,
+// for its params and metadata.
+func (h *Hasher) decodeWiped(hash string) (*Hash, error) {
+	decode := h.decode
+	if isLegacySHA1(hash) {
+		decode = func(hash string) (*Hash, error) {
+			return decodeFormat(hash, h.AllowLegacySHA1)
+		}
+	}
+	decoded, err := decode(hash)
+	if err != nil {
+		return nil, err
+	}
+	wipe(decoded.Salt)
+	wipe(decoded.Key)
+	return decoded, nil
+}
+
+// Verify is like the package-level Verify, subject to h's options.
+func (h *Hasher) Verify(password, hash string) error {
+	return h.VerifyContext(context.Background(), password, hash)
+}
+
+// VerifyContext is like Verify, passing the labels of ctx to h's hooks.
+func (h *Hasher) VerifyContext(ctx context.Context, password, hash string) error {
+	match, _, err := h.CheckHashContext(ctx, password, hash)
+	if err != nil {
+		return err
+	}
+	if !match {
+		return ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+// NeedsRehash is like the package-level NeedsRehash, using h.Params, or for
+// hashes that record a profile, the current params of that profile; hashes
+// recording a profile that no longer exists need rehashing. It also reports
+// hashes outside h.Namespace, or with a normalization other than
+// h.Normalization.
+func (h *Hasher) NeedsRehash(hash string) bool {
+	if h.checkNamespace(hash) != nil {
+		return true
+	}
+	decoded, err := decodeHash(hash)
+	if err != nil {
+		return true
+	}
+	wipe(decoded.Salt)
+	wipe(decoded.Key)
+
+	params := h.params()
+	if profile, ok := decoded.Metadata[MetadataProfile]; ok {
+		if params, err = h.profileParams(profile); err != nil {
+			return true
+		}
+	}
+	return NeedsRehash(hash, params) || decoded.Metadata[MetadataNormalization] != h.Normalization
+}
+
+// ConvertHash is like the package-level ConvertHash, using h.Params for the
+// new hash and subject to h's options when verifying the old one. With
+// AllowLegacySHA1, it can be used to migrate SHA-1 hashes as users log in.
+// The old hash may belong to any namespace, or none, so that existing hashes
+// can be moved into h.Namespace.
+func (h *Hasher) ConvertHash(password, oldHash string) (newHash string, err error) {
+	return h.ConvertHashContext(context.Background(), password, oldHash)
+}
+
+// ConvertHashContext is like ConvertHash, passing the labels of ctx to h's
+// hooks.
+func (h *Hasher) ConvertHashContext(ctx context.Context, password, oldHash string) (newHash string, err error) {
+	secret := SecureBytesFromString(password)
+	defer secret.Destroy()
+
+	// Check the new params first, so that a configuration error is not
+	// hidden behind the cost of verifying the old hash.
+	params := h.params()
+	if err := params.Validate(); err != nil {
+		return "", err
+	}
+
+	old := *h
+	old.Namespace = ""
+	match, _, err := old.checkHashSecure(ctx, secret, oldHash)
+	if err != nil {
+		return "", err
+	}
+	if !match {
+		return "", ErrMismatchedHashAndPassword
+	}
+	return h.createHash(ctx, secret, params)
+}
+
+// VerifyOldAndHashNew is like the package-level VerifyOldAndHashNew, using
+// h.Params for the new hash and subject to h's options when verifying the old
+// one.
+func (h *Hasher) VerifyOldAndHashNew(oldPassword, newPassword, hash string) (newHash string, err error) {
+	params := h.params()
+	if err := params.Validate(); err != nil {
+		return "", err
+	}
+
+	oldSecret := SecureBytesFromString(oldPassword)
+	defer oldSecret.Destroy()
+	newSecret := SecureBytesFromString(newPassword)
+	defer newSecret.Destroy()
+
+	match, _, err := h.CheckHashSecure(oldSecret, hash)
+	if err != nil {
+		return "", err
+	}
+	// The new hash is created whether or not the old password matched, so
+	// that the time taken does not reveal which it did.
+	newHash, err = h.createHash(context.Background(), newSecret, params)
+	if err != nil {
+		return "", err
+	}
+	if !match {
+		return "", ErrMismatchedHashAndPassword
+	}
+	return newHash, nil
+}
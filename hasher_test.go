@@ -0,0 +1,187 @@
+package pbkdf2
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Generated with Python's hashlib.pbkdf2_hmac("sha1", b"pa$$word", salt, 1000),
+// encoded in passlib's adapted base64 alphabet.
+var legacySHA1Hashes = []string{
+	"$pbkdf2$1000$SGVsbG8gU0hBMSBzYWx0IQ$PxlzvV9ZjdnYH5CCh11Qe/ni2Ls",
+	"$pbkdf2$1000$BwcHBwcHBwcHBwcHBwcHBw$F7DM4zDIIIxPuK9.WfIYoV5QdZM",
+}
+
+func TestHasherLegacySHA1(t *testing.T) {
+	legacy := &Hasher{AllowLegacySHA1: true}
+	for _, hash := range legacySHA1Hashes {
+		match, params, err := legacy.CheckHash("pa$$word", hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !match {
+			t.Errorf("expected %q to match", hash)
+		}
+		want := Params{Iterations: 1000, SaltLength: 16, KeyLength: 20, Variant: VariantLegacySHA1}
+		if *params != want {
+			t.Errorf("expected %#v got %#v", want, *params)
+		}
+		if err := legacy.Verify("otherPa$$word", hash); err != ErrMismatchedHashAndPassword {
+			t.Errorf("expected ErrMismatchedHashAndPassword, got %v", err)
+		}
+		if !legacy.NeedsRehash(hash) {
+			t.Errorf("expected %q to need rehashing", hash)
+		}
+
+		// Without the option, and with the package-level functions, SHA-1
+		// hashes are rejected.
+		if err := (&Hasher{}).Verify("pa$$word", hash); err != ErrIncompatibleVariant {
+			t.Errorf("expected ErrIncompatibleVariant, got %v", err)
+		}
+		if err := Verify("pa$$word", hash); err != ErrIncompatibleVariant {
+			t.Errorf("expected ErrIncompatibleVariant, got %v", err)
+		}
+	}
+
+	if err := legacy.Verify("pa$$word", "$pbkdf2$1000$SGVsbG8$"); !errors.Is(err, ErrInvalidHash) {
+		t.Errorf("expected ErrInvalidHash, got %v", err)
+	}
+
+	// SHA-1 can never be used to create hashes.
+	if _, err := CreateHash("pa$$word", &Params{Iterations: 1000, SaltLength: 16, KeyLength: 20, Variant: VariantLegacySHA1}); err != ErrIncompatibleVariant {
+		t.Errorf("expected ErrIncompatibleVariant, got %v", err)
+	}
+}
+
+func TestHasher(t *testing.T) {
+	h := &Hasher{Params: &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32}}
+	hash, err := h.CreateHash("pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Verify("pa$$word", hash); err != nil {
+		t.Fatal(err)
+	}
+	if h.NeedsRehash(hash) {
+		t.Error("expected hash created with the same params not to need rehashing")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	params := &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32}
+	hash, err := CreateHash("pa$$word", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		params *Params
+		want   bool
+	}{
+		{params, false},
+		{&Params{Iterations: 500, SaltLength: 8, KeyLength: 32}, false},
+		{&Params{Iterations: 1001, SaltLength: 16, KeyLength: 32}, true},
+		{&Params{Iterations: 1000, SaltLength: 32, KeyLength: 32}, true},
+		{&Params{Iterations: 1000, SaltLength: 16, KeyLength: 64}, true},
+		{&Params{Iterations: 1000, SaltLength: 16, KeyLength: 32, Variant: VariantSHA512}, false},
+		{&Params{Iterations: 1000, SaltLength: 16, KeyLength: 32, Variant: VariantSHA3_512}, true},
+		{nil, true},
+	}
+	for _, tt := range tests {
+		if got := NeedsRehash(hash, tt.params); got != tt.want {
+			t.Errorf("NeedsRehash(%#v) = %v, want %v", tt.params, got, tt.want)
+		}
+	}
+
+	if !NeedsRehash("$pbkdf2-sha512$1000$AA", params) {
+		t.Error("expected undecodable hash to need rehashing")
+	}
+}
+
+func TestConvertHash(t *testing.T) {
+	oldHash, err := CreateHash("pa$$word", &Params{Iterations: 1000, SaltLength: 8, KeyLength: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newParams := &Params{Iterations: 2000, SaltLength: 16, KeyLength: 64, Variant: VariantSHA3_512}
+	newHash, err := ConvertHash("pa$$word", oldHash, newParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	match, params, err := CheckHash("pa$$word", newHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !match || *params != *newParams {
+		t.Fatalf("expected match with %#v, got %v with %#v", *newParams, match, *params)
+	}
+
+	if newHash, err := ConvertHash("otherPa$$word", oldHash, newParams); err != ErrMismatchedHashAndPassword || newHash != "" {
+		t.Fatalf("expected ErrMismatchedHashAndPassword and no hash, got %q, %v", newHash, err)
+	}
+	if _, err := ConvertHash("pa$$word", oldHash, &Params{Iterations: 1000}); err != ErrInvalidParams {
+		t.Fatalf("expected ErrInvalidParams, got %v", err)
+	}
+	if _, err := ConvertHash("pa$$word", legacySHA1Hashes[0], newParams); err != ErrIncompatibleVariant {
+		t.Fatalf("expected ErrIncompatibleVariant, got %v", err)
+	}
+
+	// A Hasher allowing legacy SHA-1 hashes can migrate them.
+	h := &Hasher{Params: newParams, AllowLegacySHA1: true}
+	newHash, err = h.ConvertHash("pa$$word", legacySHA1Hashes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify("pa$$word", newHash); err != nil {
+		t.Fatal(err)
+	}
+	if h.NeedsRehash(newHash) {
+		t.Error("expected converted hash not to need rehashing")
+	}
+}
+
+func TestMinFailureDuration(t *testing.T) {
+	const minimum = 50 * time.Millisecond
+	h := &Hasher{Params: &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32}, MinFailureDuration: minimum}
+	hash, err := h.CreateHash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct{ password, hash string }{
+		{"wrong", hash},
+		{"password", "$pbkdf2-sha512$1000"},
+	} {
+		start := time.Now()
+		h.Verify(tc.password, tc.hash)
+		if elapsed := time.Since(start); elapsed < minimum {
+			t.Errorf("%q, %q: failure took %v, want at least %v", tc.password, tc.hash, elapsed, minimum)
+		}
+	}
+
+	if err := h.Verify("password", hash); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMinFailureDurationClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	h := &Hasher{Params: &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32}, MinFailureDuration: time.Hour, Clock: clock}
+	hash, err := h.CreateHash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Verify("wrong", hash)
+	if now := clock.Now(); !now.Equal(time.Unix(0, 0).Add(time.Hour)) {
+		t.Fatalf("expected the failure to be padded to an hour, clock reads %v", now)
+	}
+	if err := h.Verify("password", hash); err != nil {
+		t.Fatal(err)
+	}
+	if now := clock.Now(); !now.Equal(time.Unix(0, 0).Add(time.Hour)) {
+		t.Fatalf("expected success not to be padded, clock reads %v", now)
+	}
+}
@@ -0,0 +1,112 @@
+package pbkdf2
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MaxLineErrors is the number of errors a HashScanner retains. Further errors
+// are counted but not kept, so that scanning a file of garbage does not
+// exhaust memory.
+const MaxLineErrors = 1000
+
+// LineError records a line of input that could not be parsed as a hash.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return "pbkdf2: line " + strconv.Itoa(e.Line) + ": " + e.Err.Error()
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// A HashScanner reads newline-delimited hashes from an io.Reader, such as a
+// dump of a password column. Blank lines and lines starting with # are
+// skipped, as is surrounding whitespace. Lines that do not parse are recorded
+// and skipped, so that a single bad row does not stop the scan:
+//
+//	s := pbkdf2.NewHashScanner(f)
+//	for s.Scan() {
+//		h := s.Hash()
+//		// ...
+//	}
+//	if err := s.Err(); err != nil {
+//		// reading failed
+//	}
+//	for _, err := range s.Errors() {
+//		log.Print(err)
+//	}
+type HashScanner struct {
+	sc   *bufio.Scanner
+	line int
+	hash *Hash
+
+	errs     []*LineError
+	errCount int
+}
+
+// NewHashScanner returns a HashScanner reading from r. Lines may be up to
+// bufio.MaxScanTokenSize bytes long.
+func NewHashScanner(r io.Reader) *HashScanner {
+	return &HashScanner{sc: bufio.NewScanner(r)}
+}
+
+// Scan advances to the next hash, which is then available through Hash and
+// Line. It returns false at the end of the input or on a read error.
+func (s *HashScanner) Scan() bool {
+	s.hash = nil
+	for s.sc.Scan() {
+		s.line++
+		text := strings.TrimSpace(s.sc.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+
+		h, err := ParseHash(text)
+		if err != nil {
+			s.errCount++
+			if len(s.errs) < MaxLineErrors {
+				s.errs = append(s.errs, &LineError{Line: s.line, Err: err})
+			}
+			continue
+		}
+		s.hash = h
+		return true
+	}
+	return false
+}
+
+// Hash returns the hash found by the most recent call to Scan.
+func (s *HashScanner) Hash() *Hash {
+	return s.hash
+}
+
+// Line returns the line number, starting at 1, of the hash found by the most
+// recent call to Scan.
+func (s *HashScanner) Line() int {
+	return s.line
+}
+
+// Err returns the first error encountered reading the input, if any. Lines
+// that fail to parse are not reported here; see Errors.
+func (s *HashScanner) Err() error {
+	return s.sc.Err()
+}
+
+// Errors returns the lines scanned so far that failed to parse, up to
+// MaxLineErrors of them, in input order.
+func (s *HashScanner) Errors() []*LineError {
+	return s.errs
+}
+
+// ErrorCount returns the total number of lines scanned so far that failed to
+// parse, including any beyond MaxLineErrors.
+func (s *HashScanner) ErrorCount() int {
+	return s.errCount
+}
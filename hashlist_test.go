@@ -0,0 +1,61 @@
+package pbkdf2
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHashScanner(t *testing.T) {
+	input := strings.Join([]string{
+		"# exported 2024-01-01",
+		"$pbkdf2-sha512$1000$KuwdBW88vV7YiVGWsMmc8g$XO+ztCemYHheH1kqHe6QAmb99lL3MI7IeBQ05dnAXGk",
+		"",
+		"  $pbkdf2-sha256$tenant=acme$2000$KuwdBW88vV7YiVGWsMmc8g$XO+ztCemYHheH1kqHe6QAmb99lL3MI7IeBQ05dnAXGk\r",
+		"$pbkdf2-sha512$1000$AA",
+		"$2b$10$notapbkdf2hash",
+		"$pbkdf2-sha512$3000$KuwdBW88vV7YiVGWsMmc8g$XO+ztCemYHheH1kqHe6QAmb99lL3MI7IeBQ05dnAXGk",
+	}, "\n")
+
+	s := NewHashScanner(strings.NewReader(input))
+	var lines []int
+	var iterations []uint32
+	for s.Scan() {
+		lines = append(lines, s.Line())
+		iterations = append(iterations, s.Hash().Params.Iterations)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lines) != 3 || lines[0] != 2 || lines[1] != 4 || lines[2] != 7 {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+	if iterations[0] != 1000 || iterations[1] != 2000 || iterations[2] != 3000 {
+		t.Fatalf("unexpected iterations %v", iterations)
+	}
+
+	errs := s.Errors()
+	if len(errs) != 2 || s.ErrorCount() != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+	if errs[0].Line != 5 || !errors.Is(errs[0], ErrInvalidHash) {
+		t.Errorf("expected invalid hash on line 5, got %v", errs[0])
+	}
+	if errs[1].Line != 6 || !errors.Is(errs[1], ErrInvalidHash) {
+		t.Errorf("expected invalid hash on line 6, got %v", errs[1])
+	}
+	if want := "pbkdf2: line 5: "; !strings.HasPrefix(errs[0].Error(), want) {
+		t.Errorf("expected %q to start with %q", errs[0].Error(), want)
+	}
+}
+
+func TestHashScannerErrorLimit(t *testing.T) {
+	s := NewHashScanner(strings.NewReader(strings.Repeat("garbage\n", MaxLineErrors+10)))
+	for s.Scan() {
+		t.Fatal("expected no hashes")
+	}
+	if len(s.Errors()) != MaxLineErrors || s.ErrorCount() != MaxLineErrors+10 {
+		t.Fatalf("expected %d retained of %d errors, got %d of %d", MaxLineErrors, MaxLineErrors+10, len(s.Errors()), s.ErrorCount())
+	}
+}
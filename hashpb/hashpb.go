@@ -0,0 +1,233 @@
+// Package hashpb provides a Protocol Buffers representation of hashes produced
+// by the pbkdf2 package, for services that exchange credentials over gRPC and
+// want a typed message rather than an opaque string.
+//
+// The schema is defined in hash.proto. Services using protoc-gen-go can
+// generate their own bindings from it; the Hash type in this package
+// marshals to and from the same wire format without depending on a protobuf
+// runtime, and converts to and from the string form and pbkdf2.Hash.
+package hashpb
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// ErrInvalidMessage is returned by Unmarshal if the input is not a valid
+// encoding of a Hash message.
+var ErrInvalidMessage = errors.New("hashpb: invalid message")
+
+// Hash mirrors the pbkdf2.v1.Hash message in hash.proto.
+type Hash struct {
+	Variant    string
+	Iterations uint32
+	Salt       []byte
+	Key        []byte
+	Metadata   map[string]string
+}
+
+// FromHash returns the message form of h.
+func FromHash(h *pbkdf2.Hash) *Hash {
+	m := &Hash{
+		Variant:    h.Variant(),
+		Iterations: h.Params.Iterations,
+		Salt:       append([]byte(nil), h.Salt...),
+		Key:        append([]byte(nil), h.Key...),
+	}
+	if len(h.Metadata) > 0 {
+		m.Metadata = make(map[string]string, len(h.Metadata))
+		for k, v := range h.Metadata {
+			m.Metadata[k] = v
+		}
+	}
+	return m
+}
+
+// Parse parses a hash in string form and returns its message form.
+func Parse(hash string) (*Hash, error) {
+	h, err := pbkdf2.ParseHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return FromHash(h), nil
+}
+
+// ToHash converts the message to a pbkdf2.Hash. It returns
+// pbkdf2.ErrIncompatibleVariant if the variant is not supported, and
+// pbkdf2.ErrInvalidHash if the message does not describe a valid hash.
+func (m *Hash) ToHash() (*pbkdf2.Hash, error) {
+	h := &pbkdf2.Hash{
+		Params: pbkdf2.Params{
+			Iterations: m.Iterations,
+			SaltLength: uint32(len(m.Salt)),
+			KeyLength:  uint32(len(m.Key)),
+		},
+		Salt:     append([]byte(nil), m.Salt...),
+		Key:      append([]byte(nil), m.Key...),
+		Metadata: m.Metadata,
+	}
+	switch m.Variant {
+	case "":
+		return nil, pbkdf2.ErrIncompatibleVariant
+	case pbkdf2.VariantSHA512:
+	default:
+		h.Params.Variant = m.Variant
+	}
+	if _, err := h.MarshalText(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Encode returns the string form of the message, as accepted by
+// pbkdf2.CheckHash.
+func (m *Hash) Encode() (string, error) {
+	h, err := m.ToHash()
+	if err != nil {
+		return "", err
+	}
+	return h.String(), nil
+}
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// Marshal returns the protobuf wire encoding of the message. Metadata entries
+// are written sorted by key, so the output is deterministic.
+func (m *Hash) Marshal() ([]byte, error) {
+	var b []byte
+	if m.Variant != "" {
+		b = appendBytesField(b, 1, []byte(m.Variant))
+	}
+	if m.Iterations != 0 {
+		b = appendTag(b, 2, wireVarint)
+		b = binary.AppendUvarint(b, uint64(m.Iterations))
+	}
+	if len(m.Salt) > 0 {
+		b = appendBytesField(b, 3, m.Salt)
+	}
+	if len(m.Key) > 0 {
+		b = appendBytesField(b, 4, m.Key)
+	}
+
+	keys := make([]string, 0, len(m.Metadata))
+	for k := range m.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var entry []byte
+		entry = appendBytesField(entry, 1, []byte(k))
+		entry = appendBytesField(entry, 2, []byte(m.Metadata[k]))
+		b = appendBytesField(b, 5, entry)
+	}
+	return b, nil
+}
+
+// Unmarshal parses the protobuf wire encoding of a Hash message into m,
+// replacing its contents. Unknown fields are skipped.
+func (m *Hash) Unmarshal(b []byte) error {
+	*m = Hash{}
+	for len(b) > 0 {
+		num, typ, value, rest, err := consumeField(b)
+		if err != nil {
+			return err
+		}
+		b = rest
+
+		switch {
+		case num == 1 && typ == wireBytes:
+			m.Variant = string(value)
+		case num == 2 && typ == wireVarint:
+			v, _ := binary.Uvarint(value)
+			m.Iterations = uint32(v)
+		case num == 3 && typ == wireBytes:
+			m.Salt = append([]byte(nil), value...)
+		case num == 4 && typ == wireBytes:
+			m.Key = append([]byte(nil), value...)
+		case num == 5 && typ == wireBytes:
+			k, v, err := parseMapEntry(value)
+			if err != nil {
+				return err
+			}
+			if m.Metadata == nil {
+				m.Metadata = make(map[string]string)
+			}
+			m.Metadata[k] = v
+		}
+	}
+	return nil
+}
+
+func parseMapEntry(b []byte) (key, value string, err error) {
+	for len(b) > 0 {
+		num, typ, v, rest, err := consumeField(b)
+		if err != nil {
+			return "", "", err
+		}
+		b = rest
+
+		switch {
+		case num == 1 && typ == wireBytes:
+			key = string(v)
+		case num == 2 && typ == wireBytes:
+			value = string(v)
+		}
+	}
+	return key, value, nil
+}
+
+func appendTag(b []byte, num, typ int) []byte {
+	return binary.AppendUvarint(b, uint64(num)<<3|uint64(typ))
+}
+
+func appendBytesField(b []byte, num int, v []byte) []byte {
+	b = appendTag(b, num, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// consumeField reads a single field from b. For varint fields, value holds the
+// raw varint bytes; for length-delimited fields, the payload.
+func consumeField(b []byte) (num, typ int, value, rest []byte, err error) {
+	tag, n := binary.Uvarint(b)
+	if n <= 0 || tag>>3 == 0 || tag>>3 > 1<<29-1 {
+		return 0, 0, nil, nil, ErrInvalidMessage
+	}
+	num, typ = int(tag>>3), int(tag&7)
+	b = b[n:]
+
+	switch typ {
+	case wireVarint:
+		_, n = binary.Uvarint(b)
+		if n <= 0 {
+			return 0, 0, nil, nil, ErrInvalidMessage
+		}
+		return num, typ, b[:n], b[n:], nil
+	case wireFixed64:
+		if len(b) < 8 {
+			return 0, 0, nil, nil, ErrInvalidMessage
+		}
+		return num, typ, b[:8], b[8:], nil
+	case wireBytes:
+		size, n := binary.Uvarint(b)
+		if n <= 0 || size > uint64(len(b)-n) {
+			return 0, 0, nil, nil, ErrInvalidMessage
+		}
+		b = b[n:]
+		return num, typ, b[:size], b[size:], nil
+	case wireFixed32:
+		if len(b) < 4 {
+			return 0, 0, nil, nil, ErrInvalidMessage
+		}
+		return num, typ, b[:4], b[4:], nil
+	}
+	return 0, 0, nil, nil, ErrInvalidMessage
+}
@@ -0,0 +1,92 @@
+package hashpb
+
+import (
+	"bytes"
+	"testing"
+)
+
+const testHash = "$pbkdf2-sha512$tenant=acme$1000$KuwdBW88vV7YiVGWsMmc8g$XO+ztCemYHheH1kqHe6QAmb99lL3MI7IeBQ05dnAXGk"
+
+func TestRoundTrip(t *testing.T) {
+	m, err := Parse(testHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Variant != "pbkdf2-sha512" || m.Iterations != 1000 || m.Metadata["tenant"] != "acme" {
+		t.Fatalf("unexpected message %#v", *m)
+	}
+
+	b, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Hash
+	if err := decoded.Unmarshal(b); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := decoded.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != testHash {
+		t.Fatalf("expected %q got %q", testHash, hash)
+	}
+}
+
+func TestWireFormat(t *testing.T) {
+	m := &Hash{
+		Variant:    "pbkdf2-sha512",
+		Iterations: 1000,
+		Salt:       []byte{1, 2},
+		Key:        []byte{3},
+		Metadata:   map[string]string{"a": "b"},
+	}
+	want := append([]byte{0x0a, 0x0d}, "pbkdf2-sha512"...)
+	want = append(want,
+		0x10, 0xe8, 0x07,
+		0x1a, 0x02, 0x01, 0x02,
+		0x22, 0x01, 0x03,
+		0x2a, 0x06, 0x0a, 0x01, 'a', 0x12, 0x01, 'b',
+	)
+
+	got, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected %x got %x", want, got)
+	}
+
+	// Unknown fields are skipped.
+	withUnknown := append(append([]byte(nil), want...), 0x30, 0x01, 0x3d, 0, 0, 0, 0)
+	var decoded Hash
+	if err := decoded.Unmarshal(withUnknown); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Iterations != 1000 || !bytes.Equal(decoded.Salt, m.Salt) || decoded.Metadata["a"] != "b" {
+		t.Fatalf("unexpected message %#v", decoded)
+	}
+
+	if err := decoded.Unmarshal(want[:len(want)-1]); err != ErrInvalidMessage {
+		t.Fatalf("expected ErrInvalidMessage, got %v", err)
+	}
+}
+
+func TestToHashInvalid(t *testing.T) {
+	m, err := Parse(testHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.Variant = "pbkdf2-md5"
+	if _, err := m.ToHash(); err == nil {
+		t.Fatal("expected an error for an unsupported variant")
+	}
+
+	m.Variant = "pbkdf2-sha512"
+	m.Salt = nil
+	if _, err := m.Encode(); err == nil {
+		t.Fatal("expected an error for an empty salt")
+	}
+}
@@ -0,0 +1,83 @@
+package pbkdf2
+
+import (
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// ErrInvalidKeySpec is returned by DeriveKeys if no keys are requested, or a
+// KeySpec has an empty or repeated name or an invalid length.
+var ErrInvalidKeySpec = errors.New("pbkdf2: invalid key spec")
+
+// A KeySpec names a key to derive with DeriveKeys, and gives its length in
+// bytes.
+type KeySpec struct {
+	Name   string
+	Length int
+}
+
+// EncryptionAndMAC requests a 32-byte encryption key, such as for AES-256,
+// named "enc", and a 32-byte MAC key, such as for HMAC-SHA256, named "mac".
+var EncryptionAndMAC = []KeySpec{{"enc", 32}, {"mac", 32}}
+
+// DeriveKeys derives a root key from password with PBKDF2 using params, and
+// expands it with HKDF into a key for each spec, using the spec's name as the
+// HKDF info, so that keys for different purposes are independent:
+//
+//	keys, salt, err := pbkdf2.DeriveKeys(password, nil, params, pbkdf2.EncryptionAndMAC...)
+//	// ... store salt, and encrypt with keys["enc"] and authenticate with keys["mac"]
+//
+// The variant's hash function is used for HKDF, and params.KeyLength sets the
+// length of the root key, which should be at least the hash function's output
+// size. If salt is nil, a random salt of params.SaltLength bytes is generated;
+// either way the salt is returned, and must be stored to derive the same keys
+// again. The caller should destroy the keys once they are no longer needed.
+//
+// It returns ErrInvalidKeySpec if specs are invalid, and ErrInvalidParams or
+// ErrIncompatibleVariant if params are not valid.
+func DeriveKeys(password *SecureBytes, salt []byte, params *Params, specs ...KeySpec) (keys map[string]*SecureBytes, usedSalt []byte, err error) {
+	if err := params.Validate(); err != nil {
+		return nil, nil, err
+	}
+	prf, _ := variantPRF(params.Variant)
+	if len(specs) == 0 {
+		return nil, nil, ErrInvalidKeySpec
+	}
+	maxLength := 255 * prf().Size()
+	names := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		if spec.Name == "" || names[spec.Name] || spec.Length <= 0 || spec.Length > maxLength {
+			return nil, nil, ErrInvalidKeySpec
+		}
+		names[spec.Name] = true
+	}
+
+	if salt == nil {
+		salt = make([]byte, params.SaltLength)
+		if err := readEntropy(salt); err != nil {
+			return nil, nil, err
+		}
+	}
+	root, err := DeriveKey(password, salt, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer root.Destroy()
+
+	keys = make(map[string]*SecureBytes, len(specs))
+	for _, spec := range specs {
+		key := make([]byte, spec.Length)
+		if _, err := io.ReadFull(hkdf.Expand(prf, root.Bytes(), []byte(spec.Name)), key); err != nil {
+			// Unreachable, since the length was checked.
+			wipe(key)
+			for _, k := range keys {
+				k.Destroy()
+			}
+			return nil, nil, err
+		}
+		keys[spec.Name] = NewSecureBytes(key)
+	}
+	return keys, salt, nil
+}
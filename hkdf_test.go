@@ -0,0 +1,64 @@
+package pbkdf2
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestDeriveKeys(t *testing.T) {
+	params := &Params{Iterations: 1000, SaltLength: 16, KeyLength: 64}
+	// Generated with Python: root = hashlib.pbkdf2_hmac("sha512", b"password",
+	// b"saltsaltsaltsalt", 1000, 64), expanded with HKDF-Expand(SHA-512, root,
+	// info, 32) for the infos b"enc" and b"mac".
+	want := map[string]string{
+		"enc": "1ac2ceb44e872838d7db0cc4a22d676ced6efbd5f85b341571a7ee46143dd5b9",
+		"mac": "dbc118edf9c58dd223155050996f5ecc85fcd8726d8e2814be32571a7054133c",
+	}
+	keys, salt, err := DeriveKeys(SecureBytesFromString("password"), []byte("saltsaltsaltsalt"), params, EncryptionAndMAC...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(salt) != "saltsaltsaltsalt" || len(keys) != 2 {
+		t.Fatalf("unexpected salt %q or keys %v", salt, keys)
+	}
+	for name, key := range want {
+		if got := hex.EncodeToString(keys[name].Bytes()); got != key {
+			t.Errorf("key %q = %s, want %s", name, got, key)
+		}
+	}
+
+	keys, salt, err = DeriveKeys(SecureBytesFromString("password"), nil, params, KeySpec{"enc", 16}, KeySpec{"iv", 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(salt) != 16 || keys["enc"].Len() != 16 || keys["iv"].Len() != 12 {
+		t.Fatalf("unexpected salt %x or keys %v", salt, keys)
+	}
+	again, _, err := DeriveKeys(SecureBytesFromString("password"), salt, params, KeySpec{"enc", 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again["enc"].Bytes(), keys["enc"].Bytes()) {
+		t.Error("keys differ for the same salt")
+	}
+}
+
+func TestDeriveKeysErrors(t *testing.T) {
+	params := &Params{Iterations: 1000, SaltLength: 16, KeyLength: 64}
+	password := SecureBytesFromString("password")
+	for _, specs := range [][]KeySpec{
+		nil,
+		{{"", 32}},
+		{{"enc", 0}},
+		{{"enc", 255*64 + 1}},
+		{{"enc", 32}, {"enc", 16}},
+	} {
+		if _, _, err := DeriveKeys(password, nil, params, specs...); err != ErrInvalidKeySpec {
+			t.Errorf("specs %v: expected ErrInvalidKeySpec, got %v", specs, err)
+		}
+	}
+	if _, _, err := DeriveKeys(password, nil, &Params{}, EncryptionAndMAC...); err != ErrInvalidParams {
+		t.Errorf("expected ErrInvalidParams, got %v", err)
+	}
+}
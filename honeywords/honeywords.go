@@ -0,0 +1,316 @@
+// Package honeywords stores each password hash among decoy hashes of
+// plausible wrong passwords, after Juels and Rivest's "Honeywords: Making
+// Password-Cracking Detectable", so that a stolen and cracked database gives
+// itself away: an attacker who cannot tell the real password from the decoys
+// will sooner or later log in with a decoy.
+//
+// Generate returns the hashes of the real password and k-1 decoys, all with
+// the same salt, in random order, and the index of the real one. The hashes
+// are stored with the user's record; the index is stored apart from them, by
+// a Checker, ideally on a separate, hardened system, so that a breach of the
+// user database alone does not reveal it:
+//
+//	hashes, index, err := honeywords.Generate(password, 20, params, honeywords.Tweak(3))
+//	err = checker.SetIndex(ctx, username, index)
+//
+// When the user logs in, Verify finds which hash matches, with a single key
+// derivation, and asks the Checker whether it is the real one:
+//
+//	err := honeywords.Verify(ctx, checker, username, password, hashes)
+//	if errors.Is(err, honeywords.ErrHoneyword) {
+//		// The database has been cracked: raise an alarm.
+//	}
+package honeywords
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+var (
+	// ErrHoneyword is returned by Verify and Checker.CheckIndex when a decoy
+	// password was presented, which means the hashes have most likely been
+	// stolen and cracked.
+	ErrHoneyword = errors.New("honeywords: decoy password presented")
+
+	// ErrInvalidSet is returned by Match and Verify if the hashes are empty,
+	// do not decode, or do not share their params and salt, as those created
+	// by Generate do.
+	ErrInvalidSet = errors.New("honeywords: invalid set of hashes")
+
+	// ErrTooFewDecoys is returned by Generate if the Generator could not
+	// produce enough distinct decoys for the password, such as for very short
+	// passwords.
+	ErrTooFewDecoys = errors.New("honeywords: too few distinct decoys")
+
+	// ErrNoIndex is returned by a Checker for users with no index set.
+	ErrNoIndex = errors.New("honeywords: no index for user")
+)
+
+// A Generator produces decoy passwords for a real one. The decoys should be
+// as likely to have been chosen by the user as the real password, so that an
+// attacker holding all of them cannot tell which it is.
+type Generator interface {
+	// Decoys returns n decoys for password. They need not be distinct from
+	// each other or from password; Generate discards repeats and asks for
+	// more.
+	Decoys(password string, n int) ([]string, error)
+}
+
+// GeneratorFunc adapts an ordinary function to a Generator.
+type GeneratorFunc func(password string, n int) ([]string, error)
+
+// Decoys calls f(password, n).
+func (f GeneratorFunc) Decoys(password string, n int) ([]string, error) {
+	return f(password, n)
+}
+
+// Tweak returns a Generator implementing Juels and Rivest's
+// chaffing-by-tweaking: each decoy replaces the last tail characters of the
+// password with random characters of the same kind, digits with digits, lower
+// case letters with lower case letters, and so on. Passwords such as
+// "summer2019" get decoys such as "summer2741", which are plausible for users
+// who add digits to a word. The password is treated as bytes, so multi-byte
+// characters are left as they are, and passwords no longer than tail yield
+// no decoys that differ in their first character.
+func Tweak(tail int) Generator {
+	return GeneratorFunc(func(password string, n int) ([]string, error) {
+		decoys := make([]string, n)
+		for i := range decoys {
+			b := []byte(password)
+			start := len(b) - tail
+			if start < 0 {
+				start = 0
+			}
+			for j := start; j < len(b); j++ {
+				class := charClass(b[j])
+				if class == "" {
+					continue
+				}
+				c, err := randomIndex(len(class))
+				if err != nil {
+					return nil, err
+				}
+				b[j] = class[c]
+			}
+			decoys[i] = string(b)
+		}
+		return decoys, nil
+	})
+}
+
+const (
+	digits  = "0123456789"
+	lower   = "abcdefghijklmnopqrstuvwxyz"
+	upper   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	symbols = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+)
+
+// charClass returns the characters of the same kind as c, or "" for
+// characters that Tweak leaves alone.
+func charClass(c byte) string {
+	switch {
+	case '0' <= c && c <= '9':
+		return digits
+	case 'a' <= c && c <= 'z':
+		return lower
+	case 'A' <= c && c <= 'Z':
+		return upper
+	case '!' <= c && c <= '~':
+		return symbols
+	}
+	return ""
+}
+
+// maxRounds bounds the number of times Generate asks the Generator for more
+// decoys when it returns repeats.
+const maxRounds = 10
+
+// Generate hashes password with params, along with k-1 decoys from gen, all
+// with the same salt, and returns the hashes in random order together with
+// the index of the real password's. k must be at least 2; Juels and Rivest
+// suggest 20. Verifying the hashes costs a single key derivation, like an
+// ordinary hash.
+//
+// It returns ErrTooFewDecoys if gen does not produce k-1 distinct decoys, and
+// any error from params or gen.
+func Generate(password string, k int, params *pbkdf2.Params, gen Generator) (hashes []string, index int, err error) {
+	if k < 2 {
+		return nil, 0, ErrTooFewDecoys
+	}
+
+	words := []string{password}
+	seen := map[string]bool{password: true}
+	for round := 0; round < maxRounds && len(words) < k; round++ {
+		decoys, err := gen.Decoys(password, k-len(words))
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, d := range decoys {
+			if !seen[d] && len(words) < k {
+				seen[d] = true
+				words = append(words, d)
+			}
+		}
+	}
+	if len(words) < k {
+		return nil, 0, ErrTooFewDecoys
+	}
+
+	// Shuffle with Fisher-Yates, tracking where the real password goes.
+	for i := len(words) - 1; i > 0; i-- {
+		j, err := randomIndex(i + 1)
+		if err != nil {
+			return nil, 0, err
+		}
+		words[i], words[j] = words[j], words[i]
+		switch index {
+		case i:
+			index = j
+		case j:
+			index = i
+		}
+	}
+
+	// The salt comes from hashing the first word, so that it is read from
+	// the package's health-tested entropy source.
+	first, err := pbkdf2.CreateHash(words[0], params)
+	if err != nil {
+		return nil, 0, err
+	}
+	h, err := pbkdf2.ParseHash(first)
+	if err != nil {
+		return nil, 0, err
+	}
+	hashes = make([]string, k)
+	hashes[0] = first
+	for i := 1; i < k; i++ {
+		key, err := pbkdf2.DeriveKey(pbkdf2.NewSecureBytes([]byte(words[i])), h.Salt, &h.Params)
+		if err != nil {
+			return nil, 0, err
+		}
+		hashes[i] = (&pbkdf2.Hash{Params: h.Params, Salt: h.Salt, Key: key.Bytes()}).String()
+		key.Destroy()
+	}
+	return hashes, index, nil
+}
+
+// Match returns the index of the hash in hashes that password matches, or
+// pbkdf2.ErrMismatchedHashAndPassword if it matches none. It derives the key
+// once, and compares it with every hash in constant time, so its timing does
+// not reveal the index. It returns ErrInvalidSet if hashes were not created
+// by Generate.
+func Match(password string, hashes []string) (int, error) {
+	if len(hashes) == 0 {
+		return 0, ErrInvalidSet
+	}
+	decoded := make([]*pbkdf2.Hash, len(hashes))
+	for i, hash := range hashes {
+		h, err := pbkdf2.ParseHash(hash)
+		if err != nil || len(h.Metadata) > 0 {
+			return 0, ErrInvalidSet
+		}
+		if i > 0 && (h.Params != decoded[0].Params || subtle.ConstantTimeCompare(h.Salt, decoded[0].Salt) != 1) {
+			return 0, ErrInvalidSet
+		}
+		decoded[i] = h
+	}
+
+	key, err := pbkdf2.DeriveKey(pbkdf2.NewSecureBytes([]byte(password)), decoded[0].Salt, &decoded[0].Params)
+	if err != nil {
+		return 0, err
+	}
+	defer key.Destroy()
+
+	index, found := 0, 0
+	for i, h := range decoded {
+		eq := subtle.ConstantTimeCompare(key.Bytes(), h.Key)
+		index = subtle.ConstantTimeSelect(eq, i, index)
+		found |= eq
+	}
+	if found == 0 {
+		return 0, pbkdf2.ErrMismatchedHashAndPassword
+	}
+	return index, nil
+}
+
+// A Checker keeps the index of each user's real hash, the honeychecker of
+// Juels and Rivest. It should run apart from the system storing the hashes,
+// and never disclose the indexes it holds. Implementations must be safe for
+// concurrent use.
+type Checker interface {
+	// SetIndex records index as the real hash for username.
+	SetIndex(ctx context.Context, username string, index int) error
+
+	// CheckIndex returns nil if index is the real hash for username,
+	// ErrHoneyword if it is not, and ErrNoIndex if username has no index.
+	CheckIndex(ctx context.Context, username string, index int) error
+}
+
+// Verify checks password against the hashes of username, returning nil if it
+// is the real password, pbkdf2.ErrMismatchedHashAndPassword if it matches no
+// hash, and ErrHoneyword if it matches a decoy. Applications should treat
+// ErrHoneyword as a failed login and as evidence of a breach.
+func Verify(ctx context.Context, c Checker, username, password string, hashes []string) error {
+	index, err := Match(password, hashes)
+	if err != nil {
+		return err
+	}
+	return c.CheckIndex(ctx, username, index)
+}
+
+// MemoryChecker is a Checker holding indexes in memory. It is intended for
+// tests, and for a honeychecker service that persists them by other means.
+// The zero value is ready to use.
+type MemoryChecker struct {
+	mu      sync.Mutex
+	indexes map[string]int
+
+	// OnHoneyword, if set, is called with the username whenever a decoy is
+	// presented, such as to raise an alarm.
+	OnHoneyword func(username string)
+}
+
+// SetIndex implements Checker.
+func (c *MemoryChecker) SetIndex(ctx context.Context, username string, index int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.indexes == nil {
+		c.indexes = make(map[string]int)
+	}
+	c.indexes[username] = index
+	return nil
+}
+
+// CheckIndex implements Checker.
+func (c *MemoryChecker) CheckIndex(ctx context.Context, username string, index int) error {
+	c.mu.Lock()
+	real, ok := c.indexes[username]
+	c.mu.Unlock()
+	switch {
+	case !ok:
+		return ErrNoIndex
+	case subtle.ConstantTimeEq(int32(real), int32(index)) == 1:
+		return nil
+	}
+	if c.OnHoneyword != nil {
+		c.OnHoneyword(username)
+	}
+	return ErrHoneyword
+}
+
+// randomIndex returns a uniformly random integer in [0, n), read from
+// pbkdf2.Rand so that the entropy source is health-tested like the salt's.
+func randomIndex(n int) (int, error) {
+	i, err := rand.Int(pbkdf2.Rand, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(i.Int64()), nil
+}
@@ -0,0 +1,155 @@
+package honeywords
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+var testParams = &pbkdf2.Params{Iterations: 1000, SaltLength: 16, KeyLength: 32}
+
+func TestGenerateAndVerify(t *testing.T) {
+	ctx := context.Background()
+	hashes, index, err := Generate("summer2019", 20, testParams, Tweak(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 20 || index < 0 || index >= 20 {
+		t.Fatalf("unexpected result: %d hashes, index %d", len(hashes), index)
+	}
+	salt := strings.Split(hashes[0], "$")[3]
+	for _, h := range hashes {
+		if strings.Split(h, "$")[3] != salt {
+			t.Fatalf("hashes do not share a salt: %s", h)
+		}
+	}
+
+	var alarms []string
+	c := &MemoryChecker{OnHoneyword: func(username string) { alarms = append(alarms, username) }}
+	if err := c.SetIndex(ctx, "alice", index); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(ctx, c, "alice", "summer2019", hashes); err != nil {
+		t.Fatalf("real password: %v", err)
+	}
+	if err := Verify(ctx, c, "alice", "winter2019", hashes); err != pbkdf2.ErrMismatchedHashAndPassword {
+		t.Fatalf("wrong password: expected mismatch, got %v", err)
+	}
+	if len(alarms) != 0 {
+		t.Fatalf("unexpected alarms %v", alarms)
+	}
+
+	// Find a decoy by trying every tweak of the last three digits.
+	decoys := 0
+	for n := 0; n < 1000; n++ {
+		pw := fmt.Sprintf("summer2%03d", n)
+		if pw == "summer2019" {
+			continue
+		}
+		if err := Verify(ctx, c, "alice", pw, hashes); err == nil {
+			t.Fatalf("decoy %q verified", pw)
+		} else if errors.Is(err, ErrHoneyword) {
+			decoys++
+		}
+	}
+	if decoys != 19 || len(alarms) != 19 {
+		t.Fatalf("expected 19 decoys and alarms, got %d and %d", decoys, len(alarms))
+	}
+
+	if err := Verify(ctx, c, "bob", "summer2019", hashes); err != ErrNoIndex {
+		t.Fatalf("expected ErrNoIndex, got %v", err)
+	}
+}
+
+func TestGenerateIndexIsRandom(t *testing.T) {
+	seen := make(map[int]bool)
+	for i := 0; i < 50; i++ {
+		_, index, err := Generate("hunter42", 4, &pbkdf2.Params{Iterations: 1, SaltLength: 16, KeyLength: 16}, Tweak(2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[index] = true
+	}
+	if len(seen) < 2 {
+		t.Fatalf("real hash always at index %v", seen)
+	}
+}
+
+func TestGenerateTooFewDecoys(t *testing.T) {
+	// A single digit has only 9 decoys.
+	if _, _, err := Generate("7", 11, testParams, Tweak(3)); err != ErrTooFewDecoys {
+		t.Fatalf("expected ErrTooFewDecoys, got %v", err)
+	}
+	if _, _, err := Generate("password", 1, testParams, Tweak(3)); err != ErrTooFewDecoys {
+		t.Fatalf("expected ErrTooFewDecoys, got %v", err)
+	}
+
+	failing := errors.New("failing")
+	gen := GeneratorFunc(func(string, int) ([]string, error) { return nil, failing })
+	if _, _, err := Generate("password", 2, testParams, gen); err != failing {
+		t.Fatalf("expected generator error, got %v", err)
+	}
+}
+
+func TestMatchInvalidSet(t *testing.T) {
+	a, err := pbkdf2.CreateHash("a", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := pbkdf2.CreateHash("b", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, hashes := range [][]string{nil, {"garbage"}, {a, b}} {
+		if _, err := Match("a", hashes); err != ErrInvalidSet {
+			t.Errorf("%v: expected ErrInvalidSet, got %v", hashes, err)
+		}
+	}
+	if i, err := Match("a", []string{a}); err != nil || i != 0 {
+		t.Fatalf("expected match at 0, got %d, %v", i, err)
+	}
+}
+
+func TestTweak(t *testing.T) {
+	decoys, err := Tweak(4).Decoys("Pass-w0rD", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range decoys {
+		if len(d) != 9 || d[:5] != "Pass-" {
+			t.Fatalf("decoy %q changed the head", d)
+		}
+		for i, want := range []string{lower, digits, lower, upper} {
+			if !strings.ContainsRune(want, rune(d[5+i])) {
+				t.Fatalf("decoy %q changed the kind of character %d", d, 5+i)
+			}
+		}
+	}
+}
+
+func TestTweakStrictEntropy(t *testing.T) {
+	pbkdf2.SetEntropySource(zeroReader{})
+	pbkdf2.SetStrictEntropy(true)
+	defer func() {
+		pbkdf2.SetEntropySource(nil)
+		pbkdf2.SetStrictEntropy(false)
+	}()
+
+	if _, err := Tweak(4).Decoys("Pass-w0rD", 5); !errors.Is(err, pbkdf2.ErrEntropy) {
+		t.Fatalf("expected ErrEntropy from Tweak, got %v", err)
+	}
+}
+
+// zeroReader is an entropy source that fails the health tests.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
@@ -0,0 +1,234 @@
+// Package iosbackup derives the key that protects the keybag of encrypted
+// iTunes and Finder backups of iOS devices, and unwraps the keybag's class
+// keys with it, for forensic and backup tooling.
+//
+// The keybag, found under BackupKeyBag in a backup's Manifest.plist, stores
+// the salts and iteration counts of a double derivation. Since iOS 10.2 the
+// password is first stretched with PBKDF2-HMAC-SHA256 over DPSL and DPIC, and
+// the result with PBKDF2-HMAC-SHA1 over SALT and ITER; older backups have only
+// the second step, over the password itself:
+//
+//	kb, err := iosbackup.ParseKeybag(backupKeyBag)
+//	key, err := kb.DeriveKey(password)
+//	classKeys, err := kb.Unlock(key)
+//
+// The class keys in turn unwrap the per-file keys recorded in Manifest.db,
+// which this package does not read.
+package iosbackup
+
+import (
+	"crypto/aes"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"runtime"
+	"strconv"
+
+	xpbkdf2 "golang.org/x/crypto/pbkdf2"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// KeySize is the length of the derived key.
+const KeySize = 32
+
+// WrapPasscode is the bit of ClassKey.Wrap set for class keys wrapped with
+// the key derived from the backup password.
+const WrapPasscode = 2
+
+var (
+	// ErrMalformed is returned by ParseKeybag if the keybag is truncated or
+	// lacks a salt or iteration count.
+	ErrMalformed = errors.New("iosbackup: malformed keybag")
+
+	// ErrWrongPassword is returned by Unlock if a class key fails to unwrap,
+	// which means the password is wrong.
+	ErrWrongPassword = errors.New("iosbackup: wrong backup password")
+)
+
+// Params are the derivation parameters stored in a keybag.
+type Params struct {
+	// Salt and Iterations are those of the PBKDF2-HMAC-SHA1 step, the SALT
+	// and ITER tags.
+	Salt       []byte
+	Iterations uint32
+
+	// DoubleProtectionSalt and DoubleProtectionIterations are those of the
+	// PBKDF2-HMAC-SHA256 step, the DPSL and DPIC tags. They are empty for
+	// backups made before iOS 10.2, which skip the step.
+	DoubleProtectionSalt       []byte
+	DoubleProtectionIterations uint32
+}
+
+// DeriveKey derives the key wrapping the class keys from the backup password.
+// It returns pbkdf2.ErrInvalidParams if a salt or iteration count is missing.
+// The caller should destroy the key once it is no longer needed.
+func (p *Params) DeriveKey(password string) (*pbkdf2.SecureBytes, error) {
+	if p.Iterations == 0 || len(p.Salt) == 0 {
+		return nil, pbkdf2.ErrInvalidParams
+	}
+	secret := pbkdf2.SecureBytesFromString(password)
+	defer secret.Destroy()
+
+	if len(p.DoubleProtectionSalt) > 0 || p.DoubleProtectionIterations > 0 {
+		stretched, err := pbkdf2.DeriveKey(secret, p.DoubleProtectionSalt, &pbkdf2.Params{
+			Iterations: p.DoubleProtectionIterations,
+			SaltLength: uint32(len(p.DoubleProtectionSalt)),
+			KeyLength:  KeySize,
+			Variant:    pbkdf2.VariantSHA256,
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer stretched.Destroy()
+		secret = stretched
+	}
+	// SHA-1 is not a variant of this package, so the second step uses
+	// x/crypto directly.
+	key := xpbkdf2.Key(secret.Bytes(), p.Salt, int(p.Iterations), KeySize, sha1.New)
+	runtime.KeepAlive(secret)
+	return pbkdf2.NewSecureBytes(key), nil
+}
+
+// ClassKey is a wrapped protection class key in a keybag.
+type ClassKey struct {
+	UUID       []byte
+	Class      uint32
+	Wrap       uint32
+	KeyType    uint32
+	WrappedKey []byte
+}
+
+// Keybag is a parsed backup keybag.
+type Keybag struct {
+	Params
+
+	Version uint32
+	Type    uint32
+	UUID    []byte
+
+	ClassKeys []ClassKey
+}
+
+// ParseKeybag parses a keybag: a sequence of records, each a four-letter tag,
+// a big-endian 32-bit length and a value. Records up to the second UUID
+// describe the keybag; each UUID after that starts a class key.
+func ParseKeybag(data []byte) (*Keybag, error) {
+	kb := &Keybag{}
+	var class *ClassKey
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, ErrMalformed
+		}
+		tag, n := string(data[:4]), binary.BigEndian.Uint32(data[4:8])
+		if uint64(n) > uint64(len(data)-8) {
+			return nil, ErrMalformed
+		}
+		value := data[8 : 8+n]
+		data = data[8+n:]
+
+		u32 := func() uint32 {
+			if len(value) != 4 {
+				return 0
+			}
+			return binary.BigEndian.Uint32(value)
+		}
+		switch {
+		case tag == "UUID" && kb.UUID == nil:
+			kb.UUID = value
+		case tag == "UUID":
+			kb.ClassKeys = append(kb.ClassKeys, ClassKey{UUID: value})
+			class = &kb.ClassKeys[len(kb.ClassKeys)-1]
+		case class != nil:
+			switch tag {
+			case "CLAS":
+				class.Class = u32()
+			case "WRAP":
+				class.Wrap = u32()
+			case "KTYP":
+				class.KeyType = u32()
+			case "WPKY":
+				class.WrappedKey = value
+			}
+		default:
+			switch tag {
+			case "VERS":
+				kb.Version = u32()
+			case "TYPE":
+				kb.Type = u32()
+			case "SALT":
+				kb.Salt = value
+			case "ITER":
+				kb.Iterations = u32()
+			case "DPSL":
+				kb.DoubleProtectionSalt = value
+			case "DPIC":
+				kb.DoubleProtectionIterations = u32()
+			}
+		}
+	}
+	if len(kb.Salt) == 0 || kb.Iterations == 0 {
+		return nil, ErrMalformed
+	}
+	return kb, nil
+}
+
+// Unlock unwraps the class keys wrapped with key, the key derived from the
+// backup password, returning them by protection class. It returns
+// ErrWrongPassword if any fails to unwrap. The caller should destroy the
+// class keys once they are no longer needed.
+func (kb *Keybag) Unlock(key *pbkdf2.SecureBytes) (map[uint32]*pbkdf2.SecureBytes, error) {
+	keys := make(map[uint32]*pbkdf2.SecureBytes)
+	for _, c := range kb.ClassKeys {
+		if c.Wrap&WrapPasscode == 0 {
+			continue
+		}
+		unwrapped, err := unwrapKey(key.Bytes(), c.WrappedKey)
+		if err != nil {
+			for _, k := range keys {
+				k.Destroy()
+			}
+			return nil, err
+		}
+		keys[c.Class] = pbkdf2.NewSecureBytes(unwrapped)
+	}
+	return keys, nil
+}
+
+// unwrapKey implements the AES key unwrap of RFC 3394 with the default
+// initial value, returning ErrWrongPassword if the integrity check fails.
+func unwrapKey(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, errors.New("iosbackup: wrapped key has invalid length " + strconv.Itoa(len(wrapped)))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	n := len(wrapped)/8 - 1
+	a := make([]byte, 8)
+	copy(a, wrapped[:8])
+	r := make([]byte, 8*n)
+	copy(r, wrapped[8:])
+
+	b := make([]byte, aes.BlockSize)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(a)^t)
+			copy(b[8:], r[8*(i-1):8*i])
+			block.Decrypt(b, b)
+			copy(a, b[:8])
+			copy(r[8*(i-1):8*i], b[8:])
+		}
+	}
+	iv := []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+	if subtle.ConstantTimeCompare(a, iv) != 1 {
+		for i := range r {
+			r[i] = 0
+		}
+		return nil, ErrWrongPassword
+	}
+	return r, nil
+}
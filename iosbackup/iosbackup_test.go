@@ -0,0 +1,124 @@
+package iosbackup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+// The keys were generated with Python's hashlib:
+//
+//	stretched = hashlib.pbkdf2_hmac("sha256", b"backup password", bytes(range(20)), 1000, 32)
+//	key = hashlib.pbkdf2_hmac("sha1", stretched, bytes(range(100, 120)), 500, 32)
+//	legacy = hashlib.pbkdf2_hmac("sha1", b"backup password", bytes(range(100, 120)), 500, 32)
+//
+// and the class key bytes(range(200, 232)) was wrapped with key by OpenSSL:
+//
+//	openssl enc -id-aes256-wrap -K <key> -iv A6A6A6A6A6A6A6A6
+const (
+	testPassword   = "backup password"
+	testKey        = "c905386f4fb1a283416d7abfa192f1fc51bde8d254904b0f371cc0bb2d47575c"
+	testLegacyKey  = "3b3e1afc11b01e12a25411841a932d9c9861c44a583256ecc3f9b340b35c0857"
+	testWrappedKey = "248f3eca7cb9b9dc5d0b673dc77dc10ad7a6e1e52614dd61f1ddd60c18f8fdbd3cc0afb0f33fcb17"
+)
+
+func seq(from, to int) []byte {
+	b := make([]byte, 0, to-from)
+	for i := from; i < to; i++ {
+		b = append(b, byte(i))
+	}
+	return b
+}
+
+func record(tag string, value []byte) []byte {
+	b := make([]byte, 8, 8+len(value))
+	copy(b, tag)
+	binary.BigEndian.PutUint32(b[4:], uint32(len(value)))
+	return append(b, value...)
+}
+
+func u32(n uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, n)
+}
+
+func testKeybag(t *testing.T) []byte {
+	t.Helper()
+	wrapped, _ := hex.DecodeString(testWrappedKey)
+	return bytes.Join([][]byte{
+		record("VERS", u32(4)),
+		record("TYPE", u32(1)),
+		record("UUID", seq(0, 16)),
+		record("HMCK", seq(0, 40)),
+		record("WRAP", u32(0)),
+		record("SALT", seq(100, 120)),
+		record("ITER", u32(500)),
+		record("DPWT", u32(1)),
+		record("DPIC", u32(1000)),
+		record("DPSL", seq(0, 20)),
+		record("UUID", seq(16, 32)),
+		record("CLAS", u32(3)),
+		record("WRAP", u32(3)),
+		record("KTYP", u32(0)),
+		record("WPKY", wrapped),
+		record("UUID", seq(32, 48)),
+		record("CLAS", u32(4)),
+		record("WRAP", u32(1)),
+		record("WPKY", seq(0, 40)),
+	}, nil)
+}
+
+func TestUnlock(t *testing.T) {
+	kb, err := ParseKeybag(testKeybag(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kb.Version != 4 || kb.Iterations != 500 || kb.DoubleProtectionIterations != 1000 || len(kb.ClassKeys) != 2 {
+		t.Fatalf("unexpected keybag %+v", kb)
+	}
+
+	key, err := kb.DeriveKey(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer key.Destroy()
+	if got := hex.EncodeToString(key.Bytes()); got != testKey {
+		t.Fatalf("key = %s, want %s", got, testKey)
+	}
+
+	keys, err := kb.Unlock(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || !bytes.Equal(keys[3].Bytes(), seq(200, 232)) {
+		t.Fatalf("unexpected class keys %v", keys)
+	}
+
+	wrong, err := kb.DeriveKey("wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kb.Unlock(wrong); err != ErrWrongPassword {
+		t.Fatalf("expected ErrWrongPassword, got %v", err)
+	}
+}
+
+func TestDeriveKeyLegacy(t *testing.T) {
+	p := &Params{Salt: seq(100, 120), Iterations: 500}
+	key, err := p.DeriveKey(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(key.Bytes()); got != testLegacyKey {
+		t.Fatalf("key = %s, want %s", got, testLegacyKey)
+	}
+}
+
+func TestParseKeybagMalformed(t *testing.T) {
+	kb := testKeybag(t)
+	for _, data := range [][]byte{kb[:len(kb)-1], kb[:5], record("VERS", u32(4))} {
+		if _, err := ParseKeybag(data); err != ErrMalformed {
+			t.Errorf("expected ErrMalformed, got %v", err)
+		}
+	}
+}
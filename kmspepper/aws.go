@@ -0,0 +1,262 @@
+package kmspepper
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pganguli/pbkdf2/pepper"
+)
+
+// AWSCredentials are the credentials of an AWS principal.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// SessionToken is set for temporary credentials, such as those of an
+	// IAM role.
+	SessionToken string
+}
+
+// An AWSCredentialsSource returns the credentials to sign AWS requests with.
+// It is called before every request, and should cache credentials itself.
+type AWSCredentialsSource func(ctx context.Context) (AWSCredentials, error)
+
+// StaticCredentials returns an AWSCredentialsSource that always returns
+// creds.
+func StaticCredentials(creds AWSCredentials) AWSCredentialsSource {
+	return func(context.Context) (AWSCredentials, error) { return creds, nil }
+}
+
+// EnvCredentials is an AWSCredentialsSource reading the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables, as set
+// for Lambda functions and ECS tasks.
+func EnvCredentials(context.Context) (AWSCredentials, error) {
+	creds := AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return AWSCredentials{}, errors.New("kmspepper: AWS credentials are not set in the environment")
+	}
+	return creds, nil
+}
+
+// AWS Secrets Manager staging labels.
+const (
+	StageCurrent  = "AWSCURRENT"
+	StagePrevious = "AWSPREVIOUS"
+)
+
+// AWSSecretsManager is a pepper.SecretProvider reading the pepper from a
+// secret in AWS Secrets Manager, as SecretBinary or as base64 in
+// SecretString. The version labelled AWSCURRENT is the active key, and the one
+// labelled AWSPREVIOUS, if any, is kept to verify hashes not yet re-peppered.
+// Keys are identified by their version IDs.
+//
+// Rotating the secret, by hand or with a rotation Lambda, moves AWSCURRENT to
+// the new version and AWSPREVIOUS to the old one, so hashes peppered with a
+// key older than that must be re-peppered before the next rotation.
+type AWSSecretsManager struct {
+	Region      string
+	SecretID    string
+	Credentials AWSCredentialsSource
+
+	// Client is used for requests; if nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Endpoint, if set, replaces the regional endpoint, such as for a VPC
+	// endpoint.
+	Endpoint string
+}
+
+// Keys implements pepper.SecretProvider.
+func (s *AWSSecretsManager) Keys(ctx context.Context) (string, []pepper.Key, error) {
+	current, err := s.getSecretValue(ctx, StageCurrent)
+	if err != nil {
+		return "", nil, err
+	}
+	keys := []pepper.Key{current}
+	previous, err := s.getSecretValue(ctx, StagePrevious)
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.Code == "ResourceNotFoundException":
+		// The secret has not been rotated yet.
+	case err != nil:
+		return "", nil, err
+	case previous.ID != current.ID:
+		keys = append(keys, previous)
+	}
+	return current.ID, keys, nil
+}
+
+func (s *AWSSecretsManager) getSecretValue(ctx context.Context, stage string) (pepper.Key, error) {
+	var out struct {
+		VersionId    string
+		SecretBinary []byte
+		SecretString *string
+	}
+	in := map[string]string{"SecretId": s.SecretID, "VersionStage": stage}
+	if err := awsJSON(ctx, s.Client, s.Credentials, s.Endpoint, s.Region, "secretsmanager", "secretsmanager.GetSecretValue", in, &out); err != nil {
+		return pepper.Key{}, err
+	}
+	secret := out.SecretBinary
+	if out.SecretString != nil {
+		var err error
+		if secret, err = decodeSecret(*out.SecretString); err != nil {
+			return pepper.Key{}, err
+		}
+	}
+	return pepper.Key{ID: out.VersionId, Secret: secret}, nil
+}
+
+// AWSKMS is a pepper.SecretProvider decrypting pepper keys with AWS KMS, such
+// as those from GenerateDataKey with a KeySpec of AES_256.
+type AWSKMS struct {
+	Region      string
+	Credentials AWSCredentialsSource
+
+	// EncryptedKeys are the encrypted pepper keys; ActiveID names the one
+	// for new hashes.
+	EncryptedKeys []EncryptedKey
+	ActiveID      string
+
+	// KeyID, if set, is passed to Decrypt to require that the keys are
+	// encrypted under that KMS key.
+	KeyID string
+
+	// Client is used for requests; if nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Endpoint, if set, replaces the regional endpoint.
+	Endpoint string
+
+	envelope envelope
+}
+
+// Keys implements pepper.SecretProvider.
+func (k *AWSKMS) Keys(ctx context.Context) (string, []pepper.Key, error) {
+	return k.envelope.keys(ctx, k.ActiveID, k.EncryptedKeys, k.decrypt)
+}
+
+func (k *AWSKMS) decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	in := struct {
+		CiphertextBlob []byte
+		KeyId          string `json:",omitempty"`
+	}{ciphertext, k.KeyID}
+	var out struct{ Plaintext []byte }
+	if err := awsJSON(ctx, k.Client, k.Credentials, k.Endpoint, k.Region, "kms", "TrentService.Decrypt", in, &out); err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
+
+// awsJSON calls an action of an AWS JSON 1.1 API.
+func awsJSON(ctx context.Context, client *http.Client, creds AWSCredentialsSource, endpoint, region, service, target string, in, out interface{}) error {
+	if creds == nil {
+		return errors.New("kmspepper: no AWS credentials")
+	}
+	c, err := creds(ctx)
+	if err != nil {
+		return err
+	}
+	if endpoint == "" {
+		endpoint = "https://" + service + "." + region + ".amazonaws.com/"
+	}
+	req, body, err := newJSONRequest(ctx, http.MethodPost, endpoint, in)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+	signV4(req, body, c, region, service, time.Now())
+	return doJSON(client, req, out, func(status int, body []byte) error {
+		var e struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(body, &e)
+		// The type may be qualified, as in
+		// "com.amazonaws.kms#NotFoundException".
+		if i := strings.LastIndexByte(e.Type, '#'); i >= 0 {
+			e.Type = e.Type[i+1:]
+		}
+		return &APIError{Service: service, StatusCode: status, Code: e.Type, Message: e.Message}
+	})
+}
+
+// signV4 signs req with AWS Signature Version 4, over its Host and other
+// headers.
+func signV4(req *http.Request, body []byte, creds AWSCredentials, region, service string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	if req.Host != "" {
+		headers["host"] = req.Host
+	}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	bodyHash := sha256.Sum256(body)
+	canonicalRequest := req.Method + "\n" +
+		path + "\n" +
+		canonicalQuery(req.URL.Query()) + "\n" +
+		canonicalHeaders.String() + "\n" +
+		signedHeaders + "\n" +
+		hex.EncodeToString(bodyHash[:])
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, s := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query sorted by name, with spaces as %20.
+func canonicalQuery(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,138 @@
+package kmspepper
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testCreds = StaticCredentials(AWSCredentials{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+})
+
+// TestSignV4 checks the example request of the AWS Signature Version 4
+// documentation.
+func TestSignV4(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	creds, _ := testCreds(context.Background())
+	signV4(req, nil, creds, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %q, want %q", got, want)
+	}
+}
+
+// awsServer serves an AWS JSON API, passing each request's target and body
+// to handle.
+func awsServer(t *testing.T, handle func(target string, in map[string]interface{}) (int, interface{})) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+			t.Errorf("request not signed: %q", r.Header.Get("Authorization"))
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/x-amz-json-1.1" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		body, _ := io.ReadAll(r.Body)
+		var in map[string]interface{}
+		if err := json.Unmarshal(body, &in); err != nil {
+			t.Error(err)
+		}
+		status, out := handle(r.Header.Get("X-Amz-Target"), in)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(out)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAWSSecretsManager(t *testing.T) {
+	current := bytes.Repeat([]byte{1}, 32)
+	previous := bytes.Repeat([]byte{2}, 32)
+	rotated := true
+	srv := awsServer(t, func(target string, in map[string]interface{}) (int, interface{}) {
+		if target != "secretsmanager.GetSecretValue" || in["SecretId"] != "pepper" {
+			t.Errorf("unexpected request %s %v", target, in)
+		}
+		switch {
+		case in["VersionStage"] == StageCurrent:
+			return 200, map[string]interface{}{"VersionId": "v2", "SecretString": base64.StdEncoding.EncodeToString(current)}
+		case rotated:
+			return 200, map[string]interface{}{"VersionId": "v1", "SecretBinary": previous}
+		default:
+			return 400, map[string]string{"__type": "ResourceNotFoundException", "message": "no such version"}
+		}
+	})
+	sm := &AWSSecretsManager{Region: "us-east-1", SecretID: "pepper", Credentials: testCreds, Endpoint: srv.URL}
+
+	active, keys, err := sm.Keys(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active != "v2" || len(keys) != 2 || !bytes.Equal(keys[0].Secret, current) || keys[1].ID != "v1" || !bytes.Equal(keys[1].Secret, previous) {
+		t.Errorf("Keys = %q, %v", active, keys)
+	}
+
+	rotated = false
+	if active, keys, err = sm.Keys(context.Background()); err != nil || active != "v2" || len(keys) != 1 {
+		t.Errorf("Keys without AWSPREVIOUS = %q, %v, %v", active, keys, err)
+	}
+}
+
+func TestAWSKMS(t *testing.T) {
+	calls := 0
+	srv := awsServer(t, func(target string, in map[string]interface{}) (int, interface{}) {
+		calls++
+		if target != "TrentService.Decrypt" {
+			t.Errorf("target = %q", target)
+		}
+		// The fake KMS "decrypts" by repeating the ciphertext's first byte.
+		blob, _ := base64.StdEncoding.DecodeString(in["CiphertextBlob"].(string))
+		if blob[0] == 0 {
+			return 400, map[string]string{"__type": "com.amazonaws.kms#InvalidCiphertextException"}
+		}
+		return 200, map[string][]byte{"Plaintext": bytes.Repeat(blob[:1], 32)}
+	})
+	k := &AWSKMS{
+		Region:        "us-east-1",
+		Credentials:   testCreds,
+		EncryptedKeys: []EncryptedKey{{ID: "k1", Ciphertext: []byte{1}}, {ID: "k2", Ciphertext: []byte{2}}},
+		ActiveID:      "k2",
+		Endpoint:      srv.URL,
+	}
+	for i := 0; i < 2; i++ {
+		active, keys, err := k.Keys(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if active != "k2" || len(keys) != 2 || keys[1].Secret[0] != 2 {
+			t.Errorf("Keys = %q, %v", active, keys)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Decrypt called %d times, want 2: keys are not cached", calls)
+	}
+
+	k.EncryptedKeys = append(k.EncryptedKeys, EncryptedKey{ID: "bad", Ciphertext: []byte{0}})
+	_, _, err := k.Keys(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "InvalidCiphertextException" || apiErr.StatusCode != 400 {
+		t.Errorf("Keys with a bad key: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package kmspepper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/pganguli/pbkdf2/pepper"
+)
+
+// AzureAPIVersion is the Key Vault REST API version used.
+const AzureAPIVersion = "7.4"
+
+// AzureKeyVault is a pepper.SecretProvider reading the pepper from a secret
+// in Azure Key Vault, stored as base64. The current version of the secret is
+// the active key, and every other enabled version is kept to verify hashes
+// not yet re-peppered; disabling a version retires its key. Keys are
+// identified by their version IDs.
+type AzureKeyVault struct {
+	// VaultURL is the vault's URL, as in "https://myvault.vault.azure.net".
+	VaultURL   string
+	SecretName string
+
+	// Token supplies access tokens for https://vault.azure.net.
+	Token TokenSource
+
+	// Client is used for requests; if nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+type azureSecret struct {
+	ID         string `json:"id"`
+	Value      string `json:"value"`
+	Attributes struct {
+		Enabled bool `json:"enabled"`
+	} `json:"attributes"`
+}
+
+// Keys implements pepper.SecretProvider.
+func (a *AzureKeyVault) Keys(ctx context.Context) (string, []pepper.Key, error) {
+	base := strings.TrimSuffix(a.VaultURL, "/") + "/secrets/" + a.SecretName
+	var current azureSecret
+	if err := a.get(ctx, base, &current); err != nil {
+		return "", nil, err
+	}
+	activeID := azureVersion(current.ID)
+	secret, err := decodeSecret(current.Value)
+	if err != nil {
+		return "", nil, err
+	}
+	keys := []pepper.Key{{ID: activeID, Secret: secret}}
+
+	next := base + "/versions"
+	for next != "" {
+		var page struct {
+			Value    []azureSecret `json:"value"`
+			NextLink string        `json:"nextLink"`
+		}
+		if err := a.get(ctx, next, &page); err != nil {
+			return "", nil, err
+		}
+		for _, v := range page.Value {
+			if !v.Attributes.Enabled || azureVersion(v.ID) == activeID {
+				continue
+			}
+			var version azureSecret
+			if err := a.get(ctx, v.ID, &version); err != nil {
+				return "", nil, err
+			}
+			secret, err := decodeSecret(version.Value)
+			if err != nil {
+				return "", nil, err
+			}
+			keys = append(keys, pepper.Key{ID: azureVersion(v.ID), Secret: secret})
+		}
+		next = page.NextLink
+	}
+	return activeID, keys, nil
+}
+
+// get fetches url, which must be within the vault, so that the token is not
+// sent elsewhere by a forged next link or ID.
+func (a *AzureKeyVault) get(ctx context.Context, url string, out interface{}) error {
+	if !strings.HasPrefix(url, strings.TrimSuffix(a.VaultURL, "/")+"/") {
+		return errors.New("kmspepper: Key Vault returned a URL outside the vault: " + url)
+	}
+	if !strings.Contains(url, "api-version=") {
+		sep := "?"
+		if strings.Contains(url, "?") {
+			sep = "&"
+		}
+		url += sep + "api-version=" + AzureAPIVersion
+	}
+	req, _, err := newJSONRequest(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if err := bearer(ctx, req, a.Token); err != nil {
+		return err
+	}
+	return doJSON(a.Client, req, out, func(status int, body []byte) error {
+		var e struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(body, &e)
+		return &APIError{Service: "keyvault", StatusCode: status, Code: e.Error.Code, Message: e.Error.Message}
+	})
+}
+
+// azureVersion returns the version of a secret from its ID, the last element
+// of its URL.
+func azureVersion(id string) string {
+	return id[strings.LastIndexByte(id, '/')+1:]
+}
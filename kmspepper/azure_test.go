@@ -0,0 +1,81 @@
+package kmspepper
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAzureKeyVault(t *testing.T) {
+	secrets := map[string][]byte{
+		"v1": bytes.Repeat([]byte{1}, 32),
+		"v2": bytes.Repeat([]byte{2}, 32),
+		"v3": bytes.Repeat([]byte{3}, 32),
+	}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("api-version"); got != AzureAPIVersion {
+			t.Errorf("api-version = %q", got)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		base := srv.URL + "/secrets/pepper"
+		version := func(v string, enabled bool) map[string]interface{} {
+			return map[string]interface{}{"id": base + "/" + v, "attributes": map[string]bool{"enabled": enabled}}
+		}
+		var out interface{}
+		switch r.URL.Path {
+		case "/secrets/pepper":
+			out = map[string]string{"id": base + "/v2", "value": base64.StdEncoding.EncodeToString(secrets["v2"])}
+		case "/secrets/pepper/versions":
+			if r.URL.Query().Get("page") == "" {
+				out = map[string]interface{}{
+					"value":    []interface{}{version("v1", true), version("v2", true)},
+					"nextLink": base + "/versions?api-version=" + AzureAPIVersion + "&page=2",
+				}
+			} else {
+				out = map[string]interface{}{"value": []interface{}{version("v3", false)}}
+			}
+		case "/secrets/pepper/v1", "/secrets/pepper/v2":
+			v := r.URL.Path[len("/secrets/pepper/"):]
+			out = map[string]string{"id": base + "/" + v, "value": base64.StdEncoding.EncodeToString(secrets[v])}
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"code": "SecretNotFound", "message": "not found"}}`))
+			return
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+	defer srv.Close()
+
+	a := &AzureKeyVault{VaultURL: srv.URL, SecretName: "pepper", Token: StaticToken("token")}
+	active, keys, err := a.Keys(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active != "v2" || len(keys) != 2 || keys[1].ID != "v1" || !bytes.Equal(keys[1].Secret, secrets["v1"]) {
+		t.Errorf("Keys = %q, %v", active, keys)
+	}
+}
+
+func TestAzureKeyVaultForeignURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/secrets/pepper" {
+			w.Write([]byte(`{"id": "x/v1", "value": "` + base64.StdEncoding.EncodeToString(make([]byte, 32)) + `"}`))
+			return
+		}
+		w.Write([]byte(`{"value": [], "nextLink": "https://attacker.example/steal"}`))
+	}))
+	defer srv.Close()
+
+	a := &AzureKeyVault{VaultURL: srv.URL, SecretName: "pepper", Token: StaticToken("token")}
+	if _, _, err := a.Keys(context.Background()); err == nil {
+		t.Error("Keys followed a next link outside the vault")
+	}
+}
@@ -0,0 +1,77 @@
+package kmspepper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pganguli/pbkdf2/pepper"
+)
+
+// GCPKMS is a pepper.SecretProvider decrypting pepper keys with a Google
+// Cloud KMS symmetric key.
+type GCPKMS struct {
+	// KeyName is the resource name of the KMS key, as in
+	// "projects/p/locations/global/keyRings/r/cryptoKeys/k". Decryption
+	// picks the key version from the ciphertext, so keys encrypted under
+	// versions since rotated out remain readable while those are enabled.
+	KeyName string
+
+	// Token supplies access tokens with the cloudkms scope.
+	Token TokenSource
+
+	// EncryptedKeys are the encrypted pepper keys; ActiveID names the one
+	// for new hashes.
+	EncryptedKeys []EncryptedKey
+	ActiveID      string
+
+	// Client is used for requests; if nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Endpoint, if set, replaces https://cloudkms.googleapis.com.
+	Endpoint string
+
+	envelope envelope
+}
+
+// Keys implements pepper.SecretProvider.
+func (g *GCPKMS) Keys(ctx context.Context) (string, []pepper.Key, error) {
+	return g.envelope.keys(ctx, g.ActiveID, g.EncryptedKeys, g.decrypt)
+}
+
+func (g *GCPKMS) decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	endpoint := g.Endpoint
+	if endpoint == "" {
+		endpoint = "https://cloudkms.googleapis.com"
+	}
+	url := strings.TrimSuffix(endpoint, "/") + "/v1/" + g.KeyName + ":decrypt"
+	req, _, err := newJSONRequest(ctx, http.MethodPost, url, struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}{ciphertext})
+	if err != nil {
+		return nil, err
+	}
+	if err := bearer(ctx, req, g.Token); err != nil {
+		return nil, err
+	}
+	var out struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	if err := doJSON(g.Client, req, &out, googleError); err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
+
+// googleError decodes a Google API error response.
+func googleError(status int, body []byte) error {
+	var e struct {
+		Error struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	json.Unmarshal(body, &e)
+	return &APIError{Service: "cloudkms", StatusCode: status, Code: e.Error.Status, Message: e.Error.Message}
+}
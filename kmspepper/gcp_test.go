@@ -0,0 +1,51 @@
+package kmspepper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGCPKMS(t *testing.T) {
+	const keyName = "projects/p/locations/global/keyRings/r/cryptoKeys/k"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/"+keyName+":decrypt" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		var in struct{ Ciphertext []byte }
+		json.NewDecoder(r.Body).Decode(&in)
+		if in.Ciphertext[0] == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"code": 400, "message": "Decryption failed", "status": "INVALID_ARGUMENT"}}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string][]byte{"plaintext": bytes.Repeat(in.Ciphertext[:1], 32)})
+	}))
+	defer srv.Close()
+
+	g := &GCPKMS{
+		KeyName:       keyName,
+		Token:         StaticToken("token"),
+		EncryptedKeys: []EncryptedKey{{ID: "k1", Ciphertext: []byte{1}}},
+		ActiveID:      "k1",
+		Endpoint:      srv.URL,
+	}
+	active, keys, err := g.Keys(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active != "k1" || len(keys) != 1 || !bytes.Equal(keys[0].Secret, bytes.Repeat([]byte{1}, 32)) {
+		t.Errorf("Keys = %q, %v", active, keys)
+	}
+
+	g.EncryptedKeys = []EncryptedKey{{ID: "bad", Ciphertext: []byte{0}}}
+	if _, _, err := g.Keys(context.Background()); err == nil {
+		t.Error("Keys succeeded with a key that fails to decrypt")
+	}
+}
@@ -0,0 +1,181 @@
+// Package kmspepper provides pepper.SecretProvider implementations that fetch
+// pepper keys from AWS Secrets Manager, AWS KMS, Google Cloud KMS and Azure
+// Key Vault, using their REST APIs directly so that no cloud SDK is needed.
+//
+// Providers are used with a pepper.RefreshingKeyring, which caches the keys
+// and refetches them periodically, so that rotation in the cloud reaches
+// running services:
+//
+//	provider := &kmspepper.AWSSecretsManager{
+//		Region:      "eu-west-1",
+//		SecretID:    "prod/password-pepper",
+//		Credentials: kmspepper.EnvCredentials,
+//	}
+//	keys, err := pepper.NewRefreshingKeyring(ctx, provider)
+//	go keys.Run(ctx, 10*time.Minute)
+//	h := &pepper.Hasher{Source: keys}
+//
+// Secret stores hold the pepper directly, and rotate it by adding versions:
+// the current version is the active key, and earlier versions remain to
+// verify existing hashes. KMS providers instead decrypt pepper keys that were
+// encrypted under a KMS key, data keys in KMS terms, listed in their
+// configuration; rotating means adding a key and changing the active ID.
+// Decrypted keys are cached, so that refreshing does not call the KMS again.
+package kmspepper
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/pganguli/pbkdf2/pepper"
+)
+
+// A TokenSource returns an OAuth 2.0 access token for Google Cloud or Azure,
+// such as one from a metadata server or managed identity. It is called before
+// every request, and should cache tokens itself.
+type TokenSource func(ctx context.Context) (string, error)
+
+// StaticToken returns a TokenSource that always returns token, for tests and
+// short-lived tools.
+func StaticToken(token string) TokenSource {
+	return func(context.Context) (string, error) { return token, nil }
+}
+
+// EncryptedKey is a pepper key encrypted under a KMS key, for the KMS
+// providers.
+type EncryptedKey struct {
+	// ID identifies the key in hashes, as pepper.Key.ID.
+	ID string
+
+	// Ciphertext is the key encrypted by the KMS, such as the
+	// CiphertextBlob returned by AWS KMS GenerateDataKey.
+	Ciphertext []byte
+}
+
+// APIError is an error response from a cloud API.
+type APIError struct {
+	Service    string
+	StatusCode int
+
+	// Code is the service's error code, such as
+	// "ResourceNotFoundException", if it returned one.
+	Code    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	msg := "kmspepper: " + e.Service + ": HTTP " + fmt.Sprint(e.StatusCode)
+	if e.Code != "" {
+		msg += ": " + e.Code
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// envelope decrypts EncryptedKeys with a KMS, caching the results.
+type envelope struct {
+	mu    sync.Mutex
+	cache map[string][]byte // plaintext by ID and ciphertext
+}
+
+// keys decrypts each of encrypted with decrypt, or returns it from the cache.
+func (e *envelope) keys(ctx context.Context, activeID string, encrypted []EncryptedKey, decrypt func(context.Context, []byte) ([]byte, error)) (string, []pepper.Key, error) {
+	keys := make([]pepper.Key, 0, len(encrypted))
+	for _, k := range encrypted {
+		cacheKey := k.ID + "\x00" + string(k.Ciphertext)
+		e.mu.Lock()
+		secret, ok := e.cache[cacheKey]
+		e.mu.Unlock()
+		if !ok {
+			var err error
+			if secret, err = decrypt(ctx, k.Ciphertext); err != nil {
+				return "", nil, fmt.Errorf("kmspepper: decrypting key %q: %w", k.ID, err)
+			}
+			e.mu.Lock()
+			if e.cache == nil {
+				e.cache = make(map[string][]byte)
+			}
+			e.cache[cacheKey] = secret
+			e.mu.Unlock()
+		}
+		keys = append(keys, pepper.Key{ID: k.ID, Secret: secret})
+	}
+	return activeID, keys, nil
+}
+
+// decodeSecret decodes a pepper key stored as text in a secret store:
+// standard or URL-safe base64, padded or not, of pepper.KeySize bytes.
+func decodeSecret(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(s); err == nil && len(b) == pepper.KeySize {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("kmspepper: secret is not the base64 encoding of a %d-byte key", pepper.KeySize)
+}
+
+// doJSON sends req, with body encoded as JSON if it is not nil, and decodes
+// a successful response into out. Errors are decoded with decodeErr.
+func doJSON(client *http.Client, req *http.Request, out interface{}, decodeErr func(status int, body []byte) error) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return decodeErr(resp.StatusCode, body)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("kmspepper: decoding response: %w", err)
+	}
+	return nil
+}
+
+// newJSONRequest returns a request with body encoded as JSON.
+func newJSONRequest(ctx context.Context, method, url string, body interface{}) (*http.Request, []byte, error) {
+	var b []byte
+	if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			return nil, nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(b))
+	if err != nil {
+		return nil, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, b, nil
+}
+
+// bearer sets the Authorization header of req from tokens.
+func bearer(ctx context.Context, req *http.Request, tokens TokenSource) error {
+	if tokens == nil {
+		return fmt.Errorf("kmspepper: no token source")
+	}
+	token, err := tokens(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
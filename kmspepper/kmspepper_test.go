@@ -0,0 +1,22 @@
+package kmspepper
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeSecret(t *testing.T) {
+	key := bytes.Repeat([]byte{0xfb}, 32)
+	for _, s := range []string{
+		"+/v7+/v7+/v7+/v7+/v7+/v7+/v7+/v7+/v7+/v7+/s=",
+		"+/v7+/v7+/v7+/v7+/v7+/v7+/v7+/v7+/v7+/v7+/s\n",
+		"-_v7-_v7-_v7-_v7-_v7-_v7-_v7-_v7-_v7-_v7-_s=",
+	} {
+		if got, err := decodeSecret(s); err != nil || !bytes.Equal(got, key) {
+			t.Errorf("decodeSecret(%q) = %x, %v", s, got, err)
+		}
+	}
+	if _, err := decodeSecret("c2hvcnQ="); err == nil {
+		t.Error("decodeSecret accepted a short key")
+	}
+}
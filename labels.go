@@ -0,0 +1,33 @@
+package pbkdf2
+
+import "context"
+
+type labelsKey struct{}
+
+// WithLabels returns a copy of ctx carrying labels, such as a trace ID, tenant
+// or user ID, merged with any labels ctx already carries, which they
+// override. Hasher methods taking a context pass its labels to h.OnUsage and
+// h.OnEvent, so that hashing can be correlated with the rest of the request
+// without global state:
+//
+//	ctx = pbkdf2.WithLabels(ctx, map[string]string{"trace_id": traceID, "user": username})
+//	err := hasher.VerifyContext(ctx, password, hash)
+//
+// Labels must not include secrets, as hooks typically log them.
+func WithLabels(ctx context.Context, labels map[string]string) context.Context {
+	merged := make(map[string]string, len(labels))
+	for k, v := range LabelsFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, labelsKey{}, merged)
+}
+
+// LabelsFromContext returns the labels carried by ctx, or nil if there are
+// none. The map must not be modified.
+func LabelsFromContext(ctx context.Context) map[string]string {
+	labels, _ := ctx.Value(labelsKey{}).(map[string]string)
+	return labels
+}
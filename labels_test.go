@@ -0,0 +1,83 @@
+package pbkdf2
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestWithLabels(t *testing.T) {
+	ctx := context.Background()
+	if labels := LabelsFromContext(ctx); labels != nil {
+		t.Errorf("unexpected labels %v", labels)
+	}
+	parent := WithLabels(ctx, map[string]string{"trace_id": "t1", "tenant": "acme"})
+	child := WithLabels(parent, map[string]string{"trace_id": "t2", "user": "alice"})
+	if got := LabelsFromContext(parent); len(got) != 2 || got["trace_id"] != "t1" {
+		t.Errorf("parent labels modified: %v", got)
+	}
+	got := LabelsFromContext(child)
+	if len(got) != 3 || got["trace_id"] != "t2" || got["tenant"] != "acme" || got["user"] != "alice" {
+		t.Errorf("unexpected child labels %v", got)
+	}
+}
+
+func TestHasherHooksContext(t *testing.T) {
+	var mu sync.Mutex
+	var events []Event
+	var usage []Usage
+	h := &Hasher{
+		Params: &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32},
+		OnEvent: func(e Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, e)
+		},
+		OnUsage: func(u Usage) {
+			mu.Lock()
+			defer mu.Unlock()
+			usage = append(usage, u)
+		},
+	}
+	ctx := WithLabels(context.Background(), map[string]string{"trace_id": "abc"})
+
+	hash, err := h.CreateHashContext(ctx, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.VerifyContext(ctx, "wrong", hash); err != ErrMismatchedHashAndPassword {
+		t.Fatalf("expected ErrMismatchedHashAndPassword, got %v", err)
+	}
+	if _, err := h.ConvertHashContext(ctx, "password", hash); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.VerifyContext(ctx, "password", "$pbkdf2-sha512$1$AA"); err == nil {
+		t.Fatal("expected malformed hash to be rejected")
+	}
+	if err := h.Verify("password", hash); err != nil {
+		t.Fatal(err)
+	}
+
+	wantOps := []string{UsageHash, UsageVerify, UsageVerify, UsageHash, UsageVerify, UsageVerify}
+	if len(events) != len(wantOps) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(wantOps), events)
+	}
+	for i, e := range events {
+		if e.Op != wantOps[i] {
+			t.Errorf("event %d: op %q, want %q", i, e.Op, wantOps[i])
+		}
+		if wantLabel := i < 5; (e.Labels["trace_id"] == "abc") != wantLabel {
+			t.Errorf("event %d: unexpected labels %v", i, e.Labels)
+		}
+	}
+	if events[1].Match || events[1].Err != nil || !events[2].Match {
+		t.Errorf("unexpected verification outcomes %+v, %+v", events[1], events[2])
+	}
+	if events[4].Err == nil || events[4].Params != nil {
+		t.Errorf("expected decoding error in %+v", events[4])
+	}
+	// Usage is only reported for derivations, so not for the malformed hash.
+	if len(usage) != 5 || usage[0].Labels["trace_id"] != "abc" {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+}
@@ -0,0 +1,118 @@
+// Package lastpass derives the vault key and login hash of LastPass accounts,
+// so that incident-response and migration tooling can validate exported vault
+// credentials.
+//
+// The vault key, which encrypts the vault, is PBKDF2-HMAC-SHA256 of the master
+// password salted with the account's username, an email address. The login
+// hash sent to the server is one further iteration of it, salted with the
+// password, encoded in lowercase hexadecimal:
+//
+//	key, err := lastpass.VaultKey(username, password, iterations)
+//	hash, err := lastpass.LoginHash(username, password, iterations)
+//
+// Accounts configured with a single iteration predate PBKDF2, and use plain
+// SHA-256 instead: the vault key is SHA-256(username || password), and the
+// login hash is SHA-256(hex(key) || password).
+package lastpass
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// DefaultIterations is the iteration count LastPass uses for new accounts.
+const DefaultIterations = 600000
+
+// KeySize is the length of the vault key.
+const KeySize = 32
+
+// ErrEmptyUsername is returned if the username is empty, since the salt would
+// then be empty.
+var ErrEmptyUsername = errors.New("lastpass: username is empty")
+
+// salt returns the username as LastPass clients use it: trimmed and
+// lowercased.
+func salt(username string) ([]byte, error) {
+	s := strings.ToLower(strings.TrimSpace(username))
+	if s == "" {
+		return nil, ErrEmptyUsername
+	}
+	return []byte(s), nil
+}
+
+// VaultKey derives the key that encrypts the vault of the given account. It
+// returns pbkdf2.ErrInvalidParams if iterations is zero. The caller should
+// destroy the key once it is no longer needed.
+func VaultKey(username, password string, iterations uint32) (*pbkdf2.SecureBytes, error) {
+	s, err := salt(username)
+	if err != nil {
+		return nil, err
+	}
+	secret := pbkdf2.SecureBytesFromString(password)
+	defer secret.Destroy()
+
+	if iterations == 1 {
+		h := sha256.New()
+		h.Write(s)
+		h.Write(secret.Bytes())
+		return pbkdf2.NewSecureBytes(h.Sum(nil)), nil
+	}
+	return pbkdf2.DeriveKey(secret, s, &pbkdf2.Params{
+		Iterations: iterations,
+		SaltLength: uint32(len(s)),
+		KeyLength:  KeySize,
+		Variant:    pbkdf2.VariantSHA256,
+	})
+}
+
+// LoginHash returns the hexadecimal login hash that LastPass clients send to
+// the server to authenticate the given account.
+func LoginHash(username, password string, iterations uint32) (string, error) {
+	key, err := VaultKey(username, password, iterations)
+	if err != nil {
+		return "", err
+	}
+	defer key.Destroy()
+
+	if iterations == 1 {
+		h := sha256.New()
+		h.Write([]byte(hex.EncodeToString(key.Bytes())))
+		h.Write([]byte(password))
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+	hash, err := pbkdf2.DeriveKey(key, []byte(password), &pbkdf2.Params{
+		Iterations: 1,
+		SaltLength: 1,
+		KeyLength:  KeySize,
+		Variant:    pbkdf2.VariantSHA256,
+	})
+	if err != nil {
+		return "", err
+	}
+	defer hash.Destroy()
+	return hex.EncodeToString(hash.Bytes()), nil
+}
+
+// VerifyLoginHash returns nil if password is the master password of the
+// account with the given login hash, and pbkdf2.ErrMismatchedHashAndPassword
+// otherwise. The hash is compared case-insensitively.
+func VerifyLoginHash(username, password string, iterations uint32, loginHash string) error {
+	want, err := hex.DecodeString(loginHash)
+	if err != nil || len(want) != KeySize {
+		return pbkdf2.ErrInvalidHash
+	}
+	got, err := LoginHash(username, password, iterations)
+	if err != nil {
+		return err
+	}
+	b, _ := hex.DecodeString(got)
+	if subtle.ConstantTimeCompare(b, want) != 1 {
+		return pbkdf2.ErrMismatchedHashAndPassword
+	}
+	return nil
+}
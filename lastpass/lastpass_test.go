@@ -0,0 +1,82 @@
+package lastpass
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// The vectors were generated with Python's hashlib:
+//
+//	key = hashlib.pbkdf2_hmac("sha256", password, b"user@example.com", 5000, 32)
+//	hashlib.pbkdf2_hmac("sha256", key, password, 1, 32).hex()
+//
+// and, for a single iteration:
+//
+//	key = hashlib.sha256(b"user@example.com" + password).digest()
+//	hashlib.sha256(key.hex().encode() + password).hexdigest()
+const (
+	testUsername = " User@Example.com"
+	testPassword = "correct horse battery staple"
+
+	testKey       = "52ce2d33e007a7c15b3e3083c551fcff97da83045fa80cc6bbbd2cbc52c6f084"
+	testLoginHash = "d0531ea27b54c9fa6ef466bf0ef1112fd2cc01783d281f3654ae94a877672a4a"
+
+	testLegacyKey       = "615f1a38f77b6efed68d9d2b3be5ef22c712dc23a747eb821debe2a1da415d62"
+	testLegacyLoginHash = "e694f0b28a099b59714d1b88914543e9ab97b0daac50d41bc264c3593b765dbb"
+)
+
+func TestVectors(t *testing.T) {
+	tests := []struct {
+		iterations uint32
+		key, hash  string
+	}{
+		{5000, testKey, testLoginHash},
+		{1, testLegacyKey, testLegacyLoginHash},
+	}
+	for _, tt := range tests {
+		key, err := VaultKey(testUsername, testPassword, tt.iterations)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(key.Bytes()); got != tt.key {
+			t.Errorf("%d iterations: key = %s, want %s", tt.iterations, got, tt.key)
+		}
+		key.Destroy()
+
+		hash, err := LoginHash(testUsername, testPassword, tt.iterations)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash != tt.hash {
+			t.Errorf("%d iterations: login hash = %s, want %s", tt.iterations, hash, tt.hash)
+		}
+	}
+}
+
+func TestVerifyLoginHash(t *testing.T) {
+	if err := VerifyLoginHash(testUsername, testPassword, 5000, strings.ToUpper(testLoginHash)); err != nil {
+		t.Errorf("correct password: %v", err)
+	}
+	if err := VerifyLoginHash(testUsername, "wrong", 5000, testLoginHash); err != pbkdf2.ErrMismatchedHashAndPassword {
+		t.Errorf("wrong password: err = %v, want ErrMismatchedHashAndPassword", err)
+	}
+	if err := VerifyLoginHash(testUsername, testPassword, 4999, testLoginHash); err != pbkdf2.ErrMismatchedHashAndPassword {
+		t.Errorf("wrong iterations: err = %v, want ErrMismatchedHashAndPassword", err)
+	}
+	if err := VerifyLoginHash(testUsername, testPassword, 5000, testLoginHash[:62]); err != pbkdf2.ErrInvalidHash {
+		t.Errorf("short hash: err = %v, want ErrInvalidHash", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if _, err := VaultKey("", testPassword, 5000); err != ErrEmptyUsername {
+		t.Errorf("empty username: err = %v, want ErrEmptyUsername", err)
+	}
+	if _, err := LoginHash(testUsername, testPassword, 0); !errors.Is(err, pbkdf2.ErrInvalidParams) {
+		t.Errorf("zero iterations: err = %v, want ErrInvalidParams", err)
+	}
+}
@@ -0,0 +1,73 @@
+package pbkdf2
+
+import (
+	"crypto/sha1"
+	"runtime"
+	"strings"
+)
+
+// VariantLegacySHA1 identifies PBKDF2-HMAC-SHA1 hashes in the format used by
+// passlib's pbkdf2_sha1 and a number of PHP libraries:
+//
+//	$pbkdf2$<iterations>$<salt>$<key>
+//
+// where the salt and key are unpadded base64, using either "+" or "." as the
+// 62nd character. Such hashes can only be verified, and only by a Hasher with
+// AllowLegacySHA1 set. VariantLegacySHA1 is never accepted in Params used to
+// create a hash.
+const VariantLegacySHA1 = "pbkdf2"
+
+const legacySHA1Prefix = "$" + VariantLegacySHA1 + "$"
+
+func isLegacySHA1(hash string) bool {
+	return strings.HasPrefix(hash, legacySHA1Prefix)
+}
+
+// decodeLegacySHA1 parses a $pbkdf2$ hash.
+func decodeLegacySHA1(hash string) (*Hash, error) {
+	vals := strings.Split(hash, "$")
+	if len(vals) != 5 || vals[0] != "" || vals[1] != VariantLegacySHA1 {
+		return nil, decodeError("format", nil)
+	}
+
+	iterations, err := parseIterations(vals[2])
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Hash{Params: Params{Iterations: iterations, Variant: VariantLegacySHA1}}
+	h.Salt, err = decodeBase64(strings.ReplaceAll(vals[3], ".", "+"))
+	if err != nil {
+		return nil, decodeError("salt", err)
+	}
+	h.Params.SaltLength = uint32(len(h.Salt))
+
+	h.Key, err = decodeBase64(strings.ReplaceAll(vals[4], ".", "+"))
+	if err != nil {
+		wipe(h.Salt)
+		return nil, decodeError("key", err)
+	}
+	h.Params.KeyLength = uint32(len(h.Key))
+
+	return h, nil
+}
+
+// checkLegacySHA1 is checkHash for $pbkdf2$ hashes.
+func checkLegacySHA1(password *SecureBytes, hash string) (*CheckResult, error) {
+	h, err := decodeLegacySHA1(hash)
+	if err != nil {
+		return nil, err
+	}
+	salt, key := NewSecureBytes(h.Salt), NewSecureBytes(h.Key)
+	defer salt.Destroy()
+	defer key.Destroy()
+
+	var otherKey *SecureBytes
+	elapsed, cpu := measure(func() {
+		otherKey = NewSecureBytes(prfKey(password.Bytes(), salt.Bytes(), h.Params.Iterations, key.Len(), sha1.New))
+	})
+	runtime.KeepAlive(password)
+	defer otherKey.Destroy()
+
+	return &CheckResult{Match: keysEqual(key, otherKey), Params: &h.Params, Duration: elapsed, CPUTime: cpu}, nil
+}
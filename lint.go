@@ -0,0 +1,272 @@
+package pbkdf2
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+)
+
+// A Diagnostic is a problem found in a hash by Lint.
+type Diagnostic struct {
+	// Field is the part of the hash the problem is in: "format", "variant",
+	// "metadata", "iterations", "salt" or "key", as in a DecodeError.
+	Field string
+
+	// Start and End are the byte offsets of the offending text in the hash.
+	// The diagnostics for field "format" may cover the whole hash.
+	Start, End int
+
+	// Message explains the likely cause and how to fix it.
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return d.Field + ": " + d.Message
+}
+
+// foreignSchemes are the prefixes of hashes from other systems that are
+// commonly mistaken for this package's, and what to do with them.
+var foreignSchemes = []struct {
+	prefix, name, advice string
+}{
+	{"pbkdf2_sha256$", "Django", "register a codec for Django's format with RegisterCodec"},
+	{"pbkdf2_sha1$", "Django", "register a codec for Django's format with RegisterCodec"},
+	{"argon2$", "Django Argon2", "it is not a PBKDF2 hash"},
+	{"bcrypt$", "Django bcrypt", "it is not a PBKDF2 hash"},
+	{"bcrypt_sha256$", "Django bcrypt", "it is not a PBKDF2 hash"},
+	{"pbkdf2:", "Werkzeug", "register a codec for Werkzeug's format with RegisterCodec"},
+	{"$2a$", "bcrypt", "verify it with the crypt package"},
+	{"$2b$", "bcrypt", "verify it with the crypt package"},
+	{"$2y$", "bcrypt", "verify it with the crypt package"},
+	{"$argon2", "Argon2", "verify Argon2id hashes with the crypt package"},
+	{"$scrypt$", "scrypt", "verify it with the crypt package"},
+	{"$8$", "Cisco type 8", "verify it with the crypt package"},
+	{"{PBKDF2}", "Dovecot", "verify it with the crypt package"},
+	{"$1$", "MD5-crypt", "it is not a PBKDF2 hash"},
+	{"$5$", "SHA-256-crypt", "it is not a PBKDF2 hash"},
+	{"$6$", "SHA-512-crypt", "it is not a PBKDF2 hash"},
+}
+
+// Lint explains why hash cannot be decoded by DecodeHash, for support tools
+// and error messages shown to people who paste hashes from elsewhere:
+//
+//	for _, d := range pbkdf2.Lint(hash) {
+//		log.Printf("%s at %d: %s", d.Field, d.Start, d.Message)
+//	}
+//
+// Beyond reporting the field that is invalid, as a DecodeError does, it
+// recognises likely causes: whitespace or quotes around the hash, hashes from
+// other systems such as Django or bcrypt, upper-case or passlib-style variant
+// names, iteration counts with leading zeros or in PHC parameter style, and
+// salts and keys in padded, URL-safe or passlib's adapted base64.
+//
+// It returns nil if hash decodes, including with a registered codec.
+func Lint(hash string) []Diagnostic {
+	if h, err := decodeHash(hash); err == nil {
+		wipe(h.Salt)
+		wipe(h.Key)
+		return nil
+	}
+	whole := func(msg string) []Diagnostic {
+		return []Diagnostic{{Field: "format", End: len(hash), Message: msg}}
+	}
+
+	if isLegacySHA1(hash) {
+		if h, err := decodeFormat(hash, true); err == nil {
+			wipe(h.Salt)
+			wipe(h.Key)
+			return []Diagnostic{{Field: "variant", Start: 1, End: len(VariantLegacySHA1) + 1,
+				Message: "this is a legacy PBKDF2-HMAC-SHA1 hash, which only verifies with a Hasher with AllowLegacySHA1 set"}}
+		}
+	}
+	if trimmed := strings.TrimSpace(hash); trimmed != hash {
+		ds := whole("hash has leading or trailing whitespace, such as a line break copied from a file or terminal; trim it")
+		if trimmed == "" {
+			return ds
+		}
+		lead := strings.Index(hash, trimmed)
+		for _, d := range Lint(trimmed) {
+			d.Start += lead
+			d.End += lead
+			ds = append(ds, d)
+		}
+		return ds
+	}
+	if len(hash) >= 2 && (hash[0] == '"' || hash[0] == '\'' || hash[0] == '`') && hash[len(hash)-1] == hash[0] {
+		return whole("hash is quoted; remove the quotes")
+	}
+	if hash == "" {
+		return whole("hash is empty")
+	}
+	if name, ok := codecName(hash); ok {
+		_, err := decodeHash(hash)
+		return whole("hash does not decode with the registered " + name + " codec: " + err.Error())
+	}
+	for _, s := range foreignSchemes {
+		if strings.HasPrefix(hash, s.prefix) {
+			return whole("this looks like a " + s.name + " hash; " + s.advice)
+		}
+	}
+	if hash[0] != '$' {
+		if Lint("$"+hash) == nil {
+			return whole("hash is missing the leading $")
+		}
+		if !strings.Contains(hash, "$") {
+			if _, err := base64.StdEncoding.DecodeString(hash); err == nil {
+				return whole("this looks like a base64-encoded binary hash, such as ASP.NET Core Identity's; register a codec for its format with RegisterCodec")
+			}
+		}
+		return whole("hash must start with $ and the variant, such as $" + VariantSHA512 + "$")
+	}
+
+	segs := splitAll(hash)
+	if len(segs) < 4 {
+		return whole("hash has " + strconv.Itoa(len(segs)) + " segments separated by $, but needs at least 4: variant, iterations, salt and key; it is probably truncated")
+	}
+	if len(segs) > 5 {
+		return whole("hash has " + strconv.Itoa(len(segs)) + " segments separated by $, but at most 5 are allowed: variant, metadata, iterations, salt and key")
+	}
+
+	var ds []Diagnostic
+	ds = append(ds, lintVariant(segs[0])...)
+	if len(segs) == 5 {
+		if _, err := parseMetadata(segs[1].Value); err != nil {
+			ds = append(ds, segs[1].diagnostic("metadata", "metadata must be comma-separated key=value pairs, with lower-case keys and no repeated keys"))
+		}
+		segs = segs[1:]
+	}
+	ds = append(ds, lintIterations(segs[1])...)
+	ds = append(ds, lintBase64("salt", segs[2])...)
+	ds = append(ds, lintBase64("key", segs[3])...)
+	if len(ds) == 0 {
+		// The segments are individually valid, so the codec or the
+		// combination of them is at fault.
+		_, err := decodeHash(hash)
+		field := "format"
+		if de, ok := err.(*DecodeError); ok {
+			field = de.Field
+		}
+		ds = whole(err.Error())
+		ds[0].Field = field
+	}
+	return ds
+}
+
+// codecName returns the name of the registered codec for hash, if any, other
+// than FormatLegacySHA1.
+func codecName(hash string) (string, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	for _, c := range codecs {
+		if strings.HasPrefix(hash, c.prefix) && !c.legacy {
+			return c.name, true
+		}
+	}
+	return "", false
+}
+
+// splitAll splits hash after its leading "$" into all its segments, including
+// empty ones.
+func splitAll(hash string) []Segment {
+	var segs []Segment
+	start := 1
+	for i := 1; i <= len(hash); i++ {
+		if i == len(hash) || hash[i] == '$' {
+			segs = append(segs, Segment{Value: hash[start:i], Start: start, End: i})
+			start = i + 1
+		}
+	}
+	return segs
+}
+
+func (s Segment) diagnostic(field, msg string) Diagnostic {
+	return Diagnostic{Field: field, Start: s.Start, End: s.End, Message: msg}
+}
+
+func lintVariant(s Segment) []Diagnostic {
+	v := s.Value
+	switch {
+	case isRegisteredVariant(v):
+		return nil
+	case v == "":
+		return []Diagnostic{s.diagnostic("variant", "variant is empty")}
+	case isRegisteredVariant(strings.ToLower(v)):
+		return []Diagnostic{s.diagnostic("variant", "variant names are lower case; use "+strings.ToLower(v))}
+	case isRegisteredVariant(strings.ReplaceAll(strings.ToLower(v), "_", "-")):
+		return []Diagnostic{s.diagnostic("variant", "variant names use - rather than _, unlike passlib's Python names; use "+strings.ReplaceAll(strings.ToLower(v), "_", "-"))}
+	}
+	return []Diagnostic{s.diagnostic("variant", "unsupported variant "+strconv.Quote(v)+"; supported variants are "+strings.Join(Variants(), ", ")+", and others can be added with RegisterVariant")}
+}
+
+func isRegisteredVariant(v string) bool {
+	_, ok := variantPRF(v)
+	return ok && v != ""
+}
+
+func lintIterations(s Segment) []Diagnostic {
+	v := s.Value
+	for _, p := range []string{"i=", "rounds="} {
+		if n := strings.TrimPrefix(v, p); n != v {
+			if _, err := parseIterations(n); err == nil {
+				return []Diagnostic{s.diagnostic("iterations", "iterations are given as a PHC parameter; use the bare number, "+n)}
+			}
+		}
+	}
+	if _, err := parseIterations(v); err == nil {
+		return nil
+	}
+
+	var msg string
+	digits := strings.Trim(v, "0123456789") == ""
+	switch {
+	case v == "":
+		msg = "iterations field is empty"
+	case strings.TrimSpace(v) != v:
+		msg = "iterations field contains whitespace"
+	case v[0] == '-' || v[0] == '+':
+		msg = "iterations field has a sign; it must be a positive number without one"
+	case digits && strings.Trim(v, "0") == "":
+		msg = "iterations must not be zero"
+	case digits && v[0] == '0':
+		msg = "iterations field contains a leading zero; use " + strings.TrimLeft(v, "0")
+	case digits:
+		msg = "iterations exceed the maximum of " + strconv.FormatUint(MaxIterations, 10)
+	default:
+		msg = "iterations field must be a decimal number"
+	}
+	return []Diagnostic{s.diagnostic("iterations", msg)}
+}
+
+func lintBase64(field string, s Segment) []Diagnostic {
+	v := s.Value
+	if _, err := decodeBase64(v); err == nil {
+		return nil
+	}
+
+	var msg string
+	switch {
+	case v == "":
+		msg = field + " is empty; the hash is probably truncated"
+	case strings.TrimRight(v, "=") != v:
+		msg = field + " uses padded base64; remove the trailing ="
+	case strings.ContainsAny(v, " \t\r\n"):
+		msg = field + " contains whitespace or a line break"
+	case strings.ContainsAny(v, "-_"):
+		msg = field + " uses URL-safe base64; replace - with + and _ with /"
+	case strings.Contains(v, "."):
+		msg = field + " uses passlib's adapted base64, with . in place of +; replace . with +"
+	case len(v)%4 == 1:
+		msg = field + " has a length that is impossible for base64; it is probably truncated"
+	default:
+		if i := strings.IndexFunc(v, func(r rune) bool { return !isBase64Char(r) }); i >= 0 {
+			msg = field + " contains " + strconv.QuoteRune([]rune(v[i:])[0]) + ", which is not a base64 character"
+		} else {
+			msg = field + " ends with non-zero padding bits; it was probably truncated or edited"
+		}
+	}
+	return []Diagnostic{s.diagnostic(field, msg)}
+}
+
+func isBase64Char(r rune) bool {
+	return 'A' <= r && r <= 'Z' || 'a' <= r && r <= 'z' || '0' <= r && r <= '9' || r == '+' || r == '/'
+}
@@ -0,0 +1,91 @@
+package pbkdf2
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	hash, err := CreateHash("password", &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds := Lint(hash); ds != nil {
+		t.Fatalf("expected no diagnostics for a valid hash, got %v", ds)
+	}
+	s, err := SplitHash(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replace := func(seg Segment, v string) string {
+		return hash[:seg.Start] + v + hash[seg.End:]
+	}
+
+	tests := []struct {
+		hash    string
+		field   string
+		message string
+	}{
+		{hash + "\n", "format", "whitespace"},
+		{`"` + hash + `"`, "format", "quoted"},
+		{hash[1:], "format", "missing the leading $"},
+		{"", "format", "empty"},
+		{"pbkdf2_sha1$600000$c2FsdA$a2V5", "format", "Django hash; register a codec"},
+		{"pbkdf2:sha256:600000$salt$key", "format", "Werkzeug"},
+		{"$2b$10$abcdefghijklmnopqrstuu5s2v8.iXieOjg/.AySBTTZIIVFJeBui", "format", "bcrypt hash; verify it with the crypt package"},
+		{"AQAAAAIAAYagAAAAEA==", "format", "base64-encoded binary hash"},
+		{hash[:s.Salt.End], "format", "truncated"},
+		{replace(s.Variant, "PBKDF2-SHA512"), "variant", "use pbkdf2-sha512"},
+		{replace(s.Variant, "pbkdf2_sha512"), "variant", "use pbkdf2-sha512"},
+		{replace(s.Variant, "pbkdf2-md5"), "variant", "unsupported variant"},
+		{replace(s.Iterations, "01000"), "iterations", "leading zero; use 1000"},
+		{replace(s.Iterations, "i=1000"), "iterations", "bare number, 1000"},
+		{replace(s.Iterations, "-1000"), "iterations", "sign"},
+		{replace(s.Iterations, "0"), "iterations", "zero"},
+		{replace(s.Iterations, "99999999999999999999"), "iterations", "maximum"},
+		{replace(s.Iterations, "1k"), "iterations", "decimal"},
+		{replace(s.Salt, s.Salt.Value+"=="), "salt", "padded base64"},
+		{replace(s.Salt, "ab-_cdefghijklmnopqrst"), "salt", "URL-safe"},
+		{replace(s.Key, "ab.cdefghijklmnopqrstu"), "key", "passlib's adapted base64"},
+		{replace(s.Key, "abcde"), "key", "impossible"},
+		{replace(s.Key, "AB"), "key", "non-zero padding bits"},
+		{replace(s.Key, "ab*c"), "key", "'*'"},
+		{replace(s.Variant, "pbkdf2-sha512$x=Y=Z"), "metadata", "key=value"},
+	}
+	for _, tt := range tests {
+		ds := Lint(tt.hash)
+		found := false
+		for _, d := range ds {
+			if d.Field == tt.field && strings.Contains(d.Message, tt.message) {
+				found = true
+				if got := tt.hash[d.Start:d.End]; d.Field != "format" && strings.Contains(got, "$") {
+					t.Errorf("%q: diagnostic %v covers %q", tt.hash, d, got)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%q: expected %s diagnostic containing %q, got %v", tt.hash, tt.field, tt.message, ds)
+		}
+	}
+
+	// Problems in several segments are all reported.
+	ds := Lint("$" + s.Variant.Value + "$01000$" + s.Salt.Value + "==$" + s.Key.Value)
+	if len(ds) != 2 || ds[0].Field != "iterations" || ds[1].Field != "salt" {
+		t.Errorf("expected iterations and salt diagnostics, got %v", ds)
+	}
+}
+
+func TestLintCodec(t *testing.T) {
+	RegisterCodec("lint-test", "lint$", func(string) (*Hash, error) { return nil, decodeError("key", nil) })
+	ds := Lint("lint$x")
+	if len(ds) != 1 || !strings.Contains(ds[0].Message, "registered lint-test codec") {
+		t.Fatalf("unexpected diagnostics %v", ds)
+	}
+}
+
+func TestLintLegacySHA1(t *testing.T) {
+	ds := Lint(legacySHA1Hashes[0])
+	if len(ds) != 1 || ds[0].Field != "variant" || !strings.Contains(ds[0].Message, "AllowLegacySHA1") {
+		t.Fatalf("unexpected diagnostics %v", ds)
+	}
+}
@@ -0,0 +1,108 @@
+package pbkdf2
+
+import (
+	"context"
+	"time"
+)
+
+// A Verifier verifies passwords against hashes, returning nil if they match,
+// ErrMismatchedHashAndPassword if they do not, and any other error if the
+// hash could not be verified. It is satisfied by *Hasher.
+type Verifier interface {
+	VerifyContext(ctx context.Context, password, hash string) error
+}
+
+// VerifierFunc adapts an ordinary function to a Verifier.
+type VerifierFunc func(ctx context.Context, password, hash string) error
+
+// VerifyContext calls f(ctx, password, hash).
+func (f VerifierFunc) VerifyContext(ctx context.Context, password, hash string) error {
+	return f(ctx, password, hash)
+}
+
+// A Middleware wraps a Verifier with a cross-cutting behaviour, such as rate
+// limiting, caching, metrics or audit logging, returning a Verifier that
+// calls next, or refuses to.
+type Middleware func(next Verifier) Verifier
+
+// Compose returns a Middleware applying each of middleware in turn, the first
+// outermost, so that it sees every call first and every result last.
+func Compose(middleware ...Middleware) Middleware {
+	return func(next Verifier) Verifier {
+		for i := len(middleware) - 1; i >= 0; i-- {
+			next = middleware[i](next)
+		}
+		return next
+	}
+}
+
+// Chain returns v wrapped in middleware, the first outermost:
+//
+//	v := pbkdf2.Chain(hasher,
+//		ratelimit.Middleware(limiter, nil),
+//		pbkdf2.PadFailures(250*time.Millisecond, nil),
+//		pbkdf2.RequirePolicy(policy),
+//	)
+//	err := v.VerifyContext(pbkdf2.WithLabels(ctx, map[string]string{"client": id}), password, hash)
+//
+// Order matters: here, requests refused by the rate limiter return at once,
+// while hashes rejected by the policy are padded like wrong passwords.
+func Chain(v Verifier, middleware ...Middleware) Verifier {
+	return Compose(middleware...)(v)
+}
+
+// PadFailures returns a Middleware that makes failed verifications, whether
+// the password did not match or the hash was rejected, take at least d, like
+// Hasher.MinFailureDuration, to slow online guessing. Failures are measured
+// and padded with clock, or SystemClock if clock is nil; successful
+// verifications are not delayed. Like Hasher, the padding runs to completion
+// even if ctx is done.
+func PadFailures(d time.Duration, clock Clock) Middleware {
+	clock = clockOrSystem(clock)
+	return func(next Verifier) Verifier {
+		return VerifierFunc(func(ctx context.Context, password, hash string) error {
+			start := clock.Now()
+			err := next.VerifyContext(ctx, password, hash)
+			if err == nil {
+				return nil
+			}
+			// The padding must not end early, or its end would reveal when
+			// ctx was cancelled rather than hide how long verifying took.
+			clock.Sleep(context.Background(), d-clock.Since(start))
+			return err
+		})
+	}
+}
+
+// RequirePolicy returns a Middleware that rejects hashes whose params violate
+// policy with a *PolicyError, like Hasher.Policy, without calling the next
+// Verifier. Hashes that cannot be decoded are passed on, for the next
+// Verifier to reject or to decode with options of its own.
+func RequirePolicy(policy *Policy) Middleware {
+	return func(next Verifier) Verifier {
+		return VerifierFunc(func(ctx context.Context, password, hash string) error {
+			if decoded, err := decodeHash(hash); err == nil {
+				wipe(decoded.Salt)
+				wipe(decoded.Key)
+				if err := policy.CheckHash(decoded); err != nil {
+					return err
+				}
+			}
+			return next.VerifyContext(ctx, password, hash)
+		})
+	}
+}
+
+// Observe returns a Middleware that calls f after every verification with
+// its context, duration and result, such as to record metrics. f is called
+// synchronously, and must be safe for concurrent use.
+func Observe(f func(ctx context.Context, d time.Duration, err error)) Middleware {
+	return func(next Verifier) Verifier {
+		return VerifierFunc(func(ctx context.Context, password, hash string) error {
+			start := time.Now()
+			err := next.VerifyContext(ctx, password, hash)
+			f(ctx, time.Since(start), err)
+			return err
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package pbkdf2
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompose(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next Verifier) Verifier {
+			return VerifierFunc(func(ctx context.Context, password, hash string) error {
+				calls = append(calls, name+" in")
+				err := next.VerifyContext(ctx, password, hash)
+				calls = append(calls, name+" out")
+				return err
+			})
+		}
+	}
+	inner := VerifierFunc(func(ctx context.Context, password, hash string) error {
+		calls = append(calls, "verify")
+		return nil
+	})
+
+	v := Chain(inner, trace("a"), Compose(trace("b"), trace("c")))
+	if err := v.VerifyContext(context.Background(), "password", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a in", "b in", "c in", "verify", "c out", "b out", "a out"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+
+	if v := Chain(inner); v.VerifyContext(context.Background(), "", "") != nil {
+		t.Fatal("expected an empty chain to call the verifier")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	hash, err := CreateHash("password", &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var observed []error
+	v := Chain(&Hasher{},
+		Observe(func(ctx context.Context, d time.Duration, err error) {
+			if d <= 0 {
+				t.Errorf("expected a positive duration, got %v", d)
+			}
+			observed = append(observed, err)
+		}),
+		PadFailures(50*time.Millisecond, nil),
+		RequirePolicy(&Policy{MinIterations: 2000}),
+	)
+	ctx := context.Background()
+
+	start := time.Now()
+	var policyErr *PolicyError
+	if err := v.VerifyContext(ctx, "password", hash); !errors.As(err, &policyErr) {
+		t.Fatalf("expected a *PolicyError, got %v", err)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("expected the rejection to be padded, took %v", d)
+	}
+
+	clock := NewFakeClock(time.Unix(0, 0))
+	v = Chain(&Hasher{}, PadFailures(time.Hour, clock))
+	if err := v.VerifyContext(ctx, "password", hash); err != nil {
+		t.Fatal(err)
+	}
+	if d := clock.Since(time.Unix(0, 0)); d != 0 {
+		t.Errorf("expected a success not to be padded, took %v", d)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := v.VerifyContext(cancelled, "wrong", hash); err != ErrMismatchedHashAndPassword {
+		t.Fatalf("expected ErrMismatchedHashAndPassword, got %v", err)
+	}
+	if d := clock.Since(time.Unix(0, 0)); d != time.Hour {
+		t.Errorf("expected the padding to outlast a cancelled context, took %v", d)
+	}
+	if len(observed) != 1 || observed[0] == nil {
+		t.Errorf("expected one observed failure, got %v", observed)
+	}
+}
@@ -0,0 +1,107 @@
+// Package mobile provides a gomobile-friendly wrapper around the pbkdf2
+// package, so that iOS and Android applications can hash and verify
+// passwords with exactly the same parameters and format as a Go backend.
+//
+// Only types supported by gomobile bind are used: parameters are passed as
+// plain ints rather than a *pbkdf2.Params, and verification details are
+// returned in a CheckResult carrying any error as a string.
+//
+//	gomobile bind -target=ios github.com/pganguli/pbkdf2/mobile
+//	gomobile bind -target=android github.com/pganguli/pbkdf2/mobile
+package mobile
+
+import (
+	"math"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// ErrInvalidParams is returned by CreateHash if a parameter is not positive or
+// does not fit in 32 bits.
+var ErrInvalidParams = pbkdf2.ErrInvalidParams
+
+// DefaultIterations returns the number of iterations in the pbkdf2 package-level
+// default params; see pbkdf2.GetDefaultParams.
+func DefaultIterations() int {
+	return int(pbkdf2.GetDefaultParams().Iterations)
+}
+
+// DefaultSaltLength returns the salt length in the pbkdf2 package-level
+// default params; see pbkdf2.GetDefaultParams.
+func DefaultSaltLength() int {
+	return int(pbkdf2.GetDefaultParams().SaltLength)
+}
+
+// DefaultKeyLength returns the key length in the pbkdf2 package-level
+// default params; see pbkdf2.GetDefaultParams.
+func DefaultKeyLength() int {
+	return int(pbkdf2.GetDefaultParams().KeyLength)
+}
+
+// CreateHash returns a hash of password using the given parameters. See
+// pbkdf2.CreateHash for the format of the returned hash.
+func CreateHash(password string, iterations, saltLength, keyLength int) (string, error) {
+	params, err := toParams(iterations, saltLength, keyLength)
+	if err != nil {
+		return "", err
+	}
+	return pbkdf2.CreateHash(password, params)
+}
+
+// CreateHashDefault returns a hash of password using the pbkdf2 package-level
+// default params.
+func CreateHashDefault(password string) (string, error) {
+	return pbkdf2.CreateHash(password, nil)
+}
+
+// ComparePasswordAndHash reports whether password matches hash. See
+// pbkdf2.ComparePasswordAndHash.
+func ComparePasswordAndHash(password, hash string) (bool, error) {
+	return pbkdf2.ComparePasswordAndHash(password, hash)
+}
+
+// CheckResult is the result of CheckHash.
+type CheckResult struct {
+	// Match is true if the password matched the hash.
+	Match bool
+
+	// The parameters the hash was created with. They are zero if the hash
+	// could not be decoded.
+	Iterations int
+	SaltLength int
+	KeyLength  int
+
+	// Error is empty on success, otherwise it holds the error message.
+	Error string
+}
+
+// CheckHash is like ComparePasswordAndHash, except it also returns the
+// parameters that the hash was created with, so that applications can decide
+// whether to rehash. See pbkdf2.CheckHash.
+func CheckHash(password, hash string) *CheckResult {
+	match, params, err := pbkdf2.CheckHash(password, hash)
+	if err != nil {
+		return &CheckResult{Error: err.Error()}
+	}
+
+	return &CheckResult{
+		Match:      match,
+		Iterations: int(params.Iterations),
+		SaltLength: int(params.SaltLength),
+		KeyLength:  int(params.KeyLength),
+	}
+}
+
+func toParams(iterations, saltLength, keyLength int) (*pbkdf2.Params, error) {
+	for _, v := range []int{iterations, saltLength, keyLength} {
+		if v <= 0 || uint64(v) > math.MaxUint32 {
+			return nil, ErrInvalidParams
+		}
+	}
+
+	return &pbkdf2.Params{
+		Iterations: uint32(iterations),
+		SaltLength: uint32(saltLength),
+		KeyLength:  uint32(keyLength),
+	}, nil
+}
@@ -0,0 +1,43 @@
+package mobile
+
+import "testing"
+
+func TestCreateAndCheckHash(t *testing.T) {
+	hash, err := CreateHash("pa$$word", 1000, 16, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	match, err := ComparePasswordAndHash("pa$$word", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !match {
+		t.Error("expected password and hash to match")
+	}
+
+	result := CheckHash("otherPa$$word", hash)
+	if result.Error != "" {
+		t.Fatal(result.Error)
+	}
+	if result.Match {
+		t.Error("expected password and hash to not match")
+	}
+	if result.Iterations != 1000 || result.SaltLength != 16 || result.KeyLength != 32 {
+		t.Fatalf("unexpected params in %#v", *result)
+	}
+
+	result = CheckHash("pa$$word", "$pbkdf2-sha512$1000")
+	if result.Error == "" || result.Match {
+		t.Fatalf("expected an error for a malformed hash, got %#v", *result)
+	}
+}
+
+func TestCreateHashInvalidParams(t *testing.T) {
+	if _, err := CreateHash("pa$$word", -1, 16, 32); err != ErrInvalidParams {
+		t.Fatalf("expected ErrInvalidParams, got %v", err)
+	}
+	if _, err := CreateHash("pa$$word", 1000, 0, 32); err != ErrInvalidParams {
+		t.Fatalf("expected ErrInvalidParams, got %v", err)
+	}
+}
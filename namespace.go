@@ -0,0 +1,43 @@
+package pbkdf2
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// MetadataNamespace is the metadata key under which a hash's namespace is
+// recorded.
+const MetadataNamespace = "ns"
+
+var (
+	// ErrInvalidNamespace is returned when creating a hash if the namespace
+	// is not a valid metadata value.
+	ErrInvalidNamespace = errors.New("pbkdf2: invalid namespace")
+
+	// ErrNamespaceMismatch is returned by a Hasher with a Namespace if a
+	// hash belongs to another namespace, or to none.
+	ErrNamespaceMismatch = errors.New("pbkdf2: hash belongs to a different namespace")
+)
+
+// NamespaceSalt returns the salt that PBKDF2 is run over for a hash with the
+// given stored salt in namespace. The namespace is length-prefixed so that
+// no two namespace and salt pairs produce the same result, which means that a
+// precomputed table for one namespace is useless against any other, even if
+// every other input, including a pepper, is known.
+//
+// NamespaceSalt is intended for building other schemes on top of this
+// package, such as peppering. Hashes created by a Hasher with a Namespace use
+// it automatically, and record the namespace so that they verify with
+// CheckHash.
+func NamespaceSalt(namespace string, salt []byte) []byte {
+	b := make([]byte, 4, 4+len(namespace)+len(salt))
+	binary.BigEndian.PutUint32(b, uint32(len(namespace)))
+	b = append(b, namespace...)
+	return append(b, salt...)
+}
+
+// ValidNamespace reports whether namespace can be recorded in a hash: it must
+// be non-empty and consist of letters, digits and the characters "+/.:_-".
+func ValidNamespace(namespace string) bool {
+	return validMetadataValue(namespace)
+}
@@ -0,0 +1,74 @@
+package pbkdf2
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNamespaceSalt(t *testing.T) {
+	a := NamespaceSalt("ab", []byte("c"))
+	b := NamespaceSalt("a", []byte("bc"))
+	if bytes.Equal(a, b) {
+		t.Error("different namespace and salt pairs produced the same salt")
+	}
+	if want := []byte("\x00\x00\x00\x02abc"); !bytes.Equal(a, want) {
+		t.Errorf("got %q, want %q", a, want)
+	}
+}
+
+func TestHasherNamespace(t *testing.T) {
+	params := &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32}
+	acme := &Hasher{Params: params, Namespace: "acme"}
+	globex := &Hasher{Params: params, Namespace: "globex"}
+
+	hash, err := acme.CreateHash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := ParseHash(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Metadata[MetadataNamespace] != "acme" {
+		t.Fatalf("namespace not recorded in %s", hash)
+	}
+
+	if err := acme.Verify("password", hash); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify("password", hash); err != nil {
+		t.Errorf("expected the package-level Verify to honour the namespace, got %v", err)
+	}
+	if err := globex.Verify("password", hash); err != ErrNamespaceMismatch {
+		t.Errorf("expected ErrNamespaceMismatch, got %v", err)
+	}
+	if acme.NeedsRehash(hash) || !globex.NeedsRehash(hash) {
+		t.Error("unexpected NeedsRehash result")
+	}
+
+	// The namespace is mixed into the derivation, so moving a hash into
+	// another namespace by editing its metadata does not work.
+	h.Metadata[MetadataNamespace] = "globex"
+	if match, _, err := CheckHash("password", h.String()); err != nil || match {
+		t.Errorf("expected a relabelled hash not to match, got %v, %v", match, err)
+	}
+
+	plain, err := CreateHash("password", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := acme.Verify("password", plain); err != ErrNamespaceMismatch {
+		t.Errorf("expected ErrNamespaceMismatch for a hash without a namespace, got %v", err)
+	}
+	converted, err := acme.ConvertHash("password", plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := acme.Verify("password", converted); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := (&Hasher{Params: params, Namespace: "a,b"}).CreateHash("password"); err != ErrInvalidNamespace {
+		t.Errorf("expected ErrInvalidNamespace, got %v", err)
+	}
+}
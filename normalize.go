@@ -0,0 +1,40 @@
+package pbkdf2
+
+import "strings"
+
+// Normalize re-encodes hash in the canonical form produced by CreateHash, so
+// that hashes arriving from different producers can be stored in one consistent
+// representation. It accepts the encodings tolerated by HashesEquivalent;
+// metadata is kept, sorted by key. The password is not needed, and the salt and
+// key are unchanged, so the normalized hash verifies exactly as the original
+// did.
+//
+// Legacy SHA-1 hashes remain in the $pbkdf2$ format, with "+" as the 62nd
+// base64 character. It returns an error if hash cannot be decoded.
+func Normalize(hash string) (string, error) {
+	h, err := decodeAnyLayout(hash)
+	if err != nil {
+		return "", err
+	}
+	defer wipe(h.Salt)
+	defer wipe(h.Key)
+	return h.String(), nil
+}
+
+// decodeAnyLayout decodes hash after rewriting it into the layout produced by
+// CreateHash.
+func decodeAnyLayout(hash string) (*Hash, error) {
+	vals := strings.Split(hash, "$")
+	if len(vals) >= 2 {
+		vals[1] = strings.ToLower(vals[1])
+	}
+	if len(vals) == 5 && strings.HasPrefix(vals[2], "i=") {
+		vals[2] = vals[2][len("i="):]
+	}
+	if n := len(vals); n >= 2 {
+		for _, i := range []int{n - 2, n - 1} {
+			vals[i] = strings.ReplaceAll(strings.TrimRight(vals[i], "="), ".", "+")
+		}
+	}
+	return decodeFormat(strings.Join(vals, "$"), true)
+}
@@ -0,0 +1,43 @@
+package pbkdf2
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	const canonical = "$pbkdf2-sha512$a=1,b=2$1000$MDEyMzQ1Njc4OWFiY2RlZg$38DzhdBT7fPaUGBlsh42VTuuKSFAIYGZJ7l6feCDLIk"
+
+	for _, hash := range []string{
+		canonical,
+		"$pbkdf2-sha512$b=2,a=1$1000$MDEyMzQ1Njc4OWFiY2RlZg$38DzhdBT7fPaUGBlsh42VTuuKSFAIYGZJ7l6feCDLIk",
+		"$PBKDF2-SHA512$a=1,b=2$1000$MDEyMzQ1Njc4OWFiY2RlZg==$38DzhdBT7fPaUGBlsh42VTuuKSFAIYGZJ7l6feCDLIk=",
+	} {
+		got, err := Normalize(hash)
+		if err != nil {
+			t.Fatalf("%s: %v", hash, err)
+		}
+		if got != canonical {
+			t.Errorf("%s: got %s", hash, got)
+		}
+	}
+
+	got, err := Normalize("$pbkdf2-sha256$i=1000$Ln.vLn.vLn.vLn.vLn.vLg$38DzhdBT7fPaUGBlsh42VTuuKSFAIYGZJ7l6feCDLIk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "$pbkdf2-sha256$1000$Ln+vLn+vLn+vLn+vLn+vLg$38DzhdBT7fPaUGBlsh42VTuuKSFAIYGZJ7l6feCDLIk"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	for _, hash := range legacySHA1Hashes {
+		normalized, err := Normalize(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := HashesEquivalent(hash, normalized); err != nil || !ok {
+			t.Errorf("%s: normalized to non-equivalent %s", hash, normalized)
+		}
+	}
+
+	if _, err := Normalize("$pbkdf2-md5$1000$MDEy$MDEy"); err != ErrIncompatibleVariant {
+		t.Errorf("expected ErrIncompatibleVariant, got %v", err)
+	}
+}
@@ -0,0 +1,326 @@
+// Package paramcheck finds weak password hashing parameters in Go code using
+// the pbkdf2 module, so that they are caught in review rather than in
+// production. It reports:
+//
+//   - pbkdf2.Params literals, and assignments to the fields of a Params,
+//     whose constant iterations, salt length or key length fall below the
+//     guidance of pbkdf2.IsBelowCurrentGuidance, or whose variant is
+//     deprecated;
+//   - calls passing such constants to functions taking the params as
+//     separate arguments, such as mobile.CreateHash;
+//   - uses of pbkdf2.DefaultParams, which is meant for development and
+//     testing only.
+//
+// Only constant values are checked: params computed at run time, such as by
+// pbkdf2.Calibrate or loaded with package config, are left to run-time checks.
+// When the variant is not known, iterations are compared with the lowest
+// recommendation of any variant. Test files are not checked, since tests
+// commonly use cheap params on purpose, and a diagnostic is suppressed by a
+// "//pbkdf2vet:ignore" comment on its line or the line above, for code bound
+// to params fixed by a protocol or a legacy system.
+//
+// The checker has the shape of a golang.org/x/tools/go/analysis analyzer, and
+// is run by go vet through the pbkdf2vet command.
+package paramcheck
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"math"
+	"strings"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// Name is the name of the checker, as used in the ignore directive.
+const Name = "pbkdf2vet"
+
+// Doc describes the checker.
+const Doc = "report weak pbkdf2 params and uses of pbkdf2.DefaultParams"
+
+// ignoreDirective suppresses the diagnostics of its line and the next.
+const ignoreDirective = "//" + Name + ":ignore"
+
+const (
+	pbkdf2Path = "github.com/pganguli/pbkdf2"
+	mobilePath = pbkdf2Path + "/mobile"
+)
+
+// paramsFuncs are the functions taking params as separate arguments, keyed by
+// package path and name, with the Params field of each argument by index.
+var paramsFuncs = map[string]map[int]string{
+	mobilePath + ".CreateHash": {1: "Iterations", 2: "SaltLength", 3: "KeyLength"},
+}
+
+// A Diagnostic is a problem found by Run.
+type Diagnostic struct {
+	Pos     token.Pos
+	Message string
+}
+
+// A Pass is a type-checked package to be checked, as in an analysis.Pass.
+type Pass struct {
+	Fset      *token.FileSet
+	Files     []*ast.File
+	Pkg       *types.Package
+	TypesInfo *types.Info
+
+	// Report is called for each diagnostic, in source order within a file.
+	Report func(Diagnostic)
+}
+
+// Run checks the package of pass. TypesInfo must record at least Types,
+// Uses and Selections.
+func Run(pass *Pass) {
+	for _, f := range pass.Files {
+		name := pass.Fset.Position(f.Pos()).Filename
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		c := &checker{pass: pass, ignored: ignoredLines(pass.Fset, f)}
+		ast.Inspect(f, c.visit)
+	}
+}
+
+type checker struct {
+	pass    *Pass
+	ignored map[int]bool
+}
+
+func (c *checker) visit(n ast.Node) bool {
+	switch n := n.(type) {
+	case *ast.CompositeLit:
+		if isParams(c.pass.TypesInfo.TypeOf(n)) {
+			c.checkLiteral(n)
+		}
+	case *ast.AssignStmt:
+		if n.Tok == token.ASSIGN && len(n.Lhs) == len(n.Rhs) {
+			for i, lhs := range n.Lhs {
+				c.checkAssign(lhs, n.Rhs[i])
+			}
+		}
+	case *ast.CallExpr:
+		c.checkCall(n)
+	case *ast.Ident:
+		c.checkDefaultParams(n)
+	}
+	return true
+}
+
+// checkLiteral checks a Params literal. An omitted variant is SHA-512.
+func (c *checker) checkLiteral(lit *ast.CompositeLit) {
+	st, ok := c.pass.TypesInfo.TypeOf(lit).Underlying().(*types.Struct)
+	if !ok {
+		return
+	}
+	fields := make(map[string]ast.Expr)
+	for i, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key, ok := kv.Key.(*ast.Ident); ok {
+				fields[key.Name] = kv.Value
+			}
+		} else if i < st.NumFields() {
+			fields[st.Field(i).Name()] = elt
+		}
+	}
+	c.checkFields("pbkdf2.Params", fields, true)
+}
+
+// checkAssign checks an assignment to a field of a Params, whose variant is
+// not known.
+func (c *checker) checkAssign(lhs, rhs ast.Expr) {
+	sel, ok := lhs.(*ast.SelectorExpr)
+	if !ok {
+		return
+	}
+	selection, ok := c.pass.TypesInfo.Selections[sel]
+	if !ok || selection.Kind() != types.FieldVal || !isParams(selection.Recv()) {
+		return
+	}
+	c.checkFields("pbkdf2.Params", map[string]ast.Expr{sel.Sel.Name: rhs}, false)
+}
+
+// checkCall checks the arguments of a call to one of paramsFuncs, which all
+// use SHA-512.
+func (c *checker) checkCall(call *ast.CallExpr) {
+	fn := calledFunc(c.pass.TypesInfo, call)
+	if fn == nil || fn.Pkg() == nil {
+		return
+	}
+	name := fn.Pkg().Name() + "." + fn.Name()
+	args, ok := paramsFuncs[fn.Pkg().Path()+"."+fn.Name()]
+	if !ok {
+		return
+	}
+	fields := make(map[string]ast.Expr)
+	for i, field := range args {
+		if i < len(call.Args) {
+			fields[field] = call.Args[i]
+		}
+	}
+	c.checkFields(name, fields, true)
+}
+
+// checkDefaultParams reports a use of pbkdf2.DefaultParams outside package
+// pbkdf2 itself.
+func (c *checker) checkDefaultParams(id *ast.Ident) {
+	v, ok := c.pass.TypesInfo.Uses[id].(*types.Var)
+	if !ok || v.Name() != "DefaultParams" || v.Pkg() == nil || v.Pkg().Path() != pbkdf2Path || v.Parent() != v.Pkg().Scope() {
+		return
+	}
+	if c.pass.Pkg.Path() == pbkdf2Path {
+		return
+	}
+	c.report(id, "pbkdf2.DefaultParams is meant for development and testing; choose params for production, such as with pbkdf2.Calibrate or pbkdf2.ProfileParams")
+}
+
+// checkFields checks the constant values among fields, keyed by Params field
+// name, reporting each shortfall at its value. If the variant is not among
+// fields, it is SHA-512 if variantImplied is set, and unknown otherwise.
+func (c *checker) checkFields(what string, fields map[string]ast.Expr, variantImplied bool) {
+	// Fields that are not constant are set high enough to meet any
+	// guidance, so that only the constant ones are reported.
+	p := &pbkdf2.Params{Iterations: math.MaxUint32, SaltLength: math.MaxUint32, KeyLength: math.MaxUint32}
+	exprs := make(map[string]ast.Expr)
+	for _, f := range []struct {
+		name, param string
+		dst         *uint32
+	}{
+		{"Iterations", "iterations", &p.Iterations},
+		{"SaltLength", "salt length", &p.SaltLength},
+		{"KeyLength", "key length", &p.KeyLength},
+	} {
+		if n, ok := c.uint32Value(fields[f.name]); ok {
+			*f.dst = n
+			exprs[f.param] = fields[f.name]
+		}
+	}
+
+	variantKnown := variantImplied
+	if e, ok := fields["Variant"]; ok {
+		variantKnown = false
+		if v := c.constValue(e); v != nil && v.Kind() == constant.String {
+			p.Variant = constant.StringVal(v)
+			variantKnown = true
+			exprs["variant"] = e
+		}
+	}
+	if !variantKnown {
+		p.Variant = lowestVariant()
+	}
+
+	_, report := pbkdf2.IsBelowCurrentGuidance(p)
+	for _, s := range report.Shortfalls {
+		e, ok := exprs[s.Param]
+		if !ok {
+			continue
+		}
+		msg := "weak " + what + ": " + s.String()
+		if s.Param == "iterations" {
+			msg += " by " + report.Source
+		}
+		c.report(e, msg)
+	}
+}
+
+func (c *checker) report(n ast.Node, msg string) {
+	if c.ignored[c.pass.Fset.Position(n.Pos()).Line] {
+		return
+	}
+	c.pass.Report(Diagnostic{Pos: n.Pos(), Message: msg})
+}
+
+func (c *checker) constValue(e ast.Expr) constant.Value {
+	if e == nil {
+		return nil
+	}
+	return c.pass.TypesInfo.Types[e].Value
+}
+
+// uint32Value returns the value of e, if it is an integer constant, clamped
+// to the range of a uint32.
+func (c *checker) uint32Value(e ast.Expr) (uint32, bool) {
+	v := c.constValue(e)
+	if v == nil || v.Kind() != constant.Int {
+		return 0, false
+	}
+	if constant.Sign(v) < 0 {
+		return 0, true
+	}
+	n, exact := constant.Uint64Val(v)
+	if !exact || n > math.MaxUint32 {
+		return math.MaxUint32, true
+	}
+	return uint32(n), true
+}
+
+// lowestVariant returns the variant with the lowest recommended iterations,
+// so that params of an unknown variant are only reported if they are too
+// weak for every variant.
+func lowestVariant() string {
+	var variant string
+	var lowest uint32
+	for v, n := range pbkdf2.RecommendedMinIterations() {
+		if variant == "" || n < lowest || n == lowest && v < variant {
+			variant, lowest = v, n
+		}
+	}
+	return variant
+}
+
+// isParams reports whether t is pbkdf2.Params or a pointer to it.
+func isParams(t types.Type) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Name() == "Params" && obj.Pkg() != nil && obj.Pkg().Path() == pbkdf2Path
+}
+
+// calledFunc returns the package-level function called by call, or nil.
+func calledFunc(info *types.Info, call *ast.CallExpr) *types.Func {
+	fun := call.Fun
+	for {
+		paren, ok := fun.(*ast.ParenExpr)
+		if !ok {
+			break
+		}
+		fun = paren.X
+	}
+	var id *ast.Ident
+	switch fun := fun.(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return nil
+	}
+	fn, ok := info.Uses[id].(*types.Func)
+	if !ok || fn.Type().(*types.Signature).Recv() != nil {
+		return nil
+	}
+	return fn
+}
+
+// ignoredLines returns the lines of f on which diagnostics are suppressed by
+// an ignore directive.
+func ignoredLines(fset *token.FileSet, f *ast.File) map[int]bool {
+	lines := make(map[int]bool)
+	for _, group := range f.Comments {
+		for _, comment := range group.List {
+			if strings.HasPrefix(comment.Text, ignoreDirective) {
+				line := fset.Position(comment.Pos()).Line
+				lines[line] = true
+				lines[line+1] = true
+			}
+		}
+	}
+	return lines
+}
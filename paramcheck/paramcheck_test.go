@@ -0,0 +1,95 @@
+package paramcheck
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var wantRE = regexp.MustCompile(`// want ((?:"(?:[^"\\]|\\.)*"\s*)+)$`)
+
+// TestRun checks the package in testdata/weak, whose lines expecting
+// diagnostics end with comments of the form
+//
+//	// want "regexp" ...
+//
+// with one regexp for each diagnostic reported on the line.
+func TestRun(t *testing.T) {
+	fset := token.NewFileSet()
+	paths, err := filepath.Glob(filepath.Join("testdata", "weak", "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []*ast.File
+	want := make(map[int][]*regexp.Regexp)
+	for _, path := range paths {
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+		for _, group := range f.Comments {
+			for _, comment := range group.List {
+				m := wantRE.FindStringSubmatch(comment.Text)
+				if m == nil {
+					continue
+				}
+				line := fset.Position(comment.Pos()).Line
+				for _, q := range regexp.MustCompile(`"(?:[^"\\]|\\.)*"`).FindAllString(m[1], -1) {
+					s, err := strconv.Unquote(q)
+					if err != nil {
+						t.Fatal(err)
+					}
+					want[line] = append(want[line], regexp.MustCompile(s))
+				}
+			}
+		}
+	}
+
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check("weak", fset, files, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Diagnostic
+	Run(&Pass{Fset: fset, Files: files, Pkg: pkg, TypesInfo: info, Report: func(d Diagnostic) {
+		got = append(got, d)
+	}})
+	for _, d := range got {
+		pos := fset.Position(d.Pos)
+		if strings.HasSuffix(pos.Filename, "_test.go") {
+			t.Errorf("%s: diagnostic in a test file: %s", pos, d.Message)
+			continue
+		}
+		res := want[pos.Line]
+		matched := false
+		for i, re := range res {
+			if re.MatchString(d.Message) {
+				want[pos.Line] = append(res[:i:i], res[i+1:]...)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			t.Errorf("%s: unexpected diagnostic: %s", pos, d.Message)
+		}
+	}
+	for line, res := range want {
+		for _, re := range res {
+			t.Errorf("line %d: no diagnostic matching %q", line, re)
+		}
+	}
+}
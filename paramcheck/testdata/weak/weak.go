@@ -0,0 +1,43 @@
+package weak
+
+import (
+	"github.com/pganguli/pbkdf2"
+	"github.com/pganguli/pbkdf2/mobile"
+)
+
+const legacyIterations = 1000
+
+var strong = &pbkdf2.Params{Iterations: 600000, SaltLength: 16, KeyLength: 32, Variant: pbkdf2.VariantSHA256}
+
+var weak = &pbkdf2.Params{
+	Iterations: legacyIterations, // want "weak pbkdf2.Params: iterations 1000 is below the recommended 210000"
+	SaltLength: 8,                // want "salt length 8 is below the recommended 16"
+	KeyLength:  64,
+}
+
+var weakSHA256 = pbkdf2.Params{Iterations: 210000, SaltLength: 16, KeyLength: 32, Variant: pbkdf2.VariantSHA256} // want "iterations 210000 is below the recommended 600000"
+
+var positional = pbkdf2.Params{10000, 16, 8, ""} // want "iterations 10000" "key length 8"
+
+var legacy = pbkdf2.Params{Iterations: 1300000, SaltLength: 16, KeyLength: 20, Variant: pbkdf2.VariantLegacySHA1} // want "variant pbkdf2 is deprecated"
+
+var defaults = pbkdf2.DefaultParams // want "pbkdf2.DefaultParams is meant for development and testing"
+
+var ignored = &pbkdf2.Params{Iterations: 1000, SaltLength: 16, KeyLength: 32} //pbkdf2vet:ignore required by the legacy protocol
+
+func assign(p *pbkdf2.Params, iterations uint32, variant string) {
+	p.Iterations = iterations
+	p.Iterations = 100000 // want "iterations 100000 is below the recommended 210000"
+	p.Iterations = 300000
+	p.KeyLength = 12 // want "key length 12"
+
+	//pbkdf2vet:ignore
+	p.SaltLength = 4
+
+	*p = pbkdf2.Params{Iterations: 300000, SaltLength: 16, KeyLength: 32, Variant: variant}
+}
+
+func create(password string, n int) {
+	mobile.CreateHash(password, 5000, 16, 64) // want "weak mobile.CreateHash: iterations 5000"
+	mobile.CreateHash(password, n, 16, 64)
+}
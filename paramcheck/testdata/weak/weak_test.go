@@ -0,0 +1,7 @@
+package weak
+
+import "github.com/pganguli/pbkdf2"
+
+var cheap = &pbkdf2.Params{Iterations: 1, SaltLength: 16, KeyLength: 16}
+
+var testDefaults = pbkdf2.DefaultParams
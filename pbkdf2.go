@@ -2,31 +2,69 @@
 // implementation, making it simpler to securely hash and verify passwords
 // using PBKDF2.
 //
-// It enforces use of the PBKDF2-HMAC-SHA512 algorithm variant and cryptographically-secure
-// random salts.
+// It uses the PBKDF2-HMAC-SHA512 algorithm variant unless another is explicitly
+// requested, and enforces cryptographically-secure random salts.
+//
+// # Build tags
+//
+// The backend used to derive keys, as reported by Backend, can be pinned at
+// build time so that packagers get the same performance characteristics on
+// every build:
+//
+//	purego   the Go implementation without assembly, which is also honoured
+//	         by the standard library and golang.org/x/crypto, and without
+//	         WebCrypto on js/wasm
+//	asm      the Go implementation, requiring an architecture on which the
+//	         hash functions have assembly implementations
+//	openssl  OpenSSL's PKCS5_PBKDF2_HMAC via cgo, linking against libcrypto
+//
+// At most one of them may be given. Impossible combinations, such as two of
+// them together, asm on an architecture without assembly, or openssl without
+// cgo, fail to compile, naming the problem in the error.
 package pbkdf2
 
 import (
-	"crypto/rand"
-	"crypto/sha512"
+	"crypto/hmac"
 	"crypto/subtle"
 	"encoding/base64"
 	"errors"
-	"fmt"
-	"strings"
+	"hash"
+	"math"
+	"runtime"
+	"strconv"
+	"time"
 
 	"golang.org/x/crypto/pbkdf2"
 )
 
 var (
 	// ErrInvalidHash in returned by ComparePasswordAndHash if the provided
-	// hash isn't in the expected format.
+	// hash isn't in the expected format. Decoding functions return it wrapped
+	// in a *DecodeError describing the cause, so it should be tested for with
+	// errors.Is.
 	ErrInvalidHash = errors.New("pbkdf2: hash is not in the correct format")
 
 	// ErrIncompatibleVariant is returned by ComparePasswordAndHash if the
-	// provided hash was created using a unsupported variant of PBKDF2.
-	// Currently only PBKDF2-HMAC-SHA512 is supported by this package.
+	// provided hash was created using a unsupported variant of PBKDF2, and by
+	// CreateHash if the params name an unsupported variant. The supported
+	// variants are those built in, such as VariantSHA512, and those added with
+	// RegisterVariant.
 	ErrIncompatibleVariant = errors.New("pbkdf2: incompatible variant of pbkdf2")
+
+	// ErrInvalidParams is returned by CreateHash and SetDefaultParams if any
+	// of the provided params is zero.
+	ErrInvalidParams = errors.New("pbkdf2: invalid params")
+
+	// ErrIterationsOverflow is the cause, in a *DecodeError, of the error
+	// returned when decoding a hash whose iteration count exceeds
+	// MaxIterations, including counts too large for any integer type.
+	ErrIterationsOverflow = errors.New("pbkdf2: iteration count out of range")
+
+	// ErrMismatchedHashAndPassword is returned by Verify if the password does
+	// not match the hash.
+	ErrMismatchedHashAndPassword = errors.New("pbkdf2: hash is not the hash of the given password")
+
+	errEmptySegment = errors.New("empty segment")
 )
 
 // DefaultParams provides some sane default parameters for hashing passwords.
@@ -36,6 +74,11 @@ var (
 // The default parameters should generally be used for development/testing purposes
 // only. Custom parameters should be set for production applications depending on
 // available memory/CPU resources and business requirements.
+//
+// DefaultParams is the initial value of the package-level default returned by
+// GetDefaultParams. Modifying it afterwards has no effect on that default and
+// is not safe while other goroutines may be reading it; use SetDefaultParams
+// instead.
 var DefaultParams = &Params{
 	Iterations: 210000,
 	SaltLength: 16,
@@ -60,33 +103,138 @@ type Params struct {
 
 	// Length of the generated key. 16 bytes or more is recommended.
 	KeyLength uint32
+
+	// The variant of PBKDF2 to use, such as VariantSHA3_512. The empty string
+	// means VariantSHA512, and is what params decoded from a SHA-512 hash
+	// contain.
+	Variant string
+}
+
+// MaxIterations is the largest iteration count a hash can record, so that
+// hashes from other systems with unusual counts can be decoded and verified.
+// Counts near it take hours to compute, so policies should set a far lower
+// limit for hashes from untrusted sources.
+const MaxIterations = math.MaxUint32
+
+// Validate returns ErrInvalidParams if p is nil or any of the params is zero,
+// or ErrIncompatibleVariant if the variant is not supported.
+func (p *Params) Validate() error {
+	if p == nil || p.Iterations == 0 || p.SaltLength == 0 || p.KeyLength == 0 {
+		return ErrInvalidParams
+	}
+	if _, ok := variantPRF(p.Variant); !ok {
+		return ErrIncompatibleVariant
+	}
+	return nil
 }
 
-// CreateHash returns a PBKDF2-HMAC-SHA512 hash of a plain-text password using the
-// provided algorithm parameters. The returned hash follows the format:
+// CreateHash returns a PBKDF2 hash of a plain-text password using the provided
+// algorithm parameters, with HMAC-SHA512 as the PRF unless params.Variant says
+// otherwise. The returned hash follows the format:
 //
-//	$pbkdf2-sha512${Iterations}${b64Salt}${b64Key}
+//	${Variant}${Iterations}${b64Salt}${b64Key}
 //
 // It looks like this:
 //
 //	$pbkdf2-sha512$210000$yvu2ZftdlhcP4Tbpe2TYqA$XJsU2xkzTyRZur3/+VW07FljLcgKGfmNw+en6y3WJ0JWHHEkn4e46VcaddErsqc9jkJC5IVl4XSlh4lgv0dlug
+//
+// If params is nil, the package-level default returned by GetDefaultParams is
+// used.
 func CreateHash(password string, params *Params) (hash string, err error) {
+	secret := SecureBytesFromString(password)
+	defer secret.Destroy()
+
+	return CreateHashSecure(secret, params)
+}
+
+// CreateHashSecure is like CreateHash, except the password is provided as a
+// SecureBytes. The password is not destroyed; that remains the responsibility
+// of the caller.
+func CreateHashSecure(password *SecureBytes, params *Params) (hash string, err error) {
+	return createHash(password, params, transforms{})
+}
+
+// createHash creates a hash with the given transforms applied before the key
+// is derived, and recorded in its metadata.
+func createHash(password *SecureBytes, params *Params, t transforms) (string, error) {
+	if params == nil {
+		params = GetDefaultParams()
+	}
+	if err := params.Validate(); err != nil {
+		return "", err
+	}
+	if t.namespace != "" && !ValidNamespace(t.namespace) {
+		return "", ErrInvalidNamespace
+	}
+	password, err := normalizeSecure(t.normalization, password)
+	if err != nil {
+		return "", err
+	}
+	if t.normalization != "" {
+		defer password.Destroy()
+	}
+
 	salt, err := generateRandomBytes(params.SaltLength)
 	if err != nil {
 		return "", err
 	}
+	defer salt.Destroy()
 
-	key := pbkdf2.Key([]byte(password), salt, int(params.Iterations), int(params.KeyLength), sha512.New)
+	key := deriveKey(password, derivationSalt(salt, t.namespace), params)
+	defer key.Destroy()
 
-	b64Salt := base64.RawStdEncoding.EncodeToString(salt)
-	b64Key := base64.RawStdEncoding.EncodeToString(key)
+	return encodeHash(params.Variant, params.Iterations, salt.Bytes(), key.Bytes(), t.metadata()), nil
+}
 
-	hash = fmt.Sprintf("$pbkdf2-sha512$%d$%s$%s", params.Iterations, b64Salt, b64Key)
-	return hash, nil
+// derivationSalt returns the salt to run PBKDF2 over for a hash with the
+// given stored salt in namespace.
+func derivationSalt(salt *SecureBytes, namespace string) *SecureBytes {
+	if namespace == "" {
+		return salt
+	}
+	return NewSecureBytes(NamespaceSalt(namespace, salt.Bytes()))
+}
+
+// encodeHash formats a hash by hand rather than with fmt, which pulls in
+// reflection and noticeably bloats TinyGo and WebAssembly builds.
+func encodeHash(variant string, iterations uint32, salt, key []byte, metadata map[string]string) string {
+	if variant == "" {
+		variant = VariantSHA512
+	}
+	enc := base64.RawStdEncoding
+
+	b := make([]byte, 0, 1+len(variant)+1+10+1+enc.EncodedLen(len(salt))+1+enc.EncodedLen(len(key)))
+	b = append(b, '$')
+	b = append(b, variant...)
+	b = append(b, '$')
+	if len(metadata) > 0 {
+		b = appendMetadata(b, metadata)
+		b = append(b, '$')
+	}
+	b = strconv.AppendUint(b, uint64(iterations), 10)
+	b = append(b, '$')
+	b = appendBase64(b, salt)
+	b = append(b, '$')
+	b = appendBase64(b, key)
+
+	return string(b)
+}
+
+func appendBase64(dst, src []byte) []byte {
+	n := len(dst)
+	size := base64.RawStdEncoding.EncodedLen(len(src))
+	if cap(dst)-n < size {
+		grown := make([]byte, n, n+size)
+		copy(grown, dst)
+		dst = grown
+	}
+	dst = dst[:n+size]
+	base64.RawStdEncoding.Encode(dst[n:], src)
+	return dst
 }
 
 // ComparePasswordAndHash performs a constant-time comparison between a
-// plain-text password and PBKDF2-HMAC-SHA512 hash, using the parameters and salt
+// plain-text password and PBKDF2 hash, using the variant, parameters and salt
 // contained in the hash. It returns true if they match, otherwise it returns
 // false.
 func ComparePasswordAndHash(password, hash string) (match bool, err error) {
@@ -94,68 +242,289 @@ func ComparePasswordAndHash(password, hash string) (match bool, err error) {
 	return match, err
 }
 
+// ComparePasswordAndHashSecure is like ComparePasswordAndHash, except the
+// password is provided as a SecureBytes.
+func ComparePasswordAndHashSecure(password *SecureBytes, hash string) (match bool, err error) {
+	match, _, err = CheckHashSecure(password, hash)
+	return match, err
+}
+
 // CheckHash is like ComparePasswordAndHash, except it also returns the params that the hash was
 // created with. This can be useful if you want to update your hash params over time (which you
 // should).
 func CheckHash(password, hash string) (match bool, params *Params, err error) {
-	params, salt, key, err := DecodeHash(hash)
+	secret := SecureBytesFromString(password)
+	defer secret.Destroy()
+
+	return CheckHashSecure(secret, hash)
+}
+
+// CheckHashSecure is like CheckHash, except the password is provided as a
+// SecureBytes.
+func CheckHashSecure(password *SecureBytes, hash string) (match bool, params *Params, err error) {
+	result, err := checkHash(password, hash)
 	if err != nil {
 		return false, nil, err
 	}
+	return result.Match, result.Params, nil
+}
 
-	otherKey := pbkdf2.Key([]byte(password), salt, int(params.Iterations), int(params.KeyLength), sha512.New)
+func checkHash(password *SecureBytes, hash string) (*CheckResult, error) {
+	h, err := decodeHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return checkDecoded(password, h)
+}
 
-	keyLen := int32(len(key))
-	otherKeyLen := int32(len(otherKey))
+// checkDecoded is checkHash for a decoded hash, whose salt and key it wipes.
+func checkDecoded(password *SecureBytes, h *Hash) (*CheckResult, error) {
+	params, salt, key := &h.Params, NewSecureBytes(h.Salt), NewSecureBytes(h.Key)
+	defer salt.Destroy()
+	defer key.Destroy()
 
-	if subtle.ConstantTimeEq(keyLen, otherKeyLen) == 0 {
-		return false, params, nil
+	// Every transform is taken from the hash itself, so that hashes remain
+	// verifiable whatever is configured for new ones.
+	t := transformsOf(h.Metadata)
+	if t.namespace != "" {
+		salt = derivationSalt(salt, t.namespace)
+		defer salt.Destroy()
+	}
+	stages, err := parseStages(t.stages)
+	if err != nil {
+		return nil, err
+	}
+	password, err = normalizeSecure(t.normalization, password)
+	if err != nil {
+		return nil, err
 	}
-	if subtle.ConstantTimeCompare(key, otherKey) == 1 {
-		return true, params, nil
+	if t.normalization != "" {
+		defer password.Destroy()
 	}
-	return false, params, nil
+
+	var otherKey *SecureBytes
+	elapsed, cpu := measure(func() {
+		otherKey = applyStages(deriveKey(password, salt, params), h.Salt, params, stages)
+	})
+	defer otherKey.Destroy()
+
+	return &CheckResult{Match: keysEqual(key, otherKey), Params: params, Duration: elapsed, CPUTime: cpu}, nil
 }
 
-func generateRandomBytes(n uint32) ([]byte, error) {
-	b := make([]byte, n)
-	_, err := rand.Read(b)
+// keysEqual compares two derived keys in constant time.
+func keysEqual(key, otherKey *SecureBytes) bool {
+	keyLen := int32(key.Len())
+	otherKeyLen := int32(otherKey.Len())
+
+	if subtle.ConstantTimeEq(keyLen, otherKeyLen) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key.Bytes(), otherKey.Bytes()) == 1
+}
+
+// CheckResult is the outcome of verifying a password with CheckHashTimed.
+type CheckResult struct {
+	// Match is true if the password matches the hash.
+	Match bool
+
+	// Params are the params the hash was created with.
+	Params *Params
+
+	// Duration is the time spent deriving the key, excluding decoding the
+	// hash and comparing the keys. It is suitable for monitoring
+	// verification latency against a service level objective, and for
+	// spotting hashes whose cost has drifted from the target.
+	Duration time.Duration
+
+	// CPUTime is the CPU time consumed deriving the key, for metering. It is
+	// zero where it cannot be measured; see BackendInfo.CPUTime.
+	CPUTime time.Duration
+}
+
+// CheckHashTimed is like CheckHash, except it also reports how long the key
+// derivation took.
+func CheckHashTimed(password, hash string) (*CheckResult, error) {
+	secret := SecureBytesFromString(password)
+	defer secret.Destroy()
+
+	return CheckHashTimedSecure(secret, hash)
+}
+
+// CheckHashTimedSecure is like CheckHashTimed, except the password is provided
+// as a SecureBytes.
+func CheckHashTimedSecure(password *SecureBytes, hash string) (*CheckResult, error) {
+	return checkHash(password, hash)
+}
+
+// Verify is like ComparePasswordAndHash, except it reports the result in the
+// style of golang.org/x/crypto/bcrypt: it returns nil if the password matches
+// the hash, ErrMismatchedHashAndPassword if it does not, and any other error if
+// the hash could not be decoded.
+func Verify(password, hash string) error {
+	secret := SecureBytesFromString(password)
+	defer secret.Destroy()
+
+	return VerifySecure(secret, hash)
+}
+
+// VerifySecure is like Verify, except the password is provided as a
+// SecureBytes.
+func VerifySecure(password *SecureBytes, hash string) error {
+	match, err := ComparePasswordAndHashSecure(password, hash)
 	if err != nil {
+		return err
+	}
+	if !match {
+		return ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+// deriveKey runs PBKDF2 over password and salt using params, which must have
+// been validated. The returned key must be destroyed by the caller.
+func deriveKey(password, salt *SecureBytes, params *Params) *SecureBytes {
+	key := pbkdf2Key(password.Bytes(), salt.Bytes(), params.Iterations, int(params.KeyLength), params.Variant)
+	runtime.KeepAlive(password)
+	runtime.KeepAlive(salt)
+	return NewSecureBytes(key)
+}
+
+// DeriveKey runs PBKDF2 over password and salt using params, and returns the
+// raw derived key, which the caller should destroy once it is no longer
+// needed. It returns ErrInvalidParams or ErrIncompatibleVariant if params are
+// not valid; params.SaltLength is only checked to be non-zero, and the given
+// salt is used whatever its length.
+//
+// DeriveKey is intended for building other schemes on top of this package,
+// such as peppering. To hash passwords, use CreateHash.
+func DeriveKey(password *SecureBytes, salt []byte, params *Params) (*SecureBytes, error) {
+	if err := params.Validate(); err != nil {
 		return nil, err
 	}
+	return deriveKey(password, NewSecureBytes(append([]byte(nil), salt...)), params), nil
+}
 
-	return b, nil
+// goKey is the pure Go PBKDF2 implementation used on all platforms, and as the
+// fallback where a platform-specific implementation is unavailable.
+func goKey(password, salt []byte, iterations uint32, keyLength int, variant string) []byte {
+	prf, _ := variantPRF(variant)
+	return prfKey(password, salt, iterations, keyLength, prf)
 }
 
-// DecodeHash expects a hash created from this package, and parses it to return the params used to
-// create it, as well as the salt and key (password hash).
-func DecodeHash(hash string) (params *Params, salt, key []byte, err error) {
-	vals := strings.Split(hash, "$")
-	if len(vals) != 5 {
-		return nil, nil, nil, ErrInvalidHash
+// prfKey is golang.org/x/crypto/pbkdf2.Key, taking any uint32 iterations.
+func prfKey(password, salt []byte, iterations uint32, keyLength int, prf func() hash.Hash) []byte {
+	if strconv.IntSize == 32 && iterations > math.MaxInt32 {
+		return longKey(password, salt, iterations, keyLength, prf)
 	}
+	return pbkdf2.Key(password, salt, int(iterations), keyLength, prf)
+}
 
-	if vals[1] != "pbkdf2-sha512" {
-		return nil, nil, nil, ErrIncompatibleVariant
+// longKey is PBKDF2 as in RFC 8018, section 5.2, for iteration counts that
+// do not fit in the int of golang.org/x/crypto/pbkdf2 on 32-bit platforms.
+func longKey(password, salt []byte, iterations uint32, keyLength int, prf func() hash.Hash) []byte {
+	mac := hmac.New(prf, password)
+	size := mac.Size()
+	blocks := (keyLength + size - 1) / size
+	key := make([]byte, 0, blocks*size)
+	u := make([]byte, 0, size)
+	for block := 1; block <= blocks; block++ {
+		mac.Reset()
+		mac.Write(salt)
+		mac.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u = mac.Sum(u[:0])
+		key = append(key, u...)
+		t := key[len(key)-size:]
+		for n := uint32(1); n < iterations; n++ {
+			mac.Reset()
+			mac.Write(u)
+			u = mac.Sum(u[:0])
+			for i := range t {
+				t[i] ^= u[i]
+			}
+		}
 	}
+	wipe(u)
+	return key[:keyLength]
+}
 
-	params = &Params{}
-	_, err = fmt.Sscanf(vals[2], "%d", &params.Iterations)
-	if err != nil {
-		return nil, nil, nil, err
+func generateRandomBytes(n uint32) (*SecureBytes, error) {
+	b := make([]byte, n)
+	if err := readEntropy(b); err != nil {
+		return nil, err
 	}
 
-	salt, err = base64.RawStdEncoding.Strict().DecodeString(vals[3])
+	return NewSecureBytes(b), nil
+}
+
+// DecodeHash expects a hash created from this package, and parses it to return the params used to
+// create it, as well as the salt and key (password hash). Any metadata recorded in the hash is
+// ignored; use ParseHash to retrieve it.
+func DecodeHash(hash string) (params *Params, salt, key []byte, err error) {
+	params, secureSalt, secureKey, err := DecodeHashSecure(hash)
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	params.SaltLength = uint32(len(salt))
 
-	key, err = base64.RawStdEncoding.Strict().DecodeString(vals[4])
+	// The caller takes ownership of the returned slices, so copy them out
+	// before the SecureBytes are wiped.
+	salt = append([]byte(nil), secureSalt.Bytes()...)
+	key = append([]byte(nil), secureKey.Bytes()...)
+	secureSalt.Destroy()
+	secureKey.Destroy()
+
+	return params, salt, key, nil
+}
+
+// DecodeHashSecure is like DecodeHash, except the salt and key are returned as
+// SecureBytes, which the caller should destroy once they are no longer needed.
+func DecodeHashSecure(hash string) (params *Params, salt, key *SecureBytes, err error) {
+	h, err := decodeHash(hash)
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	params.KeyLength = uint32(len(key))
 
-	return params, salt, key, nil
+	return &h.Params, NewSecureBytes(h.Salt), NewSecureBytes(h.Key), nil
+}
+
+// parseIterations parses the iterations segment of a hash. Only the canonical
+// decimal form produced by CreateHash is accepted: no sign, no whitespace, no
+// leading zeros, and a value that is non-zero. The value is parsed as 64 bits
+// wide, so that any count beyond MaxIterations, however large, is reported as
+// ErrIterationsOverflow rather than misread. Bounding the work of a hash is
+// left to Policy.MaxIterations.
+func parseIterations(s string) (uint32, error) {
+	if s == "" || s[0] == '0' {
+		return 0, decodeError("iterations", nil)
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, decodeError("iterations", nil)
+		}
+	}
+
+	// Only overflow can fail, since s holds nothing but digits.
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || n > MaxIterations {
+		return 0, decodeError("iterations", ErrIterationsOverflow)
+	}
+	return uint32(n), nil
+}
+
+// decodeBase64 decodes a salt or key segment. The standard library decoder
+// silently skips carriage returns and newlines, so the segment is first checked
+// to contain only characters from the unpadded standard alphabet. An empty
+// segment can only come from a truncated or hand-edited hash and is rejected
+// with errEmptySegment.
+func decodeBase64(s string) ([]byte, error) {
+	if s == "" {
+		return nil, errEmptySegment
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '+' || c == '/') {
+			return nil, base64.CorruptInputError(i)
+		}
+	}
+
+	return base64.RawStdEncoding.Strict().DecodeString(s)
 }
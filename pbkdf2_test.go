@@ -1,6 +1,10 @@
 package pbkdf2
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"go/build"
 	"regexp"
 	"strings"
 	"testing"
@@ -112,8 +116,151 @@ func TestStrictDecoding(t *testing.T) {
 
 func TestVariant(t *testing.T) {
 	// Hash contains wrong variant
-	_, _, err := CheckHash("pa$$word", "$pbkdf2-sha256$210000$UDk0zEuIzbt0x3bwkf8Bgw$ihSfHWUJpTgDvNWiojrgcN4E0pJdUVmqCEdRZesx9tE")
+	_, _, err := CheckHash("pa$$word", "$pbkdf2-md5$210000$UDk0zEuIzbt0x3bwkf8Bgw$ihSfHWUJpTgDvNWiojrgcN4E0pJdUVmqCEdRZesx9tE")
 	if err != ErrIncompatibleVariant {
 		t.Fatalf("Expected error:\n%s\nGot:\n%s", ErrIncompatibleVariant, err)
 	}
 }
+
+func TestDecodeHashMalformed(t *testing.T) {
+	hashes := []string{
+		"0$pbkdf2-sha512$1$AA$AA",
+		"$pbkdf2-sha512$0$AA$AA",
+		"$pbkdf2-sha512$01$AA$AA",
+		"$pbkdf2-sha512$+1$AA$AA",
+		"$pbkdf2-sha512$4294967296$AA$AA",
+		"$pbkdf2-sha512$1$$AA",
+		"$pbkdf2-sha512$1$AA$",
+		"$pbkdf2-sha512$1$AA$\r",
+		"$pbkdf2-sha512$1$A\nA$AA",
+		"$pbkdf2-sha512$1$A-_A$AA",
+	}
+
+	for _, hash := range hashes {
+		if _, _, _, err := DecodeHash(hash); err == nil {
+			t.Errorf("expected %q to be rejected", hash)
+		}
+	}
+}
+
+func TestNoFmtImport(t *testing.T) {
+	// fmt pulls in reflection-heavy code that bloats TinyGo and WebAssembly
+	// builds, so the core package formats and parses hashes by hand. Packages
+	// that import fmt themselves, such as encoding/hex, are caught too.
+	pkg, err := build.ImportDir(".", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, imp := range pkg.Imports {
+		if imp == "fmt" {
+			t.Fatal("package pbkdf2 must not import fmt")
+		}
+		dep, err := build.Import(imp, ".", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, depImp := range dep.Imports {
+			if depImp == "fmt" {
+				t.Errorf("package pbkdf2 must not import %s, which imports fmt", imp)
+			}
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	hash, err := CreateHash("pa$$word", &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Verify("pa$$word", hash); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if err := Verify("otherPa$$word", hash); err != ErrMismatchedHashAndPassword {
+		t.Fatalf("expected ErrMismatchedHashAndPassword, got %v", err)
+	}
+	if err := Verify("pa$$word", "$pbkdf2-sha512$1000"); !errors.Is(err, ErrInvalidHash) {
+		t.Fatalf("expected ErrInvalidHash, got %v", err)
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	password := SecureBytesFromString("pa$$word")
+	defer password.Destroy()
+
+	hash, err := CreateHash("pa$$word", &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, salt, key, err := DecodeHash(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	derived, err := DeriveKey(password, salt, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer derived.Destroy()
+	if string(derived.Bytes()) != string(key) {
+		t.Fatal("expected DeriveKey to reproduce the key in the hash")
+	}
+
+	if _, err := DeriveKey(password, salt, &Params{Iterations: 1000}); err != ErrInvalidParams {
+		t.Fatalf("expected ErrInvalidParams, got %v", err)
+	}
+	if _, err := DeriveKey(password, salt, nil); err != ErrInvalidParams {
+		t.Fatalf("expected ErrInvalidParams for nil params, got %v", err)
+	}
+}
+
+func TestLongKey(t *testing.T) {
+	// longKey only runs for huge counts on 32-bit platforms, so check it
+	// against golang.org/x/crypto/pbkdf2 with small ones.
+	password, salt := []byte("pa$$word"), []byte("salt")
+	for _, iterations := range []uint32{1, 2, 1000} {
+		for _, keyLength := range []int{16, 32, 70} {
+			want := goKey(password, salt, iterations, keyLength, VariantSHA256)
+			if got := longKey(password, salt, iterations, keyLength, sha256.New); !bytes.Equal(got, want) {
+				t.Errorf("longKey(%d, %d) = %x, want %x", iterations, keyLength, got, want)
+			}
+		}
+	}
+}
+
+func TestCheckHashTimed(t *testing.T) {
+	params := &Params{Iterations: 20000, SaltLength: 16, KeyLength: 32}
+	hash, err := CreateHash("pa$$word", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := CheckHashTimed("pa$$word", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Match || *result.Params != *params || result.Duration <= 0 {
+		t.Fatalf("unexpected result %#v", *result)
+	}
+
+	result, err = CheckHashTimed("otherPa$$word", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Match || result.Duration <= 0 {
+		t.Fatalf("unexpected result %#v", *result)
+	}
+
+	if _, err := CheckHashTimed("pa$$word", "$pbkdf2-sha512$1000$AA"); !errors.Is(err, ErrInvalidHash) {
+		t.Fatalf("expected ErrInvalidHash, got %v", err)
+	}
+
+	legacy := &Hasher{AllowLegacySHA1: true}
+	result, err = legacy.CheckHashTimed("pa$$word", legacySHA1Hashes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Match || result.Params.Variant != VariantLegacySHA1 {
+		t.Fatalf("unexpected result %#v", *result)
+	}
+}
@@ -0,0 +1,54 @@
+package pepper
+
+import (
+	"context"
+
+	"github.com/pganguli/pbkdf2/store"
+)
+
+// RePepperResult counts the outcome of RePepperStore.
+type RePepperResult struct {
+	// RePeppered is the number of hashes moved to the new key.
+	RePeppered int
+
+	// Current is the number of hashes already using the new key.
+	Current int
+
+	// Irreversible is the number of ModeHMAC hashes, which were left
+	// unchanged and must be re-peppered as their users log in.
+	Irreversible int
+}
+
+// RePepperStore applies RePepper to every hash in s, storing the results. It
+// stops at the first hash that cannot be re-peppered for any reason other than
+// being a ModeHMAC hash, such as not being peppered or using a key missing
+// from old, and returns the counts so far along with the error. Running it
+// again after fixing the cause resumes where it left off, as hashes already
+// using newKey are skipped.
+func RePepperStore(ctx context.Context, s store.Store, old *Keyring, newKey Key) (RePepperResult, error) {
+	var result RePepperResult
+	if err := checkKey(newKey); err != nil {
+		return result, err
+	}
+
+	err := s.Range(ctx, func(username, hash string) error {
+		newHash, err := RePepper(hash, old, newKey)
+		switch {
+		case err == ErrNotReversible:
+			result.Irreversible++
+			return nil
+		case err != nil:
+			return &store.RecordError{Username: username, Err: err}
+		case newHash == hash:
+			result.Current++
+			return nil
+		}
+
+		if err := s.Put(ctx, username, newHash); err != nil {
+			return err
+		}
+		result.RePeppered++
+		return nil
+	})
+	return result, err
+}
@@ -0,0 +1,52 @@
+package pepper
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pganguli/pbkdf2/store"
+)
+
+func TestRePepperStore(t *testing.T) {
+	ctx := context.Background()
+	s := &store.MemoryStore{}
+	for user, h := range map[string]*Hasher{
+		"alice": newHasher(t, ModeAESGCM, "k1"),
+		"bob":   newHasher(t, ModeAESGCM, "k2"),
+		"carol": newHasher(t, ModeHMAC, "k1"),
+	} {
+		hash, err := h.CreateHash("pa$$word")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Put(ctx, user, hash)
+	}
+
+	keyring, _ := NewKeyring("k1", key1, key2)
+	result, err := RePepperStore(ctx, s, keyring, key2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (RePepperResult{RePeppered: 1, Current: 1, Irreversible: 1}); result != want {
+		t.Fatalf("expected %#v got %#v", want, result)
+	}
+
+	h := newHasher(t, 0, "k2")
+	for _, user := range []string{"alice", "bob", "carol"} {
+		hash, _ := s.Get(ctx, user)
+		if err := h.Verify("pa$$word", hash); err != nil {
+			t.Fatalf("%s: %v", user, err)
+		}
+	}
+	if hash, _ := s.Get(ctx, "alice"); h.NeedsRePepper(hash) {
+		t.Error("expected alice to have been moved to k2")
+	}
+
+	s.Put(ctx, "dave", "$pbkdf2-sha512$1000$AAAA$AAAA")
+	_, err = RePepperStore(ctx, s, keyring, key2)
+	var recErr *store.RecordError
+	if !errors.As(err, &recErr) || recErr.Username != "dave" || !errors.Is(err, ErrNotPeppered) {
+		t.Fatalf("expected ErrNotPeppered for dave, got %v", err)
+	}
+}
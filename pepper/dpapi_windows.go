@@ -0,0 +1,79 @@
+package pepper
+
+import (
+	"errors"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// A DPAPIScope selects who can unseal a key sealed by SealDPAPI.
+type DPAPIScope int
+
+const (
+	// DPAPICurrentUser seals keys to the account running the process, such
+	// as the virtual account of a Windows service, NT SERVICE\<name>.
+	DPAPICurrentUser DPAPIScope = iota
+
+	// DPAPILocalMachine seals keys to the machine: any process on it can
+	// unseal them, but a copy of the file on another machine cannot.
+	DPAPILocalMachine
+)
+
+// dpapiEntropy is passed to DPAPI as additional entropy, so that keys sealed
+// by this package are not unsealed by programs unaware of it.
+var dpapiEntropy = []byte("github.com/pganguli/pbkdf2/pepper")
+
+// SealDPAPI encrypts a pepper key with the Windows Data Protection API,
+// CryptProtectData, so that it can be stored on disk and unsealed only by the
+// same account, or on the same machine, according to scope. Sealed keys are
+// read with a SecretDir whose Decode is UnsealDPAPI:
+//
+//	keys, err := pepper.NewRefreshingKeyring(ctx, &pepper.SecretDir{
+//		Dir:    `C:\ProgramData\MyApp\pepper`,
+//		Decode: pepper.UnsealDPAPI,
+//	})
+//	h := &pepper.Hasher{Source: keys}
+//
+// with the active key ID in a plain file named "active".
+func SealDPAPI(secret []byte, scope DPAPIScope) ([]byte, error) {
+	if len(secret) != KeySize {
+		return nil, errors.New("pepper: key to seal is not KeySize bytes")
+	}
+	flags := uint32(windows.CRYPTPROTECT_UI_FORBIDDEN)
+	if scope == DPAPILocalMachine {
+		flags |= windows.CRYPTPROTECT_LOCAL_MACHINE
+	}
+	var out windows.DataBlob
+	if err := windows.CryptProtectData(blob(secret), nil, blob(dpapiEntropy), 0, nil, flags, &out); err != nil {
+		return nil, err
+	}
+	return takeBlob(&out), nil
+}
+
+// UnsealDPAPI decrypts a key sealed by SealDPAPI, failing if it was sealed for
+// another account or machine.
+func UnsealDPAPI(sealed []byte) ([]byte, error) {
+	if len(sealed) == 0 {
+		return nil, errors.New("pepper: empty DPAPI blob")
+	}
+	var out windows.DataBlob
+	if err := windows.CryptUnprotectData(blob(sealed), nil, blob(dpapiEntropy), 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, err
+	}
+	return takeBlob(&out), nil
+}
+
+func blob(b []byte) *windows.DataBlob {
+	return &windows.DataBlob{Size: uint32(len(b)), Data: &b[0]}
+}
+
+// takeBlob copies out the data of a blob allocated by DPAPI, then wipes and
+// frees it.
+func takeBlob(b *windows.DataBlob) []byte {
+	data := unsafe.Slice(b.Data, b.Size)
+	out := append([]byte(nil), data...)
+	wipe(data)
+	windows.LocalFree(windows.Handle(unsafe.Pointer(b.Data)))
+	return out
+}
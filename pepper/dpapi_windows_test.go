@@ -0,0 +1,52 @@
+package pepper
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDPAPI(t *testing.T) {
+	for _, scope := range []DPAPIScope{DPAPICurrentUser, DPAPILocalMachine} {
+		sealed, err := SealDPAPI(key1.Secret, scope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(sealed, key1.Secret) {
+			t.Error("sealed key contains the key")
+		}
+		secret, err := UnsealDPAPI(sealed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(secret, key1.Secret) {
+			t.Errorf("UnsealDPAPI = %x, want %x", secret, key1.Secret)
+		}
+	}
+
+	sealed, _ := SealDPAPI(key1.Secret, DPAPICurrentUser)
+	sealed[len(sealed)-1] ^= 1
+	if _, err := UnsealDPAPI(sealed); err == nil {
+		t.Error("UnsealDPAPI accepted a corrupted blob")
+	}
+}
+
+func TestDPAPISecretDir(t *testing.T) {
+	dir := t.TempDir()
+	sealed, err := SealDPAPI(key1.Secret, DPAPICurrentUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "k1"), sealed, 0o600)
+	os.WriteFile(filepath.Join(dir, "active"), []byte("k1"), 0o600)
+
+	active, keys, err := (&SecretDir{Dir: dir, Decode: UnsealDPAPI}).Keys(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active != "k1" || len(keys) != 1 || !bytes.Equal(keys[0].Secret, key1.Secret) {
+		t.Errorf("Keys = %q, %v", active, keys)
+	}
+}
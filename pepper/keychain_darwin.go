@@ -0,0 +1,306 @@
+//go:build darwin && cgo
+
+package pepper
+
+/*
+#cgo LDFLAGS: -framework CoreFoundation -framework Security
+#include <stdlib.h>
+#include <string.h>
+#include <CoreFoundation/CoreFoundation.h>
+#include <Security/Security.h>
+
+static CFMutableDictionaryRef pepper_query(const char *service, const char *account, int dataProtection) {
+	CFMutableDictionaryRef q = CFDictionaryCreateMutable(NULL, 0,
+		&kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+	CFDictionarySetValue(q, kSecClass, kSecClassGenericPassword);
+	CFStringRef s = CFStringCreateWithCString(NULL, service, kCFStringEncodingUTF8);
+	CFDictionarySetValue(q, kSecAttrService, s);
+	CFRelease(s);
+	if (account != NULL) {
+		CFStringRef a = CFStringCreateWithCString(NULL, account, kCFStringEncodingUTF8);
+		CFDictionarySetValue(q, kSecAttrAccount, a);
+		CFRelease(a);
+	}
+	if (dataProtection) {
+		CFDictionarySetValue(q, kSecUseDataProtectionKeychain, kCFBooleanTrue);
+	}
+	return q;
+}
+
+// pepper_get copies the data of an item into a buffer allocated with malloc.
+static OSStatus pepper_get(const char *service, const char *account, int dataProtection, void **out, size_t *outlen) {
+	CFMutableDictionaryRef q = pepper_query(service, account, dataProtection);
+	CFDictionarySetValue(q, kSecReturnData, kCFBooleanTrue);
+	CFDictionarySetValue(q, kSecMatchLimit, kSecMatchLimitOne);
+	CFTypeRef result = NULL;
+	OSStatus status = SecItemCopyMatching(q, &result);
+	CFRelease(q);
+	if (status != errSecSuccess) {
+		return status;
+	}
+	CFDataRef data = (CFDataRef)result;
+	*outlen = (size_t)CFDataGetLength(data);
+	*out = malloc(*outlen > 0 ? *outlen : 1);
+	memcpy(*out, CFDataGetBytePtr(data), *outlen);
+	CFRelease(result);
+	return errSecSuccess;
+}
+
+// pepper_accounts lists the accounts of the items of a service, separated by
+// NULs, in a buffer allocated with malloc.
+static OSStatus pepper_accounts(const char *service, int dataProtection, char **out, size_t *outlen) {
+	CFMutableDictionaryRef q = pepper_query(service, NULL, dataProtection);
+	CFDictionarySetValue(q, kSecReturnAttributes, kCFBooleanTrue);
+	CFDictionarySetValue(q, kSecMatchLimit, kSecMatchLimitAll);
+	CFTypeRef result = NULL;
+	OSStatus status = SecItemCopyMatching(q, &result);
+	CFRelease(q);
+	*out = NULL;
+	*outlen = 0;
+	if (status == errSecItemNotFound) {
+		return errSecSuccess;
+	}
+	if (status != errSecSuccess) {
+		return status;
+	}
+	CFArrayRef items = (CFArrayRef)result;
+	CFIndex n = CFArrayGetCount(items);
+	size_t size = 1;
+	for (CFIndex i = 0; i < n; i++) {
+		CFStringRef account = CFDictionaryGetValue(CFArrayGetValueAtIndex(items, i), kSecAttrAccount);
+		if (account != NULL) {
+			size += CFStringGetMaximumSizeForEncoding(CFStringGetLength(account), kCFStringEncodingUTF8) + 1;
+		}
+	}
+	*out = malloc(size);
+	for (CFIndex i = 0; i < n; i++) {
+		CFStringRef account = CFDictionaryGetValue(CFArrayGetValueAtIndex(items, i), kSecAttrAccount);
+		if (account != NULL && CFStringGetCString(account, *out + *outlen, size - *outlen, kCFStringEncodingUTF8)) {
+			*outlen += strlen(*out + *outlen) + 1;
+		}
+	}
+	CFRelease(result);
+	return errSecSuccess;
+}
+
+// pepper_set updates the data of an item, adding the item if there is none.
+static OSStatus pepper_set(const char *service, const char *account, int dataProtection, const void *data, size_t len) {
+	CFMutableDictionaryRef q = pepper_query(service, account, dataProtection);
+	CFDataRef value = CFDataCreate(NULL, data, (CFIndex)len);
+	CFMutableDictionaryRef update = CFDictionaryCreateMutable(NULL, 0,
+		&kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+	CFDictionarySetValue(update, kSecValueData, value);
+	OSStatus status = SecItemUpdate(q, update);
+	if (status == errSecItemNotFound) {
+		CFDictionarySetValue(q, kSecValueData, value);
+		status = SecItemAdd(q, NULL);
+	}
+	CFRelease(update);
+	CFRelease(value);
+	CFRelease(q);
+	return status;
+}
+
+static OSStatus pepper_delete(const char *service, const char *account, int dataProtection) {
+	CFMutableDictionaryRef q = pepper_query(service, account, dataProtection);
+	OSStatus status = SecItemDelete(q);
+	CFRelease(q);
+	return status;
+}
+
+// pepper_error_message copies the description of status into buf.
+static void pepper_error_message(OSStatus status, char *buf, size_t len) {
+	buf[0] = 0;
+	CFStringRef msg = SecCopyErrorMessageString(status, NULL);
+	if (msg != NULL) {
+		CFStringGetCString(msg, buf, (CFIndex)len, kCFStringEncodingUTF8);
+		CFRelease(msg);
+	}
+}
+*/
+import "C"
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"unsafe"
+)
+
+// DefaultKeychainService is the service of the Keychain items holding pepper
+// keys, if Keychain.Service is empty.
+const DefaultKeychainService = "github.com/pganguli/pbkdf2/pepper"
+
+// KeychainActiveAccount is the account of the Keychain item holding the
+// active key ID.
+const KeychainActiveAccount = "active"
+
+// KeychainItemNotFound is the KeychainError of a missing item,
+// errSecItemNotFound.
+const KeychainItemNotFound KeychainError = -25300
+
+// A KeychainError is an error status of the Security framework.
+type KeychainError int32
+
+func (e KeychainError) Error() string {
+	buf := make([]byte, 256)
+	C.pepper_error_message(C.OSStatus(e), (*C.char)(unsafe.Pointer(&buf[0])), C.size_t(len(buf)))
+	msg := "pepper: keychain: OSStatus " + strconv.Itoa(int(e))
+	if n := bytes.IndexByte(buf, 0); n > 0 {
+		msg += ": " + string(buf[:n])
+	}
+	return msg
+}
+
+// Keychain is a SecretProvider reading pepper keys from the macOS Keychain, as
+// generic passwords of one service, one per key with the key ID as account.
+// The item with account KeychainActiveAccount holds the active key ID. Store
+// and SetActive write the items, typically when the application is installed
+// or first run:
+//
+//	kc := &pepper.Keychain{Service: "com.example.myapp.pepper"}
+//	if err := kc.Store(pepper.Key{ID: "k1", Secret: secret}); err != nil { ... }
+//	if err := kc.SetActive("k1"); err != nil { ... }
+//	keys, err := pepper.NewRefreshingKeyring(ctx, kc)
+//
+// Items go to the default keychain of the process: the login keychain of the
+// user, or the System keychain for daemons run as root. Their access control
+// lists allow only the application that created them to read them without
+// the user's consent, and the keychain is encrypted at rest with the user's
+// login password or the system key.
+type Keychain struct {
+	// Service names the items; if empty, DefaultKeychainService is used.
+	Service string
+
+	// DataProtection selects the data protection keychain, shared with iOS,
+	// in place of the file-based keychains. It requires the application to
+	// be signed with a keychain access group entitlement.
+	DataProtection bool
+}
+
+// Keys implements SecretProvider.
+func (k *Keychain) Keys(ctx context.Context) (string, []Key, error) {
+	accounts, err := k.accounts()
+	if err != nil {
+		return "", nil, err
+	}
+	var active string
+	var keys []Key
+	for _, account := range accounts {
+		data, err := k.get(account)
+		if err != nil {
+			return "", nil, err
+		}
+		if account == KeychainActiveAccount {
+			active = string(data)
+			continue
+		}
+		keys = append(keys, Key{ID: account, Secret: data})
+	}
+	if active == "" {
+		return "", nil, errors.New("pepper: no active key ID in the keychain")
+	}
+	return active, keys, nil
+}
+
+// Store adds key to the keychain, replacing any key with the same ID.
+func (k *Keychain) Store(key Key) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	if key.ID == KeychainActiveAccount {
+		return errors.New("pepper: key ID " + strconv.Quote(key.ID) + " is reserved in the keychain")
+	}
+	return k.set(key.ID, key.Secret)
+}
+
+// SetActive records id as the active key ID.
+func (k *Keychain) SetActive(id string) error {
+	if !validKeyID(id) {
+		return errors.New("pepper: invalid key ID " + strconv.Quote(id))
+	}
+	return k.set(KeychainActiveAccount, []byte(id))
+}
+
+// Delete removes the key with the given ID from the keychain.
+func (k *Keychain) Delete(id string) error {
+	service, account := k.cstrings(id)
+	defer C.free(unsafe.Pointer(service))
+	defer C.free(unsafe.Pointer(account))
+	return keychainStatus(C.pepper_delete(service, account, k.dataProtection()))
+}
+
+func (k *Keychain) get(id string) ([]byte, error) {
+	service, account := k.cstrings(id)
+	defer C.free(unsafe.Pointer(service))
+	defer C.free(unsafe.Pointer(account))
+
+	var out unsafe.Pointer
+	var n C.size_t
+	if err := keychainStatus(C.pepper_get(service, account, k.dataProtection(), &out, &n)); err != nil {
+		return nil, err
+	}
+	defer C.free(out)
+	data := unsafe.Slice((*byte)(out), int(n))
+	secret := append([]byte(nil), data...)
+	wipe(data)
+	return secret, nil
+}
+
+func (k *Keychain) set(id string, data []byte) error {
+	service, account := k.cstrings(id)
+	defer C.free(unsafe.Pointer(service))
+	defer C.free(unsafe.Pointer(account))
+
+	value := C.CBytes(data)
+	defer C.free(value)
+	defer wipe(unsafe.Slice((*byte)(value), len(data)))
+	return keychainStatus(C.pepper_set(service, account, k.dataProtection(), value, C.size_t(len(data))))
+}
+
+func (k *Keychain) accounts() ([]string, error) {
+	service, _ := k.cstrings("")
+	defer C.free(unsafe.Pointer(service))
+
+	var out *C.char
+	var n C.size_t
+	if err := keychainStatus(C.pepper_accounts(service, k.dataProtection(), &out, &n)); err != nil {
+		return nil, err
+	}
+	if out == nil {
+		return nil, nil
+	}
+	defer C.free(unsafe.Pointer(out))
+	var accounts []string
+	for _, a := range bytes.Split(C.GoBytes(unsafe.Pointer(out), C.int(n)), []byte{0}) {
+		if len(a) > 0 {
+			accounts = append(accounts, string(a))
+		}
+	}
+	return accounts, nil
+}
+
+// cstrings returns the service and the account id as C strings, to be freed
+// by the caller.
+func (k *Keychain) cstrings(id string) (service, account *C.char) {
+	s := k.Service
+	if s == "" {
+		s = DefaultKeychainService
+	}
+	return C.CString(s), C.CString(id)
+}
+
+func (k *Keychain) dataProtection() C.int {
+	if k.DataProtection {
+		return 1
+	}
+	return 0
+}
+
+func keychainStatus(status C.OSStatus) error {
+	if status == C.errSecSuccess {
+		return nil
+	}
+	return KeychainError(status)
+}
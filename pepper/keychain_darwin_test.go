@@ -0,0 +1,50 @@
+//go:build darwin && cgo
+
+package pepper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestKeychain(t *testing.T) {
+	kc := &Keychain{Service: "github.com/pganguli/pbkdf2/pepper.test"}
+	if err := kc.Store(key1); err != nil {
+		t.Skipf("keychain unavailable: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, id := range []string{key1.ID, key2.ID, KeychainActiveAccount} {
+			kc.Delete(id)
+		}
+	})
+	if err := kc.Store(key2); err != nil {
+		t.Fatal(err)
+	}
+	if err := kc.SetActive("k2"); err != nil {
+		t.Fatal(err)
+	}
+
+	active, keys, err := kc.Keys(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := NewKeyring(active, keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyring.ActiveID() != "k2" || len(keyring.IDs()) != 2 || !bytes.Equal(keyring.keys["k1"], key1.Secret) {
+		t.Errorf("keyring = %q, %v", keyring.ActiveID(), keyring.IDs())
+	}
+
+	if err := kc.Delete("k1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := kc.Delete("k1"); !errors.Is(err, KeychainItemNotFound) {
+		t.Errorf("Delete of a missing key = %v, want KeychainItemNotFound", err)
+	}
+	if err := kc.Store(Key{ID: KeychainActiveAccount, Secret: key1.Secret}); err == nil {
+		t.Error("Store accepted the reserved key ID")
+	}
+}
@@ -0,0 +1,498 @@
+// Package pepper adds a secret key, the pepper, to password hashes, so that a
+// leaked database of hashes cannot be attacked without also obtaining the key.
+// The pepper is held outside the database, for example in a KMS or secrets
+// manager, and identified by an ID recorded in each hash so that it can be
+// rotated.
+//
+// Two modes are supported. In ModeHMAC the PBKDF2 output is replaced with an
+// HMAC of it keyed by the pepper. In ModeAESGCM it is encrypted with the
+// pepper using AES-256-GCM. Both are verified the same way, but only ModeAESGCM
+// hashes can be moved to a new pepper without the users' passwords, using
+// RePepper; ModeHMAC hashes can only move as users log in.
+//
+// Peppered hashes use the pbkdf2 format, with the mode and key ID recorded as
+// metadata:
+//
+//	$pbkdf2-sha512$kid=k2,pepper=aes-gcm$210000$<salt>$<nonce+ciphertext>
+//
+// They must be verified with this package; pbkdf2.CheckHash does not know the
+// pepper, and reports that they do not match any password.
+package pepper
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha512"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// KeySize is the required length of a pepper key in bytes.
+const KeySize = 32
+
+var (
+	// ErrUnknownKey is returned if a hash is peppered with a key that is not
+	// in the keyring.
+	ErrUnknownKey = errors.New("pepper: hash uses a key that is not in the keyring")
+
+	// ErrNotPeppered is returned if a hash has no pepper metadata.
+	ErrNotPeppered = errors.New("pepper: hash is not peppered")
+
+	// ErrNotReversible is returned by RePepper for ModeHMAC hashes, which
+	// cannot be moved to a new key without the password.
+	ErrNotReversible = errors.New("pepper: HMAC-peppered hashes cannot be re-peppered without the password")
+)
+
+// Mode selects how the pepper is applied.
+type Mode int
+
+const (
+	// ModeHMAC replaces the PBKDF2 output with HMAC-SHA512 of it, keyed with
+	// the pepper and truncated to the key length, which must be at most 64
+	// bytes.
+	ModeHMAC Mode = iota + 1
+
+	// ModeAESGCM encrypts the PBKDF2 output with AES-256-GCM using the
+	// pepper, with the salt as additional data. The stored key is 28 bytes
+	// longer than the key length, to hold the nonce and tag.
+	ModeAESGCM
+)
+
+// Metadata keys and values recorded in peppered hashes.
+const (
+	metadataMode  = "pepper"
+	metadataKeyID = "kid"
+	modeHMAC      = "hmac"
+	modeAESGCM    = "aes-gcm"
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeHMAC:
+		return modeHMAC
+	case ModeAESGCM:
+		return modeAESGCM
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+func parseMode(s string) (Mode, bool) {
+	switch s {
+	case modeHMAC:
+		return ModeHMAC, true
+	case modeAESGCM:
+		return ModeAESGCM, true
+	}
+	return 0, false
+}
+
+// A Key is a pepper key and its ID.
+type Key struct {
+	// ID identifies the key in hashes. It must consist of letters, digits
+	// and the characters "+/.:_-".
+	ID string
+
+	// Secret is the key itself, KeySize random bytes.
+	Secret []byte
+}
+
+// A Keyring holds the pepper keys that may be needed to verify hashes, and
+// names the one used for new hashes.
+type Keyring struct {
+	active string
+	keys   map[string][]byte
+}
+
+// NewKeyring returns a keyring holding copies of keys, with activeID as the
+// key used for new hashes. It returns an error if a key has an invalid ID or
+// the wrong size, if two keys share an ID, or if activeID is not among them.
+func NewKeyring(activeID string, keys ...Key) (*Keyring, error) {
+	k := &Keyring{active: activeID, keys: make(map[string][]byte, len(keys))}
+	for _, key := range keys {
+		if err := checkKey(key); err != nil {
+			return nil, err
+		}
+		if _, dup := k.keys[key.ID]; dup {
+			return nil, fmt.Errorf("pepper: duplicate key ID %q", key.ID)
+		}
+		k.keys[key.ID] = append([]byte(nil), key.Secret...)
+	}
+	if _, ok := k.keys[activeID]; !ok {
+		return nil, fmt.Errorf("pepper: active key %q is not in the keyring", activeID)
+	}
+	return k, nil
+}
+
+func checkKey(key Key) error {
+	if !validKeyID(key.ID) {
+		return fmt.Errorf("pepper: invalid key ID %q", key.ID)
+	}
+	if len(key.Secret) != KeySize {
+		return fmt.Errorf("pepper: key %q is %d bytes, want %d", key.ID, len(key.Secret), KeySize)
+	}
+	return nil
+}
+
+// validKeyID reports whether id can be stored as a pbkdf2 metadata value.
+func validKeyID(id string) bool {
+	h := pbkdf2.Hash{
+		Params:   pbkdf2.Params{Iterations: 1, SaltLength: 1, KeyLength: 1},
+		Salt:     []byte{0},
+		Key:      []byte{0},
+		Metadata: map[string]string{metadataKeyID: id},
+	}
+	_, err := h.MarshalText()
+	return err == nil
+}
+
+// ActiveID returns the ID of the key used for new hashes.
+func (k *Keyring) ActiveID() string {
+	return k.active
+}
+
+// IDs returns the IDs of all keys in the keyring, in no particular order.
+func (k *Keyring) IDs() []string {
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// A Hasher creates and verifies peppered hashes.
+type Hasher struct {
+	// Keyring holds the pepper keys. It must be set unless Source is.
+	Keyring *Keyring
+
+	// Source, if set, supplies the keyring in place of Keyring on every
+	// call, such as a *RefreshingKeyring that follows key rotation in a KMS.
+	Source KeyringSource
+
+	// Mode is used for new hashes. Existing hashes are verified according
+	// to the mode recorded in them. If zero, ModeAESGCM is used.
+	Mode Mode
+
+	// Params are used for new hashes. If nil, the pbkdf2 package-level
+	// default params are used.
+	Params *pbkdf2.Params
+
+	// Namespace, if set, scopes new hashes to a namespace, as with
+	// pbkdf2.Hasher, so that a leaked pepper does not allow a table to be
+	// precomputed across namespaces. Hashes from any other namespace, or
+	// from none, are rejected with pbkdf2.ErrNamespaceMismatch.
+	Namespace string
+
+	// Normalization, if set, names a normalization registered with
+	// pbkdf2.RegisterNormalization, applied to passwords of new hashes, as
+	// with pbkdf2.Hasher. Hashes are verified with the normalization they
+	// record.
+	Normalization string
+
+	// Policy, if set, is checked against the params of every hash before it
+	// is verified, as with pbkdf2.Hasher. The key length checked is that of
+	// the PBKDF2 output, before the pepper is applied.
+	Policy *pbkdf2.Policy
+}
+
+// CreateHash returns a hash of password, peppered with the active key.
+func (h *Hasher) CreateHash(password string) (string, error) {
+	params := h.Params
+	if params == nil {
+		params = pbkdf2.GetDefaultParams()
+	}
+	mode := h.Mode
+	if mode == 0 {
+		mode = ModeAESGCM
+	}
+	if h.Namespace != "" && !pbkdf2.ValidNamespace(h.Namespace) {
+		return "", pbkdf2.ErrInvalidNamespace
+	}
+	password, err := pbkdf2.ApplyNormalization(h.Normalization, password)
+	if err != nil {
+		return "", err
+	}
+
+	salt := make([]byte, params.SaltLength)
+	if _, err := io.ReadFull(pbkdf2.Rand, salt); err != nil {
+		return "", err
+	}
+
+	secret := pbkdf2.SecureBytesFromString(password)
+	defer secret.Destroy()
+	derived, err := pbkdf2.DeriveKey(secret, derivationSalt(salt, h.Namespace), params)
+	if err != nil {
+		return "", err
+	}
+	defer derived.Destroy()
+
+	keyring := h.keyring()
+	id := keyring.active
+	key, err := apply(mode, keyring.keys[id], salt, derived.Bytes())
+	if err != nil {
+		return "", err
+	}
+	return encode(params, salt, key, mode, id, transforms{h.Namespace, h.Normalization}), nil
+}
+
+// CheckHash reports whether password matches hash. It returns ErrNotPeppered
+// if hash carries no pepper metadata, ErrUnknownKey if its key is not in the
+// keyring, a *pbkdf2.PolicyError if it violates h.Policy, and any error from
+// decoding the hash.
+func (h *Hasher) CheckHash(password, hash string) (match bool, err error) {
+	p, err := parse(hash)
+	if err != nil {
+		return false, err
+	}
+	namespace := p.hash.Metadata[pbkdf2.MetadataNamespace]
+	if h.Namespace != "" && namespace != h.Namespace {
+		return false, pbkdf2.ErrNamespaceMismatch
+	}
+	pepper, ok := h.keyring().keys[p.keyID]
+	if !ok {
+		return false, ErrUnknownKey
+	}
+
+	params := p.hash.Params
+	params.KeyLength = uint32(p.derivedLength())
+	if err := h.Policy.Check(&params); err != nil {
+		return false, err
+	}
+	password, err = pbkdf2.ApplyNormalization(p.hash.Metadata[pbkdf2.MetadataNormalization], password)
+	if err != nil {
+		return false, err
+	}
+	secret := pbkdf2.SecureBytesFromString(password)
+	defer secret.Destroy()
+	derived, err := pbkdf2.DeriveKey(secret, derivationSalt(p.hash.Salt, namespace), &params)
+	if err != nil {
+		return false, err
+	}
+	defer derived.Destroy()
+
+	switch p.mode {
+	case ModeHMAC:
+		other := hmacKey(pepper, derived.Bytes())
+		return subtle.ConstantTimeCompare(other, p.hash.Key) == 1, nil
+	default:
+		stored, err := openKey(pepper, p.hash.Salt, p.hash.Key)
+		if err != nil {
+			return false, err
+		}
+		defer wipe(stored)
+		return subtle.ConstantTimeCompare(stored, derived.Bytes()) == 1, nil
+	}
+}
+
+// Verify is like CheckHash, except it returns
+// pbkdf2.ErrMismatchedHashAndPassword if the password does not match.
+func (h *Hasher) Verify(password, hash string) error {
+	match, err := h.CheckHash(password, hash)
+	if err != nil {
+		return err
+	}
+	if !match {
+		return pbkdf2.ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+// NeedsRePepper reports whether hash is peppered with a key other than the
+// active one, or is not peppered at all.
+func (h *Hasher) NeedsRePepper(hash string) bool {
+	p, err := parse(hash)
+	return err != nil || p.keyID != h.keyring().active
+}
+
+func (h *Hasher) keyring() *Keyring {
+	if h.Source != nil {
+		return h.Source.Keyring()
+	}
+	return h.Keyring
+}
+
+// RePepper moves a ModeAESGCM hash from whichever key in old it uses to
+// newKey, without needing the password. Hashes already using newKey are
+// returned unchanged. It returns ErrNotReversible for ModeHMAC hashes,
+// ErrUnknownKey if the hash's key is not in old, and an error if the stored
+// key fails to decrypt, which indicates the hash or keyring is corrupt.
+//
+// The key to rotate to is normally added to the keyring of the application
+// ahead of time, and only made active once every hash has been re-peppered,
+// so that hashes written in the meantime remain verifiable.
+func RePepper(hash string, old *Keyring, newKey Key) (string, error) {
+	if err := checkKey(newKey); err != nil {
+		return "", err
+	}
+	p, err := parse(hash)
+	if err != nil {
+		return "", err
+	}
+	if p.mode != ModeAESGCM {
+		return "", ErrNotReversible
+	}
+
+	if p.keyID == newKey.ID {
+		return hash, nil
+	}
+
+	pepper, ok := old.keys[p.keyID]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+	derived, err := openKey(pepper, p.hash.Salt, p.hash.Key)
+	if err != nil {
+		return "", err
+	}
+	defer wipe(derived)
+
+	key, err := sealKey(newKey.Secret, p.hash.Salt, derived)
+	if err != nil {
+		return "", err
+	}
+	return encode(&p.hash.Params, p.hash.Salt, key, ModeAESGCM, newKey.ID, transformsOf(p.hash)), nil
+}
+
+type parsed struct {
+	hash  *pbkdf2.Hash
+	mode  Mode
+	keyID string
+}
+
+func parse(hash string) (*parsed, error) {
+	h, err := pbkdf2.ParseHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	modeName, ok := h.Metadata[metadataMode]
+	if !ok {
+		return nil, ErrNotPeppered
+	}
+	mode, ok := parseMode(modeName)
+	keyID := h.Metadata[metadataKeyID]
+	if !ok || keyID == "" {
+		return nil, pbkdf2.ErrInvalidHash
+	}
+
+	p := &parsed{hash: h, mode: mode, keyID: keyID}
+	if n := p.derivedLength(); n <= 0 || mode == ModeHMAC && n > sha512.Size {
+		return nil, pbkdf2.ErrInvalidHash
+	}
+	return p, nil
+}
+
+// derivedLength returns the length of the PBKDF2 output the stored key was
+// produced from.
+func (p *parsed) derivedLength() int {
+	if p.mode == ModeAESGCM {
+		return len(p.hash.Key) - gcmOverhead
+	}
+	return len(p.hash.Key)
+}
+
+// transforms are the pbkdf2 transforms recorded in a peppered hash.
+type transforms struct {
+	namespace     string
+	normalization string
+}
+
+func transformsOf(h *pbkdf2.Hash) transforms {
+	return transforms{h.Metadata[pbkdf2.MetadataNamespace], h.Metadata[pbkdf2.MetadataNormalization]}
+}
+
+func encode(params *pbkdf2.Params, salt, key []byte, mode Mode, keyID string, t transforms) string {
+	h := &pbkdf2.Hash{
+		Params: pbkdf2.Params{
+			Iterations: params.Iterations,
+			SaltLength: uint32(len(salt)),
+			KeyLength:  uint32(len(key)),
+			Variant:    params.Variant,
+		},
+		Salt:     salt,
+		Key:      key,
+		Metadata: map[string]string{metadataMode: mode.String(), metadataKeyID: keyID},
+	}
+	if t.namespace != "" {
+		h.Metadata[pbkdf2.MetadataNamespace] = t.namespace
+	}
+	if t.normalization != "" {
+		h.Metadata[pbkdf2.MetadataNormalization] = t.normalization
+	}
+	return h.String()
+}
+
+// derivationSalt returns the salt to run PBKDF2 over for a hash with the
+// given stored salt in namespace.
+func derivationSalt(salt []byte, namespace string) []byte {
+	if namespace == "" {
+		return salt
+	}
+	return pbkdf2.NamespaceSalt(namespace, salt)
+}
+
+func apply(mode Mode, pepper, salt, derived []byte) ([]byte, error) {
+	switch mode {
+	case ModeHMAC:
+		if len(derived) > sha512.Size {
+			return nil, fmt.Errorf("pepper: key length %d exceeds the %d bytes ModeHMAC supports", len(derived), sha512.Size)
+		}
+		return hmacKey(pepper, derived), nil
+	case ModeAESGCM:
+		return sealKey(pepper, salt, derived)
+	}
+	return nil, fmt.Errorf("pepper: unsupported mode %v", mode)
+}
+
+func hmacKey(pepper, derived []byte) []byte {
+	mac := hmac.New(sha512.New, pepper)
+	mac.Write(derived)
+	return mac.Sum(nil)[:len(derived)]
+}
+
+const gcmOverhead = 12 + 16
+
+func newGCM(pepper []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pepper)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealKey(pepper, salt, derived []byte) ([]byte, error) {
+	aead, err := newGCM(pepper)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(derived)+aead.Overhead())
+	if _, err := io.ReadFull(pbkdf2.Rand, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, derived, salt), nil
+}
+
+func openKey(pepper, salt, stored []byte) ([]byte, error) {
+	aead, err := newGCM(pepper)
+	if err != nil {
+		return nil, err
+	}
+	if len(stored) < gcmOverhead {
+		return nil, pbkdf2.ErrInvalidHash
+	}
+	nonce, ciphertext := stored[:aead.NonceSize()], stored[aead.NonceSize():]
+	derived, err := aead.Open(nil, nonce, ciphertext, salt)
+	if err != nil {
+		return nil, errors.New("pepper: stored key failed to decrypt; the hash or key is corrupt")
+	}
+	return derived, nil
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,246 @@
+package pepper
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+var (
+	key1 = Key{ID: "k1", Secret: bytes.Repeat([]byte{1}, KeySize)}
+	key2 = Key{ID: "k2", Secret: bytes.Repeat([]byte{2}, KeySize)}
+
+	testParams = &pbkdf2.Params{Iterations: 1000, SaltLength: 16, KeyLength: 32}
+)
+
+func newHasher(t *testing.T, mode Mode, active string) *Hasher {
+	t.Helper()
+	keyring, err := NewKeyring(active, key1, key2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Hasher{Keyring: keyring, Mode: mode, Params: testParams}
+}
+
+func TestHasher(t *testing.T) {
+	for _, mode := range []Mode{ModeHMAC, ModeAESGCM} {
+		h := newHasher(t, mode, "k1")
+		hash, err := h.CreateHash("pa$$word")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(hash, "$kid=k1,pepper="+mode.String()+"$") {
+			t.Errorf("%v: expected pepper metadata in %q", mode, hash)
+		}
+		if err := h.Verify("pa$$word", hash); err != nil {
+			t.Fatalf("%v: %v", mode, err)
+		}
+		if err := h.Verify("otherPa$$word", hash); err != pbkdf2.ErrMismatchedHashAndPassword {
+			t.Fatalf("%v: expected ErrMismatchedHashAndPassword, got %v", mode, err)
+		}
+
+		// The pepper is required.
+		if err := pbkdf2.Verify("pa$$word", hash); err != pbkdf2.ErrMismatchedHashAndPassword {
+			t.Fatalf("%v: expected hash not to verify without the pepper, got %v", mode, err)
+		}
+		other, _ := NewKeyring("k2", key2)
+		if err := (&Hasher{Keyring: other}).Verify("pa$$word", hash); err != ErrUnknownKey {
+			t.Fatalf("%v: expected ErrUnknownKey, got %v", mode, err)
+		}
+
+		if h.NeedsRePepper(hash) {
+			t.Errorf("%v: expected hash with the active key not to need re-peppering", mode)
+		}
+		if !newHasher(t, mode, "k2").NeedsRePepper(hash) {
+			t.Errorf("%v: expected hash with an old key to need re-peppering", mode)
+		}
+	}
+
+	plain, _ := pbkdf2.CreateHash("pa$$word", testParams)
+	if err := newHasher(t, 0, "k1").Verify("pa$$word", plain); err != ErrNotPeppered {
+		t.Fatalf("expected ErrNotPeppered, got %v", err)
+	}
+}
+
+func TestHasherOverlongHMACKey(t *testing.T) {
+	h := newHasher(t, ModeHMAC, "k1")
+	hash, err := h.CreateHash("pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := parse(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// HMAC-SHA512 cannot have produced a key longer than 64 bytes.
+	crafted := encode(&p.hash.Params, p.hash.Salt, bytes.Repeat([]byte{1}, 100), ModeHMAC, p.keyID, transforms{})
+	if _, err := h.CheckHash("pa$$word", crafted); !errors.Is(err, pbkdf2.ErrInvalidHash) {
+		t.Fatalf("expected ErrInvalidHash, got %v", err)
+	}
+}
+
+func TestRePepper(t *testing.T) {
+	h := newHasher(t, ModeAESGCM, "k1")
+	hash, err := h.CreateHash("pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, _ := NewKeyring("k1", key1)
+
+	newHash, err := RePepper(hash, old, key2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(newHash, "kid=k2") {
+		t.Fatalf("expected %q to use k2", newHash)
+	}
+	if err := newHasher(t, 0, "k2").Verify("pa$$word", newHash); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := RePepper(newHash, old, key2); err != nil || again != newHash {
+		t.Fatalf("expected hash already using the new key to be unchanged, got %q, %v", again, err)
+	}
+
+	// The salt is bound to the ciphertext.
+	tampered, _ := pbkdf2.ParseHash(hash)
+	tampered.Salt[0] ^= 1
+	if _, err := RePepper(tampered.String(), old, key2); err == nil {
+		t.Fatal("expected tampered hash to fail to decrypt")
+	}
+
+	hmacHash, err := newHasher(t, ModeHMAC, "k1").CreateHash("pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RePepper(hmacHash, old, key2); err != ErrNotReversible {
+		t.Fatalf("expected ErrNotReversible, got %v", err)
+	}
+	only2, _ := NewKeyring("k2", key2)
+	if _, err := RePepper(hash, only2, Key{ID: "k3", Secret: key2.Secret}); err != ErrUnknownKey {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+	if _, err := RePepper("$pbkdf2-sha512$kid=k1,pepper=rot13$1000$AAAA$AAAA", old, key2); !errors.Is(err, pbkdf2.ErrInvalidHash) {
+		t.Fatalf("expected ErrInvalidHash, got %v", err)
+	}
+}
+
+func TestNewKeyring(t *testing.T) {
+	for _, tt := range []struct {
+		active string
+		keys   []Key
+	}{
+		{"k3", []Key{key1, key2}},
+		{"k1", []Key{key1, key1}},
+		{"k1", []Key{{ID: "k1", Secret: []byte("short")}}},
+		{"k 1", []Key{{ID: "k 1", Secret: key1.Secret}}},
+	} {
+		if _, err := NewKeyring(tt.active, tt.keys...); err == nil {
+			t.Errorf("NewKeyring(%q, %v): expected error", tt.active, tt.keys)
+		}
+	}
+
+	if _, err := newHasher(t, ModeHMAC, "k1").CreateHash("pa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	long := newHasher(t, ModeHMAC, "k1")
+	long.Params = &pbkdf2.Params{Iterations: 1000, SaltLength: 16, KeyLength: 65}
+	if _, err := long.CreateHash("pa$$word"); err == nil {
+		t.Fatal("expected ModeHMAC to reject keys longer than 64 bytes")
+	}
+}
+
+func TestFallbackChain(t *testing.T) {
+	current, err := NewKeyring("k2", key2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, err := NewKeyring("k1", key1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := &pbkdf2.FallbackChain{Candidates: []pbkdf2.Candidate{
+		{Name: "k2", Check: (&Hasher{Keyring: current}).CheckHash},
+		{Name: "k1", Check: (&Hasher{Keyring: previous}).CheckHash},
+		{Name: "unpeppered"},
+	}}
+
+	old, err := (&Hasher{Keyring: previous, Params: testParams}).CreateHash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := pbkdf2.CreateHash("password", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for hash, want := range map[string]string{old: "k1", plain: "unpeppered"} {
+		result, err := chain.Verify("password", hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Name != want || !result.NeedsUpgrade() {
+			t.Errorf("expected a match against %q needing an upgrade, got %+v", want, result)
+		}
+	}
+}
+
+func TestHasherNamespace(t *testing.T) {
+	acme := newHasher(t, ModeAESGCM, "k1")
+	acme.Namespace = "acme"
+	hash, err := acme.CreateHash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := acme.Verify("password", hash); err != nil {
+		t.Fatal(err)
+	}
+
+	globex := newHasher(t, ModeAESGCM, "k1")
+	globex.Namespace = "globex"
+	if err := globex.Verify("password", hash); err != pbkdf2.ErrNamespaceMismatch {
+		t.Errorf("expected ErrNamespaceMismatch, got %v", err)
+	}
+
+	repeppered, err := RePepper(hash, acme.Keyring, key2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := newHasher(t, ModeAESGCM, "k2").Verify("password", repeppered); err != nil {
+		t.Errorf("expected the namespace to survive re-peppering, got %v", err)
+	}
+}
+
+func TestHasherPolicy(t *testing.T) {
+	h := newHasher(t, ModeAESGCM, "k1")
+	hash, err := h.CreateHash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The key length checked is that of the PBKDF2 output, not the 28 bytes
+	// longer ciphertext.
+	h.Policy = &pbkdf2.Policy{MaxKeyLength: testParams.KeyLength}
+	if err := h.Verify("password", hash); err != nil {
+		t.Fatal(err)
+	}
+	h.Policy = &pbkdf2.Policy{MinIterations: testParams.Iterations + 1}
+	if err := h.Verify("password", hash); !errors.Is(err, pbkdf2.ErrPolicyViolation) {
+		t.Errorf("expected a policy violation, got %v", err)
+	}
+}
+
+func TestHasherNormalization(t *testing.T) {
+	pbkdf2.RegisterNormalization("test-lower", strings.ToLower)
+
+	h := newHasher(t, ModeHMAC, "k1")
+	h.Normalization = "test-lower"
+	hash, err := h.CreateHash("PassWord")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := newHasher(t, ModeHMAC, "k1").Verify("PASSWORD", hash); err != nil {
+		t.Errorf("expected the normalization recorded in the hash to be applied, got %v", err)
+	}
+}
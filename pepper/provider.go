@@ -0,0 +1,126 @@
+package pepper
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// A SecretProvider fetches pepper keys from where they are held, such as a
+// KMS or secrets manager; the kmspepper package has providers for the major
+// clouds. Implementations must be safe for concurrent use.
+type SecretProvider interface {
+	// Keys returns the keys currently available, which should include
+	// those rotated out but still protecting stored hashes, and the ID of
+	// the one to use for new hashes.
+	Keys(ctx context.Context) (activeID string, keys []Key, err error)
+}
+
+// SecretProviderFunc adapts an ordinary function to a SecretProvider.
+type SecretProviderFunc func(ctx context.Context) (activeID string, keys []Key, err error)
+
+// Keys calls f(ctx).
+func (f SecretProviderFunc) Keys(ctx context.Context) (string, []Key, error) {
+	return f(ctx)
+}
+
+// A KeyringSource supplies the keyring of a Hasher; see Hasher.Source.
+type KeyringSource interface {
+	Keyring() *Keyring
+}
+
+// Keyring returns k, so that a *Keyring is a KeyringSource.
+func (k *Keyring) Keyring() *Keyring {
+	return k
+}
+
+// RefreshingKeyring is a KeyringSource holding the keys of a SecretProvider,
+// refetched by Refresh or periodically by Run, so that rotating the pepper in
+// a KMS reaches running services without a restart:
+//
+//	keys, err := pepper.NewRefreshingKeyring(ctx, provider)
+//	go keys.Run(ctx, 10*time.Minute)
+//	h := &pepper.Hasher{Source: keys}
+//
+// If a refresh fails, the keys last fetched stay in use, so that an outage
+// of the provider does not stop logins. It is safe for concurrent use.
+type RefreshingKeyring struct {
+	provider SecretProvider
+	current  atomic.Pointer[Keyring]
+
+	mu          sync.Mutex
+	lastRefresh time.Time
+
+	// OnError, if set, is called by Run with each failed refresh.
+	OnError func(error)
+
+	// Clock, if set, replaces pbkdf2.SystemClock for Run's waits.
+	Clock pbkdf2.Clock
+}
+
+// NewRefreshingKeyring fetches the keys of provider, returning an error if
+// they cannot be fetched or do not form a valid keyring.
+func NewRefreshingKeyring(ctx context.Context, provider SecretProvider) (*RefreshingKeyring, error) {
+	r := &RefreshingKeyring{provider: provider}
+	if err := r.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Keyring returns the keyring last fetched.
+func (r *RefreshingKeyring) Keyring() *Keyring {
+	return r.current.Load()
+}
+
+// Refresh fetches the keys from the provider and replaces the keyring with
+// them. On error, the keyring is left as it was.
+func (r *RefreshingKeyring) Refresh(ctx context.Context) error {
+	active, keys, err := r.provider.Keys(ctx)
+	if err != nil {
+		return err
+	}
+	keyring, err := NewKeyring(active, keys...)
+	if err != nil {
+		return err
+	}
+	r.current.Store(keyring)
+
+	r.mu.Lock()
+	r.lastRefresh = r.clock().Now()
+	r.mu.Unlock()
+	return nil
+}
+
+// LastRefresh returns when the keys were last fetched successfully, so that
+// stale keys can be monitored.
+func (r *RefreshingKeyring) LastRefresh() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastRefresh
+}
+
+// Run refreshes the keys every interval until ctx is done, reporting failures
+// to OnError.
+func (r *RefreshingKeyring) Run(ctx context.Context, interval time.Duration) {
+	clock := r.clock()
+	for {
+		clock.Sleep(ctx, interval)
+		if ctx.Err() != nil {
+			return
+		}
+		if err := r.Refresh(ctx); err != nil && r.OnError != nil {
+			r.OnError(err)
+		}
+	}
+}
+
+func (r *RefreshingKeyring) clock() pbkdf2.Clock {
+	if r.Clock == nil {
+		return pbkdf2.SystemClock
+	}
+	return r.Clock
+}
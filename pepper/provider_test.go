@@ -0,0 +1,93 @@
+package pepper
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+func TestRefreshingKeyring(t *testing.T) {
+	active, fail := "k1", false
+	provider := SecretProviderFunc(func(context.Context) (string, []Key, error) {
+		if fail {
+			return "", nil, errors.New("provider unavailable")
+		}
+		return active, []Key{key1, key2}, nil
+	})
+	keys, err := NewRefreshingKeyring(context.Background(), provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Hasher{Source: keys, Params: testParams}
+	hash, err := h.CreateHash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	active = "k2"
+	if err := keys.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !h.NeedsRePepper(hash) {
+		t.Error("hash under the rotated-out key does not need re-peppering")
+	}
+	if match, err := h.CheckHash("password", hash); !match || err != nil {
+		t.Errorf("CheckHash after rotation = %v, %v", match, err)
+	}
+
+	fail = true
+	if err := keys.Refresh(context.Background()); err == nil {
+		t.Error("Refresh succeeded with a failing provider")
+	}
+	if keys.Keyring().ActiveID() != "k2" {
+		t.Error("failed Refresh replaced the keyring")
+	}
+
+	active, fail = "missing", false
+	if err := keys.Refresh(context.Background()); err == nil {
+		t.Error("Refresh accepted an active key not among the keys")
+	}
+	if _, err := NewRefreshingKeyring(context.Background(), provider); err == nil {
+		t.Error("NewRefreshingKeyring accepted an invalid keyring")
+	}
+}
+
+func TestRefreshingKeyringRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var calls int32
+	provider := SecretProviderFunc(func(context.Context) (string, []Key, error) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			return "k1", []Key{key1}, nil
+		case 2:
+			return "", nil, errors.New("provider unavailable")
+		default:
+			cancel()
+			return "k2", []Key{key1, key2}, nil
+		}
+	})
+	clock := pbkdf2.NewFakeClock(time.Unix(1e9, 0))
+	keys, err := NewRefreshingKeyring(ctx, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.Clock = clock
+	var errs int
+	keys.OnError = func(error) { errs++ }
+
+	keys.Run(ctx, time.Minute)
+	if calls != 3 || errs != 1 {
+		t.Errorf("Run made %d calls with %d errors, want 3 and 1", calls, errs)
+	}
+	if keys.Keyring().ActiveID() != "k2" {
+		t.Error("Run did not refresh the keyring")
+	}
+	if want := time.Unix(1e9, 0).Add(2 * time.Minute); !keys.LastRefresh().Equal(want) {
+		t.Errorf("LastRefresh = %v, want %v", keys.LastRefresh(), want)
+	}
+}
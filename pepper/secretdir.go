@@ -0,0 +1,145 @@
+package pepper
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultActiveFile is the file of a SecretDir naming the active key, if
+// SecretDir.ActiveFile is empty.
+const DefaultActiveFile = "active"
+
+// DefaultSecretDirInterval is the polling interval of WatchSecretDir if none
+// is given. The kubelet updates mounted secrets about once a minute.
+const DefaultSecretDirInterval = 10 * time.Second
+
+// kubeletDataDir is the symlink through which the kubelet publishes the
+// current contents of a secret volume, swapping it atomically on update.
+const kubeletDataDir = "..data"
+
+// SecretDir is a SecretProvider reading pepper keys from a directory with one
+// file per key, named by key ID and holding the key as KeySize raw bytes or
+// their standard base64 encoding, as in a Kubernetes secret volume:
+//
+//	apiVersion: v1
+//	kind: Secret
+//	metadata:
+//	  name: password-pepper
+//	stringData:
+//	  active: "2024-06"
+//	  "2024-01": "0C4tYv4Fq6Kz...="
+//	  "2024-06": "wF0Kx2ZcJ1mB...="
+//
+// The file ActiveFile names the active key. Files whose names start with "."
+// are ignored.
+//
+// The kubelet writes each version of a secret to a new directory and swaps a
+// symlink to it, so a reader can see the files of two versions at once. When
+// the directory has that layout, Keys reads all files through one resolution
+// of the symlink, so the active key and the keys always come from the same
+// version of the secret.
+type SecretDir struct {
+	Dir string
+
+	// ActiveFile is the name of the file holding the active key ID. If
+	// empty, DefaultActiveFile is used.
+	ActiveFile string
+
+	// Prefix, if set, restricts the keys to files whose names start with
+	// it, the key IDs being the rest of their names, so that the directory
+	// can hold other secrets too. The active key ID is read from Prefix
+	// followed by ActiveFile.
+	Prefix string
+
+	// Decode, if set, replaces the decoding of key files, such as with
+	// UnsealDPAPI for keys sealed on Windows.
+	Decode func(data []byte) ([]byte, error)
+}
+
+// Keys implements SecretProvider.
+func (d *SecretDir) Keys(ctx context.Context) (string, []Key, error) {
+	dir := d.Dir
+	resolved, err := filepath.EvalSymlinks(filepath.Join(d.Dir, kubeletDataDir))
+	switch {
+	case err == nil:
+		dir = resolved
+	case !errors.Is(err, os.ErrNotExist):
+		return "", nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	activeFile := d.ActiveFile
+	if activeFile == "" {
+		activeFile = DefaultActiveFile
+	}
+	activeFile = d.Prefix + activeFile
+	decode := d.Decode
+	if decode == nil {
+		decode = decodeKeyFile
+	}
+	var active string
+	var keys []Key
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") || !strings.HasPrefix(name, d.Prefix) || e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return "", nil, err
+		}
+		if name == activeFile {
+			active = strings.TrimSpace(string(data))
+			continue
+		}
+		secret, err := decode(data)
+		if err != nil {
+			return "", nil, fmt.Errorf("pepper: key file %q: %w", name, err)
+		}
+		keys = append(keys, Key{ID: strings.TrimPrefix(name, d.Prefix), Secret: secret})
+	}
+	if active == "" {
+		return "", nil, fmt.Errorf("pepper: no active key ID in %q", filepath.Join(d.Dir, activeFile))
+	}
+	return active, keys, nil
+}
+
+// decodeKeyFile decodes the contents of a key file: KeySize raw bytes, or
+// their standard base64 encoding with optional surrounding whitespace.
+func decodeKeyFile(data []byte) ([]byte, error) {
+	if len(data) == KeySize {
+		return data, nil
+	}
+	secret, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return nil, errors.New("neither a raw key nor base64")
+	}
+	return secret, nil
+}
+
+// WatchSecretDir returns a RefreshingKeyring reading the SecretDir dir, and
+// polls it every interval, or DefaultSecretDirInterval if interval is zero,
+// until ctx is done. Rotating the pepper is then a matter of updating the
+// secret: first adding the new key, then, once every replica has it, changing
+// the active key ID.
+func WatchSecretDir(ctx context.Context, dir string, interval time.Duration) (*RefreshingKeyring, error) {
+	if interval <= 0 {
+		interval = DefaultSecretDirInterval
+	}
+	r, err := NewRefreshingKeyring(ctx, &SecretDir{Dir: dir})
+	if err != nil {
+		return nil, err
+	}
+	go r.Run(ctx, interval)
+	return r, nil
+}
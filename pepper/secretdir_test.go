@@ -0,0 +1,91 @@
+package pepper
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSecretVersion publishes files in dir as the kubelet does: into a new
+// timestamped directory, then by swapping the ..data symlink to it.
+func writeSecretVersion(t *testing.T, dir, version string, files map[string]string) {
+	t.Helper()
+	versionDir := filepath.Join(dir, "..2024_06_01_"+version)
+	if err := os.Mkdir(versionDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(versionDir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); os.IsNotExist(err) {
+			if err := os.Symlink(filepath.Join(kubeletDataDir, name), link); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	tmp := filepath.Join(dir, "..data_tmp")
+	if err := os.Symlink(filepath.Base(versionDir), tmp); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, kubeletDataDir)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSecretDir(t *testing.T) {
+	dir := t.TempDir()
+	b64 := base64.StdEncoding.EncodeToString
+	writeSecretVersion(t, dir, "1", map[string]string{
+		"active": "k1\n",
+		"k1":     b64(key1.Secret) + "\n",
+	})
+	keys, err := NewRefreshingKeyring(context.Background(), &SecretDir{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := keys.Keyring(); got.ActiveID() != "k1" || len(got.IDs()) != 1 {
+		t.Errorf("keyring = %q, %v", got.ActiveID(), got.IDs())
+	}
+
+	writeSecretVersion(t, dir, "2", map[string]string{
+		"active": "k2",
+		"k1":     b64(key1.Secret),
+		"k2":     string(key2.Secret),
+	})
+	if err := keys.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := keys.Keyring(); got.ActiveID() != "k2" || len(got.IDs()) != 2 {
+		t.Errorf("keyring after rotation = %q, %v", got.ActiveID(), got.IDs())
+	}
+
+	writeSecretVersion(t, dir, "3", map[string]string{"active": "k2", "k2": "not a key"})
+	if err := keys.Refresh(context.Background()); err == nil {
+		t.Error("Refresh accepted an invalid key file")
+	}
+	if keys.Keyring().ActiveID() != "k2" {
+		t.Error("failed Refresh replaced the keyring")
+	}
+}
+
+func TestSecretDirPlain(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "current"), []byte("k1"), 0o644)
+	os.WriteFile(filepath.Join(dir, "k1"), key1.Secret, 0o600)
+	os.WriteFile(filepath.Join(dir, ".hidden"), []byte("ignored"), 0o600)
+
+	active, keys, err := (&SecretDir{Dir: dir, ActiveFile: "current"}).Keys(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active != "k1" || len(keys) != 1 || keys[0].ID != "k1" {
+		t.Errorf("Keys = %q, %v", active, keys)
+	}
+	if _, _, err := (&SecretDir{Dir: dir}).Keys(context.Background()); err == nil {
+		t.Error("Keys succeeded without an active key file")
+	}
+}
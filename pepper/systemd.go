@@ -0,0 +1,40 @@
+package pepper
+
+import (
+	"errors"
+	"os"
+)
+
+// DefaultCredentialPrefix is the prefix of the credentials holding pepper
+// keys, if SystemdCredentials is given none.
+const DefaultCredentialPrefix = "pepper."
+
+// SystemdCredentials returns a SecretDir reading pepper keys from the
+// credentials systemd passes to the service in $CREDENTIALS_DIRECTORY, so
+// that they need not appear in its environment, command line or unit file.
+// Each key is a credential named by prefix, or DefaultCredentialPrefix if
+// prefix is empty, and the key ID, and the credential named by prefix and
+// "active" holds the active key ID:
+//
+//	[Service]
+//	LoadCredentialEncrypted=pepper.2024-06:/etc/credstore.encrypted/pepper.2024-06
+//	SetCredential=pepper.active:2024-06
+//
+// Credentials encrypted with systemd-creds, for LoadCredentialEncrypted or
+// SetCredentialEncrypted, are decrypted by systemd, with the TPM or host key,
+// before the service starts, and read like any other. They are fixed for the
+// life of the service, so rotating the pepper means restarting it; there is no
+// need for a RefreshingKeyring.
+//
+// It returns an error if $CREDENTIALS_DIRECTORY is not set, as when the
+// service has no credentials or is not run by systemd 247 or later.
+func SystemdCredentials(prefix string) (*SecretDir, error) {
+	dir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if dir == "" {
+		return nil, errors.New("pepper: $CREDENTIALS_DIRECTORY is not set")
+	}
+	if prefix == "" {
+		prefix = DefaultCredentialPrefix
+	}
+	return &SecretDir{Dir: dir, Prefix: prefix}, nil
+}
@@ -0,0 +1,44 @@
+package pepper
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSystemdCredentials(t *testing.T) {
+	t.Setenv("CREDENTIALS_DIRECTORY", "")
+	if _, err := SystemdCredentials(""); err == nil {
+		t.Error("SystemdCredentials succeeded without $CREDENTIALS_DIRECTORY")
+	}
+
+	dir := t.TempDir()
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+	for name, data := range map[string]string{
+		"pepper.active": "k2",
+		"pepper.k1":     base64.StdEncoding.EncodeToString(key1.Secret),
+		"pepper.k2":     string(key2.Secret),
+		"tls.key":       "unrelated",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o400); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d, err := SystemdCredentials("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	active, keys, err := d.Keys(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := NewKeyring(active, keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyring.ActiveID() != "k2" || len(keyring.IDs()) != 2 {
+		t.Errorf("keyring = %q, %v", keyring.ActiveID(), keyring.IDs())
+	}
+}
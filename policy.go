@@ -0,0 +1,164 @@
+package pbkdf2
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrPolicyViolation is returned, wrapped in a *PolicyError, when the parameters
+// of a hash fall outside the bounds of a Policy.
+var ErrPolicyViolation = errors.New("pbkdf2: hash parameters violate policy")
+
+// Policy describes the bounds that the parameters of a hash must satisfy. A zero
+// field means the corresponding bound is not enforced.
+type Policy struct {
+	// Minimum and maximum number of iterations.
+	MinIterations uint32
+	MaxIterations uint32
+
+	// VariantMinIterations maps variant names, such as VariantSHA256, to the
+	// minimum number of iterations for hashes of that variant, since the
+	// iterations needed for a given strength depend on the PRF. Where the
+	// variant of a hash is present, its entry replaces MinIterations. See
+	// RecommendedMinIterations.
+	VariantMinIterations map[string]uint32
+
+	// Minimum length of the salt in bytes.
+	MinSaltLength uint32
+
+	// Minimum and maximum length of the key in bytes.
+	MinKeyLength uint32
+	MaxKeyLength uint32
+}
+
+// PolicyError describes a single parameter that violates a Policy. It wraps
+// ErrPolicyViolation, so callers can test for it with errors.Is and retrieve
+// the details with errors.As.
+type PolicyError struct {
+	// The name of the offending parameter: "iterations", "salt length" or
+	// "key length".
+	Param string
+
+	// The value found in the hash, and the bound it violates.
+	Value uint32
+	Limit uint32
+
+	// Max is true if Value exceeds an upper bound, and false if it is below a
+	// lower bound.
+	Max bool
+
+	// Variant is set to the variant of the hash if Limit came from
+	// Policy.VariantMinIterations.
+	Variant string
+}
+
+func (e *PolicyError) Error() string {
+	bound := "below the policy minimum of "
+	if e.Max {
+		bound = "above the policy maximum of "
+	}
+	msg := "pbkdf2: " + e.Param + " " + strconv.FormatUint(uint64(e.Value), 10) + " is " + bound + strconv.FormatUint(uint64(e.Limit), 10)
+	if e.Variant != "" {
+		msg += " for " + e.Variant
+	}
+	return msg
+}
+
+func (e *PolicyError) Unwrap() error {
+	return ErrPolicyViolation
+}
+
+// Check reports whether params satisfy the policy. It returns nil if they do,
+// otherwise a *PolicyError describing the first violation found. A nil Policy
+// accepts any params. To check a decoded hash, which may record stages added
+// by Strengthen, use CheckHash.
+func (p *Policy) Check(params *Params) error {
+	if p == nil {
+		return nil
+	}
+
+	variant := params.Variant
+	if variant == "" {
+		variant = VariantSHA512
+	}
+	if floor, ok := p.VariantMinIterations[variant]; ok {
+		if params.Iterations < floor {
+			return &PolicyError{Param: "iterations", Value: params.Iterations, Limit: floor, Variant: variant}
+		}
+	} else if p.MinIterations != 0 && params.Iterations < p.MinIterations {
+		return &PolicyError{Param: "iterations", Value: params.Iterations, Limit: p.MinIterations}
+	}
+
+	switch {
+	case p.MaxIterations != 0 && params.Iterations > p.MaxIterations:
+		return &PolicyError{Param: "iterations", Value: params.Iterations, Limit: p.MaxIterations, Max: true}
+	case p.MinSaltLength != 0 && params.SaltLength < p.MinSaltLength:
+		return &PolicyError{Param: "salt length", Value: params.SaltLength, Limit: p.MinSaltLength}
+	case p.MinKeyLength != 0 && params.KeyLength < p.MinKeyLength:
+		return &PolicyError{Param: "key length", Value: params.KeyLength, Limit: p.MinKeyLength}
+	case p.MaxKeyLength != 0 && params.KeyLength > p.MaxKeyLength:
+		return &PolicyError{Param: "key length", Value: params.KeyLength, Limit: p.MaxKeyLength, Max: true}
+	}
+	return nil
+}
+
+// CheckHash is like Check for the params of h, and also counts the
+// iterations of every stage added by Strengthen against MaxIterations, since
+// verifying h derives through all of them. A violation of MaxIterations by
+// the stages reports their total, including the first, as the Value.
+func (p *Policy) CheckHash(h *Hash) error {
+	if p == nil {
+		return nil
+	}
+	if err := p.Check(&h.Params); err != nil {
+		return err
+	}
+	if p.MaxIterations == 0 {
+		return nil
+	}
+	total, err := totalIterations(h)
+	if err != nil {
+		return err
+	}
+	if total > uint64(p.MaxIterations) {
+		value := uint32(MaxIterations)
+		if total < MaxIterations {
+			value = uint32(total)
+		}
+		return &PolicyError{Param: "iterations", Value: value, Limit: p.MaxIterations, Max: true}
+	}
+	return nil
+}
+
+// Validate fully parses a hash without needing the password, and checks its
+// parameters against policy, which may be nil. It returns nil if the hash is
+// well formed and satisfies the policy. Otherwise it returns the error from
+// DecodeHash, or a *PolicyError.
+//
+// Validate is much cheaper than CheckHash, as no key derivation is performed,
+// which makes it suitable for checking hashes at ingest time.
+func Validate(hash string, policy *Policy) error {
+	h, err := decodeHash(hash)
+	if err != nil {
+		return err
+	}
+	wipe(h.Salt)
+	wipe(h.Key)
+
+	return policy.CheckHash(h)
+}
+
+// RecommendedMinIterations returns the currently recommended minimum number of
+// iterations for each built-in variant, suitable for
+// Policy.VariantMinIterations. The figures for SHA-512, SHA-256 and SHA-1 are
+// those of the OWASP Password Storage Cheat Sheet. OWASP gives none for SHA-3
+// or BLAKE2, so the SHA-512 figure is used for them. The returned map is a
+// copy, and may be modified.
+func RecommendedMinIterations() map[string]uint32 {
+	current := currentGuidance().iterations
+	m := make(map[string]uint32, len(current))
+	for variant, n := range current {
+		m[variant] = n
+	}
+	return m
+}
@@ -0,0 +1,99 @@
+package pbkdf2
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	hash := "$pbkdf2-sha512$210000$KuwdBW88vV7YiVGWsMmc8g$XO+ztCemYHheH1kqHe6QAmb99lL3MI7IeBQ05dnAXGk"
+
+	if err := Validate(hash, nil); err != nil {
+		t.Fatalf("expected valid hash, got %v", err)
+	}
+
+	if err := Validate(hash, &Policy{MinIterations: 210000, MinSaltLength: 16, MinKeyLength: 32}); err != nil {
+		t.Fatalf("expected hash to satisfy policy, got %v", err)
+	}
+
+	err := Validate(hash, &Policy{MinIterations: 600000})
+	if !errors.Is(err, ErrPolicyViolation) {
+		t.Fatalf("expected ErrPolicyViolation, got %v", err)
+	}
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("expected *PolicyError, got %T", err)
+	}
+	if policyErr.Param != "iterations" || policyErr.Value != 210000 || policyErr.Limit != 600000 || policyErr.Max {
+		t.Fatalf("unexpected policy error %#v", *policyErr)
+	}
+
+	err = Validate(hash, &Policy{MaxKeyLength: 16})
+	if !errors.As(err, &policyErr) || policyErr.Param != "key length" || !policyErr.Max {
+		t.Fatalf("expected key length above maximum, got %v", err)
+	}
+
+	if err := Validate("$pbkdf2-sha512$210000$KuwdBW88vV7YiVGWsMmc8g", nil); !errors.Is(err, ErrInvalidHash) {
+		t.Fatalf("expected ErrInvalidHash, got %v", err)
+	}
+}
+
+func TestVariantMinIterations(t *testing.T) {
+	policy := &Policy{MinIterations: 1000, VariantMinIterations: RecommendedMinIterations()}
+
+	tests := []struct {
+		params *Params
+		limit  uint32
+	}{
+		{&Params{Iterations: 210000, SaltLength: 16, KeyLength: 32}, 0},
+		{&Params{Iterations: 209999, SaltLength: 16, KeyLength: 32}, 210000},
+		{&Params{Iterations: 210000, SaltLength: 16, KeyLength: 32, Variant: VariantSHA256}, 600000},
+		{&Params{Iterations: 600000, SaltLength: 16, KeyLength: 32, Variant: VariantSHA256}, 0},
+		{&Params{Iterations: 600000, SaltLength: 16, KeyLength: 20, Variant: VariantLegacySHA1}, 1300000},
+		// Variants without an entry fall back to MinIterations.
+		{&Params{Iterations: 1000, SaltLength: 16, KeyLength: 32, Variant: "pbkdf2-custom"}, 0},
+		{&Params{Iterations: 999, SaltLength: 16, KeyLength: 32, Variant: "pbkdf2-custom"}, 1000},
+	}
+	for _, tt := range tests {
+		err := policy.Check(tt.params)
+		if tt.limit == 0 {
+			if err != nil {
+				t.Errorf("%#v: expected no error, got %v", *tt.params, err)
+			}
+			continue
+		}
+		var policyErr *PolicyError
+		if !errors.As(err, &policyErr) || policyErr.Limit != tt.limit {
+			t.Errorf("%#v: expected iterations below %d, got %v", *tt.params, tt.limit, err)
+		}
+	}
+
+	RecommendedMinIterations()[VariantSHA512] = 1
+	if RecommendedMinIterations()[VariantSHA512] != 210000 {
+		t.Error("expected RecommendedMinIterations to return a copy")
+	}
+}
+
+func TestHasherPolicy(t *testing.T) {
+	hash, err := CreateHash("pa$$word", &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32, Variant: VariantSHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Hasher{Policy: &Policy{VariantMinIterations: map[string]uint32{VariantSHA256: 2000}}}
+	err = h.Verify("pa$$word", hash)
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) || policyErr.Variant != VariantSHA256 {
+		t.Fatalf("expected per-variant policy error, got %v", err)
+	}
+
+	h.Policy.VariantMinIterations[VariantSHA256] = 1000
+	if err := h.Verify("pa$$word", hash); err != nil {
+		t.Fatal(err)
+	}
+
+	legacy := &Hasher{AllowLegacySHA1: true, Policy: &Policy{VariantMinIterations: RecommendedMinIterations()}}
+	if err := legacy.Verify("pa$$word", legacySHA1Hashes[0]); !errors.Is(err, ErrPolicyViolation) {
+		t.Fatalf("expected ErrPolicyViolation, got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package pbkdf2
+
+import "errors"
+
+// ErrUnknownProfile is returned by ProfileParams if no profile with the given
+// name exists.
+var ErrUnknownProfile = errors.New("pbkdf2: unknown profile")
+
+// MetadataProfile is the metadata key under which a Hasher records the
+// profile a hash was created with; see Hasher.Profiles.
+const MetadataProfile = "profile"
+
+// Names of the built-in parameter profiles, modelled on libsodium's
+// interactive/moderate/sensitive limits. They allow configuration to refer to
+// a cost level by name rather than by raw parameters.
+const (
+	// ProfileDefault uses the package-level default params returned by
+	// GetDefaultParams.
+	ProfileDefault = "default"
+
+	// ProfileInteractive meets the OWASP recommended minimum for
+	// PBKDF2-HMAC-SHA512 and is suitable for logins.
+	ProfileInteractive = "interactive"
+
+	// ProfileModerate is roughly three times the cost of ProfileInteractive.
+	ProfileModerate = "moderate"
+
+	// ProfileSensitive is suitable for infrequent operations protecting
+	// high-value secrets, where a hashing time of a second or more is
+	// acceptable.
+	ProfileSensitive = "sensitive"
+)
+
+var profiles = map[string]Params{
+	ProfileInteractive: {Iterations: 210000, SaltLength: 16, KeyLength: 64},
+	ProfileModerate:    {Iterations: 600000, SaltLength: 16, KeyLength: 64},
+	ProfileSensitive:   {Iterations: 1200000, SaltLength: 32, KeyLength: 64},
+}
+
+// ProfileParams returns a copy of the parameters of the named profile. It
+// returns ErrUnknownProfile if name is not one of the Profile constants.
+func ProfileParams(name string) (*Params, error) {
+	if name == ProfileDefault {
+		return GetDefaultParams(), nil
+	}
+
+	params, ok := profiles[name]
+	if !ok {
+		return nil, ErrUnknownProfile
+	}
+	return &params, nil
+}
@@ -0,0 +1,108 @@
+package pbkdf2
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestProfileParams(t *testing.T) {
+	params, err := ProfileParams(ProfileDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *params != *DefaultParams {
+		t.Fatalf("expected %#v got %#v", *DefaultParams, *params)
+	}
+
+	// The returned params are a copy.
+	params.Iterations = 1
+	if DefaultParams.Iterations == 1 {
+		t.Fatal("ProfileParams must not return DefaultParams itself")
+	}
+
+	for _, name := range []string{ProfileInteractive, ProfileModerate, ProfileSensitive} {
+		params, err := ProfileParams(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if params.Iterations < DefaultParams.Iterations {
+			t.Fatalf("%s: iterations %d below the default", name, params.Iterations)
+		}
+	}
+
+	if _, err := ProfileParams("fast"); err != ErrUnknownProfile {
+		t.Fatalf("expected ErrUnknownProfile, got %v", err)
+	}
+}
+
+func TestHasherProfiles(t *testing.T) {
+	h := &Hasher{
+		Params: &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32},
+		Profiles: map[string]*Params{
+			"admin":   {Iterations: 3000, SaltLength: 16, KeyLength: 32, Variant: VariantSHA256},
+			"service": {Iterations: 2000, SaltLength: 16, KeyLength: 32},
+		},
+	}
+	ctx := context.Background()
+
+	admin, err := h.CreateHashWithProfile(ctx, "password", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseHash(admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Metadata[MetadataProfile] != "admin" || parsed.Params.Iterations != 3000 || parsed.Variant() != VariantSHA256 {
+		t.Fatalf("unexpected admin hash %s", admin)
+	}
+	if err := Verify("password", admin); err != nil {
+		t.Fatal(err)
+	}
+	user, err := h.CreateHash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each hash is compared against its own profile.
+	if h.NeedsRehash(admin) || h.NeedsRehash(user) {
+		t.Fatal("expected hashes matching their profiles not to need rehashing")
+	}
+	h.Profiles["admin"] = &Params{Iterations: 4000, SaltLength: 16, KeyLength: 32, Variant: VariantSHA256}
+	if !h.NeedsRehash(admin) || h.NeedsRehash(user) {
+		t.Fatal("expected only the admin hash to need rehashing")
+	}
+	delete(h.Profiles, "admin")
+	if !h.NeedsRehash(admin) {
+		t.Fatal("expected a hash with a removed profile to need rehashing")
+	}
+
+	// Profile selects the profile for CreateHash, and built-in profiles
+	// are available by name.
+	interactive := &Hasher{Profile: ProfileInteractive}
+	if s := interactive.params(); *s != profiles[ProfileInteractive] {
+		t.Fatalf("expected the interactive profile, got %+v", s)
+	}
+	service, err := (&Hasher{Profiles: h.Profiles, Profile: "service"}).CreateHash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(service, "$profile=service$2000$") {
+		t.Fatalf("unexpected service hash %s", service)
+	}
+
+	if _, err := h.CreateHashWithProfile(ctx, "password", "nobody"); err != ErrUnknownProfile {
+		t.Fatalf("expected ErrUnknownProfile, got %v", err)
+	}
+	if _, err := (&Hasher{Profile: "nobody"}).CreateHash("password"); err != ErrUnknownProfile {
+		t.Fatalf("expected ErrUnknownProfile, got %v", err)
+	}
+
+	// The profile does not change the key, so the hash is equivalent to one
+	// without it.
+	unlabelled := strings.Replace(admin, "$profile=admin$", "$", 1)
+	if ok, err := HashesEquivalent(admin, unlabelled); err != nil || !ok {
+		t.Fatalf("expected hashes differing only in profile to be equivalent, got %v, %v", ok, err)
+	}
+}
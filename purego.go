@@ -0,0 +1,8 @@
+//go:build purego
+
+package pbkdf2
+
+// purego is set by the purego build tag, which also disables the assembly
+// implementations of the hash functions in the standard library and
+// golang.org/x/crypto.
+const purego = true
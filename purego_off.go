@@ -0,0 +1,5 @@
+//go:build !purego
+
+package pbkdf2
+
+const purego = false
@@ -0,0 +1,214 @@
+// Package ratelimit throttles password verification with a token bucket per
+// caller-supplied key, such as a username or client IP address, so that basic
+// protection against online guessing needs no extra infrastructure.
+//
+// Attempts over the limit are rejected with a *LimitError before any key is
+// derived, so that throttled attempts cost the server nothing:
+//
+//	v := &ratelimit.Verifier{
+//		Verifier: &pbkdf2.Hasher{},
+//		Limiter:  ratelimit.NewLimiter(1, 5), // 5 attempts, then 1 per second
+//	}
+//	err := v.Verify(username, password, storedHash)
+//	if errors.Is(err, ratelimit.ErrRateLimited) { ... }
+//
+// In a pbkdf2.Chain of middleware, Middleware limits by a key taken from the
+// context of each call instead.
+//
+// Limits are held in memory, so each process of a horizontally scaled
+// service limits independently.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// ErrRateLimited is returned, wrapped in a *LimitError, when an attempt
+// exceeds the limit for its key.
+var ErrRateLimited = errors.New("ratelimit: too many attempts")
+
+// ErrNoKey is returned by Middleware for an attempt whose key is empty, such
+// as one whose context has no DefaultKeyLabel label.
+var ErrNoKey = errors.New("ratelimit: no key to limit the attempt by")
+
+// LimitError reports an attempt rejected by a Limiter. It wraps
+// ErrRateLimited, so callers can test for it with errors.Is.
+type LimitError struct {
+	// Key is the key whose limit was exceeded.
+	Key string
+
+	// RetryAfter is how long until the next attempt for the key would be
+	// allowed, suitable for a Retry-After header.
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return ErrRateLimited.Error() + " for " + strconv.Quote(e.Key) + "; retry after " + e.RetryAfter.String()
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// sweepThreshold is the number of tracked keys above which a Limiter forgets
+// those whose buckets have refilled, bounding its memory use.
+const sweepThreshold = 10000
+
+// A Limiter holds a token bucket for each key. Each attempt takes a token;
+// buckets start full, hold at most burst tokens, and refill at rate tokens per
+// second. It is safe for concurrent use.
+type Limiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+
+	// Clock, if set, replaces pbkdf2.SystemClock for refilling buckets, so
+	// that limits can be tested and simulated without waiting. It must be
+	// set before the Limiter is first used.
+	Clock pbkdf2.Clock
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a Limiter allowing burst attempts per key at once, and
+// rate attempts per second per key after that. It panics if rate is not
+// positive or burst is less than 1.
+func NewLimiter(rate float64, burst int) *Limiter {
+	if !(rate > 0) || burst < 1 {
+		panic("ratelimit: rate must be positive and burst at least 1")
+	}
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from the bucket for key, returning a *LimitError if
+// there is none.
+func (l *Limiter) Allow(key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= sweepThreshold {
+			l.sweep(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	l.refill(b, now)
+
+	if b.tokens < 1 {
+		wait := time.Duration(math.Ceil((1 - b.tokens) / l.rate * float64(time.Second)))
+		return &LimitError{Key: key, RetryAfter: wait}
+	}
+	b.tokens--
+	return nil
+}
+
+// Reset forgets key, restoring its full burst, for example after a
+// successful login.
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.buckets, key)
+}
+
+func (l *Limiter) now() time.Time {
+	if l.Clock == nil {
+		return pbkdf2.SystemClock.Now()
+	}
+	return l.Clock.Now()
+}
+
+func (l *Limiter) refill(b *bucket, now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(l.burst, b.tokens+elapsed.Seconds()*l.rate)
+		b.last = now
+	}
+}
+
+// sweep removes buckets that have refilled, which are indistinguishable from
+// new ones.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// A PasswordVerifier verifies passwords against hashes. It is satisfied by
+// *pbkdf2.Hasher, *pepper.Hasher and crypt.Verifier.
+type PasswordVerifier interface {
+	Verify(password, hash string) error
+}
+
+// A Verifier decorates a PasswordVerifier with a Limiter.
+type Verifier struct {
+	Verifier PasswordVerifier
+	Limiter  *Limiter
+
+	// ResetOnSuccess, if true, resets the key's bucket when the password
+	// matches, so that only failed attempts are throttled over time.
+	ResetOnSuccess bool
+}
+
+// Verify takes a token for key and, if one was available, verifies password
+// against hash. Otherwise it returns a *LimitError without verifying.
+func (v *Verifier) Verify(key, password, hash string) error {
+	if err := v.Limiter.Allow(key); err != nil {
+		return err
+	}
+	err := v.Verifier.Verify(password, hash)
+	if err == nil && v.ResetOnSuccess {
+		v.Limiter.Reset(key)
+	}
+	return err
+}
+
+// DefaultKeyLabel is the label Middleware limits by when given no key
+// function: the client ID set by the service package.
+const DefaultKeyLabel = "client"
+
+// Middleware returns a pbkdf2.Middleware that takes a token from l for the
+// key key(ctx) before every verification, rejecting the attempt with a
+// *LimitError without calling the next Verifier if none is available. If key
+// is nil, the DefaultKeyLabel label of the context is used; see
+// pbkdf2.WithLabels. Attempts with an empty key are rejected with ErrNoKey,
+// rather than sharing one limit that any client could exhaust for the rest.
+func Middleware(l *Limiter, key func(ctx context.Context) string) pbkdf2.Middleware {
+	if key == nil {
+		key = func(ctx context.Context) string {
+			return pbkdf2.LabelsFromContext(ctx)[DefaultKeyLabel]
+		}
+	}
+	return func(next pbkdf2.Verifier) pbkdf2.Verifier {
+		return pbkdf2.VerifierFunc(func(ctx context.Context, password, hash string) error {
+			k := key(ctx)
+			if k == "" {
+				return ErrNoKey
+			}
+			if err := l.Allow(k); err != nil {
+				return err
+			}
+			return next.VerifyContext(ctx, password, hash)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+func newTestLimiter(rate float64, burst int) (*Limiter, *pbkdf2.FakeClock) {
+	clock := pbkdf2.NewFakeClock(time.Unix(0, 0))
+	l := NewLimiter(rate, burst)
+	l.Clock = clock
+	return l, clock
+}
+
+func TestLimiter(t *testing.T) {
+	l, clock := newTestLimiter(0.5, 2)
+	for i := 0; i < 2; i++ {
+		if err := l.Allow("alice"); err != nil {
+			t.Fatalf("attempt %d: %v", i, err)
+		}
+	}
+
+	err := l.Allow("alice")
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected a *LimitError, got %v", err)
+	}
+	if limitErr.Key != "alice" || limitErr.RetryAfter != 2*time.Second {
+		t.Errorf("unexpected error %+v", limitErr)
+	}
+	if err := l.Allow("bob"); err != nil {
+		t.Errorf("keys are not independent: %v", err)
+	}
+
+	clock.Advance(2 * time.Second)
+	if err := l.Allow("alice"); err != nil {
+		t.Errorf("bucket did not refill: %v", err)
+	}
+	if err := l.Allow("alice"); err == nil {
+		t.Error("expected the refilled bucket to hold one token")
+	}
+
+	l.Reset("alice")
+	if err := l.Allow("alice"); err != nil {
+		t.Errorf("Reset did not restore the bucket: %v", err)
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l, clock := newTestLimiter(1, 1)
+	for i := 0; i < sweepThreshold; i++ {
+		l.Allow(strconv.Itoa(i))
+	}
+	clock.Advance(time.Second)
+	l.Allow("new")
+	if len(l.buckets) != 1 {
+		t.Errorf("expected refilled buckets to be swept, %d remain", len(l.buckets))
+	}
+}
+
+type countingVerifier struct {
+	calls int
+}
+
+func (v *countingVerifier) Verify(password, hash string) error {
+	v.calls++
+	return pbkdf2.Verify(password, hash)
+}
+
+func TestVerifier(t *testing.T) {
+	hash, err := pbkdf2.CreateHash("password", &pbkdf2.Params{Iterations: 1000, SaltLength: 16, KeyLength: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := &countingVerifier{}
+	l, _ := newTestLimiter(1, 2)
+	v := &Verifier{Verifier: inner, Limiter: l, ResetOnSuccess: true}
+
+	if err := v.Verify("alice", "wrong", hash); err != pbkdf2.ErrMismatchedHashAndPassword {
+		t.Fatalf("expected ErrMismatchedHashAndPassword, got %v", err)
+	}
+	if err := v.Verify("alice", "password", hash); err != nil {
+		t.Fatal(err)
+	}
+	// The success reset the bucket, so two more attempts are allowed.
+	for i := 0; i < 2; i++ {
+		if err := v.Verify("alice", "wrong", hash); err != pbkdf2.ErrMismatchedHashAndPassword {
+			t.Fatalf("expected ErrMismatchedHashAndPassword, got %v", err)
+		}
+	}
+	if err := v.Verify("alice", "password", hash); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	if inner.calls != 4 {
+		t.Errorf("expected the rate-limited attempt not to be verified, got %d calls", inner.calls)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	hash, err := pbkdf2.CreateHash("password", &pbkdf2.Params{Iterations: 1000, SaltLength: 16, KeyLength: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, _ := newTestLimiter(1, 1)
+	v := pbkdf2.Chain(&pbkdf2.Hasher{}, Middleware(l, nil))
+
+	alice := pbkdf2.WithLabels(context.Background(), map[string]string{DefaultKeyLabel: "alice"})
+	bob := pbkdf2.WithLabels(context.Background(), map[string]string{DefaultKeyLabel: "bob"})
+	if err := v.VerifyContext(alice, "password", hash); err != nil {
+		t.Fatal(err)
+	}
+	var limitErr *LimitError
+	if err := v.VerifyContext(alice, "password", hash); !errors.As(err, &limitErr) || limitErr.Key != "alice" {
+		t.Fatalf("expected alice to be limited, got %v", err)
+	}
+	if err := v.VerifyContext(bob, "password", hash); err != nil {
+		t.Fatalf("expected bob to be limited separately, got %v", err)
+	}
+	if err := v.VerifyContext(context.Background(), "password", hash); err != ErrNoKey {
+		t.Fatalf("expected ErrNoKey without a client label, got %v", err)
+	}
+}
+
+func TestNewLimiterPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	NewLimiter(0, 1)
+}
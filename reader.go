@@ -0,0 +1,118 @@
+package pbkdf2
+
+import (
+	"io"
+)
+
+// readChunkSize is the size of the buffer used to stream long inputs into the
+// PRF.
+const readChunkSize = 32 << 10
+
+// ReadPassword reads a password, such as the contents of a keyfile, from r
+// until EOF, and returns key material that derives the same keys with variant
+// as the whole input would, without buffering all of it.
+//
+// HMAC hashes keys longer than the block size of its hash function before
+// use, so PBKDF2 over such a key equals PBKDF2 over its digest. ReadPassword
+// returns inputs of up to one block as they are, and the digest of longer
+// ones, computed as the input is read. The result can be passed to DeriveKey
+// and CreateHashSecure with params of the same variant, but not with others.
+// The hash function's internal state cannot be wiped, although the buffers
+// ReadPassword uses are.
+//
+// It returns ErrIncompatibleVariant if variant is not supported, and any
+// error from r.
+func ReadPassword(r io.Reader, variant string) (*SecureBytes, error) {
+	prf, ok := variantPRF(variant)
+	if !ok {
+		return nil, ErrIncompatibleVariant
+	}
+	h := prf()
+
+	// Read one byte more than a block, to find out whether the input fits.
+	head := make([]byte, h.BlockSize()+1)
+	n, err := io.ReadFull(r, head)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return NewSecureBytes(head[:n]), nil
+	}
+	defer wipe(head)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(head)
+
+	buf := make([]byte, readChunkSize)
+	defer wipe(buf)
+	for {
+		n, err := r.Read(buf)
+		h.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewSecureBytes(h.Sum(nil)), nil
+}
+
+// DeriveKeyFromReader is like DeriveKey, except the password is read from r
+// with ReadPassword.
+func DeriveKeyFromReader(r io.Reader, salt []byte, params *Params) (*SecureBytes, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	password, err := ReadPassword(r, params.Variant)
+	if err != nil {
+		return nil, err
+	}
+	defer password.Destroy()
+
+	return DeriveKey(password, salt, params)
+}
+
+// CreateHashFromReader is like CreateHash, except the password is read from r
+// with ReadPassword. The hash verifies against the whole input, whether given
+// to VerifyReader or as a string to Verify.
+func CreateHashFromReader(r io.Reader, params *Params) (hash string, err error) {
+	if params == nil {
+		params = GetDefaultParams()
+	}
+	if err := params.Validate(); err != nil {
+		return "", err
+	}
+	password, err := ReadPassword(r, params.Variant)
+	if err != nil {
+		return "", err
+	}
+	defer password.Destroy()
+
+	return CreateHashSecure(password, params)
+}
+
+// VerifyReader is like Verify, except the password is read from r with
+// ReadPassword. If the hash records a normalization, which must apply to the
+// whole input, the input is read into memory in full instead.
+func VerifyReader(r io.Reader, hash string) error {
+	h, err := decodeHash(hash)
+	if err != nil {
+		return err
+	}
+	wipe(h.Salt)
+	wipe(h.Key)
+
+	var password *SecureBytes
+	if transformsOf(h.Metadata).normalization != "" {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			wipe(b)
+			return err
+		}
+		password = NewSecureBytes(b)
+	} else if password, err = ReadPassword(r, h.Params.Variant); err != nil {
+		return err
+	}
+	defer password.Destroy()
+
+	return VerifySecure(password, hash)
+}
@@ -0,0 +1,93 @@
+package pbkdf2
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDeriveKeyFromReader(t *testing.T) {
+	salt := []byte("saltsaltsaltsalt")
+	for _, variant := range []string{"", VariantSHA256, VariantSHA512_256, VariantSHA3_512, VariantBLAKE2b} {
+		params := &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32, Variant: variant}
+		// Lengths around the block sizes of 64, 72 and 128 bytes, and one
+		// longer than the read buffer.
+		for _, n := range []int{0, 10, 64, 65, 72, 73, 128, 129, 3*readChunkSize + 7} {
+			input := bytes.Repeat([]byte("keyfile-"), n/8+1)[:n]
+			want, err := DeriveKey(NewSecureBytes(append([]byte(nil), input...)), salt, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Short reads exercise the read loop.
+			got, err := DeriveKeyFromReader(&shortReader{bytes.NewReader(input)}, salt, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Bytes(), want.Bytes()) {
+				t.Errorf("variant %q, length %d: key mismatch", variant, n)
+			}
+		}
+	}
+}
+
+// shortReader returns at most 1000 bytes from each Read.
+type shortReader struct {
+	r io.Reader
+}
+
+func (r *shortReader) Read(p []byte) (int, error) {
+	if len(p) > 1000 {
+		p = p[:1000]
+	}
+	return r.r.Read(p)
+}
+
+func TestCreateHashFromReader(t *testing.T) {
+	params := &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32}
+	keyfile := strings.Repeat("0123456789abcdef", 1000)
+	hash, err := CreateHashFromReader(strings.NewReader(keyfile), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(keyfile, hash); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if err := VerifyReader(strings.NewReader(keyfile), hash); err != nil {
+		t.Errorf("VerifyReader: %v", err)
+	}
+	if err := VerifyReader(strings.NewReader(keyfile[1:]), hash); err != ErrMismatchedHashAndPassword {
+		t.Errorf("expected ErrMismatchedHashAndPassword, got %v", err)
+	}
+
+	hash, err = CreateHash("short", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyReader(strings.NewReader("short"), hash); err != nil {
+		t.Errorf("VerifyReader short password: %v", err)
+	}
+}
+
+func TestReadPasswordErrors(t *testing.T) {
+	if _, err := ReadPassword(strings.NewReader("x"), VariantLegacySHA1); err != ErrIncompatibleVariant {
+		t.Errorf("expected ErrIncompatibleVariant, got %v", err)
+	}
+	errRead := errors.New("read failed")
+	r := io.MultiReader(strings.NewReader(strings.Repeat("x", 200)), &failingReader{errRead})
+	if _, err := ReadPassword(r, ""); err != errRead {
+		t.Errorf("expected read error, got %v", err)
+	}
+	if _, err := CreateHashFromReader(&failingReader{errRead}, nil); err != errRead {
+		t.Errorf("expected read error, got %v", err)
+	}
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,413 @@
+// Package redisstore implements store.Store on top of Redis, so that stateless
+// services can share credentials without a relational database.
+//
+// Each user is a Redis hash at Prefix+username, with the encoded password hash
+// in its "hash" field:
+//
+//	HSET pbkdf2:user:alice hash "$pbkdf2-sha512$210000$..."
+//
+// Other fields of the Redis hash are left untouched, so applications may keep
+// further attributes of the user alongside it. Update performs
+// read-modify-write cycles, such as upgrading a hash after login, with
+// optimistic locking via WATCH, so that concurrent writers never lose each
+// other's changes:
+//
+//	s := &redisstore.Store{Addr: "redis:6379", TTL: 90 * 24 * time.Hour}
+//	defer s.Close()
+//	err := s.Update(ctx, username, func(hash string) (string, error) {
+//		return hasher.ConvertHash(password, hash)
+//	})
+//
+// The package includes its own minimal client for the Redis protocol, so it
+// has no further dependencies. TLS connections can be made with Store.Dial.
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pganguli/pbkdf2/store"
+)
+
+// Defaults used when the corresponding Store field is zero.
+const (
+	DefaultAddr         = "localhost:6379"
+	DefaultPrefix       = "pbkdf2:user:"
+	DefaultMaxIdleConns = 2
+	DefaultMaxRetries   = 10
+)
+
+// hashField is the field of the per-user Redis hash holding the password hash.
+const hashField = "hash"
+
+// scanCount is the COUNT hint passed to SCAN by Range.
+const scanCount = "100"
+
+// ErrConflict is returned by Update if the user was modified concurrently on
+// every attempt.
+var ErrConflict = errors.New("redisstore: too many concurrent modifications")
+
+// A Store is a store.Store held in Redis. The zero value connects to
+// DefaultAddr. It is safe for concurrent use, and holds a small pool of
+// connections, which Close releases.
+type Store struct {
+	// Addr is the host and port of the Redis server.
+	Addr string
+
+	// Username and Password, if Password is set, authenticate each
+	// connection with AUTH. Username is only needed for Redis 6 ACLs.
+	Username string
+	Password string
+
+	// DB is the database selected on each connection.
+	DB int
+
+	// Prefix is prepended to usernames to form keys.
+	Prefix string
+
+	// TTL, if positive, is set as the expiry of a user's key whenever its
+	// hash is written, so that unused credentials lapse. An existing expiry
+	// is kept when TTL is zero.
+	TTL time.Duration
+
+	// Dial, if set, opens connections to the server, for example with TLS.
+	// Otherwise a net.Dialer is used.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// MaxIdleConns is the number of connections kept for reuse.
+	MaxIdleConns int
+
+	// MaxRetries is the number of times Update retries after a concurrent
+	// modification.
+	MaxRetries int
+
+	mu   sync.Mutex
+	idle []*conn
+}
+
+var _ store.Store = (*Store)(nil)
+
+func (s *Store) key(username string) string {
+	if s.Prefix == "" {
+		return DefaultPrefix + username
+	}
+	return s.Prefix + username
+}
+
+// Get implements store.Store.
+func (s *Store) Get(ctx context.Context, username string) (string, error) {
+	var hash string
+	err := s.with(ctx, func(c *conn) error {
+		v, err := c.do(ctx, "HGET", s.key(username), hashField)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			return store.ErrNotFound
+		}
+		var ok bool
+		if hash, ok = v.(string); !ok {
+			return errProtocol
+		}
+		return nil
+	})
+	return hash, err
+}
+
+// Put implements store.Store.
+func (s *Store) Put(ctx context.Context, username, hash string) error {
+	return s.with(ctx, func(c *conn) error {
+		ok, err := s.write(ctx, c, s.key(username), hash)
+		if err == nil && !ok {
+			// Only possible if the server discarded the transaction.
+			err = errProtocol
+		}
+		return err
+	})
+}
+
+// Update atomically replaces the hash of username with the result of fn,
+// which is called with the current hash. If the user is modified by another
+// client before the new hash is written, fn is called again with the new
+// current hash, up to MaxRetries times before Update returns ErrConflict. It
+// returns store.ErrNotFound if the user does not exist, and any error from fn
+// without writing.
+func (s *Store) Update(ctx context.Context, username string, fn func(hash string) (string, error)) error {
+	retries := s.MaxRetries
+	if retries <= 0 {
+		retries = DefaultMaxRetries
+	}
+	key := s.key(username)
+
+	return s.with(ctx, func(c *conn) error {
+		err := s.update(ctx, c, key, fn, retries)
+		if err != nil && !c.broken {
+			// Leave no keys watched on a connection returned to the pool.
+			c.do(ctx, "UNWATCH")
+		}
+		return err
+	})
+}
+
+func (s *Store) update(ctx context.Context, c *conn, key string, fn func(string) (string, error), retries int) error {
+	for attempt := 0; attempt <= retries; attempt++ {
+		if _, err := c.do(ctx, "WATCH", key); err != nil {
+			return err
+		}
+		v, err := c.do(ctx, "HGET", key, hashField)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			return store.ErrNotFound
+		}
+		old, ok := v.(string)
+		if !ok {
+			return errProtocol
+		}
+
+		hash, err := fn(old)
+		if err != nil {
+			return err
+		}
+		if ok, err := s.write(ctx, c, key, hash); err != nil || ok {
+			return err
+		}
+	}
+	return ErrConflict
+}
+
+// write sets the hash field of key, and its expiry, in a transaction. It
+// reports false if the transaction was aborted because a watched key changed.
+func (s *Store) write(ctx context.Context, c *conn, key, hash string) (bool, error) {
+	c.send("MULTI")
+	c.send("HSET", key, hashField, hash)
+	queued := 1
+	if s.TTL > 0 {
+		ms := s.TTL.Milliseconds()
+		if ms == 0 {
+			ms = 1
+		}
+		c.send("PEXPIRE", key, strconv.FormatInt(ms, 10))
+		queued++
+	}
+	c.send("EXEC")
+
+	// Read every reply, so that the connection stays in step, keeping the
+	// first error.
+	var firstErr error
+	for i := 0; i < 1+queued; i++ {
+		if _, err := c.receive(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if c.broken {
+			return false, firstErr
+		}
+	}
+	v, err := c.receive(ctx)
+	if firstErr != nil {
+		return false, firstErr
+	}
+	if err != nil {
+		return false, err
+	}
+	if v == nil {
+		return false, nil
+	}
+	results, ok := v.([]interface{})
+	if !ok || len(results) != queued {
+		return false, errProtocol
+	}
+	for _, r := range results {
+		if e, ok := r.(Error); ok {
+			return false, e
+		}
+	}
+	return true, nil
+}
+
+// Delete implements store.Store. It removes only the hash field, so the key
+// itself is removed only if the user has no other fields.
+func (s *Store) Delete(ctx context.Context, username string) error {
+	return s.with(ctx, func(c *conn) error {
+		v, err := c.do(ctx, "HDEL", s.key(username), hashField)
+		if err != nil {
+			return err
+		}
+		if n, ok := v.(int64); !ok {
+			return errProtocol
+		} else if n == 0 {
+			return store.ErrNotFound
+		}
+		return nil
+	})
+}
+
+// Range implements store.Store, visiting users in no particular order. It
+// iterates with SCAN, so it does not block the server, and visits each user
+// present for the whole iteration exactly once.
+func (s *Store) Range(ctx context.Context, fn func(username, hash string) error) error {
+	prefix := s.key("")
+	pattern := escapeGlob(prefix) + "*"
+	seen := make(map[string]struct{})
+
+	return s.with(ctx, func(c *conn) error {
+		cursor := "0"
+		for {
+			v, err := c.do(ctx, "SCAN", cursor, "MATCH", pattern, "COUNT", scanCount)
+			if err != nil {
+				return err
+			}
+			reply, ok := v.([]interface{})
+			if !ok || len(reply) != 2 {
+				return errProtocol
+			}
+			keys, ok := reply[1].([]interface{})
+			if cursor, ok = reply[0].(string); !ok || keys == nil {
+				return errProtocol
+			}
+
+			for _, k := range keys {
+				key, ok := k.(string)
+				if !ok || !strings.HasPrefix(key, prefix) {
+					return errProtocol
+				}
+				// SCAN may return a key more than once.
+				if _, ok := seen[key]; ok {
+					continue
+				}
+				seen[key] = struct{}{}
+
+				v, err := c.do(ctx, "HGET", key, hashField)
+				if err != nil {
+					return err
+				}
+				if v == nil {
+					// Deleted since the scan, or holds no hash.
+					continue
+				}
+				hash, ok := v.(string)
+				if !ok {
+					return errProtocol
+				}
+				if err := fn(key[len(prefix):], hash); err != nil {
+					return err
+				}
+			}
+			if cursor == "0" {
+				return nil
+			}
+		}
+	})
+}
+
+// escapeGlob escapes the characters special to Redis glob patterns.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Close closes the idle connections. The Store may still be used afterwards,
+// in which case it opens new ones.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	idle := s.idle
+	s.idle = nil
+	s.mu.Unlock()
+
+	var firstErr error
+	for _, c := range idle {
+		if err := c.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// with calls fn with a connection from the pool, returning it afterwards
+// unless it broke.
+func (s *Store) with(ctx context.Context, fn func(*conn) error) error {
+	c, err := s.get(ctx)
+	if err != nil {
+		return err
+	}
+	err = fn(c)
+	s.put(c)
+	return err
+}
+
+func (s *Store) get(ctx context.Context) (*conn, error) {
+	s.mu.Lock()
+	if n := len(s.idle); n > 0 {
+		c := s.idle[n-1]
+		s.idle = s.idle[:n-1]
+		s.mu.Unlock()
+		return c, nil
+	}
+	s.mu.Unlock()
+
+	addr := s.Addr
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	dial := s.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	nc, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	c := newConn(nc)
+
+	if s.Password != "" {
+		args := []string{"AUTH", s.Password}
+		if s.Username != "" {
+			args = []string{"AUTH", s.Username, s.Password}
+		}
+		if _, err := c.do(ctx, args...); err != nil {
+			c.close()
+			return nil, err
+		}
+	}
+	if s.DB != 0 {
+		if _, err := c.do(ctx, "SELECT", strconv.Itoa(s.DB)); err != nil {
+			c.close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (s *Store) put(c *conn) {
+	if c.broken {
+		c.close()
+		return
+	}
+	max := s.MaxIdleConns
+	if max <= 0 {
+		max = DefaultMaxIdleConns
+	}
+
+	s.mu.Lock()
+	if len(s.idle) < max {
+		s.idle = append(s.idle, c)
+		c = nil
+	}
+	s.mu.Unlock()
+	if c != nil {
+		c.close()
+	}
+}
@@ -0,0 +1,514 @@
+package redisstore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pganguli/pbkdf2/store"
+)
+
+// fakeServer is an in-memory server implementing the commands used by Store,
+// with the semantics of Redis for hashes, expiry and transactions.
+type fakeServer struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	hashes   map[string]map[string]string
+	versions map[string]int
+	expiry   map[string]time.Duration
+
+	// beforeExec, if set, is called before each EXEC, to simulate
+	// concurrent clients.
+	beforeExec func(f *fakeServer)
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeServer{
+		ln:       ln,
+		hashes:   make(map[string]map[string]string),
+		versions: make(map[string]int),
+		expiry:   make(map[string]time.Duration),
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(c)
+		}
+	}()
+	return f
+}
+
+func (f *fakeServer) addr() string {
+	return f.ln.Addr().String()
+}
+
+// hset sets a field as another client would.
+func (f *fakeServer) hset(key, field, value string) {
+	if f.hashes[key] == nil {
+		f.hashes[key] = make(map[string]string)
+	}
+	f.hashes[key][field] = value
+	f.versions[key]++
+}
+
+func (f *fakeServer) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+
+	var watched map[string]int
+	var queue [][]string
+	inMulti := false
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		name := strings.ToUpper(args[0])
+
+		f.mu.Lock()
+		switch {
+		case name == "MULTI":
+			inMulti = true
+			w.WriteString("+OK\r\n")
+		case name == "EXEC":
+			if f.beforeExec != nil {
+				f.beforeExec(f)
+			}
+			aborted := false
+			for key, v := range watched {
+				aborted = aborted || f.versions[key] != v
+			}
+			if aborted {
+				w.WriteString("*-1\r\n")
+			} else {
+				w.WriteString("*" + strconv.Itoa(len(queue)) + "\r\n")
+				for _, cmd := range queue {
+					f.exec(w, cmd)
+				}
+			}
+			watched, queue, inMulti = nil, nil, false
+		case inMulti:
+			queue = append(queue, args)
+			w.WriteString("+QUEUED\r\n")
+		case name == "WATCH":
+			if watched == nil {
+				watched = make(map[string]int)
+			}
+			for _, key := range args[1:] {
+				watched[key] = f.versions[key]
+			}
+			w.WriteString("+OK\r\n")
+		case name == "UNWATCH":
+			watched = nil
+			w.WriteString("+OK\r\n")
+		default:
+			f.exec(w, args)
+		}
+		f.mu.Unlock()
+
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeServer) exec(w *bufio.Writer, args []string) {
+	switch strings.ToUpper(args[0]) {
+	case "AUTH":
+		if args[len(args)-1] != "secret" {
+			w.WriteString("-WRONGPASS invalid username-password pair\r\n")
+			return
+		}
+		w.WriteString("+OK\r\n")
+	case "SELECT":
+		w.WriteString("+OK\r\n")
+	case "HGET":
+		v, ok := f.hashes[args[1]][args[2]]
+		if !ok {
+			w.WriteString("$-1\r\n")
+			return
+		}
+		writeBulk(w, v)
+	case "HSET":
+		f.hset(args[1], args[2], args[3])
+		w.WriteString(":1\r\n")
+	case "HDEL":
+		if _, ok := f.hashes[args[1]][args[2]]; !ok {
+			w.WriteString(":0\r\n")
+			return
+		}
+		delete(f.hashes[args[1]], args[2])
+		if len(f.hashes[args[1]]) == 0 {
+			delete(f.hashes, args[1])
+			delete(f.expiry, args[1])
+		}
+		f.versions[args[1]]++
+		w.WriteString(":1\r\n")
+	case "PEXPIRE":
+		ms, _ := strconv.ParseInt(args[2], 10, 64)
+		f.expiry[args[1]] = time.Duration(ms) * time.Millisecond
+		w.WriteString(":1\r\n")
+	case "SCAN":
+		// Return one key per call, repeating the first, to exercise
+		// cursors and duplicates.
+		var keys []string
+		for key := range f.hashes {
+			if ok, _ := path.Match(args[3], key); ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		cursor, _ := strconv.Atoi(args[1])
+		if cursor >= len(keys) {
+			w.WriteString("*2\r\n$1\r\n0\r\n*0\r\n")
+			return
+		}
+		next := strconv.Itoa(cursor + 1)
+		if cursor+1 == len(keys) {
+			next = "0"
+		}
+		w.WriteString("*2\r\n")
+		writeBulk(w, next)
+		w.WriteString("*2\r\n")
+		writeBulk(w, keys[cursor])
+		writeBulk(w, keys[0])
+	default:
+		w.WriteString("-ERR unknown command '" + args[0] + "'\r\n")
+	}
+}
+
+func writeBulk(w *bufio.Writer, s string) {
+	w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || line[0] != '*' {
+		return nil, errProtocol
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+	return args, nil
+}
+
+func TestStore(t *testing.T) {
+	f := newFakeServer(t)
+	s := &Store{Addr: f.addr(), Password: "secret", DB: 1, TTL: time.Hour}
+	defer s.Close()
+	ctx := context.Background()
+
+	if _, err := s.Get(ctx, "alice"); err != store.ErrNotFound {
+		t.Fatalf("Get missing: err = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, "alice"); err != store.ErrNotFound {
+		t.Fatalf("Delete missing: err = %v, want ErrNotFound", err)
+	}
+	if err := s.Put(ctx, "alice", "h1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, "bob", "h2"); err != nil {
+		t.Fatal(err)
+	}
+	if hash, err := s.Get(ctx, "alice"); err != nil || hash != "h1" {
+		t.Fatalf("Get = %q, %v", hash, err)
+	}
+
+	f.mu.Lock()
+	ttl := f.expiry[DefaultPrefix+"alice"]
+	f.mu.Unlock()
+	if ttl != time.Hour {
+		t.Errorf("expiry = %v, want 1h", ttl)
+	}
+
+	// Other fields of the user are preserved by Delete.
+	f.mu.Lock()
+	f.hset(DefaultPrefix+"bob", "email", "bob@example.com")
+	f.mu.Unlock()
+
+	got := map[string]string{}
+	err := s.Range(ctx, func(username, hash string) error {
+		if _, ok := got[username]; ok {
+			t.Errorf("Range visited %q twice", username)
+		}
+		got[username] = hash
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["alice"] != "h1" || got["bob"] != "h2" {
+		t.Errorf("Range visited %v", got)
+	}
+
+	if err := s.Delete(ctx, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, "bob"); err != store.ErrNotFound {
+		t.Errorf("Get deleted: err = %v, want ErrNotFound", err)
+	}
+	f.mu.Lock()
+	email := f.hashes[DefaultPrefix+"bob"]["email"]
+	f.mu.Unlock()
+	if email != "bob@example.com" {
+		t.Errorf("Delete removed other fields")
+	}
+}
+
+func TestStoreBulk(t *testing.T) {
+	f := newFakeServer(t)
+	s := &Store{Addr: f.addr(), Prefix: "app:[users]:"}
+	defer s.Close()
+	ctx := context.Background()
+
+	input := "username,hash\nalice,h1\nbob,h2\ncarol,h3\n"
+	if _, err := store.Import(ctx, s, strings.NewReader(input), store.ImportOptions{
+		Format:   store.CSV,
+		Validate: func(string) error { return nil },
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	n, err := store.Export(ctx, s, &out, store.CSV, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("exported %d records, want 3", n)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	f := newFakeServer(t)
+	s := &Store{Addr: f.addr(), MaxRetries: 2}
+	defer s.Close()
+	ctx := context.Background()
+
+	if err := s.Update(ctx, "alice", func(string) (string, error) { return "x", nil }); err != store.ErrNotFound {
+		t.Fatalf("Update missing: err = %v, want ErrNotFound", err)
+	}
+	if err := s.Put(ctx, "alice", "h1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A concurrent write before the first EXEC forces a retry with the new
+	// hash.
+	conflicts := 1
+	f.mu.Lock()
+	f.beforeExec = func(f *fakeServer) {
+		if conflicts > 0 {
+			conflicts--
+			f.hset(DefaultPrefix+"alice", hashField, "h2")
+		}
+	}
+	f.mu.Unlock()
+	var seen []string
+	err := s.Update(ctx, "alice", func(hash string) (string, error) {
+		seen = append(seen, hash)
+		return hash + "+", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(seen, ",") != "h1,h2" {
+		t.Errorf("fn called with %v, want [h1 h2]", seen)
+	}
+	if hash, _ := s.Get(ctx, "alice"); hash != "h2+" {
+		t.Errorf("hash = %q, want h2+", hash)
+	}
+
+	// Continual conflicts exhaust the retries.
+	f.mu.Lock()
+	f.beforeExec = func(f *fakeServer) { f.hset(DefaultPrefix+"alice", hashField, "h3") }
+	f.mu.Unlock()
+	calls := 0
+	err = s.Update(ctx, "alice", func(hash string) (string, error) {
+		calls++
+		return "mine", nil
+	})
+	if err != ErrConflict {
+		t.Errorf("err = %v, want ErrConflict", err)
+	}
+	if calls != 3 {
+		t.Errorf("fn called %d times, want 3", calls)
+	}
+
+	// An error from fn aborts without writing.
+	f.mu.Lock()
+	f.beforeExec = nil
+	f.mu.Unlock()
+	errStop := errors.New("stop")
+	if err := s.Update(ctx, "alice", func(string) (string, error) { return "", errStop }); err != errStop {
+		t.Errorf("err = %v, want errStop", err)
+	}
+	if hash, _ := s.Get(ctx, "alice"); hash != "h3" {
+		t.Errorf("hash = %q, want h3", hash)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	f := newFakeServer(t)
+	ctx := context.Background()
+
+	s := &Store{Addr: f.addr(), Password: "wrong"}
+	var e Error
+	if _, err := s.Get(ctx, "alice"); !errors.As(err, &e) || !strings.HasPrefix(string(e), "WRONGPASS") {
+		t.Errorf("bad password: err = %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	s = &Store{Addr: f.addr()}
+	if err := s.Put(canceled, "alice", "h1"); err != context.Canceled {
+		t.Errorf("canceled: err = %v, want context.Canceled", err)
+	}
+}
+
+func TestCanceledBeforeSend(t *testing.T) {
+	f := newFakeServer(t)
+	s := &Store{Addr: f.addr()}
+	defer s.Close()
+	ctx := context.Background()
+	if err := s.Put(ctx, "alice", "h1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, "bob", "h2"); err != nil {
+		t.Fatal(err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	// Each call finds a pooled connection and buffers its command before
+	// seeing that ctx is done; the reply must not be left for the next call.
+	calls := map[string]func() error{
+		"Get": func() error {
+			_, err := s.Get(canceled, "alice")
+			return err
+		},
+		"Put": func() error {
+			return s.Put(canceled, "alice", "h3")
+		},
+		"Update": func() error {
+			return s.Update(canceled, "alice", func(string) (string, error) { return "h3", nil })
+		},
+	}
+	for name, call := range calls {
+		if err := call(); err != context.Canceled {
+			t.Errorf("%s: err = %v, want context.Canceled", name, err)
+		}
+		if hash, err := s.Get(ctx, "bob"); err != nil || hash != "h2" {
+			t.Errorf("Get after canceled %s = %q, %v, want h2", name, hash, err)
+		}
+		if hash, err := s.Get(ctx, "alice"); err != nil || hash != "h1" {
+			t.Errorf("Get after canceled %s = %q, %v, want h1", name, hash, err)
+		}
+	}
+}
+
+func TestCancelPending(t *testing.T) {
+	// A server that accepts connections but never replies.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err == nil {
+			accepted <- c
+		}
+	}()
+	defer func() {
+		ln.Close()
+		select {
+		case c := <-accepted:
+			c.Close()
+		default:
+		}
+	}()
+
+	s := &Store{Addr: ln.Addr().String()}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := s.Get(ctx, "alice"); err != context.DeadlineExceeded {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if len(s.idle) != 0 {
+		t.Error("broken connection returned to the pool")
+	}
+}
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{"+OK\r\n", "OK"},
+		{":42\r\n", int64(42)},
+		{"$3\r\nfoo\r\n", "foo"},
+		{"$-1\r\n", nil},
+		{"*-1\r\n", nil},
+		{"-ERR bad\r\n", Error("ERR bad")},
+	}
+	for _, tt := range tests {
+		c := &conn{r: bufio.NewReader(strings.NewReader(tt.input))}
+		got, err := c.readReply(0)
+		if err != nil || got != tt.want {
+			t.Errorf("readReply(%q) = %v, %v, want %v", tt.input, got, err, tt.want)
+		}
+	}
+
+	for _, input := range []string{
+		"",
+		"\r\n",
+		"?x\r\n",
+		":x\r\n",
+		"$5\r\nfoo\r\n",
+		"$3\r\nfooxx",
+		"$99999999999\r\n",
+		"*2\r\n:1\r\n",
+		strings.Repeat("*1\r\n", 20) + ":1\r\n",
+	} {
+		c := &conn{r: bufio.NewReader(strings.NewReader(input))}
+		if _, err := c.readReply(0); err == nil {
+			t.Errorf("readReply(%q) succeeded", input)
+		}
+	}
+}
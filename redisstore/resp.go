@@ -0,0 +1,199 @@
+package redisstore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// The client speaks the subset of RESP2 needed by Store: commands are sent as
+// arrays of bulk strings, and replies are decoded as string (simple and bulk
+// strings), int64, nil (null bulk strings and arrays), []interface{} and
+// Error.
+
+// maxBulkLength bounds the length of bulk strings and arrays in replies, so
+// that a misbehaving server cannot make the client allocate without limit.
+const maxBulkLength = 64 << 20
+
+var errProtocol = errors.New("redisstore: malformed reply from server")
+
+// Error is an error reply from the Redis server.
+type Error string
+
+func (e Error) Error() string {
+	return "redisstore: " + string(e)
+}
+
+// conn is a connection to the server. It is not safe for concurrent use.
+type conn struct {
+	c net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+
+	// broken is set after an I/O or protocol error, after which the
+	// connection must not be reused.
+	broken bool
+}
+
+func newConn(c net.Conn) *conn {
+	return &conn{c: c, r: bufio.NewReader(c), w: bufio.NewWriter(c)}
+}
+
+// do sends a command and returns its reply. Error replies are returned as an
+// Error, and leave the connection usable.
+func (c *conn) do(ctx context.Context, args ...string) (interface{}, error) {
+	c.send(args...)
+	return c.receive(ctx)
+}
+
+// send buffers a command without flushing it, so that several can be
+// pipelined. Write errors are reported by the next receive.
+func (c *conn) send(args ...string) {
+	c.w.WriteByte('*')
+	c.w.WriteString(strconv.Itoa(len(args)))
+	c.w.WriteString("\r\n")
+	for _, arg := range args {
+		c.w.WriteByte('$')
+		c.w.WriteString(strconv.Itoa(len(arg)))
+		c.w.WriteString("\r\n")
+		c.w.WriteString(arg)
+		c.w.WriteString("\r\n")
+	}
+}
+
+// receive flushes buffered commands and reads one reply, honouring the
+// deadline and cancellation of ctx.
+func (c *conn) receive(ctx context.Context) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		// The command awaiting this reply was buffered, or already sent,
+		// so the connection is out of step with the server.
+		c.broken = true
+		return nil, err
+	}
+	// The deadline of ctx is enforced through ctx.Done rather than as the
+	// connection's deadline, so that I/O never times out before ctx reports
+	// why.
+	c.c.SetDeadline(time.Time{})
+	if ctx.Done() != nil {
+		stop, stopped := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(stopped)
+			select {
+			case <-ctx.Done():
+				// Unblock any pending I/O.
+				c.c.SetDeadline(time.Unix(1, 0))
+			case <-stop:
+			}
+		}()
+		defer func() {
+			close(stop)
+			<-stopped
+		}()
+	}
+
+	if err := c.w.Flush(); err != nil {
+		c.broken = true
+		return nil, contextError(ctx, err)
+	}
+	v, err := c.readReply(0)
+	if err != nil {
+		c.broken = true
+		return nil, contextError(ctx, err)
+	}
+	if e, ok := v.(Error); ok {
+		return nil, e
+	}
+	return v, nil
+}
+
+// contextError returns the context's error in place of err if the context
+// ended, since ending it is what caused err.
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (c *conn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return "", errProtocol
+	}
+	return line[:len(line)-2], nil
+}
+
+func (c *conn) readReply(depth int) (interface{}, error) {
+	if depth > 8 {
+		return nil, errProtocol
+	}
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	payload := line[1:]
+	switch line[0] {
+	case '+':
+		return payload, nil
+	case '-':
+		return Error(payload), nil
+	case ':':
+		n, err := strconv.ParseInt(payload, 10, 64)
+		if err != nil {
+			return nil, errProtocol
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < -1 || n > maxBulkLength {
+			return nil, errProtocol
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return nil, err
+		}
+		if b[n] != '\r' || b[n+1] != '\n' {
+			return nil, errProtocol
+		}
+		return string(b[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < -1 || n > maxBulkLength {
+			return nil, errProtocol
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		capacity := n
+		if capacity > 1024 {
+			capacity = 1024
+		}
+		array := make([]interface{}, 0, capacity)
+		for i := 0; i < n; i++ {
+			v, err := c.readReply(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, v)
+		}
+		return array, nil
+	}
+	return nil, errProtocol
+}
+
+func (c *conn) close() error {
+	return c.c.Close()
+}
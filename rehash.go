@@ -0,0 +1,38 @@
+package pbkdf2
+
+// NeedsRehash reports whether hash should be replaced with a new hash created
+// with params, once the password has been verified. This is the case if hash
+// cannot be decoded, uses a different variant, has fewer iterations, a shorter
+// salt or a different key length than params, or is a legacy SHA-1 hash. If
+// params is nil, the package-level default params are used.
+func NeedsRehash(hash string, params *Params) bool {
+	if params == nil {
+		params = GetDefaultParams()
+	}
+	if isLegacySHA1(hash) {
+		return true
+	}
+
+	h, err := decodeHash(hash)
+	if err != nil {
+		return true
+	}
+	defer wipe(h.Salt)
+	defer wipe(h.Key)
+
+	return h.Variant() != (&Hash{Params: *params}).Variant() ||
+		h.Params.Iterations < params.Iterations ||
+		h.Params.SaltLength < params.SaltLength ||
+		h.Params.KeyLength != params.KeyLength
+}
+
+// ConvertHash verifies password against oldHash and, if it matches, returns a
+// new hash of password created with newParams, which may change any of the
+// params, including the variant. If newParams is nil, the package-level
+// default params are used. It returns ErrMismatchedHashAndPassword if the
+// password does not match, or any error from decoding oldHash or creating the
+// new hash; in every error case no hash is returned, so the caller can
+// unconditionally store the result on success.
+func ConvertHash(password, oldHash string, newParams *Params) (newHash string, err error) {
+	return (&Hasher{Params: newParams}).ConvertHash(password, oldHash)
+}
@@ -0,0 +1,150 @@
+package pbkdf2
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const (
+	sealedPrefix    = "$sealed$"
+	sealedKeyLength = 32
+	sealedNonceSize = 12
+)
+
+var (
+	// ErrInvalidSealed is returned by Open if the value is not in the format
+	// produced by Seal.
+	ErrInvalidSealed = errors.New("pbkdf2: sealed value is not in the correct format")
+
+	// ErrOpen is returned by Open if the password is wrong, or the sealed
+	// value has been modified. The two cannot be told apart.
+	ErrOpen = errors.New("pbkdf2: wrong password or corrupted sealed value")
+)
+
+// Seal encrypts plaintext, such as a secret in a configuration file, with
+// AES-256-GCM under a key derived from password, and returns a self-contained
+// string holding everything Open needs besides the password:
+//
+//	$sealed$pbkdf2-sha512$210000$<b64Salt>$<b64Nonce>$<b64Ciphertext>
+//
+// The iterations, salt length and variant are those of the package-level
+// default params returned by GetDefaultParams; the key is always 32 bytes.
+// The whole string is authenticated, so changing any part of it makes Open
+// fail.
+func Seal(plaintext []byte, password string) (string, error) {
+	return SealWithParams(plaintext, password, nil)
+}
+
+// SealWithParams is like Seal, using params, or the package-level default
+// params if params is nil. Only their iterations, salt length and variant are
+// used. It returns ErrInvalidParams or ErrIncompatibleVariant if params are
+// not valid.
+func SealWithParams(plaintext []byte, password string, params *Params) (string, error) {
+	if params == nil {
+		params = GetDefaultParams()
+	}
+	if err := params.Validate(); err != nil {
+		return "", err
+	}
+	salt, err := generateRandomBytes(params.SaltLength)
+	if err != nil {
+		return "", err
+	}
+	defer salt.Destroy()
+	nonce, err := generateRandomBytes(sealedNonceSize)
+	if err != nil {
+		return "", err
+	}
+	defer nonce.Destroy()
+
+	variant := params.Variant
+	if variant == "" {
+		variant = VariantSHA512
+	}
+	b := []byte(sealedPrefix + variant + "$")
+	b = strconv.AppendUint(b, uint64(params.Iterations), 10)
+	b = append(b, '$')
+	b = appendBase64(b, salt.Bytes())
+	b = append(b, '$')
+	b = appendBase64(b, nonce.Bytes())
+	b = append(b, '$')
+	header := len(b)
+
+	aead := sealedAEAD(password, salt, &Params{Iterations: params.Iterations, SaltLength: params.SaltLength, KeyLength: sealedKeyLength, Variant: params.Variant})
+	ciphertext := aead.Seal(nil, nonce.Bytes(), plaintext, b[:header])
+	return string(appendBase64(b, ciphertext)), nil
+}
+
+// Open decrypts a value produced by Seal. It returns ErrInvalidSealed if the
+// value is malformed or its params are not valid, and ErrOpen if the password
+// is wrong or the value has been modified.
+func Open(sealed, password string) ([]byte, error) {
+	return OpenWithPolicy(sealed, password, nil)
+}
+
+// OpenWithPolicy is like Open, except it first checks the params of the
+// sealed value against policy, returning a *PolicyError if they violate it,
+// so that a tampered value cannot demand excessive iterations. A nil policy
+// accepts any params.
+func OpenWithPolicy(sealed, password string, policy *Policy) ([]byte, error) {
+	if !strings.HasPrefix(sealed, sealedPrefix) {
+		return nil, ErrInvalidSealed
+	}
+	vals := strings.Split(sealed[len(sealedPrefix):], "$")
+	if len(vals) != 5 {
+		return nil, ErrInvalidSealed
+	}
+	params := &Params{KeyLength: sealedKeyLength}
+	if vals[0] != VariantSHA512 {
+		params.Variant = vals[0]
+	}
+	var err error
+	if params.Iterations, err = parseIterations(vals[1]); err != nil {
+		return nil, ErrInvalidSealed
+	}
+	salt, err := decodeBase64(vals[2])
+	if err != nil {
+		return nil, ErrInvalidSealed
+	}
+	saltBytes := NewSecureBytes(salt)
+	defer saltBytes.Destroy()
+	params.SaltLength = uint32(len(salt))
+	nonce, err := decodeBase64(vals[3])
+	if err != nil || len(nonce) != sealedNonceSize {
+		return nil, ErrInvalidSealed
+	}
+	ciphertext, err := decodeBase64(vals[4])
+	if err != nil {
+		return nil, ErrInvalidSealed
+	}
+	if err := params.Validate(); err != nil {
+		return nil, ErrInvalidSealed
+	}
+	if err := policy.Check(params); err != nil {
+		return nil, err
+	}
+
+	aead := sealedAEAD(password, saltBytes, params)
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(sealed[:len(sealed)-len(vals[4])]))
+	if err != nil {
+		return nil, ErrOpen
+	}
+	return plaintext, nil
+}
+
+// sealedAEAD derives the key for a sealed value, and returns an AES-256-GCM
+// AEAD using it. params must have been validated.
+func sealedAEAD(password string, salt *SecureBytes, params *Params) cipher.AEAD {
+	secret := SecureBytesFromString(password)
+	defer secret.Destroy()
+	key := deriveKey(secret, salt, params)
+	defer key.Destroy()
+
+	// Neither fails for a 32-byte key.
+	block, _ := aes.NewCipher(key.Bytes())
+	aead, _ := cipher.NewGCM(block)
+	return aead
+}
@@ -0,0 +1,94 @@
+package pbkdf2
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	params := &Params{Iterations: 1000, SaltLength: 16, KeyLength: 64}
+	for _, plaintext := range []string{"", "db-password", strings.Repeat("x", 1000)} {
+		sealed, err := SealWithParams([]byte(plaintext), "pa$$word", params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(sealed, "$sealed$pbkdf2-sha512$1000$") || strings.Count(sealed, "$") != 6 {
+			t.Errorf("unexpected format %q", sealed)
+		}
+		got, err := Open(sealed, "pa$$word")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != plaintext {
+			t.Errorf("Open = %q, want %q", got, plaintext)
+		}
+	}
+
+	sealed, err := SealWithParams([]byte("db-password"), "pa$$word", &Params{Iterations: 1000, SaltLength: 16, KeyLength: 1, Variant: VariantSHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealed, "$sealed$pbkdf2-sha256$1000$") {
+		t.Errorf("unexpected format %q", sealed)
+	}
+	if got, err := Open(sealed, "pa$$word"); err != nil || string(got) != "db-password" {
+		t.Errorf("Open = %q, %v", got, err)
+	}
+
+	defaults, err := Seal([]byte("db-password"), "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(defaults, "$sealed$pbkdf2-sha512$"+strconv.FormatUint(uint64(GetDefaultParams().Iterations), 10)+"$") {
+		t.Errorf("expected default params in %q", defaults)
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	sealed, err := SealWithParams([]byte("db-password"), "pa$$word", &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(sealed, "wrong"); err != ErrOpen {
+		t.Errorf("wrong password: expected ErrOpen, got %v", err)
+	}
+	vals := strings.Split(sealed, "$")
+	tamper := func(i int, s string) string {
+		v := append([]string(nil), vals...)
+		v[i] = s
+		return strings.Join(v, "$")
+	}
+	if _, err := Open(tamper(3, "1001"), "pa$$word"); err != ErrOpen {
+		t.Errorf("tampered iterations: expected ErrOpen, got %v", err)
+	}
+	if _, err := Open(tamper(2, VariantSHA256), "pa$$word"); err != ErrOpen {
+		t.Errorf("tampered variant: expected ErrOpen, got %v", err)
+	}
+
+	for _, s := range []string{
+		"",
+		"$pbkdf2-sha512$1000$c2FsdA$a2V5",
+		tamper(2, "pbkdf2-md5"),
+		tamper(3, "0"),
+		tamper(3, "x"),
+		tamper(4, ""),
+		tamper(5, "c2FsdA"),
+		tamper(6, "not base64!"),
+		sealed + "$extra",
+	} {
+		if _, err := Open(s, "pa$$word"); err != ErrInvalidSealed {
+			t.Errorf("Open(%q): expected ErrInvalidSealed, got %v", s, err)
+		}
+	}
+
+	_, err = OpenWithPolicy(sealed, "pa$$word", &Policy{MaxIterations: 500})
+	var pe *PolicyError
+	if !errors.As(err, &pe) || pe.Param != "iterations" {
+		t.Errorf("expected iterations *PolicyError, got %v", err)
+	}
+	if _, err := SealWithParams(nil, "pa$$word", &Params{}); err != ErrInvalidParams {
+		t.Errorf("expected ErrInvalidParams, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package pbkdf2
+
+import "runtime"
+
+// SecureBytes holds sensitive material such as a password, salt or derived key.
+// The underlying memory is overwritten with zeros when Destroy is called, or
+// by a finalizer once the SecureBytes becomes unreachable if Destroy was never
+// called. Callers should not rely on the finalizer and should call Destroy as
+// soon as the secret is no longer needed.
+type SecureBytes struct {
+	b []byte
+}
+
+// NewSecureBytes wraps b in a SecureBytes. Ownership of b passes to the returned
+// value: the caller must not use or retain b afterwards, as it will be wiped
+// when the SecureBytes is destroyed.
+func NewSecureBytes(b []byte) *SecureBytes {
+	s := &SecureBytes{b: b}
+	runtime.SetFinalizer(s, (*SecureBytes).Destroy)
+	return s
+}
+
+// SecureBytesFromString copies str into a new SecureBytes. Go strings are
+// immutable and cannot be wiped, so sensitive values should be read into a
+// byte slice and passed to NewSecureBytes where possible.
+func SecureBytesFromString(str string) *SecureBytes {
+	return NewSecureBytes([]byte(str))
+}
+
+// Bytes returns the underlying secret. The returned slice aliases the memory
+// owned by s and is only valid until s is destroyed.
+func (s *SecureBytes) Bytes() []byte {
+	if s == nil {
+		return nil
+	}
+	return s.b
+}
+
+// Len returns the length of the secret in bytes.
+func (s *SecureBytes) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.b)
+}
+
+// Destroy overwrites the secret with zeros and releases it. It is safe to call
+// Destroy more than once.
+func (s *SecureBytes) Destroy() {
+	if s == nil {
+		return
+	}
+	wipe(s.b)
+	s.b = nil
+	runtime.SetFinalizer(s, nil)
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,71 @@
+package pbkdf2
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSecureBytesDestroy(t *testing.T) {
+	b := []byte("pa$$word")
+	s := NewSecureBytes(b)
+
+	if s.Len() != len(b) {
+		t.Fatalf("expected length %d got %d", len(b), s.Len())
+	}
+	if !bytes.Equal(s.Bytes(), []byte("pa$$word")) {
+		t.Fatalf("unexpected contents %q", s.Bytes())
+	}
+
+	s.Destroy()
+	if !bytes.Equal(b, make([]byte, len(b))) {
+		t.Fatalf("expected underlying memory to be wiped, got %q", b)
+	}
+	if s.Len() != 0 || s.Bytes() != nil {
+		t.Fatal("expected destroyed SecureBytes to be empty")
+	}
+
+	// Destroying twice, or a nil SecureBytes, must be harmless.
+	s.Destroy()
+	var nilSecret *SecureBytes
+	nilSecret.Destroy()
+}
+
+func TestSecureHashRoundTrip(t *testing.T) {
+	password := SecureBytesFromString("pa$$word")
+	defer password.Destroy()
+
+	hash, err := CreateHashSecure(password, DefaultParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	match, err := ComparePasswordAndHash("pa$$word", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !match {
+		t.Error("expected password and hash to match")
+	}
+
+	other := SecureBytesFromString("otherPa$$word")
+	defer other.Destroy()
+
+	match, err = ComparePasswordAndHashSecure(other, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match {
+		t.Error("expected password and hash to not match")
+	}
+
+	params, salt, key, err := DecodeHashSecure(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer salt.Destroy()
+	defer key.Destroy()
+
+	if uint32(salt.Len()) != params.SaltLength || uint32(key.Len()) != params.KeyLength {
+		t.Fatalf("salt/key lengths %d/%d do not match params %#v", salt.Len(), key.Len(), *params)
+	}
+}
@@ -0,0 +1,186 @@
+package pbkdf2
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrSelfCheck is matched, with errors.Is, by the *SelfCheckError returned
+// when a self-check fails.
+var ErrSelfCheck = errors.New("pbkdf2: self-check failed")
+
+// Names of the checks reported in SelfCheckError.Check.
+const (
+	SelfCheckEntropy     = "entropy"
+	SelfCheckKnownAnswer = "known-answer"
+	SelfCheckBackend     = "backend"
+	SelfCheckLatency     = "latency"
+)
+
+// SelfCheckError describes a failed self-check.
+type SelfCheckError struct {
+	// Check is the check that failed, such as SelfCheckLatency.
+	Check string
+
+	// Err is the underlying error, such as an *EntropyError.
+	Err error
+}
+
+func (e *SelfCheckError) Error() string {
+	return ErrSelfCheck.Error() + ": " + e.Check + ": " + e.Err.Error()
+}
+
+func (e *SelfCheckError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrSelfCheck.
+func (e *SelfCheckError) Is(target error) bool {
+	return target == ErrSelfCheck
+}
+
+// DefaultSelfCheckRuns is the number of derivations SelfCheck times, if
+// SelfChecker.Runs is zero.
+const DefaultSelfCheckRuns = 3
+
+// knownAnswers are the vectors SelfCheck derives, one per built-in variant,
+// each a single iteration so that the check is cheap. The SHA-256 vector is
+// from RFC 7914, section 11; the others were generated with Python's
+// hashlib.pbkdf2_hmac.
+var knownAnswers = []struct {
+	variant, password, salt, key string
+}{
+	{VariantSHA512, "password", "salt", "867f70cf1ade02cff3752599a3a53dc4af34c7a669815ae5d513554e1c8cf252c02d470a285a0501bad999bfe943c08f050235d7d68b1da55e63f73b60a57fce"},
+	{VariantSHA256, "passwd", "salt", "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+	{VariantSHA512_256, "password", "salt", "4b6a63117d3ec0032624616082c1c1912f56fa5f0c1f94574d515e20e5ddd74a"},
+	{VariantSHA3_512, "password", "salt", "f7a2684630ec0f81f23abbf606278deeaad1a35053db3c066903d9114ed3fd6e44c23dd5bddbe4e81626880cef267ef7dcf13b183194a5530f154ec57f646e2d"},
+	{VariantBLAKE2b, "password", "salt", "684e7cc1dd9b241d2c977f38a896645da49b85eb13cf8f5c021efc167aad799343c06f50e2959de06a0bca80a154457d8e92e70ebdcdb3722dcf9badd6ff1dfb"},
+}
+
+// unhex decodes the lower-case hex of knownAnswers. It stands in for
+// encoding/hex, which imports fmt.
+func unhex(s string) []byte {
+	digit := func(c byte) byte {
+		if c >= 'a' {
+			return c - 'a' + 10
+		}
+		return c - '0'
+	}
+	b := make([]byte, len(s)/2)
+	for i := range b {
+		b[i] = digit(s[2*i])<<4 | digit(s[2*i+1])
+	}
+	return b
+}
+
+// A SelfChecker checks that key derivation works, and is fast enough, before
+// a service starts taking traffic. The zero value runs the checks that need
+// no configuration; see SelfCheck.
+type SelfChecker struct {
+	// Params are the params whose latency is measured. If nil, the
+	// package-level default params are used.
+	Params *Params
+
+	// LatencyBudget, if set, is the longest the median derivation with
+	// Params may take, such as the share of a login's latency budget given
+	// to hashing. A slower machine fails the latency check, rather than
+	// serving logins that time out.
+	LatencyBudget time.Duration
+
+	// Runs is the number of derivations timed. If zero,
+	// DefaultSelfCheckRuns is used.
+	Runs int
+
+	// Backend, if set, is the name the backend must have, such as
+	// BackendOpenSSL, so that a build without the intended tags fails
+	// the backend check.
+	Backend string
+
+	// Clock, if set, replaces SystemClock for timing derivations.
+	Clock Clock
+}
+
+// SelfCheck runs the checks of a zero SelfChecker: the entropy source's health
+// tests and the known-answer tests of the built-in variants.
+func SelfCheck(ctx context.Context) error {
+	var c SelfChecker
+	return c.SelfCheck(ctx)
+}
+
+// SelfCheck checks, in order, that the entropy source passes its health tests,
+// as CheckEntropy does; that each built-in variant derives its known answer
+// with the selected backend; that the backend is c.Backend, if set; and that
+// a derivation with c.Params takes at most c.LatencyBudget, if set. It returns
+// a *SelfCheckError for the first check that fails, or ctx's error if ctx is
+// done first. It is intended for readiness probes:
+//
+//	http.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+//		if err := checker.SelfCheck(r.Context()); err != nil {
+//			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+//		}
+//	})
+//
+// With a latency budget, the check takes Runs derivations with c.Params, so
+// probes calling it should be infrequent, or cache its result.
+func (c *SelfChecker) SelfCheck(ctx context.Context) error {
+	if err := CheckEntropy(); err != nil {
+		return &SelfCheckError{Check: SelfCheckEntropy, Err: err}
+	}
+	for _, v := range knownAnswers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		want := unhex(v.key)
+		got := pbkdf2Key([]byte(v.password), []byte(v.salt), 1, len(want), v.variant)
+		if !bytes.Equal(got, want) {
+			return &SelfCheckError{Check: SelfCheckKnownAnswer, Err: errors.New(v.variant + " derived the wrong key")}
+		}
+	}
+	if name := backendName(); c.Backend != "" && name != c.Backend {
+		return &SelfCheckError{Check: SelfCheckBackend, Err: errors.New("backend is " + name + ", want " + c.Backend)}
+	}
+	if c.LatencyBudget > 0 {
+		if err := c.checkLatency(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *SelfChecker) checkLatency(ctx context.Context) error {
+	params := c.Params
+	if params == nil {
+		params = GetDefaultParams()
+	}
+	if err := params.Validate(); err != nil {
+		return &SelfCheckError{Check: SelfCheckLatency, Err: err}
+	}
+	runs := c.Runs
+	if runs <= 0 {
+		runs = DefaultSelfCheckRuns
+	}
+	clock := clockOrSystem(c.Clock)
+
+	password := SecureBytesFromString("self-check password")
+	defer password.Destroy()
+	salt := NewSecureBytes(make([]byte, params.SaltLength))
+	defer salt.Destroy()
+
+	durations := make([]time.Duration, runs)
+	for i := range durations {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		start := clock.Now()
+		deriveKey(password, salt, params).Destroy()
+		durations[i] = clock.Since(start)
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	if d := median(durations); d > c.LatencyBudget {
+		return &SelfCheckError{Check: SelfCheckLatency, Err: errors.New("derivation took " + d.String() + ", over the budget of " + c.LatencyBudget.String())}
+	}
+	return nil
+}
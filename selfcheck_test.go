@@ -0,0 +1,57 @@
+package pbkdf2
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSelfCheck(t *testing.T) {
+	if err := SelfCheck(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &SelfChecker{Params: testParams, LatencyBudget: time.Minute, Backend: Backend().Name}
+	if err := c.SelfCheck(context.Background()); err != nil {
+		t.Errorf("SelfCheck with a generous budget: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.SelfCheck(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("SelfCheck with a canceled context = %v", err)
+	}
+}
+
+func TestSelfCheckFailures(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	clock.SetStep(50 * time.Millisecond)
+	checks := []struct {
+		c     SelfChecker
+		check string
+	}{
+		{SelfChecker{Backend: "no-such-backend"}, SelfCheckBackend},
+		{SelfChecker{Params: testParams, LatencyBudget: 40 * time.Millisecond, Clock: clock}, SelfCheckLatency},
+		{SelfChecker{Params: &Params{}, LatencyBudget: time.Second}, SelfCheckLatency},
+	}
+	for _, tc := range checks {
+		err := tc.c.SelfCheck(context.Background())
+		var e *SelfCheckError
+		if !errors.As(err, &e) || e.Check != tc.check || !errors.Is(err, ErrSelfCheck) {
+			t.Errorf("SelfCheck = %v, want a failed %s check", err, tc.check)
+		}
+	}
+
+	withEntropySource(t, func(p []byte) (int, error) {
+		for i := range p {
+			p[i] = 0
+		}
+		return len(p), nil
+	}, false)
+	err := SelfCheck(context.Background())
+	var e *SelfCheckError
+	if !errors.As(err, &e) || e.Check != SelfCheckEntropy || !errors.Is(err, ErrEntropy) {
+		t.Errorf("SelfCheck with a constant entropy source = %v", err)
+	}
+}
@@ -0,0 +1,346 @@
+// Package service serves password hashing over HTTP, so that the pepper keys
+// and CPU cost of hashing can be held by one hardened service rather than by
+// every application.
+//
+// A Handler accepts JSON requests from authenticated clients:
+//
+//	POST /v1/hash    {"password": "..."}                → {"hash": "..."}
+//	POST /v1/verify  {"password": "...", "hash": "..."} → {"match": true}
+//
+// Clients authenticate with a bearer token, or with a client certificate when
+// the server is configured for mutual TLS with TLSConfig. Each client is rate
+// limited separately, so that one misbehaving client cannot exhaust the
+// service for the others:
+//
+//	tlsConfig, err := service.TLSConfig("server.crt", "server.key", "clients-ca.crt")
+//	h := &service.Handler{
+//		Hasher:          pepperHasher,
+//		Tokens:          map[string]string{"billing": billingToken},
+//		CertificateAuth: true,
+//		Limiter:         ratelimit.NewLimiter(50, 100),
+//	}
+//	srv := &http.Server{Addr: ":8443", Handler: h, TLSConfig: tlsConfig}
+//	err = srv.ListenAndServeTLS("", "")
+//
+// Errors are reported as {"error": "..."} with a matching status code.
+// Hashers with context-aware methods, such as *pbkdf2.Hasher, receive each
+// request's context labelled with the client ID under "client", so that their
+// OnUsage and OnEvent hooks can attribute hashing to clients; see
+// pbkdf2.WithLabels.
+//
+// For rolling deploys, Handler.Shutdown drains in-flight requests alongside
+// http.Server.Shutdown, and reports how many were abandoned at the deadline:
+//
+//	go srv.Shutdown(ctx)
+//	abandoned, err := h.Shutdown(ctx)
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pganguli/pbkdf2"
+	"github.com/pganguli/pbkdf2/ratelimit"
+)
+
+// MaxRequestSize is the largest request body a Handler accepts.
+const MaxRequestSize = 64 << 10
+
+// A Hasher creates and verifies hashes. It is satisfied by *pbkdf2.Hasher and
+// *pepper.Hasher.
+type Hasher interface {
+	CreateHash(password string) (string, error)
+	Verify(password, hash string) error
+}
+
+// contextHasher is implemented by hashers, such as *pbkdf2.Hasher, that pass
+// the labels of a context to their hooks. The Handler labels each request
+// with the client ID under "client".
+type contextHasher interface {
+	CreateHashContext(ctx context.Context, password string) (string, error)
+	VerifyContext(ctx context.Context, password, hash string) error
+}
+
+// A Handler serves the hashing API. Its fields must not be modified while it
+// is serving; use SetHasher to change the hasher.
+type Handler struct {
+	Hasher Hasher
+
+	// Tokens maps client IDs to the bearer tokens they authenticate with in
+	// the Authorization header. Tokens should be long random strings.
+	Tokens map[string]string
+
+	// CertificateAuth, if true, authenticates clients presenting a verified
+	// TLS client certificate as the certificate's subject common name.
+	CertificateAuth bool
+
+	// Limiter, if set, limits the requests of each client, keyed by client
+	// ID.
+	Limiter *ratelimit.Limiter
+
+	// replaced holds the hasher set by SetHasher, as a hasherValue.
+	replaced atomic.Value
+
+	mu       sync.Mutex
+	inFlight int
+	draining bool
+	drained  chan struct{}
+}
+
+// hasherValue wraps a Hasher so that hashers of different types can be stored
+// in an atomic.Value.
+type hasherValue struct {
+	Hasher
+}
+
+// SetHasher replaces the hasher, for example with new params, policy or
+// pepper keyring after a configuration reload. Requests already in flight
+// complete with the previous hasher; subsequent ones use the new one. It is
+// safe to call while the handler is serving.
+func (h *Handler) SetHasher(hasher Hasher) {
+	h.replaced.Store(hasherValue{hasher})
+}
+
+func (h *Handler) hasher() Hasher {
+	if v, ok := h.replaced.Load().(hasherValue); ok {
+		return v.Hasher
+	}
+	return h.Hasher
+}
+
+type request struct {
+	Password string `json:"password"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+type response struct {
+	Hash  string `json:"hash,omitempty"`
+	Match *bool  `json:"match,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// ServeHTTP implements http.Handler. Requests that fail authentication are
+// rejected before any other processing, and an authenticated client's
+// requests over its limit before any hashing.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.begin() {
+		w.Header().Set("Connection", "close")
+		writeError(w, http.StatusServiceUnavailable, "shutting down")
+		return
+	}
+	defer h.end()
+
+	client, ok := h.authenticate(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="pbkdf2"`)
+		writeError(w, http.StatusUnauthorized, "authentication required")
+		return
+	}
+
+	var op func(ctx context.Context, hasher Hasher, req *request) (*response, int)
+	switch r.URL.Path {
+	case "/v1/hash":
+		op = handleHash
+	case "/v1/verify":
+		op = handleVerify
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if h.Limiter != nil {
+		if err := h.Limiter.Allow(client); err != nil {
+			var le *ratelimit.LimitError
+			if errors.As(err, &le) {
+				w.Header().Set("Retry-After", strconv.Itoa(int((le.RetryAfter+time.Second-1)/time.Second)))
+			}
+			writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+	}
+
+	var req request
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	ctx := pbkdf2.WithLabels(r.Context(), map[string]string{"client": client})
+	resp, status := op(ctx, h.hasher(), &req)
+	writeJSON(w, status, resp)
+}
+
+// begin records the start of a request, reporting false if the handler is
+// shutting down.
+func (h *Handler) begin() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.draining {
+		return false
+	}
+	h.inFlight++
+	return true
+}
+
+func (h *Handler) end() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.inFlight--
+	if h.inFlight == 0 && h.drained != nil {
+		close(h.drained)
+		h.drained = nil
+	}
+}
+
+// Shutdown stops the handler accepting requests, which are then rejected with
+// 503 Service Unavailable, and waits for those in flight, each deriving at
+// most one key, to complete. If ctx ends first, it returns the number still
+// in flight, which will be abandoned when the process exits, and the
+// context's error. It does not close connections; use it alongside
+// http.Server.Shutdown.
+func (h *Handler) Shutdown(ctx context.Context) (abandoned int, err error) {
+	h.mu.Lock()
+	h.draining = true
+	if h.inFlight == 0 {
+		h.mu.Unlock()
+		return 0, nil
+	}
+	if h.drained == nil {
+		h.drained = make(chan struct{})
+	}
+	drained := h.drained
+	h.mu.Unlock()
+
+	select {
+	case <-drained:
+		return 0, nil
+	case <-ctx.Done():
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return h.inFlight, ctx.Err()
+	}
+}
+
+func handleHash(ctx context.Context, hasher Hasher, req *request) (*response, int) {
+	var hash string
+	var err error
+	if ch, ok := hasher.(contextHasher); ok {
+		hash, err = ch.CreateHashContext(ctx, req.Password)
+	} else {
+		hash, err = hasher.CreateHash(req.Password)
+	}
+	if err != nil {
+		return &response{Error: "hashing failed"}, http.StatusInternalServerError
+	}
+	return &response{Hash: hash}, http.StatusOK
+}
+
+func handleVerify(ctx context.Context, hasher Hasher, req *request) (*response, int) {
+	var err error
+	if ch, ok := hasher.(contextHasher); ok {
+		err = ch.VerifyContext(ctx, req.Password, req.Hash)
+	} else {
+		err = hasher.Verify(req.Password, req.Hash)
+	}
+	if err != nil && err != pbkdf2.ErrMismatchedHashAndPassword {
+		// Malformed hashes, unknown pepper keys and policy violations are
+		// the caller's problem, and their messages reveal no secrets.
+		return &response{Error: err.Error()}, http.StatusUnprocessableEntity
+	}
+	match := err == nil
+	return &response{Match: &match}, http.StatusOK
+}
+
+// authenticate returns the ID of the client making r. A bearer token, if
+// present, must be valid, and takes precedence over a client certificate.
+func (h *Handler) authenticate(r *http.Request) (string, bool) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		scheme, token, ok := strings.Cut(auth, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return "", false
+		}
+		return h.clientForToken(strings.TrimSpace(token))
+	}
+	if h.CertificateAuth && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		if id := r.TLS.VerifiedChains[0][0].Subject.CommonName; id != "" {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// clientForToken compares token against every client's, in constant time
+// with respect to the tokens' contents.
+func (h *Handler) clientForToken(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	digest := sha256.Sum256([]byte(token))
+	var client string
+	found := 0
+	for id, t := range h.Tokens {
+		other := sha256.Sum256([]byte(t))
+		if subtle.ConstantTimeCompare(digest[:], other[:]) == 1 && t != "" {
+			client = id
+			found = 1
+		}
+	}
+	return client, found == 1
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, &response{Error: msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, resp *response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// TLSConfig returns a server TLS configuration using the certificate and key
+// in the given PEM files, and requiring TLS 1.2 or later. If clientCAFile is
+// not empty, clients must present a certificate signed by one of the CAs in
+// it, in which case Handler.CertificateAuth can identify them.
+func TLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("service: no certificates in " + clientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
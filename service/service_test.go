@@ -0,0 +1,322 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pganguli/pbkdf2"
+	"github.com/pganguli/pbkdf2/ratelimit"
+)
+
+var testParams = &pbkdf2.Params{Iterations: 1000, SaltLength: 16, KeyLength: 32}
+
+func post(t *testing.T, h http.Handler, path, token, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s: invalid response %q", path, w.Body.String())
+	}
+	return w, resp
+}
+
+func TestHandler(t *testing.T) {
+	h := &Handler{
+		Hasher: &pbkdf2.Hasher{Params: testParams},
+		Tokens: map[string]string{"billing": "billing-token", "disabled": ""},
+	}
+
+	w, resp := post(t, h, "/v1/hash", "billing-token", `{"password":"pa$$word"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("hash: status %d: %v", w.Code, resp)
+	}
+	hash, _ := resp["hash"].(string)
+	if err := pbkdf2.Verify("pa$$word", hash); err != nil {
+		t.Fatalf("hash %q: %v", hash, err)
+	}
+
+	for password, want := range map[string]bool{"pa$$word": true, "wrong": false} {
+		body, _ := json.Marshal(map[string]string{"password": password, "hash": hash})
+		w, resp := post(t, h, "/v1/verify", "billing-token", string(body))
+		if w.Code != http.StatusOK || resp["match"] != want {
+			t.Errorf("verify %q: status %d: %v", password, w.Code, resp)
+		}
+	}
+
+	tests := []struct {
+		name, path, token, body string
+		status                  int
+	}{
+		{"no token", "/v1/hash", "", `{"password":"x"}`, http.StatusUnauthorized},
+		{"wrong token", "/v1/hash", "other", `{"password":"x"}`, http.StatusUnauthorized},
+		{"empty token", "/v1/hash", " ", `{"password":"x"}`, http.StatusUnauthorized},
+		{"unknown path", "/v1/other", "billing-token", `{}`, http.StatusNotFound},
+		{"bad body", "/v1/hash", "billing-token", `{"password":`, http.StatusBadRequest},
+		{"unknown field", "/v1/hash", "billing-token", `{"pw":"x"}`, http.StatusBadRequest},
+		{"malformed hash", "/v1/verify", "billing-token", `{"password":"x","hash":"$pbkdf2-sha512$1$AA"}`, http.StatusUnprocessableEntity},
+		{"oversized", "/v1/hash", "billing-token", `{"password":"` + strings.Repeat("x", MaxRequestSize) + `"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w, resp := post(t, h, tt.path, tt.token, tt.body)
+		if w.Code != tt.status || resp["error"] == "" {
+			t.Errorf("%s: status %d, want %d: %v", tt.name, w.Code, tt.status, resp)
+		}
+		if tt.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: no WWW-Authenticate header", tt.name)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/hash", nil)
+	r.Header.Set("Authorization", "Bearer billing-token")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("GET: status %d, Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestHandlerLabels(t *testing.T) {
+	var clients []string
+	h := &Handler{
+		Hasher: &pbkdf2.Hasher{
+			Params: testParams,
+			OnEvent: func(e pbkdf2.Event) {
+				clients = append(clients, e.Labels["client"])
+			},
+		},
+		Tokens: map[string]string{"billing": "billing-token"},
+	}
+	w, resp := post(t, h, "/v1/hash", "billing-token", `{"password":"pa$$word"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("hash: status %d: %v", w.Code, resp)
+	}
+	body, _ := json.Marshal(map[string]string{"password": "pa$$word", "hash": resp["hash"].(string)})
+	if w, resp := post(t, h, "/v1/verify", "billing-token", string(body)); w.Code != http.StatusOK {
+		t.Fatalf("verify: status %d: %v", w.Code, resp)
+	}
+	if len(clients) != 2 || clients[0] != "billing" || clients[1] != "billing" {
+		t.Errorf("unexpected client labels %q", clients)
+	}
+}
+
+func TestHandlerRateLimit(t *testing.T) {
+	h := &Handler{
+		Hasher:  &pbkdf2.Hasher{Params: testParams},
+		Tokens:  map[string]string{"a": "token-a", "b": "token-b"},
+		Limiter: ratelimit.NewLimiter(0.5, 2),
+	}
+	for i := 0; i < 2; i++ {
+		if w, resp := post(t, h, "/v1/hash", "token-a", `{"password":"x"}`); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d: %v", i, w.Code, resp)
+		}
+	}
+	w, _ := post(t, h, "/v1/hash", "token-a", `{"password":"x"}`)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" {
+		t.Errorf("over limit: status %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	// Limits are per client.
+	if w, resp := post(t, h, "/v1/hash", "token-b", `{"password":"x"}`); w.Code != http.StatusOK {
+		t.Errorf("other client: status %d: %v", w.Code, resp)
+	}
+}
+
+// writeCert writes a PEM certificate and key for an ECDSA key signed by
+// parent, or self-signed if parent is nil, and returns them.
+func writeCert(t *testing.T, dir, name string, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	notAfter := time.Now().Add(time.Hour)
+	ca, caKey := writeCert(t, dir, "ca", &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil, nil)
+	writeCert(t, dir, "server", &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "server"},
+		NotAfter:     notAfter,
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	writeCert(t, dir, "client", &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "billing"},
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+
+	config, err := TLSConfig(filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"), filepath.Join(dir, "ca.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(&Handler{
+		Hasher:          &pbkdf2.Hasher{Params: testParams},
+		CertificateAuth: true,
+	})
+	srv.TLS = config
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	clientCert, err := tls.LoadX509KeyPair(filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newClient := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+	}
+
+	resp, err := newClient(clientCert).Post(srv.URL+"/v1/hash", "application/json", strings.NewReader(`{"password":"x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("with client certificate: status %d", resp.StatusCode)
+	}
+
+	if resp, err := newClient().Post(srv.URL+"/v1/hash", "application/json", strings.NewReader(`{"password":"x"}`)); err == nil {
+		resp.Body.Close()
+		t.Error("request without client certificate succeeded")
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := TLSConfig(filepath.Join(dir, "missing.crt"), filepath.Join(dir, "missing.key"), ""); err == nil {
+		t.Error("missing certificate accepted")
+	}
+}
+
+// blockingHasher blocks CreateHash until release is closed.
+type blockingHasher struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingHasher) CreateHash(password string) (string, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return "hash", nil
+}
+
+func (b *blockingHasher) Verify(password, hash string) error {
+	return nil
+}
+
+func TestShutdown(t *testing.T) {
+	b := &blockingHasher{started: make(chan struct{}), release: make(chan struct{})}
+	h := &Handler{Hasher: b, Tokens: map[string]string{"a": "token-a"}}
+
+	done := make(chan int)
+	go func() {
+		w, _ := post(t, h, "/v1/hash", "token-a", `{"password":"x"}`)
+		done <- w.Code
+	}()
+	<-b.started
+
+	// The deadline passes with the request still in flight.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if abandoned, err := h.Shutdown(ctx); abandoned != 1 || err != context.DeadlineExceeded {
+		t.Errorf("Shutdown = %d, %v, want 1, DeadlineExceeded", abandoned, err)
+	}
+
+	// New requests are rejected while draining.
+	if w, _ := post(t, h, "/v1/hash", "token-a", `{"password":"x"}`); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("request while draining: status %d", w.Code)
+	}
+
+	// A second Shutdown waits for the request to complete.
+	result := make(chan int)
+	go func() {
+		abandoned, _ := h.Shutdown(context.Background())
+		result <- abandoned
+	}()
+	close(b.release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("in-flight request: status %d", code)
+	}
+	if abandoned := <-result; abandoned != 0 {
+		t.Errorf("Shutdown abandoned %d", abandoned)
+	}
+}
+
+func TestSetHasher(t *testing.T) {
+	h := &Handler{
+		Hasher: &pbkdf2.Hasher{Params: testParams},
+		Tokens: map[string]string{"a": "token-a"},
+	}
+	_, resp := post(t, h, "/v1/hash", "token-a", `{"password":"x"}`)
+	oldHash, _ := resp["hash"].(string)
+
+	h.SetHasher(&pbkdf2.Hasher{Params: testParams, Namespace: "reloaded"})
+	_, resp = post(t, h, "/v1/hash", "token-a", `{"password":"x"}`)
+	parsed, err := pbkdf2.ParseHash(resp["hash"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Metadata[pbkdf2.MetadataNamespace] != "reloaded" {
+		t.Errorf("new hash %v not made with the new hasher", resp["hash"])
+	}
+
+	// Hashes are verified by the new hasher, which rejects the old namespace.
+	body, _ := json.Marshal(map[string]string{"password": "x", "hash": oldHash})
+	if w, _ := post(t, h, "/v1/verify", "token-a", string(body)); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("old hash: status %d", w.Code)
+	}
+}
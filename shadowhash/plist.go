@@ -0,0 +1,366 @@
+package shadowhash
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// The plist decoders support the subset of property list types found in
+// directory service user records: dictionaries, arrays, data, integers,
+// strings, booleans and reals. Values are decoded as map[string]interface{},
+// []interface{}, []byte, int64, string, bool and float64.
+
+// maxDepth bounds the nesting of containers, so that hostile input cannot
+// exhaust the stack. Binary plists may also reference an object more than
+// once, so each object is decoded at most once and reused, and a reference
+// back to a container being decoded is rejected.
+const maxDepth = 32
+
+var errPlist = errors.New("shadowhash: malformed property list")
+
+func decodePlist(data []byte) (interface{}, error) {
+	if bytes.HasPrefix(data, []byte("bplist00")) {
+		return decodeBinaryPlist(data)
+	}
+	return decodeXMLPlist(data)
+}
+
+type binaryPlist struct {
+	data       []byte
+	offsets    []uint64
+	refSize    int
+	offsetSize int
+
+	// decoded caches each object once it has been decoded, and decoding
+	// marks the objects on the current path.
+	decoded  []interface{}
+	decoding []bool
+}
+
+func decodeBinaryPlist(data []byte) (interface{}, error) {
+	const headerSize, trailerSize = 8, 32
+	if len(data) < headerSize+trailerSize {
+		return nil, errPlist
+	}
+	trailer := data[len(data)-trailerSize:]
+	p := &binaryPlist{
+		data:       data,
+		offsetSize: int(trailer[6]),
+		refSize:    int(trailer[7]),
+	}
+	numObjects := binary.BigEndian.Uint64(trailer[8:])
+	top := binary.BigEndian.Uint64(trailer[16:])
+	tableOffset := binary.BigEndian.Uint64(trailer[24:])
+
+	if p.offsetSize < 1 || p.offsetSize > 8 || p.refSize < 1 || p.refSize > 8 ||
+		numObjects == 0 || top >= numObjects || tableOffset < headerSize ||
+		numObjects > uint64(len(data))/uint64(p.offsetSize) ||
+		tableOffset+numObjects*uint64(p.offsetSize) > uint64(len(data)-trailerSize) {
+		return nil, errPlist
+	}
+
+	p.offsets = make([]uint64, numObjects)
+	p.decoded = make([]interface{}, numObjects)
+	p.decoding = make([]bool, numObjects)
+	for i := range p.offsets {
+		start := tableOffset + uint64(i*p.offsetSize)
+		p.offsets[i] = readUint(data[start : start+uint64(p.offsetSize)])
+		if p.offsets[i] < headerSize || p.offsets[i] >= tableOffset {
+			return nil, errPlist
+		}
+	}
+	return p.object(top, 0)
+}
+
+func readUint(b []byte) uint64 {
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
+
+// bytesAt returns the n bytes at off, or an error if they are out of range.
+func (p *binaryPlist) bytesAt(off, n uint64) ([]byte, error) {
+	if off > uint64(len(p.data)) || n > uint64(len(p.data))-off {
+		return nil, errPlist
+	}
+	return p.data[off : off+n], nil
+}
+
+// length returns the length encoded in the marker at off, and the offset of
+// the object's contents.
+func (p *binaryPlist) length(marker byte, off uint64) (uint64, uint64, error) {
+	n := uint64(marker & 0x0f)
+	if n != 0x0f {
+		return n, off + 1, nil
+	}
+	b, err := p.bytesAt(off+1, 1)
+	if err != nil || b[0]&0xf0 != 0x10 {
+		return 0, 0, errPlist
+	}
+	size := uint64(1) << (b[0] & 0x0f)
+	if size > 8 {
+		return 0, 0, errPlist
+	}
+	v, err := p.bytesAt(off+2, size)
+	if err != nil {
+		return 0, 0, err
+	}
+	return readUint(v), off + 2 + size, nil
+}
+
+func (p *binaryPlist) object(ref uint64, depth int) (interface{}, error) {
+	if ref >= uint64(len(p.offsets)) || depth > maxDepth || p.decoding[ref] {
+		return nil, errPlist
+	}
+	if v := p.decoded[ref]; v != nil {
+		return v, nil
+	}
+	p.decoding[ref] = true
+	v, err := p.decode(ref, depth)
+	p.decoding[ref] = false
+	if err != nil {
+		return nil, err
+	}
+	p.decoded[ref] = v
+	return v, nil
+}
+
+func (p *binaryPlist) decode(ref uint64, depth int) (interface{}, error) {
+	off := p.offsets[ref]
+	marker := p.data[off]
+
+	switch marker >> 4 {
+	case 0x0:
+		switch marker {
+		case 0x08:
+			return false, nil
+		case 0x09:
+			return true, nil
+		}
+	case 0x1:
+		size := uint64(1) << (marker & 0x0f)
+		if size > 8 {
+			return nil, errPlist
+		}
+		b, err := p.bytesAt(off+1, size)
+		if err != nil {
+			return nil, err
+		}
+		return int64(readUint(b)), nil
+	case 0x2:
+		switch marker & 0x0f {
+		case 2:
+			b, err := p.bytesAt(off+1, 4)
+			if err != nil {
+				return nil, err
+			}
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+		case 3:
+			b, err := p.bytesAt(off+1, 8)
+			if err != nil {
+				return nil, err
+			}
+			return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+		}
+	case 0x4, 0x5, 0x6:
+		n, start, err := p.length(marker, off)
+		if err != nil {
+			return nil, err
+		}
+		if marker>>4 == 0x6 {
+			if n > uint64(len(p.data))/2 {
+				return nil, errPlist
+			}
+			b, err := p.bytesAt(start, 2*n)
+			if err != nil {
+				return nil, err
+			}
+			units := make([]uint16, n)
+			for i := range units {
+				units[i] = binary.BigEndian.Uint16(b[2*i:])
+			}
+			return string(utf16.Decode(units)), nil
+		}
+		b, err := p.bytesAt(start, n)
+		if err != nil {
+			return nil, err
+		}
+		if marker>>4 == 0x5 {
+			return string(b), nil
+		}
+		return append([]byte(nil), b...), nil
+	case 0xa, 0xd:
+		n, start, err := p.length(marker, off)
+		if err != nil {
+			return nil, err
+		}
+		count := n
+		if marker>>4 == 0xd {
+			count *= 2
+		}
+		if n > uint64(len(p.data)) {
+			return nil, errPlist
+		}
+		b, err := p.bytesAt(start, count*uint64(p.refSize))
+		if err != nil {
+			return nil, err
+		}
+		refs := make([]uint64, count)
+		for i := range refs {
+			refs[i] = readUint(b[i*p.refSize : (i+1)*p.refSize])
+		}
+
+		if marker>>4 == 0xa {
+			array := make([]interface{}, n)
+			for i, r := range refs {
+				if array[i], err = p.object(r, depth+1); err != nil {
+					return nil, err
+				}
+			}
+			return array, nil
+		}
+		dict := make(map[string]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			k, err := p.object(refs[i], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, errPlist
+			}
+			if dict[key], err = p.object(refs[n+i], depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	}
+	return nil, errPlist
+}
+
+func decodeXMLPlist(data []byte) (interface{}, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, errPlist
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			if start.Name.Local != "plist" {
+				return nil, errPlist
+			}
+			break
+		}
+	}
+	start, err := nextElement(d)
+	if err != nil {
+		return nil, err
+	}
+	return xmlValue(d, start, 0)
+}
+
+// nextElement returns the next start element, skipping character data,
+// comments and directives, or an error at an end element.
+func nextElement(d *xml.Decoder) (xml.StartElement, error) {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return xml.StartElement{}, errPlist
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			return t, nil
+		case xml.EndElement:
+			return xml.StartElement{}, io.EOF
+		}
+	}
+}
+
+func xmlValue(d *xml.Decoder, start xml.StartElement, depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errPlist
+	}
+	switch start.Name.Local {
+	case "dict":
+		dict := make(map[string]interface{})
+		for {
+			keyElem, err := nextElement(d)
+			if err == io.EOF {
+				return dict, nil
+			}
+			if err != nil || keyElem.Name.Local != "key" {
+				return nil, errPlist
+			}
+			var key string
+			if err := d.DecodeElement(&key, &keyElem); err != nil {
+				return nil, errPlist
+			}
+			valueElem, err := nextElement(d)
+			if err != nil {
+				return nil, errPlist
+			}
+			if dict[key], err = xmlValue(d, valueElem, depth+1); err != nil {
+				return nil, err
+			}
+		}
+	case "array":
+		array := []interface{}{}
+		for {
+			elem, err := nextElement(d)
+			if err == io.EOF {
+				return array, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			v, err := xmlValue(d, elem, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, v)
+		}
+	case "true", "false":
+		if err := d.Skip(); err != nil {
+			return nil, errPlist
+		}
+		return start.Name.Local == "true", nil
+	}
+
+	var text string
+	if err := d.DecodeElement(&text, &start); err != nil {
+		return nil, errPlist
+	}
+	switch start.Name.Local {
+	case "string":
+		return text, nil
+	case "data":
+		b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+		if err != nil {
+			return nil, errPlist
+		}
+		return b, nil
+	case "integer":
+		n, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+		if err != nil {
+			return nil, errPlist
+		}
+		return n, nil
+	case "real":
+		f, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil {
+			return nil, errPlist
+		}
+		return f, nil
+	}
+	return nil, errPlist
+}
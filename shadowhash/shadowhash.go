@@ -0,0 +1,131 @@
+// Package shadowhash decodes and verifies the SALTED-SHA512-PBKDF2 password
+// verifiers that macOS stores in the ShadowHashData attribute of local user
+// records, so that fleet-management tools can validate credentials offline.
+//
+// ShadowHashData is a property list, usually binary, holding a dictionary of
+// verifiers keyed by scheme:
+//
+//	SALTED-SHA512-PBKDF2 = {
+//		entropy    = <128 bytes>
+//		iterations = 45454
+//		salt       = <32 bytes>
+//	}
+//
+// The entropy is PBKDF2-HMAC-SHA512 of the password. Parse accepts the
+// ShadowHashData property list itself, a whole user record from
+// /var/db/dslocal/nodes/Default/users, or the hexadecimal text printed by
+//
+//	dscl . -read /Users/<name> dsAttrTypeNative:ShadowHashData
+//
+// Other verifiers, such as SRP-RFC5054-4096-SHA512-PBKDF2, are ignored.
+package shadowhash
+
+import (
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"runtime"
+	"strings"
+
+	xpbkdf2 "golang.org/x/crypto/pbkdf2"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// Scheme is the key of the PBKDF2 verifier in ShadowHashData.
+const Scheme = "SALTED-SHA512-PBKDF2"
+
+// ErrNoVerifier is returned by Parse if the input holds no
+// SALTED-SHA512-PBKDF2 verifier, as for accounts with no password or whose
+// password is managed elsewhere.
+var ErrNoVerifier = errors.New("shadowhash: no " + Scheme + " verifier")
+
+// A ShadowHash is a decoded SALTED-SHA512-PBKDF2 verifier.
+type ShadowHash struct {
+	Entropy    []byte
+	Salt       []byte
+	Iterations uint32
+}
+
+const dsclPrefix = "dsAttrTypeNative:ShadowHashData:"
+
+// Parse decodes the SALTED-SHA512-PBKDF2 verifier from ShadowHashData, a user
+// record holding it, or the output of dscl, in binary or XML property list
+// format.
+func Parse(data []byte) (*ShadowHash, error) {
+	if text := strings.TrimSpace(string(data)); strings.HasPrefix(text, dsclPrefix) {
+		decoded, err := hex.DecodeString(strings.Join(strings.Fields(text[len(dsclPrefix):]), ""))
+		if err != nil {
+			return nil, errors.New("shadowhash: invalid hexadecimal in dscl output")
+		}
+		data = decoded
+	}
+
+	v, err := decodePlist(data)
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errPlist
+	}
+
+	// A user record holds ShadowHashData as an array of one data value,
+	// which is itself a property list.
+	if record, ok := dict["ShadowHashData"].([]interface{}); ok {
+		if len(record) != 1 {
+			return nil, errPlist
+		}
+		inner, ok := record[0].([]byte)
+		if !ok {
+			return nil, errPlist
+		}
+		return Parse(inner)
+	}
+
+	verifier, ok := dict[Scheme].(map[string]interface{})
+	if !ok {
+		return nil, ErrNoVerifier
+	}
+	entropy, ok1 := verifier["entropy"].([]byte)
+	salt, ok2 := verifier["salt"].([]byte)
+	iterations, ok3 := verifier["iterations"].(int64)
+	if !ok1 || !ok2 || !ok3 || len(entropy) == 0 || len(salt) == 0 || iterations <= 0 || iterations > 1<<32-1 {
+		return nil, pbkdf2.ErrInvalidHash
+	}
+	return &ShadowHash{Entropy: entropy, Salt: salt, Iterations: uint32(iterations)}, nil
+}
+
+// Verify returns nil if password matches the verifier, and
+// pbkdf2.ErrMismatchedHashAndPassword otherwise.
+func (s *ShadowHash) Verify(password string) error {
+	secret := pbkdf2.SecureBytesFromString(password)
+	defer secret.Destroy()
+
+	derived := xpbkdf2.Key(secret.Bytes(), s.Salt, int(s.Iterations), len(s.Entropy), sha512.New)
+	runtime.KeepAlive(secret)
+	defer func() {
+		for i := range derived {
+			derived[i] = 0
+		}
+	}()
+	if subtle.ConstantTimeCompare(derived, s.Entropy) != 1 {
+		return pbkdf2.ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+// ToHash converts the verifier into a pbkdf2 hash, which verifies the same
+// passwords with pbkdf2.CheckHash.
+func (s *ShadowHash) ToHash() *pbkdf2.Hash {
+	return &pbkdf2.Hash{
+		Params: pbkdf2.Params{
+			Iterations: s.Iterations,
+			SaltLength: uint32(len(s.Salt)),
+			KeyLength:  uint32(len(s.Entropy)),
+		},
+		Salt: append([]byte(nil), s.Salt...),
+		Key:  append([]byte(nil), s.Entropy...),
+	}
+}
@@ -0,0 +1,104 @@
+package shadowhash
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// The files in testdata were generated with Python's plistlib from a
+// verifier computed with hashlib.pbkdf2_hmac("sha512", b"pa$$word",
+// bytes(range(32)), 1000, 128), alongside an SRP verifier that Parse ignores.
+func TestParse(t *testing.T) {
+	for _, name := range []string{"shadowhashdata.bplist", "shadowhashdata.plist", "user.plist", "dscl.txt"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := Parse(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if s.Iterations != 1000 || len(s.Salt) != 32 || len(s.Entropy) != 128 {
+			t.Fatalf("%s: unexpected verifier %d iterations, %d byte salt, %d byte entropy", name, s.Iterations, len(s.Salt), len(s.Entropy))
+		}
+		if err := s.Verify("pa$$word"); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if err := s.Verify("password"); err != pbkdf2.ErrMismatchedHashAndPassword {
+			t.Errorf("%s: expected ErrMismatchedHashAndPassword, got %v", name, err)
+		}
+		if err := pbkdf2.Verify("pa$$word", s.ToHash().String()); err != nil {
+			t.Errorf("%s: converted hash does not verify: %v", name, err)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "shadowhashdata.bplist"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(data); i++ {
+		// Truncated input must be rejected without panicking.
+		Parse(data[:i])
+	}
+
+	noVerifier := []byte(`<?xml version="1.0"?><plist version="1.0"><dict><key>other</key><data>AAAA</data></dict></plist>`)
+	if _, err := Parse(noVerifier); err != ErrNoVerifier {
+		t.Errorf("expected ErrNoVerifier, got %v", err)
+	}
+	if _, err := Parse([]byte("not a plist")); err == nil {
+		t.Error("expected an error for invalid input")
+	}
+}
+
+// sharedRefsPlist returns a binary plist of n arrays, each referencing the
+// next one twice, which would decode to 2^n arrays if shared references were
+// expanded.
+func sharedRefsPlist(n int) []byte {
+	data := []byte("bplist00")
+	offsets := make([]byte, 0, n+1)
+	for i := 0; i < n; i++ {
+		offsets = append(offsets, byte(len(data)))
+		data = append(data, 0xa2, byte(i+1), byte(i+1))
+	}
+	offsets = append(offsets, byte(len(data)))
+	data = append(data, 0xa0)
+	tableOffset := len(data)
+	data = append(data, offsets...)
+
+	trailer := make([]byte, 32)
+	trailer[6], trailer[7] = 1, 1
+	binary.BigEndian.PutUint64(trailer[8:], uint64(len(offsets)))
+	binary.BigEndian.PutUint64(trailer[24:], uint64(tableOffset))
+	return append(data, trailer...)
+}
+
+func TestParseSharedRefs(t *testing.T) {
+	data := sharedRefsPlist(31)
+	if len(data) != 166 {
+		t.Fatalf("expected a 166-byte plist, got %d bytes", len(data))
+	}
+	v, err := decodePlist(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, ok := v.([]interface{}); !ok || len(a) != 2 {
+		t.Fatalf("unexpected top object %#v", v)
+	}
+	if _, err := Parse(data); err == nil {
+		t.Error("expected an error for a plist without a verifier")
+	}
+
+	// An array referencing itself must be rejected rather than decoded to
+	// the depth limit.
+	cycle := sharedRefsPlist(1)
+	cycle[9], cycle[10] = 0, 0
+	if _, err := decodePlist(cycle); err == nil {
+		t.Error("expected an error for a cyclic plist")
+	}
+}
@@ -0,0 +1,66 @@
+package pbkdf2
+
+import "strings"
+
+// A Segment is a substring of an encoded hash, with its byte offsets, so that
+// hash[Start:End] == Value.
+type Segment struct {
+	Value      string
+	Start, End int
+}
+
+// Segments holds the raw segments of an encoded hash, as returned by
+// SplitHash.
+type Segments struct {
+	Variant Segment
+
+	// Metadata is the zero Segment if the hash records no metadata.
+	Metadata Segment
+
+	Iterations Segment
+	Salt       Segment
+	Key        Segment
+}
+
+// SplitHash splits a hash in FormatPHC or FormatLegacySHA1 into its raw
+// segments, without decoding or validating them, for tooling such as log
+// scrubbers and database checkers that needs the untouched text:
+//
+//	s, err := pbkdf2.SplitHash(hash)
+//	if err == nil {
+//		redacted := hash[:s.Salt.Start] + "<redacted>"
+//	}
+//
+// It only checks the layout: a leading "$", and four or five non-empty
+// segments separated by "$". It returns an
+// error wrapping ErrInvalidHash otherwise. Use ParseHash to decode a hash.
+func SplitHash(hash string) (*Segments, error) {
+	if !strings.HasPrefix(hash, "$") {
+		return nil, decodeError("format", nil)
+	}
+	var segs []Segment
+	start := 1
+	for i := 1; i <= len(hash); i++ {
+		if i == len(hash) || hash[i] == '$' {
+			segs = append(segs, Segment{Value: hash[start:i], Start: start, End: i})
+			start = i + 1
+		}
+	}
+	if len(segs) != 4 && len(segs) != 5 {
+		return nil, decodeError("format", nil)
+	}
+
+	for _, seg := range segs {
+		if seg.Value == "" {
+			return nil, decodeError("format", nil)
+		}
+	}
+
+	s := &Segments{Variant: segs[0]}
+	if len(segs) == 5 {
+		s.Metadata = segs[1]
+		segs = segs[1:]
+	}
+	s.Iterations, s.Salt, s.Key = segs[1], segs[2], segs[3]
+	return s, nil
+}
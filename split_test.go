@@ -0,0 +1,55 @@
+package pbkdf2
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSplitHash(t *testing.T) {
+	const hash = "$pbkdf2-sha512$tenant=acme$1000$KuwdBW88vV7YiVGWsMmc8g$XO+ztCemYHheH1kqHe6QAmb99lL3MI7IeBQ05dnAXGk"
+	s, err := SplitHash(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"variant":    "pbkdf2-sha512",
+		"metadata":   "tenant=acme",
+		"iterations": "1000",
+		"salt":       "KuwdBW88vV7YiVGWsMmc8g",
+		"key":        "XO+ztCemYHheH1kqHe6QAmb99lL3MI7IeBQ05dnAXGk",
+	}
+	for name, seg := range map[string]Segment{
+		"variant": s.Variant, "metadata": s.Metadata, "iterations": s.Iterations, "salt": s.Salt, "key": s.Key,
+	} {
+		if seg.Value != want[name] || hash[seg.Start:seg.End] != seg.Value {
+			t.Errorf("%s: got %+v, want %q", name, seg, want[name])
+		}
+	}
+	if s.Key.End != len(hash) || s.Variant.Start != 1 {
+		t.Errorf("unexpected offsets %+v", s)
+	}
+
+	// Segments are not decoded, so neither the variant nor the base64 is
+	// checked, and legacy hashes split too.
+	s, err = SplitHash("$pbkdf2$12$not*base64$a.b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Metadata != (Segment{}) || s.Variant.Value != "pbkdf2" || s.Salt.Value != "not*base64" {
+		t.Errorf("unexpected segments %+v", s)
+	}
+
+	for _, bad := range []string{
+		"",
+		"pbkdf2-sha512$1000$salt$key",
+		"$pbkdf2-sha512$1000$salt",
+		"$pbkdf2-sha512$1000$salt$key$more$extra",
+		"$pbkdf2-sha512$1000$$key",
+		"$pbkdf2-sha512$$1000$salt$key",
+		"$pbkdf2-sha512$1000$salt$key$",
+	} {
+		if _, err := SplitHash(bad); !errors.Is(err, ErrInvalidHash) {
+			t.Errorf("SplitHash(%q): expected ErrInvalidHash, got %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Package sqlhash provides column types for storing pbkdf2 hashes with
+// database/sql and ORMs built on it, such as GORM.
+//
+// Hashes are validated when read, so a corrupted or truncated column is
+// reported at load time rather than as a failed login, and always written in
+// their canonical string form:
+//
+//	type User struct {
+//		ID       uint
+//		Password sqlhash.Hash
+//	}
+//
+//	h, err := pbkdf2.ParseHash(encoded)
+//	user := User{Password: sqlhash.Hash{Hash: *h}}
+//	db.Create(&user)
+//
+// GORM stores both types as strings; use a column type such as
+// varchar(255) or text.
+package sqlhash
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// ErrNull is returned when scanning a NULL column into a Hash. Use NullHash
+// for nullable columns.
+var ErrNull = errors.New("sqlhash: cannot scan NULL into Hash")
+
+// Hash is a pbkdf2.Hash that implements sql.Scanner and driver.Valuer.
+type Hash struct {
+	pbkdf2.Hash
+}
+
+// Scan implements sql.Scanner. It accepts strings and byte slices containing
+// a hash in the form produced by pbkdf2.CreateHash, and returns the error from
+// pbkdf2.ParseHash if the value is not a valid hash.
+func (h *Hash) Scan(src interface{}) error {
+	var text string
+	switch v := src.(type) {
+	case nil:
+		return ErrNull
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return fmt.Errorf("sqlhash: cannot scan %T into Hash", src)
+	}
+
+	parsed, err := pbkdf2.ParseHash(text)
+	if err != nil {
+		return err
+	}
+	h.Hash = *parsed
+	return nil
+}
+
+// Value implements driver.Valuer. It returns the canonical string form of the
+// hash, or the error from pbkdf2.Hash.MarshalText if the hash is not valid.
+func (h Hash) Value() (driver.Value, error) {
+	text, err := h.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return string(text), nil
+}
+
+// GormDataType returns the GORM data type of the column.
+func (Hash) GormDataType() string {
+	return "string"
+}
+
+// NullHash is a Hash that may be NULL, for example for users who sign in
+// only with an external identity provider.
+type NullHash struct {
+	Hash  Hash
+	Valid bool // Valid is true if Hash is not NULL
+}
+
+// Scan implements sql.Scanner.
+func (n *NullHash) Scan(src interface{}) error {
+	if src == nil {
+		n.Hash, n.Valid = Hash{}, false
+		return nil
+	}
+	if err := n.Hash.Scan(src); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (n NullHash) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.Hash.Value()
+}
+
+// GormDataType returns the GORM data type of the column.
+func (NullHash) GormDataType() string {
+	return "string"
+}
@@ -0,0 +1,76 @@
+package sqlhash
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+var (
+	_ sql.Scanner   = (*Hash)(nil)
+	_ driver.Valuer = Hash{}
+	_ sql.Scanner   = (*NullHash)(nil)
+	_ driver.Valuer = NullHash{}
+)
+
+const testHash = "$pbkdf2-sha512$tenant=acme$1000$KuwdBW88vV7YiVGWsMmc8g$XO+ztCemYHheH1kqHe6QAmb99lL3MI7IeBQ05dnAXGk"
+
+func TestHashScanValue(t *testing.T) {
+	for _, src := range []interface{}{testHash, []byte(testHash)} {
+		var h Hash
+		if err := h.Scan(src); err != nil {
+			t.Fatal(err)
+		}
+		if h.Params.Iterations != 1000 || h.Metadata["tenant"] != "acme" {
+			t.Fatalf("unexpected hash %#v", h)
+		}
+
+		v, err := h.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != testHash {
+			t.Fatalf("expected %q got %q", testHash, v)
+		}
+	}
+
+	var h Hash
+	if err := h.Scan("$pbkdf2-sha512$1000$AA"); !errors.Is(err, pbkdf2.ErrInvalidHash) {
+		t.Fatalf("expected ErrInvalidHash, got %v", err)
+	}
+	if err := h.Scan(nil); err != ErrNull {
+		t.Fatalf("expected ErrNull, got %v", err)
+	}
+	if err := h.Scan(42); err == nil {
+		t.Fatal("expected error scanning an integer")
+	}
+	if _, err := (Hash{}).Value(); err != pbkdf2.ErrInvalidHash {
+		t.Fatalf("expected ErrInvalidHash writing a zero Hash, got %v", err)
+	}
+}
+
+func TestNullHash(t *testing.T) {
+	var n NullHash
+	if err := n.Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+	if n.Valid {
+		t.Fatal("expected NULL to be invalid")
+	}
+	if v, err := n.Value(); v != nil || err != nil {
+		t.Fatalf("expected nil value, got %v, %v", v, err)
+	}
+
+	if err := n.Scan(testHash); err != nil {
+		t.Fatal(err)
+	}
+	if !n.Valid {
+		t.Fatal("expected hash to be valid")
+	}
+	if v, err := n.Value(); v != testHash || err != nil {
+		t.Fatalf("expected %q, got %v, %v", testHash, v, err)
+	}
+}
@@ -0,0 +1,262 @@
+package store
+
+import (
+	"context"
+	"hash/maphash"
+	"sort"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// Reasons a salt is reported as weak by AuditSalts.
+const (
+	// SaltShort is reported for salts shorter than
+	// SaltAuditOptions.MinSaltLength.
+	SaltShort = "short"
+
+	// SaltRepeated is reported for salts consisting of a single repeated
+	// byte, such as all zeros, as written by an RNG that failed silently.
+	SaltRepeated = "repeated"
+
+	// SaltLowEntropy is reported for salts with fewer distinct byte values
+	// than half their length, which random salts of 8 bytes or more almost
+	// never have.
+	SaltLowEntropy = "low-entropy"
+)
+
+// DefaultAuditMemory is the size in bytes of the filter AuditSalts uses to
+// find candidate duplicates when SaltAuditOptions.Memory is zero. With it, a
+// store of ten million records produces a few tens of thousands of false
+// candidates, which the second pass discards.
+const DefaultAuditMemory = 16 << 20
+
+// DefaultMaxFindings is the number of duplicate and weak salts each reported
+// by AuditSalts when SaltAuditOptions.MaxFindings is zero.
+const DefaultMaxFindings = 1000
+
+// SaltAuditOptions configures AuditSalts.
+type SaltAuditOptions struct {
+	// Memory is the size in bytes of the filter used to find candidate
+	// duplicates, DefaultAuditMemory if zero. The memory used also grows
+	// with the number of candidates, which is small unless the filter is too
+	// small for the store or many salts really are duplicated.
+	Memory int
+
+	// MaxFindings bounds the number of entries in each of
+	// SaltAudit.Duplicates and SaltAudit.Weak, DefaultMaxFindings if zero.
+	// Findings beyond it are counted but not listed.
+	MaxFindings int
+
+	// MinSaltLength is the length in bytes below which salts are reported as
+	// SaltShort. If zero, 16 bytes are required, as recommended by NIST SP
+	// 800-132.
+	MinSaltLength int
+}
+
+// DuplicateSalt is a salt shared by more than one record.
+type DuplicateSalt struct {
+	Salt []byte
+
+	// Usernames are the users whose hashes have the salt, sorted.
+	Usernames []string
+}
+
+// WeakSalt is a salt that is unlikely to have been generated randomly.
+type WeakSalt struct {
+	Username string
+	Salt     []byte
+
+	// Reason is SaltShort, SaltRepeated or SaltLowEntropy.
+	Reason string
+}
+
+// SaltAudit is the result of AuditSalts.
+type SaltAudit struct {
+	// Records is the number of records read in the first pass.
+	Records int
+
+	// Undecodable is the number of records whose hashes could not be
+	// decoded by pbkdf2.DecodeHash, such as legacy SHA-1 hashes or hashes
+	// in other schemes. Their salts are not audited.
+	Undecodable int
+
+	// Duplicates lists the duplicated salts found, and DuplicateRecords
+	// counts the records sharing a salt with another, including those not
+	// listed because of SaltAuditOptions.MaxFindings.
+	Duplicates       []DuplicateSalt
+	DuplicateRecords int
+
+	// Weak lists the weak salts found, and WeakRecords counts them,
+	// including those not listed.
+	Weak        []WeakSalt
+	WeakRecords int
+}
+
+// OK reports whether the audit found no duplicate or weak salts.
+func (a *SaltAudit) OK() bool {
+	return a.DuplicateRecords == 0 && a.WeakRecords == 0
+}
+
+// AuditSalts reads every record of s and reports salts that are shared by
+// more than one record, or that look non-random, either of which almost
+// always means a broken RNG or copied rows:
+//
+//	audit, err := store.AuditSalts(ctx, s, store.SaltAuditOptions{})
+//	for _, d := range audit.Duplicates {
+//		log.Printf("salt shared by %d users: %v", len(d.Usernames), d.Usernames)
+//	}
+//
+// Memory is bounded regardless of the size of the store. The first pass adds
+// a fingerprint of each salt to a Bloom filter of SaltAuditOptions.Memory
+// bytes, remembering only the fingerprints already present, which are
+// candidate duplicates. A second pass with Range collects the records with
+// candidate fingerprints and compares their salts exactly, so false
+// candidates are discarded and every duplicate is reported. Records changed
+// between the passes may be missed.
+func AuditSalts(ctx context.Context, s Store, opts SaltAuditOptions) (*SaltAudit, error) {
+	memory := opts.Memory
+	if memory <= 0 {
+		memory = DefaultAuditMemory
+	}
+	maxFindings := opts.MaxFindings
+	if maxFindings <= 0 {
+		maxFindings = DefaultMaxFindings
+	}
+	minLength := opts.MinSaltLength
+	if minLength <= 0 {
+		minLength = 16
+	}
+
+	seed := maphash.MakeSeed()
+	fingerprint := func(salt []byte) uint64 {
+		var h maphash.Hash
+		h.SetSeed(seed)
+		h.Write(salt)
+		return h.Sum64()
+	}
+
+	audit := &SaltAudit{}
+	filter := newBloomFilter(memory)
+	candidates := make(map[uint64]struct{})
+	err := s.Range(ctx, func(username, hash string) error {
+		audit.Records++
+		_, salt, key, err := pbkdf2.DecodeHash(hash)
+		if err != nil {
+			audit.Undecodable++
+			return nil
+		}
+		wipe(key)
+
+		if reason := weakSalt(salt, minLength); reason != "" {
+			audit.WeakRecords++
+			if len(audit.Weak) < maxFindings {
+				audit.Weak = append(audit.Weak, WeakSalt{Username: username, Salt: salt, Reason: reason})
+			}
+		}
+		if f := fingerprint(salt); !filter.add(f) {
+			candidates[f] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return audit, err
+	}
+	sort.Slice(audit.Weak, func(i, j int) bool { return audit.Weak[i].Username < audit.Weak[j].Username })
+	if len(candidates) == 0 {
+		return audit, nil
+	}
+
+	owners := make(map[string][]string)
+	err = s.Range(ctx, func(username, hash string) error {
+		_, salt, key, err := pbkdf2.DecodeHash(hash)
+		if err != nil {
+			return nil
+		}
+		wipe(key)
+		if _, ok := candidates[fingerprint(salt)]; ok {
+			owners[string(salt)] = append(owners[string(salt)], username)
+		}
+		return nil
+	})
+	if err != nil {
+		return audit, err
+	}
+
+	for salt, usernames := range owners {
+		if len(usernames) < 2 {
+			continue
+		}
+		audit.DuplicateRecords += len(usernames)
+		sort.Strings(usernames)
+		audit.Duplicates = append(audit.Duplicates, DuplicateSalt{Salt: []byte(salt), Usernames: usernames})
+	}
+	sort.Slice(audit.Duplicates, func(i, j int) bool {
+		a, b := audit.Duplicates[i], audit.Duplicates[j]
+		if len(a.Usernames) != len(b.Usernames) {
+			return len(a.Usernames) > len(b.Usernames)
+		}
+		return a.Usernames[0] < b.Usernames[0]
+	})
+	if len(audit.Duplicates) > maxFindings {
+		audit.Duplicates = audit.Duplicates[:maxFindings]
+	}
+	return audit, nil
+}
+
+// weakSalt returns the reason salt is weak, or "" if it is not.
+func weakSalt(salt []byte, minLength int) string {
+	var seen [256]bool
+	distinct := 0
+	for _, b := range salt {
+		if !seen[b] {
+			seen[b] = true
+			distinct++
+		}
+	}
+	possible := len(salt)
+	if possible > 256 {
+		possible = 256
+	}
+	switch {
+	case len(salt) > 1 && distinct == 1:
+		return SaltRepeated
+	case len(salt) < minLength:
+		return SaltShort
+	case len(salt) >= 8 && distinct < possible/2:
+		return SaltLowEntropy
+	}
+	return ""
+}
+
+// bloomHashes is the number of bits set in the filter per fingerprint.
+const bloomHashes = 4
+
+// bloomFilter is a Bloom filter of 64-bit fingerprints, using double hashing
+// to derive its bit positions.
+type bloomFilter struct {
+	bits []uint64
+}
+
+func newBloomFilter(size int) *bloomFilter {
+	n := size / 8
+	if n < 1 {
+		n = 1
+	}
+	return &bloomFilter{bits: make([]uint64, n)}
+}
+
+// add adds f to the filter, reporting whether it was absent.
+func (b *bloomFilter) add(f uint64) bool {
+	m := uint64(len(b.bits)) * 64
+	h1, h2 := f, f>>32|f<<32|1
+	absent := false
+	for i := uint64(0); i < bloomHashes; i++ {
+		bit := (h1 + i*h2) % m
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if b.bits[word]&mask == 0 {
+			absent = true
+			b.bits[word] |= mask
+		}
+	}
+	return absent
+}
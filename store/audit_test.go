@@ -0,0 +1,120 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"strconv"
+	"testing"
+)
+
+// saltHash returns a hash with the given salt. Its key is random, as
+// AuditSalts does not verify passwords.
+func saltHash(t *testing.T, salt []byte) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawStdEncoding
+	return "$pbkdf2-sha512$1000$" + b64.EncodeToString(salt) + "$" + b64.EncodeToString(key)
+}
+
+func randomSalt(t *testing.T) []byte {
+	t.Helper()
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		t.Fatal(err)
+	}
+	return salt
+}
+
+func TestAuditSalts(t *testing.T) {
+	ctx := context.Background()
+	s := &MemoryStore{}
+	for i := 0; i < 500; i++ {
+		s.Put(ctx, "user"+strconv.Itoa(i), saltHash(t, randomSalt(t)))
+	}
+	shared := randomSalt(t)
+	s.Put(ctx, "copy1", saltHash(t, shared))
+	s.Put(ctx, "copy2", saltHash(t, shared))
+	s.Put(ctx, "copy3", saltHash(t, shared))
+	s.Put(ctx, "zeros", saltHash(t, make([]byte, 16)))
+	s.Put(ctx, "short", saltHash(t, shared[:8]))
+	s.Put(ctx, "ascii", saltHash(t, []byte("abababababababab")))
+	s.Put(ctx, "legacy", "not a hash")
+
+	// A tiny filter makes most fingerprints false candidates, which the
+	// second pass must discard.
+	for _, memory := range []int{0, 8} {
+		audit, err := AuditSalts(ctx, s, SaltAuditOptions{Memory: memory})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if audit.Records != 507 || audit.Undecodable != 1 {
+			t.Fatalf("memory %d: unexpected counts %+v", memory, audit)
+		}
+		if len(audit.Duplicates) != 1 || audit.DuplicateRecords != 3 {
+			t.Fatalf("memory %d: expected one duplicate, got %+v", memory, audit.Duplicates)
+		}
+		d := audit.Duplicates[0]
+		if !bytes.Equal(d.Salt, shared) || len(d.Usernames) != 3 || d.Usernames[0] != "copy1" || d.Usernames[2] != "copy3" {
+			t.Fatalf("memory %d: unexpected duplicate %+v", memory, d)
+		}
+
+		want := []WeakSalt{
+			{Username: "ascii", Reason: SaltLowEntropy},
+			{Username: "short", Reason: SaltShort},
+			{Username: "zeros", Reason: SaltRepeated},
+		}
+		if len(audit.Weak) != len(want) || audit.WeakRecords != len(want) {
+			t.Fatalf("memory %d: unexpected weak salts %+v", memory, audit.Weak)
+		}
+		for i, w := range want {
+			if audit.Weak[i].Username != w.Username || audit.Weak[i].Reason != w.Reason {
+				t.Errorf("memory %d: weak salt %d: expected %+v, got %+v", memory, i, w, audit.Weak[i])
+			}
+		}
+		if audit.OK() {
+			t.Error("expected audit to fail")
+		}
+	}
+}
+
+func TestAuditSaltsMaxFindings(t *testing.T) {
+	ctx := context.Background()
+	s := &MemoryStore{}
+	for i := 0; i < 5; i++ {
+		salt := randomSalt(t)
+		s.Put(ctx, "a"+strconv.Itoa(i), saltHash(t, salt))
+		s.Put(ctx, "b"+strconv.Itoa(i), saltHash(t, salt))
+		s.Put(ctx, "z"+strconv.Itoa(i), saltHash(t, bytes.Repeat([]byte{byte(i)}, 16)))
+	}
+
+	audit, err := AuditSalts(ctx, s, SaltAuditOptions{MaxFindings: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(audit.Duplicates) != 2 || audit.DuplicateRecords != 10 {
+		t.Fatalf("unexpected duplicates %d of %d", len(audit.Duplicates), audit.DuplicateRecords)
+	}
+	if len(audit.Weak) != 2 || audit.WeakRecords != 5 {
+		t.Fatalf("unexpected weak salts %d of %d", len(audit.Weak), audit.WeakRecords)
+	}
+}
+
+func TestAuditSaltsClean(t *testing.T) {
+	ctx := context.Background()
+	s := &MemoryStore{}
+	for i := 0; i < 100; i++ {
+		s.Put(ctx, "user"+strconv.Itoa(i), saltHash(t, randomSalt(t)))
+	}
+	audit, err := AuditSalts(ctx, s, SaltAuditOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !audit.OK() || audit.Records != 100 {
+		t.Fatalf("unexpected audit %+v", audit)
+	}
+}
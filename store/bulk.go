@@ -0,0 +1,363 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// Format identifies the encoding of a bulk import or export.
+type Format int
+
+const (
+	// CSV with two columns, username and hash. A first row consisting of
+	// exactly "username,hash" is treated as a header; Export always writes
+	// one. Hashes with metadata contain commas, so must be quoted.
+	CSV Format = iota + 1
+
+	// JSONL, one object per line with "username" and "hash" string fields:
+	//
+	//	{"username":"alice","hash":"$pbkdf2-sha512$210000$..."}
+	//
+	// Blank lines are ignored, and lines may be at most 1 MiB long.
+	JSONL
+)
+
+func (f Format) String() string {
+	switch f {
+	case CSV:
+		return "CSV"
+	case JSONL:
+		return "JSONL"
+	}
+	return "Format(" + strconv.Itoa(int(f)) + ")"
+}
+
+// DefaultProgressInterval is the number of records between progress callbacks
+// when ImportOptions.ProgressInterval or the interval passed to Export is
+// zero.
+const DefaultProgressInterval = 1000
+
+// Progress reports the state of a bulk import or export.
+type Progress struct {
+	// Records is the number of records read or written so far.
+	Records int
+
+	// Imported and Skipped count the records stored and skipped by Import.
+	// They are zero for Export.
+	Imported int
+	Skipped  int
+}
+
+// RecordError describes a record that could not be imported.
+type RecordError struct {
+	// Line is the line of the input the record starts on, or zero if the
+	// record did not come from an input file.
+	Line int
+
+	// Username is the username of the record, if it could be read.
+	Username string
+
+	// Err is the cause: a decoding or validation error from the hash,
+	// ErrExists for duplicates, or a syntax error.
+	Err error
+}
+
+func (e *RecordError) Error() string {
+	msg := "store"
+	if e.Line > 0 {
+		msg += ": line " + strconv.Itoa(e.Line)
+	}
+	if e.Username != "" {
+		msg += ": user " + strconv.Quote(e.Username)
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// ImportOptions configures Import.
+type ImportOptions struct {
+	// Format of the input. It must be set.
+	Format Format
+
+	// Validate checks each hash before it is stored. If nil, hashes must
+	// decode with pbkdf2.Validate and no policy. To enforce a policy, or to
+	// accept hashes in other schemes for later migration, supply a function
+	// such as:
+	//
+	//	func(hash string) error { return pbkdf2.Validate(hash, policy) }
+	Validate func(hash string) error
+
+	// Overwrite allows imported records to replace existing users. Without
+	// it, records for existing users fail with ErrExists. Usernames that
+	// appear more than once in the input always fail with ErrExists.
+	Overwrite bool
+
+	// OnError, if set, is called with each record that cannot be imported.
+	// If it returns nil, the record is skipped and the import continues;
+	// otherwise the import stops with the returned error. If OnError is nil,
+	// the import stops at the first invalid record, returning its
+	// *RecordError. Errors reading the input itself, such as an I/O error
+	// or an overlong JSONL line, always stop the import.
+	OnError func(*RecordError) error
+
+	// OnProgress, if set, is called every ProgressInterval records, and once
+	// more when the import finishes successfully.
+	OnProgress       func(Progress)
+	ProgressInterval int
+}
+
+// Import reads credentials from r and stores them in s. Records are processed
+// one at a time, so the input may be arbitrarily large, although the
+// usernames seen are remembered for duplicate detection. It returns the
+// progress made, which is accurate even if an error stops the import early;
+// records stored before that point are not rolled back.
+func Import(ctx context.Context, s Store, r io.Reader, opts ImportOptions) (Progress, error) {
+	validate := opts.Validate
+	if validate == nil {
+		validate = func(hash string) error { return pbkdf2.Validate(hash, nil) }
+	}
+	interval := opts.ProgressInterval
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+
+	next, err := newRecordReader(r, opts.Format)
+	if err != nil {
+		return Progress{}, err
+	}
+
+	var progress Progress
+	seen := make(map[string]struct{})
+	fail := func(recErr *RecordError) error {
+		progress.Skipped++
+		if opts.OnError == nil {
+			return recErr
+		}
+		return opts.OnError(recErr)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+		line, username, hash, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			recErr, ok := err.(*RecordError)
+			if !ok {
+				return progress, err
+			}
+			progress.Records++
+			if err := fail(recErr); err != nil {
+				return progress, err
+			}
+			continue
+		}
+		progress.Records++
+
+		if err := importRecord(ctx, s, username, hash, seen, validate, opts.Overwrite); err != nil {
+			var recErr *RecordError
+			if !errors.As(err, &recErr) {
+				return progress, err
+			}
+			recErr.Line = line
+			if err := fail(recErr); err != nil {
+				return progress, err
+			}
+		} else {
+			progress.Imported++
+		}
+
+		if opts.OnProgress != nil && progress.Records%interval == 0 {
+			opts.OnProgress(progress)
+		}
+	}
+
+	if opts.OnProgress != nil {
+		opts.OnProgress(progress)
+	}
+	return progress, nil
+}
+
+// importRecord validates and stores a single record. Problems with the record
+// itself are returned as a *RecordError; any other error comes from the store.
+func importRecord(ctx context.Context, s Store, username, hash string, seen map[string]struct{}, validate func(string) error, overwrite bool) error {
+	invalid := func(err error) error {
+		return &RecordError{Username: username, Err: err}
+	}
+	if username == "" {
+		return invalid(errors.New("empty username"))
+	}
+	if _, dup := seen[username]; dup {
+		return invalid(ErrExists)
+	}
+	seen[username] = struct{}{}
+
+	if err := validate(hash); err != nil {
+		return invalid(err)
+	}
+
+	if !overwrite {
+		_, err := s.Get(ctx, username)
+		switch {
+		case err == nil:
+			return invalid(ErrExists)
+		case err != ErrNotFound:
+			return err
+		}
+	}
+	return s.Put(ctx, username, hash)
+}
+
+// newRecordReader returns a function that reads the next record, returning
+// io.EOF at the end of the input. Records that cannot be parsed are reported
+// as a *RecordError, after which reading can continue; any other error means
+// the input cannot be read further.
+func newRecordReader(r io.Reader, format Format) (func() (line int, username, hash string, err error), error) {
+	switch format {
+	case CSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = 2
+		cr.ReuseRecord = true
+		first := true
+		return func() (int, string, string, error) {
+			for {
+				record, err := cr.Read()
+				if err == io.EOF {
+					return 0, "", "", io.EOF
+				}
+				line, _ := cr.FieldPos(0)
+				if err != nil {
+					var parseErr *csv.ParseError
+					if !errors.As(err, &parseErr) {
+						return 0, "", "", err
+					}
+					return parseErr.StartLine, "", "", &RecordError{Line: parseErr.StartLine, Err: err}
+				}
+				if first {
+					first = false
+					if record[0] == "username" && record[1] == "hash" {
+						continue
+					}
+				}
+				return line, record[0], record[1], nil
+			}
+		}, nil
+
+	case JSONL:
+		sc := bufio.NewScanner(r)
+		sc.Buffer(nil, 1<<20)
+		line := 0
+		return func() (int, string, string, error) {
+			invalid := func(err error) (int, string, string, error) {
+				return line, "", "", &RecordError{Line: line, Err: err}
+			}
+			for sc.Scan() {
+				line++
+				text := bytes.TrimSpace(sc.Bytes())
+				if len(text) == 0 {
+					continue
+				}
+
+				var record struct {
+					Username *string `json:"username"`
+					Hash     *string `json:"hash"`
+				}
+				dec := json.NewDecoder(bytes.NewReader(text))
+				dec.DisallowUnknownFields()
+				if err := dec.Decode(&record); err != nil {
+					return invalid(err)
+				}
+				if dec.More() {
+					return invalid(errors.New("more than one object on the line"))
+				}
+				if record.Username == nil || record.Hash == nil {
+					return invalid(errors.New(`"username" and "hash" are required`))
+				}
+				return line, *record.Username, *record.Hash, nil
+			}
+			if err := sc.Err(); err != nil {
+				return 0, "", "", fmt.Errorf("store: line %d: %w", line+1, err)
+			}
+			return 0, "", "", io.EOF
+		}, nil
+	}
+	return nil, fmt.Errorf("store: unsupported format %v", format)
+}
+
+// Export writes every user in s to w in the given format, calling onProgress,
+// if not nil, every interval records and once more at the end. It returns the
+// number of records written.
+func Export(ctx context.Context, s Store, w io.Writer, format Format, interval int, onProgress func(Progress)) (int, error) {
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+
+	var write func(username, hash string) error
+	var flush func() error
+	switch format {
+	case CSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"username", "hash"}); err != nil {
+			return 0, err
+		}
+		write = func(username, hash string) error {
+			return cw.Write([]string{username, hash})
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+
+	case JSONL:
+		bw := bufio.NewWriter(w)
+		enc := json.NewEncoder(bw)
+		enc.SetEscapeHTML(false)
+		write = func(username, hash string) error {
+			return enc.Encode(struct {
+				Username string `json:"username"`
+				Hash     string `json:"hash"`
+			}{username, hash})
+		}
+		flush = bw.Flush
+
+	default:
+		return 0, fmt.Errorf("store: unsupported format %v", format)
+	}
+
+	var progress Progress
+	err := s.Range(ctx, func(username, hash string) error {
+		if err := write(username, hash); err != nil {
+			return err
+		}
+		progress.Records++
+		if onProgress != nil && progress.Records%interval == 0 {
+			onProgress(progress)
+		}
+		return nil
+	})
+	if err != nil {
+		return progress.Records, err
+	}
+	if err := flush(); err != nil {
+		return progress.Records, err
+	}
+	if onProgress != nil {
+		onProgress(progress)
+	}
+	return progress.Records, nil
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+const (
+	hashA = "$pbkdf2-sha512$1000$KuwdBW88vV7YiVGWsMmc8g$XO+ztCemYHheH1kqHe6QAmb99lL3MI7IeBQ05dnAXGk"
+	hashB = "$pbkdf2-sha512$2000$KuwdBW88vV7YiVGWsMmc8g$XO+ztCemYHheH1kqHe6QAmb99lL3MI7IeBQ05dnAXGk"
+)
+
+func TestImportExportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	src := &MemoryStore{}
+	src.Put(ctx, "alice", hashA)
+	src.Put(ctx, "bob", hashB)
+	src.Put(ctx, `o"brien, jr`, hashA)
+
+	for _, format := range []Format{CSV, JSONL} {
+		var buf bytes.Buffer
+		n, err := Export(ctx, src, &buf, format, 0, nil)
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		if n != 3 {
+			t.Fatalf("%v: expected 3 records, got %d", format, n)
+		}
+
+		dst := &MemoryStore{}
+		var calls []Progress
+		progress, err := Import(ctx, dst, &buf, ImportOptions{
+			Format:           format,
+			OnProgress:       func(p Progress) { calls = append(calls, p) },
+			ProgressInterval: 2,
+		})
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		if want := (Progress{Records: 3, Imported: 3}); progress != want {
+			t.Fatalf("%v: expected %#v got %#v", format, want, progress)
+		}
+		if len(calls) != 2 || calls[0].Records != 2 || calls[1] != progress {
+			t.Fatalf("%v: unexpected progress callbacks %#v", format, calls)
+		}
+		if hash, _ := dst.Get(ctx, `o"brien, jr`); hash != hashA {
+			t.Fatalf("%v: expected username with quotes and commas to round-trip", format)
+		}
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	ctx := context.Background()
+	input := strings.Join([]string{
+		"username,hash",
+		"alice," + hashA,
+		"bob,$pbkdf2-sha512$1000$AA",
+		"alice," + hashB,
+		"carol," + hashB,
+		"dave",
+		"eve," + hashA,
+	}, "\n")
+
+	s := &MemoryStore{}
+	s.Put(ctx, "eve", hashB)
+
+	var errs []*RecordError
+	progress, err := Import(ctx, s, strings.NewReader(input), ImportOptions{
+		Format:  CSV,
+		OnError: func(e *RecordError) error { errs = append(errs, e); return nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Progress{Records: 6, Imported: 2, Skipped: 4}); progress != want {
+		t.Fatalf("expected %#v got %#v", want, progress)
+	}
+
+	wantLines := []int{3, 4, 6, 7}
+	if len(errs) != len(wantLines) {
+		t.Fatalf("expected %d errors, got %v", len(wantLines), errs)
+	}
+	for i, e := range errs {
+		if e.Line != wantLines[i] {
+			t.Errorf("error %d: expected line %d, got %d (%v)", i, wantLines[i], e.Line, e)
+		}
+	}
+	if !errors.Is(errs[0], pbkdf2.ErrInvalidHash) || errs[0].Username != "bob" {
+		t.Errorf("expected invalid hash for bob, got %v", errs[0])
+	}
+	if !errors.Is(errs[1], ErrExists) || !errors.Is(errs[3], ErrExists) {
+		t.Errorf("expected duplicates to be reported with ErrExists, got %v and %v", errs[1], errs[3])
+	}
+	if hash, _ := s.Get(ctx, "eve"); hash != hashB {
+		t.Error("expected existing user not to be overwritten")
+	}
+
+	// Without OnError, the first bad record stops the import.
+	_, err = Import(ctx, &MemoryStore{}, strings.NewReader(input), ImportOptions{Format: CSV})
+	var recErr *RecordError
+	if !errors.As(err, &recErr) || recErr.Line != 3 {
+		t.Fatalf("expected error on line 3, got %v", err)
+	}
+
+	// Overwrite replaces existing users, and a policy can be enforced.
+	policy := &pbkdf2.Policy{MinIterations: 2000}
+	progress, err = Import(ctx, s, strings.NewReader("eve,"+hashA+"\nfrank,"+hashB+"\n"), ImportOptions{
+		Format:    CSV,
+		Overwrite: true,
+		Validate:  func(hash string) error { return pbkdf2.Validate(hash, policy) },
+		OnError:   func(e *RecordError) error { errs = append(errs, e); return nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Imported != 1 || !errors.Is(errs[len(errs)-1], pbkdf2.ErrPolicyViolation) {
+		t.Fatalf("expected the below-policy record to be rejected, got %#v, %v", progress, errs[len(errs)-1])
+	}
+}
+
+func TestImportJSONLErrors(t *testing.T) {
+	input := strings.Join([]string{
+		`{"username":"alice","hash":"` + hashA + `"}`,
+		``,
+		`{"username":"bob"}`,
+		`{"username":"carol","hash":"` + hashA + `","admin":true}`,
+		`not json`,
+	}, "\n")
+
+	var lines []int
+	progress, err := Import(context.Background(), &MemoryStore{}, strings.NewReader(input), ImportOptions{
+		Format:  JSONL,
+		OnError: func(e *RecordError) error { lines = append(lines, e.Line); return nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Imported != 1 || len(lines) != 3 || lines[0] != 3 || lines[1] != 4 || lines[2] != 5 {
+		t.Fatalf("unexpected result %#v, error lines %v", progress, lines)
+	}
+
+	// An overlong line cannot be skipped, so it stops the import even when
+	// OnError asks to continue.
+	input = `{"username":"alice","hash":"` + hashA + `"}` + "\n" + strings.Repeat("x", 1<<20+1) + "\n"
+	progress, err = Import(context.Background(), &MemoryStore{}, strings.NewReader(input), ImportOptions{
+		Format:  JSONL,
+		OnError: func(e *RecordError) error { return nil },
+	})
+	if !errors.Is(err, bufio.ErrTooLong) || progress != (Progress{Records: 1, Imported: 1}) {
+		t.Fatalf("expected bufio.ErrTooLong after one record, got %#v, %v", progress, err)
+	}
+
+	if _, err := Import(context.Background(), &MemoryStore{}, strings.NewReader(""), ImportOptions{}); err == nil {
+		t.Fatal("expected error for unset format")
+	}
+}
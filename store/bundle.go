@@ -0,0 +1,281 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// DefaultBundleIterations is the PBKDF2-HMAC-SHA512 iteration count used to
+// derive a bundle's key from a password when BundleKey.Iterations is zero.
+const DefaultBundleIterations = 600000
+
+// BundleKeySize is the required length of BundleKey.Key.
+const BundleKeySize = 32
+
+var (
+	// ErrInvalidBundle is returned by ImportBundle if its input is not a
+	// bundle.
+	ErrInvalidBundle = errors.New("store: input is not a credential bundle")
+
+	// ErrBundleKey is returned by ImportBundle if the bundle cannot be
+	// decrypted, because the key or password is wrong or the bundle has been
+	// modified.
+	ErrBundleKey = errors.New("store: bundle cannot be decrypted with the given key")
+)
+
+// A BundleKey protects a credential bundle. Exactly one of Password and Key
+// must be set.
+type BundleKey struct {
+	// Password is stretched with PBKDF2-HMAC-SHA512 into the encryption
+	// key, using Iterations when exporting. When importing, the iteration
+	// count recorded in the bundle is used instead.
+	Password   string
+	Iterations uint32
+
+	// Key is a random BundleKeySize-byte AES-256 key, for bundles that are
+	// exported and imported by automation holding the key in a secrets
+	// manager.
+	Key []byte
+}
+
+// A Manifest describes the contents of a credential bundle.
+type Manifest struct {
+	// Created is when the bundle was exported.
+	Created time.Time `json:"created"`
+
+	// Records is the number of credentials in the bundle.
+	Records int `json:"records"`
+
+	// PepperKeyIDs lists, in sorted order, the IDs of the pepper keys that
+	// the bundle's hashes were peppered with, as recorded by the pepper
+	// package. The keys themselves are never included: they must be made
+	// available to the environment the bundle is imported into separately.
+	PepperKeyIDs []string `json:"pepper_key_ids"`
+}
+
+// bundleHeader is the unencrypted first line of a bundle. It is authenticated
+// as additional data, so it cannot be modified without detection.
+type bundleHeader struct {
+	Format     string `json:"format"`
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations uint32 `json:"iterations,omitempty"`
+	Salt       []byte `json:"salt,omitempty"`
+	Nonce      []byte `json:"nonce"`
+}
+
+const (
+	bundleFormat  = "pbkdf2-credential-bundle"
+	bundleVersion = 1
+	kdfPBKDF2     = "pbkdf2-sha512"
+	kdfNone       = "none"
+
+	// pepperKeyID is the metadata key the pepper package records key IDs
+	// under.
+	pepperKeyID = "kid"
+)
+
+// ExportBundle writes every user in s to w as a single bundle, encrypted with
+// AES-256-GCM under key, for disaster recovery or for cloning an environment.
+// The bundle holds the stored hashes, which carry their own params and pepper
+// key IDs, and a Manifest; it never holds passwords or pepper keys.
+//
+// A bundle is an unencrypted JSON header line followed by the ciphertext of
+// the manifest and the users, in the JSONL format. The whole bundle is built
+// in memory, so it is suited to user bases of up to a few million users.
+func ExportBundle(ctx context.Context, s Store, w io.Writer, key BundleKey) (*Manifest, error) {
+	if err := key.check(); err != nil {
+		return nil, err
+	}
+
+	header := &bundleHeader{Format: bundleFormat, Version: bundleVersion, KDF: kdfNone}
+	if key.Key == nil {
+		header.KDF = kdfPBKDF2
+		header.Iterations = key.Iterations
+		if header.Iterations == 0 {
+			header.Iterations = DefaultBundleIterations
+		}
+		header.Salt = make([]byte, 16)
+		if _, err := io.ReadFull(pbkdf2.Rand, header.Salt); err != nil {
+			return nil, err
+		}
+	}
+
+	var records bytes.Buffer
+	keyIDs := make(map[string]struct{})
+	wrapped := &keyIDStore{Store: s, keyIDs: keyIDs}
+	n, err := Export(ctx, wrapped, &records, JSONL, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{Created: time.Now().UTC(), Records: n, PepperKeyIDs: []string{}}
+	for id := range keyIDs {
+		manifest.PepperKeyIDs = append(manifest.PepperKeyIDs, id)
+	}
+	sort.Strings(manifest.PepperKeyIDs)
+
+	plaintext, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	plaintext = append(plaintext, '\n')
+	plaintext = append(plaintext, records.Bytes()...)
+	defer wipe(plaintext)
+	defer wipe(records.Bytes())
+
+	aead, err := key.aead(header)
+	if err != nil {
+		return nil, err
+	}
+	header.Nonce = make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(pbkdf2.Rand, header.Nonce); err != nil {
+		return nil, err
+	}
+	headerLine, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	headerLine = append(headerLine, '\n')
+
+	bundle := aead.Seal(headerLine, header.Nonce, plaintext, headerLine)
+	if _, err := w.Write(bundle); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// ImportBundle decrypts a bundle written by ExportBundle and imports its users
+// into s, as Import would with opts; opts.Format is ignored. The bundle is
+// authenticated in full before any user is stored. It returns
+// ErrInvalidBundle if r does not hold a bundle, and ErrBundleKey if it cannot
+// be decrypted with key.
+func ImportBundle(ctx context.Context, s Store, r io.Reader, key BundleKey, opts ImportOptions) (*Manifest, Progress, error) {
+	if err := key.check(); err != nil {
+		return nil, Progress{}, err
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, Progress{}, err
+	}
+	i := bytes.IndexByte(data, '\n')
+	if i < 0 {
+		return nil, Progress{}, ErrInvalidBundle
+	}
+	headerLine, ciphertext := data[:i+1], data[i+1:]
+
+	var header bundleHeader
+	if err := json.Unmarshal(headerLine, &header); err != nil || header.Format != bundleFormat {
+		return nil, Progress{}, ErrInvalidBundle
+	}
+	if header.Version != bundleVersion {
+		return nil, Progress{}, errors.New("store: unsupported bundle version")
+	}
+	if (header.KDF == kdfNone) != (key.Key != nil) {
+		return nil, Progress{}, ErrBundleKey
+	}
+
+	aead, err := key.aead(&header)
+	if err != nil {
+		return nil, Progress{}, err
+	}
+	if len(header.Nonce) != aead.NonceSize() {
+		return nil, Progress{}, ErrInvalidBundle
+	}
+	plaintext, err := aead.Open(nil, header.Nonce, ciphertext, headerLine)
+	if err != nil {
+		return nil, Progress{}, ErrBundleKey
+	}
+	defer wipe(plaintext)
+
+	br := bufio.NewReader(bytes.NewReader(plaintext))
+	line, err := br.ReadBytes('\n')
+	if err != nil {
+		return nil, Progress{}, ErrInvalidBundle
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(line, &manifest); err != nil {
+		return nil, Progress{}, ErrInvalidBundle
+	}
+
+	opts.Format = JSONL
+	progress, err := Import(ctx, s, br, opts)
+	return &manifest, progress, err
+}
+
+func (k *BundleKey) check() error {
+	switch {
+	case (k.Password == "") == (k.Key == nil):
+		return errors.New("store: exactly one of BundleKey.Password and BundleKey.Key must be set")
+	case k.Key != nil && len(k.Key) != BundleKeySize:
+		return errors.New("store: BundleKey.Key must be 32 bytes")
+	}
+	return nil
+}
+
+// aead returns the cipher for a bundle with the given header, deriving the
+// key from the password if necessary.
+func (k *BundleKey) aead(header *bundleHeader) (cipher.AEAD, error) {
+	key := k.Key
+	switch header.KDF {
+	case kdfNone:
+	case kdfPBKDF2:
+		if header.Iterations == 0 || len(header.Salt) == 0 {
+			return nil, ErrInvalidBundle
+		}
+		params := &pbkdf2.Params{
+			Iterations: header.Iterations,
+			SaltLength: uint32(len(header.Salt)),
+			KeyLength:  BundleKeySize,
+		}
+		password := pbkdf2.SecureBytesFromString(k.Password)
+		defer password.Destroy()
+		derived, err := pbkdf2.DeriveKey(password, header.Salt, params)
+		if err != nil {
+			return nil, err
+		}
+		defer derived.Destroy()
+		key = derived.Bytes()
+	default:
+		return nil, ErrInvalidBundle
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// keyIDStore records the pepper key IDs of the hashes visited by Range.
+type keyIDStore struct {
+	Store
+	keyIDs map[string]struct{}
+}
+
+func (s *keyIDStore) Range(ctx context.Context, fn func(username, hash string) error) error {
+	return s.Store.Range(ctx, func(username, hash string) error {
+		if h, err := pbkdf2.ParseHash(hash); err == nil {
+			if id, ok := h.Metadata[pepperKeyID]; ok {
+				s.keyIDs[id] = struct{}{}
+			}
+		}
+		return fn(username, hash)
+	})
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
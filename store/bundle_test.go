@@ -0,0 +1,88 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+const hashPeppered = "$pbkdf2-sha512$kid=k2,pepper=hmac$1000$KuwdBW88vV7YiVGWsMmc8g$XO+ztCemYHheH1kqHe6QAmb99lL3MI7IeBQ05dnAXGk"
+
+func TestBundleRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	src := &MemoryStore{}
+	src.Put(ctx, "alice", hashA)
+	src.Put(ctx, "bob", hashPeppered)
+
+	for _, key := range []BundleKey{
+		{Password: "correct horse", Iterations: 1000},
+		{Key: bytes.Repeat([]byte{7}, BundleKeySize)},
+	} {
+		var buf bytes.Buffer
+		manifest, err := ExportBundle(ctx, src, &buf, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if manifest.Records != 2 || len(manifest.PepperKeyIDs) != 1 || manifest.PepperKeyIDs[0] != "k2" {
+			t.Errorf("unexpected manifest %+v", manifest)
+		}
+		if bytes.Contains(buf.Bytes(), []byte("alice")) || bytes.Contains(buf.Bytes(), []byte(hashA)) {
+			t.Error("bundle contains plaintext credentials")
+		}
+
+		dst := &MemoryStore{}
+		imported, progress, err := ImportBundle(ctx, dst, bytes.NewReader(buf.Bytes()), key, ImportOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if progress.Imported != 2 || imported.Records != 2 || !imported.Created.Equal(manifest.Created) {
+			t.Errorf("unexpected result %+v, %+v", imported, progress)
+		}
+		if hash, _ := dst.Get(ctx, "bob"); hash != hashPeppered {
+			t.Errorf("unexpected hash %q", hash)
+		}
+	}
+}
+
+func TestImportBundleErrors(t *testing.T) {
+	ctx := context.Background()
+	src := &MemoryStore{}
+	src.Put(ctx, "alice", hashA)
+
+	key := BundleKey{Password: "correct horse", Iterations: 1000}
+	var buf bytes.Buffer
+	if _, err := ExportBundle(ctx, src, &buf, key); err != nil {
+		t.Fatal(err)
+	}
+	bundle := buf.Bytes()
+
+	tampered := append([]byte(nil), bundle...)
+	tampered[len(tampered)-1] ^= 1
+	header := bytes.Replace(bundle, []byte(`"iterations":1000`), []byte(`"iterations":1001`), 1)
+
+	for name, tc := range map[string]struct {
+		bundle []byte
+		key    BundleKey
+		err    error
+	}{
+		"wrong password": {bundle, BundleKey{Password: "wrong"}, ErrBundleKey},
+		"raw key":        {bundle, BundleKey{Key: make([]byte, BundleKeySize)}, ErrBundleKey},
+		"tampered":       {tampered, key, ErrBundleKey},
+		"header":         {header, key, ErrBundleKey},
+		"not a bundle":   {[]byte("username,hash\n"), key, ErrInvalidBundle},
+	} {
+		dst := &MemoryStore{}
+		_, _, err := ImportBundle(ctx, dst, bytes.NewReader(tc.bundle), tc.key, ImportOptions{})
+		if err != tc.err {
+			t.Errorf("%s: expected %v, got %v", name, tc.err, err)
+		}
+		if dst.Len() != 0 {
+			t.Errorf("%s: users were imported", name)
+		}
+	}
+
+	if _, err := ExportBundle(ctx, src, &buf, BundleKey{}); err == nil || !strings.Contains(err.Error(), "exactly one") {
+		t.Errorf("expected an error for an empty key, got %v", err)
+	}
+}
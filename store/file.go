@@ -0,0 +1,263 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidUsername is returned by FileStore.Put for usernames that cannot
+// be stored in a credential file: empty, starting with "#", or containing a
+// colon or a line break.
+var ErrInvalidUsername = errors.New("store: invalid username")
+
+var errLineBreak = errors.New("store: hash contains a line break")
+
+// FileStore is a Store held in an htpasswd-style credential file, one user
+// per line as a username and an encoded hash separated by a colon:
+//
+//	# managed by pbkdf2 adduser
+//	alice:$pbkdf2-sha512$210000$yvu2ZftdlhcP4Tbpe2TYqA$XJsU2xkz...
+//
+// Blank lines and lines starting with # are kept as they are, and users stay
+// in the order they were added. Every call reads the file; calls that change
+// it write a new file beside it and rename it into place, so that readers
+// never see a partial update. Changes are serialized, across processes too,
+// by a lock on a file with the suffix ".lock" next to it, on platforms with
+// advisory file locks: Unix and Windows.
+//
+// FileStore suits small deployments managed with the pbkdf2 adduser, passwd
+// and deluser commands; each call costs a read of the whole file.
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileStore returns a FileStore for the credential file at path. The file
+// need not exist until the first Put creates it, with mode 0600.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Path returns the path of the credential file.
+func (s *FileStore) Path() string {
+	return s.path
+}
+
+// fileLine is a line of a credential file: a user, or a comment or blank line
+// if username is empty.
+type fileLine struct {
+	username, hash, raw string
+}
+
+// Get implements Store.
+func (s *FileStore) Get(ctx context.Context, username string) (string, error) {
+	var hash string
+	err := s.read(func(lines []fileLine) error {
+		for _, l := range lines {
+			if l.username == username {
+				hash = l.hash
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	return hash, err
+}
+
+// Put implements Store.
+func (s *FileStore) Put(ctx context.Context, username, hash string) error {
+	if !validFileUsername(username) {
+		return ErrInvalidUsername
+	}
+	if strings.ContainsAny(hash, "\r\n") {
+		return errLineBreak
+	}
+	return s.update(func(lines []fileLine) ([]fileLine, error) {
+		for i := range lines {
+			if lines[i].username == username {
+				lines[i].hash = hash
+				return lines, nil
+			}
+		}
+		return append(lines, fileLine{username: username, hash: hash}), nil
+	})
+}
+
+// Create stores hash for username, or returns ErrExists if the user already
+// exists. Unlike a Get followed by a Put, it holds the file's lock throughout,
+// so that two processes cannot both create the same user.
+func (s *FileStore) Create(ctx context.Context, username, hash string) error {
+	if !validFileUsername(username) {
+		return ErrInvalidUsername
+	}
+	if strings.ContainsAny(hash, "\r\n") {
+		return errLineBreak
+	}
+	return s.update(func(lines []fileLine) ([]fileLine, error) {
+		for _, l := range lines {
+			if l.username == username {
+				return nil, ErrExists
+			}
+		}
+		return append(lines, fileLine{username: username, hash: hash}), nil
+	})
+}
+
+// Delete implements Store.
+func (s *FileStore) Delete(ctx context.Context, username string) error {
+	return s.update(func(lines []fileLine) ([]fileLine, error) {
+		for i := range lines {
+			if lines[i].username == username {
+				return append(lines[:i], lines[i+1:]...), nil
+			}
+		}
+		return nil, ErrNotFound
+	})
+}
+
+// Range implements Store, visiting users in file order. fn is called after
+// the file has been read and its lock released, so it may modify the store.
+func (s *FileStore) Range(ctx context.Context, fn func(username, hash string) error) error {
+	var users []fileLine
+	err := s.read(func(lines []fileLine) error {
+		users = lines
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, l := range users {
+		if l.username == "" {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(l.username, l.hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read calls fn with the lines of the file, under a shared lock. A missing
+// file has no lines.
+func (s *FileStore) read(fn func([]fileLine) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := lockFile(s.path+".lock", false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	lines, _, err := s.load()
+	if err != nil {
+		return err
+	}
+	return fn(lines)
+}
+
+// update replaces the lines of the file with those returned by fn, under an
+// exclusive lock.
+func (s *FileStore) update(fn func([]fileLine) ([]fileLine, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := lockFile(s.path+".lock", true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	lines, mode, err := s.load()
+	if err != nil {
+		return err
+	}
+	if lines, err = fn(lines); err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	for _, l := range lines {
+		if l.username == "" {
+			b.WriteString(l.raw)
+		} else {
+			b.WriteString(l.username + ":" + l.hash)
+		}
+		b.WriteByte('\n')
+	}
+	return writeFileAtomic(s.path, b.Bytes(), mode)
+}
+
+// load reads and parses the file, returning its lines and mode.
+func (s *FileStore) load() ([]fileLine, os.FileMode, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0o600, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	mode := os.FileMode(0o600)
+	if fi, err := os.Stat(s.path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	var lines []fileLine
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		text := strings.TrimRight(sc.Text(), "\r")
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			lines = append(lines, fileLine{raw: text})
+			continue
+		}
+		username, hash, ok := strings.Cut(text, ":")
+		if !ok || username == "" {
+			return nil, 0, errors.New("store: " + s.path + ":" + strconv.Itoa(n) + ": expected username:hash")
+		}
+		lines = append(lines, fileLine{username: username, hash: hash})
+	}
+	return lines, mode, sc.Err()
+}
+
+func validFileUsername(username string) bool {
+	return username != "" && !strings.HasPrefix(username, "#") &&
+		strings.TrimSpace(username) == username && !strings.ContainsAny(username, ":\r\n")
+}
+
+// writeFileAtomic writes data to a temporary file in the directory of path,
+// syncs it and renames it over path.
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
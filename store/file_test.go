@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "passwd")
+	s := NewFileStore(path)
+
+	if _, err := s.Get(ctx, "alice"); err != ErrNotFound {
+		t.Fatalf("Get from a missing file = %v, want ErrNotFound", err)
+	}
+	if err := os.WriteFile(path, []byte("# users\nalice:$pbkdf2-sha512$1$c2FsdA$a2V5\n\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, "bob", "hash:with:colons"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, "alice", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if hash, err := s.Get(ctx, "bob"); err != nil || hash != "hash:with:colons" {
+		t.Errorf("Get(bob) = %q, %v", hash, err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if want := "# users\nalice:new\n\nbob:hash:with:colons\n"; string(data) != want {
+		t.Errorf("file = %q, want %q", data, want)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0o640 {
+		t.Errorf("mode = %v, want the original 0640", fi.Mode().Perm())
+	}
+
+	var users []string
+	s.Range(ctx, func(username, hash string) error {
+		users = append(users, username)
+		return nil
+	})
+	if len(users) != 2 || users[0] != "alice" || users[1] != "bob" {
+		t.Errorf("Range visited %v", users)
+	}
+
+	if err := s.Create(ctx, "alice", "other"); err != ErrExists {
+		t.Errorf("Create of an existing user = %v, want ErrExists", err)
+	}
+	if err := s.Delete(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Create(ctx, "alice", "h"); err != nil {
+		t.Errorf("Create after Delete: %v", err)
+	}
+	if err := s.Delete(ctx, "carol"); err != ErrNotFound {
+		t.Errorf("Delete of a missing user = %v, want ErrNotFound", err)
+	}
+	for _, name := range []string{"", "#root", "a:b", "a\nb", " padded"} {
+		if err := s.Put(ctx, name, "h"); !errors.Is(err, ErrInvalidUsername) {
+			t.Errorf("Put(%q) = %v, want ErrInvalidUsername", name, err)
+		}
+	}
+}
+
+func TestFileStoreMalformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "passwd")
+	os.WriteFile(path, []byte("alice:h\nno colon here\n"), 0o600)
+	if _, err := NewFileStore(path).Get(context.Background(), "alice"); err == nil {
+		t.Error("Get succeeded on a malformed file")
+	}
+}
+
+func TestFileStoreConcurrent(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "passwd")
+	// Separate FileStores serialize only through the lock file, as separate
+	// processes would.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := NewFileStore(path).Put(ctx, "user"+string(rune('a'+i)), "h"); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	n := 0
+	NewFileStore(path).Range(ctx, func(string, string) error { n++; return nil })
+	if n != 8 {
+		t.Errorf("%d users after concurrent Puts, want 8", n)
+	}
+}
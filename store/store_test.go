@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := &MemoryStore{}
+
+	if _, err := s.Get(ctx, "alice"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	for _, u := range []string{"carol", "alice", "bob"} {
+		if err := s.Put(ctx, u, "hash-"+u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hash, err := s.Get(ctx, "alice"); err != nil || hash != "hash-alice" {
+		t.Fatalf("expected hash-alice, got %q, %v", hash, err)
+	}
+
+	var order []string
+	if err := s.Range(ctx, func(username, hash string) error {
+		order = append(order, username)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 3 || order[0] != "alice" || order[1] != "bob" || order[2] != "carol" {
+		t.Fatalf("expected users in order, got %v", order)
+	}
+
+	errStop := errors.New("stop")
+	if err := s.Range(ctx, func(string, string) error { return errStop }); err != errStop {
+		t.Fatalf("expected Range to return the callback's error, got %v", err)
+	}
+
+	if err := s.Delete(ctx, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, "bob"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if s.Len() != 2 {
+		t.Fatalf("expected 2 users, got %d", s.Len())
+	}
+}
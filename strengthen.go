@@ -0,0 +1,147 @@
+package pbkdf2
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// MetadataStages is the metadata key under which the iterations of the
+// stages added by Strengthen are recorded, separated by ".".
+const MetadataStages = "stages"
+
+// MaxStages is the largest number of stages a hash may record.
+const MaxStages = 8
+
+// ErrCannotStrengthen is returned by Strengthen for hashes whose stored key
+// cannot be strengthened: legacy SHA-1 hashes, hashes with MaxStages stages
+// already, and hashes with metadata this package does not interpret, such as
+// peppered hashes.
+var ErrCannotStrengthen = errors.New("pbkdf2: hash cannot be strengthened")
+
+// Strengthen returns hash with another stage of key derivation applied to its
+// stored key, without the password, so that operators can raise the cost of
+// cracking stored hashes at once rather than as users log in:
+//
+//	stronger, err := pbkdf2.Strengthen(stored, 400000)
+//
+// The stage runs PBKDF2 with the hash's variant over the stored key, with
+// the hash's salt and the given iterations, and replaces the stored key with
+// the result. The stage's iterations are appended to the hash's metadata
+// under MetadataStages, and every function that verifies hashes derives the
+// key through each recorded stage in turn, so the strengthened hash verifies
+// with the same password:
+//
+//	$pbkdf2-sha512$stages=400000$210000$<b64Salt>$<b64Key>
+//
+// Verifying takes as long as deriving every stage, so decoding rejects hashes
+// whose stages total more than MaxIterations, and Policy.CheckHash counts
+// every stage against Policy.MaxIterations. The iterations in the hash's
+// params, which minimum iterations are checked against, remain those of the
+// first stage; NeedsRehash therefore still reports hashes created with too
+// few iterations, which should be replaced once the password is known.
+//
+// The stage is not keyed, so that strengthened hashes verify anywhere the
+// password and hash are known; to make stored hashes useless without a
+// secret, pepper them with package pepper instead.
+//
+// It returns ErrInvalidParams if iterations is zero, or would take the total
+// over MaxIterations, ErrCannotStrengthen if hash cannot be strengthened, and
+// any error from decoding hash.
+func Strengthen(hash string, iterations uint32) (string, error) {
+	if isLegacySHA1(hash) {
+		return "", ErrCannotStrengthen
+	}
+	h, err := decodeHash(hash)
+	if err != nil {
+		return "", err
+	}
+	defer wipe(h.Salt)
+	defer wipe(h.Key)
+	for k := range h.Metadata {
+		if k != MetadataNamespace && k != MetadataNormalization && k != MetadataStages && k != MetadataProfile {
+			return "", ErrCannotStrengthen
+		}
+	}
+
+	stages, err := parseStages(h.Metadata[MetadataStages])
+	if err != nil {
+		return "", err
+	}
+	if len(stages) == MaxStages {
+		return "", ErrCannotStrengthen
+	}
+	total := uint64(h.Params.Iterations) + uint64(iterations)
+	for _, n := range stages {
+		total += uint64(n)
+	}
+	if iterations == 0 || total > MaxIterations {
+		return "", ErrInvalidParams
+	}
+
+	key := applyStages(NewSecureBytes(h.Key), h.Salt, &h.Params, []uint32{iterations})
+	defer key.Destroy()
+
+	metadata := make(map[string]string, len(h.Metadata)+1)
+	for k, v := range h.Metadata {
+		metadata[k] = v
+	}
+	metadata[MetadataStages] = formatStages(append(stages, iterations))
+	return encodeHash(h.Params.Variant, h.Params.Iterations, h.Salt, key.Bytes(), metadata), nil
+}
+
+// totalIterations returns the iterations of h's params plus those of every
+// stage recorded in its metadata, which verifying h derives through in turn.
+func totalIterations(h *Hash) (uint64, error) {
+	stages, err := parseStages(h.Metadata[MetadataStages])
+	if err != nil {
+		return 0, err
+	}
+	total := uint64(h.Params.Iterations)
+	for _, n := range stages {
+		total += uint64(n)
+	}
+	return total, nil
+}
+
+// parseStages parses the value recorded under MetadataStages.
+func parseStages(s string) ([]uint32, error) {
+	if s == "" {
+		return nil, nil
+	}
+	fields := strings.Split(s, ".")
+	if len(fields) > MaxStages {
+		return nil, decodeError("metadata", nil)
+	}
+	stages := make([]uint32, len(fields))
+	for i, f := range fields {
+		n, err := parseIterations(f)
+		if err != nil {
+			return nil, decodeError("metadata", nil)
+		}
+		stages[i] = n
+	}
+	return stages, nil
+}
+
+func formatStages(stages []uint32) string {
+	b := make([]byte, 0, 8*len(stages))
+	for i, n := range stages {
+		if i > 0 {
+			b = append(b, '.')
+		}
+		b = strconv.AppendUint(b, uint64(n), 10)
+	}
+	return string(b)
+}
+
+// applyStages derives key through each of stages in turn, destroying each
+// intermediate key, including key itself unless there are no stages.
+func applyStages(key *SecureBytes, salt []byte, params *Params, stages []uint32) *SecureBytes {
+	for _, n := range stages {
+		next := pbkdf2Key(key.Bytes(), salt, n, int(params.KeyLength), params.Variant)
+		key.Destroy()
+		key = NewSecureBytes(next)
+	}
+	return key
+}
@@ -0,0 +1,127 @@
+package pbkdf2
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Generated with Python's hashlib.pbkdf2_hmac, each stage deriving from the
+// previous stage's key with the same salt.
+const (
+	unstrengthenedHash = "$pbkdf2-sha256$1000$c2FsdFNBTFRzYWx0U0FMVA$RviVuqoYXwJlwdpwjXmZU95ZL1dsRTi366mc7XAO51s"
+	strengthenedHash   = "$pbkdf2-sha256$stages=500$1000$c2FsdFNBTFRzYWx0U0FMVA$PJm40N2DosU3hq25k858JbXqEsZZwxCt7eeEwr8QAOo"
+	twiceStrengthened  = "$pbkdf2-sha256$stages=500.2$1000$c2FsdFNBTFRzYWx0U0FMVA$aGKfVaY7tLWWMZcgKQGc2wHWooD8GWiYKy3Z35weTbQ"
+)
+
+func TestStrengthen(t *testing.T) {
+	once, err := Strengthen(unstrengthenedHash, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if once != strengthenedHash {
+		t.Fatalf("Strengthen = %s, want %s", once, strengthenedHash)
+	}
+	twice, err := Strengthen(once, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if twice != twiceStrengthened {
+		t.Fatalf("Strengthen = %s, want %s", twice, twiceStrengthened)
+	}
+
+	for _, hash := range []string{once, twice} {
+		if err := Verify("password", hash); err != nil {
+			t.Errorf("%s: %v", hash, err)
+		}
+		if err := Verify("wrong", hash); err != ErrMismatchedHashAndPassword {
+			t.Errorf("%s: expected ErrMismatchedHashAndPassword, got %v", hash, err)
+		}
+		if err := (&Hasher{AllowLegacySHA1: true}).Verify("password", hash); err != nil {
+			t.Errorf("Hasher: %s: %v", hash, err)
+		}
+	}
+	if ok, err := HashesEquivalent(unstrengthenedHash, once); err != nil || ok {
+		t.Errorf("expected strengthened hash not to be equivalent, got %v, %v", ok, err)
+	}
+}
+
+func TestStrengthenNamespace(t *testing.T) {
+	hash, err := (&Hasher{Params: &Params{Iterations: 100, SaltLength: 16, KeyLength: 32}, Namespace: "tenant-a"}).CreateHash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stronger, err := Strengthen(hash, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stronger, "ns=tenant-a") || !strings.Contains(stronger, "stages=100") {
+		t.Fatalf("expected metadata to be kept, got %s", stronger)
+	}
+	if err := (&Hasher{Namespace: "tenant-a"}).Verify("password", stronger); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStrengthenErrors(t *testing.T) {
+	if _, err := Strengthen(unstrengthenedHash, 0); err != ErrInvalidParams {
+		t.Errorf("expected ErrInvalidParams for zero iterations, got %v", err)
+	}
+	if _, err := Strengthen(unstrengthenedHash, MaxIterations); err != ErrInvalidParams {
+		t.Errorf("expected ErrInvalidParams beyond MaxIterations, got %v", err)
+	}
+	if _, err := Strengthen(legacySHA1Hashes[0], 1); err != ErrCannotStrengthen {
+		t.Errorf("expected ErrCannotStrengthen for SHA-1, got %v", err)
+	}
+	peppered := strings.Replace(unstrengthenedHash, "$1000$", "$pepper=aes-gcm$1000$", 1)
+	if _, err := Strengthen(peppered, 1); err != ErrCannotStrengthen {
+		t.Errorf("expected ErrCannotStrengthen for unknown metadata, got %v", err)
+	}
+
+	full := unstrengthenedHash
+	for i := 0; i < MaxStages; i++ {
+		var err error
+		if full, err = Strengthen(full, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := Strengthen(full, 1); err != ErrCannotStrengthen {
+		t.Errorf("expected ErrCannotStrengthen beyond MaxStages, got %v", err)
+	}
+
+	for _, stages := range []string{"0", "1..2", "01", "1.2.3.4.5.6.7.8.9"} {
+		hash := strings.Replace(strengthenedHash, "stages=500", "stages="+stages, 1)
+		if err := Verify("password", hash); !isDecodeError(err, "metadata") {
+			t.Errorf("stages=%s: expected invalid metadata, got %v", stages, err)
+		}
+	}
+}
+
+func TestStrengthenPolicy(t *testing.T) {
+	// The first stage is within the policy, but verifying would take
+	// 3001000 iterations.
+	expensive := strings.Replace(strengthenedHash, "stages=500", "stages=3000000", 1)
+	policy := &Policy{MaxIterations: 10000}
+
+	var policyErr *PolicyError
+	if err := Validate(expensive, policy); !errors.As(err, &policyErr) || policyErr.Value != 3001000 || !policyErr.Max {
+		t.Errorf("Validate: expected total iterations above the maximum, got %v", err)
+	}
+	if err := (&Hasher{Policy: policy}).Verify("password", expensive); !errors.As(err, &policyErr) {
+		t.Errorf("Hasher: expected *PolicyError, got %v", err)
+	}
+	if err := Validate(twiceStrengthened, policy); err != nil {
+		t.Errorf("expected stages within the policy to pass, got %v", err)
+	}
+
+	// Stages may not take the total past MaxIterations, whatever the policy.
+	overflow := strings.Replace(strengthenedHash, "stages=500", "stages=4294967295", 1)
+	if _, err := ParseHash(overflow); !errors.Is(err, ErrIterationsOverflow) {
+		t.Errorf("expected ErrIterationsOverflow, got %v", err)
+	}
+}
+
+func isDecodeError(err error, field string) bool {
+	de, ok := err.(*DecodeError)
+	return ok && de.Field == field
+}
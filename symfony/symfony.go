@@ -0,0 +1,194 @@
+// Package symfony verifies passwords hashed by the Pbkdf2PasswordEncoder of
+// the Symfony PHP framework (Pbkdf2PasswordHasher since Symfony 5.3), so that
+// users can be carried over when migrating an application from PHP to Go.
+//
+// Symfony stores only the encoded digest; the salt and the encoder's settings
+// are held elsewhere, typically the salt in a column next to the password and
+// the settings in security.yaml:
+//
+//	security:
+//	  encoders:
+//	    App\Entity\User:
+//	      algorithm: pbkdf2
+//	      hash_algorithm: sha512
+//	      iterations: 1000
+//	      key_length: 40
+//
+// The salt is passed to PBKDF2 as it is: unlike Symfony's message digest
+// encoder, the PBKDF2 encoder does not merge it into the password as
+// "password{salt}". An Encoder with the same settings verifies the stored
+// digests directly, and ToHash converts them, with their salt, into pbkdf2
+// hashes so that the out-of-band components can be dropped:
+//
+//	e := &symfony.Encoder{}
+//	if err := e.Verify(password, user.Password, user.Salt); err == nil {
+//		hash, _ := e.ToHash(user.Password, user.Salt)
+//		// ... store hash.String(), which pbkdf2.CheckHash verifies
+//	}
+package symfony
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+
+	xpbkdf2 "golang.org/x/crypto/pbkdf2"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// Symfony's defaults, used when the corresponding Encoder field is zero.
+const (
+	DefaultAlgorithm  = "sha512"
+	DefaultIterations = 1000
+	DefaultLength     = 40
+)
+
+// MaxPasswordLength is the length in bytes above which Symfony rejects
+// passwords without hashing them.
+const MaxPasswordLength = 4096
+
+var (
+	// ErrUnsupportedAlgorithm is returned if Encoder.Algorithm is not one of
+	// "sha1", "sha256" and "sha512".
+	ErrUnsupportedAlgorithm = errors.New("symfony: unsupported hash algorithm")
+
+	// ErrInvalidHash is returned if an encoded digest cannot be decoded, or
+	// has the wrong length for the encoder. It is the same value as
+	// pbkdf2.ErrInvalidHash.
+	ErrInvalidHash = pbkdf2.ErrInvalidHash
+)
+
+// An Encoder holds the settings of a Symfony Pbkdf2PasswordEncoder. The zero
+// value uses Symfony's defaults.
+type Encoder struct {
+	// Algorithm is the hash_algorithm setting: "sha1", "sha256" or
+	// "sha512".
+	Algorithm string
+
+	// Iterations and Length are the iterations and key_length settings;
+	// Length is the length of the digest in bytes, before encoding.
+	Iterations int
+	Length     int
+
+	// Hex is set if encode_as_base64 is false, in which case digests are
+	// encoded in lowercase hexadecimal rather than base64.
+	Hex bool
+}
+
+func (e *Encoder) settings() (prf func() hash.Hash, variant string, iterations, length int, err error) {
+	switch e.Algorithm {
+	case "", "sha512":
+		prf, variant = sha512.New, pbkdf2.VariantSHA512
+	case "sha256":
+		prf, variant = sha256.New, pbkdf2.VariantSHA256
+	case "sha1":
+		prf, variant = sha1.New, pbkdf2.VariantLegacySHA1
+	default:
+		return nil, "", 0, 0, ErrUnsupportedAlgorithm
+	}
+	iterations, length = e.Iterations, e.Length
+	if iterations <= 0 {
+		iterations = DefaultIterations
+	}
+	if length <= 0 {
+		length = DefaultLength
+	}
+	return prf, variant, iterations, length, nil
+}
+
+// Encode returns the digest Symfony would store for password and salt.
+func (e *Encoder) Encode(password, salt string) (string, error) {
+	prf, _, iterations, length, err := e.settings()
+	if err != nil {
+		return "", err
+	}
+	if len(password) > MaxPasswordLength {
+		return "", errors.New("symfony: password exceeds 4096 bytes")
+	}
+	digest := xpbkdf2.Key([]byte(password), []byte(salt), iterations, length, prf)
+	return e.encode(digest), nil
+}
+
+// Verify returns nil if password matches the encoded digest with the given
+// salt, pbkdf2.ErrMismatchedHashAndPassword if it does not, and any other
+// error if the digest cannot be decoded. As in Symfony, passwords longer than
+// MaxPasswordLength never match.
+func (e *Encoder) Verify(password, encoded, salt string) error {
+	prf, _, iterations, length, err := e.settings()
+	if err != nil {
+		return err
+	}
+	stored, err := e.decode(encoded, length)
+	if err != nil {
+		return err
+	}
+	if len(password) > MaxPasswordLength {
+		return pbkdf2.ErrMismatchedHashAndPassword
+	}
+
+	digest := xpbkdf2.Key([]byte(password), []byte(salt), iterations, length, prf)
+	if subtle.ConstantTimeCompare(digest, stored) != 1 {
+		return pbkdf2.ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+// ToHash converts an encoded digest and its salt into a pbkdf2 hash, which
+// verifies the same passwords. SHA-1 digests are converted to hashes in the
+// legacy $pbkdf2$ format, which can only be verified by a pbkdf2.Hasher with
+// AllowLegacySHA1 set. It returns ErrInvalidHash if the salt is empty, since
+// pbkdf2 hashes cannot represent one.
+func (e *Encoder) ToHash(encoded, salt string) (*pbkdf2.Hash, error) {
+	_, variant, iterations, length, err := e.settings()
+	if err != nil {
+		return nil, err
+	}
+	key, err := e.decode(encoded, length)
+	if err != nil {
+		return nil, err
+	}
+	if salt == "" || uint64(iterations) > 1<<32-1 {
+		return nil, ErrInvalidHash
+	}
+	if variant == pbkdf2.VariantSHA512 {
+		variant = ""
+	}
+
+	return &pbkdf2.Hash{
+		Params: pbkdf2.Params{
+			Iterations: uint32(iterations),
+			SaltLength: uint32(len(salt)),
+			KeyLength:  uint32(len(key)),
+			Variant:    variant,
+		},
+		Salt: []byte(salt),
+		Key:  key,
+	}, nil
+}
+
+func (e *Encoder) encode(digest []byte) string {
+	if e.Hex {
+		return hex.EncodeToString(digest)
+	}
+	return base64.StdEncoding.EncodeToString(digest)
+}
+
+func (e *Encoder) decode(encoded string, length int) ([]byte, error) {
+	var digest []byte
+	var err error
+	if e.Hex {
+		digest, err = hex.DecodeString(encoded)
+	} else {
+		digest, err = base64.StdEncoding.Strict().DecodeString(encoded)
+	}
+	if err != nil || len(digest) != length {
+		return nil, ErrInvalidHash
+	}
+	return digest, nil
+}
@@ -0,0 +1,80 @@
+package symfony
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// Generated with Python's hashlib.pbkdf2_hmac(algorithm, b"pa$$word",
+// b"5f3a9c1e2b", 1000, 40), which computes the same digest as the
+// hash_pbkdf2($algorithm, $raw, $salt, 1000, 40, true) call made by Symfony's
+// Pbkdf2PasswordEncoder with its default iterations and key length.
+var vectors = []struct {
+	algorithm, base64, hex string
+}{
+	{"sha512", "OQkIJL32cT/QBjHAVQwPMOPeVOV0rclnCW614ZaArk1kp0EHNBhfZA==", "39090824bdf6713fd00631c0550c0f30e3de54e574adc967096eb5e19680ae4d64a7410734185f64"},
+	{"sha256", "+CUpyIi/uYg1lPAvT5WEMQwpXb8kmxWTv9p59NhiITgTfGUzN8jN8w==", "f82529c888bfb9883594f02f4f9584310c295dbf249b1593bfda79f4d8622138137c653337c8cdf3"},
+	{"sha1", "DSC4z9f4ih2qc8moMz6pAUjGX9Yzdrt0+M7gZv7Ercl9bYkwa8fzCA==", "0d20b8cfd7f88a1daa73c9a8333ea90148c65fd63376bb74f8cee066fec4adc97d6d89306bc7f308"},
+}
+
+const salt = "5f3a9c1e2b"
+
+func TestEncoder(t *testing.T) {
+	for _, v := range vectors {
+		for _, tc := range []struct {
+			e       *Encoder
+			encoded string
+		}{
+			{&Encoder{Algorithm: v.algorithm}, v.base64},
+			{&Encoder{Algorithm: v.algorithm, Hex: true}, v.hex},
+		} {
+			got, err := tc.e.Encode("pa$$word", salt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.encoded {
+				t.Errorf("%s: got %s, want %s", v.algorithm, got, tc.encoded)
+			}
+			if err := tc.e.Verify("pa$$word", tc.encoded, salt); err != nil {
+				t.Errorf("%s: %v", v.algorithm, err)
+			}
+			if err := tc.e.Verify("password", tc.encoded, salt); err != pbkdf2.ErrMismatchedHashAndPassword {
+				t.Errorf("%s: expected ErrMismatchedHashAndPassword, got %v", v.algorithm, err)
+			}
+			if err := tc.e.Verify("pa$$word", tc.encoded, "other"); err != pbkdf2.ErrMismatchedHashAndPassword {
+				t.Errorf("%s: expected a different salt not to match, got %v", v.algorithm, err)
+			}
+		}
+	}
+}
+
+func TestToHash(t *testing.T) {
+	h := &pbkdf2.Hasher{AllowLegacySHA1: true}
+	for _, v := range vectors {
+		hash, err := (&Encoder{Algorithm: v.algorithm}).ToHash(v.base64, salt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := h.Verify("pa$$word", hash.String()); err != nil {
+			t.Errorf("%s: converted hash %s does not verify: %v", v.algorithm, hash, err)
+		}
+	}
+}
+
+func TestEncoderErrors(t *testing.T) {
+	e := &Encoder{}
+	if err := e.Verify("pa$$word", vectors[0].base64[:20], salt); err != ErrInvalidHash {
+		t.Errorf("expected ErrInvalidHash for a truncated digest, got %v", err)
+	}
+	if err := (&Encoder{Algorithm: "md5"}).Verify("pa$$word", vectors[0].base64, salt); err != ErrUnsupportedAlgorithm {
+		t.Errorf("expected ErrUnsupportedAlgorithm, got %v", err)
+	}
+	if err := e.Verify(strings.Repeat("a", MaxPasswordLength+1), vectors[0].base64, salt); err != pbkdf2.ErrMismatchedHashAndPassword {
+		t.Errorf("expected overlong passwords not to match, got %v", err)
+	}
+	if _, err := e.ToHash(vectors[0].base64, ""); err != ErrInvalidHash {
+		t.Errorf("expected ErrInvalidHash for an empty salt, got %v", err)
+	}
+}
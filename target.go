@@ -0,0 +1,144 @@
+package pbkdf2
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrInvalidTarget is returned by Calibrate and Target.Params if the target
+// duration is not positive, or the iteration bounds are inconsistent.
+var ErrInvalidTarget = errors.New("pbkdf2: invalid target")
+
+// ErrClockStopped is returned by calibration if the clock it measures with
+// does not advance, as with a FakeClock without a step.
+var ErrClockStopped = errors.New("pbkdf2: calibration clock did not advance")
+
+// calibrationSample is the minimum time spent measuring derivation speed. It
+// is long enough to smooth out timer resolution and scheduling noise, while
+// keeping calibration quick.
+const calibrationSample = 20 * time.Millisecond
+
+// stoppedClockIterations is the iteration count after which a clock that has
+// measured no time for a derivation is taken to be stopped. Deriving it takes
+// far longer than the resolution of any real clock.
+const stoppedClockIterations = 1 << 17
+
+// Calibrate returns a copy of base with Iterations set so that hashing takes
+// roughly d on the current machine. Only the variant and key length of base
+// affect the measurement; its Iterations are ignored. If base is nil, the
+// package-level default params are used.
+//
+// The result reflects the load on the machine at the time of the call, so it
+// should be made at startup rather than under peak load, and clamped with a
+// floor; see Target.
+func Calibrate(d time.Duration, base *Params) (*Params, error) {
+	return calibrate(d, base, SystemClock)
+}
+
+func calibrate(d time.Duration, base *Params, clock Clock) (*Params, error) {
+	if d <= 0 {
+		return nil, ErrInvalidTarget
+	}
+	if base == nil {
+		base = GetDefaultParams()
+	}
+	params := *base
+	params.Iterations = 1
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	password := SecureBytesFromString("calibration password")
+	defer password.Destroy()
+	salt := NewSecureBytes(make([]byte, params.SaltLength))
+	defer salt.Destroy()
+
+	// Double the iterations until a single derivation is long enough to
+	// time reliably, then scale linearly.
+	iterations := uint32(1000)
+	for {
+		params.Iterations = iterations
+		start := clock.Now()
+		deriveKey(password, salt, &params).Destroy()
+		elapsed := clock.Since(start)
+
+		if elapsed <= 0 && iterations >= stoppedClockIterations {
+			return nil, ErrClockStopped
+		}
+		if elapsed >= calibrationSample || iterations > MaxIterations/2 {
+			scaled := float64(iterations) * float64(d) / float64(elapsed)
+			switch {
+			case scaled < 1:
+				params.Iterations = 1
+			case scaled > MaxIterations:
+				params.Iterations = MaxIterations
+			default:
+				params.Iterations = uint32(scaled)
+			}
+			return &params, nil
+		}
+		iterations *= 2
+	}
+}
+
+// Target expresses the cost of hashing as a time budget rather than an
+// iteration count, so that the same configuration is right-sized on every
+// hardware tier. The iteration count is found with Calibrate the first time
+// Params is called, and then reused.
+//
+// A Target must not be copied after first use.
+type Target struct {
+	// Duration is the time hashing a password should take.
+	Duration time.Duration
+
+	// MinIterations and MaxIterations clamp the calibrated iteration count.
+	// A floor of at least the recommended minimum should always be set, so
+	// that a slow or overloaded machine cannot calibrate itself into weak
+	// hashes. Zero means no bound.
+	MinIterations uint32
+	MaxIterations uint32
+
+	// Base provides the salt length, key length and variant. If nil, those
+	// of the package-level default params are used.
+	Base *Params
+
+	// Clock, if set, replaces SystemClock for timing calibration, so that
+	// the result can be made deterministic in tests with a FakeClock whose
+	// step is the simulated time of a derivation.
+	Clock Clock
+
+	once   sync.Once
+	params *Params
+	err    error
+}
+
+// Params returns the params resolved from the target, calibrating on the first
+// call. It returns ErrInvalidTarget if Duration is not positive or
+// MinIterations exceeds MaxIterations, and ErrClockStopped if Clock does not
+// advance. The returned params are a copy, and may be modified.
+func (t *Target) Params() (*Params, error) {
+	t.once.Do(func() {
+		if t.MaxIterations != 0 && t.MinIterations > t.MaxIterations {
+			t.err = ErrInvalidTarget
+			return
+		}
+		params, err := calibrate(t.Duration, t.Base, clockOrSystem(t.Clock))
+		if err != nil {
+			t.err = err
+			return
+		}
+		if params.Iterations < t.MinIterations {
+			params.Iterations = t.MinIterations
+		}
+		if t.MaxIterations != 0 && params.Iterations > t.MaxIterations {
+			params.Iterations = t.MaxIterations
+		}
+		t.params = params
+	})
+	if t.err != nil {
+		return nil, t.err
+	}
+	params := *t.params
+	return &params, nil
+}
@@ -0,0 +1,84 @@
+package pbkdf2
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalibrate(t *testing.T) {
+	base := &Params{Iterations: 1, SaltLength: 16, KeyLength: 32, Variant: VariantSHA256}
+	params, err := Calibrate(50*time.Millisecond, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Iterations < 1000 || params.SaltLength != 16 || params.KeyLength != 32 || params.Variant != VariantSHA256 {
+		t.Fatalf("unexpected params %#v", *params)
+	}
+
+	// Doubling the target roughly doubles the iterations. The bounds are
+	// loose, as timing on shared machines is noisy.
+	doubled, err := Calibrate(100*time.Millisecond, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ratio := float64(doubled.Iterations) / float64(params.Iterations); ratio < 1.2 || ratio > 3.5 {
+		t.Errorf("expected iterations to roughly double, got ratio %.2f", ratio)
+	}
+
+	if _, err := Calibrate(0, nil); err != ErrInvalidTarget {
+		t.Fatalf("expected ErrInvalidTarget, got %v", err)
+	}
+	if _, err := Calibrate(time.Millisecond, &Params{}); err != ErrInvalidParams {
+		t.Fatalf("expected ErrInvalidParams, got %v", err)
+	}
+}
+
+func TestTarget(t *testing.T) {
+	base := &Params{SaltLength: 16, KeyLength: 32}
+
+	floored := &Target{Duration: time.Microsecond, MinIterations: 123456, Base: base}
+	params, err := floored.Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Iterations != 123456 {
+		t.Fatalf("expected the floor to apply, got %d iterations", params.Iterations)
+	}
+
+	// Resolution happens once; later calls return copies of the same params.
+	params.Iterations = 1
+	again, _ := floored.Params()
+	if again.Iterations != 123456 {
+		t.Fatal("expected Params to return a copy")
+	}
+
+	capped := &Target{Duration: time.Hour, MaxIterations: 5000, Base: base}
+	if params, err := capped.Params(); err != nil || params.Iterations != 5000 {
+		t.Fatalf("expected the ceiling to apply, got %v, %v", params, err)
+	}
+
+	if _, err := (&Target{Duration: time.Millisecond, MinIterations: 2, MaxIterations: 1}).Params(); err != ErrInvalidTarget {
+		t.Fatalf("expected ErrInvalidTarget, got %v", err)
+	}
+}
+
+func TestTargetClock(t *testing.T) {
+	// Each derivation appears to take 40ms, so 1000 iterations run at 25
+	// per millisecond.
+	clock := NewFakeClock(time.Unix(0, 0))
+	clock.SetStep(40 * time.Millisecond)
+	target := &Target{Duration: time.Second, Base: &Params{SaltLength: 16, KeyLength: 32}, Clock: clock}
+	params, err := target.Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Iterations != 25000 {
+		t.Fatalf("expected 25000 iterations, got %d", params.Iterations)
+	}
+
+	// A clock without a step never measures any time.
+	stopped := &Target{Duration: time.Second, Base: &Params{SaltLength: 16, KeyLength: 32}, Clock: NewFakeClock(time.Unix(0, 0))}
+	if _, err := stopped.Params(); err != ErrClockStopped {
+		t.Fatalf("expected ErrClockStopped, got %v", err)
+	}
+}
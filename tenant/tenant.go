@@ -0,0 +1,183 @@
+// Package tenant routes password hashing for multi-tenant systems, where each
+// tenant has its own params, policy and, optionally, pepper keys.
+//
+// A Registry maps tenant IDs to configurations. Hashes it creates are scoped
+// to the tenant's namespace, as with pbkdf2.Hasher.Namespace, which records
+// the tenant ID in the hash:
+//
+//	$pbkdf2-sha512$ns=acme$210000$<salt>$<key>
+//
+// so that Verify can select the right configuration from the hash alone:
+//
+//	tenantID, err := registry.Verify(password, storedHash)
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pganguli/pbkdf2"
+	"github.com/pganguli/pbkdf2/pepper"
+)
+
+var (
+	// ErrUnknownTenant is returned if a tenant is not registered, or a hash
+	// records no tenant.
+	ErrUnknownTenant = errors.New("tenant: unknown tenant")
+
+	// ErrExists is returned by Register if the tenant is already registered.
+	ErrExists = errors.New("tenant: tenant already registered")
+)
+
+// Config is the hashing configuration of a tenant.
+type Config struct {
+	// Params are used to create new hashes. If nil, the pbkdf2
+	// package-level default params are used.
+	Params *pbkdf2.Params
+
+	// Policy, if set, is checked against the params of every hash before it
+	// is verified.
+	Policy *pbkdf2.Policy
+
+	// Keyring, if set, holds the tenant's pepper keys, and new hashes are
+	// peppered with its active key using Mode. Hashes of a tenant with a
+	// keyring must be peppered.
+	Keyring *pepper.Keyring
+	Mode    pepper.Mode
+}
+
+// hasher is the part of pbkdf2.Hasher and pepper.Hasher used by a Registry.
+type hasher interface {
+	CreateHash(password string) (string, error)
+	CheckHash(password, hash string) (bool, error)
+}
+
+// unpeppered adapts a pbkdf2.Hasher to the hasher interface.
+type unpeppered struct {
+	*pbkdf2.Hasher
+}
+
+func (h unpeppered) CheckHash(password, hash string) (bool, error) {
+	match, _, err := h.Hasher.CheckHash(password, hash)
+	return match, err
+}
+
+// A Registry holds the configuration of each tenant. The zero value is an
+// empty registry ready to use. It is safe for concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	tenants map[string]hasher
+}
+
+// Register adds a tenant. The ID must satisfy pbkdf2.ValidNamespace. It
+// returns ErrExists if the tenant is already registered.
+func (r *Registry) Register(id string, c Config) error {
+	if !pbkdf2.ValidNamespace(id) {
+		return fmt.Errorf("tenant: invalid tenant ID %q", id)
+	}
+
+	var h hasher
+	if c.Keyring != nil {
+		h = &pepper.Hasher{Keyring: c.Keyring, Mode: c.Mode, Params: c.Params, Namespace: id, Policy: c.Policy}
+	} else {
+		h = unpeppered{&pbkdf2.Hasher{Params: c.Params, Policy: c.Policy, Namespace: id}}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tenants[id]; ok {
+		return ErrExists
+	}
+	if r.tenants == nil {
+		r.tenants = make(map[string]hasher)
+	}
+	r.tenants[id] = h
+	return nil
+}
+
+// Unregister removes a tenant. Its hashes can no longer be verified.
+func (r *Registry) Unregister(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tenants, id)
+}
+
+// Tenants returns the IDs of the registered tenants in sorted order.
+func (r *Registry) Tenants() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]string, 0, len(r.tenants))
+	for id := range r.tenants {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (r *Registry) hasher(id string) (hasher, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	h, ok := r.tenants[id]
+	if !ok {
+		return nil, ErrUnknownTenant
+	}
+	return h, nil
+}
+
+// CreateHash returns a hash of password created with the configuration of
+// the given tenant.
+func (r *Registry) CreateHash(tenantID, password string) (string, error) {
+	h, err := r.hasher(tenantID)
+	if err != nil {
+		return "", err
+	}
+	return h.CreateHash(password)
+}
+
+// CheckHash reports whether password matches hash, using the configuration of
+// the tenant recorded in the hash, and returns the tenant's ID. It returns
+// ErrUnknownTenant if the hash records no tenant, or one that is not
+// registered.
+func (r *Registry) CheckHash(password, hash string) (match bool, tenantID string, err error) {
+	tenantID, err = TenantOf(hash)
+	if err != nil {
+		return false, "", err
+	}
+	h, err := r.hasher(tenantID)
+	if err != nil {
+		return false, tenantID, err
+	}
+	match, err = h.CheckHash(password, hash)
+	return match, tenantID, err
+}
+
+// Verify is like CheckHash, except it returns
+// pbkdf2.ErrMismatchedHashAndPassword if the password does not match.
+func (r *Registry) Verify(password, hash string) (tenantID string, err error) {
+	match, tenantID, err := r.CheckHash(password, hash)
+	if err != nil {
+		return tenantID, err
+	}
+	if !match {
+		return tenantID, pbkdf2.ErrMismatchedHashAndPassword
+	}
+	return tenantID, nil
+}
+
+// TenantOf returns the ID of the tenant recorded in hash. It returns
+// ErrUnknownTenant if there is none, and any error from decoding the hash.
+func TenantOf(hash string) (string, error) {
+	h, err := pbkdf2.ParseHash(hash)
+	if err != nil {
+		return "", err
+	}
+	id := h.Metadata[pbkdf2.MetadataNamespace]
+	if id == "" {
+		return "", ErrUnknownTenant
+	}
+	return id, nil
+}
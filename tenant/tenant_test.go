@@ -0,0 +1,98 @@
+package tenant
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/pganguli/pbkdf2"
+	"github.com/pganguli/pbkdf2/pepper"
+)
+
+var testParams = &pbkdf2.Params{Iterations: 1000, SaltLength: 16, KeyLength: 32}
+
+func newRegistry(t *testing.T) *Registry {
+	t.Helper()
+	keyring, err := pepper.NewKeyring("k1", pepper.Key{ID: "k1", Secret: bytes.Repeat([]byte{1}, pepper.KeySize)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Registry{}
+	if err := r.Register("acme", Config{Params: testParams}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register("globex", Config{Params: testParams, Keyring: keyring, Mode: pepper.ModeHMAC}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register("initech", Config{
+		Params: testParams,
+		Policy: &pbkdf2.Policy{MinIterations: 2000},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRegistry(t *testing.T) {
+	r := newRegistry(t)
+	if got := r.Tenants(); len(got) != 3 || got[0] != "acme" || got[2] != "initech" {
+		t.Errorf("unexpected tenants %q", got)
+	}
+
+	for _, id := range []string{"acme", "globex"} {
+		hash, err := r.CreateHash(id, "password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tenantID, err := r.Verify("password", hash)
+		if err != nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+		if tenantID != id {
+			t.Errorf("expected tenant %q, got %q", id, tenantID)
+		}
+		if _, err := r.Verify("wrong", hash); err != pbkdf2.ErrMismatchedHashAndPassword {
+			t.Errorf("%s: expected ErrMismatchedHashAndPassword, got %v", id, err)
+		}
+	}
+
+	hash, err := r.CreateHash("initech", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Verify("password", hash); !errors.Is(err, pbkdf2.ErrPolicyViolation) {
+		t.Errorf("expected a policy violation, got %v", err)
+	}
+
+	r.Unregister("acme")
+	if _, err := r.CreateHash("acme", "password"); err != ErrUnknownTenant {
+		t.Errorf("expected ErrUnknownTenant, got %v", err)
+	}
+}
+
+func TestRegistryErrors(t *testing.T) {
+	r := newRegistry(t)
+	if err := r.Register("acme", Config{}); err != ErrExists {
+		t.Errorf("expected ErrExists, got %v", err)
+	}
+	if err := r.Register("a,b", Config{}); err == nil {
+		t.Error("expected an error for an invalid tenant ID")
+	}
+
+	plain, err := pbkdf2.CreateHash("password", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Verify("password", plain); err != ErrUnknownTenant {
+		t.Errorf("expected ErrUnknownTenant, got %v", err)
+	}
+
+	other, err := (&pbkdf2.Hasher{Params: testParams, Namespace: "umbrella"}).CreateHash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tenantID, err := r.Verify("password", other); err != ErrUnknownTenant || tenantID != "umbrella" {
+		t.Errorf("expected ErrUnknownTenant for umbrella, got %q, %v", tenantID, err)
+	}
+}
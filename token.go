@@ -0,0 +1,59 @@
+package pbkdf2
+
+import (
+	"encoding/base64"
+	"errors"
+)
+
+// MinTokenLength is the shortest token, in bytes, accepted by HashToken.
+const MinTokenLength = 16
+
+// ErrShortToken is returned by HashToken if the token is shorter than
+// MinTokenLength, and so unlikely to have the entropy that makes TokenParams
+// safe.
+var ErrShortToken = errors.New("pbkdf2: token is too short to hash with TokenParams")
+
+// TokenParams are the parameters HashToken uses, for secrets generated with at
+// least 128 bits of entropy by a cryptographic random number generator, such
+// as API keys and session tokens. Such secrets cannot be guessed however
+// cheaply each guess is checked, so a work factor adds cost without adding
+// security: a single iteration suffices, with a salt against precomputation
+// and the full 64-byte output of HMAC-SHA512, which costs no more than a
+// shorter key. Never use TokenParams for passwords.
+//
+// TokenParams is a copy of the params HashToken uses; modifying it has no
+// effect.
+var TokenParams = &Params{
+	Iterations: tokenParams.Iterations,
+	SaltLength: tokenParams.SaltLength,
+	KeyLength:  tokenParams.KeyLength,
+}
+
+var tokenParams = Params{Iterations: 1, SaltLength: 16, KeyLength: 64}
+
+// HashToken returns a hash of token created with TokenParams, which VerifyToken
+// verifies. It returns ErrShortToken if token is shorter than MinTokenLength.
+func HashToken(token string) (hash string, err error) {
+	if len(token) < MinTokenLength {
+		return "", ErrShortToken
+	}
+	params := tokenParams
+	return CreateHash(token, &params)
+}
+
+// VerifyToken returns nil if token matches hash, and
+// ErrMismatchedHashAndPassword if it does not. It is Verify under a name that
+// keeps token checks visibly separate from password checks.
+func VerifyToken(token, hash string) error {
+	return Verify(token, hash)
+}
+
+// GenerateToken returns a new token of 32 random bytes, encoded as unpadded
+// URL-safe base64, suitable for HashToken.
+func GenerateToken() (string, error) {
+	b := make([]byte, 32)
+	if err := readEntropy(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
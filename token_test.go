@@ -0,0 +1,50 @@
+package pbkdf2
+
+import "testing"
+
+func TestHashToken(t *testing.T) {
+	token, err := GenerateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) != 43 {
+		t.Fatalf("token %q has length %d, want 43", token, len(token))
+	}
+
+	hash, err := HashToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, _, _, err := DecodeHash(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *params != *TokenParams {
+		t.Errorf("hash params %+v, want %+v", params, TokenParams)
+	}
+	if err := VerifyToken(token, hash); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyToken(token+"x", hash); err != ErrMismatchedHashAndPassword {
+		t.Fatalf("expected ErrMismatchedHashAndPassword, got %v", err)
+	}
+
+	if _, err := HashToken("short"); err != ErrShortToken {
+		t.Fatalf("expected ErrShortToken, got %v", err)
+	}
+}
+
+func TestTokenParamsCopy(t *testing.T) {
+	saved := *TokenParams
+	defer func() { *TokenParams = saved }()
+
+	TokenParams.Iterations = 5
+	hash, err := HashToken("0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, _, _, _ := DecodeHash(hash)
+	if params.Iterations != 1 {
+		t.Fatalf("HashToken used modified TokenParams: %d iterations", params.Iterations)
+	}
+}
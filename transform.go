@@ -0,0 +1,148 @@
+package pbkdf2
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// MetadataNormalization is the metadata key under which the normalization
+// applied to a hash's password is recorded.
+const MetadataNormalization = "norm"
+
+// ErrUnknownNormalization is returned when a hash declares, or a Hasher
+// requests, a password normalization that has not been registered.
+var ErrUnknownNormalization = errors.New("pbkdf2: unknown password normalization")
+
+var (
+	normalizationsMu sync.RWMutex
+	normalizations   = map[string]func(string) string{}
+)
+
+// RegisterNormalization makes a password normalization available under name,
+// for example Unicode NFKC from golang.org/x/text:
+//
+//	pbkdf2.RegisterNormalization("nfkc", norm.NFKC.String)
+//
+// Once registered, it can be selected with Hasher.Normalization. Hashes
+// created with it record its name as metadata, and every function that
+// verifies hashes applies the normalization a hash declares, so that changing
+// the normalization used for new hashes never makes old ones unverifiable.
+//
+// f must produce the same output for the lifetime of every hash stored with
+// the normalization. Names must satisfy ValidNamespace. RegisterNormalization
+// is intended to be called from init functions. It panics if name is invalid
+// or already registered, or if f is nil.
+func RegisterNormalization(name string, f func(password string) string) {
+	if !validMetadataValue(name) {
+		panic("pbkdf2: RegisterNormalization called with invalid name " + strconv.Quote(name))
+	}
+	if f == nil {
+		panic("pbkdf2: RegisterNormalization called with nil function")
+	}
+
+	normalizationsMu.Lock()
+	defer normalizationsMu.Unlock()
+	if _, dup := normalizations[name]; dup {
+		panic("pbkdf2: RegisterNormalization called twice for " + strconv.Quote(name))
+	}
+	normalizations[name] = f
+}
+
+// Normalizations returns the names of the registered normalizations, sorted.
+func Normalizations() []string {
+	normalizationsMu.RLock()
+	defer normalizationsMu.RUnlock()
+
+	names := make([]string, 0, len(normalizations))
+	for name := range normalizations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyNormalization returns password transformed by the registered
+// normalization name, or unchanged if name is empty. It returns
+// ErrUnknownNormalization if name is not registered.
+//
+// ApplyNormalization is intended for building other schemes on top of this
+// package, such as peppering, which should record name under
+// MetadataNormalization.
+func ApplyNormalization(name, password string) (string, error) {
+	if name == "" {
+		return password, nil
+	}
+	normalizationsMu.RLock()
+	f, ok := normalizations[name]
+	normalizationsMu.RUnlock()
+	if !ok {
+		return "", ErrUnknownNormalization
+	}
+	return f(password), nil
+}
+
+// normalizeSecure is ApplyNormalization for a SecureBytes password. The
+// returned password must be destroyed by the caller if it is not password
+// itself.
+func normalizeSecure(name string, password *SecureBytes) (*SecureBytes, error) {
+	if name == "" {
+		return password, nil
+	}
+	normalized, err := ApplyNormalization(name, string(password.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	return SecureBytesFromString(normalized), nil
+}
+
+// transforms holds the transforms applied to a password and salt before they
+// are derived into a key, as recorded in a hash's metadata.
+type transforms struct {
+	namespace     string
+	normalization string
+
+	// stages is the raw MetadataStages value; see Strengthen.
+	stages string
+
+	// profile names the profile the hash was created with. Unlike the
+	// others, it does not change the derivation.
+	profile string
+}
+
+func transformsOf(metadata map[string]string) transforms {
+	return transforms{
+		namespace:     metadata[MetadataNamespace],
+		normalization: metadata[MetadataNormalization],
+		stages:        metadata[MetadataStages],
+		profile:       metadata[MetadataProfile],
+	}
+}
+
+// derivation returns t without the transforms that do not change the key.
+func (t transforms) derivation() transforms {
+	t.profile = ""
+	return t
+}
+
+// metadata returns the metadata recording t, or nil if there is none.
+func (t transforms) metadata() map[string]string {
+	if t == (transforms{}) {
+		return nil
+	}
+	m := make(map[string]string, 4)
+	if t.namespace != "" {
+		m[MetadataNamespace] = t.namespace
+	}
+	if t.normalization != "" {
+		m[MetadataNormalization] = t.normalization
+	}
+	if t.stages != "" {
+		m[MetadataStages] = t.stages
+	}
+	if t.profile != "" {
+		m[MetadataProfile] = t.profile
+	}
+	return m
+}
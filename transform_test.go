@@ -0,0 +1,72 @@
+package pbkdf2
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegisterNormalization(t *testing.T) {
+	const name = "test-lower"
+	RegisterNormalization(name, strings.ToLower)
+
+	params := &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32}
+	h := &Hasher{Params: params, Normalization: name}
+	hash, err := h.CreateHash("PassWord")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ParseHash(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Metadata[MetadataNormalization] != name {
+		t.Fatalf("normalization not recorded in %s", hash)
+	}
+
+	// Verification follows the hash, not the Hasher.
+	for _, password := range []string{"password", "PASSWORD"} {
+		if err := Verify(password, hash); err != nil {
+			t.Errorf("%q: %v", password, err)
+		}
+	}
+	if h.NeedsRehash(hash) {
+		t.Error("unexpected NeedsRehash for the current normalization")
+	}
+	if !(&Hasher{Params: params}).NeedsRehash(hash) {
+		t.Error("expected NeedsRehash when the normalization changes")
+	}
+
+	found := false
+	for _, n := range Normalizations() {
+		found = found || n == name
+	}
+	if !found {
+		t.Errorf("expected Normalizations to include %q", name)
+	}
+
+	decoded.Metadata[MetadataNormalization] = "test-unknown"
+	if _, _, err := CheckHash("password", decoded.String()); err != ErrUnknownNormalization {
+		t.Errorf("expected ErrUnknownNormalization, got %v", err)
+	}
+	if _, err := (&Hasher{Params: params, Normalization: "test-unknown"}).CreateHash("password"); err != ErrUnknownNormalization {
+		t.Errorf("expected ErrUnknownNormalization, got %v", err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		fn   func()
+	}{
+		{"duplicate", func() { RegisterNormalization(name, strings.ToUpper) }},
+		{"invalid name", func() { RegisterNormalization("a,b", strings.ToUpper) }},
+		{"nil function", func() { RegisterNormalization("test-nil", nil) }},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected RegisterNormalization to panic", tt.name)
+				}
+			}()
+			tt.fn()
+		}()
+	}
+}
@@ -0,0 +1,125 @@
+package pbkdf2
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"sort"
+	"strconv"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+// The PBKDF2 variants built into this package, named after the HMAC PRF they
+// use, as they appear in the encoded form of a hash:
+//
+//	$pbkdf2-sha512$210000$...
+//	$pbkdf2-sha256$600000$...
+//	$pbkdf2-sha512-256$210000$...
+//	$pbkdf2-sha3-512$210000$...
+//	$pbkdf2-blake2b$210000$...
+//
+// VariantSHA512 is the default. VariantSHA256 is provided for interoperability;
+// it needs roughly three times as many iterations as SHA-512 for the same
+// strength. VariantSHA512_256 costs the same per iteration as SHA-512, and so
+// is as fast on 64-bit machines, while its 256-bit output, truncated from a
+// distinct initial state, is not subject to length extension. The SHA-3 and
+// BLAKE2 variants are provided for environments whose standards require a
+// particular hash function; PBKDF2 with either is no stronger than with
+// SHA-512, and hashes using them cannot be verified by other PBKDF2
+// implementations that only understand the SHA-2 family.
+//
+// Further variants can be added with RegisterVariant.
+const (
+	// PBKDF2-HMAC-SHA512.
+	VariantSHA512 = "pbkdf2-sha512"
+
+	// PBKDF2-HMAC-SHA256.
+	VariantSHA256 = "pbkdf2-sha256"
+
+	// PBKDF2-HMAC-SHA-512/256, as specified in FIPS 180-4.
+	VariantSHA512_256 = "pbkdf2-sha512-256"
+
+	// PBKDF2-HMAC-SHA3-512, as specified in FIPS 202.
+	VariantSHA3_512 = "pbkdf2-sha3-512"
+
+	// PBKDF2-HMAC-BLAKE2b-512, using unkeyed BLAKE2b as the hash function
+	// inside HMAC.
+	VariantBLAKE2b = "pbkdf2-blake2b"
+)
+
+var (
+	variantsMu  sync.RWMutex
+	variantPRFs = map[string]func() hash.Hash{
+		VariantSHA512:     sha512.New,
+		VariantSHA256:     sha256.New,
+		VariantSHA512_256: sha512.New512_256,
+		VariantSHA3_512:   sha3.New512,
+		VariantBLAKE2b:    newBLAKE2b512,
+	}
+)
+
+// RegisterVariant makes a PBKDF2 variant available under name, using the hash
+// function returned by prf as the HMAC PRF. Once registered, the variant can be
+// selected with Params.Variant, and hashes whose variant segment is name are
+// decoded and verified with it.
+//
+// The PRF is security-critical, and the hash function must be one that is
+// safe to use with HMAC: a cryptographic hash function with no known
+// collision or preimage weaknesses, and an output of at least 256 bits.
+// prf must return a new, unkeyed hash.Hash on every call, and must produce
+// the same output for the lifetime of every hash stored with the variant;
+// changing it makes those hashes unverifiable.
+//
+// Names consist of lowercase letters, digits and hyphens, and must start with a
+// letter; by convention they start with "pbkdf2-". VariantLegacySHA1 is
+// reserved. RegisterVariant is intended to be called from init functions. It
+// panics if name is invalid or already registered, or if prf is nil.
+func RegisterVariant(name string, prf func() hash.Hash) {
+	if !validMetadataKey(name) || name == VariantLegacySHA1 {
+		panic("pbkdf2: RegisterVariant called with invalid name " + strconv.Quote(name))
+	}
+	if prf == nil {
+		panic("pbkdf2: RegisterVariant called with nil prf")
+	}
+
+	variantsMu.Lock()
+	defer variantsMu.Unlock()
+	if _, dup := variantPRFs[name]; dup {
+		panic("pbkdf2: RegisterVariant called twice for variant " + strconv.Quote(name))
+	}
+	variantPRFs[name] = prf
+}
+
+// Variants returns the names of the registered variants, sorted.
+func Variants() []string {
+	variantsMu.RLock()
+	defer variantsMu.RUnlock()
+
+	names := make([]string, 0, len(variantPRFs))
+	for name := range variantPRFs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// variantPRF returns the hash function used as the HMAC PRF of variant, where
+// the empty string means VariantSHA512.
+func variantPRF(variant string) (func() hash.Hash, bool) {
+	if variant == "" {
+		variant = VariantSHA512
+	}
+	variantsMu.RLock()
+	prf, ok := variantPRFs[variant]
+	variantsMu.RUnlock()
+	return prf, ok
+}
+
+func newBLAKE2b512() hash.Hash {
+	// New512 only fails for keys longer than 64 bytes.
+	h, _ := blake2b.New512(nil)
+	return h
+}
@@ -0,0 +1,148 @@
+package pbkdf2
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+// Test vectors for the non-default variants. The SHA-256 vector is from RFC
+// 7914, section 11; the others were generated with Python's
+// hashlib.pbkdf2_hmac using OpenSSL's "sha512_256", "sha3_512" and
+// "blake2b512" digests, and the SHA-512/256 ones checked with "openssl kdf".
+var variantVectors = []struct {
+	variant    string
+	password   string
+	salt       string
+	iterations int
+	key        string
+}{
+	{VariantSHA256, "passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+	{VariantSHA512_256, "password", "salt", 1, "4b6a63117d3ec0032624616082c1c1912f56fa5f0c1f94574d515e20e5ddd74a"},
+	{VariantSHA512_256, "password", "salt", 2, "fcfd108c99cc888ec0af9f184885aff5f02d19a956afad9ccea4d56a482b851b"},
+	{VariantSHA512_256, "password", "salt", 4096, "f2fbe5f8ec3618bb145279a8c6a8dfa476c282a3ed53d8c257d51ce021d3877d"},
+	{VariantSHA512_256, "passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, "31cf94e3d8e36aa18d40ad92654ab80f500ed7fb575a2215547db6f82dd227ed0f41215e8f9bb976"},
+	{VariantSHA3_512, "password", "salt", 1, "f7a2684630ec0f81f23abbf606278deeaad1a35053db3c066903d9114ed3fd6e44c23dd5bddbe4e81626880cef267ef7dcf13b183194a5530f154ec57f646e2d"},
+	{VariantSHA3_512, "password", "salt", 4096, "2bfaf2d5ceb6d10f5e262cd902488cfd4489614ecd6709e5ee395dc33f2e9ad7f89d31ad6781e90940e9e534ff44b817159ddcd3bdce3373541186b727340231"},
+	{VariantSHA3_512, "passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, "d60791a4ed27195d813f35510351b9d1ff9ad426215394460950a4fe03dd9f548710e552615ab127"},
+	{VariantBLAKE2b, "password", "salt", 1, "684e7cc1dd9b241d2c977f38a896645da49b85eb13cf8f5c021efc167aad799343c06f50e2959de06a0bca80a154457d8e92e70ebdcdb3722dcf9badd6ff1dfb"},
+	{VariantBLAKE2b, "password", "salt", 4096, "9d4f324ef40b5be658fa0ab94a168664f060c0c9cc85a02ac83f2d44088cb7e7b812ef60e9b1673d4fd77240a68607d72b912e18a0ea4772f476be7583b66970"},
+	{VariantBLAKE2b, "passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, "a46b5335dbdda3d25d19bb11feddd99e452a7c34474198ca3174b63422ac83b0386ef5930ff51646"},
+}
+
+func TestVariantVectors(t *testing.T) {
+	for _, v := range variantVectors {
+		want, err := hex.DecodeString(v.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := &Hash{
+			Params: Params{Iterations: uint32(v.iterations), SaltLength: uint32(len(v.salt)), KeyLength: uint32(len(want)), Variant: v.variant},
+			Salt:   []byte(v.salt),
+			Key:    want,
+		}
+
+		match, params, err := CheckHash(v.password, h.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !match {
+			t.Errorf("%s: vector %q/%q/%d does not match", v.variant, v.password, v.salt, v.iterations)
+		}
+		if *params != h.Params {
+			t.Errorf("expected %#v got %#v", h.Params, *params)
+		}
+	}
+}
+
+func TestCreateHashVariant(t *testing.T) {
+	for _, variant := range []string{VariantSHA512_256, VariantSHA3_512, VariantBLAKE2b} {
+		params := &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32, Variant: variant}
+		hash, err := CreateHash("pa$$word", params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "$" + variant + "$1000$"; hash[:len(want)] != want {
+			t.Errorf("expected %q to start with %q", hash, want)
+		}
+
+		match, checkParams, err := CheckHash("pa$$word", hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !match {
+			t.Error("expected password and hash to match")
+		}
+		if *checkParams != *params {
+			t.Errorf("expected %#v got %#v", *params, *checkParams)
+		}
+		if err := Verify("otherPa$$word", hash); err != ErrMismatchedHashAndPassword {
+			t.Errorf("expected ErrMismatchedHashAndPassword, got %v", err)
+		}
+
+		h, err := ParseHash(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Variant() != variant {
+			t.Errorf("expected variant %q got %q", variant, h.Variant())
+		}
+	}
+
+	// SHA-512 remains the default, and is left implicit in decoded params.
+	params, _, _, err := DecodeHash("$pbkdf2-sha512$1000$AAAA$AAAA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Variant != "" {
+		t.Errorf("expected empty variant, got %q", params.Variant)
+	}
+
+	if _, err := CreateHash("pa$$word", &Params{Iterations: 1000, SaltLength: 16, KeyLength: 32, Variant: "pbkdf2-md5"}); err != ErrIncompatibleVariant {
+		t.Errorf("expected ErrIncompatibleVariant, got %v", err)
+	}
+	if _, err := ParseHash("$$1000$AAAA$AAAA"); err != ErrIncompatibleVariant {
+		t.Errorf("expected ErrIncompatibleVariant, got %v", err)
+	}
+}
+
+func TestRegisterVariant(t *testing.T) {
+	const name = "pbkdf2-test-sha224"
+	RegisterVariant(name, sha256.New224)
+
+	params := &Params{Iterations: 1000, SaltLength: 16, KeyLength: 28, Variant: name}
+	hash, err := CreateHash("pa$$word", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify("pa$$word", hash); err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, v := range Variants() {
+		found = found || v == name
+	}
+	if !found {
+		t.Errorf("expected Variants to include %q", name)
+	}
+
+	for _, tt := range []struct {
+		name string
+		fn   func()
+	}{
+		{"duplicate", func() { RegisterVariant(VariantSHA512, sha256.New) }},
+		{"invalid name", func() { RegisterVariant("pbkdf2$sha224", sha256.New224) }},
+		{"empty name", func() { RegisterVariant("", sha256.New224) }},
+		{"nil prf", func() { RegisterVariant("pbkdf2-nil", nil) }},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected RegisterVariant to panic", tt.name)
+				}
+			}()
+			tt.fn()
+		}()
+	}
+}
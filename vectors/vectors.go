@@ -0,0 +1,200 @@
+// Package vectors generates test vectors for the pbkdf2 package, so that teams
+// implementing the other side of an integration in another language can check
+// interoperability automatically:
+//
+//	vs, err := vectors.Generate(nil)
+//	err = vectors.WriteJSON(f, vs)
+//
+// Each vector gives the inputs of a key derivation, the raw derived key, the
+// hash encoding it as CreateHash would, and alternative encodings of the same
+// hash that pbkdf2.HashesEquivalent treats as equal to it. Salts are fixed
+// rather than random, so the output is the same on every run. The
+// "pbkdf2 vectors" command writes the vectors as a JSON file.
+package vectors
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+// ErrInvalidOptions is returned by Generate if an option holds a zero
+// iteration count or key length, or an empty salt.
+var ErrInvalidOptions = errors.New("vectors: invalid options")
+
+// A Vector is the expected output of one key derivation.
+type Vector struct {
+	// Password is the password as UTF-8 text, and PasswordHex its bytes.
+	Password    string `json:"password"`
+	PasswordHex string `json:"password_hex"`
+
+	// SaltHex is the salt, hex-encoded.
+	SaltHex string `json:"salt_hex"`
+
+	// Variant is the pbkdf2 variant, such as "pbkdf2-sha512", and PRF the
+	// HMAC hash function it uses, such as "SHA-512".
+	Variant string `json:"variant"`
+	PRF     string `json:"prf"`
+
+	Iterations uint32 `json:"iterations"`
+	KeyLength  uint32 `json:"key_length"`
+
+	// KeyHex is the derived key, hex-encoded.
+	KeyHex string `json:"key_hex"`
+
+	// Hash is the canonical encoding, as produced by pbkdf2.CreateHash.
+	Hash string `json:"hash"`
+
+	// Equivalent holds other encodings of the same hash, keyed by a name for
+	// the encoding, which implementations that accept them, as
+	// pbkdf2.HashesEquivalent does, must decode to the same key.
+	Equivalent map[string]string `json:"equivalent"`
+}
+
+// Options select the vectors generated. Zero fields take the defaults
+// described below.
+type Options struct {
+	// Passwords defaults to an empty password, an ASCII one, one with
+	// non-ASCII characters, and one longer than any PRF's block size, which
+	// HMAC hashes before use.
+	Passwords []string
+
+	// Salts defaults to a 16-byte ASCII salt and a 16-byte binary one.
+	Salts [][]byte
+
+	// Variants defaults to every variant registered with the pbkdf2 package.
+	Variants []string
+
+	// Iterations defaults to 1, 2 and 1000.
+	Iterations []uint32
+
+	// KeyLengths defaults to 16, 64 and 100 bytes; the last spans several
+	// PRF output blocks for every built-in variant.
+	KeyLengths []uint32
+}
+
+// prfNames maps the built-in variants to the names of their hash functions.
+var prfNames = map[string]string{
+	pbkdf2.VariantSHA512:     "SHA-512",
+	pbkdf2.VariantSHA256:     "SHA-256",
+	pbkdf2.VariantSHA512_256: "SHA-512/256",
+	pbkdf2.VariantSHA3_512:   "SHA3-512",
+	pbkdf2.VariantBLAKE2b:    "BLAKE2b-512",
+}
+
+func (o *Options) withDefaults() Options {
+	var opts Options
+	if o != nil {
+		opts = *o
+	}
+	if opts.Passwords == nil {
+		opts.Passwords = []string{"", "password", "pässwörd 🔑", strings.Repeat("long password ", 15)}
+	}
+	if opts.Salts == nil {
+		binary := make([]byte, 16)
+		for i := range binary {
+			binary[i] = byte(i * 17)
+		}
+		opts.Salts = [][]byte{[]byte("saltSALTsaltSALT"), binary}
+	}
+	if opts.Variants == nil {
+		opts.Variants = pbkdf2.Variants()
+	}
+	if opts.Iterations == nil {
+		opts.Iterations = []uint32{1, 2, 1000}
+	}
+	if opts.KeyLengths == nil {
+		opts.KeyLengths = []uint32{16, 64, 100}
+	}
+	return opts
+}
+
+// Generate returns a vector for every combination of the options, or of the
+// defaults if opts is nil. It returns ErrInvalidOptions if an iteration count
+// or key length is zero, or a salt is empty, and pbkdf2.ErrIncompatibleVariant
+// if a variant is not supported.
+func Generate(opts *Options) ([]Vector, error) {
+	o := opts.withDefaults()
+	for _, n := range append(append([]uint32(nil), o.Iterations...), o.KeyLengths...) {
+		if n == 0 {
+			return nil, ErrInvalidOptions
+		}
+	}
+
+	var vs []Vector
+	for _, variant := range o.Variants {
+		for _, password := range o.Passwords {
+			for _, salt := range o.Salts {
+				if len(salt) == 0 {
+					return nil, ErrInvalidOptions
+				}
+				for _, iterations := range o.Iterations {
+					for _, keyLength := range o.KeyLengths {
+						v, err := generate(variant, password, salt, iterations, keyLength)
+						if err != nil {
+							return nil, err
+						}
+						vs = append(vs, *v)
+					}
+				}
+			}
+		}
+	}
+	return vs, nil
+}
+
+func generate(variant, password string, salt []byte, iterations, keyLength uint32) (*Vector, error) {
+	params := &pbkdf2.Params{Iterations: iterations, SaltLength: uint32(len(salt)), KeyLength: keyLength, Variant: variant}
+	if variant == pbkdf2.VariantSHA512 {
+		params.Variant = ""
+	}
+	secret := pbkdf2.SecureBytesFromString(password)
+	defer secret.Destroy()
+	key, err := pbkdf2.DeriveKey(secret, salt, params)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Destroy()
+
+	h := &pbkdf2.Hash{Params: *params, Salt: salt, Key: key.Bytes()}
+	v := &Vector{
+		Password:    password,
+		PasswordHex: hex.EncodeToString([]byte(password)),
+		SaltHex:     hex.EncodeToString(salt),
+		Variant:     h.Variant(),
+		PRF:         prfNames[h.Variant()],
+		Iterations:  iterations,
+		KeyLength:   keyLength,
+		KeyHex:      hex.EncodeToString(key.Bytes()),
+		Hash:        h.String(),
+	}
+	if v.PRF == "" {
+		v.PRF = "registered"
+	}
+
+	// The alternative encodings tolerated by pbkdf2.HashesEquivalent.
+	padded := base64.StdEncoding
+	saltB64, keyB64 := base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key.Bytes())
+	iters := strconv.FormatUint(uint64(iterations), 10)
+	v.Equivalent = map[string]string{
+		"padded":    "$" + v.Variant + "$" + iters + "$" + padded.EncodeToString(salt) + "$" + padded.EncodeToString(key.Bytes()),
+		"passlib":   "$" + v.Variant + "$" + iters + "$" + strings.ReplaceAll(saltB64, "+", ".") + "$" + strings.ReplaceAll(keyB64, "+", "."),
+		"phc":       "$" + v.Variant + "$i=" + iters + "$" + saltB64 + "$" + keyB64,
+		"uppercase": "$" + strings.ToUpper(v.Variant) + "$" + iters + "$" + saltB64 + "$" + keyB64,
+	}
+	return v, nil
+}
+
+// WriteJSON writes vs to w as an indented JSON array.
+func WriteJSON(w io.Writer, vs []Vector) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(vs)
+}
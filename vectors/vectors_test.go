@@ -0,0 +1,93 @@
+package vectors
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/pganguli/pbkdf2"
+)
+
+func TestGenerate(t *testing.T) {
+	vs, err := Generate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := len(pbkdf2.Variants()) * 4 * 2 * 3 * 3; len(vs) != want {
+		t.Fatalf("expected %d vectors, got %d", want, len(vs))
+	}
+
+	for _, v := range vs {
+		if err := pbkdf2.Verify(v.Password, v.Hash); err != nil {
+			t.Fatalf("%s: %v", v.Hash, err)
+		}
+		for name, alt := range v.Equivalent {
+			if ok, err := pbkdf2.HashesEquivalent(v.Hash, alt); err != nil || !ok {
+				t.Fatalf("%s encoding %s not equivalent to %s: %v", name, alt, v.Hash, err)
+			}
+		}
+	}
+}
+
+func TestGenerateKnownAnswers(t *testing.T) {
+	// Generated with Python's hashlib.pbkdf2_hmac.
+	tests := []struct {
+		variant, password string
+		salt              []byte
+		iterations        uint32
+		keyLength         uint32
+		keyHex            string
+	}{
+		{pbkdf2.VariantSHA512, "password", []byte("saltSALTsaltSALT"), 1000, 16, "2febe385f6399aa2a20a9926d1822e2f"},
+		{pbkdf2.VariantSHA256, "", []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}, 2, 100,
+			"33736fb1978736e3fef2d6808cf9b268149c997d526da0966965382d68958f6f5bb77afc5563e3dd6ffb946625b7ddef2991a5a46a05a4cb8c4b50b5b12724d80402a4094acf669290a7f052ebfe6076d5bcf5a4a7ae571dfc3adab5708bc75db1ecf780"},
+	}
+	for _, tt := range tests {
+		vs, err := Generate(&Options{
+			Passwords:  []string{tt.password},
+			Salts:      [][]byte{tt.salt},
+			Variants:   []string{tt.variant},
+			Iterations: []uint32{tt.iterations},
+			KeyLengths: []uint32{tt.keyLength},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(vs) != 1 || vs[0].KeyHex != tt.keyHex {
+			t.Fatalf("%s: expected key %s, got %+v", tt.variant, tt.keyHex, vs)
+		}
+		if vs[0].Variant != tt.variant {
+			t.Errorf("expected variant %s, got %s", tt.variant, vs[0].Variant)
+		}
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	if _, err := Generate(&Options{Iterations: []uint32{0}}); err != ErrInvalidOptions {
+		t.Errorf("expected ErrInvalidOptions for zero iterations, got %v", err)
+	}
+	if _, err := Generate(&Options{Salts: [][]byte{nil}}); err != ErrInvalidOptions {
+		t.Errorf("expected ErrInvalidOptions for empty salt, got %v", err)
+	}
+	if _, err := Generate(&Options{Variants: []string{"pbkdf2-md5"}}); err != pbkdf2.ErrIncompatibleVariant {
+		t.Errorf("expected ErrIncompatibleVariant, got %v", err)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	vs, err := Generate(&Options{Variants: []string{pbkdf2.VariantSHA256}, Iterations: []uint32{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, vs); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Vector
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(vs) || decoded[0].Hash != vs[0].Hash || decoded[0].Equivalent["phc"] != vs[0].Equivalent["phc"] {
+		t.Fatalf("round trip mismatch: %+v", decoded)
+	}
+}